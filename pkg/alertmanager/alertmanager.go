@@ -279,6 +279,10 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		am.mux.Handle(a, http.NotFoundHandler())
 	}
 
+	// Register the silences import and export endpoints, which are not part of the Alertmanager API.
+	am.mux.HandleFunc(path.Join(am.cfg.ExternalURL.Path, silencesExportPath), am.exportSilencesHandler)
+	am.mux.HandleFunc(path.Join(am.cfg.ExternalURL.Path, silencesImportPath), am.importSilencesHandler)
+
 	am.dispatcherMetrics = dispatch.NewDispatcherMetrics(true, am.registry)
 
 	//TODO: From this point onward, the alertmanager _might_ receive requests - we need to make sure we've settled and are ready.
@@ -101,6 +101,52 @@ func (am *MultitenantAlertmanager) GetUserConfig(w http.ResponseWriter, r *http.
 	}
 }
 
+// UserTemplates is used to communicate the effective templates of a user, with the default templates
+// merged with the user templates.
+type UserTemplates struct {
+	TemplateFiles map[string]string `yaml:"template_files"`
+}
+
+// GetUserTemplates returns the effective templates of the user, merging the default templates with the
+// templates of the user configuration, if any.
+func (am *MultitenantAlertmanager) GetUserTemplates(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	var userTemplates []*alertspb.TemplateDesc
+	cfg, err := am.store.GetAlertConfig(r.Context(), userID)
+	if err == nil {
+		userTemplates = cfg.Templates
+	} else if !errors.Is(err, alertspb.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	templateFiles := map[string]string{}
+	for _, tmpl := range mergeTemplates(am.defaultTemplates, userTemplates) {
+		templateFiles[tmpl.Filename] = tmpl.Body
+	}
+
+	d, err := yaml.Marshal(&UserTemplates{TemplateFiles: templateFiles})
+	if err != nil {
+		level.Error(logger).Log("msg", errMarshallingYAML, "err", err, "user", userID)
+		http.Error(w, fmt.Sprintf("%s: %s", errMarshallingYAML, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
 func (am *MultitenantAlertmanager) SetUserConfig(w http.ResponseWriter, r *http.Request) {
 	logger := util_log.WithContext(r.Context(), am.logger)
 	userID, err := tenant.TenantID(r.Context())
@@ -748,6 +748,42 @@ func TestMultitenantAlertmanager_DeleteUserConfig(t *testing.T) {
 	}
 }
 
+func TestMultitenantAlertmanager_GetUserTemplates(t *testing.T) {
+	storage := objstore.NewInMemBucket()
+	alertStore := bucketclient.NewBucketAlertStore(storage, nil, log.NewNopLogger())
+
+	am := &MultitenantAlertmanager{
+		store:  alertStore,
+		logger: util_log.Logger,
+		defaultTemplates: []*alertspb.TemplateDesc{
+			{Filename: "default.tpl", Body: "default"},
+			{Filename: "shared.tpl", Body: "default shared"},
+		},
+	}
+
+	getTemplates := func(userID string) string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/alerts/templates", nil)
+		req = req.WithContext(user.InjectOrgID(context.Background(), userID))
+		rec := httptest.NewRecorder()
+		am.GetUserTemplates(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	// Without configuration, only the default templates are returned.
+	require.Equal(t, "template_files:\n    default.tpl: default\n    shared.tpl: default shared\n", getTemplates("test_user"))
+
+	require.NoError(t, alertStore.SetAlertConfig(context.Background(), alertspb.AlertConfigDesc{
+		User:      "test_user",
+		RawConfig: "config",
+		Templates: []*alertspb.TemplateDesc{
+			{Filename: "shared.tpl", Body: "tenant shared"},
+			{Filename: "tenant.tpl", Body: "tenant"},
+		},
+	}))
+	require.Equal(t, "template_files:\n    default.tpl: default\n    shared.tpl: tenant shared\n    tenant.tpl: tenant\n", getTemplates("test_user"))
+}
+
 func TestAMConfigListUserConfig(t *testing.T) {
 	testCases := map[string]*UserConfig{
 		"user1": {
@@ -76,7 +76,7 @@ func (d *Distributor) isQuorumWritePath(p string) bool {
 }
 
 func (d *Distributor) isUnaryWritePath(p string) bool {
-	return strings.HasSuffix(p, "/silences")
+	return strings.HasSuffix(p, "/silences") || strings.HasSuffix(p, "/v2/silences/import")
 }
 
 func (d *Distributor) isUnaryDeletePath(p string) bool {
@@ -105,6 +105,9 @@ func (d *Distributor) isQuorumReadPath(p string) (bool, merger.Merger) {
 	if strings.HasSuffix(path.Dir(p), "/v2/silence") {
 		return true, merger.V2SilenceID{}
 	}
+	if strings.HasSuffix(p, "/v2/silences/export") {
+		return true, merger.V2Silences{}
+	}
 	return false, nil
 }
 
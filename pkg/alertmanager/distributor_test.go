@@ -190,6 +190,24 @@ func TestDistributor_DistributeRequest(t *testing.T) {
 			expStatusCode:      http.StatusOK,
 			expectedTotalCalls: 1,
 			route:              "/silence/id",
+		}, {
+			name:               "Read /v2/silences/export is sent to 3 AMs",
+			numAM:              5,
+			numHappyAM:         5,
+			replicationFactor:  3,
+			isRead:             true,
+			expStatusCode:      http.StatusOK,
+			expectedTotalCalls: 3,
+			route:              "/v2/silences/export",
+			responseBody:       []byte(`[]`),
+		}, {
+			name:               "Write /v2/silences/import is sent to only 1 AM",
+			numAM:              5,
+			numHappyAM:         5,
+			replicationFactor:  3,
+			expStatusCode:      http.StatusOK,
+			expectedTotalCalls: 1,
+			route:              "/v2/silences/import",
 		}, {
 			name:               "Read /status is sent to only 1 AM",
 			numAM:              5,
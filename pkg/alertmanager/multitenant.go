@@ -27,6 +27,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/alertmanager/cluster/clusterpb"
 	amconfig "github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/template"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/httpgrpc"
@@ -75,6 +76,8 @@ type MultitenantAlertmanagerConfig struct {
 
 	FallbackConfigFile string `yaml:"fallback_config_file"`
 
+	DefaultTemplatesDir string `yaml:"default_templates_dir" category:"experimental"`
+
 	PeerTimeout time.Duration `yaml:"peer_timeout" category:"advanced"`
 
 	EnableAPI bool `yaml:"enable_api" category:"advanced"`
@@ -102,6 +105,7 @@ func (cfg *MultitenantAlertmanagerConfig) RegisterFlags(f *flag.FlagSet, logger
 	f.Var(&cfg.ExternalURL, "alertmanager.web.external-url", "The URL under which Alertmanager is externally reachable (eg. could be different than -http.alertmanager-http-prefix in case Alertmanager is served via a reverse proxy). This setting is used both to configure the internal requests router and to generate links in alert templates. If the external URL has a path portion, it will be used to prefix all HTTP endpoints served by Alertmanager, both the UI and API.")
 
 	f.StringVar(&cfg.FallbackConfigFile, "alertmanager.configs.fallback", "", "Filename of fallback config to use if none specified for instance.")
+	f.StringVar(&cfg.DefaultTemplatesDir, "alertmanager.configs.default-templates-dir", "", "Directory with the default notification template files available to all tenants. The default templates are merged with the templates of each tenant: a tenant template overrides the default template with the same filename, and the templates defined by a tenant override the default templates with the same name.")
 	f.DurationVar(&cfg.PollInterval, "alertmanager.configs.poll-interval", 15*time.Second, "How frequently to poll Alertmanager configs.")
 
 	f.BoolVar(&cfg.EnableAPI, "alertmanager.enable-api", true, "Enable the alertmanager config API.")
@@ -254,6 +258,9 @@ type MultitenantAlertmanager struct {
 	// effect here.
 	fallbackConfig string
 
+	// The default templates are merged with the templates of each tenant.
+	defaultTemplates []*alertspb.TemplateDesc
+
 	alertmanagersMtx sync.Mutex
 	alertmanagers    map[string]*Alertmanager
 	// Stores the current set of configurations we're running in each tenant's Alertmanager.
@@ -309,9 +316,15 @@ func NewMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, store alerts
 }
 
 func createMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, fallbackConfig []byte, store alertstore.AlertStore, ringStore kv.Client, limits Limits, logger log.Logger, registerer prometheus.Registerer) (*MultitenantAlertmanager, error) {
+	defaultTemplates, err := loadDefaultTemplates(cfg.DefaultTemplatesDir)
+	if err != nil {
+		return nil, err
+	}
+
 	am := &MultitenantAlertmanager{
 		cfg:                 cfg,
 		fallbackConfig:      string(fallbackConfig),
+		defaultTemplates:    defaultTemplates,
 		cfgs:                map[string]alertspb.AlertConfigDesc{},
 		alertmanagers:       map[string]*Alertmanager{},
 		alertmanagerMetrics: newAlertmanagerMetrics(),
@@ -639,7 +652,7 @@ func (am *MultitenantAlertmanager) setConfig(cfg alertspb.AlertConfigDesc) error
 		}
 	}
 
-	for _, tmpl := range cfg.Templates {
+	for _, tmpl := range mergeTemplates(am.defaultTemplates, cfg.Templates) {
 		templateFilePath, err := safeTemplateFilepath(userTemplateDir, tmpl.Filename)
 		if err != nil {
 			return err
@@ -699,6 +712,7 @@ func (am *MultitenantAlertmanager) setConfig(cfg alertspb.AlertConfigDesc) error
 	if userAmConfig == nil {
 		return fmt.Errorf("no usable Alertmanager configuration for %v", cfg.User)
 	}
+	userAmConfig.Templates = withDefaultTemplateFiles(am.defaultTemplates, userAmConfig.Templates)
 
 	// If no Alertmanager instance exists for this user yet, start one.
 	if !hasExisting {
@@ -1194,3 +1208,91 @@ func storeTemplateFile(templateFilepath, content string) (bool, error) {
 
 	return true, nil
 }
+
+// loadDefaultTemplates loads the default templates from the files of the given directory, and validates them.
+// The hidden files and the subdirectories are ignored.
+func loadDefaultTemplates(dir string) ([]*alertspb.TemplateDesc, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read default templates directory %q: %s", dir, err)
+	}
+
+	var templates []*alertspb.TemplateDesc
+	var templateFiles []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		// Stat the file to follow the symbolic links, like the ones of the Kubernetes ConfigMap volumes.
+		templateFilepath := filepath.Join(dir, entry.Name())
+		info, err := os.Stat(templateFilepath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read default template %q: %s", templateFilepath, err)
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		body, err := os.ReadFile(templateFilepath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read default template %q: %s", templateFilepath, err)
+		}
+
+		templates = append(templates, &alertspb.TemplateDesc{Filename: entry.Name(), Body: string(body)})
+		templateFiles = append(templateFiles, templateFilepath)
+	}
+
+	if _, err := template.FromGlobs(templateFiles...); err != nil {
+		return nil, fmt.Errorf("unable to load default templates from %q: %s", dir, err)
+	}
+
+	return templates, nil
+}
+
+// mergeTemplates returns the default templates not overridden by a tenant template with the same filename,
+// followed by the tenant templates.
+func mergeTemplates(defaults, tenant []*alertspb.TemplateDesc) []*alertspb.TemplateDesc {
+	if len(defaults) == 0 {
+		return tenant
+	}
+
+	overridden := make(map[string]struct{}, len(tenant))
+	for _, tmpl := range tenant {
+		overridden[tmpl.Filename] = struct{}{}
+	}
+
+	merged := make([]*alertspb.TemplateDesc, 0, len(defaults)+len(tenant))
+	for _, tmpl := range defaults {
+		if _, ok := overridden[tmpl.Filename]; !ok {
+			merged = append(merged, tmpl)
+		}
+	}
+	return append(merged, tenant...)
+}
+
+// withDefaultTemplateFiles returns the template files referenced by the tenant Alertmanager configuration, preceded
+// by the default template files it doesn't reference. The templates are loaded in order, so the templates defined
+// by the tenant override the default templates with the same name.
+func withDefaultTemplateFiles(defaults []*alertspb.TemplateDesc, templateFiles []string) []string {
+	if len(defaults) == 0 {
+		return templateFiles
+	}
+
+	referenced := make(map[string]struct{}, len(templateFiles))
+	for _, name := range templateFiles {
+		referenced[name] = struct{}{}
+	}
+
+	result := make([]string, 0, len(defaults)+len(templateFiles))
+	for _, tmpl := range defaults {
+		if _, ok := referenced[tmpl.Filename]; !ok {
+			result = append(result, tmpl.Filename)
+		}
+	}
+	return append(result, templateFiles...)
+}
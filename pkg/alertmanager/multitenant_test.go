@@ -34,6 +34,7 @@ import (
 	"github.com/prometheus/alertmanager/cluster/clusterpb"
 	"github.com/prometheus/alertmanager/notify"
 	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/template"
 	"github.com/prometheus/alertmanager/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -2038,6 +2039,84 @@ func TestStoreTemplateFile(t *testing.T) {
 	require.False(t, changed)
 }
 
+func TestMultitenantAlertmanager_defaultTemplates(t *testing.T) {
+	ctx := context.Background()
+
+	defaultTemplatesDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(defaultTemplatesDir, "default.tpl"), []byte(`{{ define "title" }}default title{{ end }}{{ define "text" }}default text{{ end }}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(defaultTemplatesDir, "shared.tpl"), []byte(`{{ define "shared" }}default shared{{ end }}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(defaultTemplatesDir, ".hidden"), []byte(`{{ invalid`), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(defaultTemplatesDir, "subdir"), 0755))
+
+	store := prepareInMemoryAlertStore()
+	require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+		User: "user1",
+		RawConfig: simpleConfigOne + `
+templates:
+- 'tenant.tpl'
+`,
+		Templates: []*alertspb.TemplateDesc{
+			{Filename: "tenant.tpl", Body: `{{ define "title" }}tenant title{{ end }}`},
+			{Filename: "shared.tpl", Body: `{{ define "shared" }}tenant shared{{ end }}`},
+		},
+	}))
+
+	cfg := mockAlertmanagerConfig(t)
+	cfg.DefaultTemplatesDir = defaultTemplatesDir
+	am := setupSingleMultitenantAlertmanager(t, cfg, store, nil, log.NewNopLogger(), nil)
+	require.NoError(t, am.loadAndSyncConfigs(ctx, reasonPeriodic))
+	require.Len(t, am.alertmanagers, 1)
+
+	// The default templates are stored with the tenant templates, unless overridden by a tenant template with the same filename.
+	userTemplatesDir := filepath.Join(am.getPerUserDirectories()["user1"], templatesDir)
+	for filename, expected := range map[string]string{
+		"default.tpl": `{{ define "title" }}default title{{ end }}{{ define "text" }}default text{{ end }}`,
+		"shared.tpl":  `{{ define "shared" }}tenant shared{{ end }}`,
+		"tenant.tpl":  `{{ define "title" }}tenant title{{ end }}`,
+	} {
+		body, err := os.ReadFile(filepath.Join(userTemplatesDir, filename))
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(body))
+	}
+	assert.False(t, fileExists(t, filepath.Join(userTemplatesDir, ".hidden")))
+
+	// The templates defined by the tenant override the default templates with the same name.
+	var templateFiles []string
+	for _, name := range withDefaultTemplateFiles(am.defaultTemplates, []string{"tenant.tpl"}) {
+		templateFiles = append(templateFiles, filepath.Join(userTemplatesDir, name))
+	}
+	tmpl, err := template.FromGlobs(templateFiles...)
+	require.NoError(t, err)
+	for name, expected := range map[string]string{"title": "tenant title", "text": "default text", "shared": "tenant shared"} {
+		actual, err := tmpl.ExecuteTextString(fmt.Sprintf(`{{ template %q . }}`, name), nil)
+		require.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestLoadDefaultTemplates(t *testing.T) {
+	templates, err := loadDefaultTemplates("")
+	require.NoError(t, err)
+	assert.Empty(t, templates)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.tpl"), []byte(`{{ define "b" }}b{{ end }}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.tpl"), []byte(`{{ define "a" }}a{{ end }}`), 0644))
+	templates, err = loadDefaultTemplates(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []*alertspb.TemplateDesc{
+		{Filename: "a.tpl", Body: `{{ define "a" }}a{{ end }}`},
+		{Filename: "b.tpl", Body: `{{ define "b" }}b{{ end }}`},
+	}, templates)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "invalid.tpl"), []byte(`{{ define "invalid" }}`), 0644))
+	_, err = loadDefaultTemplates(dir)
+	require.Error(t, err)
+
+	_, err = loadDefaultTemplates(filepath.Join(dir, "missing"))
+	require.Error(t, err)
+}
+
 func TestMultitenantAlertmanager_verifyRateLimitedEmailConfig(t *testing.T) {
 	ctx := context.Background()
 
@@ -0,0 +1,260 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/go-openapi/strfmt"
+	"github.com/pkg/errors"
+	v2 "github.com/prometheus/alertmanager/api/v2"
+	v2_models "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/silence"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+const (
+	silencesExportPath = "/api/v2/silences/export"
+	silencesImportPath = "/api/v2/silences/import"
+
+	silenceImportStatusImported = "imported"
+	silenceImportStatusSkipped  = "skipped"
+	silenceImportStatusFailed   = "failed"
+)
+
+// SilencesImportResult is the result of the import of silences.
+type SilencesImportResult struct {
+	Silences []SilenceImportResult `json:"silences"`
+}
+
+// SilenceImportResult is the result of the import of a silence.
+type SilenceImportResult struct {
+	// ID is the ID of the silence in the imported document.
+	ID string `json:"id"`
+	// ImportedID is the ID of the imported silence, which is the same as ID unless the silence
+	// has no ID in the imported document.
+	ImportedID string `json:"importedId,omitempty"`
+	Status     string `json:"status"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// exportSilencesHandler responds with all the silences of the tenant, including the expired ones, in the
+// same format as the GET /api/v2/silences endpoint. The response can be imported with importSilencesHandler.
+func (am *Alertmanager) exportSilencesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sils, _, err := am.silences.Query()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	exported := make(v2_models.GettableSilences, 0, len(sils))
+	for _, sil := range sils {
+		s, err := v2.GettableSilenceFromProto(sil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		exported = append(exported, &s)
+	}
+	v2.SortSilences(exported)
+
+	d, err := json.Marshal(exported)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// importSilencesHandler imports the silences exported by exportSilencesHandler, possibly from another tenant
+// or cluster. The IDs of the imported silences are preserved, so that importing the same silences again skips
+// them. Expired silences are skipped.
+func (am *Alertmanager) importSilencesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	logger := util_log.WithContext(r.Context(), am.logger)
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var imported v2_models.GettableSilences
+	if err := json.Unmarshal(payload, &imported); err != nil {
+		http.Error(w, fmt.Sprintf("unable to unmarshal the silences: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	result := SilencesImportResult{Silences: make([]SilenceImportResult, 0, len(imported))}
+	for _, s := range imported {
+		res := am.importSilence(s)
+		if res.Status == silenceImportStatusFailed {
+			level.Warn(logger).Log("msg", "failed to import silence", "id", res.ID, "err", res.Reason)
+		}
+		result.Silences = append(result.Silences, res)
+	}
+
+	d, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+func (am *Alertmanager) importSilence(s *v2_models.GettableSilence) SilenceImportResult {
+	res := SilenceImportResult{}
+	if s == nil {
+		res.Status = silenceImportStatusFailed
+		res.Reason = "empty silence"
+		return res
+	}
+	if s.ID != nil {
+		res.ID = *s.ID
+	}
+
+	if err := s.Silence.Validate(strfmt.Default); err != nil {
+		res.Status = silenceImportStatusFailed
+		res.Reason = err.Error()
+		return res
+	}
+	sil, err := v2.PostableSilenceToProto(&v2_models.PostableSilence{ID: res.ID, Silence: s.Silence})
+	if err != nil {
+		res.Status = silenceImportStatusFailed
+		res.Reason = err.Error()
+		return res
+	}
+
+	now := time.Now().UTC()
+	if !sil.EndsAt.After(now) {
+		res.Status = silenceImportStatusSkipped
+		res.Reason = "silence is expired"
+		return res
+	}
+
+	if sil.Id == "" {
+		// The silence can't be matched with an existing one, so it's created with a new ID.
+		id, err := am.silences.Set(sil)
+		if err != nil {
+			res.Status = silenceImportStatusFailed
+			res.Reason = err.Error()
+			return res
+		}
+		res.ImportedID = id
+		res.Status = silenceImportStatusImported
+		return res
+	}
+
+	if _, err := am.silences.QueryOne(silence.QIDs(sil.Id)); err == nil {
+		res.Status = silenceImportStatusSkipped
+		res.Reason = "silence already exists"
+		return res
+	} else if !errors.Is(err, silence.ErrNotFound) {
+		res.Status = silenceImportStatusFailed
+		res.Reason = err.Error()
+		return res
+	}
+
+	// The silences API can't create a silence with a given ID, so the silence is merged like the silences
+	// received from the other replicas, which also replicates it.
+	sil.UpdatedAt = now
+	if err := validateImportedSilence(sil); err != nil {
+		res.Status = silenceImportStatusFailed
+		res.Reason = err.Error()
+		return res
+	}
+	b, err := marshalMeshSilence(&silencepb.MeshSilence{
+		Silence:   sil,
+		ExpiresAt: sil.EndsAt.Add(am.cfg.Retention),
+	})
+	if err != nil {
+		res.Status = silenceImportStatusFailed
+		res.Reason = err.Error()
+		return res
+	}
+	if err := am.silences.Merge(b); err != nil {
+		res.Status = silenceImportStatusFailed
+		res.Reason = err.Error()
+		return res
+	}
+
+	res.ImportedID = sil.Id
+	res.Status = silenceImportStatusImported
+	return res
+}
+
+// validateImportedSilence validates the silence like the Alertmanager does when a silence is created.
+func validateImportedSilence(s *silencepb.Silence) error {
+	if len(s.Matchers) == 0 {
+		return errors.New("at least one matcher required")
+	}
+	allMatchEmpty := true
+	for i, m := range s.Matchers {
+		if err := silence.ValidateMatcher(m); err != nil {
+			return fmt.Errorf("invalid label matcher %d: %s", i, err)
+		}
+		allMatchEmpty = allMatchEmpty && matcherMatchesEmpty(m)
+	}
+	if allMatchEmpty {
+		return errors.New("at least one matcher must not match the empty string")
+	}
+	if s.StartsAt.IsZero() {
+		return errors.New("invalid zero start timestamp")
+	}
+	if s.EndsAt.Before(s.StartsAt) {
+		return errors.New("end time must not be before start time")
+	}
+	return nil
+}
+
+func matcherMatchesEmpty(m *silencepb.Matcher) bool {
+	switch m.Type {
+	case silencepb.Matcher_EQUAL:
+		return m.Pattern == ""
+	case silencepb.Matcher_REGEXP:
+		matched, _ := regexp.MatchString(m.Pattern, "")
+		return matched
+	default:
+		return false
+	}
+}
+
+// marshalMeshSilence encodes the silence with its size prefix, like the Alertmanager does to replicate silences.
+func marshalMeshSilence(s *silencepb.MeshSilence) ([]byte, error) {
+	data, err := s.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(data))
+	b = b[:binary.PutUvarint(b, uint64(len(data)))]
+	return append(b, data...), nil
+}
@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	v2_models "github.com/prometheus/alertmanager/api/v2/models"
+	"github.com/prometheus/alertmanager/silence"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertmanager_ExportImportSilences(t *testing.T) {
+	newAlertmanager := func(userID string) *Alertmanager {
+		am, err := New(&Config{
+			UserID:          userID,
+			Logger:          log.NewNopLogger(),
+			Limits:          &mockAlertManagerLimits{},
+			TenantDataDir:   t.TempDir(),
+			ExternalURL:     &url.URL{Path: "/am"},
+			ShardingEnabled: true,
+			Store:           prepareInMemoryAlertStore(),
+			Replicator:      &stubReplicator{},
+			// The replication factor must be greater than 1 for the silences to be replicated.
+			ReplicationFactor: 2,
+			Retention:         time.Hour,
+			// We have to set this interval non-zero, though we don't need the persister to do anything.
+			PersisterConfig: PersisterConfig{Interval: time.Hour},
+		}, prometheus.NewPedanticRegistry())
+		require.NoError(t, err)
+		t.Cleanup(am.StopAndWait)
+		require.NoError(t, am.WaitInitialStateSync(context.Background()))
+		return am
+	}
+
+	exportSilences := func(am *Alertmanager) v2_models.GettableSilences {
+		rec := httptest.NewRecorder()
+		am.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/am/api/v2/silences/export", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var exported v2_models.GettableSilences
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &exported))
+		return exported
+	}
+
+	importSilences := func(am *Alertmanager, body []byte) SilencesImportResult {
+		rec := httptest.NewRecorder()
+		am.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/am/api/v2/silences/import", bytes.NewReader(body)))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var result SilencesImportResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		return result
+	}
+
+	source := newAlertmanager("user-1")
+	now := time.Now()
+	activeID, err := source.silences.Set(&silencepb.Silence{
+		Matchers:  []*silencepb.Matcher{{Name: "alertname", Pattern: "Active", Type: silencepb.Matcher_EQUAL}},
+		StartsAt:  now.Add(-time.Minute),
+		EndsAt:    now.Add(time.Hour),
+		CreatedBy: "alice",
+		Comment:   "active",
+	})
+	require.NoError(t, err)
+	pendingID, err := source.silences.Set(&silencepb.Silence{
+		Matchers:  []*silencepb.Matcher{{Name: "alertname", Pattern: "Pending.*", Type: silencepb.Matcher_REGEXP}},
+		StartsAt:  now.Add(time.Hour),
+		EndsAt:    now.Add(2 * time.Hour),
+		CreatedBy: "bob",
+		Comment:   "pending",
+	})
+	require.NoError(t, err)
+	expiredID, err := source.silences.Set(&silencepb.Silence{
+		Matchers:  []*silencepb.Matcher{{Name: "alertname", Pattern: "Expired", Type: silencepb.Matcher_EQUAL}},
+		StartsAt:  now.Add(-time.Minute),
+		EndsAt:    now.Add(time.Hour),
+		CreatedBy: "carol",
+		Comment:   "expired",
+	})
+	require.NoError(t, err)
+	require.NoError(t, source.silences.Expire(expiredID))
+
+	exported := exportSilences(source)
+	require.Len(t, exported, 3)
+	body, err := json.Marshal(exported)
+	require.NoError(t, err)
+
+	t.Run("should import the silences preserving their IDs", func(t *testing.T) {
+		target := newAlertmanager("user-2")
+
+		result := importSilences(target, body)
+		assert.ElementsMatch(t, []SilenceImportResult{
+			{ID: activeID, ImportedID: activeID, Status: "imported"},
+			{ID: pendingID, ImportedID: pendingID, Status: "imported"},
+			{ID: expiredID, Status: "skipped", Reason: "silence is expired"},
+		}, result.Silences)
+
+		active, err := target.silences.QueryOne(silence.QIDs(activeID))
+		require.NoError(t, err)
+		assert.Equal(t, "alice", active.CreatedBy)
+		assert.Equal(t, "active", active.Comment)
+		assert.Equal(t, []*silencepb.Matcher{{Name: "alertname", Pattern: "Active", Type: silencepb.Matcher_EQUAL}}, active.Matchers)
+
+		pending, err := target.silences.QueryOne(silence.QIDs(pendingID))
+		require.NoError(t, err)
+		assert.Equal(t, []*silencepb.Matcher{{Name: "alertname", Pattern: "Pending.*", Type: silencepb.Matcher_REGEXP}}, pending.Matchers)
+
+		_, err = target.silences.QueryOne(silence.QIDs(expiredID))
+		assert.ErrorIs(t, err, silence.ErrNotFound)
+
+		// Importing the same silences again skips them.
+		result = importSilences(target, body)
+		assert.ElementsMatch(t, []SilenceImportResult{
+			{ID: activeID, Status: "skipped", Reason: "silence already exists"},
+			{ID: pendingID, Status: "skipped", Reason: "silence already exists"},
+			{ID: expiredID, Status: "skipped", Reason: "silence is expired"},
+		}, result.Silences)
+		assert.Len(t, exportSilences(target), 2)
+	})
+
+	t.Run("should create the silences without ID with a new ID", func(t *testing.T) {
+		target := newAlertmanager("user-3")
+
+		result := importSilences(target, []byte(`[{
+			"matchers": [{"name": "alertname", "value": "NoID", "isRegex": false}],
+			"startsAt": "2020-01-01T00:00:00Z",
+			"endsAt": "2100-01-01T00:00:00Z",
+			"createdBy": "alice",
+			"comment": "no ID"
+		}]`))
+		require.Len(t, result.Silences, 1)
+		assert.Equal(t, "imported", result.Silences[0].Status)
+		assert.NotEmpty(t, result.Silences[0].ImportedID)
+
+		_, err := target.silences.QueryOne(silence.QIDs(result.Silences[0].ImportedID))
+		require.NoError(t, err)
+	})
+
+	t.Run("should fail to import invalid silences", func(t *testing.T) {
+		target := newAlertmanager("user-4")
+
+		result := importSilences(target, []byte(`[{
+			"id": "4b0e3b9e-1d6e-4b4e-9b4a-4d1a0b8e4f1c",
+			"matchers": [{"name": "alertname", "value": "", "isRegex": false}],
+			"startsAt": "2020-01-01T00:00:00Z",
+			"endsAt": "2100-01-01T00:00:00Z",
+			"createdBy": "alice",
+			"comment": "matches everything"
+		}, {
+			"id": "0d6c8a1e-3c0a-4f7e-8f2b-6c1c1f3e8a2d",
+			"startsAt": "2020-01-01T00:00:00Z",
+			"endsAt": "2100-01-01T00:00:00Z"
+		}]`))
+		require.Len(t, result.Silences, 2)
+		assert.Equal(t, "failed", result.Silences[0].Status)
+		assert.Equal(t, "at least one matcher must not match the empty string", result.Silences[0].Reason)
+		assert.Equal(t, "failed", result.Silences[1].Status)
+		assert.Empty(t, exportSilences(target))
+	})
+
+	t.Run("should reject a malformed document", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		source.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/am/api/v2/silences/import", bytes.NewReader([]byte(`{`))))
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
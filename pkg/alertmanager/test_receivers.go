@@ -0,0 +1,206 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	amconfig "github.com/prometheus/alertmanager/config"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+	util_net "github.com/grafana/mimir/pkg/util/net"
+)
+
+const (
+	errReceiverNotFound = "receiver %q not found in the Alertmanager config"
+	errTestingReceiver  = "unable to test the receiver"
+
+	// receiverTestTimeout is the maximum time to send the test notification through all the integrations of a receiver.
+	receiverTestTimeout = 30 * time.Second
+)
+
+// ReceiverTestResult is the result of the test notification sent through a receiver.
+type ReceiverTestResult struct {
+	Receiver     string                          `json:"receiver"`
+	Integrations []ReceiverIntegrationTestResult `json:"integrations"`
+}
+
+// ReceiverIntegrationTestResult is the result of the test notification sent through an integration of a receiver.
+type ReceiverIntegrationTestResult struct {
+	Name   string `json:"name"`
+	Index  int    `json:"index"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TestReceiver sends a test notification through all the integrations of a receiver of the tenant's Alertmanager
+// configuration, and responds with the result of each integration. The test notification is sent by the Alertmanager
+// replica handling the request, with the configuration read from the object storage.
+func (am *MultitenantAlertmanager) TestReceiver(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	receiverName, err := url.PathUnescape(mux.Vars(r)["receiver"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cfg, err := am.store.GetAlertConfig(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, alertspb.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	amCfg, err := amconfig.Load(cfg.RawConfig)
+	if err != nil {
+		level.Warn(logger).Log("msg", errValidatingConfig, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errValidatingConfig, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	var receiver *amconfig.Receiver
+	for _, rcv := range amCfg.Receivers {
+		if rcv.Name == receiverName {
+			receiver = rcv
+			break
+		}
+	}
+	if receiver == nil {
+		http.Error(w, fmt.Sprintf(errReceiverNotFound, receiverName), http.StatusNotFound)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), receiverTestTimeout)
+	defer cancel()
+
+	result, err := am.testReceiver(ctx, userID, receiver, mergeTemplates(am.defaultTemplates, cfg.Templates), amCfg.Templates, logger)
+	if err != nil {
+		level.Warn(logger).Log("msg", errTestingReceiver, "receiver", receiverName, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errTestingReceiver, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	d, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// testReceiver sends a test notification through all the integrations of the receiver. The templates are stored
+// in a temporary directory to load the ones referenced by templateFiles, like the tenant Alertmanager does.
+func (am *MultitenantAlertmanager) testReceiver(ctx context.Context, userID string, receiver *amconfig.Receiver, templates []*alertspb.TemplateDesc, templateFiles []string, logger log.Logger) (*ReceiverTestResult, error) {
+	templatesDir, err := os.MkdirTemp("", "test-receiver-"+userID)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(templatesDir)
+
+	for _, tmpl := range templates {
+		templateFilepath, err := safeTemplateFilepath(templatesDir, tmpl.Filename)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := storeTemplateFile(templateFilepath, tmpl.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	templateFiles = withDefaultTemplateFiles(am.defaultTemplates, templateFiles)
+	for i, name := range templateFiles {
+		templateFiles[i], err = safeTemplateFilepath(templatesDir, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tmpl, err := template.FromGlobs(templateFiles...)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.ExternalURL = am.cfg.ExternalURL.URL
+
+	firewallDialer := util_net.NewFirewallDialer(newFirewallDialerConfigProvider(userID, am.limits))
+	integrations, err := buildReceiverIntegrations(receiver, tmpl, firewallDialer, logger, func(_ string, n notify.Notifier) notify.Notifier { return n })
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	alert := newTestAlert(receiver.Name, now)
+	ctx = notify.WithReceiverName(ctx, receiver.Name)
+	ctx = notify.WithGroupKey(ctx, fmt.Sprintf("%s-%s-%d", receiver.Name, alert.Fingerprint(), now.UnixNano()))
+	ctx = notify.WithGroupLabels(ctx, alert.Labels)
+	ctx = notify.WithFiringAlerts(ctx, []uint64{uint64(alert.Fingerprint())})
+	ctx = notify.WithNow(ctx, now)
+
+	result := &ReceiverTestResult{
+		Receiver:     receiver.Name,
+		Integrations: make([]ReceiverIntegrationTestResult, 0, len(integrations)),
+	}
+	for _, integration := range integrations {
+		integrationResult := ReceiverIntegrationTestResult{
+			Name:   integration.Name(),
+			Index:  integration.Index(),
+			Status: "success",
+		}
+		if _, err := integration.Notify(ctx, alert); err != nil {
+			integrationResult.Status = "failed"
+			integrationResult.Error = err.Error()
+		}
+		result.Integrations = append(result.Integrations, integrationResult)
+	}
+
+	return result, nil
+}
+
+// newTestAlert returns the firing alert sent by the receivers test notifications.
+func newTestAlert(receiver string, now time.Time) *types.Alert {
+	return &types.Alert{
+		Alert: model.Alert{
+			Labels: model.LabelSet{
+				model.AlertNameLabel: "TestAlert",
+				"receiver":           model.LabelValue(receiver),
+			},
+			Annotations: model.LabelSet{
+				"summary":     "Test notification",
+				"description": "This is a test notification sent by the Grafana Mimir Alertmanager to validate the receiver configuration.",
+			},
+			StartsAt: now,
+			EndsAt:   now.Add(5 * time.Minute),
+		},
+		UpdatedAt: now,
+	}
+}
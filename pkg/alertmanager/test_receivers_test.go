@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
+	"github.com/grafana/mimir/pkg/alertmanager/alertstore/bucketclient"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestMultitenantAlertmanager_TestReceiver(t *testing.T) {
+	var received []string
+	receiverServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		msg := struct {
+			Receiver string `json:"receiver"`
+			Alerts   []struct {
+				Labels map[string]string `json:"labels"`
+			} `json:"alerts"`
+		}{}
+		require.NoError(t, json.Unmarshal(body, &msg))
+		require.Len(t, msg.Alerts, 1)
+		received = append(received, msg.Receiver+"/"+msg.Alerts[0].Labels["alertname"])
+
+		if r.URL.Path == "/failing" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(receiverServer.Close)
+
+	alertStore := bucketclient.NewBucketAlertStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	require.NoError(t, alertStore.SetAlertConfig(context.Background(), alertspb.AlertConfigDesc{
+		User: "user-1",
+		RawConfig: fmt.Sprintf(`
+route:
+  receiver: working
+receivers:
+  - name: working
+    webhook_configs:
+      - url: %[1]s/working
+  - name: partially failing
+    webhook_configs:
+      - url: %[1]s/working
+      - url: %[1]s/failing
+`, receiverServer.URL),
+	}))
+
+	limits, err := validation.NewOverrides(validation.Limits{}, nil)
+	require.NoError(t, err)
+
+	am := &MultitenantAlertmanager{
+		cfg:    mockAlertmanagerConfig(t),
+		store:  alertStore,
+		limits: limits,
+		logger: log.NewNopLogger(),
+	}
+
+	router := mux.NewRouter()
+	router.Path("/api/v1/alerts/receivers/{receiver}/test").Methods(http.MethodPost).HandlerFunc(am.TestReceiver)
+
+	testReceiver := func(userID, receiver string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/alerts/receivers/"+receiver+"/test", nil)
+		req = req.WithContext(user.InjectOrgID(context.Background(), userID))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("should send the test notification through the receiver", func(t *testing.T) {
+		received = nil
+		rec := testReceiver("user-1", "working")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		result := ReceiverTestResult{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, ReceiverTestResult{
+			Receiver:     "working",
+			Integrations: []ReceiverIntegrationTestResult{{Name: "webhook", Index: 0, Status: "success"}},
+		}, result)
+		assert.Equal(t, []string{"working/TestAlert"}, received)
+	})
+
+	t.Run("should return the result of each integration of the receiver", func(t *testing.T) {
+		received = nil
+		rec := testReceiver("user-1", "partially%20failing")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		result := ReceiverTestResult{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		require.Len(t, result.Integrations, 2)
+		assert.Equal(t, "success", result.Integrations[0].Status)
+		assert.Equal(t, "failed", result.Integrations[1].Status)
+		assert.Equal(t, 1, result.Integrations[1].Index)
+		assert.Contains(t, result.Integrations[1].Error, "400")
+		assert.Equal(t, []string{"partially failing/TestAlert", "partially failing/TestAlert"}, received)
+	})
+
+	t.Run("should return 404 if the receiver doesn't exist", func(t *testing.T) {
+		rec := testReceiver("user-1", "unknown")
+		require.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "receiver \"unknown\" not found in the Alertmanager config\n", rec.Body.String())
+	})
+
+	t.Run("should return 404 if the tenant has no configuration", func(t *testing.T) {
+		rec := testReceiver("user-2", "working")
+		require.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
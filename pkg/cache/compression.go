@@ -16,6 +16,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
 
 	"github.com/grafana/mimir/pkg/util"
 )
@@ -23,10 +24,13 @@ import (
 const (
 	// CompressionSnappy is the value of the snappy compression.
 	CompressionSnappy = "snappy"
+
+	// CompressionZstd is the value of the zstd compression.
+	CompressionZstd = "zstd"
 )
 
 var (
-	supportedCompressions     = []string{CompressionSnappy}
+	supportedCompressions     = []string{CompressionSnappy, CompressionZstd}
 	errUnsupportedCompression = errors.New("unsupported compression")
 )
 
@@ -51,6 +55,8 @@ func NewCompression(cfg CompressionConfig, next Cache, logger log.Logger) Cache
 	switch cfg.Compression {
 	case CompressionSnappy:
 		return NewSnappy(next, logger)
+	case CompressionZstd:
+		return NewZstd(next, logger)
 	default:
 		// No compression.
 		return next
@@ -101,3 +107,57 @@ func (s *snappyCache) Fetch(ctx context.Context, keys []string) map[string][]byt
 func (s *snappyCache) Name() string {
 	return s.next.Name()
 }
+
+type zstdCache struct {
+	next    Cache
+	logger  log.Logger
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// NewZstd makes a new zstd encoding cache wrapper.
+func NewZstd(next Cache, logger log.Logger) Cache {
+	// The encoder and decoder are safe for concurrent use when only EncodeAll() and DecodeAll()
+	// are called, and creating them without options can't fail.
+	encoder, _ := zstd.NewWriter(nil)
+	decoder, _ := zstd.NewReader(nil)
+
+	return &zstdCache{
+		next:    next,
+		logger:  logger,
+		encoder: encoder,
+		decoder: decoder,
+	}
+}
+
+// Store implements Cache.
+func (z *zstdCache) Store(ctx context.Context, data map[string][]byte, ttl time.Duration) {
+	encoded := make(map[string][]byte, len(data))
+	for key, value := range data {
+		encoded[key] = z.encoder.EncodeAll(value, nil)
+	}
+
+	z.next.Store(ctx, encoded, ttl)
+}
+
+// Fetch implements Cache.
+func (z *zstdCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	found := z.next.Fetch(ctx, keys)
+	decoded := make(map[string][]byte, len(found))
+
+	for key, encodedValue := range found {
+		decodedValue, err := z.decoder.DecodeAll(encodedValue, nil)
+		if err != nil {
+			level.Error(z.logger).Log("msg", "failed to decode cache entry", "err", err)
+			continue
+		}
+
+		decoded[key] = decodedValue
+	}
+
+	return decoded
+}
+
+func (z *zstdCache) Name() string {
+	return z.next.Name()
+}
@@ -24,6 +24,11 @@ func TestCompressionConfig_Validate(t *testing.T) {
 				Compression: "snappy",
 			},
 		},
+		"should pass with zstd compression": {
+			cfg: CompressionConfig{
+				Compression: "zstd",
+			},
+		},
 		"should fail with unsupported compression": {
 			cfg: CompressionConfig{
 				Compression: "unsupported",
@@ -40,9 +45,17 @@ func TestCompressionConfig_Validate(t *testing.T) {
 }
 
 func TestSnappyCache(t *testing.T) {
+	testCompressionCache(t, NewSnappy)
+}
+
+func TestZstdCache(t *testing.T) {
+	testCompressionCache(t, NewZstd)
+}
+
+func testCompressionCache(t *testing.T, newCache func(Cache, log.Logger) Cache) {
 	ctx := context.Background()
 	backend := NewMockCache()
-	c := NewSnappy(backend, log.NewNopLogger())
+	c := newCache(backend, log.NewNopLogger())
 
 	t.Run("Fetch() should return empty results if no key has been found", func(t *testing.T) {
 		assert.Empty(t, c.Fetch(ctx, []string{"a", "b", "c"}))
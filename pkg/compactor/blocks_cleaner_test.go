@@ -35,6 +35,7 @@ import (
 	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/test"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 type testBlocksCleanerOptions struct {
@@ -955,6 +956,7 @@ type mockConfigProvider struct {
 	instancesShardSize           map[string]int
 	splitGroups                  map[string]int
 	blockUploadEnabled           map[string]bool
+	largeCompactionsWindows      map[string][]validation.TimeOfDayWindow
 	userPartialBlockDelay        map[string]time.Duration
 	userPartialBlockDelayInvalid map[string]bool
 }
@@ -965,6 +967,7 @@ func newMockConfigProvider() *mockConfigProvider {
 		splitAndMergeShards:          make(map[string]int),
 		splitGroups:                  make(map[string]int),
 		blockUploadEnabled:           make(map[string]bool),
+		largeCompactionsWindows:      make(map[string][]validation.TimeOfDayWindow),
 		userPartialBlockDelay:        make(map[string]time.Duration),
 		userPartialBlockDelayInvalid: make(map[string]bool),
 	}
@@ -1002,6 +1005,10 @@ func (m *mockConfigProvider) CompactorBlockUploadEnabled(tenantID string) bool {
 	return m.blockUploadEnabled[tenantID]
 }
 
+func (m *mockConfigProvider) CompactorLargeCompactionsWindows(user string) []validation.TimeOfDayWindow {
+	return m.largeCompactionsWindows[user]
+}
+
 func (m *mockConfigProvider) CompactorPartialBlockDeletionDelay(user string) (time.Duration, bool) {
 	return m.userPartialBlockDelay[user], !m.userPartialBlockDelayInvalid[user]
 }
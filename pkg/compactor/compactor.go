@@ -34,6 +34,7 @@ import (
 	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 	"github.com/grafana/mimir/pkg/util"
 	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
@@ -195,6 +196,10 @@ type ConfigProvider interface {
 
 	// CompactorBlockUploadEnabled returns whether block upload is enabled for a given tenant.
 	CompactorBlockUploadEnabled(tenantID string) bool
+
+	// CompactorLargeCompactionsWindows returns the time of day windows during which the large compaction jobs
+	// of a given tenant can start. No window means the jobs can start at any time.
+	CompactorLargeCompactionsWindows(userID string) []validation.TimeOfDayWindow
 }
 
 // MultitenantCompactor is a multi-tenant TSDB blocks compactor based on Thanos.
@@ -677,10 +682,15 @@ func (c *MultitenantCompactor) compactUser(ctx context.Context, userID string) e
 		return errors.Wrap(err, "failed to create syncer")
 	}
 
+	grouper := c.blocksGrouperFactory(ctx, c.compactorCfg, c.cfgProvider, userID, ulogger, reg)
+	if windows := c.cfgProvider.CompactorLargeCompactionsWindows(userID); len(windows) > 0 && len(c.compactorCfg.BlockRanges) > 0 {
+		grouper = newLargeCompactionsWindowsGrouper(grouper, windows, c.compactorCfg.BlockRanges[0], ulogger)
+	}
+
 	compactor, err := NewBucketCompactor(
 		ulogger,
 		syncer,
-		c.blocksGrouperFactory(ctx, c.compactorCfg, c.cfgProvider, userID, ulogger, reg),
+		grouper,
 		c.blocksPlanner,
 		c.blocksCompactor,
 		path.Join(c.compactorCfg.DataDir, "compact"),
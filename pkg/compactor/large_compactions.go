@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/metadata"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// largeCompactionsWindowsGrouper is a Grouper deferring the large compaction jobs of a tenant while the current time
+// is outside of the tenant's large compactions windows, so that they run during off-peak hours. The other jobs, like
+// the ones compacting the blocks uploaded by the ingesters, are never deferred.
+type largeCompactionsWindowsGrouper struct {
+	Grouper
+
+	windows []validation.TimeOfDayWindow
+	// smallestRange is the smallest compaction block range, in milliseconds.
+	smallestRange int64
+	now           func() time.Time
+	logger        log.Logger
+}
+
+func newLargeCompactionsWindowsGrouper(grouper Grouper, windows []validation.TimeOfDayWindow, smallestRange time.Duration, logger log.Logger) *largeCompactionsWindowsGrouper {
+	return &largeCompactionsWindowsGrouper{
+		Grouper:       grouper,
+		windows:       windows,
+		smallestRange: smallestRange.Milliseconds(),
+		now:           time.Now,
+		logger:        logger,
+	}
+}
+
+func (g *largeCompactionsWindowsGrouper) Groups(blocks map[ulid.ULID]*metadata.Meta) ([]*Job, error) {
+	jobs, err := g.Grouper.Groups(blocks)
+	if err != nil || validation.TimeOfDayWindowsContain(g.windows, g.now()) {
+		return jobs, err
+	}
+
+	filtered := make([]*Job, 0, len(jobs))
+	for _, job := range jobs {
+		if !isLargeCompactionJob(job, g.smallestRange) {
+			filtered = append(filtered, job)
+		}
+	}
+
+	if deferred := len(jobs) - len(filtered); deferred > 0 {
+		windows := make([]string, 0, len(g.windows))
+		for _, w := range g.windows {
+			windows = append(windows, w.String())
+		}
+		level.Info(g.logger).Log("msg", "deferred large compaction jobs because outside of the large compactions windows", "jobs", deferred, "windows", strings.Join(windows, ","))
+	}
+
+	return filtered, nil
+}
+
+// isLargeCompactionJob returns whether the job compacts blocks spanning more than one range of the smallest
+// compaction block range, like the jobs compacting the blocks of the smallest range into larger blocks.
+func isLargeCompactionJob(job *Job, smallestRange int64) bool {
+	if smallestRange <= 0 {
+		return false
+	}
+	return job.MinTime()/smallestRange != (job.MaxTime()-1)/smallestRange
+}
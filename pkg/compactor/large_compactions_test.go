@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/metadata"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestLargeCompactionsWindowsGrouper(t *testing.T) {
+	const h = int64(time.Hour / time.Millisecond)
+
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	block3 := ulid.MustNew(3, nil)
+	block4 := ulid.MustNew(4, nil)
+	blocks := map[ulid.ULID]*metadata.Meta{
+		// Blocks uploaded by the ingesters, compacted by a small job.
+		block1: mockMetaWithMinMax(block1, 24*h, 26*h),
+		block2: mockMetaWithMinMax(block2, 24*h, 26*h),
+		// Blocks already compacted to the smallest range, compacted by a large job.
+		block3: mockMetaWithMinMax(block3, 12*h, 14*h),
+		block4: mockMetaWithMinMax(block4, 14*h, 16*h),
+	}
+
+	// Windows from 22:00 to 06:00 UTC.
+	windows := []validation.TimeOfDayWindow{{Start: 22 * time.Hour, End: 6 * time.Hour}}
+	grouper := newLargeCompactionsWindowsGrouper(NewSplitAndMergeGrouper("user-1", []int64{2 * h, 12 * h}, 0, 0, log.NewNopLogger()), windows, 2*time.Hour, log.NewNopLogger())
+
+	jobIDs := func(jobs []*Job) [][]ulid.ULID {
+		var ids [][]ulid.ULID
+		for _, job := range jobs {
+			ids = append(ids, job.IDs())
+		}
+		return ids
+	}
+
+	t.Run("should run all the jobs within the windows", func(t *testing.T) {
+		grouper.now = func() time.Time { return time.Date(2022, 11, 1, 23, 30, 0, 0, time.UTC) }
+
+		jobs, err := grouper.Groups(blocks)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, [][]ulid.ULID{{block1, block2}, {block3, block4}}, jobIDs(jobs))
+	})
+
+	t.Run("should defer the large jobs outside of the windows", func(t *testing.T) {
+		grouper.now = func() time.Time { return time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC) }
+
+		jobs, err := grouper.Groups(blocks)
+		require.NoError(t, err)
+		assert.Equal(t, [][]ulid.ULID{{block1, block2}}, jobIDs(jobs))
+	})
+}
+
+func TestIsLargeCompactionJob(t *testing.T) {
+	const h = int64(time.Hour / time.Millisecond)
+
+	job := func(ranges ...[2]int64) *Job {
+		j := NewJob("user-1", "key", nil, 0, metadata.NoneFunc, false, 0, "")
+		for i, r := range ranges {
+			require.NoError(t, j.AppendMeta(mockMetaWithMinMax(ulid.MustNew(uint64(i), nil), r[0], r[1])))
+		}
+		return j
+	}
+
+	assert.False(t, isLargeCompactionJob(job([2]int64{0, 2 * h}, [2]int64{0, 2 * h}), 2*h))
+	assert.False(t, isLargeCompactionJob(job([2]int64{2 * h, 3 * h}, [2]int64{3 * h, 4 * h}), 2*h))
+	assert.True(t, isLargeCompactionJob(job([2]int64{0, 2 * h}, [2]int64{2 * h, 4 * h}), 2*h))
+	assert.True(t, isLargeCompactionJob(job([2]int64{0, 12 * h}, [2]int64{12 * h, 24 * h}), 2*h))
+}
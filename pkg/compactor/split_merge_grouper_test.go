@@ -97,6 +97,41 @@ func TestPlanCompaction(t *testing.T) {
 				}},
 			},
 		},
+		"should split a 1st level block containing out-of-order samples uploaded after its time range has been compacted": {
+			ranges:     []int64{10, 20},
+			shardCount: 1,
+			blocks: []*metadata.Meta{
+				{BlockMeta: tsdb.BlockMeta{ULID: block1, MinTime: 0, MaxTime: 20}, Thanos: metadata.Thanos{Labels: map[string]string{mimir_tsdb.CompactorShardIDExternalLabel: "1_of_1"}}},
+				{BlockMeta: tsdb.BlockMeta{ULID: block2, MinTime: 0, MaxTime: 10, Compaction: tsdb.BlockMetaCompaction{Level: 1, Hints: []string{tsdb.CompactionHintFromOutOfOrder}}}},
+			},
+			expected: []*job{
+				{userID: userID, stage: stageSplit, shardID: "1_of_1", blocksGroup: blocksGroup{
+					rangeStart: 0,
+					rangeEnd:   10,
+					blocks: []*metadata.Meta{
+						{BlockMeta: tsdb.BlockMeta{ULID: block2, MinTime: 0, MaxTime: 10, Compaction: tsdb.BlockMetaCompaction{Level: 1, Hints: []string{tsdb.CompactionHintFromOutOfOrder}}}},
+					},
+				}},
+			},
+		},
+		"should merge a split block containing out-of-order samples with the block already compacted for its time range": {
+			ranges:     []int64{10, 20},
+			shardCount: 1,
+			blocks: []*metadata.Meta{
+				{BlockMeta: tsdb.BlockMeta{ULID: block1, MinTime: 0, MaxTime: 20}, Thanos: metadata.Thanos{Labels: map[string]string{mimir_tsdb.CompactorShardIDExternalLabel: "1_of_1"}}},
+				{BlockMeta: tsdb.BlockMeta{ULID: block2, MinTime: 0, MaxTime: 10, Compaction: tsdb.BlockMetaCompaction{Level: 1, Hints: []string{tsdb.CompactionHintFromOutOfOrder}}}, Thanos: metadata.Thanos{Labels: map[string]string{mimir_tsdb.CompactorShardIDExternalLabel: "1_of_1"}}},
+			},
+			expected: []*job{
+				{userID: userID, stage: stageMerge, shardID: "1_of_1", blocksGroup: blocksGroup{
+					rangeStart: 0,
+					rangeEnd:   20,
+					blocks: []*metadata.Meta{
+						{BlockMeta: tsdb.BlockMeta{ULID: block1, MinTime: 0, MaxTime: 20}, Thanos: metadata.Thanos{Labels: map[string]string{mimir_tsdb.CompactorShardIDExternalLabel: "1_of_1"}}},
+						{BlockMeta: tsdb.BlockMeta{ULID: block2, MinTime: 0, MaxTime: 10, Compaction: tsdb.BlockMetaCompaction{Level: 1, Hints: []string{tsdb.CompactionHintFromOutOfOrder}}}, Thanos: metadata.Thanos{Labels: map[string]string{mimir_tsdb.CompactorShardIDExternalLabel: "1_of_1"}}},
+					},
+				}},
+			},
+		},
 		"should merge and split multiple 1st level blocks in different time ranges": {
 			ranges:     []int64{10, 20},
 			shardCount: 1,
@@ -0,0 +1,50 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"context"
+
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// logDebugSeries logs the outcome of the series matching the tenant's debug series selector.
+func (d *Distributor) logDebugSeries(ctx context.Context, userID string, series []mimirpb.PreallocTimeseries, outcome string, keyvals ...interface{}) {
+	logDebugSeries(validation.NewDebugSeriesLogger(ctx, d.log, d.limits, userID), series, outcome, keyvals...)
+}
+
+func logDebugSeries(logger *validation.DebugSeriesLogger, series []mimirpb.PreallocTimeseries, outcome string, keyvals ...interface{}) {
+	collectDebugSeries(logger, series).log(outcome, keyvals...)
+}
+
+// debugSeriesBatch holds a copy of the series of a write request matching the tenant's debug series
+// selector, so that their outcome can be logged after the request has been cleaned up.
+type debugSeriesBatch struct {
+	logger  *validation.DebugSeriesLogger
+	series  []labels.Labels
+	samples []int
+}
+
+func collectDebugSeries(logger *validation.DebugSeriesLogger, series []mimirpb.PreallocTimeseries) debugSeriesBatch {
+	b := debugSeriesBatch{logger: logger}
+	if logger == nil {
+		return b
+	}
+
+	for _, ts := range series {
+		if logger.Matches(ts.Labels) {
+			b.series = append(b.series, mimirpb.FromLabelAdaptersToLabelsWithCopy(ts.Labels))
+			b.samples = append(b.samples, len(ts.Samples))
+		}
+	}
+	return b
+}
+
+func (b debugSeriesBatch) log(outcome string, keyvals ...interface{}) {
+	for i, series := range b.series {
+		b.logger.Log(series, b.samples[i], outcome, keyvals...)
+	}
+}
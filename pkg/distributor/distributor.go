@@ -104,6 +104,11 @@ type Distributor struct {
 	// Per-user rate limiters.
 	requestRateLimiter   *limiter.RateLimiter
 	ingestionRateLimiter *limiter.RateLimiter
+	// Rate limiter of the samples written by the ruler, when the tenant has a dedicated limit for them.
+	rulerIngestionRateLimiter *limiter.RateLimiter
+
+	// Digests of the idempotency keys of the push requests ingested. Nil if the deduplication is disabled.
+	idempotencyStore idempotencyStore
 
 	// Manager for subservices (HA Tracker, distributor ring, forwarder and client pool)
 	subservices        *services.Manager
@@ -128,7 +133,10 @@ type Distributor struct {
 	incomingExemplars                *prometheus.CounterVec
 	incomingMetadata                 *prometheus.CounterVec
 	nonHASamples                     *prometheus.CounterVec
+	relabeledSeries                  *prometheus.CounterVec
+	relabelDroppedSeries             *prometheus.CounterVec
 	dedupedSamples                   *prometheus.CounterVec
+	idempotencyDedupedRequests       *prometheus.CounterVec
 	labelsHistogram                  prometheus.Histogram
 	sampleDelayHistogram             prometheus.Histogram
 	replicationFactor                prometheus.Gauge
@@ -175,6 +183,9 @@ type Config struct {
 
 	// Configuration for forwarding of metrics to alternative ingestion endpoint.
 	Forwarding forwarding.Config
+
+	// Deduplication of the push requests retried by clients.
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
 }
 
 type InstanceLimits struct {
@@ -189,6 +200,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	cfg.HATrackerConfig.RegisterFlags(f)
 	cfg.DistributorRing.RegisterFlags(f, logger)
 	cfg.Forwarding.RegisterFlags(f)
+	cfg.Idempotency.RegisterFlagsWithPrefix(f, "distributor.idempotency.")
 
 	f.IntVar(&cfg.MaxRecvMsgSize, "distributor.max-recv-msg-size", 100<<20, "Max message size in bytes that the distributors will accept for incoming push requests to the remote write API. If exceeded, the request will be rejected.")
 	f.DurationVar(&cfg.RemoteTimeout, "distributor.remote-timeout", 2*time.Second, "Timeout for downstream ingesters.")
@@ -208,6 +220,10 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 		return err
 	}
 
+	if err := cfg.Idempotency.Validate(); err != nil {
+		return errors.Wrap(err, "invalid idempotency config")
+	}
+
 	return cfg.Forwarding.Validate()
 }
 
@@ -306,11 +322,26 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 			Name:      "distributor_non_ha_samples_received_total",
 			Help:      "The total number of received samples for a user that has HA tracking turned on, but the sample didn't contain both HA labels.",
 		}, []string{"user"}),
+		relabeledSeries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_relabeled_series_total",
+			Help:      "The total number of received series whose labels have been changed by the tenant metric relabel configs.",
+		}, []string{"user"}),
+		relabelDroppedSeries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_relabel_dropped_series_total",
+			Help:      "The total number of received series dropped by the tenant metric relabel configs.",
+		}, []string{"user"}),
 		dedupedSamples: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Namespace: "cortex",
 			Name:      "distributor_deduped_samples_total",
 			Help:      "The total number of deduplicated samples.",
 		}, []string{"user", "cluster"}),
+		idempotencyDedupedRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "distributor_idempotency_deduped_requests_total",
+			Help:      "The total number of push requests dropped because their idempotency key matched a request already ingested.",
+		}, []string{"user"}),
 		labelsHistogram: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
 			Namespace: "cortex",
 			Name:      "labels_per_sample",
@@ -395,13 +426,14 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 	// Create the configured ingestion rate limit strategy (local or global). In case
 	// it's an internal dependency and we can't join the distributors ring, we skip rate
 	// limiting.
-	var ingestionRateStrategy, requestRateStrategy limiter.RateLimiterStrategy
+	var ingestionRateStrategy, rulerIngestionRateStrategy, requestRateStrategy limiter.RateLimiterStrategy
 	var distributorsLifecycler *ring.BasicLifecycler
 	var distributorsRing *ring.Ring
 
 	if !canJoinDistributorsRing {
 		requestRateStrategy = newInfiniteRateStrategy()
 		ingestionRateStrategy = newInfiniteRateStrategy()
+		rulerIngestionRateStrategy = newInfiniteRateStrategy()
 	} else {
 		distributorsRing, distributorsLifecycler, err = newRingAndLifecycler(cfg.DistributorRing, d.healthyInstancesCount, log, reg)
 		if err != nil {
@@ -411,10 +443,12 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 		subservices = append(subservices, distributorsLifecycler, distributorsRing)
 		requestRateStrategy = newGlobalRateStrategy(newRequestRateStrategy(limits), d)
 		ingestionRateStrategy = newGlobalRateStrategy(newIngestionRateStrategy(limits), d)
+		rulerIngestionRateStrategy = newGlobalRateStrategy(newRulerIngestionRateStrategy(limits), d)
 	}
 
 	d.requestRateLimiter = limiter.NewRateLimiter(requestRateStrategy, 10*time.Second)
 	d.ingestionRateLimiter = limiter.NewRateLimiter(ingestionRateStrategy, 10*time.Second)
+	d.rulerIngestionRateLimiter = limiter.NewRateLimiter(rulerIngestionRateStrategy, 10*time.Second)
 	d.distributorsLifecycler = distributorsLifecycler
 	d.distributorsRing = distributorsRing
 
@@ -427,6 +461,11 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 		subservices = append(subservices, d.forwarder)
 	}
 
+	d.idempotencyStore, err = newIdempotencyStore(cfg.Idempotency, log, reg)
+	if err != nil {
+		return nil, err
+	}
+
 	d.PushWithMiddlewares = d.wrapPushWithMiddlewares(d.push)
 
 	subservices = append(subservices, d.ingesterPool, d.activeUsers)
@@ -522,9 +561,16 @@ func (d *Distributor) cleanupInactiveUser(userID string) {
 	d.incomingExemplars.DeleteLabelValues(userID)
 	d.incomingMetadata.DeleteLabelValues(userID)
 	d.nonHASamples.DeleteLabelValues(userID)
+	d.relabeledSeries.DeleteLabelValues(userID)
+	d.relabelDroppedSeries.DeleteLabelValues(userID)
 	d.latestSeenSampleTimestampPerUser.DeleteLabelValues(userID)
 
 	d.dedupedSamples.DeletePartialMatch(prometheus.Labels{"user": userID})
+	d.idempotencyDedupedRequests.DeleteLabelValues(userID)
+
+	if d.idempotencyStore != nil {
+		d.idempotencyStore.deleteTenant(userID)
+	}
 
 	d.discardedSamplesTooManyHaClusters.DeleteLabelValues(userID)
 	d.discardedSamplesRateLimited.DeleteLabelValues(userID)
@@ -673,6 +719,8 @@ func (d *Distributor) wrapPushWithMiddlewares(next push.Func) push.Func {
 	// To guarantee that, middleware functions will be called in reversed order, wrapping the
 	// result from previous call.
 	middlewares = append(middlewares, d.limitsMiddleware) // should run first because it checks limits before other middlewares need to read the request body
+	// The requests dropped as duplicates of a request already ingested should not be accounted by the metrics.
+	middlewares = append(middlewares, d.prePushIdempotencyMiddleware)
 	middlewares = append(middlewares, d.metricsMiddleware)
 	middlewares = append(middlewares, d.prePushHaDedupeMiddleware)
 	middlewares = append(middlewares, d.prePushRelabelMiddleware)
@@ -731,11 +779,13 @@ func (d *Distributor) prePushHaDedupeMiddleware(next push.Func) push.Func {
 			if errors.Is(err, replicasNotMatchError{}) {
 				// These samples have been deduped.
 				d.dedupedSamples.WithLabelValues(userID, cluster).Add(float64(numSamples))
+				d.logDebugSeries(ctx, userID, req.Timeseries, "deduped", "cluster", cluster, "replica", replica)
 				return nil, httpgrpc.Errorf(http.StatusAccepted, err.Error())
 			}
 
 			if errors.Is(err, tooManyClustersError{}) {
 				d.discardedSamplesTooManyHaClusters.WithLabelValues(userID).Add(float64(numSamples))
+				d.logDebugSeries(ctx, userID, req.Timeseries, "rejected", "err", err)
 				return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
 			}
 
@@ -778,12 +828,26 @@ func (d *Distributor) prePushRelabelMiddleware(next push.Func) push.Func {
 			return nil, err
 		}
 
+		debugSeries := validation.NewDebugSeriesLogger(ctx, d.log, d.limits, userID)
+
 		var removeTsIndexes []int
+		var relabeledSeries, relabelDroppedSeries int
 		for tsIdx := 0; tsIdx < len(req.Timeseries); tsIdx++ {
 			ts := req.Timeseries[tsIdx]
 
+			var debugSeriesLabels labels.Labels
+			if debugSeries.Matches(ts.Labels) {
+				debugSeriesLabels = mimirpb.FromLabelAdaptersToLabelsWithCopy(ts.Labels)
+			}
+
 			if mrc := d.limits.MetricRelabelConfigs(userID); len(mrc) > 0 {
-				l := relabel.Process(mimirpb.FromLabelAdaptersToLabels(ts.Labels), mrc...)
+				original := mimirpb.FromLabelAdaptersToLabels(ts.Labels)
+				l := relabel.Process(original, mrc...)
+				if len(l) == 0 {
+					relabelDroppedSeries++
+				} else if !labels.Equal(l, original) {
+					relabeledSeries++
+				}
 				ts.Labels = mimirpb.FromLabelsToLabelAdapters(l)
 			}
 
@@ -792,6 +856,9 @@ func (d *Distributor) prePushRelabelMiddleware(next push.Func) push.Func {
 			}
 
 			if len(ts.Labels) == 0 {
+				if debugSeriesLabels != nil {
+					debugSeries.Log(debugSeriesLabels, len(ts.Samples), "dropped", "reason", "relabeling")
+				}
 				removeTsIndexes = append(removeTsIndexes, tsIdx)
 				continue
 			}
@@ -805,6 +872,13 @@ func (d *Distributor) prePushRelabelMiddleware(next push.Func) push.Func {
 			sortLabelsIfNeeded(ts.Labels)
 		}
 
+		if relabeledSeries > 0 {
+			d.relabeledSeries.WithLabelValues(userID).Add(float64(relabeledSeries))
+		}
+		if relabelDroppedSeries > 0 {
+			d.relabelDroppedSeries.WithLabelValues(userID).Add(float64(relabelDroppedSeries))
+		}
+
 		if len(removeTsIndexes) > 0 {
 			for _, removeTsIndex := range removeTsIndexes {
 				mimirpb.ReusePreallocTimeseries(req.Timeseries[removeTsIndex])
@@ -865,6 +939,14 @@ func (d *Distributor) prePushValidationMiddleware(next push.Func) push.Func {
 		if earliestSampleTimestampMs != math.MaxInt64 {
 			minExemplarTS = earliestSampleTimestampMs - 300000
 		}
+		// Exemplars older than the retention period are dropped too.
+		if retention := d.limits.ExemplarsRetentionPeriod(userID); retention > 0 {
+			if retentionMinTS := now.Add(-retention).UnixMilli(); retentionMinTS > minExemplarTS {
+				minExemplarTS = retentionMinTS
+			}
+		}
+
+		debugSeries := validation.NewDebugSeriesLogger(ctx, d.log, d.limits, userID)
 
 		var firstPartialErr error
 		var removeIndexes []int
@@ -888,6 +970,9 @@ func (d *Distributor) prePushValidationMiddleware(next push.Func) push.Func {
 					// use case because we format it calling Error() and then we discard it.
 					firstPartialErr = httpgrpc.Errorf(http.StatusBadRequest, validationErr.Error())
 				}
+				if debugSeries.Matches(ts.Labels) {
+					debugSeries.Log(mimirpb.FromLabelAdaptersToLabels(ts.Labels), len(ts.Samples), "rejected", "err", validationErr)
+				}
 				removeIndexes = append(removeIndexes, tsIdx)
 				continue
 			}
@@ -926,30 +1011,53 @@ func (d *Distributor) prePushValidationMiddleware(next push.Func) push.Func {
 		}
 
 		totalN := validatedSamples + validatedExemplars + validatedMetadata
-		if !d.ingestionRateLimiter.AllowN(now, userID, totalN) {
+		if err := d.checkIngestionRateLimit(now, userID, req.Source, totalN); err != nil {
 			d.discardedSamplesRateLimited.WithLabelValues(userID).Add(float64(validatedSamples))
 			d.discardedExemplarsRateLimited.WithLabelValues(userID).Add(float64(validatedExemplars))
 			d.discardedMetadataRateLimited.WithLabelValues(userID).Add(float64(validatedMetadata))
 			// Return a 429 here to tell the client it is going too fast.
 			// Client may discard the data or slow down and re-send.
 			// Prometheus v2.26 added a remote-write option 'retry_on_http_429'.
-			return nil, httpgrpc.Errorf(http.StatusTooManyRequests, validation.NewIngestionRateLimitedError(d.limits.IngestionRate(userID), d.limits.IngestionBurstSize(userID)).Error())
+			logDebugSeries(debugSeries, req.Timeseries, "rejected", "err", err)
+			return nil, httpgrpc.Errorf(http.StatusTooManyRequests, err.Error())
 		}
 
 		// totalN included samples, exemplars and metadata. Ingester follows this pattern when computing its ingestion rate.
 		d.ingestionRate.Add(int64(totalN))
 
+		// Keep a copy of the series to debug, because the request is cleaned up once pushed.
+		pushedDebugSeries := collectDebugSeries(debugSeries, req.Timeseries)
+
 		cleanupInDefer = false
 		res, err := next(ctx, pushReq)
 		if err != nil {
+			pushedDebugSeries.log("rejected", "err", err)
 			// Errors resulting from the pushing to the ingesters have priority over validation errors.
 			return nil, err
 		}
+		pushedDebugSeries.log("pushed")
 
 		return res, firstPartialErr
 	}
 }
 
+// checkIngestionRateLimit returns an error if the n samples, exemplars and metadata of a write request
+// from the source exceed the tenant ingestion rate limit. The samples written by the ruler are rate
+// limited separately when the tenant has a dedicated limit for them.
+func (d *Distributor) checkIngestionRateLimit(now time.Time, userID string, source mimirpb.WriteRequest_SourceEnum, n int) error {
+	if source == mimirpb.RULE && d.limits.RulerIngestionRate(userID) != 0 {
+		if !d.rulerIngestionRateLimiter.AllowN(now, userID, n) {
+			return validation.NewRulerIngestionRateLimitedError(d.limits.RulerIngestionRate(userID), d.rulerIngestionRateLimiter.Burst(now, userID))
+		}
+		return nil
+	}
+
+	if !d.ingestionRateLimiter.AllowN(now, userID, n) {
+		return validation.NewIngestionRateLimitedError(d.limits.IngestionRate(userID), d.limits.IngestionBurstSize(userID))
+	}
+	return nil
+}
+
 // prePushForwardingMiddleware is used as push.Func middleware in front of push method.
 // It forwards time series to configured remote_write endpoints if the forwarding rules say so.
 func (d *Distributor) prePushForwardingMiddleware(next push.Func) push.Func {
@@ -20,6 +20,7 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/dskit/concurrency"
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/kv"
 	"github.com/grafana/dskit/kv/consul"
@@ -616,6 +617,89 @@ func TestDistributor_PushIngestionRateLimiter(t *testing.T) {
 	}
 }
 
+func TestDistributor_PushRulerIngestionRateLimiter(t *testing.T) {
+	type testPush struct {
+		source        mimirpb.WriteRequest_SourceEnum
+		samples       int
+		expectedError error
+	}
+
+	ctx := user.InjectOrgID(context.Background(), "user")
+	tests := map[string]struct {
+		rulerIngestionRate      float64
+		rulerIngestionBurstSize int
+		pushes                  []testPush
+	}{
+		"samples written by the ruler share the tenant ingestion rate limit by default": {
+			rulerIngestionRate: 0,
+			pushes: []testPush{
+				{source: mimirpb.API, samples: 5, expectedError: nil},
+				{source: mimirpb.RULE, samples: 1, expectedError: httpgrpc.Errorf(http.StatusTooManyRequests, validation.NewIngestionRateLimitedError(5, 5).Error())},
+			},
+		},
+		"samples written by the ruler are rate limited by the ruler ingestion rate limit": {
+			rulerIngestionRate:      10,
+			rulerIngestionBurstSize: 8,
+			pushes: []testPush{
+				{source: mimirpb.API, samples: 5, expectedError: nil},
+				{source: mimirpb.API, samples: 1, expectedError: httpgrpc.Errorf(http.StatusTooManyRequests, validation.NewIngestionRateLimitedError(5, 5).Error())},
+				{source: mimirpb.RULE, samples: 8, expectedError: nil},
+				{source: mimirpb.RULE, samples: 1, expectedError: httpgrpc.Errorf(http.StatusTooManyRequests, validation.NewRulerIngestionRateLimitedError(10, 8).Error())},
+			},
+		},
+		"samples written by the ruler use the tenant ingestion burst size if the ruler one is not set": {
+			rulerIngestionRate: 10,
+			pushes: []testPush{
+				{source: mimirpb.RULE, samples: 5, expectedError: nil},
+				{source: mimirpb.RULE, samples: 1, expectedError: httpgrpc.Errorf(http.StatusTooManyRequests, validation.NewRulerIngestionRateLimitedError(10, 5).Error())},
+				{source: mimirpb.API, samples: 5, expectedError: nil},
+			},
+		},
+		"samples written by the ruler are not rate limited if the ruler ingestion rate limit is negative": {
+			rulerIngestionRate: -1,
+			pushes: []testPush{
+				{source: mimirpb.API, samples: 5, expectedError: nil},
+				{source: mimirpb.RULE, samples: 100, expectedError: nil},
+				{source: mimirpb.API, samples: 1, expectedError: httpgrpc.Errorf(http.StatusTooManyRequests, validation.NewIngestionRateLimitedError(5, 5).Error())},
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			limits := &validation.Limits{}
+			flagext.DefaultValues(limits)
+			limits.IngestionRate = 5
+			limits.IngestionBurstSize = 5
+			limits.RulerIngestionRate = testData.rulerIngestionRate
+			limits.RulerIngestionBurstSize = testData.rulerIngestionBurstSize
+
+			distributors, _, _ := prepare(t, prepConfig{
+				numIngesters:    3,
+				happyIngesters:  3,
+				numDistributors: 1,
+				limits:          limits,
+			})
+
+			for _, push := range testData.pushes {
+				request := makeWriteRequest(0, push.samples, 0, false)
+				request.Source = push.source
+				response, err := distributors[0].Push(ctx, request)
+
+				if push.expectedError == nil {
+					assert.Equal(t, emptyResponse, response)
+					assert.Nil(t, err)
+				} else {
+					assert.Nil(t, response)
+					assert.Equal(t, push.expectedError, err)
+				}
+			}
+		})
+	}
+}
+
 func TestDistributor_PushInstanceLimits(t *testing.T) {
 	type testPush struct {
 		samples       int
@@ -1150,6 +1234,132 @@ func TestDistributor_QueryStream_ShouldReturnErrorIfMaxSeriesPerQueryLimitIsReac
 	assert.ErrorContains(t, err, "the query exceeded the maximum number of series")
 }
 
+func TestDistributor_QueryExemplars_ShouldReturnErrorIfMaxExemplarsPerQueryLimitIsReached(t *testing.T) {
+	const maxExemplarsLimit = 3
+
+	ctx := user.InjectOrgID(context.Background(), "user")
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.MaxGlobalExemplarsPerUser = 10
+	limits.MaxExemplarsPerQuery = maxExemplarsLimit
+
+	// Prepare distributors.
+	ds, _, _ := prepare(t, prepConfig{
+		numIngesters:    3,
+		happyIngesters:  3,
+		numDistributors: 1,
+		limits:          limits,
+	})
+
+	// Push a number of exemplars equal to the limit.
+	for i := 0; i < maxExemplarsLimit; i++ {
+		_, err := ds[0].Push(ctx, makeWriteRequestSampleAndExemplar(fmt.Sprintf("series_%d", i), 1000))
+		require.NoError(t, err)
+	}
+
+	allSeriesMatchers := []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchRegexp, model.MetricNameLabel, ".+"),
+	}
+
+	queryRes, err := ds[0].QueryExemplars(ctx, 0, 2000, allSeriesMatchers)
+	require.NoError(t, err)
+	assert.Len(t, queryRes.Timeseries, maxExemplarsLimit)
+
+	// Push another exemplar to exceed the limit.
+	_, err = ds[0].Push(ctx, makeWriteRequestSampleAndExemplar("another_series", 1000))
+	require.NoError(t, err)
+
+	_, err = ds[0].QueryExemplars(ctx, 0, 2000, allSeriesMatchers)
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "the query exceeded the maximum number of exemplars")
+
+	// A query selecting fewer exemplars still succeeds.
+	queryRes, err = ds[0].QueryExemplars(ctx, 0, 2000, []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabel, "another_series"),
+	})
+	require.NoError(t, err)
+	assert.Len(t, queryRes.Timeseries, 1)
+}
+
+func TestDistributor_Push_ShouldDropExemplarsOlderThanRetentionPeriod(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.MaxGlobalExemplarsPerUser = 10
+	limits.ExemplarsRetentionPeriod = model.Duration(time.Hour)
+
+	ds, ingesters, regs := prepare(t, prepConfig{
+		numIngesters:      1,
+		happyIngesters:    1,
+		replicationFactor: 1,
+		numDistributors:   1,
+		limits:            limits,
+	})
+
+	now := time.Now()
+	req := makeWriteRequestSampleAndExemplar("series", now.UnixMilli())
+	req.Timeseries = append(req.Timeseries, makeWriteRequestSampleAndExemplar("old_series", now.Add(-2*time.Hour).UnixMilli()).Timeseries...)
+	// The sample is recent, so that the exemplar isn't dropped because it's older than the samples of the request.
+	req.Timeseries[1].Samples[0].TimestampMs = now.UnixMilli()
+
+	_, err := ds[0].Push(ctx, req)
+	require.NoError(t, err)
+
+	exemplars := map[string]int{}
+	for _, ts := range ingesters[0].series() {
+		exemplars[mimirpb.FromLabelAdaptersToLabels(ts.Labels).Get(model.MetricNameLabel)] = len(ts.Exemplars)
+	}
+	assert.Equal(t, map[string]int{"series": 1, "old_series": 0}, exemplars)
+
+	require.NoError(t, testutil.GatherAndCompare(regs[0], strings.NewReader(`
+		# HELP cortex_discarded_exemplars_total The total number of exemplars that were discarded.
+		# TYPE cortex_discarded_exemplars_total counter
+		cortex_discarded_exemplars_total{reason="exemplar_too_old",user="user"} 1
+	`), "cortex_discarded_exemplars_total"))
+}
+
+func TestDistributor_Push_ShouldLogDebugSeries(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.MaxLabelNamesPerSeries = 3
+	limits.DebugSeriesSelector = `{__name__="foo",sample=~"0|1"}`
+
+	ds, _, _ := prepare(t, prepConfig{
+		numIngesters:      1,
+		happyIngesters:    1,
+		replicationFactor: 1,
+		numDistributors:   1,
+		limits:            limits,
+	})
+
+	logs := &concurrency.SyncBuffer{}
+	ds[0].log = log.NewLogfmtLogger(logs)
+
+	req := makeWriteRequest(time.Now().UnixMilli(), 3, 0, false)
+	// This series has too many labels, so it's rejected.
+	req.Timeseries[1].Labels = append(req.Timeseries[1].Labels, mimirpb.LabelAdapter{Name: "zzz", Value: "zzz"})
+
+	_, err := ds[0].Push(ctx, req)
+	require.Error(t, err)
+
+	var lines []string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, `msg="debug series"`) {
+			lines = append(lines, line)
+		}
+	}
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `series="{__name__=\"foo\", bar=\"baz\", sample=\"1\", zzz=\"zzz\"}" samples=1 outcome=rejected`)
+	assert.Contains(t, lines[1], `series="{__name__=\"foo\", bar=\"baz\", sample=\"0\"}" samples=1 outcome=pushed`)
+}
+
+func makeWriteRequestSampleAndExemplar(metricName string, timestamp int64) *mimirpb.WriteRequest {
+	ts := makeExemplarTimeseries([]string{model.MetricNameLabel, metricName}, timestamp, []string{"traceID", "123"})
+	ts.Samples = []mimirpb.Sample{{Value: 1, TimestampMs: timestamp}}
+	return &mimirpb.WriteRequest{Timeseries: []mimirpb.PreallocTimeseries{ts}}
+}
+
 func TestDistributor_QueryStream_ShouldReturnErrorIfMaxChunkBytesPerQueryLimitIsReached(t *testing.T) {
 	const seriesToAdd = 10
 
@@ -2993,13 +3203,14 @@ func TestRelabelMiddleware(t *testing.T) {
 	ctxWithUser := user.InjectOrgID(context.Background(), "user")
 
 	type testCase struct {
-		name           string
-		ctx            context.Context
-		relabelConfigs []*relabel.Config
-		dropLabels     []string
-		reqs           []*mimirpb.WriteRequest
-		expectedReqs   []*mimirpb.WriteRequest
-		expectErrs     []bool
+		name            string
+		ctx             context.Context
+		relabelConfigs  []*relabel.Config
+		dropLabels      []string
+		reqs            []*mimirpb.WriteRequest
+		expectedReqs    []*mimirpb.WriteRequest
+		expectErrs      []bool
+		expectedMetrics string
 	}
 	testCases := []testCase{
 		{
@@ -3058,6 +3269,39 @@ func TestRelabelMiddleware(t *testing.T) {
 				makeWriteRequestForGenerators(5, labelSetGenForStringPairs(t, "label4", "value4"), nil, nil),
 			},
 			expectErrs: []bool{false, false, false, false},
+		}, {
+			name: "track the series relabeled and dropped by the relabel configs",
+			ctx:  ctxWithUser,
+			relabelConfigs: []*relabel.Config{
+				{
+					SourceLabels: []model.LabelName{"__name__"},
+					Action:       relabel.Drop,
+					Regex:        relabel.MustNewRegexp("metric2.*"),
+				},
+				{
+					Action: relabel.LabelDrop,
+					Regex:  relabel.MustNewRegexp("pod"),
+				},
+			},
+			reqs: []*mimirpb.WriteRequest{
+				makeWriteRequestForGenerators(2, labelSetGenForStringPairs(t, "__name__", "metric1", "pod", "pod"), nil, nil),
+				makeWriteRequestForGenerators(3, labelSetGenForStringPairs(t, "__name__", "metric2", "label", "value"), nil, nil),
+				makeWriteRequestForGenerators(1, labelSetGenForStringPairs(t, "__name__", "metric3", "label", "value"), nil, nil),
+			},
+			expectedReqs: []*mimirpb.WriteRequest{
+				makeWriteRequestForGenerators(2, labelSetGenForStringPairs(t, "__name__", "metric1"), nil, nil),
+				{Timeseries: []mimirpb.PreallocTimeseries{}},
+				makeWriteRequestForGenerators(1, labelSetGenForStringPairs(t, "__name__", "metric3", "label", "value"), nil, nil),
+			},
+			expectErrs: []bool{false, false, false},
+			expectedMetrics: `
+				# HELP cortex_distributor_relabeled_series_total The total number of received series whose labels have been changed by the tenant metric relabel configs.
+				# TYPE cortex_distributor_relabeled_series_total counter
+				cortex_distributor_relabeled_series_total{user="user"} 2
+				# HELP cortex_distributor_relabel_dropped_series_total The total number of received series dropped by the tenant metric relabel configs.
+				# TYPE cortex_distributor_relabel_dropped_series_total counter
+				cortex_distributor_relabel_dropped_series_total{user="user"} 3
+			`,
 		},
 	}
 
@@ -3081,7 +3325,7 @@ func TestRelabelMiddleware(t *testing.T) {
 			flagext.DefaultValues(&limits)
 			limits.MetricRelabelConfigs = tc.relabelConfigs
 			limits.DropLabels = tc.dropLabels
-			ds, _, _ := prepare(t, prepConfig{
+			ds, _, regs := prepare(t, prepConfig{
 				numDistributors: 1,
 				limits:          &limits,
 			})
@@ -3100,6 +3344,11 @@ func TestRelabelMiddleware(t *testing.T) {
 
 			// Cleanup must have been called once per request.
 			assert.Equal(t, len(tc.reqs), cleanupCallCount)
+
+			if tc.expectedMetrics != "" {
+				assert.NoError(t, testutil.GatherAndCompare(regs[0], strings.NewReader(tc.expectedMetrics),
+					"cortex_distributor_relabeled_series_total", "cortex_distributor_relabel_dropped_series_total"))
+			}
 		})
 	}
 }
@@ -3368,6 +3617,7 @@ type prepConfig struct {
 	forwarding                         bool
 	getForwarder                       func() forwarding.Forwarder
 	timeOut                            bool
+	idempotencyBackend                 string
 }
 
 func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, []*prometheus.Registry) {
@@ -3474,6 +3724,7 @@ func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, []*p
 		distributorCfg.InstanceLimits.MaxInflightPushRequestsBytes = cfg.maxInflightRequestsBytes
 		distributorCfg.InstanceLimits.MaxIngestionRate = cfg.maxIngestionRate
 		distributorCfg.ShuffleShardingLookbackPeriod = time.Hour
+		distributorCfg.Idempotency.Backend = cfg.idempotencyBackend
 
 		if cfg.forwarding {
 			distributorCfg.Forwarding.Enabled = true
@@ -3839,10 +4090,12 @@ func (i *mockIngester) Push(ctx context.Context, req *mimirpb.WriteRequest, opts
 
 			copy(item.Labels, series.TimeSeries.Labels)
 			copy(item.Samples, series.TimeSeries.Samples)
+			item.Exemplars = append(item.Exemplars, series.TimeSeries.Exemplars...)
 
 			i.timeseries[hash] = &mimirpb.PreallocTimeseries{TimeSeries: &item}
 		} else {
 			existing.Samples = append(existing.Samples, series.Samples...)
+			existing.Exemplars = append(existing.Exemplars, series.Exemplars...)
 		}
 	}
 
@@ -3929,6 +4182,43 @@ func (i *mockIngester) QueryStream(ctx context.Context, req *client.QueryRequest
 	}, nil
 }
 
+func (i *mockIngester) QueryExemplars(ctx context.Context, req *client.ExemplarQueryRequest, opts ...grpc.CallOption) (*client.ExemplarQueryResponse, error) {
+	i.Lock()
+	defer i.Unlock()
+
+	i.trackCall("QueryExemplars")
+
+	if !i.happy {
+		return nil, errFail
+	}
+
+	from, through, multiMatchers, err := client.FromExemplarQueryRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response := client.ExemplarQueryResponse{}
+	for _, ts := range i.timeseries {
+		var exemplars []mimirpb.Exemplar
+		for _, e := range ts.Exemplars {
+			if e.TimestampMs >= from && e.TimestampMs <= through {
+				exemplars = append(exemplars, e)
+			}
+		}
+		if len(exemplars) == 0 {
+			continue
+		}
+
+		for _, matchers := range multiMatchers {
+			if match(ts.Labels, matchers) {
+				response.Timeseries = append(response.Timeseries, mimirpb.TimeSeries{Labels: ts.Labels, Exemplars: exemplars})
+				break
+			}
+		}
+	}
+	return &response, nil
+}
+
 func (i *mockIngester) MetricsForLabelMatchers(ctx context.Context, req *client.MetricsForLabelMatchersRequest, opts ...grpc.CallOption) (*client.MetricsForLabelMatchersResponse, error) {
 	i.Lock()
 	defer i.Unlock()
@@ -35,8 +35,21 @@ var (
 	errNegativeUpdateTimeoutJitterMax = errors.New("HA tracker max update timeout jitter shouldn't be negative")
 	errInvalidFailoverTimeout         = "HA Tracker failover timeout (%v) must be at least 1s greater than update timeout - max jitter (%v)"
 	errMemberlistUnsupported          = errors.New("memberlist is not supported by the HA tracker since gossip propagation is too slow for HA purposes")
+	errInvalidElectionStrategy        = fmt.Errorf("invalid HA tracker election strategy, supported values are: %s", strings.Join(haTrackerElectionStrategies, ", "))
+	errHATrackerDisabled              = errors.New("the HA tracker is disabled")
+	errHAClusterNotFound              = errors.New("the HA cluster is not tracked")
+	errNoFailoverCandidate            = errors.New("no replica other than the elected one has recently sent samples for the HA cluster")
 )
 
+const (
+	// haTrackerElectionFirstWriter fails over to the replica that most recently sent samples.
+	haTrackerElectionFirstWriter = "first-writer"
+	// haTrackerElectionLowestReplicaName fails over to the replica with the lowest name among the ones that recently sent samples.
+	haTrackerElectionLowestReplicaName = "lowest-replica-name"
+)
+
+var haTrackerElectionStrategies = []string{haTrackerElectionFirstWriter, haTrackerElectionLowestReplicaName}
+
 type haTrackerLimits interface {
 	// MaxHAClusters returns max number of clusters that HA tracker should track for a user.
 	// Samples from additional clusters are rejected.
@@ -67,6 +80,8 @@ type HATrackerConfig struct {
 	// between the stored timestamp and the time we received a sample is
 	// more than this duration
 	FailoverTimeout time.Duration `yaml:"ha_tracker_failover_timeout" category:"advanced"`
+	// How the replica to fail over to is chosen among the replicas of a cluster.
+	ElectionStrategy string `yaml:"ha_tracker_election_strategy" category:"experimental"`
 
 	KVStore kv.Config `yaml:"kvstore" doc:"description=Backend storage to use for the ring. Please be aware that memberlist is not supported by the HA tracker since gossip propagation is too slow for HA purposes."`
 }
@@ -77,6 +92,7 @@ func (cfg *HATrackerConfig) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.UpdateTimeout, "distributor.ha-tracker.update-timeout", 15*time.Second, "Update the timestamp in the KV store for a given cluster/replica only after this amount of time has passed since the current stored timestamp.")
 	f.DurationVar(&cfg.UpdateTimeoutJitterMax, "distributor.ha-tracker.update-timeout-jitter-max", 5*time.Second, "Maximum jitter applied to the update timeout, in order to spread the HA heartbeats over time.")
 	f.DurationVar(&cfg.FailoverTimeout, "distributor.ha-tracker.failover-timeout", 30*time.Second, "If we don't receive any samples from the accepted replica for a cluster in this amount of time we will failover to the next replica we receive a sample from. This value must be greater than the update timeout")
+	f.StringVar(&cfg.ElectionStrategy, "distributor.ha-tracker.election-strategy", haTrackerElectionFirstWriter, fmt.Sprintf("How to choose the replica to fail over to, when a cluster has more than two replicas. Supported values are: %s. %s fails over to the replica that most recently sent samples, %s fails over to the replica with the lowest name among the ones that recently sent samples.", strings.Join(haTrackerElectionStrategies, ", "), haTrackerElectionFirstWriter, haTrackerElectionLowestReplicaName))
 
 	// We want the ability to use different Consul instances for the ring and
 	// for HA cluster tracking. We also customize the default keys prefix, in
@@ -100,6 +116,10 @@ func (cfg *HATrackerConfig) Validate() error {
 		return errMemberlistUnsupported
 	}
 
+	if !util.StringsContain(haTrackerElectionStrategies, cfg.ElectionStrategy) {
+		return errInvalidElectionStrategy
+	}
+
 	return nil
 }
 
@@ -134,10 +154,36 @@ type haTracker struct {
 
 // For one cluster, the information we need to do ha-tracking.
 type haClusterInfo struct {
-	elected                     ReplicaDesc // latest info from KVStore
-	electedLastSeenTimestamp    int64
-	nonElectedLastSeenReplica   string
-	nonElectedLastSeenTimestamp int64
+	elected                  ReplicaDesc // latest info from KVStore
+	electedLastSeenTimestamp int64
+	// Timestamp of the last sample received from each non-elected replica.
+	nonElectedLastSeen map[string]int64
+}
+
+// failoverCandidate returns the non-elected replica to fail over to according to the election strategy,
+// among the ones seen within the update timeout. It returns an empty string if there's no candidate.
+func (h *haTracker) failoverCandidate(entry *haClusterInfo, now time.Time) string {
+	var candidate string
+	var candidateLastSeen int64
+	for replica, lastSeen := range entry.nonElectedLastSeen {
+		if !h.withinUpdateTimeout(now, lastSeen) {
+			continue
+		}
+
+		switch {
+		case candidate == "":
+		case h.cfg.ElectionStrategy == haTrackerElectionLowestReplicaName:
+			if replica > candidate {
+				continue
+			}
+		default:
+			if lastSeen < candidateLastSeen || lastSeen == candidateLastSeen && replica > candidate {
+				continue
+			}
+		}
+		candidate, candidateLastSeen = replica, lastSeen
+	}
+	return candidate
 }
 
 // newHATracker returns a new HA cluster tracker using either Consul
@@ -311,9 +357,9 @@ func (h *haTracker) updateKVStoreAll(ctx context.Context, now time.Time) {
 			if h.withinUpdateTimeout(now, entry.electedLastSeenTimestamp) {
 				// We have seen the elected replica recently; carry on with that choice.
 				replica = entry.elected.Replica
-			} else if h.withinUpdateTimeout(now, entry.nonElectedLastSeenTimestamp) {
+			} else if candidate := h.failoverCandidate(entry, now); candidate != "" {
 				// Not seen elected but have seen another: attempt to fail over.
-				replica = entry.nonElectedLastSeenReplica
+				replica = candidate
 			} else {
 				continue // we don't have any recent timestamps
 			}
@@ -423,8 +469,15 @@ func (h *haTracker) checkReplica(ctx context.Context, userID, cluster, replica s
 			entry.electedLastSeenTimestamp = timestamp.FromTime(now)
 		} else {
 			// Sample received is from non-elected replica: record details and reject.
-			entry.nonElectedLastSeenReplica = replica
-			entry.nonElectedLastSeenTimestamp = timestamp.FromTime(now)
+			if _, ok := entry.nonElectedLastSeen[replica]; !ok {
+				// Forget the replicas not seen recently, before tracking a new one.
+				for r, lastSeen := range entry.nonElectedLastSeen {
+					if !h.withinUpdateTimeout(now, lastSeen) {
+						delete(entry.nonElectedLastSeen, r)
+					}
+				}
+			}
+			entry.nonElectedLastSeen[replica] = timestamp.FromTime(now)
 			err = replicasNotMatchError{replica: replica, elected: entry.elected.Replica}
 		}
 		h.electedLock.Unlock()
@@ -459,11 +512,18 @@ func (h *haTracker) updateCache(userID, cluster string, desc *ReplicaDesc) {
 	}
 	entry := h.clusters[userID][cluster]
 	if entry == nil {
-		entry = &haClusterInfo{}
+		entry = &haClusterInfo{nonElectedLastSeen: map[string]int64{}}
 		h.clusters[userID][cluster] = entry
 	}
 	if desc.Replica != entry.elected.Replica {
 		h.electedReplicaChanges.WithLabelValues(userID, cluster).Inc()
+
+		// Keep track of when the previously elected replica and the newly elected one have been last seen.
+		if entry.elected.Replica != "" && entry.electedLastSeenTimestamp > 0 {
+			entry.nonElectedLastSeen[entry.elected.Replica] = entry.electedLastSeenTimestamp
+		}
+		entry.electedLastSeenTimestamp = entry.nonElectedLastSeen[desc.Replica]
+		delete(entry.nonElectedLastSeen, desc.Replica)
 	}
 	entry.elected = *desc
 	h.electedReplicaTimestamp.WithLabelValues(userID, cluster).Set(float64(desc.ReceivedAt / 1000))
@@ -505,6 +565,59 @@ func (h *haTracker) updateKVStore(ctx context.Context, userID, cluster, replica
 	return err
 }
 
+// forceFailover elects the replica for the cluster in the KV store, regardless of the failover timeout.
+// If replica is empty, the replica to fail over to is chosen according to the election strategy, among
+// the non-elected replicas recently seen by this distributor.
+func (h *haTracker) forceFailover(ctx context.Context, userID, cluster, replica string, now time.Time) (ReplicaDesc, error) {
+	if !h.cfg.EnableHATracker {
+		return ReplicaDesc{}, errHATrackerDisabled
+	}
+
+	if replica == "" {
+		h.electedLock.RLock()
+		if entry := h.clusters[userID][cluster]; entry != nil {
+			replica = h.failoverCandidate(entry, now)
+		}
+		h.electedLock.RUnlock()
+
+		if replica == "" {
+			return ReplicaDesc{}, errNoFailoverCandidate
+		}
+	}
+
+	key := fmt.Sprintf("%s/%s", userID, cluster)
+	var desc *ReplicaDesc
+	err := h.client.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
+		current, ok := in.(*ReplicaDesc)
+		if !ok || current == nil || current.DeletedAt > 0 {
+			return nil, false, errHAClusterNotFound
+		}
+		if current.Replica == replica {
+			desc = current
+			return nil, false, nil
+		}
+
+		desc = &ReplicaDesc{
+			Replica:    replica,
+			ReceivedAt: timestamp.FromTime(now),
+		}
+		return desc, true, nil
+	})
+	h.kvCASCalls.WithLabelValues(userID, cluster).Inc()
+	if err != nil {
+		return ReplicaDesc{}, err
+	}
+
+	level.Info(h.logger).Log("msg", "forced HA tracker failover", "user", userID, "cluster", cluster, "replica", desc.Replica)
+
+	// Update the cache right away, without waiting for the KV store watch notification.
+	h.electedLock.Lock()
+	h.updateCache(userID, cluster, desc)
+	h.electedLock.Unlock()
+
+	return *desc, nil
+}
+
 type replicasNotMatchError struct {
 	replica, elected string
 }
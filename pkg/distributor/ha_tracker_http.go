@@ -7,11 +7,13 @@ package distributor
 
 import (
 	_ "embed" // Used to embed html template
+	"errors"
 	"html/template"
 	"net/http"
 	"sort"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/prometheus/prometheus/model/timestamp"
 
 	"github.com/grafana/mimir/pkg/util"
@@ -69,3 +71,83 @@ func (h *haTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		Now:     time.Now(),
 	}, haTrackerStatusPageTemplate, req)
 }
+
+type haTrackerClusterStatus struct {
+	UserID           string                     `json:"userID"`
+	Cluster          string                     `json:"cluster"`
+	ElectedReplica   string                     `json:"electedReplica"`
+	ElectedAt        time.Time                  `json:"electedAt"`
+	ElectionStrategy string                     `json:"electionStrategy"`
+	Replicas         []haTrackerReplicaLastSeen `json:"replicas"`
+}
+
+type haTrackerReplicaLastSeen struct {
+	Replica  string    `json:"replica"`
+	Elected  bool      `json:"elected"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// ClusterHandler returns the elected replica of a cluster of a tenant, along with the replicas of the cluster
+// this distributor has received samples from.
+func (h *haTracker) ClusterHandler(w http.ResponseWriter, req *http.Request) {
+	userID, cluster := mux.Vars(req)["tenant"], mux.Vars(req)["cluster"]
+
+	status, ok := h.clusterStatus(userID, cluster)
+	if !ok {
+		http.Error(w, errHAClusterNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	util.WriteJSONResponse(w, status)
+}
+
+// FailoverHandler forces the failover of a cluster of a tenant to the replica in the "replica" parameter or,
+// if empty, to the replica chosen by the election strategy.
+func (h *haTracker) FailoverHandler(w http.ResponseWriter, req *http.Request) {
+	userID, cluster := mux.Vars(req)["tenant"], mux.Vars(req)["cluster"]
+
+	_, err := h.forceFailover(req.Context(), userID, cluster, req.FormValue("replica"), time.Now())
+	switch {
+	case errors.Is(err, errHATrackerDisabled), errors.Is(err, errNoFailoverCandidate):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, errHAClusterNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status, _ := h.clusterStatus(userID, cluster)
+	util.WriteJSONResponse(w, status)
+}
+
+func (h *haTracker) clusterStatus(userID, cluster string) (haTrackerClusterStatus, bool) {
+	h.electedLock.RLock()
+	defer h.electedLock.RUnlock()
+
+	entry := h.clusters[userID][cluster]
+	if entry == nil {
+		return haTrackerClusterStatus{}, false
+	}
+
+	status := haTrackerClusterStatus{
+		UserID:           userID,
+		Cluster:          cluster,
+		ElectedReplica:   entry.elected.Replica,
+		ElectedAt:        timestamp.Time(entry.elected.ReceivedAt),
+		ElectionStrategy: h.cfg.ElectionStrategy,
+		Replicas:         []haTrackerReplicaLastSeen{},
+	}
+	if entry.electedLastSeenTimestamp > 0 {
+		status.Replicas = append(status.Replicas, haTrackerReplicaLastSeen{Replica: entry.elected.Replica, Elected: true, LastSeen: timestamp.Time(entry.electedLastSeenTimestamp)})
+	}
+	for replica, lastSeen := range entry.nonElectedLastSeen {
+		status.Replicas = append(status.Replicas, haTrackerReplicaLastSeen{Replica: replica, LastSeen: timestamp.Time(lastSeen)})
+	}
+	sort.Slice(status.Replicas, func(i, j int) bool {
+		return status.Replicas[i].Replica < status.Replicas[j].Replica
+	})
+
+	return status, true
+}
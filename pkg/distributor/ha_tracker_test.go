@@ -126,6 +126,16 @@ func TestHATrackerConfig_Validate(t *testing.T) {
 			}(),
 			expectedErr: errMemberlistUnsupported,
 		},
+		"should fail if election strategy is invalid": {
+			cfg: func() HATrackerConfig {
+				cfg := HATrackerConfig{}
+				flagext.DefaultValues(&cfg)
+				cfg.ElectionStrategy = "unknown"
+
+				return cfg
+			}(),
+			expectedErr: errInvalidElectionStrategy,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -336,6 +346,117 @@ func TestCheckReplicaMultiClusterTimeout(t *testing.T) {
 	}), uint64(0))
 }
 
+func TestCheckReplicaMultipleReplicasElectionStrategy(t *testing.T) {
+	tests := map[string]struct {
+		strategy        string
+		expectedElected string
+	}{
+		"first-writer should fail over to the replica which most recently sent samples": {
+			strategy:        haTrackerElectionFirstWriter,
+			expectedElected: "replica3",
+		},
+		"lowest-replica-name should fail over to the replica with the lowest name": {
+			strategy:        haTrackerElectionLowestReplicaName,
+			expectedElected: "replica2",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			kvStore, closer := consul.NewInMemoryClient(GetReplicaDescCodec(), log.NewNopLogger(), nil)
+			t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+			c, err := newHATracker(HATrackerConfig{
+				EnableHATracker:        true,
+				KVStore:                kv.Config{Mock: kvStore},
+				UpdateTimeout:          time.Second,
+				UpdateTimeoutJitterMax: 0,
+				FailoverTimeout:        2 * time.Second,
+				ElectionStrategy:       testData.strategy,
+			}, trackerLimits{maxClusters: 100}, nil, log.NewNopLogger())
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+			defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+			now := time.Now()
+
+			// replica1 is elected, the other replicas are rejected.
+			require.NoError(t, c.checkReplica(context.Background(), "user", "c1", "replica1", now))
+			assert.Error(t, c.checkReplica(context.Background(), "user", "c1", "replica3", now))
+			assert.Error(t, c.checkReplica(context.Background(), "user", "c1", "replica2", now))
+
+			// replica1 stops sending samples, while replica2 and replica3 keep going.
+			now = now.Add(2500 * time.Millisecond)
+			assert.Error(t, c.checkReplica(context.Background(), "user", "c1", "replica2", now))
+			assert.Error(t, c.checkReplica(context.Background(), "user", "c1", "replica3", now.Add(100*time.Millisecond)))
+
+			now = now.Add(200 * time.Millisecond)
+			c.updateKVStoreAll(context.Background(), now)
+			checkReplicaTimestamp(t, time.Second, c, "user", "c1", testData.expectedElected, now)
+
+			require.NoError(t, c.checkReplica(context.Background(), "user", "c1", testData.expectedElected, now))
+			assert.Error(t, c.checkReplica(context.Background(), "user", "c1", "replica1", now))
+		})
+	}
+}
+
+func TestHATracker_ForceFailover(t *testing.T) {
+	kvStore, closer := consul.NewInMemoryClient(GetReplicaDescCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	c, err := newHATracker(HATrackerConfig{
+		EnableHATracker:        true,
+		KVStore:                kv.Config{Mock: kvStore},
+		UpdateTimeout:          time.Second,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        time.Minute,
+		ElectionStrategy:       haTrackerElectionLowestReplicaName,
+	}, trackerLimits{maxClusters: 100}, nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	now := time.Now()
+
+	// The cluster is not tracked yet.
+	_, err = c.forceFailover(context.Background(), "user", "c1", "replica2", now)
+	assert.ErrorIs(t, err, errHAClusterNotFound)
+
+	require.NoError(t, c.checkReplica(context.Background(), "user", "c1", "replica1", now))
+
+	// No other replica has been seen yet.
+	_, err = c.forceFailover(context.Background(), "user", "c1", "", now)
+	assert.ErrorIs(t, err, errNoFailoverCandidate)
+
+	assert.Error(t, c.checkReplica(context.Background(), "user", "c1", "replica3", now))
+	assert.Error(t, c.checkReplica(context.Background(), "user", "c1", "replica2", now))
+
+	// Fail over to the replica chosen by the election strategy, well before the failover timeout.
+	desc, err := c.forceFailover(context.Background(), "user", "c1", "", now)
+	require.NoError(t, err)
+	assert.Equal(t, "replica2", desc.Replica)
+	require.NoError(t, c.checkReplica(context.Background(), "user", "c1", "replica2", now))
+	assert.Error(t, c.checkReplica(context.Background(), "user", "c1", "replica1", now))
+
+	// Fail over to the requested replica.
+	desc, err = c.forceFailover(context.Background(), "user", "c1", "replica3", now)
+	require.NoError(t, err)
+	assert.Equal(t, "replica3", desc.Replica)
+	require.NoError(t, c.checkReplica(context.Background(), "user", "c1", "replica3", now))
+	assert.Error(t, c.checkReplica(context.Background(), "user", "c1", "replica2", now))
+
+	status, ok := c.clusterStatus("user", "c1")
+	require.True(t, ok)
+	assert.Equal(t, "replica3", status.ElectedReplica)
+	assert.Equal(t, []string{"replica1", "replica2", "replica3"}, func() []string {
+		var replicas []string
+		for _, r := range status.Replicas {
+			replicas = append(replicas, r.Replica)
+		}
+		return replicas
+	}())
+}
+
 // Test that writes only happen every update timeout.
 func TestCheckReplicaUpdateTimeout(t *testing.T) {
 	replica := "r1"
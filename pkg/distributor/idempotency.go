@@ -0,0 +1,244 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/tenant"
+	lru "github.com/hashicorp/golang-lru/simplelru"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/push"
+)
+
+const (
+	// IdempotencyBackendInMemory is the value for the in-memory idempotency keys backend.
+	IdempotencyBackendInMemory = "inmemory"
+	// IdempotencyBackendMemcached is the value for the memcached idempotency keys backend.
+	IdempotencyBackendMemcached = cache.BackendMemcached
+)
+
+var (
+	supportedIdempotencyBackends = []string{IdempotencyBackendInMemory, IdempotencyBackendMemcached}
+
+	errUnsupportedIdempotencyBackend = errors.New("unsupported idempotency keys backend")
+	errInvalidIdempotencyTTL         = errors.New("the idempotency keys TTL must be greater than 0")
+	errInvalidIdempotencyMaxKeys     = errors.New("the max number of in-memory idempotency keys per tenant must be greater than 0")
+)
+
+// IdempotencyConfig configures the deduplication of the push requests retried by clients, based on the
+// idempotency key they're sent with.
+type IdempotencyConfig struct {
+	Backend   string                    `yaml:"backend" category:"experimental"`
+	Memcached cache.MemcachedConfig     `yaml:"memcached"`
+	InMemory  InMemoryIdempotencyConfig `yaml:"inmemory"`
+
+	TTL time.Duration `yaml:"ttl" category:"experimental"`
+}
+
+func (cfg *IdempotencyConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.StringVar(&cfg.Backend, prefix+"backend", "", fmt.Sprintf("Backend storing the idempotency keys of the push requests successfully ingested, if not empty. Push requests sent with the %s header set to the key of a request already ingested for the same tenant are dropped, and a successful response is returned. Supported values: %s.", push.IdempotencyKeyHeader, strings.Join(supportedIdempotencyBackends, ", ")))
+
+	cfg.InMemory.RegisterFlagsWithPrefix(f, prefix+"inmemory.")
+	cfg.Memcached.RegisterFlagsWithPrefix(f, prefix+"memcached.")
+
+	f.DurationVar(&cfg.TTL, prefix+"ttl", 10*time.Minute, "How long the idempotency keys of the push requests successfully ingested are retained. It should be greater than the time clients retry a push request for.")
+}
+
+// Validate the config.
+func (cfg *IdempotencyConfig) Validate() error {
+	if cfg.Backend == "" {
+		return nil
+	}
+	if !util.StringsContain(supportedIdempotencyBackends, cfg.Backend) {
+		return errUnsupportedIdempotencyBackend
+	}
+	if cfg.TTL <= 0 {
+		return errInvalidIdempotencyTTL
+	}
+
+	switch cfg.Backend {
+	case IdempotencyBackendInMemory:
+		if cfg.InMemory.MaxKeysPerTenant <= 0 {
+			return errInvalidIdempotencyMaxKeys
+		}
+	case IdempotencyBackendMemcached:
+		if err := cfg.Memcached.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// InMemoryIdempotencyConfig configures the in-memory idempotency keys backend.
+type InMemoryIdempotencyConfig struct {
+	MaxKeysPerTenant int `yaml:"max_keys_per_tenant" category:"experimental"`
+}
+
+func (cfg *InMemoryIdempotencyConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.IntVar(&cfg.MaxKeysPerTenant, prefix+"max-keys-per-tenant", 10000, "Maximum number of idempotency keys retained in memory per tenant. The least recently used keys are evicted first.")
+}
+
+// idempotencyStore stores the digests of the idempotency keys of the push requests successfully ingested.
+type idempotencyStore interface {
+	// contains returns whether the digest has been stored for the tenant and is not expired yet.
+	contains(ctx context.Context, userID, digest string) bool
+
+	// store stores the digest for the tenant.
+	store(ctx context.Context, userID, digest string)
+
+	// deleteTenant removes all digests stored for the tenant, if supported by the backend.
+	deleteTenant(userID string)
+}
+
+// newIdempotencyStore creates a new idempotency store based on the input configuration.
+// It returns nil if no backend is configured.
+func newIdempotencyStore(cfg IdempotencyConfig, logger log.Logger, reg prometheus.Registerer) (idempotencyStore, error) {
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case IdempotencyBackendInMemory:
+		return newInMemoryIdempotencyStore(cfg.InMemory.MaxKeysPerTenant, cfg.TTL), nil
+	case IdempotencyBackendMemcached:
+		backendCfg := cache.BackendConfig{Backend: cache.BackendMemcached, Memcached: cfg.Memcached}
+		client, err := cache.CreateClient("distributor-idempotency-keys", backendCfg, logger, reg)
+		if err != nil {
+			return nil, errors.Wrap(err, "create idempotency keys cache")
+		}
+		return &cacheIdempotencyStore{cache: cache.NewSpanlessTracingCache(client, logger), ttl: cfg.TTL}, nil
+	default:
+		return nil, errUnsupportedIdempotencyBackend
+	}
+}
+
+// idempotencyDigest returns the digest of the input idempotency key for the tenant.
+func idempotencyDigest(userID, key string) string {
+	sum := sha256.Sum256([]byte(userID + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// inMemoryIdempotencyStore keeps, for each tenant, the most recently stored digests in memory.
+type inMemoryIdempotencyStore struct {
+	maxKeysPerTenant int
+	ttl              time.Duration
+	now              func() time.Time
+
+	mtx     sync.Mutex
+	tenants map[string]*lru.LRU // Tenant ID -> digest -> expiration time.
+}
+
+func newInMemoryIdempotencyStore(maxKeysPerTenant int, ttl time.Duration) *inMemoryIdempotencyStore {
+	return &inMemoryIdempotencyStore{
+		maxKeysPerTenant: maxKeysPerTenant,
+		ttl:              ttl,
+		now:              time.Now,
+		tenants:          map[string]*lru.LRU{},
+	}
+}
+
+func (s *inMemoryIdempotencyStore) contains(_ context.Context, userID, digest string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	keys, ok := s.tenants[userID]
+	if !ok {
+		return false
+	}
+	expiresAt, ok := keys.Get(digest)
+	if !ok {
+		return false
+	}
+	if !s.now().Before(expiresAt.(time.Time)) {
+		keys.Remove(digest)
+		return false
+	}
+	return true
+}
+
+func (s *inMemoryIdempotencyStore) store(_ context.Context, userID, digest string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	keys, ok := s.tenants[userID]
+	if !ok {
+		// The error is returned only if the size is not positive, which is prevented by the config validation.
+		keys, _ = lru.NewLRU(s.maxKeysPerTenant, nil)
+		s.tenants[userID] = keys
+	}
+	keys.Add(digest, s.now().Add(s.ttl))
+}
+
+func (s *inMemoryIdempotencyStore) deleteTenant(userID string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.tenants, userID)
+}
+
+// cacheIdempotencyStore stores the digests in a cache shared between distributors.
+type cacheIdempotencyStore struct {
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+func (s *cacheIdempotencyStore) contains(ctx context.Context, _, digest string) bool {
+	key := idempotencyCacheKey(digest)
+	_, ok := s.cache.Fetch(ctx, []string{key})[key]
+	return ok
+}
+
+func (s *cacheIdempotencyStore) store(ctx context.Context, _, digest string) {
+	s.cache.Store(ctx, map[string][]byte{idempotencyCacheKey(digest): {1}}, s.ttl)
+}
+
+func (s *cacheIdempotencyStore) deleteTenant(string) {
+	// Keys expire on their own.
+}
+
+func idempotencyCacheKey(digest string) string {
+	// The tenant ID is already part of the digest, so it doesn't need to be part of the key.
+	return "idem:" + digest
+}
+
+// prePushIdempotencyMiddleware drops the push requests whose idempotency key matches a request already
+// successfully ingested for the same tenant, returning a successful response without ingesting them again.
+func (d *Distributor) prePushIdempotencyMiddleware(next push.Func) push.Func {
+	return func(ctx context.Context, pushReq *push.Request) (*mimirpb.WriteResponse, error) {
+		if d.idempotencyStore == nil || pushReq.IdempotencyKey == "" {
+			return next(ctx, pushReq)
+		}
+
+		userID, err := tenant.TenantID(ctx)
+		if err != nil {
+			pushReq.CleanUp()
+			return nil, err
+		}
+
+		digest := idempotencyDigest(userID, pushReq.IdempotencyKey)
+		if d.idempotencyStore.contains(ctx, userID, digest) {
+			pushReq.CleanUp()
+			d.idempotencyDedupedRequests.WithLabelValues(userID).Inc()
+			return &mimirpb.WriteResponse{}, nil
+		}
+
+		resp, err := next(ctx, pushReq)
+		if err == nil {
+			d.idempotencyStore.store(ctx, userID, digest)
+		}
+		return resp, err
+	}
+}
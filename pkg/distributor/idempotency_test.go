@@ -0,0 +1,171 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/flagext"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/push"
+)
+
+func TestIdempotencyConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		setup    func(cfg *IdempotencyConfig)
+		expected error
+	}{
+		"should pass with the default config": {
+			setup:    func(*IdempotencyConfig) {},
+			expected: nil,
+		},
+		"should pass with the in-memory backend": {
+			setup: func(cfg *IdempotencyConfig) {
+				cfg.Backend = IdempotencyBackendInMemory
+			},
+			expected: nil,
+		},
+		"should fail on unsupported backend": {
+			setup: func(cfg *IdempotencyConfig) {
+				cfg.Backend = "redis"
+			},
+			expected: errUnsupportedIdempotencyBackend,
+		},
+		"should fail on non-positive TTL": {
+			setup: func(cfg *IdempotencyConfig) {
+				cfg.Backend = IdempotencyBackendInMemory
+				cfg.TTL = 0
+			},
+			expected: errInvalidIdempotencyTTL,
+		},
+		"should fail on non-positive max keys per tenant with the in-memory backend": {
+			setup: func(cfg *IdempotencyConfig) {
+				cfg.Backend = IdempotencyBackendInMemory
+				cfg.InMemory.MaxKeysPerTenant = 0
+			},
+			expected: errInvalidIdempotencyMaxKeys,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := Config{}
+			flagext.DefaultValues(&cfg)
+			testData.setup(&cfg.Idempotency)
+
+			assert.Equal(t, testData.expected, cfg.Idempotency.Validate())
+		})
+	}
+}
+
+func TestInMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := newInMemoryIdempotencyStore(2, time.Minute)
+	s.now = func() time.Time { return now }
+
+	s.store(ctx, "user-1", "a")
+	s.store(ctx, "user-1", "b")
+	assert.True(t, s.contains(ctx, "user-1", "a"))
+	assert.True(t, s.contains(ctx, "user-1", "b"))
+
+	// Digests are isolated between tenants.
+	assert.False(t, s.contains(ctx, "user-2", "a"))
+
+	// The least recently used digest is evicted once the limit is reached.
+	s.store(ctx, "user-1", "c")
+	assert.False(t, s.contains(ctx, "user-1", "a"))
+	assert.True(t, s.contains(ctx, "user-1", "b"))
+	assert.True(t, s.contains(ctx, "user-1", "c"))
+
+	// Digests expire after the TTL.
+	now = now.Add(time.Minute)
+	assert.False(t, s.contains(ctx, "user-1", "b"))
+	assert.False(t, s.contains(ctx, "user-1", "c"))
+
+	s.store(ctx, "user-1", "d")
+	s.deleteTenant("user-1")
+	assert.False(t, s.contains(ctx, "user-1", "d"))
+}
+
+func TestIdempotencyDigest(t *testing.T) {
+	assert.Equal(t, idempotencyDigest("user", "key"), idempotencyDigest("user", "key"))
+	assert.NotEqual(t, idempotencyDigest("user-1", "key"), idempotencyDigest("user-2", "key"))
+	assert.NotEqual(t, idempotencyDigest("user", "key-1"), idempotencyDigest("user", "key-2"))
+	assert.NotEqual(t, idempotencyDigest("a", "bc"), idempotencyDigest("ab", "c"))
+}
+
+func TestDistributor_Push_IdempotencyKey(t *testing.T) {
+	distributors, ingesters, regs := prepare(t, prepConfig{
+		numIngesters:       3,
+		happyIngesters:     3,
+		numDistributors:    1,
+		idempotencyBackend: IdempotencyBackendInMemory,
+	})
+	d := distributors[0]
+
+	pushWithKey := func(userID, key string, req *mimirpb.WriteRequest) (*mimirpb.WriteResponse, error) {
+		pushReq := push.NewParsedRequest(req)
+		pushReq.IdempotencyKey = key
+		return d.PushWithMiddlewares(user.InjectOrgID(context.Background(), userID), pushReq)
+	}
+
+	countSeries := func() int {
+		count := 0
+		for i := range ingesters {
+			count += len(ingesters[i].series())
+		}
+		return count
+	}
+
+	// The first request is ingested.
+	resp, err := pushWithKey("user-1", "key-1", makeWriteRequest(0, 1, 0, false, "first"))
+	require.NoError(t, err)
+	assert.Equal(t, emptyResponse, resp)
+	seriesAfterFirstPush := countSeries()
+	require.Greater(t, seriesAfterFirstPush, 0)
+
+	// The retried request with the same key is not ingested again.
+	resp, err = pushWithKey("user-1", "key-1", makeWriteRequest(0, 1, 0, false, "retried"))
+	require.NoError(t, err)
+	assert.Equal(t, emptyResponse, resp)
+	assert.Equal(t, seriesAfterFirstPush, countSeries())
+
+	// The same key is not deduplicated for a different tenant.
+	_, err = pushWithKey("user-2", "key-1", makeWriteRequest(0, 1, 0, false, "other_tenant"))
+	require.NoError(t, err)
+	assert.Greater(t, countSeries(), seriesAfterFirstPush)
+
+	// The key of a failed request is not stored, so the retry is ingested.
+	invalid := &mimirpb.WriteRequest{Timeseries: []mimirpb.PreallocTimeseries{
+		makeWriteRequestTimeseries([]mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}}, 0, 1),
+	}}
+	_, err = pushWithKey("user-1", "key-2", invalid)
+	require.Error(t, err)
+
+	seriesBeforeRetry := countSeries()
+	_, err = pushWithKey("user-1", "key-2", makeWriteRequest(0, 1, 0, false, "after_failure"))
+	require.NoError(t, err)
+	assert.Greater(t, countSeries(), seriesBeforeRetry)
+
+	// Requests without a key are never deduplicated.
+	seriesBeforeNoKey := countSeries()
+	_, err = pushWithKey("user-1", "", makeWriteRequest(0, 1, 0, false, "no_key"))
+	require.NoError(t, err)
+	assert.Greater(t, countSeries(), seriesBeforeNoKey)
+
+	assert.NoError(t, testutil.GatherAndCompare(regs[0], strings.NewReader(`
+		# HELP cortex_distributor_idempotency_deduped_requests_total The total number of push requests dropped because their idempotency key matched a request already ingested.
+		# TYPE cortex_distributor_idempotency_deduped_requests_total counter
+		cortex_distributor_idempotency_deduped_requests_total{user="user-1"} 1
+	`), "cortex_distributor_idempotency_deduped_requests_total"))
+}
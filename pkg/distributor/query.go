@@ -45,6 +45,14 @@ func (d *Distributor) QueryExemplars(ctx context.Context, from, to model.Time, m
 			return err
 		}
 
+		userID, err := tenant.TenantID(ctx)
+		if err != nil {
+			return err
+		}
+		if maxExemplars := d.limits.MaxExemplarsPerQuery(userID); maxExemplars > 0 && countExemplars(result) > maxExemplars {
+			return validation.NewMaxExemplarsPerQueryError(maxExemplars)
+		}
+
 		if s := opentracing.SpanFromContext(ctx); s != nil {
 			s.LogKV("series", len(result.Timeseries))
 		}
@@ -178,6 +186,14 @@ func mergeExemplarQueryResponses(results []interface{}) *ingester_client.Exempla
 	return &ingester_client.ExemplarQueryResponse{Timeseries: result}
 }
 
+func countExemplars(resp *ingester_client.ExemplarQueryResponse) int {
+	count := 0
+	for _, ts := range resp.Timeseries {
+		count += len(ts.Exemplars)
+	}
+	return count
+}
+
 // queryIngesterStream queries the ingesters using the new streaming API.
 func (d *Distributor) queryIngesterStream(ctx context.Context, replicationSet ring.ReplicationSet, req *ingester_client.QueryRequest) (*ingester_client.QueryStreamResponse, error) {
 	var (
@@ -94,6 +94,34 @@ func (s *ingestionRateStrategy) Burst(tenantID string) int {
 	return s.limits.IngestionBurstSize(tenantID)
 }
 
+type rulerIngestionRateStrategy struct {
+	limits *validation.Overrides
+}
+
+func newRulerIngestionRateStrategy(limits *validation.Overrides) limiter.RateLimiterStrategy {
+	return &rulerIngestionRateStrategy{
+		limits: limits,
+	}
+}
+
+func (s *rulerIngestionRateStrategy) Limit(tenantID string) float64 {
+	if lm := s.limits.RulerIngestionRate(tenantID); lm > 0 {
+		return lm
+	}
+	return float64(rate.Inf)
+}
+
+func (s *rulerIngestionRateStrategy) Burst(tenantID string) int {
+	if s.limits.RulerIngestionRate(tenantID) <= 0 {
+		// Burst is ignored when limit = rate.Inf
+		return 0
+	}
+	if lm := s.limits.RulerIngestionBurstSize(tenantID); lm > 0 {
+		return lm
+	}
+	return s.limits.IngestionBurstSize(tenantID)
+}
+
 type infiniteStrategy struct{}
 
 func newInfiniteRateStrategy() limiter.RateLimiterStrategy {
@@ -48,6 +48,9 @@ func (cfg *CombinedFrontendConfig) Validate(log log.Logger) error {
 	if err := cfg.QueryMiddleware.Validate(); err != nil {
 		return err
 	}
+	if err := cfg.Handler.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
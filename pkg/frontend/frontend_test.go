@@ -36,6 +36,7 @@ import (
 	"github.com/grafana/mimir/pkg/frontend/v1/frontendv1pb"
 	querier_worker "github.com/grafana/mimir/pkg/querier/worker"
 	"github.com/grafana/mimir/pkg/scheduler/schedulerdiscovery"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
@@ -254,7 +255,7 @@ func testFrontend(t *testing.T, config CombinedFrontendConfig, handler http.Hand
 	r.PathPrefix("/").Handler(middleware.Merge(
 		middleware.AuthenticateUser,
 		middleware.Tracer{},
-	).Wrap(transport.NewHandler(config.Handler, rt, logger, nil)))
+	).Wrap(transport.NewHandler(config.Handler, limits{}, rt, logger, nil)))
 
 	httpServer := http.Server{
 		Handler: r,
@@ -295,3 +296,19 @@ type limits struct {
 func (l limits) MaxQueriersPerUser(_ string) int {
 	return l.queriers
 }
+
+func (l limits) QueryTimeout(_ string) time.Duration {
+	return 0
+}
+
+func (l limits) SlowQueryLogThreshold(_ string) time.Duration {
+	return 0
+}
+
+func (l limits) BlockedQueries(_ string) []*validation.BlockedQuery {
+	return nil
+}
+
+func (l limits) MaxResponseSizeBytes(_ string) int {
+	return 0
+}
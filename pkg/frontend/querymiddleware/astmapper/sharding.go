@@ -17,17 +17,21 @@ import (
 	"github.com/grafana/mimir/pkg/storage/sharding"
 )
 
-// NewSharding creates a new query sharding mapper.
-func NewSharding(ctx context.Context, shards int, logger log.Logger, stats *MapperStats) (ASTMapper, error) {
+// NewSharding creates a new query sharding mapper. If verticalSharding is true, the queries
+// without aggregations are sharded by series too, and the results concatenated in the query-frontend.
+func NewSharding(ctx context.Context, shards int, verticalSharding bool, logger log.Logger, stats *MapperStats) (ASTMapper, error) {
 	shardSummer, err := newShardSummer(ctx, shards, vectorSquasher, logger, stats)
 	if err != nil {
 		return nil, err
 	}
 	subtreeFolder := newSubtreeFolder()
-	return NewMultiMapper(
-		shardSummer,
-		subtreeFolder,
-	), nil
+
+	mapper := NewMultiMapper()
+	if verticalSharding {
+		mapper.Register(newVerticalSharder(ctx, shards, vectorSquasher, logger, stats))
+	}
+	mapper.Register(shardSummer, subtreeFolder)
+	return mapper, nil
 }
 
 type squasher = func(...parser.Expr) (parser.Expr, error)
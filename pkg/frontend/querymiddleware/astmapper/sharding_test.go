@@ -508,7 +508,7 @@ func TestShardSummer(t *testing.T) {
 
 		t.Run(tt.in, func(t *testing.T) {
 			stats := NewMapperStats()
-			mapper, err := NewSharding(context.Background(), 3, log.NewNopLogger(), stats)
+			mapper, err := NewSharding(context.Background(), 3, false, log.NewNopLogger(), stats)
 			require.NoError(t, err)
 			expr, err := parser.ParseExpr(tt.in)
 			require.NoError(t, err)
@@ -618,3 +618,86 @@ func TestIsSubqueryCall(t *testing.T) {
 		})
 	}
 }
+
+func TestShardingWithVerticalSharding(t *testing.T) {
+	for _, tt := range []struct {
+		in                     string
+		out                    string
+		expectedShardedQueries int
+	}{
+		{
+			in:                     `rate(foo[5m])`,
+			out:                    concatShards(3, `rate(foo{__query_shard__="x_of_y"}[5m])`),
+			expectedShardedQueries: 3,
+		},
+		{
+			in:                     `(label_replace(rate(foo{bar="baz"}[5m]), "dst", "$1", "src", "(.*)"))`,
+			out:                    concatShards(3, `(label_replace(rate(foo{__query_shard__="x_of_y",bar="baz"}[5m]), "dst", "$1", "src", "(.*)"))`),
+			expectedShardedQueries: 3,
+		},
+		{
+			in:                     `rate(foo[5m]) * 2`,
+			out:                    concatShards(3, `rate(foo{__query_shard__="x_of_y"}[5m]) * 2`),
+			expectedShardedQueries: 3,
+		},
+		{
+			in:                     `max_over_time(rate(foo[1m])[5m:1m])`,
+			out:                    concatShards(3, `max_over_time(rate(foo{__query_shard__="x_of_y"}[1m])[5m:1m])`),
+			expectedShardedQueries: 3,
+		},
+		{
+			// Aggregations are sharded by the shard summer.
+			in:                     `sum(rate(foo[5m]))`,
+			out:                    `sum(` + concatShards(3, `sum(rate(foo{__query_shard__="x_of_y"}[5m]))`) + `)`,
+			expectedShardedQueries: 3,
+		},
+		{
+			// Plain selectors have nothing to compute.
+			in:                     `foo`,
+			out:                    concat(`foo`),
+			expectedShardedQueries: 0,
+		},
+		{
+			in:                     `rate(foo[5m]) / rate(bar[5m])`,
+			out:                    concat(`rate(foo[5m]) / rate(bar[5m])`),
+			expectedShardedQueries: 0,
+		},
+		{
+			in:                     `sort(rate(foo[5m]))`,
+			out:                    concat(`sort(rate(foo[5m]))`),
+			expectedShardedQueries: 0,
+		},
+		{
+			in:                     `absent(foo)`,
+			out:                    concat(`absent(foo)`),
+			expectedShardedQueries: 0,
+		},
+		{
+			in:                     `scalar(foo)`,
+			out:                    concat(`scalar(foo)`),
+			expectedShardedQueries: 0,
+		},
+		{
+			in:                     `rate(foo[5m]) > scalar(sum(bar))`,
+			out:                    concat(`rate(foo[5m]) > scalar(sum(bar))`),
+			expectedShardedQueries: 0,
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.in, func(t *testing.T) {
+			stats := NewMapperStats()
+			mapper, err := NewSharding(context.Background(), 3, true, log.NewNopLogger(), stats)
+			require.NoError(t, err)
+			expr, err := parser.ParseExpr(tt.in)
+			require.NoError(t, err)
+			out, err := parser.ParseExpr(tt.out)
+			require.NoError(t, err)
+
+			mapped, err := mapper.Map(expr)
+			require.NoError(t, err)
+			require.Equal(t, out.String(), mapped.String())
+			assert.Equal(t, tt.expectedShardedQueries, stats.GetShardedQueries())
+		})
+	}
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package astmapper
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+const (
+	// SubqueryMetricName is a reserved metric name denoting a special metric whose series are the
+	// results of a subquery spun off into a range query.
+	SubqueryMetricName = "__subquery_spinoff__"
+
+	// SubqueryQueryLabelName is a reserved label name containing the inner query of the spun off subquery.
+	SubqueryQueryLabelName = "__query__"
+
+	// SubqueryStepLabelName is a reserved label name containing the step of the spun off subquery.
+	SubqueryStepLabelName = "__step__"
+
+	// minSpinOffSubqueryRange is the minimum range of a subquery to be spun off.
+	minSpinOffSubqueryRange = time.Hour
+
+	// minSpinOffSubquerySteps is the minimum number of steps a subquery should evaluate to be spun off.
+	minSpinOffSubquerySteps = 10
+
+	// maxSpinOffSubquerySteps is the maximum number of steps a subquery can evaluate to be spun off.
+	// It matches the max number of points per timeseries supported by the range queries.
+	maxSpinOffSubquerySteps = 11000
+)
+
+type subquerySpinOffMapper struct {
+	ctx   context.Context
+	stats *SubquerySpinOffMapperStats
+}
+
+// NewSubquerySpinOffMapper creates a new ASTMapper which replaces the expensive subqueries with a special
+// matrix selector, whose series are the results of the subquery inner expression, executed as a range query.
+// The parts of the query which don't contain any spun off subquery are folded into embedded queries.
+func NewSubquerySpinOffMapper(ctx context.Context, stats *SubquerySpinOffMapperStats) ASTMapper {
+	return NewMultiMapper(
+		NewASTExprMapper(&subquerySpinOffMapper{
+			ctx:   ctx,
+			stats: stats,
+		}),
+		newSubtreeFolder(),
+	)
+}
+
+// MapExpr implements ExprMapper.
+func (m *subquerySpinOffMapper) MapExpr(expr parser.Expr) (mapped parser.Expr, finished bool, err error) {
+	if err := m.ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	e, ok := expr.(*parser.SubqueryExpr)
+	if !ok || !canSpinOffSubquery(e) {
+		return expr, false, nil
+	}
+
+	/*
+		spinning off max_over_time(rate(foo[5m])[1d:1m]) is representable as
+
+		max_over_time(__subquery_spinoff__{__query__="rate(foo[5m])",__step__="1m"}[1d])
+
+		where the series of the special matrix selector are the results of the range query
+		rate(foo[5m]) with a step of 1m, over the time range selected by the matrix selector.
+	*/
+
+	selector, err := newSubquerySpinOffSelector(e)
+	if err != nil {
+		return nil, true, err
+	}
+
+	m.stats.AddSpunOffSubqueries(1)
+	return selector, true, nil
+}
+
+// canSpinOffSubquery returns whether the subquery is expensive enough to be spun off into a range query.
+func canSpinOffSubquery(e *parser.SubqueryExpr) bool {
+	// The default step depends on the evaluation interval, which isn't known here.
+	if e.Step <= 0 || e.Range < minSpinOffSubqueryRange {
+		return false
+	}
+
+	steps := int64(e.Range / e.Step)
+	if steps < minSpinOffSubquerySteps || steps > maxSpinOffSubquerySteps {
+		return false
+	}
+
+	// There's nothing to fetch if the inner expression doesn't select any series.
+	hasVectorSelector, err := anyNode(e.Expr, isVectorSelector)
+	if err != nil || !hasVectorSelector {
+		return false
+	}
+
+	// The start() and end() of the range query differ from the ones of the original query.
+	hasStartOrEnd, err := anyNode(e.Expr, hasStartOrEndModifier)
+	return err == nil && !hasStartOrEnd
+}
+
+// hasStartOrEndModifier returns whether the node has the @ start() or @ end() modifier.
+func hasStartOrEndModifier(node parser.Node) (bool, error) {
+	switch n := node.(type) {
+	case *parser.VectorSelector:
+		return n.StartOrEnd != 0, nil
+	case *parser.SubqueryExpr:
+		return n.StartOrEnd != 0, nil
+	}
+	return false, nil
+}
+
+// newSubquerySpinOffSelector returns the matrix selector replacing the input subquery. The range,
+// the offset and the @ modifier of the subquery are kept on the matrix selector, so that the time
+// range to query is the one selected by the PromQL engine.
+func newSubquerySpinOffSelector(e *parser.SubqueryExpr) (parser.Expr, error) {
+	nameMatcher, err := labels.NewMatcher(labels.MatchEqual, labels.MetricName, SubqueryMetricName)
+	if err != nil {
+		return nil, err
+	}
+	queryMatcher, err := labels.NewMatcher(labels.MatchEqual, SubqueryQueryLabelName, e.Expr.String())
+	if err != nil {
+		return nil, err
+	}
+	stepMatcher, err := labels.NewMatcher(labels.MatchEqual, SubqueryStepLabelName, model.Duration(e.Step).String())
+	if err != nil {
+		return nil, err
+	}
+
+	return &parser.MatrixSelector{
+		VectorSelector: &parser.VectorSelector{
+			Name:           SubqueryMetricName,
+			LabelMatchers:  []*labels.Matcher{nameMatcher, queryMatcher, stepMatcher},
+			OriginalOffset: e.OriginalOffset,
+			Timestamp:      e.Timestamp,
+			StartOrEnd:     e.StartOrEnd,
+		},
+		Range: e.Range,
+	}, nil
+}
@@ -0,0 +1,21 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package astmapper
+
+type SubquerySpinOffMapperStats struct {
+	spunOffSubqueries int // counter of spun off subqueries
+}
+
+func NewSubquerySpinOffMapperStats() *SubquerySpinOffMapperStats {
+	return &SubquerySpinOffMapperStats{}
+}
+
+// AddSpunOffSubqueries add num spun off subqueries to the counter.
+func (s *SubquerySpinOffMapperStats) AddSpunOffSubqueries(num int) {
+	s.spunOffSubqueries += num
+}
+
+// GetSpunOffSubqueries returns the number of spun off subqueries.
+func (s *SubquerySpinOffMapperStats) GetSpunOffSubqueries() int {
+	return s.spunOffSubqueries
+}
@@ -0,0 +1,116 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package astmapper
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubquerySpinOffMapper(t *testing.T) {
+	for _, tt := range []struct {
+		in                        string
+		out                       string
+		expectedSpunOffSubqueries int
+	}{
+		{
+			in:                        `max_over_time(rate(foo[5m])[1d:1m])`,
+			out:                       `max_over_time(` + spinOff(`rate(foo[5m])`, "1m", "1d") + `)`,
+			expectedSpunOffSubqueries: 1,
+		},
+		{
+			in:                        `max_over_time(rate(foo[5m])[1d:1m] offset 1h)`,
+			out:                       `max_over_time(` + spinOff(`rate(foo[5m])`, "1m", "1d") + ` offset 1h)`,
+			expectedSpunOffSubqueries: 1,
+		},
+		{
+			in:                        `max_over_time(rate(foo[5m])[1d:1m] @ 1000)`,
+			out:                       `max_over_time(` + spinOff(`rate(foo[5m])`, "1m", "1d") + ` @ 1000.000)`,
+			expectedSpunOffSubqueries: 1,
+		},
+		{
+			// The parts of the query without spun off subqueries are embedded.
+			in:                        `sum by(job) (avg_over_time(up{job="foo"}[1d:5m])) / on(job) group_left count by(job) (up)`,
+			out:                       `sum by(job) (avg_over_time(` + spinOff(`up{job="foo"}`, "5m", "1d") + `)) / on(job) group_left() ` + concat(`count by(job) (up)`),
+			expectedSpunOffSubqueries: 1,
+		},
+		{
+			in:                        `max_over_time(rate(foo[5m])[1d:1m]) - min_over_time(rate(foo[5m])[2h:1m])`,
+			out:                       `max_over_time(` + spinOff(`rate(foo[5m])`, "1m", "1d") + `) - min_over_time(` + spinOff(`rate(foo[5m])`, "1m", "2h") + `)`,
+			expectedSpunOffSubqueries: 2,
+		},
+		{
+			// Only the most outer subquery is spun off.
+			in:                        `max_over_time(max_over_time(rate(foo[5m])[1h:1m])[1d:1h])`,
+			out:                       `max_over_time(` + spinOff(`max_over_time(rate(foo[5m])[1h:1m])`, "1h", "1d") + `)`,
+			expectedSpunOffSubqueries: 1,
+		},
+		{
+			// The inner subquery is spun off if the outer one is not expensive.
+			in:                        `max_over_time(max_over_time(rate(foo[5m])[1d:1m])[5m:1m])`,
+			out:                       `max_over_time(max_over_time(` + spinOff(`rate(foo[5m])`, "1m", "1d") + `)[5m:1m])`,
+			expectedSpunOffSubqueries: 1,
+		},
+		{
+			// Range too small.
+			in:                        `max_over_time(rate(foo[5m])[30m:1m])`,
+			out:                       concat(`max_over_time(rate(foo[5m])[30m:1m])`),
+			expectedSpunOffSubqueries: 0,
+		},
+		{
+			// Too few steps.
+			in:                        `max_over_time(rate(foo[5m])[1d:6h])`,
+			out:                       concat(`max_over_time(rate(foo[5m])[1d:6h])`),
+			expectedSpunOffSubqueries: 0,
+		},
+		{
+			// Too many steps.
+			in:                        `max_over_time(rate(foo[5m])[1d:1s])`,
+			out:                       concat(`max_over_time(rate(foo[5m])[1d:1s])`),
+			expectedSpunOffSubqueries: 0,
+		},
+		{
+			// No explicit step.
+			in:                        `max_over_time(rate(foo[5m])[1d:])`,
+			out:                       concat(`max_over_time(rate(foo[5m])[1d:])`),
+			expectedSpunOffSubqueries: 0,
+		},
+		{
+			// No series selected.
+			in:                        `max_over_time(vector(1)[1d:1m])`,
+			out:                       `max_over_time(vector(1)[1d:1m])`,
+			expectedSpunOffSubqueries: 0,
+		},
+		{
+			// The start() and end() of the range query differ from the ones of the original query.
+			in:                        `max_over_time(rate(foo[5m] @ end())[1d:1m])`,
+			out:                       concat(`max_over_time(rate(foo[5m] @ end())[1d:1m])`),
+			expectedSpunOffSubqueries: 0,
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.in, func(t *testing.T) {
+			stats := NewSubquerySpinOffMapperStats()
+			mapper := NewSubquerySpinOffMapper(context.Background(), stats)
+			expr, err := parser.ParseExpr(tt.in)
+			require.NoError(t, err)
+			out, err := parser.ParseExpr(tt.out)
+			require.NoError(t, err)
+
+			mapped, err := mapper.Map(expr)
+			require.NoError(t, err)
+			require.Equal(t, out.String(), mapped.String())
+			assert.Equal(t, tt.expectedSpunOffSubqueries, stats.GetSpunOffSubqueries())
+		})
+	}
+}
+
+func spinOff(query, step, rng string) string {
+	return fmt.Sprintf(`%s{%s=%q,%s=%q}[%s]`, SubqueryMetricName, SubqueryQueryLabelName, query, SubqueryStepLabelName, step, rng)
+}
@@ -45,11 +45,11 @@ func (f *subtreeFolder) MapExpr(expr parser.Expr) (mapped parser.Expr, finished
 	return expr, false, nil
 }
 
-// hasEmbeddedQueries returns whether the expr has embedded queries.
+// hasEmbeddedQueries returns whether the expr has embedded queries or spun off subqueries.
 func hasEmbeddedQueries(node parser.Node) (bool, error) {
 	switch n := node.(type) {
 	case *parser.VectorSelector:
-		if n.Name == EmbeddedQueriesMetricName {
+		if n.Name == EmbeddedQueriesMetricName || n.Name == SubqueryMetricName {
 			return true, nil
 		}
 	}
@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package astmapper
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// verticalSharder is an ASTMapper which shards by series the queries that don't contain any
+// aggregation, like rate(foo[5m]). Each shard selects a subset of the series and, since the
+// functions applied are computed on a per-series basis, the results of the shards are simply
+// concatenated in the query-frontend.
+type verticalSharder struct {
+	summer *shardSummer
+}
+
+// newVerticalSharder creates a verticalSharder which splits the input query into the
+// given number of shards, squashed together by the input squasher.
+func newVerticalSharder(ctx context.Context, shards int, squasher squasher, logger log.Logger, stats *MapperStats) ASTMapper {
+	return &verticalSharder{
+		summer: &shardSummer{
+			ctx:    ctx,
+			shards: shards,
+			squash: squasher,
+			logger: logger,
+			stats:  stats,
+		},
+	}
+}
+
+// Map implements ASTMapper. Only the most outer expression is considered: if it can't be
+// sharded vertically, the input expr is returned unaltered.
+func (s *verticalSharder) Map(expr parser.Expr) (parser.Expr, error) {
+	if err := s.summer.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if !canShardVertically(expr, s.summer.logger) {
+		return expr, nil
+	}
+
+	/*
+		sharding vertically rate(foo[5m]) is representable naively as
+
+		concat(
+		  rate(foo{__query_shard__="0_of_2"}[5m]),
+		  rate(foo{__query_shard__="1_of_2"}[5m])
+		)
+	*/
+
+	children := make([]parser.Expr, 0, s.summer.shards)
+
+	// Create sub-query for each shard.
+	for i := 0; i < s.summer.shards; i++ {
+		mapped, err := cloneAndMap(NewASTExprMapper(s.summer.CopyWithCurShard(i)), expr)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, mapped)
+	}
+
+	// Update stats.
+	s.summer.stats.AddShardedQueries(s.summer.shards)
+	return s.summer.squash(children...)
+}
+
+// canShardVertically returns whether the input expr can be sharded by series and the
+// results of the shards concatenated. This is the case of the parallelizable exprs
+// returning an instant vector which select series and don't aggregate them.
+func canShardVertically(expr parser.Expr, logger log.Logger) bool {
+	// Unwrap the parens to find out the most outer expression.
+	for {
+		paren, ok := expr.(*parser.ParenExpr)
+		if !ok {
+			break
+		}
+		expr = paren.Expr
+	}
+
+	// There's nothing to compute on a plain selector, so we don't shard it.
+	switch expr.(type) {
+	case *parser.Call, *parser.BinaryExpr:
+	default:
+		return false
+	}
+
+	if expr.Type() != parser.ValueTypeVector {
+		return false
+	}
+
+	if containsAggregateExpr(expr) || !CanParallelize(expr, logger) {
+		return false
+	}
+
+	hasVectorSelector, err := anyNode(expr, isVectorSelector)
+	return err == nil && hasVectorSelector
+}
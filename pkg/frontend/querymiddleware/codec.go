@@ -49,6 +49,10 @@ const (
 
 	totalShardsControlHeader = "Sharding-Control"
 
+	// Range query specific options
+	stepAlignControlHeader = "Step-Align-Control"
+	stepAlignDisabledValue = "disabled"
+
 	// Instant query specific options
 	instantSplitControlHeader = "Instant-Split-Control"
 )
@@ -228,6 +232,12 @@ func decodeOptions(r *http.Request, opts *Options) {
 		}
 	}
 
+	for _, value := range r.Header.Values(stepAlignControlHeader) {
+		if value == stepAlignDisabledValue {
+			opts.StepAlignDisabled = true
+		}
+	}
+
 	for _, value := range r.Header.Values(instantSplitControlHeader) {
 		splitInterval, err := time.ParseDuration(value)
 		if err != nil {
@@ -341,6 +351,13 @@ func (prometheusCodec) EncodeResponse(ctx context.Context, res Response) (*http.
 		StatusCode:    http.StatusOK,
 		ContentLength: int64(len(b)),
 	}
+
+	// Propagate the headers added to the response by the middlewares.
+	for _, h := range a.Headers {
+		if h.Name == queryClampedHeader {
+			resp.Header[h.Name] = h.Values
+		}
+	}
 	return &resp, nil
 }
 
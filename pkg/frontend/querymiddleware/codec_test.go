@@ -744,6 +744,17 @@ func Test_DecodeOptions(t *testing.T) {
 				ShardingDisabled: true,
 			},
 		},
+		{
+			name: "disable step alignment",
+			input: &http.Request{
+				Header: http.Header{
+					stepAlignControlHeader: []string{stepAlignDisabledValue},
+				},
+			},
+			expected: &Options{
+				StepAlignDisabled: true,
+			},
+		},
 		{
 			name: "custom instant query splitting",
 			input: &http.Request{
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/weaveworks/common/user"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+type estimatedSeriesCountMiddlewareMetrics struct {
+	estimationFailures prometheus.Counter
+}
+
+func newEstimatedSeriesCountMiddlewareMetrics(registerer prometheus.Registerer) *estimatedSeriesCountMiddlewareMetrics {
+	return &estimatedSeriesCountMiddlewareMetrics{
+		estimationFailures: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_query_sharding_series_count_estimation_failures_total",
+			Help: "Total number of queries whose series count the query-frontend failed to estimate. These queries are sharded with the default number of shards.",
+		}),
+	}
+}
+
+// estimatedSeriesCountMiddleware is a Middleware attaching to the request the hint with the estimated
+// number of series selected by the query, used by the query sharding to pick the number of shards.
+type estimatedSeriesCountMiddleware struct {
+	next      Handler
+	limits    Limits
+	estimator seriesCountEstimator
+	logger    log.Logger
+
+	metrics *estimatedSeriesCountMiddlewareMetrics
+}
+
+// newEstimatedSeriesCountMiddleware creates a new Middleware that estimates the series count of the
+// queries of the tenants with a target number of series per shard.
+func newEstimatedSeriesCountMiddleware(estimator seriesCountEstimator, limits Limits, logger log.Logger, metrics *estimatedSeriesCountMiddlewareMetrics) Middleware {
+	if metrics == nil {
+		metrics = newEstimatedSeriesCountMiddlewareMetrics(nil)
+	}
+
+	return MiddlewareFunc(func(next Handler) Handler {
+		return estimatedSeriesCountMiddleware{
+			next:      next,
+			limits:    limits,
+			estimator: estimator,
+			logger:    logger,
+			metrics:   metrics,
+		}
+	})
+}
+
+func (e estimatedSeriesCountMiddleware) Do(ctx context.Context, r Request) (Response, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	// The series count is not used if the request specifies the number of shards.
+	targetSeriesPerShard := validation.SmallestPositiveIntPerTenant(tenantIDs, e.limits.QueryShardingTargetSeriesPerShard)
+	if targetSeriesPerShard <= 0 || r.GetOptions().ShardingDisabled || r.GetOptions().TotalShards > 0 {
+		return e.next.Do(ctx, r)
+	}
+
+	spanLog, ctx := spanlogger.NewWithLogger(ctx, e.logger, "estimatedSeriesCountMiddleware.Do")
+	defer spanLog.Finish()
+
+	count, err := e.estimateSeriesCount(ctx, tenantIDs, r)
+	if err != nil {
+		// The estimation is best-effort: the query is sharded with the default number of shards if it fails.
+		e.metrics.estimationFailures.Inc()
+		level.Warn(spanLog).Log("msg", "failed to estimate the query series count, the query will be sharded with the default number of shards", "query", r.GetQuery(), "err", err)
+		return e.next.Do(ctx, r)
+	}
+
+	level.Debug(spanLog).Log("msg", "estimated the query series count", "query", r.GetQuery(), "series", count)
+
+	hints := &Hints{EstimatedSeriesCount: count}
+	if r.GetHints() != nil {
+		hints.TotalQueries = r.GetHints().TotalQueries
+	}
+	return e.next.Do(ctx, r.WithHints(hints))
+}
+
+// estimateSeriesCount returns the estimated number of series selected by the query of r, summing
+// the estimations of all the distinct selectors of the query and of all tenants.
+func (e estimatedSeriesCountMiddleware) estimateSeriesCount(ctx context.Context, tenantIDs []string, r Request) (uint64, error) {
+	expr, err := parser.ParseExpr(r.GetQuery())
+	if err != nil {
+		return 0, err
+	}
+
+	path := ""
+	if p, ok := r.(interface{ GetPath() string }); ok {
+		path = p.GetPath()
+	}
+
+	var count uint64
+	estimated := map[string]struct{}{}
+	for _, selector := range parser.ExtractSelectors(expr) {
+		key := matchersString(selector)
+		if _, ok := estimated[key]; ok {
+			continue
+		}
+		estimated[key] = struct{}{}
+
+		for _, tenantID := range tenantIDs {
+			tenantCount, err := e.estimator.EstimateSeriesCount(user.InjectOrgID(ctx, tenantID), path, selector)
+			if err != nil {
+				return 0, errors.Wrapf(err, "estimate the series count of {%s}", key)
+			}
+			count += tenantCount
+		}
+	}
+	return count, nil
+}
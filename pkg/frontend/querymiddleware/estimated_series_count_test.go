@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+func TestEstimatedSeriesCountMiddleware(t *testing.T) {
+	// Set a multi tenant resolver, restoring the default one at the end of the test.
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	for name, test := range map[string]struct {
+		orgID                string
+		query                string
+		options              Options
+		hints                *Hints
+		targetSeriesPerShard int
+		estimatorErr         error
+		expectedHints        *Hints
+		expectedFailures     int
+		expectedCalls        int
+	}{
+		"should not estimate the series count if the target series per shard is disabled": {
+			orgID: "tenant-1",
+			query: `sum(rate(http_requests_total[5m]))`,
+		},
+		"should attach the estimated series count to the request": {
+			orgID:                "tenant-1",
+			query:                `sum(rate(http_requests_total[5m]))`,
+			targetSeriesPerShard: 10,
+			expectedHints:        &Hints{EstimatedSeriesCount: 100},
+			expectedCalls:        1,
+		},
+		"should preserve the hints of the request": {
+			orgID:                "tenant-1",
+			query:                `sum(rate(http_requests_total[5m]))`,
+			hints:                &Hints{TotalQueries: 3},
+			targetSeriesPerShard: 10,
+			expectedHints:        &Hints{TotalQueries: 3, EstimatedSeriesCount: 100},
+			expectedCalls:        1,
+		},
+		"should estimate the same selector only once": {
+			orgID:                "tenant-1",
+			query:                `rate(http_requests_total[5m]) / rate(http_requests_total[1m]) + sum(up)`,
+			targetSeriesPerShard: 10,
+			expectedHints:        &Hints{EstimatedSeriesCount: 200},
+			expectedCalls:        2,
+		},
+		"should sum the series count of all tenants": {
+			orgID:                "tenant-1|tenant-2",
+			query:                `sum(rate(http_requests_total[5m]))`,
+			targetSeriesPerShard: 10,
+			expectedHints:        &Hints{EstimatedSeriesCount: 200},
+			expectedCalls:        2,
+		},
+		"should not estimate the series count if the request specifies the number of shards": {
+			orgID:                "tenant-1",
+			query:                `sum(rate(http_requests_total[5m]))`,
+			options:              Options{TotalShards: 4},
+			targetSeriesPerShard: 10,
+		},
+		"should not estimate the series count if the sharding is disabled for the request": {
+			orgID:                "tenant-1",
+			query:                `sum(rate(http_requests_total[5m]))`,
+			options:              Options{ShardingDisabled: true},
+			targetSeriesPerShard: 10,
+		},
+		"should execute the query if the series count can't be estimated": {
+			orgID:                "tenant-1",
+			query:                `sum(rate(http_requests_total[5m]))`,
+			targetSeriesPerShard: 10,
+			estimatorErr:         errors.New("cardinality analysis is disabled"),
+			expectedFailures:     1,
+			expectedCalls:        1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			estimator := &mockSeriesCountEstimator{seriesCount: 100, err: test.estimatorErr}
+			metrics := newEstimatedSeriesCountMiddlewareMetrics(prometheus.NewPedanticRegistry())
+			limits := mockLimits{targetSeriesPerShard: test.targetSeriesPerShard}
+
+			var downstreamReq Request
+			downstream := HandlerFunc(func(_ context.Context, r Request) (Response, error) {
+				downstreamReq = r
+				return &PrometheusResponse{Status: statusSuccess}, nil
+			})
+
+			req := &PrometheusInstantQueryRequest{
+				Path:    "/api/v1/query",
+				Time:    util.TimeToMillis(time.Now()),
+				Query:   test.query,
+				Options: test.options,
+				Hints:   test.hints,
+			}
+			ctx := user.InjectOrgID(context.Background(), test.orgID)
+
+			mw := newEstimatedSeriesCountMiddleware(estimator, limits, log.NewNopLogger(), metrics)
+			_, err := mw.Wrap(downstream).Do(ctx, req)
+			require.NoError(t, err)
+
+			require.NotNil(t, downstreamReq)
+			expectedHints := test.expectedHints
+			if expectedHints == nil {
+				expectedHints = test.hints
+			}
+			assert.Equal(t, expectedHints, downstreamReq.GetHints())
+			assert.Equal(t, test.expectedCalls, estimator.callsCount())
+			assert.Equal(t, float64(test.expectedFailures), testutil.ToFloat64(metrics.estimationFailures))
+		})
+	}
+}
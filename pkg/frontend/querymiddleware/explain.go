@@ -0,0 +1,422 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware/astmapper"
+	"github.com/grafana/mimir/pkg/util"
+	util_math "github.com/grafana/mimir/pkg/util/math"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const queryExplainPathSuffix = "/query_explain"
+
+// queryExplainResponse is the response of the query explain endpoint.
+type queryExplainResponse struct {
+	Status string            `json:"status"`
+	Data   *queryExplanation `json:"data"`
+}
+
+// queryExplanation describes how the query-frontend would execute a query, without executing it.
+type queryExplanation struct {
+	Query     string                  `json:"query"`
+	QueryType string                  `json:"queryType"`
+	Limits    explainLimits           `json:"limits"`
+	StepAlign *explainStepAlign       `json:"stepAlign,omitempty"`
+	Split     *explainSplit           `json:"split,omitempty"`
+	Cache     *explainCache           `json:"cache,omitempty"`
+	SpinOff   *explainSubquerySpinOff `json:"subquerySpinOff,omitempty"`
+	Sharding  *explainSharding        `json:"sharding,omitempty"`
+}
+
+type explainTimeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func newExplainTimeRange(start, end int64) explainTimeRange {
+	return explainTimeRange{Start: util.TimeFromMillis(start).UTC(), End: util.TimeFromMillis(end).UTC()}
+}
+
+// explainLimits describes the per-tenant limits applied to the query.
+type explainLimits struct {
+	// TimeRange is the time range of the query after the max lookback, the creation grace period and the max query
+	// into future are enforced.
+	TimeRange           explainTimeRange `json:"timeRange"`
+	StartClamped        bool             `json:"startClamped"`
+	EndClamped          bool             `json:"endClamped"`
+	MaxQueryLookback    model.Duration   `json:"maxQueryLookback"`
+	MaxQueryIntoFuture  model.Duration   `json:"maxQueryIntoFuture"`
+	MaxTotalQueryLength model.Duration   `json:"maxTotalQueryLength"`
+	MaxQueryParallelism int              `json:"maxQueryParallelism"`
+	// Skipped is true when the query is fully outside the allowed time range, and an empty result is returned.
+	Skipped bool `json:"skipped"`
+	// Rejected is the error the query is rejected with, if any.
+	Rejected string `json:"rejected,omitempty"`
+}
+
+type explainStepAlign struct {
+	Aligned   bool             `json:"aligned"`
+	TimeRange explainTimeRange `json:"timeRange"`
+}
+
+type explainSplit struct {
+	Interval model.Duration `json:"interval"`
+	// Error is the error the query failed to be split with, in which case it's executed without splitting.
+	Error string `json:"error,omitempty"`
+	// Queries are the time ranges of the split range queries.
+	Queries []explainTimeRange `json:"queries,omitempty"`
+	// SplitQueries is the number of partial queries an instant query is split into.
+	SplitQueries   int    `json:"splitQueries,omitempty"`
+	RewrittenQuery string `json:"rewrittenQuery,omitempty"`
+}
+
+type explainCache struct {
+	// CachedRanges are the time ranges whose results are currently in the results cache.
+	CachedRanges []explainTimeRange `json:"cachedRanges"`
+	// NotCachableReason is the reason why the query results can't be cached, if any.
+	NotCachableReason string `json:"notCachableReason,omitempty"`
+}
+
+type explainSubquerySpinOff struct {
+	SpunOffSubqueries int    `json:"spunOffSubqueries"`
+	RewrittenQuery    string `json:"rewrittenQuery,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+type explainSharding struct {
+	TotalShards    int    `json:"totalShards"`
+	ShardedQueries int    `json:"shardedQueries"`
+	RewrittenQuery string `json:"rewrittenQuery,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// queryExplainRoundTripper is a http.RoundTripper serving the query explain endpoint. It runs the
+// planning steps of the query middlewares (limits, step alignment, splitting, results cache lookup,
+// subquery spin-off and sharding) without executing the query.
+type queryExplainRoundTripper struct {
+	cfg    Config
+	limits Limits
+	cache  cache.Cache
+	logger log.Logger
+	now    func() time.Time
+}
+
+func newQueryExplainRoundTripper(cfg Config, limits Limits, c cache.Cache, logger log.Logger) http.RoundTripper {
+	return &queryExplainRoundTripper{
+		cfg:    cfg,
+		limits: limits,
+		cache:  c,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+func (e *queryExplainRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	ctx := r.Context()
+
+	req, err := e.decodeRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := parser.ParseExpr(req.GetQuery()); err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	explanation, err := e.explain(ctx, tenantIDs, req)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(&queryExplainResponse{Status: statusSuccess, Data: explanation})
+	if err != nil {
+		return nil, apierror.Newf(apierror.TypeInternal, "error encoding response: %v", err)
+	}
+
+	return &http.Response{
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+		},
+		Body:          io.NopCloser(bytes.NewBuffer(b)),
+		StatusCode:    http.StatusOK,
+		ContentLength: int64(len(b)),
+	}, nil
+}
+
+// decodeRequest decodes the query to explain as a range query if the step is specified,
+// or as an instant query otherwise.
+func (e *queryExplainRoundTripper) decodeRequest(r *http.Request) (Request, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	if r.Form.Get("step") != "" {
+		return prometheusCodec{}.decodeRangeQueryRequest(r)
+	}
+
+	if r.Form.Get("time") == "" {
+		r.Form.Set("time", strconv.FormatInt(e.now().Unix(), 10))
+	}
+	return prometheusCodec{}.decodeInstantQueryRequest(r)
+}
+
+func (e *queryExplainRoundTripper) explain(ctx context.Context, tenantIDs []string, req Request) (*queryExplanation, error) {
+	explanation := &queryExplanation{Query: req.GetQuery()}
+	_, isRange := req.(*PrometheusRangeQueryRequest)
+	if isRange {
+		explanation.QueryType = "range"
+	} else {
+		explanation.QueryType = "instant"
+	}
+
+	// The limits are enforced by running the limits middleware, capturing the request it sends downstream.
+	var limited Request
+	_, err := newLimitsMiddleware(e.limits, e.logger).Wrap(HandlerFunc(func(_ context.Context, r Request) (Response, error) {
+		limited = r
+		return nil, nil
+	})).Do(ctx, req)
+
+	explanation.Limits = explainLimits{
+		TimeRange:           newExplainTimeRange(req.GetStart(), req.GetEnd()),
+		MaxQueryLookback:    model.Duration(validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, e.limits.MaxQueryLookback)),
+		MaxQueryIntoFuture:  model.Duration(validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, e.limits.MaxQueryIntoFuture)),
+		MaxTotalQueryLength: model.Duration(validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, e.limits.MaxTotalQueryLength)),
+		MaxQueryParallelism: validation.SmallestPositiveIntPerTenant(tenantIDs, e.limits.MaxQueryParallelism),
+	}
+	switch {
+	case err != nil:
+		explanation.Limits.Rejected = err.Error()
+		return explanation, nil
+	case limited == nil:
+		explanation.Limits.Skipped = true
+		return explanation, nil
+	}
+	explanation.Limits.TimeRange = newExplainTimeRange(limited.GetStart(), limited.GetEnd())
+	explanation.Limits.StartClamped = limited.GetStart() != req.GetStart()
+	explanation.Limits.EndClamped = limited.GetEnd() != req.GetEnd()
+	req = limited
+
+	if isRange {
+		req = e.explainStepAlign(ctx, req, explanation)
+		splitReqs, err := e.explainRangeSplit(req, explanation)
+		if err != nil {
+			return nil, err
+		}
+		e.explainRangeCache(ctx, tenantIDs, splitReqs, explanation)
+	} else {
+		e.explainInstantCache(ctx, tenantIDs, req, explanation)
+		e.explainSubquerySpinOff(ctx, req, explanation)
+		e.explainInstantSplit(ctx, tenantIDs, req, explanation)
+	}
+
+	e.explainSharding(ctx, tenantIDs, req, explanation)
+	return explanation, nil
+}
+
+// explainStepAlign returns the request aligned to its step, if the step alignment is enabled.
+func (e *queryExplainRoundTripper) explainStepAlign(ctx context.Context, req Request, explanation *queryExplanation) Request {
+	aligned := req
+	_, _ = newStepAlignMiddleware(e.limits, e.cfg.AlignQueriesWithStep, nil).Wrap(HandlerFunc(func(_ context.Context, r Request) (Response, error) {
+		aligned = r
+		return nil, nil
+	})).Do(ctx, req)
+
+	explanation.StepAlign = &explainStepAlign{
+		Aligned:   isRequestStepAligned(aligned),
+		TimeRange: newExplainTimeRange(aligned.GetStart(), aligned.GetEnd()),
+	}
+	return aligned
+}
+
+func (e *queryExplainRoundTripper) explainRangeSplit(req Request, explanation *queryExplanation) ([]Request, error) {
+	if e.cfg.SplitQueriesByInterval <= 0 {
+		return []Request{req}, nil
+	}
+
+	splitReqs, err := splitQueryByInterval(req, e.cfg.SplitQueriesByInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	explanation.Split = &explainSplit{Interval: model.Duration(e.cfg.SplitQueriesByInterval)}
+	for _, splitReq := range splitReqs {
+		explanation.Split.Queries = append(explanation.Split.Queries, newExplainTimeRange(splitReq.GetStart(), splitReq.GetEnd()))
+	}
+	return splitReqs, nil
+}
+
+// explainRangeCache looks up the results cache for the extents of the split queries, without updating it.
+func (e *queryExplainRoundTripper) explainRangeCache(ctx context.Context, tenantIDs []string, splitReqs []Request, explanation *queryExplanation) {
+	if !e.cfg.CacheResults || e.cache == nil {
+		return
+	}
+
+	explanation.Cache = &explainCache{CachedRanges: []explainTimeRange{}}
+	if !resultsCacheEnabledByOption(splitReqs[0]) {
+		explanation.Cache.NotCachableReason = "disabled-by-option"
+		return
+	}
+
+	splitter := e.cfg.CacheSplitter
+	if splitter == nil {
+		splitter = ConstSplitter(e.cfg.SplitQueriesByInterval)
+	}
+	maxCacheTime := int64(model.Now().Add(-validation.MaxDurationPerTenant(tenantIDs, e.limits.MaxCacheFreshness)))
+
+	var (
+		lookupReqs []Request
+		lookupKeys []string
+	)
+	for _, splitReq := range splitReqs {
+		if cachable, reason := isRequestCachable(splitReq, maxCacheTime, e.cfg.CacheUnalignedRequests, e.logger); !cachable {
+			explanation.Cache.NotCachableReason = reason
+			continue
+		}
+		lookupReqs = append(lookupReqs, splitReq)
+		lookupKeys = append(lookupKeys, splitter.GenerateCacheKey(ctx, tenant.JoinTenantIDs(tenantIDs), splitReq))
+	}
+
+	fetcher := &splitAndCacheMiddleware{cache: e.cache, logger: e.logger}
+	for idx, extents := range fetcher.fetchCacheExtents(ctx, lookupKeys) {
+		for _, extent := range extents {
+			start := util_math.Max64(extent.Start, lookupReqs[idx].GetStart())
+			end := util_math.Min64(extent.End, lookupReqs[idx].GetEnd())
+			if start <= end {
+				explanation.Cache.CachedRanges = append(explanation.Cache.CachedRanges, newExplainTimeRange(start, end))
+			}
+		}
+	}
+}
+
+// explainInstantCache looks up the results cache for the instant query results, without updating it.
+func (e *queryExplainRoundTripper) explainInstantCache(ctx context.Context, tenantIDs []string, req Request, explanation *queryExplanation) {
+	if !e.cfg.CacheResults || !e.cfg.CacheInstantQueries || e.cache == nil {
+		return
+	}
+
+	explanation.Cache = &explainCache{CachedRanges: []explainTimeRange{}}
+	if !resultsCacheEnabledByOption(req) {
+		explanation.Cache.NotCachableReason = "disabled-by-option"
+		return
+	}
+
+	maxCacheTime := int64(model.Now().Add(-validation.MaxDurationPerTenant(tenantIDs, e.limits.MaxCacheFreshness)))
+	if cachable, reason := isRequestCachable(req, maxCacheTime, true, e.logger); !cachable {
+		explanation.Cache.NotCachableReason = reason
+		return
+	}
+
+	fetcher := &instantQueryCacheMiddleware{cache: e.cache, logger: e.logger}
+	if fetcher.fetchCachedResponse(ctx, generateInstantQueryCacheKey(tenant.JoinTenantIDs(tenantIDs), req)) != nil {
+		explanation.Cache.CachedRanges = append(explanation.Cache.CachedRanges, newExplainTimeRange(req.GetStart(), req.GetEnd()))
+	}
+}
+
+func (e *queryExplainRoundTripper) explainSubquerySpinOff(ctx context.Context, req Request, explanation *queryExplanation) {
+	if !e.cfg.SpinOffSubqueries {
+		return
+	}
+
+	mapperStats := astmapper.NewSubquerySpinOffMapperStats()
+	mapperCtx, cancel := context.WithTimeout(ctx, shardingTimeout)
+	defer cancel()
+
+	explanation.SpinOff = &explainSubquerySpinOff{}
+	rewritten, err := mapQuery(req.GetQuery(), astmapper.NewSubquerySpinOffMapper(mapperCtx, mapperStats))
+	if err != nil {
+		explanation.SpinOff.Error = err.Error()
+		return
+	}
+
+	explanation.SpinOff.SpunOffSubqueries = mapperStats.GetSpunOffSubqueries()
+	if mapperStats.GetSpunOffSubqueries() > 0 {
+		explanation.SpinOff.RewrittenQuery = rewritten
+	}
+}
+
+func (e *queryExplainRoundTripper) explainInstantSplit(ctx context.Context, tenantIDs []string, req Request, explanation *queryExplanation) {
+	splitter := &splitInstantQueryByIntervalMiddleware{limits: e.limits}
+	splitInterval := splitter.getSplitIntervalForQuery(tenantIDs, req, e.logger)
+	if splitInterval <= 0 {
+		return
+	}
+
+	mapperStats := astmapper.NewInstantSplitterStats()
+	mapperCtx, cancel := context.WithTimeout(ctx, shardingTimeout)
+	defer cancel()
+
+	explanation.Split = &explainSplit{Interval: model.Duration(splitInterval)}
+	rewritten, err := mapQuery(req.GetQuery(), astmapper.NewInstantQuerySplitter(mapperCtx, splitInterval, e.logger, mapperStats))
+	if err != nil {
+		explanation.Split.Error = err.Error()
+		return
+	}
+
+	explanation.Split.SplitQueries = mapperStats.GetSplitQueries()
+	if mapperStats.GetSplitQueries() > 0 {
+		explanation.Split.RewrittenQuery = rewritten
+	}
+}
+
+func (e *queryExplainRoundTripper) explainSharding(ctx context.Context, tenantIDs []string, req Request, explanation *queryExplanation) {
+	if !e.cfg.ShardedQueries {
+		return
+	}
+
+	sharding := &querySharding{limit: e.limits, logger: e.logger}
+	totalShards := sharding.getShardsForQuery(ctx, tenantIDs, req, e.logger)
+	explanation.Sharding = &explainSharding{TotalShards: totalShards}
+	if totalShards <= 1 {
+		return
+	}
+
+	rewritten, stats, err := sharding.shardQuery(ctx, req.GetQuery(), totalShards, sharding.isVerticalShardingEnabled(tenantIDs))
+	if err != nil {
+		explanation.Sharding.Error = err.Error()
+		return
+	}
+
+	explanation.Sharding.ShardedQueries = stats.GetShardedQueries()
+	if stats.GetShardedQueries() > 0 {
+		explanation.Sharding.RewrittenQuery = rewritten
+	}
+}
+
+// mapQuery parses the query and returns it rewritten by the mapper.
+func mapQuery(query string, mapper astmapper.ASTMapper) (string, error) {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return "", apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	mapped, err := mapper.Map(expr)
+	if err != nil {
+		return "", err
+	}
+	return mapped.String(), nil
+}
+
+func isQueryExplain(path string) bool {
+	return strings.HasSuffix(path, queryExplainPathSuffix)
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+func TestQueryExplain_RangeQuery(t *testing.T) {
+	now := time.Now()
+	start := now.Add(-3 * 24 * time.Hour).Truncate(24 * time.Hour).Add(30 * time.Second)
+	end := now.Add(-24 * time.Hour).Truncate(24 * time.Hour).Add(-time.Hour)
+
+	cfg := Config{
+		SplitQueriesByInterval: 24 * time.Hour,
+		CacheResults:           true,
+		ShardedQueries:         true,
+		AlignQueriesWithStep:   true,
+	}
+	limits := mockLimits{totalShards: 4, maxQueryLookback: 7 * 24 * time.Hour}
+
+	// Store the results of the first split query in the cache.
+	c := cache.NewMockCache()
+	firstSplitStart := start.Truncate(time.Minute)
+	firstSplitEnd := firstSplitStart.Truncate(24 * time.Hour).Add(24*time.Hour - time.Minute)
+	firstSplitReq := &PrometheusRangeQueryRequest{Start: util.TimeToMillis(firstSplitStart), End: util.TimeToMillis(firstSplitEnd), Step: 60000, Query: "sum(rate(foo[1m]))"}
+	key := ConstSplitter(cfg.SplitQueriesByInterval).GenerateCacheKey(context.Background(), "user-1", firstSplitReq)
+	data, err := proto.Marshal(&CachedResponse{Key: key, Extents: []Extent{{Start: firstSplitReq.Start, End: firstSplitReq.End}}})
+	require.NoError(t, err)
+	c.Store(context.Background(), map[string][]byte{cacheHashKey(key): data}, time.Hour)
+
+	explanation := runQueryExplain(t, cfg, limits, c, url.Values{
+		"query": []string{"sum(rate(foo[1m]))"},
+		"start": []string{encodeTime(util.TimeToMillis(start))},
+		"end":   []string{encodeTime(util.TimeToMillis(end))},
+		"step":  []string{"60"},
+	})
+
+	assert.Equal(t, "range", explanation.QueryType)
+	assert.False(t, explanation.Limits.StartClamped)
+	assert.False(t, explanation.Limits.Skipped)
+
+	require.NotNil(t, explanation.StepAlign)
+	assert.True(t, explanation.StepAlign.Aligned)
+	assert.Equal(t, firstSplitStart.UTC(), explanation.StepAlign.TimeRange.Start)
+
+	require.NotNil(t, explanation.Split)
+	require.Len(t, explanation.Split.Queries, 2)
+	assert.Equal(t, newExplainTimeRange(firstSplitReq.Start, firstSplitReq.End), explanation.Split.Queries[0])
+
+	require.NotNil(t, explanation.Cache)
+	assert.Equal(t, []explainTimeRange{newExplainTimeRange(firstSplitReq.Start, firstSplitReq.End)}, explanation.Cache.CachedRanges)
+
+	require.NotNil(t, explanation.Sharding)
+	assert.Equal(t, 4, explanation.Sharding.TotalShards)
+	assert.Equal(t, 4, explanation.Sharding.ShardedQueries)
+	assert.NotEmpty(t, explanation.Sharding.RewrittenQuery)
+
+	assert.Nil(t, explanation.SpinOff)
+}
+
+func TestQueryExplain_InstantQuery(t *testing.T) {
+	cfg := Config{SpinOffSubqueries: true, ShardedQueries: true}
+	limits := mockLimits{totalShards: 1, splitInstantQueriesInterval: time.Hour}
+
+	explanation := runQueryExplain(t, cfg, limits, nil, url.Values{
+		"query": []string{"max_over_time(rate(foo[5m])[1d:1m])"},
+		"time":  []string{encodeTime(util.TimeToMillis(time.Now()))},
+	})
+
+	assert.Equal(t, "instant", explanation.QueryType)
+	assert.Nil(t, explanation.StepAlign)
+	assert.Nil(t, explanation.Cache)
+
+	require.NotNil(t, explanation.SpinOff)
+	assert.Equal(t, 1, explanation.SpinOff.SpunOffSubqueries)
+	assert.Contains(t, explanation.SpinOff.RewrittenQuery, "__subquery_spinoff__")
+
+	// Subqueries are not split by the instant query splitting.
+	require.NotNil(t, explanation.Split)
+	assert.Equal(t, 0, explanation.Split.SplitQueries)
+
+	require.NotNil(t, explanation.Sharding)
+	assert.Equal(t, 1, explanation.Sharding.TotalShards)
+	assert.Empty(t, explanation.Sharding.RewrittenQuery)
+}
+
+func TestQueryExplain_Limits(t *testing.T) {
+	now := time.Now()
+
+	t.Run("should clamp the start to the max query lookback", func(t *testing.T) {
+		explanation := runQueryExplain(t, Config{}, mockLimits{maxQueryLookback: 24 * time.Hour, compactorBlocksRetentionPeriod: 24 * time.Hour}, nil, url.Values{
+			"query": []string{"up"},
+			"start": []string{encodeTime(util.TimeToMillis(now.Add(-48 * time.Hour)))},
+			"end":   []string{encodeTime(util.TimeToMillis(now))},
+			"step":  []string{"60"},
+		})
+		assert.True(t, explanation.Limits.StartClamped)
+		assert.False(t, explanation.Limits.EndClamped)
+		assert.True(t, explanation.Limits.TimeRange.Start.After(now.Add(-25*time.Hour)))
+	})
+
+	t.Run("should skip queries before the max query lookback", func(t *testing.T) {
+		explanation := runQueryExplain(t, Config{}, mockLimits{maxQueryLookback: 24 * time.Hour, compactorBlocksRetentionPeriod: 24 * time.Hour}, nil, url.Values{
+			"query": []string{"up"},
+			"start": []string{encodeTime(util.TimeToMillis(now.Add(-72 * time.Hour)))},
+			"end":   []string{encodeTime(util.TimeToMillis(now.Add(-48 * time.Hour)))},
+			"step":  []string{"60"},
+		})
+		assert.True(t, explanation.Limits.Skipped)
+		assert.Nil(t, explanation.StepAlign)
+	})
+
+	t.Run("should report queries rejected because of the max query length", func(t *testing.T) {
+		explanation := runQueryExplain(t, Config{}, mockLimits{maxQueryLength: time.Hour}, nil, url.Values{
+			"query": []string{"up"},
+			"start": []string{encodeTime(util.TimeToMillis(now.Add(-2 * time.Hour)))},
+			"end":   []string{encodeTime(util.TimeToMillis(now))},
+			"step":  []string{"60"},
+		})
+		assert.Contains(t, explanation.Limits.Rejected, "the total query time range exceeds the limit")
+	})
+}
+
+func TestQueryExplain_InvalidQuery(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query_explain?query=sum(", nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+
+	_, err := newQueryExplainRoundTripper(Config{}, mockLimits{}, nil, log.NewNopLogger()).RoundTrip(req)
+	require.Error(t, err)
+	assert.True(t, apierror.IsAPIError(err))
+}
+
+func runQueryExplain(t *testing.T, cfg Config, limits Limits, c cache.Cache, params url.Values) *queryExplanation {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/query_explain?"+params.Encode(), nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+
+	resp, err := newQueryExplainRoundTripper(cfg, limits, c, log.NewNopLogger()).RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	var decoded queryExplainResponse
+	require.NoError(t, json.Unmarshal(body, &decoded))
+	require.Equal(t, statusSuccess, decoded.Status)
+	require.NotNil(t, decoded.Data)
+	return decoded.Data
+}
@@ -0,0 +1,192 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/dskit/tenant"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+type instantQueryCacheMiddlewareMetrics struct {
+	queryResultCacheAttemptedCount prometheus.Counter
+	queryResultCacheHitsCount      prometheus.Counter
+	queryResultCacheSkippedCount   *prometheus.CounterVec
+}
+
+func newInstantQueryCacheMiddlewareMetrics(reg prometheus.Registerer) *instantQueryCacheMiddlewareMetrics {
+	m := &instantQueryCacheMiddlewareMetrics{
+		queryResultCacheAttemptedCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_instant_query_result_cache_attempted_total",
+			Help: "Total number of instant queries that were attempted to be fetched from cache.",
+		}),
+		queryResultCacheHitsCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_instant_query_result_cache_hits_total",
+			Help: "Total number of instant queries whose results have been fetched from cache.",
+		}),
+		queryResultCacheSkippedCount: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_frontend_instant_query_result_cache_skipped_total",
+			Help: "Total number of times an instant query was not cacheable because of a reason.",
+		}, []string{"reason"}),
+	}
+
+	// Initialize known label values.
+	for _, reason := range []string{notCachableReasonTooNew, notCachableReasonModifiersNotCachable, notCachableReasonTooLarge} {
+		m.queryResultCacheSkippedCount.WithLabelValues(reason)
+	}
+
+	return m
+}
+
+// instantQueryCacheMiddleware is a Middleware running the instant queries through the results cache.
+// Only the instant queries whose evaluation time is older than the max cache freshness are cached.
+type instantQueryCacheMiddleware struct {
+	next           Handler
+	limits         Limits
+	cache          cache.Cache
+	extractor      Extractor
+	shouldCacheReq shouldCacheFn
+	logger         log.Logger
+	metrics        *instantQueryCacheMiddlewareMetrics
+}
+
+// newInstantQueryCacheMiddleware makes a new instantQueryCacheMiddleware.
+func newInstantQueryCacheMiddleware(
+	limits Limits,
+	cache cache.Cache,
+	extractor Extractor,
+	shouldCacheReq shouldCacheFn,
+	logger log.Logger,
+	reg prometheus.Registerer,
+) Middleware {
+	metrics := newInstantQueryCacheMiddlewareMetrics(reg)
+
+	return MiddlewareFunc(func(next Handler) Handler {
+		return &instantQueryCacheMiddleware{
+			next:           next,
+			limits:         limits,
+			cache:          cache,
+			extractor:      extractor,
+			shouldCacheReq: shouldCacheReq,
+			logger:         logger,
+			metrics:        metrics,
+		}
+	})
+}
+
+func (c *instantQueryCacheMiddleware) Do(ctx context.Context, req Request) (Response, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	if c.shouldCacheReq != nil && !c.shouldCacheReq(req) {
+		return c.next.Do(ctx, req)
+	}
+
+	c.metrics.queryResultCacheAttemptedCount.Inc()
+
+	// The step alignment doesn't apply to instant queries.
+	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, c.limits.MaxCacheFreshness)
+	maxCacheTime := int64(model.Now().Add(-maxCacheFreshness))
+	if cachable, reason := isRequestCachable(req, maxCacheTime, true, c.logger); !cachable {
+		c.metrics.queryResultCacheSkippedCount.WithLabelValues(reason).Inc()
+		return c.next.Do(ctx, req)
+	}
+
+	key := generateInstantQueryCacheKey(tenant.JoinTenantIDs(tenantIDs), req)
+	if cached := c.fetchCachedResponse(ctx, key); cached != nil {
+		c.metrics.queryResultCacheHitsCount.Inc()
+		return cached, nil
+	}
+
+	resp, err := c.next.Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if isResponseCachable(resp, c.logger) {
+		c.storeCachedResponse(ctx, key, tenantIDs, req, resp)
+	}
+
+	return resp, nil
+}
+
+// fetchCachedResponse returns the response cached for the input key, or nil in case of error or cache miss.
+func (c *instantQueryCacheMiddleware) fetchCachedResponse(ctx context.Context, key string) Response {
+	spanLog, ctx := spanlogger.NewWithLogger(ctx, c.logger, "fetchCachedInstantQueryResponse")
+	defer spanLog.Finish()
+
+	hashedKey := cacheHashKey(key)
+	spanLog.LogKV("key", key, "hashedKey", hashedKey)
+
+	data, ok := c.cache.Fetch(ctx, []string{hashedKey})[hashedKey]
+	if !ok {
+		return nil
+	}
+
+	var cached CachedResponse
+	if err := proto.Unmarshal(data, &cached); err != nil {
+		level.Error(spanLog).Log("msg", "error unmarshalling cached instant query response", "err", err)
+		return nil
+	}
+
+	// Ensure there's no hashed key collision.
+	if cached.Key != key || len(cached.Extents) != 1 {
+		return nil
+	}
+
+	resp, err := cached.Extents[0].toResponse()
+	if err != nil {
+		level.Error(spanLog).Log("msg", "error decoding cached instant query response", "err", err)
+		return nil
+	}
+
+	spanLog.LogKV("returned bytes", len(data))
+	return resp
+}
+
+// storeCachedResponse stores the response of the input request in the cache.
+func (c *instantQueryCacheMiddleware) storeCachedResponse(ctx context.Context, key string, tenantIDs []string, req Request, resp Response) {
+	extent, err := toExtent(ctx, req, c.extractor.ResponseWithoutHeaders(resp))
+	if err != nil {
+		level.Error(c.logger).Log("msg", "error converting instant query response to extent", "err", err)
+		return
+	}
+
+	data, err := proto.Marshal(&CachedResponse{
+		Key:     key,
+		Extents: []Extent{extent},
+	})
+	if err != nil {
+		level.Error(c.logger).Log("msg", "error marshalling cached instant query response", "err", err)
+		return
+	}
+
+	if maxSize := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, c.limits.ResultsCacheMaxItemSizeBytes); maxSize > 0 && len(data) > maxSize {
+		level.Debug(c.logger).Log("msg", "skipped caching the instant query results because they exceed the results cache max item size", "size", len(data), "maxSize", maxSize)
+		c.metrics.queryResultCacheSkippedCount.WithLabelValues(notCachableReasonTooLarge).Inc()
+		return
+	}
+
+	c.cache.Store(ctx, map[string][]byte{cacheHashKey(key): data}, resultsCacheTTL(c.limits, tenantIDs, req.GetEnd()))
+}
+
+// generateInstantQueryCacheKey generates the cache key of an instant query, based on the userID,
+// the query and the evaluation time. The key is prefixed to not clash with the range queries ones.
+func generateInstantQueryCacheKey(userID string, r Request) string {
+	return fmt.Sprintf("qi:%s:%s:%d", userID, r.GetQuery(), r.GetStart())
+}
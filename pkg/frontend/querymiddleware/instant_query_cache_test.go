@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+func TestInstantQueryCacheMiddleware(t *testing.T) {
+	now := time.Now()
+
+	downstreamResponse := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: model.ValVector.String(),
+			Result: []SampleStream{{
+				Labels:  []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}},
+				Samples: []mimirpb.Sample{{Value: 137, TimestampMs: now.Add(-time.Hour).UnixMilli()}},
+			}},
+		},
+	}
+
+	tests := map[string]struct {
+		request             Request
+		limits              mockLimits
+		downstreamResponse  *PrometheusResponse
+		expectedDownstreams int
+		expectedMetrics     string
+	}{
+		"should cache the results of an instant query older than the max cache freshness": {
+			request:             &PrometheusInstantQueryRequest{Path: "/api/v1/query", Time: now.Add(-time.Hour).UnixMilli(), Query: "foo"},
+			expectedDownstreams: 1,
+			expectedMetrics: `
+				# HELP cortex_frontend_instant_query_result_cache_attempted_total Total number of instant queries that were attempted to be fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_attempted_total counter
+				cortex_frontend_instant_query_result_cache_attempted_total 2
+				# HELP cortex_frontend_instant_query_result_cache_hits_total Total number of instant queries whose results have been fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_hits_total counter
+				cortex_frontend_instant_query_result_cache_hits_total 1
+				# HELP cortex_frontend_instant_query_result_cache_skipped_total Total number of times an instant query was not cacheable because of a reason.
+				# TYPE cortex_frontend_instant_query_result_cache_skipped_total counter
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="has-modifiers"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-large"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-new"} 0
+			`,
+		},
+		"should not cache the results of an instant query more recent than the max cache freshness": {
+			request:             &PrometheusInstantQueryRequest{Path: "/api/v1/query", Time: now.Add(-5 * time.Minute).UnixMilli(), Query: "foo"},
+			expectedDownstreams: 2,
+			expectedMetrics: `
+				# HELP cortex_frontend_instant_query_result_cache_attempted_total Total number of instant queries that were attempted to be fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_attempted_total counter
+				cortex_frontend_instant_query_result_cache_attempted_total 2
+				# HELP cortex_frontend_instant_query_result_cache_hits_total Total number of instant queries whose results have been fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_hits_total counter
+				cortex_frontend_instant_query_result_cache_hits_total 0
+				# HELP cortex_frontend_instant_query_result_cache_skipped_total Total number of times an instant query was not cacheable because of a reason.
+				# TYPE cortex_frontend_instant_query_result_cache_skipped_total counter
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="has-modifiers"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-large"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-new"} 2
+			`,
+		},
+		"should not cache the results of an instant query with a @ modifier after the max cache freshness": {
+			request:             &PrometheusInstantQueryRequest{Path: "/api/v1/query", Time: now.Add(-time.Hour).UnixMilli(), Query: fmt.Sprintf("foo @ %d", now.Unix())},
+			expectedDownstreams: 2,
+			expectedMetrics: `
+				# HELP cortex_frontend_instant_query_result_cache_attempted_total Total number of instant queries that were attempted to be fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_attempted_total counter
+				cortex_frontend_instant_query_result_cache_attempted_total 2
+				# HELP cortex_frontend_instant_query_result_cache_hits_total Total number of instant queries whose results have been fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_hits_total counter
+				cortex_frontend_instant_query_result_cache_hits_total 0
+				# HELP cortex_frontend_instant_query_result_cache_skipped_total Total number of times an instant query was not cacheable because of a reason.
+				# TYPE cortex_frontend_instant_query_result_cache_skipped_total counter
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="has-modifiers"} 2
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-large"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-new"} 0
+			`,
+		},
+		"should not cache the results of an instant query with a negative offset": {
+			request:             &PrometheusInstantQueryRequest{Path: "/api/v1/query", Time: now.Add(-time.Hour).UnixMilli(), Query: "foo offset -1m"},
+			expectedDownstreams: 2,
+			expectedMetrics: `
+				# HELP cortex_frontend_instant_query_result_cache_attempted_total Total number of instant queries that were attempted to be fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_attempted_total counter
+				cortex_frontend_instant_query_result_cache_attempted_total 2
+				# HELP cortex_frontend_instant_query_result_cache_hits_total Total number of instant queries whose results have been fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_hits_total counter
+				cortex_frontend_instant_query_result_cache_hits_total 0
+				# HELP cortex_frontend_instant_query_result_cache_skipped_total Total number of times an instant query was not cacheable because of a reason.
+				# TYPE cortex_frontend_instant_query_result_cache_skipped_total counter
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="has-modifiers"} 2
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-large"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-new"} 0
+			`,
+		},
+		"should not cache the results of an instant query if the cache is disabled for the request": {
+			request:             &PrometheusInstantQueryRequest{Path: "/api/v1/query", Time: now.Add(-time.Hour).UnixMilli(), Query: "foo", Options: Options{CacheDisabled: true}},
+			expectedDownstreams: 2,
+			expectedMetrics: `
+				# HELP cortex_frontend_instant_query_result_cache_attempted_total Total number of instant queries that were attempted to be fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_attempted_total counter
+				cortex_frontend_instant_query_result_cache_attempted_total 0
+				# HELP cortex_frontend_instant_query_result_cache_hits_total Total number of instant queries whose results have been fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_hits_total counter
+				cortex_frontend_instant_query_result_cache_hits_total 0
+				# HELP cortex_frontend_instant_query_result_cache_skipped_total Total number of times an instant query was not cacheable because of a reason.
+				# TYPE cortex_frontend_instant_query_result_cache_skipped_total counter
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="has-modifiers"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-large"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-new"} 0
+			`,
+		},
+		"should not cache the results of an instant query if the response disables it": {
+			request: &PrometheusInstantQueryRequest{Path: "/api/v1/query", Time: now.Add(-time.Hour).UnixMilli(), Query: "foo"},
+			downstreamResponse: &PrometheusResponse{
+				Status:  statusSuccess,
+				Data:    downstreamResponse.Data,
+				Headers: []*PrometheusResponseHeader{{Name: cacheControlHeader, Values: []string{noStoreValue}}},
+			},
+			expectedDownstreams: 2,
+			expectedMetrics: `
+				# HELP cortex_frontend_instant_query_result_cache_attempted_total Total number of instant queries that were attempted to be fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_attempted_total counter
+				cortex_frontend_instant_query_result_cache_attempted_total 2
+				# HELP cortex_frontend_instant_query_result_cache_hits_total Total number of instant queries whose results have been fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_hits_total counter
+				cortex_frontend_instant_query_result_cache_hits_total 0
+				# HELP cortex_frontend_instant_query_result_cache_skipped_total Total number of times an instant query was not cacheable because of a reason.
+				# TYPE cortex_frontend_instant_query_result_cache_skipped_total counter
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="has-modifiers"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-large"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-new"} 0
+			`,
+		},
+		"should not cache the results of an instant query larger than the max item size": {
+			request:             &PrometheusInstantQueryRequest{Path: "/api/v1/query", Time: now.Add(-time.Hour).UnixMilli(), Query: "foo"},
+			limits:              mockLimits{resultsCacheMaxItemSizeBytes: 10},
+			expectedDownstreams: 2,
+			expectedMetrics: `
+				# HELP cortex_frontend_instant_query_result_cache_attempted_total Total number of instant queries that were attempted to be fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_attempted_total counter
+				cortex_frontend_instant_query_result_cache_attempted_total 2
+				# HELP cortex_frontend_instant_query_result_cache_hits_total Total number of instant queries whose results have been fetched from cache.
+				# TYPE cortex_frontend_instant_query_result_cache_hits_total counter
+				cortex_frontend_instant_query_result_cache_hits_total 0
+				# HELP cortex_frontend_instant_query_result_cache_skipped_total Total number of times an instant query was not cacheable because of a reason.
+				# TYPE cortex_frontend_instant_query_result_cache_skipped_total counter
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="has-modifiers"} 0
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-large"} 2
+				cortex_frontend_instant_query_result_cache_skipped_total{reason="too-new"} 0
+			`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limits := testData.limits
+			limits.maxCacheFreshness = 10 * time.Minute
+
+			expectedResponse := testData.downstreamResponse
+			if expectedResponse == nil {
+				expectedResponse = downstreamResponse
+			}
+
+			reg := prometheus.NewPedanticRegistry()
+			mw := newInstantQueryCacheMiddleware(limits, cache.NewMockCache(), PrometheusResponseExtractor{}, resultsCacheEnabledByOption, log.NewNopLogger(), reg)
+
+			downstreams := 0
+			handler := mw.Wrap(HandlerFunc(func(_ context.Context, req Request) (Response, error) {
+				downstreams++
+				return expectedResponse, nil
+			}))
+
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+			for i := 0; i < 2; i++ {
+				resp, err := handler.Do(ctx, testData.request)
+				require.NoError(t, err)
+				assert.Equal(t, expectedResponse.Data, resp.(*PrometheusResponse).Data)
+			}
+
+			assert.Equal(t, testData.expectedDownstreams, downstreams)
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(testData.expectedMetrics)))
+		})
+	}
+}
+
+func TestInstantQueryCacheMiddleware_ShouldNotShareTheCacheEntriesAcrossTenantsAndTimes(t *testing.T) {
+	now := time.Now()
+	mw := newInstantQueryCacheMiddleware(mockLimits{maxCacheFreshness: 10 * time.Minute}, cache.NewMockCache(), PrometheusResponseExtractor{}, resultsCacheEnabledByOption, log.NewNopLogger(), nil)
+
+	downstreams := 0
+	handler := mw.Wrap(HandlerFunc(func(_ context.Context, req Request) (Response, error) {
+		downstreams++
+		return &PrometheusResponse{Status: statusSuccess, Data: &PrometheusData{ResultType: model.ValVector.String()}}, nil
+	}))
+
+	for _, userID := range []string{"user-1", "user-2", "user-1"} {
+		for _, ts := range []time.Time{now.Add(-time.Hour), now.Add(-2 * time.Hour)} {
+			_, err := handler.Do(user.InjectOrgID(context.Background(), userID), &PrometheusInstantQueryRequest{Path: "/api/v1/query", Time: ts.UnixMilli(), Query: "foo"})
+			require.NoError(t, err)
+		}
+	}
+
+	// The last 2 requests are served from the cache.
+	assert.Equal(t, 4, downstreams)
+}
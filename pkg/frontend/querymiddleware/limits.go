@@ -22,11 +22,18 @@ import (
 
 	apierror "github.com/grafana/mimir/pkg/api/error"
 	"github.com/grafana/mimir/pkg/util"
-	util_math "github.com/grafana/mimir/pkg/util/math"
 	"github.com/grafana/mimir/pkg/util/spanlogger"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
+const (
+	// queryClampedHeader is the response header listing the bounds of the query time range
+	// which have been manipulated to enforce the limits.
+	queryClampedHeader = "X-Mimir-Query-Clamped"
+	queryClampedStart  = "start"
+	queryClampedEnd    = "end"
+)
+
 // Limits allows us to specify per-tenant runtime limits on the behavior of
 // the query handling code.
 type Limits interface {
@@ -36,6 +43,9 @@ type Limits interface {
 	// MaxTotalQueryLength returns the limit of the length (in time) of a query.
 	MaxTotalQueryLength(userID string) time.Duration
 
+	// MaxQueryIntoFuture returns how far into the future queries can query data.
+	MaxQueryIntoFuture(userID string) time.Duration
+
 	// MaxQueryParallelism returns the limit to the number of split queries the
 	// frontend will process in parallel.
 	MaxQueryParallelism(userID string) int
@@ -51,6 +61,13 @@ type Limits interface {
 	// be run for a given received query. 0 to disable limit.
 	QueryShardingMaxShardedQueries(userID string) int
 
+	// QueryShardingVerticalEnabled returns whether the queries without aggregations should be sharded by series.
+	QueryShardingVerticalEnabled(userID string) bool
+
+	// QueryShardingTargetSeriesPerShard returns the target number of series per shard used to
+	// pick the number of shards of a query from its estimated series count. 0 to disable.
+	QueryShardingTargetSeriesPerShard(userID string) int
+
 	// SplitInstantQueriesByInterval returns the time interval to split instant queries for a given tenant.
 	SplitInstantQueriesByInterval(userID string) time.Duration
 
@@ -67,6 +84,23 @@ type Limits interface {
 	// CreationGracePeriod returns the time interval to control how far into the future
 	// incoming samples are accepted compared to the wall clock.
 	CreationGracePeriod(userID string) time.Duration
+
+	// MaxEstimatedQueryCost returns the max estimated cost of a query. 0 to disable limit.
+	MaxEstimatedQueryCost(userID string) int
+
+	// ResultsCacheTTL returns the time to live of the tenant's entries in the results cache.
+	ResultsCacheTTL(userID string) time.Duration
+
+	// ResultsCacheMaxItemSizeBytes returns the max size of the tenant's entries in the results cache.
+	// 0 to disable limit.
+	ResultsCacheMaxItemSizeBytes(userID string) int
+
+	// ResultsCacheCompression returns the compression of the tenant's entries in the results cache.
+	// Empty to use the compression of the results cache config.
+	ResultsCacheCompression(userID string) string
+
+	// QueryStepAlignEnabled returns whether the start and end of the tenant's range queries should be aligned with their step.
+	QueryStepAlignEnabled(userID string) bool
 }
 
 type limitsMiddleware struct {
@@ -95,10 +129,13 @@ func (l limitsMiddleware) Do(ctx context.Context, r Request) (Response, error) {
 		return nil, apierror.New(apierror.TypeBadData, err.Error())
 	}
 
+	// The bounds of the query time range manipulated because of the limits.
+	var clamped []string
+
 	// Clamp the time range based on the max query lookback and block retention period.
 	blocksRetentionPeriod := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.CompactorBlocksRetentionPeriod)
 	maxQueryLookback := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.MaxQueryLookback)
-	maxLookback := util_math.MinDuration(blocksRetentionPeriod, maxQueryLookback)
+	maxLookback := smallestPositiveNonZeroDuration(blocksRetentionPeriod, maxQueryLookback)
 	if maxLookback > 0 {
 		minStartTime := util.TimeToMillis(time.Now().Add(-maxLookback))
 
@@ -112,7 +149,7 @@ func (l limitsMiddleware) Do(ctx context.Context, r Request) (Response, error) {
 				"maxQueryLookback", maxQueryLookback,
 				"blocksRetentionPeriod", blocksRetentionPeriod)
 
-			return newEmptyPrometheusResponse(), nil
+			return withQueryClampedHeader(newEmptyPrometheusResponse(), queryClampedStart), nil
 		}
 
 		if r.GetStart() < minStartTime {
@@ -125,22 +162,40 @@ func (l limitsMiddleware) Do(ctx context.Context, r Request) (Response, error) {
 				"blocksRetentionPeriod", blocksRetentionPeriod)
 
 			r = r.WithStartEnd(minStartTime, r.GetEnd())
+			clamped = append(clamped, queryClampedStart)
 		}
 	}
 
-	// Enforce the max end time.
+	// Enforce the max end time, based on the creation grace period and max query into future.
 	creationGracePeriod := validation.LargestPositiveNonZeroDurationPerTenant(tenantIDs, l.CreationGracePeriod)
-	if creationGracePeriod > 0 {
-		maxEndTime := util.TimeToMillis(time.Now().Add(creationGracePeriod))
+	maxQueryIntoFuture := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.MaxQueryIntoFuture)
+	if maxIntoFuture := smallestPositiveNonZeroDuration(creationGracePeriod, maxQueryIntoFuture); maxIntoFuture > 0 {
+		maxEndTime := util.TimeToMillis(time.Now().Add(maxIntoFuture))
+
+		if r.GetStart() > maxEndTime {
+			// The request is fully outside the allowed range, so we can return an
+			// empty response.
+			level.Debug(log).Log(
+				"msg", "skipping the execution of the query because its time range is after the 'creation grace period' or 'max query into future' setting",
+				"reqStart", util.FormatTimeMillis(r.GetStart()),
+				"reqEnd", util.FormatTimeMillis(r.GetEnd()),
+				"creationGracePeriod", creationGracePeriod,
+				"maxQueryIntoFuture", maxQueryIntoFuture)
+
+			return withQueryClampedHeader(newEmptyPrometheusResponse(), queryClampedEnd), nil
+		}
+
 		if r.GetEnd() > maxEndTime {
 			// Replace the end time in the request.
 			level.Debug(log).Log(
-				"msg", "the end time of the query has been manipulated because of the 'creation grace period' setting",
+				"msg", "the end time of the query has been manipulated because of the 'creation grace period' or 'max query into future' setting",
 				"original", util.FormatTimeMillis(r.GetEnd()),
 				"updated", util.FormatTimeMillis(maxEndTime),
-				"creationGracePeriod", creationGracePeriod)
+				"creationGracePeriod", creationGracePeriod,
+				"maxQueryIntoFuture", maxQueryIntoFuture)
 
 			r = r.WithStartEnd(r.GetStart(), maxEndTime)
+			clamped = append(clamped, queryClampedEnd)
 		}
 	}
 
@@ -152,7 +207,32 @@ func (l limitsMiddleware) Do(ctx context.Context, r Request) (Response, error) {
 		}
 	}
 
-	return l.next.Do(ctx, r)
+	res, err := l.next.Do(ctx, r)
+	if err != nil || len(clamped) == 0 {
+		return res, err
+	}
+	return withQueryClampedHeader(res, clamped...), nil
+}
+
+// withQueryClampedHeader adds the header listing the input bounds of the query time range, manipulated
+// because of the limits, to the response.
+func withQueryClampedHeader(res Response, bounds ...string) Response {
+	if promRes, ok := res.(*PrometheusResponse); ok {
+		promRes.Headers = append(promRes.Headers, &PrometheusResponseHeader{Name: queryClampedHeader, Values: bounds})
+	}
+	return res
+}
+
+// smallestPositiveNonZeroDuration returns the smallest of the input durations greater than 0,
+// or 0 if none is.
+func smallestPositiveNonZeroDuration(values ...time.Duration) time.Duration {
+	var result time.Duration
+	for _, v := range values {
+		if v > 0 && (result == 0 || v < result) {
+			result = v
+		}
+	}
+	return result
 }
 
 type limitedParallelismRoundTripper struct {
@@ -78,6 +78,14 @@ func TestLimitsMiddleware_MaxQueryLookback(t *testing.T) {
 			reqEndTime:            now.Add(-thirtyDays).Add(-90 * time.Hour),
 			expectedSkipped:       true,
 		},
+		"should manipulate a query on large time range over the limit when the blocks retention period is disabled": {
+			maxQueryLookback:      thirtyDays,
+			blocksRetentionPeriod: 0,
+			reqStartTime:          now.Add(-thirtyDays).Add(-100 * time.Hour),
+			reqEndTime:            now,
+			expectedStartTime:     now.Add(-thirtyDays),
+			expectedEndTime:       now,
+		},
 		"should manipulate a query where maxQueryLookback is past the retention period": {
 			maxQueryLookback:      thirtyDays,
 			blocksRetentionPeriod: thirtyDays - (24 * time.Hour),
@@ -278,19 +286,178 @@ func TestLimitsMiddleware_CreationGracePeriod(t *testing.T) {
 	}
 }
 
+func TestLimitsMiddleware_MaxQueryIntoFuture(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		req                 Request
+		creationGracePeriod time.Duration
+		maxQueryIntoFuture  time.Duration
+		expectedSkipped     bool
+		expectedEndTime     time.Time
+		expectedClamped     []string
+	}{
+		"should not manipulate time range if max query into future is disabled": {
+			req:             &PrometheusRangeQueryRequest{Start: util.TimeToMillis(now.Add(-time.Hour)), End: util.TimeToMillis(now.Add(2 * time.Hour))},
+			expectedEndTime: now.Add(2 * time.Hour),
+		},
+		"should not manipulate time range for a query in now + max_query_into_future": {
+			req:                &PrometheusRangeQueryRequest{Start: util.TimeToMillis(now.Add(-time.Hour)), End: util.TimeToMillis(now.Add(30 * time.Minute))},
+			maxQueryIntoFuture: time.Hour,
+			expectedEndTime:    now.Add(30 * time.Minute),
+		},
+		"should manipulate time range for a query over now + max_query_into_future": {
+			req:                &PrometheusRangeQueryRequest{Start: util.TimeToMillis(now.Add(-time.Hour)), End: util.TimeToMillis(now.Add(2 * time.Hour))},
+			maxQueryIntoFuture: time.Hour,
+			expectedEndTime:    now.Add(time.Hour),
+			expectedClamped:    []string{queryClampedEnd},
+		},
+		"should manipulate time range based on max_query_into_future if smaller than creation_grace_period": {
+			req:                 &PrometheusRangeQueryRequest{Start: util.TimeToMillis(now.Add(-time.Hour)), End: util.TimeToMillis(now.Add(2 * time.Hour))},
+			creationGracePeriod: time.Hour,
+			maxQueryIntoFuture:  10 * time.Minute,
+			expectedEndTime:     now.Add(10 * time.Minute),
+			expectedClamped:     []string{queryClampedEnd},
+		},
+		"should manipulate time range based on creation_grace_period if smaller than max_query_into_future": {
+			req:                 &PrometheusRangeQueryRequest{Start: util.TimeToMillis(now.Add(-time.Hour)), End: util.TimeToMillis(now.Add(2 * time.Hour))},
+			creationGracePeriod: 10 * time.Minute,
+			maxQueryIntoFuture:  time.Hour,
+			expectedEndTime:     now.Add(10 * time.Minute),
+			expectedClamped:     []string{queryClampedEnd},
+		},
+		"should skip executing a range query fully after now + max_query_into_future": {
+			req:                &PrometheusRangeQueryRequest{Start: util.TimeToMillis(now.Add(2 * time.Hour)), End: util.TimeToMillis(now.Add(3 * time.Hour))},
+			maxQueryIntoFuture: time.Hour,
+			expectedSkipped:    true,
+			expectedClamped:    []string{queryClampedEnd},
+		},
+		"should skip executing an instant query after now + max_query_into_future": {
+			req:                &PrometheusInstantQueryRequest{Time: util.TimeToMillis(now.Add(2 * time.Hour))},
+			maxQueryIntoFuture: time.Hour,
+			expectedSkipped:    true,
+			expectedClamped:    []string{queryClampedEnd},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limits := mockLimits{creationGracePeriod: testData.creationGracePeriod, maxQueryIntoFuture: testData.maxQueryIntoFuture}
+			middleware := newLimitsMiddleware(limits, log.NewNopLogger())
+
+			innerRes := newEmptyPrometheusResponse()
+			inner := &mockHandler{}
+			inner.On("Do", mock.Anything, mock.Anything).Return(innerRes, nil)
+
+			ctx := user.InjectOrgID(context.Background(), "test")
+			outer := middleware.Wrap(inner)
+			res, err := outer.Do(ctx, testData.req)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedClamped, queryClampedHeaderValues(res))
+
+			if testData.expectedSkipped {
+				assert.NotSame(t, innerRes, res)
+				assert.Len(t, inner.Calls, 0)
+				return
+			}
+
+			// We expect the response returned by the inner handler.
+			assert.Same(t, innerRes, res)
+
+			// Assert on the time range of the request passed to the inner handler (5s delta).
+			delta := float64(5000)
+			require.Len(t, inner.Calls, 1)
+
+			assert.InDelta(t, util.TimeToMillis(testData.expectedEndTime), inner.Calls[0].Arguments.Get(1).(Request).GetEnd(), delta)
+		})
+	}
+}
+
+func TestLimitsMiddleware_QueryClampedHeader(t *testing.T) {
+	now := time.Now()
+
+	tests := map[string]struct {
+		reqStartTime    time.Time
+		reqEndTime      time.Time
+		expectedClamped []string
+	}{
+		"should not add the header if the time range has not been manipulated": {
+			reqStartTime: now.Add(-time.Hour),
+			reqEndTime:   now,
+		},
+		"should add the header if the start time has been manipulated": {
+			reqStartTime:    now.Add(-48 * time.Hour),
+			reqEndTime:      now,
+			expectedClamped: []string{queryClampedStart},
+		},
+		"should add the header if both the start and end time have been manipulated": {
+			reqStartTime:    now.Add(-48 * time.Hour),
+			reqEndTime:      now.Add(48 * time.Hour),
+			expectedClamped: []string{queryClampedStart, queryClampedEnd},
+		},
+		"should add the header if the query has been skipped because of the max query lookback": {
+			reqStartTime:    now.Add(-72 * time.Hour),
+			reqEndTime:      now.Add(-48 * time.Hour),
+			expectedClamped: []string{queryClampedStart},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			req := &PrometheusRangeQueryRequest{
+				Start: util.TimeToMillis(testData.reqStartTime),
+				End:   util.TimeToMillis(testData.reqEndTime),
+			}
+
+			limits := mockLimits{maxQueryLookback: 24 * time.Hour, maxQueryIntoFuture: time.Hour}
+			middleware := newLimitsMiddleware(limits, log.NewNopLogger())
+
+			inner := &mockHandler{}
+			inner.On("Do", mock.Anything, mock.Anything).Return(newEmptyPrometheusResponse(), nil)
+
+			ctx := user.InjectOrgID(context.Background(), "test")
+			res, err := middleware.Wrap(inner).Do(ctx, req)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedClamped, queryClampedHeaderValues(res))
+
+			// The header is propagated to the HTTP response.
+			httpRes, err := PrometheusCodec.EncodeResponse(ctx, res)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedClamped, httpRes.Header.Values(queryClampedHeader))
+		})
+	}
+}
+
+func queryClampedHeaderValues(res Response) []string {
+	for _, h := range res.GetHeaders() {
+		if h.Name == queryClampedHeader {
+			return h.Values
+		}
+	}
+	return nil
+}
+
 type mockLimits struct {
 	maxQueryLookback               time.Duration
 	maxQueryLength                 time.Duration
 	maxTotalQueryLength            time.Duration
+	maxQueryIntoFuture             time.Duration
 	maxCacheFreshness              time.Duration
 	maxQueryParallelism            int
 	maxShardedQueries              int
+	verticalShardingEnabled        bool
+	targetSeriesPerShard           int
 	splitInstantQueriesInterval    time.Duration
 	totalShards                    int
 	compactorShards                int
 	compactorBlocksRetentionPeriod time.Duration
 	outOfOrderTimeWindow           model.Duration
 	creationGracePeriod            time.Duration
+	maxEstimatedQueryCost          int
+	resultsCacheTTL                time.Duration
+	resultsCacheMaxItemSizeBytes   int
+	resultsCacheCompression        string
+	stepAlignEnabled               bool
 }
 
 func (m mockLimits) MaxQueryLookback(string) time.Duration {
@@ -308,6 +475,10 @@ func (m mockLimits) MaxTotalQueryLength(string) time.Duration {
 	return m.maxTotalQueryLength
 }
 
+func (m mockLimits) MaxQueryIntoFuture(string) time.Duration {
+	return m.maxQueryIntoFuture
+}
+
 func (m mockLimits) MaxQueryParallelism(string) int {
 	if m.maxQueryParallelism == 0 {
 		return 14 // Flag default.
@@ -327,6 +498,14 @@ func (m mockLimits) QueryShardingMaxShardedQueries(string) int {
 	return m.maxShardedQueries
 }
 
+func (m mockLimits) QueryShardingVerticalEnabled(string) bool {
+	return m.verticalShardingEnabled
+}
+
+func (m mockLimits) QueryShardingTargetSeriesPerShard(string) int {
+	return m.targetSeriesPerShard
+}
+
 func (m mockLimits) SplitInstantQueriesByInterval(string) time.Duration {
 	return m.splitInstantQueriesInterval
 }
@@ -347,6 +526,26 @@ func (m mockLimits) CreationGracePeriod(userID string) time.Duration {
 	return m.creationGracePeriod
 }
 
+func (m mockLimits) MaxEstimatedQueryCost(string) int {
+	return m.maxEstimatedQueryCost
+}
+
+func (m mockLimits) ResultsCacheTTL(string) time.Duration {
+	return m.resultsCacheTTL
+}
+
+func (m mockLimits) ResultsCacheMaxItemSizeBytes(string) int {
+	return m.resultsCacheMaxItemSizeBytes
+}
+
+func (m mockLimits) ResultsCacheCompression(string) string {
+	return m.resultsCacheCompression
+}
+
+func (m mockLimits) QueryStepAlignEnabled(string) bool {
+	return m.stepAlignEnabled
+}
+
 type mockHandler struct {
 	mock.Mock
 }
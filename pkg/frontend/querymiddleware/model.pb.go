@@ -577,6 +577,7 @@ type Options struct {
 	InstantSplitDisabled bool  `protobuf:"varint,4,opt,name=InstantSplitDisabled,proto3" json:"InstantSplitDisabled,omitempty"`
 	// Instant split by time interval unit stored in nanoseconds (time.Duration unit in int64)
 	InstantSplitInterval int64 `protobuf:"varint,5,opt,name=InstantSplitInterval,proto3" json:"InstantSplitInterval,omitempty"`
+	StepAlignDisabled    bool  `protobuf:"varint,6,opt,name=StepAlignDisabled,proto3" json:"StepAlignDisabled,omitempty"`
 }
 
 func (m *Options) Reset()      { *m = Options{} }
@@ -646,9 +647,18 @@ func (m *Options) GetInstantSplitInterval() int64 {
 	return 0
 }
 
+func (m *Options) GetStepAlignDisabled() bool {
+	if m != nil {
+		return m.StepAlignDisabled
+	}
+	return false
+}
+
 type Hints struct {
 	// Total number of queries that are expected to to be executed to serve the original request.
 	TotalQueries int32 `protobuf:"varint,1,opt,name=TotalQueries,proto3" json:"TotalQueries,omitempty"`
+	// Estimated number of series selected by the query of the original request.
+	EstimatedSeriesCount uint64 `protobuf:"varint,2,opt,name=EstimatedSeriesCount,proto3" json:"EstimatedSeriesCount,omitempty"`
 }
 
 func (m *Hints) Reset()      { *m = Hints{} }
@@ -690,6 +700,13 @@ func (m *Hints) GetTotalQueries() int32 {
 	return 0
 }
 
+func (m *Hints) GetEstimatedSeriesCount() uint64 {
+	if m != nil {
+		return m.EstimatedSeriesCount
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*PrometheusRangeQueryRequest)(nil), "queryrange.PrometheusRangeQueryRequest")
 	proto.RegisterType((*PrometheusInstantQueryRequest)(nil), "queryrange.PrometheusInstantQueryRequest")
@@ -706,70 +723,72 @@ func init() {
 func init() { proto.RegisterFile("model.proto", fileDescriptor_4c16552f9fdb66d8) }
 
 var fileDescriptor_4c16552f9fdb66d8 = []byte{
-	// 993 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xcd, 0x6e, 0x1c, 0x45,
-	0x10, 0xde, 0xd9, 0x7f, 0xd7, 0x86, 0xb5, 0x69, 0x5b, 0x62, 0x6c, 0x94, 0x99, 0xd5, 0x28, 0x07,
-	0xf3, 0xe3, 0x35, 0x38, 0xe2, 0x82, 0x04, 0x22, 0x13, 0x5b, 0x8a, 0x11, 0x82, 0xd0, 0xb6, 0x38,
-	0x70, 0x41, 0xbd, 0x3b, 0x9d, 0xdd, 0x21, 0xf3, 0x97, 0x9e, 0x9e, 0x90, 0xbd, 0x21, 0x9e, 0x80,
-	0x23, 0x2f, 0x80, 0xc4, 0x81, 0x33, 0x27, 0x1e, 0x20, 0x47, 0x73, 0x8b, 0x38, 0x0c, 0x78, 0x2d,
-	0x24, 0xb4, 0xa7, 0x3c, 0x02, 0xea, 0xea, 0x99, 0xdd, 0x71, 0x6c, 0x44, 0xb8, 0xec, 0x56, 0x57,
-	0x7d, 0x55, 0xfd, 0xd5, 0x37, 0xd5, 0x05, 0xbd, 0x30, 0xf6, 0x78, 0x30, 0x4c, 0x44, 0x2c, 0x63,
-	0x02, 0x8f, 0x32, 0x2e, 0x66, 0x82, 0x45, 0x13, 0xbe, 0xb3, 0x37, 0xf1, 0xe5, 0x34, 0x1b, 0x0d,
-	0xc7, 0x71, 0xb8, 0x3f, 0x89, 0x27, 0xf1, 0x3e, 0x42, 0x46, 0xd9, 0x03, 0x3c, 0xe1, 0x01, 0x2d,
-	0x9d, 0xba, 0x63, 0x4d, 0xe2, 0x78, 0x12, 0xf0, 0x15, 0xca, 0xcb, 0x04, 0x93, 0x7e, 0x1c, 0x15,
-	0xf1, 0x77, 0xaa, 0xe5, 0x04, 0x7b, 0xc0, 0x22, 0xb6, 0x1f, 0xfa, 0xa1, 0x2f, 0xf6, 0x93, 0x87,
-	0x13, 0x6d, 0x25, 0x23, 0xfd, 0x5f, 0x64, 0x6c, 0xbf, 0x58, 0x91, 0x45, 0x33, 0x1d, 0x72, 0x7e,
-	0xa9, 0xc3, 0xeb, 0xf7, 0x45, 0x1c, 0x72, 0x39, 0xe5, 0x59, 0x4a, 0x15, 0xdf, 0xcf, 0x15, 0x73,
-	0xca, 0x1f, 0x65, 0x3c, 0x95, 0x84, 0x40, 0x33, 0x61, 0x72, 0x6a, 0x1a, 0x03, 0x63, 0x77, 0x8d,
-	0xa2, 0x4d, 0xb6, 0xa0, 0x95, 0x4a, 0x26, 0xa4, 0x59, 0x1f, 0x18, 0xbb, 0x0d, 0xaa, 0x0f, 0x64,
-	0x03, 0x1a, 0x3c, 0xf2, 0xcc, 0x06, 0xfa, 0x94, 0xa9, 0x72, 0x53, 0xc9, 0x13, 0xb3, 0x89, 0x2e,
-	0xb4, 0xc9, 0x07, 0xd0, 0x91, 0x7e, 0xc8, 0xe3, 0x4c, 0x9a, 0xad, 0x81, 0xb1, 0xdb, 0x3b, 0xd8,
-	0x1e, 0x6a, 0x72, 0xc3, 0x92, 0xdc, 0xf0, 0xb0, 0x68, 0xd7, 0xed, 0x3e, 0xcd, 0xed, 0xda, 0x0f,
-	0x7f, 0xd8, 0x06, 0x2d, 0x73, 0xd4, 0xd5, 0x28, 0xac, 0xd9, 0x46, 0x3e, 0xfa, 0x40, 0x6e, 0x43,
-	0x27, 0x4e, 0x54, 0x4a, 0x6a, 0x76, 0xb0, 0xe8, 0xe6, 0x70, 0x25, 0xff, 0xf0, 0x33, 0x1d, 0x72,
-	0x9b, 0xaa, 0x1c, 0x2d, 0x91, 0xa4, 0x0f, 0x75, 0xdf, 0x33, 0xbb, 0xc8, 0xad, 0xee, 0x7b, 0x64,
-	0x0f, 0x5a, 0x53, 0x3f, 0x92, 0xa9, 0xb9, 0x86, 0x25, 0x5e, 0xad, 0x96, 0xb8, 0xa7, 0x02, 0x58,
-	0xc0, 0xa0, 0x1a, 0xe5, 0xfc, 0x66, 0xc0, 0xcd, 0x95, 0x70, 0xc7, 0x51, 0x2a, 0x59, 0x24, 0xff,
-	0x53, 0x3a, 0x02, 0x4d, 0xd5, 0x4a, 0xa1, 0x1c, 0xda, 0xab, 0x9e, 0x1a, 0xff, 0xd2, 0x53, 0xf3,
-	0x7f, 0xf6, 0xd4, 0xba, 0xda, 0x53, 0xfb, 0xa5, 0x7a, 0x3a, 0x05, 0xb3, 0x32, 0x0b, 0x3c, 0x4d,
-	0xe2, 0x28, 0xe5, 0xf7, 0x38, 0xf3, 0xb8, 0x20, 0xdb, 0xd0, 0xfc, 0x94, 0x85, 0x5c, 0x77, 0xe3,
-	0xb6, 0x16, 0xb9, 0x6d, 0xec, 0x51, 0x74, 0x91, 0x9b, 0xd0, 0xfe, 0x82, 0x05, 0x19, 0x4f, 0xcd,
-	0xfa, 0xa0, 0xb1, 0x0a, 0x16, 0x4e, 0xe7, 0xc7, 0x3a, 0x90, 0xab, 0x65, 0x89, 0x03, 0xed, 0x13,
-	0xc9, 0x64, 0x96, 0x16, 0x25, 0x61, 0x91, 0xdb, 0xed, 0x14, 0x3d, 0xb4, 0x88, 0x10, 0x17, 0x9a,
-	0x87, 0x4c, 0x32, 0x94, 0xab, 0x77, 0xb0, 0x53, 0xa5, 0xbf, 0xaa, 0xa8, 0x10, 0x2e, 0x59, 0xe4,
-	0x76, 0xdf, 0x63, 0x92, 0xbd, 0x1d, 0x87, 0xbe, 0xe4, 0x61, 0x22, 0x67, 0x14, 0x73, 0xc9, 0x7b,
-	0xb0, 0x76, 0x24, 0x44, 0x2c, 0x4e, 0x67, 0x09, 0xd7, 0x12, 0xbb, 0xaf, 0x2d, 0x72, 0x7b, 0x93,
-	0x97, 0xce, 0x4a, 0xc6, 0x0a, 0x49, 0xde, 0x80, 0x16, 0x1e, 0x50, 0xfd, 0x35, 0x77, 0x73, 0x91,
-	0xdb, 0xeb, 0x98, 0x52, 0x81, 0x6b, 0x04, 0x39, 0x82, 0x8e, 0x16, 0x29, 0x35, 0x5b, 0x83, 0xc6,
-	0x6e, 0xef, 0xe0, 0xd6, 0xf5, 0x44, 0x2f, 0x2b, 0x5a, 0xca, 0x54, 0xe6, 0x3a, 0xdf, 0x19, 0xd0,
-	0xbf, 0xdc, 0x15, 0x19, 0x02, 0x50, 0x9e, 0x66, 0x81, 0x44, 0xf2, 0x5a, 0xa7, 0xfe, 0x22, 0xb7,
-	0x41, 0x2c, 0xbd, 0xb4, 0x82, 0x20, 0x1f, 0x41, 0x5b, 0x9f, 0xf0, 0x4b, 0xf4, 0x0e, 0xcc, 0x2a,
-	0x91, 0x13, 0x16, 0x26, 0x01, 0x3f, 0x91, 0x82, 0xb3, 0xd0, 0xed, 0xab, 0xc1, 0x51, 0x8a, 0xeb,
-	0x4a, 0xb4, 0xc8, 0x73, 0x7e, 0x35, 0xe0, 0x46, 0x15, 0x48, 0x12, 0x68, 0x07, 0x6c, 0xc4, 0x03,
-	0xf5, 0x99, 0x1a, 0x38, 0x86, 0xe3, 0x58, 0x48, 0xfe, 0x24, 0x19, 0x0d, 0x3f, 0x51, 0xfe, 0xfb,
-	0xcc, 0x17, 0xee, 0x5d, 0x55, 0xed, 0xf7, 0xdc, 0x7e, 0xf7, 0x65, 0x56, 0x93, 0xce, 0xbb, 0xe3,
-	0xb1, 0x44, 0x72, 0xa1, 0x28, 0x84, 0x5c, 0x0a, 0x7f, 0x4c, 0x8b, 0x7b, 0xc8, 0xfb, 0xd0, 0x49,
-	0x91, 0x41, 0x5a, 0x74, 0xb1, 0xb1, 0xba, 0x52, 0x53, 0x5b, 0xb1, 0x7f, 0x8c, 0x23, 0x46, 0xcb,
-	0x04, 0xe7, 0x6b, 0xe8, 0xdf, 0x65, 0xe3, 0x29, 0xf7, 0x96, 0x63, 0xb6, 0x0d, 0x8d, 0x87, 0x7c,
-	0x56, 0x68, 0xd7, 0x59, 0xe4, 0xb6, 0x3a, 0x52, 0xf5, 0xa3, 0x76, 0x11, 0x7f, 0x22, 0xb9, 0x7a,
-	0x1f, 0xfa, 0x22, 0x52, 0x95, 0xeb, 0x08, 0x43, 0xee, 0x7a, 0x71, 0x55, 0x09, 0xa5, 0xa5, 0xe1,
-	0xfc, 0x6c, 0x40, 0x5b, 0x83, 0x88, 0x5d, 0x6e, 0x44, 0x75, 0x4d, 0xc3, 0x5d, 0x5b, 0xe4, 0xb6,
-	0x76, 0x94, 0xcb, 0x71, 0x5b, 0x2f, 0x47, 0x7c, 0xf6, 0x9a, 0x05, 0x8f, 0x3c, 0xbd, 0x25, 0x07,
-	0xd0, 0x95, 0x82, 0x8d, 0xf9, 0x57, 0xbe, 0x57, 0xcc, 0x5a, 0x39, 0x18, 0xe8, 0x3e, 0xf6, 0xc8,
-	0x87, 0xd0, 0x15, 0x45, 0x3b, 0xc5, 0xd2, 0xdc, 0xba, 0xb2, 0x34, 0xef, 0x44, 0x33, 0xf7, 0xc6,
-	0x22, 0xb7, 0x97, 0x48, 0xba, 0xb4, 0x3e, 0x6e, 0x76, 0x1b, 0x1b, 0x4d, 0xe7, 0x2f, 0x03, 0x3a,
-	0xc5, 0xda, 0x20, 0xb7, 0xe0, 0x15, 0x94, 0xe9, 0xd0, 0x4f, 0xd9, 0x28, 0xe0, 0x1e, 0xf2, 0xee,
-	0xd2, 0xcb, 0x4e, 0xf2, 0x26, 0x6c, 0x9c, 0x4c, 0x99, 0xf0, 0xfc, 0x68, 0xb2, 0x04, 0xd6, 0x11,
-	0x78, 0xc5, 0x4f, 0x06, 0xd0, 0x3b, 0x8d, 0x25, 0x0b, 0x30, 0x90, 0xe2, 0x3b, 0x6b, 0xd1, 0xaa,
-	0x8b, 0x1c, 0xc0, 0x56, 0xb1, 0x25, 0x4f, 0x92, 0xc0, 0x97, 0xcb, 0x8a, 0x4d, 0xac, 0x78, 0x6d,
-	0xec, 0xc5, 0x9c, 0xe3, 0x48, 0x72, 0xf1, 0x98, 0x05, 0xc5, 0x86, 0xbb, 0x36, 0xe6, 0xbc, 0x05,
-	0x2d, 0x5c, 0x6d, 0xc4, 0x81, 0x1b, 0x78, 0xbf, 0x5a, 0xca, 0x3e, 0xd7, 0x6b, 0xa6, 0x45, 0x2f,
-	0xf9, 0xdc, 0xa3, 0xb3, 0x73, 0xab, 0xf6, 0xec, 0xdc, 0xaa, 0x3d, 0x3f, 0xb7, 0x8c, 0x6f, 0xe7,
-	0x96, 0xf1, 0xd3, 0xdc, 0x32, 0x9e, 0xce, 0x2d, 0xe3, 0x6c, 0x6e, 0x19, 0x7f, 0xce, 0x2d, 0xe3,
-	0xef, 0xb9, 0x55, 0x7b, 0x3e, 0xb7, 0x8c, 0xef, 0x2f, 0xac, 0xda, 0xd9, 0x85, 0x55, 0x7b, 0x76,
-	0x61, 0xd5, 0xbe, 0x5c, 0xc7, 0x31, 0x09, 0x7d, 0xcf, 0x0b, 0xf8, 0x37, 0x4c, 0xf0, 0x51, 0x1b,
-	0xbf, 0xc3, 0xed, 0x7f, 0x02, 0x00, 0x00, 0xff, 0xff, 0x35, 0xce, 0x26, 0x85, 0x03, 0x08, 0x00,
-	0x00,
+	// 1027 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x55, 0xcd, 0x6e, 0x1c, 0x45,
+	0x10, 0xde, 0xd9, 0x7f, 0xd7, 0x9a, 0xb5, 0xd3, 0xb6, 0xc4, 0xd8, 0x28, 0x33, 0xab, 0x55, 0x0e,
+	0x06, 0xc5, 0x6b, 0x70, 0xc4, 0x05, 0x09, 0x84, 0xc7, 0xb6, 0x14, 0x23, 0x04, 0xa1, 0xd7, 0xe2,
+	0xc0, 0x25, 0xea, 0xf5, 0x74, 0x76, 0x87, 0xcc, 0x5f, 0x7a, 0x7a, 0x42, 0xf6, 0x86, 0x78, 0x02,
+	0x24, 0x2e, 0xbc, 0x00, 0x12, 0x07, 0xce, 0x9c, 0x78, 0x80, 0x1c, 0xcd, 0x2d, 0xe2, 0x30, 0xe0,
+	0xf5, 0x05, 0xed, 0x29, 0x8f, 0x80, 0xba, 0x7a, 0x66, 0x77, 0x1c, 0x1b, 0x11, 0x2e, 0x49, 0x75,
+	0xd5, 0x57, 0x55, 0x5f, 0x7d, 0x53, 0x5b, 0x86, 0x4e, 0x10, 0xb9, 0xdc, 0x1f, 0xc4, 0x22, 0x92,
+	0x11, 0x81, 0x27, 0x29, 0x17, 0x53, 0xc1, 0xc2, 0x31, 0xdf, 0xde, 0x1d, 0x7b, 0x72, 0x92, 0x8e,
+	0x06, 0x67, 0x51, 0xb0, 0x37, 0x8e, 0xc6, 0xd1, 0x1e, 0x42, 0x46, 0xe9, 0x23, 0x7c, 0xe1, 0x03,
+	0x2d, 0x9d, 0xba, 0x6d, 0x8d, 0xa3, 0x68, 0xec, 0xf3, 0x25, 0xca, 0x4d, 0x05, 0x93, 0x5e, 0x14,
+	0xe6, 0xf1, 0x77, 0xcb, 0xe5, 0x04, 0x7b, 0xc4, 0x42, 0xb6, 0x17, 0x78, 0x81, 0x27, 0xf6, 0xe2,
+	0xc7, 0x63, 0x6d, 0xc5, 0x23, 0xfd, 0x7f, 0x9e, 0xb1, 0xf5, 0x6a, 0x45, 0x16, 0x4e, 0x75, 0xa8,
+	0xff, 0x6b, 0x15, 0xde, 0x7a, 0x20, 0xa2, 0x80, 0xcb, 0x09, 0x4f, 0x13, 0xaa, 0xf8, 0x7e, 0xa1,
+	0x98, 0x53, 0xfe, 0x24, 0xe5, 0x89, 0x24, 0x04, 0xea, 0x31, 0x93, 0x13, 0xd3, 0xe8, 0x19, 0x3b,
+	0x2b, 0x14, 0x6d, 0xb2, 0x09, 0x8d, 0x44, 0x32, 0x21, 0xcd, 0x6a, 0xcf, 0xd8, 0xa9, 0x51, 0xfd,
+	0x20, 0xeb, 0x50, 0xe3, 0xa1, 0x6b, 0xd6, 0xd0, 0xa7, 0x4c, 0x95, 0x9b, 0x48, 0x1e, 0x9b, 0x75,
+	0x74, 0xa1, 0x4d, 0x3e, 0x84, 0x96, 0xf4, 0x02, 0x1e, 0xa5, 0xd2, 0x6c, 0xf4, 0x8c, 0x9d, 0xce,
+	0xfe, 0xd6, 0x40, 0x93, 0x1b, 0x14, 0xe4, 0x06, 0x47, 0xf9, 0xb8, 0x4e, 0xfb, 0x79, 0x66, 0x57,
+	0x7e, 0xfc, 0xd3, 0x36, 0x68, 0x91, 0xa3, 0x5a, 0xa3, 0xb0, 0x66, 0x13, 0xf9, 0xe8, 0x07, 0xb9,
+	0x07, 0xad, 0x28, 0x56, 0x29, 0x89, 0xd9, 0xc2, 0xa2, 0x1b, 0x83, 0xa5, 0xfc, 0x83, 0xcf, 0x75,
+	0xc8, 0xa9, 0xab, 0x72, 0xb4, 0x40, 0x92, 0x2e, 0x54, 0x3d, 0xd7, 0x6c, 0x23, 0xb7, 0xaa, 0xe7,
+	0x92, 0x5d, 0x68, 0x4c, 0xbc, 0x50, 0x26, 0xe6, 0x0a, 0x96, 0xb8, 0x55, 0x2e, 0x71, 0x5f, 0x05,
+	0xb0, 0x80, 0x41, 0x35, 0xaa, 0xff, 0xbb, 0x01, 0xb7, 0x97, 0xc2, 0x9d, 0x84, 0x89, 0x64, 0xa1,
+	0xfc, 0x4f, 0xe9, 0x08, 0xd4, 0xd5, 0x28, 0xb9, 0x72, 0x68, 0x2f, 0x67, 0xaa, 0xfd, 0xcb, 0x4c,
+	0xf5, 0xff, 0x39, 0x53, 0xe3, 0xfa, 0x4c, 0xcd, 0xd7, 0x9a, 0xe9, 0x14, 0xcc, 0xd2, 0x2e, 0xf0,
+	0x24, 0x8e, 0xc2, 0x84, 0xdf, 0xe7, 0xcc, 0xe5, 0x82, 0x6c, 0x41, 0xfd, 0x33, 0x16, 0x70, 0x3d,
+	0x8d, 0xd3, 0x98, 0x67, 0xb6, 0xb1, 0x4b, 0xd1, 0x45, 0x6e, 0x43, 0xf3, 0x4b, 0xe6, 0xa7, 0x3c,
+	0x31, 0xab, 0xbd, 0xda, 0x32, 0x98, 0x3b, 0xfb, 0x3f, 0x55, 0x81, 0x5c, 0x2f, 0x4b, 0xfa, 0xd0,
+	0x1c, 0x4a, 0x26, 0xd3, 0x24, 0x2f, 0x09, 0xf3, 0xcc, 0x6e, 0x26, 0xe8, 0xa1, 0x79, 0x84, 0x38,
+	0x50, 0x3f, 0x62, 0x92, 0xa1, 0x5c, 0x9d, 0xfd, 0xed, 0x32, 0xfd, 0x65, 0x45, 0x85, 0x70, 0xc8,
+	0x3c, 0xb3, 0xbb, 0x2e, 0x93, 0xec, 0x6e, 0x14, 0x78, 0x92, 0x07, 0xb1, 0x9c, 0x52, 0xcc, 0x25,
+	0xef, 0xc3, 0xca, 0xb1, 0x10, 0x91, 0x38, 0x9d, 0xc6, 0x5c, 0x4b, 0xec, 0xbc, 0x39, 0xcf, 0xec,
+	0x0d, 0x5e, 0x38, 0x4b, 0x19, 0x4b, 0x24, 0x79, 0x1b, 0x1a, 0xf8, 0x40, 0xf5, 0x57, 0x9c, 0x8d,
+	0x79, 0x66, 0xaf, 0x61, 0x4a, 0x09, 0xae, 0x11, 0xe4, 0x18, 0x5a, 0x5a, 0xa4, 0xc4, 0x6c, 0xf4,
+	0x6a, 0x3b, 0x9d, 0xfd, 0x3b, 0x37, 0x13, 0xbd, 0xaa, 0x68, 0x21, 0x53, 0x91, 0xdb, 0xff, 0xce,
+	0x80, 0xee, 0xd5, 0xa9, 0xc8, 0x00, 0x80, 0xf2, 0x24, 0xf5, 0x25, 0x92, 0xd7, 0x3a, 0x75, 0xe7,
+	0x99, 0x0d, 0x62, 0xe1, 0xa5, 0x25, 0x04, 0xf9, 0x18, 0x9a, 0xfa, 0x85, 0x5f, 0xa2, 0xb3, 0x6f,
+	0x96, 0x89, 0x0c, 0x59, 0x10, 0xfb, 0x7c, 0x28, 0x05, 0x67, 0x81, 0xd3, 0x55, 0x8b, 0xa3, 0x14,
+	0xd7, 0x95, 0x68, 0x9e, 0xd7, 0xff, 0xcd, 0x80, 0xd5, 0x32, 0x90, 0xc4, 0xd0, 0xf4, 0xd9, 0x88,
+	0xfb, 0xea, 0x33, 0xd5, 0x70, 0x0d, 0xcf, 0x22, 0x21, 0xf9, 0xb3, 0x78, 0x34, 0xf8, 0x54, 0xf9,
+	0x1f, 0x30, 0x4f, 0x38, 0x87, 0xaa, 0xda, 0x1f, 0x99, 0xfd, 0xde, 0xeb, 0x9c, 0x26, 0x9d, 0x77,
+	0xe0, 0xb2, 0x58, 0x72, 0xa1, 0x28, 0x04, 0x5c, 0x0a, 0xef, 0x8c, 0xe6, 0x7d, 0xc8, 0x07, 0xd0,
+	0x4a, 0x90, 0x41, 0x92, 0x4f, 0xb1, 0xbe, 0x6c, 0xa9, 0xa9, 0x2d, 0xd9, 0x3f, 0xc5, 0x15, 0xa3,
+	0x45, 0x42, 0xff, 0x6b, 0xe8, 0x1e, 0xb2, 0xb3, 0x09, 0x77, 0x17, 0x6b, 0xb6, 0x05, 0xb5, 0xc7,
+	0x7c, 0x9a, 0x6b, 0xd7, 0x9a, 0x67, 0xb6, 0x7a, 0x52, 0xf5, 0x8f, 0xba, 0x45, 0xfc, 0x99, 0xe4,
+	0xa1, 0x2c, 0x1a, 0x91, 0xb2, 0x5c, 0xc7, 0x18, 0x72, 0xd6, 0xf2, 0x56, 0x05, 0x94, 0x16, 0x46,
+	0xff, 0x17, 0x03, 0x9a, 0x1a, 0x44, 0xec, 0xe2, 0x22, 0xaa, 0x36, 0x35, 0x67, 0x65, 0x9e, 0xd9,
+	0xda, 0x51, 0x1c, 0xc7, 0x2d, 0x7d, 0x1c, 0xf1, 0x67, 0xaf, 0x59, 0xf0, 0xd0, 0xd5, 0x57, 0xb2,
+	0x07, 0x6d, 0x29, 0xd8, 0x19, 0x7f, 0xe8, 0xb9, 0xf9, 0xae, 0x15, 0x8b, 0x81, 0xee, 0x13, 0x97,
+	0x7c, 0x04, 0x6d, 0x91, 0x8f, 0x93, 0x1f, 0xcd, 0xcd, 0x6b, 0x47, 0xf3, 0x20, 0x9c, 0x3a, 0xab,
+	0xf3, 0xcc, 0x5e, 0x20, 0xe9, 0xc2, 0xfa, 0xa4, 0xde, 0xae, 0xad, 0xd7, 0xfb, 0x3f, 0x54, 0xa1,
+	0x95, 0x9f, 0x0d, 0x72, 0x07, 0xde, 0x40, 0x99, 0x8e, 0xbc, 0x84, 0x8d, 0x7c, 0xee, 0x22, 0xef,
+	0x36, 0xbd, 0xea, 0x24, 0xef, 0xc0, 0xfa, 0x70, 0xc2, 0x84, 0xeb, 0x85, 0xe3, 0x05, 0xb0, 0x8a,
+	0xc0, 0x6b, 0x7e, 0xd2, 0x83, 0xce, 0x69, 0x24, 0x99, 0x8f, 0x81, 0x04, 0x7f, 0x67, 0x0d, 0x5a,
+	0x76, 0x91, 0x7d, 0xd8, 0xcc, 0xaf, 0xe4, 0x30, 0xf6, 0x3d, 0xb9, 0xa8, 0x58, 0xc7, 0x8a, 0x37,
+	0xc6, 0x5e, 0xcd, 0x39, 0x09, 0x25, 0x17, 0x4f, 0x99, 0x9f, 0x5f, 0xb8, 0x1b, 0x63, 0xe4, 0x2e,
+	0xdc, 0x1a, 0x4a, 0x1e, 0x1f, 0xf8, 0xde, 0x38, 0x5c, 0x34, 0x69, 0x62, 0x93, 0xeb, 0x81, 0xfe,
+	0x43, 0x68, 0xe0, 0x21, 0x24, 0x7d, 0x58, 0x45, 0xb6, 0xea, 0x84, 0x7b, 0x5c, 0x1f, 0xa5, 0x06,
+	0xbd, 0xe2, 0x53, 0x74, 0x8e, 0x13, 0xe9, 0x05, 0x4c, 0x72, 0x77, 0x88, 0xae, 0xc3, 0x28, 0x0d,
+	0xf5, 0xdf, 0xc1, 0x3a, 0xbd, 0x31, 0xe6, 0x1c, 0x9f, 0x5f, 0x58, 0x95, 0x17, 0x17, 0x56, 0xe5,
+	0xe5, 0x85, 0x65, 0x7c, 0x3b, 0xb3, 0x8c, 0x9f, 0x67, 0x96, 0xf1, 0x7c, 0x66, 0x19, 0xe7, 0x33,
+	0xcb, 0xf8, 0x6b, 0x66, 0x19, 0x7f, 0xcf, 0xac, 0xca, 0xcb, 0x99, 0x65, 0x7c, 0x7f, 0x69, 0x55,
+	0xce, 0x2f, 0xad, 0xca, 0x8b, 0x4b, 0xab, 0xf2, 0xd5, 0x1a, 0x2e, 0x62, 0xe0, 0xb9, 0xae, 0xcf,
+	0xbf, 0x61, 0x82, 0x8f, 0x9a, 0xf8, 0xa5, 0xef, 0xfd, 0x33, 0x00, 0x1b, 0xec, 0x55, 0xfe, 0x65,
+	0x08, 0x00, 0x00,
 }
 
 func (this *PrometheusRangeQueryRequest) Equal(that interface{}) bool {
@@ -1100,6 +1119,9 @@ func (this *Options) Equal(that interface{}) bool {
 	if this.InstantSplitInterval != that1.InstantSplitInterval {
 		return false
 	}
+	if this.StepAlignDisabled != that1.StepAlignDisabled {
+		return false
+	}
 	return true
 }
 func (this *Hints) Equal(that interface{}) bool {
@@ -1124,6 +1146,9 @@ func (this *Hints) Equal(that interface{}) bool {
 	if this.TotalQueries != that1.TotalQueries {
 		return false
 	}
+	if this.EstimatedSeriesCount != that1.EstimatedSeriesCount {
+		return false
+	}
 	return true
 }
 func (this *PrometheusRangeQueryRequest) GoString() string {
@@ -1262,13 +1287,14 @@ func (this *Options) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 9)
+	s := make([]string, 0, 10)
 	s = append(s, "&querymiddleware.Options{")
 	s = append(s, "CacheDisabled: "+fmt.Sprintf("%#v", this.CacheDisabled)+",\n")
 	s = append(s, "ShardingDisabled: "+fmt.Sprintf("%#v", this.ShardingDisabled)+",\n")
 	s = append(s, "TotalShards: "+fmt.Sprintf("%#v", this.TotalShards)+",\n")
 	s = append(s, "InstantSplitDisabled: "+fmt.Sprintf("%#v", this.InstantSplitDisabled)+",\n")
 	s = append(s, "InstantSplitInterval: "+fmt.Sprintf("%#v", this.InstantSplitInterval)+",\n")
+	s = append(s, "StepAlignDisabled: "+fmt.Sprintf("%#v", this.StepAlignDisabled)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1276,9 +1302,10 @@ func (this *Hints) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 5)
+	s := make([]string, 0, 6)
 	s = append(s, "&querymiddleware.Hints{")
 	s = append(s, "TotalQueries: "+fmt.Sprintf("%#v", this.TotalQueries)+",\n")
+	s = append(s, "EstimatedSeriesCount: "+fmt.Sprintf("%#v", this.EstimatedSeriesCount)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1766,6 +1793,16 @@ func (m *Options) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.StepAlignDisabled {
+		i--
+		if m.StepAlignDisabled {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x30
+	}
 	if m.InstantSplitInterval != 0 {
 		i = encodeVarintModel(dAtA, i, uint64(m.InstantSplitInterval))
 		i--
@@ -1829,6 +1866,11 @@ func (m *Hints) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.EstimatedSeriesCount != 0 {
+		i = encodeVarintModel(dAtA, i, uint64(m.EstimatedSeriesCount))
+		i--
+		dAtA[i] = 0x10
+	}
 	if m.TotalQueries != 0 {
 		i = encodeVarintModel(dAtA, i, uint64(m.TotalQueries))
 		i--
@@ -2067,6 +2109,9 @@ func (m *Options) Size() (n int) {
 	if m.InstantSplitInterval != 0 {
 		n += 1 + sovModel(uint64(m.InstantSplitInterval))
 	}
+	if m.StepAlignDisabled {
+		n += 2
+	}
 	return n
 }
 
@@ -2079,6 +2124,9 @@ func (m *Hints) Size() (n int) {
 	if m.TotalQueries != 0 {
 		n += 1 + sovModel(uint64(m.TotalQueries))
 	}
+	if m.EstimatedSeriesCount != 0 {
+		n += 1 + sovModel(uint64(m.EstimatedSeriesCount))
+	}
 	return n
 }
 
@@ -2222,6 +2270,7 @@ func (this *Options) String() string {
 		`TotalShards:` + fmt.Sprintf("%v", this.TotalShards) + `,`,
 		`InstantSplitDisabled:` + fmt.Sprintf("%v", this.InstantSplitDisabled) + `,`,
 		`InstantSplitInterval:` + fmt.Sprintf("%v", this.InstantSplitInterval) + `,`,
+		`StepAlignDisabled:` + fmt.Sprintf("%v", this.StepAlignDisabled) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -2232,6 +2281,7 @@ func (this *Hints) String() string {
 	}
 	s := strings.Join([]string{`&Hints{`,
 		`TotalQueries:` + fmt.Sprintf("%v", this.TotalQueries) + `,`,
+		`EstimatedSeriesCount:` + fmt.Sprintf("%v", this.EstimatedSeriesCount) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -3744,6 +3794,26 @@ func (m *Options) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 6:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StepAlignDisabled", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowModel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.StepAlignDisabled = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipModel(dAtA[iNdEx:])
@@ -3816,6 +3886,25 @@ func (m *Hints) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EstimatedSeriesCount", wireType)
+			}
+			m.EstimatedSeriesCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowModel
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.EstimatedSeriesCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipModel(dAtA[iNdEx:])
@@ -0,0 +1,297 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/weaveworks/common/user"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	// queryCostSampleInterval is the interval between the samples of a series assumed to estimate
+	// the number of samples in the range of a range vector selector.
+	queryCostSampleInterval = 15 * time.Second
+
+	// queryCostSubqueryStep is the step assumed for subqueries without an explicit step, matching
+	// the default evaluation interval of the querier.
+	queryCostSubqueryStep = time.Minute
+
+	cardinalityLabelValuesPathSuffix = "/cardinality/label_values"
+)
+
+// seriesCountEstimator estimates the number of series matching a selector.
+type seriesCountEstimator interface {
+	// EstimateSeriesCount returns the estimated number of series of the tenant in ctx matching the matchers.
+	// The path is the path of the query request the estimation is made for.
+	EstimateSeriesCount(ctx context.Context, path string, matchers []*labels.Matcher) (uint64, error)
+}
+
+// cardinalitySeriesCountEstimator estimates the number of series from the cardinality analysis
+// of the ingesters, requested to the label values cardinality API through the downstream round tripper.
+type cardinalitySeriesCountEstimator struct {
+	next http.RoundTripper
+}
+
+func newCardinalitySeriesCountEstimator(next http.RoundTripper) *cardinalitySeriesCountEstimator {
+	return &cardinalitySeriesCountEstimator{next: next}
+}
+
+func (e *cardinalitySeriesCountEstimator) EstimateSeriesCount(ctx context.Context, path string, matchers []*labels.Matcher) (uint64, error) {
+	// Every series has a metric name, so the series count of the metric name label values
+	// is the number of series matching the selector.
+	u := &url.URL{
+		Path: cardinalityLabelValuesPath(path),
+		RawQuery: url.Values{
+			"label_names[]": []string{model.MetricNameLabel},
+			"selector":      []string{"{" + matchersString(matchers) + "}"},
+			"limit":         []string{"1"},
+		}.Encode(),
+	}
+	req := &http.Request{
+		Method:     "GET",
+		RequestURI: u.String(), // This is what the httpgrpc code looks at.
+		URL:        u,
+		Body:       http.NoBody,
+		Header:     http.Header{},
+	}
+	req = req.WithContext(ctx)
+	if err := user.InjectOrgIDIntoHTTPRequest(ctx, req); err != nil {
+		return 0, err
+	}
+
+	resp, err := e.next.RoundTrip(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unexpected status code %d from the label values cardinality API: %s", resp.StatusCode, strings.TrimSpace(string(mustReadAllBody(resp))))
+	}
+
+	var cardinality struct {
+		Labels []struct {
+			SeriesCount uint64 `json:"series_count"`
+		} `json:"labels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&cardinality); err != nil {
+		return 0, errors.Wrap(err, "decode label values cardinality response")
+	}
+
+	var count uint64
+	for _, l := range cardinality.Labels {
+		count += l.SeriesCount
+	}
+	return count, nil
+}
+
+// cardinalityLabelValuesPath returns the path of the label values cardinality API with the same prefix
+// of the query request path.
+func cardinalityLabelValuesPath(queryPath string) string {
+	prefix := strings.TrimSuffix(queryPath, queryRangePathSuffix)
+	if prefix == queryPath {
+		prefix = strings.TrimSuffix(queryPath, instantQueryPathSuffix)
+	}
+	return prefix + cardinalityLabelValuesPathSuffix
+}
+
+type queryCostMiddlewareMetrics struct {
+	rejectedQueries    prometheus.Counter
+	estimationFailures prometheus.Counter
+}
+
+func newQueryCostMiddlewareMetrics(registerer prometheus.Registerer) *queryCostMiddlewareMetrics {
+	return &queryCostMiddlewareMetrics{
+		rejectedQueries: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_query_cost_rejected_queries_total",
+			Help: "Total number of queries rejected by the query-frontend because their estimated cost exceeds the limit.",
+		}),
+		estimationFailures: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_query_cost_estimation_failures_total",
+			Help: "Total number of queries whose cost the query-frontend failed to estimate. These queries are executed.",
+		}),
+	}
+}
+
+// queryCostMiddleware is a Middleware rejecting the queries whose estimated cost exceeds the limit,
+// before they're executed.
+type queryCostMiddleware struct {
+	next      Handler
+	limits    Limits
+	estimator seriesCountEstimator
+	logger    log.Logger
+
+	metrics *queryCostMiddlewareMetrics
+}
+
+// newQueryCostMiddleware creates a new Middleware that enforces the max estimated query cost.
+func newQueryCostMiddleware(estimator seriesCountEstimator, limits Limits, logger log.Logger, metrics *queryCostMiddlewareMetrics) Middleware {
+	if metrics == nil {
+		metrics = newQueryCostMiddlewareMetrics(nil)
+	}
+
+	return MiddlewareFunc(func(next Handler) Handler {
+		return queryCostMiddleware{
+			next:      next,
+			limits:    limits,
+			estimator: estimator,
+			logger:    logger,
+			metrics:   metrics,
+		}
+	})
+}
+
+func (q queryCostMiddleware) Do(ctx context.Context, r Request) (Response, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	maxCost := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, q.limits.MaxEstimatedQueryCost)
+	if maxCost <= 0 {
+		return q.next.Do(ctx, r)
+	}
+
+	spanLog, ctx := spanlogger.NewWithLogger(ctx, q.logger, "queryCostMiddleware.Do")
+	defer spanLog.Finish()
+
+	cost, err := q.estimateCost(ctx, tenantIDs, r)
+	if err != nil {
+		// The cost estimation is best-effort: the query is executed if it fails.
+		q.metrics.estimationFailures.Inc()
+		level.Warn(spanLog).Log("msg", "failed to estimate the query cost, the query will be executed", "query", r.GetQuery(), "err", err)
+		return q.next.Do(ctx, r)
+	}
+
+	level.Debug(spanLog).Log("msg", "estimated the query cost", "query", r.GetQuery(), "cost", cost, "limit", maxCost)
+	if cost > float64(maxCost) {
+		q.metrics.rejectedQueries.Inc()
+		return nil, apierror.New(apierror.TypeExec, validation.NewMaxEstimatedQueryCostError(saturatingInt(cost), maxCost).Error())
+	}
+
+	return q.next.Do(ctx, r)
+}
+
+// estimateCost returns the estimated number of samples processed by the query of r, summing the
+// estimations of all tenants.
+func (q queryCostMiddleware) estimateCost(ctx context.Context, tenantIDs []string, r Request) (float64, error) {
+	expr, err := parser.ParseExpr(r.GetQuery())
+	if err != nil {
+		return 0, err
+	}
+
+	path := ""
+	if p, ok := r.(interface{ GetPath() string }); ok {
+		path = p.GetPath()
+	}
+
+	// Series counts are cached by selector, so that the same selector is estimated only once.
+	seriesCounts := map[string]uint64{}
+	return estimateQueryCost(expr, r.GetStart(), r.GetEnd(), r.GetStep(), func(vs *parser.VectorSelector) (uint64, error) {
+		key := matchersString(vs.LabelMatchers)
+		if count, ok := seriesCounts[key]; ok {
+			return count, nil
+		}
+
+		var count uint64
+		for _, tenantID := range tenantIDs {
+			tenantCount, err := q.estimator.EstimateSeriesCount(user.InjectOrgID(ctx, tenantID), path, vs.LabelMatchers)
+			if err != nil {
+				return 0, errors.Wrapf(err, "estimate the series count of %s", vs.String())
+			}
+			count += tenantCount
+		}
+
+		seriesCounts[key] = count
+		return count, nil
+	})
+}
+
+// estimateQueryCost returns the estimated number of samples processed by the expression evaluated from start
+// to end (in milliseconds) every step, or once at start if step is 0. The samples processed by each vector
+// selector are the number of series, returned by seriesCount, multiplied by the number of evaluations of the
+// selector and by the samples selected by each evaluation: one sample for instant vector selectors, and the
+// samples in the range for range vector selectors.
+func estimateQueryCost(expr parser.Expr, start, end, step int64, seriesCount func(*parser.VectorSelector) (uint64, error)) (float64, error) {
+	steps := float64(1)
+	if step > 0 && end > start {
+		steps = float64((end-start)/step + 1)
+	}
+
+	var (
+		cost     float64
+		firstErr error
+	)
+	parser.Inspect(expr, func(node parser.Node, path []parser.Node) error {
+		vs, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+
+		series, err := seriesCount(vs)
+		if err != nil {
+			// Returning the error stops the inspection.
+			firstErr = err
+			return err
+		}
+
+		evaluations := steps
+		samples := float64(1)
+		for i, parent := range path {
+			switch parent := parent.(type) {
+			case *parser.SubqueryExpr:
+				subqueryStep := parent.Step
+				if subqueryStep <= 0 {
+					subqueryStep = queryCostSubqueryStep
+				}
+				evaluations *= math.Max(1, float64(parent.Range/subqueryStep))
+			case *parser.MatrixSelector:
+				// The vector selector is the direct child of the matrix selector.
+				if i == len(path)-1 {
+					samples = math.Max(1, float64(parent.Range/queryCostSampleInterval))
+				}
+			}
+		}
+
+		cost += float64(series) * evaluations * samples
+		return nil
+	})
+	return cost, firstErr
+}
+
+// matchersString returns the comma-separated matchers, identifying the series selected by them.
+func matchersString(matchers []*labels.Matcher) string {
+	parts := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		parts = append(parts, m.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// saturatingInt returns v as int, capped to the max int value.
+func saturatingInt(v float64) int {
+	if v >= math.MaxInt {
+		return math.MaxInt
+	}
+	return int(v)
+}
@@ -0,0 +1,276 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+func TestEstimateQueryCost(t *testing.T) {
+	const (
+		start = int64(0)
+		end   = int64(time.Hour / time.Millisecond)
+		step  = int64(time.Minute / time.Millisecond)
+	)
+
+	seriesCounts := map[string]uint64{
+		"up":                        10,
+		"http_requests_total":       100,
+		"process_cpu_seconds_total": 5,
+	}
+
+	for name, test := range map[string]struct {
+		query        string
+		step         int64
+		expectedCost float64
+	}{
+		"instant vector selector in instant query": {
+			query:        `up`,
+			expectedCost: 10,
+		},
+		"instant vector selector in range query": {
+			query:        `up`,
+			step:         step,
+			expectedCost: 10 * 61,
+		},
+		"range vector selector in instant query": {
+			query:        `rate(http_requests_total[5m])`,
+			expectedCost: 100 * 20,
+		},
+		"range vector selector in range query": {
+			query:        `sum(rate(http_requests_total[5m]))`,
+			step:         step,
+			expectedCost: 100 * 61 * 20,
+		},
+		"range vector selector shorter than the sample interval": {
+			query:        `rate(http_requests_total[5s])`,
+			expectedCost: 100,
+		},
+		"binary expression": {
+			query:        `up + on() group_left process_cpu_seconds_total`,
+			expectedCost: 10 + 5,
+		},
+		"subquery with step": {
+			query:        `max_over_time(rate(http_requests_total[1m])[10m:30s])`,
+			expectedCost: 100 * 20 * 4,
+		},
+		"subquery without step": {
+			query:        `max_over_time(up[10m:])`,
+			step:         step,
+			expectedCost: 10 * 61 * 10,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			expr, err := parser.ParseExpr(test.query)
+			require.NoError(t, err)
+
+			queryEnd := start
+			if test.step > 0 {
+				queryEnd = end
+			}
+
+			cost, err := estimateQueryCost(expr, start, queryEnd, test.step, func(vs *parser.VectorSelector) (uint64, error) {
+				return seriesCounts[vs.Name], nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCost, cost)
+		})
+	}
+
+	t.Run("should return the series count estimation error", func(t *testing.T) {
+		expr, err := parser.ParseExpr(`up + process_cpu_seconds_total`)
+		require.NoError(t, err)
+
+		_, err = estimateQueryCost(expr, start, end, step, func(vs *parser.VectorSelector) (uint64, error) {
+			return 0, errors.New("estimation failed")
+		})
+		require.EqualError(t, err, "estimation failed")
+	})
+}
+
+func TestQueryCostMiddleware(t *testing.T) {
+	// Set a multi tenant resolver, restoring the default one at the end of the test.
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	for name, test := range map[string]struct {
+		orgID            string
+		query            string
+		maxCost          int
+		estimatorErr     error
+		expectedRejected bool
+		expectedFailures int
+		expectedCalls    int
+	}{
+		"should not estimate the cost if the limit is disabled": {
+			orgID: "tenant-1",
+			query: `sum(rate(http_requests_total[5m]))`,
+		},
+		"should execute a query whose cost is within the limit": {
+			orgID:         "tenant-1",
+			query:         `sum(rate(http_requests_total[5m]))`,
+			maxCost:       100 * 20,
+			expectedCalls: 1,
+		},
+		"should reject a query whose cost exceeds the limit": {
+			orgID:            "tenant-1",
+			query:            `sum(rate(http_requests_total[5m]))`,
+			maxCost:          100*20 - 1,
+			expectedRejected: true,
+			expectedCalls:    1,
+		},
+		"should estimate the same selector only once": {
+			orgID:         "tenant-1",
+			query:         `rate(http_requests_total[5m]) / rate(http_requests_total[1m])`,
+			maxCost:       100*20 + 100*4,
+			expectedCalls: 1,
+		},
+		"should sum the cost of all tenants": {
+			orgID:            "tenant-1|tenant-2",
+			query:            `sum(rate(http_requests_total[5m]))`,
+			maxCost:          100*20 + 1,
+			expectedRejected: true,
+			expectedCalls:    2,
+		},
+		"should execute the query if the cost can't be estimated": {
+			orgID:            "tenant-1",
+			query:            `sum(rate(http_requests_total[5m]))`,
+			maxCost:          1,
+			estimatorErr:     errors.New("cardinality analysis is disabled"),
+			expectedFailures: 1,
+			expectedCalls:    1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			estimator := &mockSeriesCountEstimator{seriesCount: 100, err: test.estimatorErr}
+			metrics := newQueryCostMiddlewareMetrics(prometheus.NewPedanticRegistry())
+			limits := mockLimits{maxEstimatedQueryCost: test.maxCost}
+
+			downstreamCalled := false
+			downstream := HandlerFunc(func(context.Context, Request) (Response, error) {
+				downstreamCalled = true
+				return &PrometheusResponse{Status: statusSuccess}, nil
+			})
+
+			req := &PrometheusInstantQueryRequest{
+				Path:  "/api/v1/query",
+				Time:  util.TimeToMillis(time.Now()),
+				Query: test.query,
+			}
+			ctx := user.InjectOrgID(context.Background(), test.orgID)
+
+			mw := newQueryCostMiddleware(estimator, limits, log.NewNopLogger(), metrics)
+			_, err := mw.Wrap(downstream).Do(ctx, req)
+
+			assert.Equal(t, test.expectedCalls, estimator.callsCount())
+			if test.expectedRejected {
+				require.Error(t, err)
+				assert.False(t, downstreamCalled)
+				assert.True(t, apierror.IsAPIError(err))
+				assert.Contains(t, err.Error(), "the estimated query cost exceeds the limit")
+				assert.Contains(t, err.Error(), "err-mimir-max-estimated-query-cost")
+
+				// The query is rejected with the same status code of the other query limits.
+				resp, ok := apierror.HTTPResponseFromError(err)
+				require.True(t, ok)
+				assert.Equal(t, int32(http.StatusUnprocessableEntity), resp.Code)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, downstreamCalled)
+			}
+
+			rejected := 0
+			if test.expectedRejected {
+				rejected = 1
+			}
+			assert.Equal(t, float64(rejected), testutil.ToFloat64(metrics.rejectedQueries))
+			assert.Equal(t, float64(test.expectedFailures), testutil.ToFloat64(metrics.estimationFailures))
+		})
+	}
+}
+
+func TestCardinalitySeriesCountEstimator(t *testing.T) {
+	var req *http.Request
+	estimator := newCardinalitySeriesCountEstimator(RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		req = r
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body: io.NopCloser(strings.NewReader(`{
+				"series_count_total": 1000,
+				"labels": [{"label_name": "__name__", "label_values_count": 2, "series_count": 150, "cardinality": [{"label_value": "metric_a", "series_count": 100}]}]
+			}`)),
+		}, nil
+	}))
+
+	matchers := []*labels.Matcher{
+		labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, "metric_.*"),
+		labels.MustNewMatcher(labels.MatchEqual, "job", "test"),
+	}
+	count, err := estimator.EstimateSeriesCount(user.InjectOrgID(context.Background(), "tenant-1"), "/prometheus/api/v1/query_range", matchers)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(150), count)
+
+	require.NotNil(t, req)
+	assert.Equal(t, "/prometheus/api/v1/cardinality/label_values", req.URL.Path)
+	assert.Equal(t, []string{labels.MetricName}, req.URL.Query()["label_names[]"])
+	assert.Equal(t, `{__name__=~"metric_.*",job="test"}`, req.URL.Query().Get("selector"))
+	assert.Equal(t, "tenant-1", req.Header.Get(user.OrgIDHeaderName))
+
+	t.Run("should fail on non successful response", func(t *testing.T) {
+		estimator := newCardinalitySeriesCountEstimator(RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusBadRequest,
+				Body:       io.NopCloser(strings.NewReader("cardinality analysis is disabled for the tenant: tenant-1")),
+			}, nil
+		}))
+
+		_, err := estimator.EstimateSeriesCount(user.InjectOrgID(context.Background(), "tenant-1"), "/api/v1/query", matchers)
+		require.EqualError(t, err, "unexpected status code 400 from the label values cardinality API: cardinality analysis is disabled for the tenant: tenant-1")
+	})
+}
+
+func TestCardinalityLabelValuesPath(t *testing.T) {
+	assert.Equal(t, "/api/v1/cardinality/label_values", cardinalityLabelValuesPath("/api/v1/query"))
+	assert.Equal(t, "/prometheus/api/v1/cardinality/label_values", cardinalityLabelValuesPath("/prometheus/api/v1/query_range"))
+}
+
+type mockSeriesCountEstimator struct {
+	seriesCount uint64
+	err         error
+
+	mtx   sync.Mutex
+	calls int
+}
+
+func (m *mockSeriesCountEstimator) EstimateSeriesCount(context.Context, string, []*labels.Matcher) (uint64, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.calls++
+	return m.seriesCount, m.err
+}
+
+func (m *mockSeriesCountEstimator) callsCount() int {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.calls
+}
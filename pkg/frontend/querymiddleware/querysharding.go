@@ -109,7 +109,7 @@ func (s *querySharding) Do(ctx context.Context, r Request) (Response, error) {
 	}
 
 	s.shardingAttempts.Inc()
-	shardedQuery, shardingStats, err := s.shardQuery(ctx, r.GetQuery(), totalShards)
+	shardedQuery, shardingStats, err := s.shardQuery(ctx, r.GetQuery(), totalShards, s.isVerticalShardingEnabled(tenantIDs))
 
 	// If an error occurred while trying to rewrite the query or the query has not been sharded,
 	// then we should fallback to execute it via queriers.
@@ -226,12 +226,12 @@ func mapEngineError(err error) error {
 // shardQuery attempts to rewrite the input query in a shardable way. Returns the rewritten query
 // to be executed by PromQL engine with shardedQueryable or an empty string if the input query
 // can't be sharded.
-func (s *querySharding) shardQuery(ctx context.Context, query string, totalShards int) (string, *astmapper.MapperStats, error) {
+func (s *querySharding) shardQuery(ctx context.Context, query string, totalShards int, verticalSharding bool) (string, *astmapper.MapperStats, error) {
 	stats := astmapper.NewMapperStats()
 	ctx, cancel := context.WithTimeout(ctx, shardingTimeout)
 	defer cancel()
 
-	mapper, err := astmapper.NewSharding(ctx, totalShards, s.logger, stats)
+	mapper, err := astmapper.NewSharding(ctx, totalShards, verticalSharding, s.logger, stats)
 	if err != nil {
 		return "", nil, err
 	}
@@ -249,6 +249,17 @@ func (s *querySharding) shardQuery(ctx context.Context, query string, totalShard
 	return shardedQuery.String(), stats, nil
 }
 
+// isVerticalShardingEnabled returns whether the queries without aggregations should be sharded by series.
+// When querying multiple tenants, it's enabled only if it's enabled for all of them.
+func (s *querySharding) isVerticalShardingEnabled(tenantIDs []string) bool {
+	for _, tenantID := range tenantIDs {
+		if !s.limit.QueryShardingVerticalEnabled(tenantID) {
+			return false
+		}
+	}
+	return len(tenantIDs) > 0
+}
+
 // getShardsForQuery calculates and return the number of shards that should be used to run the query.
 func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []string, r Request, spanLog log.Logger) int {
 	// Check if sharding is disabled for the given request.
@@ -262,13 +273,31 @@ func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []strin
 		return 1
 	}
 
-	// Honor the number of shards specified in the request (if any).
+	hints := r.GetHints()
+
+	// Honor the number of shards specified in the request (if any). Otherwise, if the series count
+	// of the query has been estimated, pick the number of shards needed to get the target number of
+	// series per shard, up to the default number of shards.
 	if r.GetOptions().TotalShards > 0 {
 		totalShards = int(r.GetOptions().TotalShards)
+	} else if targetSeriesPerShard := validation.SmallestPositiveIntPerTenant(tenantIDs, s.limit.QueryShardingTargetSeriesPerShard); targetSeriesPerShard > 0 && hints.GetEstimatedSeriesCount() > 0 {
+		prevTotalShards := totalShards
+		estimatedShards := (hints.GetEstimatedSeriesCount() + uint64(targetSeriesPerShard) - 1) / uint64(targetSeriesPerShard)
+		if estimatedShards < uint64(totalShards) {
+			totalShards = int(estimatedShards)
+		}
+
+		if prevTotalShards != totalShards {
+			level.Debug(spanLog).Log(
+				"msg", "number of shards has been adjusted to the estimated series count",
+				"updated total shards", totalShards,
+				"previous total shards", prevTotalShards,
+				"estimated series count", hints.GetEstimatedSeriesCount(),
+				"target series per shard", targetSeriesPerShard)
+		}
 	}
 
 	maxShardedQueries := validation.SmallestPositiveIntPerTenant(tenantIDs, s.limit.QueryShardingMaxShardedQueries)
-	hints := r.GetHints()
 
 	// If total queries is provided through hints, then we adjust the number of shards for the query
 	// based on the configured max sharded queries limit.
@@ -286,7 +315,7 @@ func (s *querySharding) getShardsForQuery(ctx context.Context, tenantIDs []strin
 		// - count(metric)
 		//
 		// Calling s.shardQuery() with 1 total shards we can see how many shardable legs the query has.
-		_, shardingStats, err := s.shardQuery(ctx, r.GetQuery(), 1)
+		_, shardingStats, err := s.shardQuery(ctx, r.GetQuery(), 1, s.isVerticalShardingEnabled(tenantIDs))
 		numShardableLegs := 1
 		if err == nil && shardingStats.GetShardedQueries() > 0 {
 			numShardableLegs = shardingStats.GetShardedQueries()
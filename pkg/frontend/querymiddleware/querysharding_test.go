@@ -872,52 +872,56 @@ func TestQuerySharding_FunctionCorrectness(t *testing.T) {
 	for _, tc := range tests {
 		const numShards = 4
 		for _, query := range mkQueries(tc.tpl, tc.fn, tc.rangeQuery, tc.args) {
-			t.Run(query, func(t *testing.T) {
-				queryable := storageSeriesQueryable([]*promql.StorageSeries{
-					newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blop", "foo", "barr"), start.Add(-lookbackDelta), end, step, factor(5)),
-					newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blop", "foo", "bazz"), start.Add(-lookbackDelta), end, step, factor(7)),
-					newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blap", "foo", "buzz"), start.Add(-lookbackDelta), end, step, factor(12)),
-					newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blap", "foo", "bozz"), start.Add(-lookbackDelta), end, step, factor(11)),
-					newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blop", "foo", "buzz"), start.Add(-lookbackDelta), end, step, factor(8)),
-					newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blap", "foo", "bazz"), start.Add(-lookbackDelta), end, step, arithmeticSequence(10)),
-				})
-
-				req := &PrometheusRangeQueryRequest{
-					Path:  "/query_range",
-					Start: util.TimeToMillis(start),
-					End:   util.TimeToMillis(end),
-					Step:  step.Milliseconds(),
-					Query: query,
-				}
+			for _, verticalShardingEnabled := range []bool{false, true} {
+				query, verticalShardingEnabled := query, verticalShardingEnabled
+
+				t.Run(fmt.Sprintf("%s (vertical sharding enabled: %t)", query, verticalShardingEnabled), func(t *testing.T) {
+					queryable := storageSeriesQueryable([]*promql.StorageSeries{
+						newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blop", "foo", "barr"), start.Add(-lookbackDelta), end, step, factor(5)),
+						newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blop", "foo", "bazz"), start.Add(-lookbackDelta), end, step, factor(7)),
+						newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blap", "foo", "buzz"), start.Add(-lookbackDelta), end, step, factor(12)),
+						newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blap", "foo", "bozz"), start.Add(-lookbackDelta), end, step, factor(11)),
+						newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blop", "foo", "buzz"), start.Add(-lookbackDelta), end, step, factor(8)),
+						newSeries(labels.FromStrings("__name__", "bar1", "baz", "blip", "bar", "blap", "foo", "bazz"), start.Add(-lookbackDelta), end, step, arithmeticSequence(10)),
+					})
+
+					req := &PrometheusRangeQueryRequest{
+						Path:  "/query_range",
+						Start: util.TimeToMillis(start),
+						End:   util.TimeToMillis(end),
+						Step:  step.Milliseconds(),
+						Query: query,
+					}
 
-				reg := prometheus.NewPedanticRegistry()
-				engine := newEngine()
-				shardingware := newQueryShardingMiddleware(
-					log.NewNopLogger(),
-					engine,
-					mockLimits{totalShards: numShards},
-					reg,
-				)
-				downstream := &downstreamHandler{
-					engine:    engine,
-					queryable: queryable,
-				}
+					reg := prometheus.NewPedanticRegistry()
+					engine := newEngine()
+					shardingware := newQueryShardingMiddleware(
+						log.NewNopLogger(),
+						engine,
+						mockLimits{totalShards: numShards, verticalShardingEnabled: verticalShardingEnabled},
+						reg,
+					)
+					downstream := &downstreamHandler{
+						engine:    engine,
+						queryable: queryable,
+					}
 
-				// Run the query without sharding.
-				expectedRes, err := downstream.Do(context.Background(), req)
-				require.Nil(t, err)
+					// Run the query without sharding.
+					expectedRes, err := downstream.Do(context.Background(), req)
+					require.Nil(t, err)
 
-				// Ensure the query produces some results.
-				require.NotEmpty(t, expectedRes.(*PrometheusResponse).Data.Result)
+					// Ensure the query produces some results.
+					require.NotEmpty(t, expectedRes.(*PrometheusResponse).Data.Result)
 
-				// Run the query with sharding.
-				shardedRes, err := shardingware.Wrap(downstream).Do(user.InjectOrgID(context.Background(), "test"), req)
-				require.Nil(t, err)
+					// Run the query with sharding.
+					shardedRes, err := shardingware.Wrap(downstream).Do(user.InjectOrgID(context.Background(), "test"), req)
+					require.Nil(t, err)
 
-				// Ensure the two results matches (float precision can slightly differ, there's no guarantee in PromQL engine too
-				// if you rerun the same query twice).
-				approximatelyEquals(t, expectedRes.(*PrometheusResponse), shardedRes.(*PrometheusResponse))
-			})
+					// Ensure the two results matches (float precision can slightly differ, there's no guarantee in PromQL engine too
+					// if you rerun the same query twice).
+					approximatelyEquals(t, expectedRes.(*PrometheusResponse), shardedRes.(*PrometheusResponse))
+				})
+			}
 		}
 	}
 
@@ -1022,14 +1026,52 @@ func TestQuerySharding_ShouldOverrideShardingSizeViaOption(t *testing.T) {
 	downstream.AssertNumberOfCalls(t, "Do", 128)
 }
 
+func TestQuerySharding_ShouldShardVerticallyNonAggregatedQueriesOnlyIfEnabled(t *testing.T) {
+	for _, verticalShardingEnabled := range []bool{false, true} {
+		verticalShardingEnabled := verticalShardingEnabled
+
+		t.Run(fmt.Sprintf("vertical sharding enabled: %t", verticalShardingEnabled), func(t *testing.T) {
+			req := &PrometheusRangeQueryRequest{
+				Path:  "/query_range",
+				Start: util.TimeToMillis(start),
+				End:   util.TimeToMillis(end),
+				Step:  step.Milliseconds(),
+				Query: "rate(bar{}[1m])", // non-aggregated query.
+			}
+
+			shardingware := newQueryShardingMiddleware(log.NewNopLogger(), newEngine(), mockLimits{totalShards: 16, verticalShardingEnabled: verticalShardingEnabled}, nil)
+
+			downstream := &mockHandler{}
+			downstream.On("Do", mock.Anything, mock.Anything).Return(&PrometheusResponse{
+				Status: statusSuccess, Data: &PrometheusData{
+					ResultType: string(parser.ValueTypeVector),
+				},
+			}, nil)
+
+			res, err := shardingware.Wrap(downstream).Do(user.InjectOrgID(context.Background(), "test"), req)
+			require.NoError(t, err)
+			assert.Equal(t, statusSuccess, res.(*PrometheusResponse).GetStatus())
+
+			if verticalShardingEnabled {
+				downstream.AssertNumberOfCalls(t, "Do", 16)
+			} else {
+				// Ensure we get the same request downstream. No sharding
+				downstream.AssertCalled(t, "Do", mock.Anything, req)
+				downstream.AssertNumberOfCalls(t, "Do", 1)
+			}
+		})
+	}
+}
+
 func TestQuerySharding_ShouldSupportMaxShardedQueries(t *testing.T) {
 	tests := map[string]struct {
-		query             string
-		hints             *Hints
-		totalShards       int
-		maxShardedQueries int
-		expectedShards    int
-		compactorShards   int
+		query                string
+		hints                *Hints
+		totalShards          int
+		maxShardedQueries    int
+		targetSeriesPerShard int
+		expectedShards       int
+		compactorShards      int
 	}{
 		"query is not shardable": {
 			query:             "metric",
@@ -1121,6 +1163,45 @@ func TestQuerySharding_ShouldSupportMaxShardedQueries(t *testing.T) {
 			maxShardedQueries: 64,
 			expectedShards:    1,
 		},
+		"estimated series count lower than the target series per shard": {
+			query:                "sum(metric)",
+			hints:                &Hints{TotalQueries: 1, EstimatedSeriesCount: 500},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 1000,
+			expectedShards:       1,
+		},
+		"estimated series count requiring less shards than the total shards": {
+			query:                "sum(metric)",
+			hints:                &Hints{TotalQueries: 1, EstimatedSeriesCount: 5500},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 1000,
+			expectedShards:       6,
+		},
+		"estimated series count requiring more shards than the total shards": {
+			query:                "sum(metric)",
+			hints:                &Hints{TotalQueries: 1, EstimatedSeriesCount: 100000},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 1000,
+			expectedShards:       16,
+		},
+		"estimated series count and max sharded queries": {
+			query:                "sum(metric)",
+			hints:                &Hints{TotalQueries: 10, EstimatedSeriesCount: 8000},
+			totalShards:          16,
+			maxShardedQueries:    64,
+			targetSeriesPerShard: 1000,
+			expectedShards:       6,
+		},
+		"estimated series count ignored if the target series per shard is disabled": {
+			query:             "sum(metric)",
+			hints:             &Hints{TotalQueries: 1, EstimatedSeriesCount: 500},
+			totalShards:       16,
+			maxShardedQueries: 64,
+			expectedShards:    16,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -1135,9 +1216,10 @@ func TestQuerySharding_ShouldSupportMaxShardedQueries(t *testing.T) {
 			}
 
 			limits := mockLimits{
-				totalShards:       testData.totalShards,
-				maxShardedQueries: testData.maxShardedQueries,
-				compactorShards:   testData.compactorShards,
+				totalShards:          testData.totalShards,
+				maxShardedQueries:    testData.maxShardedQueries,
+				targetSeriesPerShard: testData.targetSeriesPerShard,
+				compactorShards:      testData.compactorShards,
 			}
 			shardingware := newQueryShardingMiddleware(log.NewNopLogger(), newEngine(), limits, nil)
 
@@ -0,0 +1,477 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/snappy"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/util"
+	util_math "github.com/grafana/mimir/pkg/util/math"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const remoteReadPathSuffix = "/api/v1/read"
+
+func isRemoteRead(path string) bool {
+	return strings.HasSuffix(path, remoteReadPathSuffix)
+}
+
+type remoteReadMetrics struct {
+	splitQueriesCount  prometheus.Counter
+	cachedQueriesCount prometheus.Counter
+}
+
+func newRemoteReadMetrics(reg prometheus.Registerer) *remoteReadMetrics {
+	return &remoteReadMetrics{
+		splitQueriesCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_remote_read_split_queries_total",
+			Help: "Total number of underlying remote read queries after the split by interval is applied.",
+		}),
+		cachedQueriesCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_remote_read_cached_queries_total",
+			Help: "Total number of underlying remote read queries whose result has been fetched from the results cache.",
+		}),
+	}
+}
+
+// remoteReadRoundTripper is a http.RoundTripper for the remote read requests. It enforces the same limits
+// of the range queries to each query of the request, splits the queries by interval, runs the split queries
+// through the results cache and merges their results. The remote read requests which don't accept the
+// samples response type are sent downstream as is.
+type remoteReadRoundTripper struct {
+	next          http.RoundTripper
+	limits        Limits
+	splitInterval time.Duration
+	cache         cache.Cache // Nil if the results cache is disabled.
+	logger        log.Logger
+	metrics       *remoteReadMetrics
+}
+
+func newRemoteReadRoundTripper(next http.RoundTripper, limits Limits, splitInterval time.Duration, c cache.Cache, logger log.Logger, metrics *remoteReadMetrics) http.RoundTripper {
+	return &remoteReadRoundTripper{
+		next:          next,
+		limits:        limits,
+		splitInterval: splitInterval,
+		cache:         c,
+		logger:        logger,
+		metrics:       metrics,
+	}
+}
+
+// remoteReadSplitQuery is a query of the remote read request, split by interval.
+type remoteReadSplitQuery struct {
+	// Index of the query in the remote read request.
+	queryIdx int
+
+	query    *prompb.Query
+	cacheKey string // Empty if the query result is not cacheable.
+	result   *prompb.QueryResult
+}
+
+func (rt *remoteReadRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	spanLog, ctx := spanlogger.NewWithLogger(r.Context(), rt.logger, "remoteReadRoundTripper.RoundTrip")
+	defer spanLog.Finish()
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	req, err := remote.DecodeReadRequest(r)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	if respType, err := remote.NegotiateResponseType(req.AcceptedResponseTypes); err != nil || respType != prompb.ReadRequest_SAMPLES {
+		// Only the samples responses can be split and cached, so the request is sent downstream as is.
+		return rt.next.RoundTrip(withRemoteReadRequest(r, req))
+	}
+
+	var opts Options
+	decodeOptions(r, &opts)
+
+	var splits []*remoteReadSplitQuery
+	for idx, query := range req.Queries {
+		query, err := rt.applyLimits(spanLog, tenantIDs, query)
+		if err != nil {
+			return nil, err
+		}
+		if query == nil {
+			// The query is fully outside the allowed time range, so its result is empty.
+			continue
+		}
+
+		for _, split := range splitRemoteReadQueryByInterval(query, rt.splitInterval) {
+			splits = append(splits, &remoteReadSplitQuery{
+				queryIdx: idx,
+				query:    split,
+				cacheKey: rt.cacheKey(tenantIDs, split, opts),
+			})
+		}
+	}
+	rt.metrics.splitQueriesCount.Add(float64(len(splits)))
+
+	rt.fetchCachedResults(ctx, splits)
+
+	var downstream []*remoteReadSplitQuery
+	for _, split := range splits {
+		if split.result == nil {
+			downstream = append(downstream, split)
+		}
+	}
+	rt.metrics.cachedQueriesCount.Add(float64(len(splits) - len(downstream)))
+	spanLog.LogKV("split queries", len(splits), "cached queries", len(splits)-len(downstream))
+
+	// The amount of concurrent downstream requests is limited by the MaxQueryParallelism tenant setting.
+	parallelism := validation.SmallestPositiveIntPerTenant(tenantIDs, rt.limits.MaxQueryParallelism)
+	var (
+		errRespMtx sync.Mutex
+		errResp    *http.Response
+	)
+	err = concurrency.ForEachJob(ctx, len(downstream), parallelism, func(ctx context.Context, idx int) error {
+		split := downstream[idx]
+
+		subReq := &prompb.ReadRequest{
+			Queries:               []*prompb.Query{split.query},
+			AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{prompb.ReadRequest_SAMPLES},
+		}
+		resp, err := rt.next.RoundTrip(withRemoteReadRequest(r.WithContext(ctx), subReq))
+		if err != nil {
+			return err
+		}
+
+		result, err := decodeRemoteReadResponse(resp)
+		if err != nil {
+			return err
+		}
+		if result == nil {
+			// The downstream request failed, and the first failed response is returned as is.
+			errRespMtx.Lock()
+			defer errRespMtx.Unlock()
+			if errResp == nil {
+				errResp = resp
+			} else {
+				_ = resp.Body.Close()
+			}
+			return errRemoteReadDownstreamFailed
+		}
+		split.result = result
+		return nil
+	})
+	if errResp != nil {
+		return errResp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rt.storeCachedResults(ctx, tenantIDs, downstream)
+
+	return encodeRemoteReadResponse(mergeRemoteReadResults(len(req.Queries), splits))
+}
+
+var errRemoteReadDownstreamFailed = errors.New("remote read downstream request failed")
+
+// applyLimits enforces the limits of the range queries on the query time range. Returns the query
+// with the time range manipulated according to the limits, or nil if the query is fully outside the
+// allowed time range.
+func (rt *remoteReadRoundTripper) applyLimits(spanLog *spanlogger.SpanLogger, tenantIDs []string, query *prompb.Query) (*prompb.Query, error) {
+	start, end := query.StartTimestampMs, query.EndTimestampMs
+
+	// Clamp the time range based on the max query lookback and block retention period.
+	blocksRetentionPeriod := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, rt.limits.CompactorBlocksRetentionPeriod)
+	maxQueryLookback := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, rt.limits.MaxQueryLookback)
+	if maxLookback := smallestPositiveNonZeroDuration(blocksRetentionPeriod, maxQueryLookback); maxLookback > 0 {
+		minStartTime := util.TimeToMillis(time.Now().Add(-maxLookback))
+		if end < minStartTime {
+			level.Debug(spanLog).Log("msg", "skipping the remote read query because its time range is before the 'max query lookback' or 'blocks retention period' setting", "reqStart", util.FormatTimeMillis(start), "reqEnd", util.FormatTimeMillis(end))
+			return nil, nil
+		}
+		start = util_math.Max64(start, minStartTime)
+	}
+
+	// Enforce the max end time, based on the creation grace period and max query into future.
+	creationGracePeriod := validation.LargestPositiveNonZeroDurationPerTenant(tenantIDs, rt.limits.CreationGracePeriod)
+	maxQueryIntoFuture := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, rt.limits.MaxQueryIntoFuture)
+	if maxIntoFuture := smallestPositiveNonZeroDuration(creationGracePeriod, maxQueryIntoFuture); maxIntoFuture > 0 {
+		maxEndTime := util.TimeToMillis(time.Now().Add(maxIntoFuture))
+		if start > maxEndTime {
+			level.Debug(spanLog).Log("msg", "skipping the remote read query because its time range is after the 'creation grace period' or 'max query into future' setting", "reqStart", util.FormatTimeMillis(start), "reqEnd", util.FormatTimeMillis(end))
+			return nil, nil
+		}
+		end = util_math.Min64(end, maxEndTime)
+	}
+
+	// Enforce the max query length.
+	if maxQueryLength := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, rt.limits.MaxTotalQueryLength); maxQueryLength > 0 {
+		queryLen := timestamp.Time(end).Sub(timestamp.Time(start))
+		if queryLen > maxQueryLength {
+			return nil, apierror.New(apierror.TypeBadData, validation.NewMaxTotalQueryLengthError(queryLen, maxQueryLength).Error())
+		}
+	}
+
+	if start == query.StartTimestampMs && end == query.EndTimestampMs {
+		return query, nil
+	}
+	return withRemoteReadQueryStartEnd(query, start, end), nil
+}
+
+// splitRemoteReadQueryByInterval splits the query into queries whose time range doesn't cross
+// the interval boundaries. The remote read time range includes both the start and end.
+func splitRemoteReadQueryByInterval(query *prompb.Query, interval time.Duration) []*prompb.Query {
+	if interval <= 0 {
+		return []*prompb.Query{query}
+	}
+
+	var splits []*prompb.Query
+	for start := query.StartTimestampMs; start <= query.EndTimestampMs; {
+		end := util_math.Min64(((start/interval.Milliseconds())+1)*interval.Milliseconds()-1, query.EndTimestampMs)
+		splits = append(splits, withRemoteReadQueryStartEnd(query, start, end))
+		start = end + 1
+	}
+	return splits
+}
+
+func withRemoteReadQueryStartEnd(query *prompb.Query, start, end int64) *prompb.Query {
+	split := *query
+	split.StartTimestampMs = start
+	split.EndTimestampMs = end
+	if query.Hints != nil {
+		hints := *query.Hints
+		hints.StartMs = start
+		hints.EndMs = end
+		split.Hints = &hints
+	}
+	return &split
+}
+
+// cacheKey returns the results cache key of the split query, or an empty string if its result is not cacheable.
+func (rt *remoteReadRoundTripper) cacheKey(tenantIDs []string, query *prompb.Query, opts Options) string {
+	if rt.cache == nil || opts.CacheDisabled {
+		return ""
+	}
+
+	// Very recent results are not cached, because they could change.
+	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, rt.limits.MaxCacheFreshness)
+	if query.EndTimestampMs > util.TimeToMillis(time.Now().Add(-maxCacheFreshness)) {
+		return ""
+	}
+
+	data, err := query.Marshal()
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("rr:%s:%s", tenant.JoinTenantIDs(tenantIDs), cacheHashKey(string(data)))
+}
+
+// fetchCachedResults sets the results of the split queries found in the results cache.
+func (rt *remoteReadRoundTripper) fetchCachedResults(ctx context.Context, splits []*remoteReadSplitQuery) {
+	var hashedKeys []string
+	byHashedKey := map[string][]*remoteReadSplitQuery{}
+	for _, split := range splits {
+		if split.cacheKey == "" {
+			continue
+		}
+		hashed := cacheHashKey(split.cacheKey)
+		if _, ok := byHashedKey[hashed]; !ok {
+			hashedKeys = append(hashedKeys, hashed)
+		}
+		byHashedKey[hashed] = append(byHashedKey[hashed], split)
+	}
+	if len(hashedKeys) == 0 {
+		return
+	}
+
+	for hashed, data := range rt.cache.Fetch(ctx, hashedKeys) {
+		for _, split := range byHashedKey[hashed] {
+			var cached CachedResponse
+			if err := proto.Unmarshal(data, &cached); err != nil {
+				level.Warn(rt.logger).Log("msg", "error unmarshalling cached remote read result", "err", err)
+				break
+			}
+
+			// Ensure there's no hashed key collision.
+			if cached.Key != split.cacheKey || len(cached.Extents) != 1 {
+				continue
+			}
+
+			var result prompb.QueryResult
+			if err := types.UnmarshalAny(cached.Extents[0].Response, &result); err != nil {
+				level.Warn(rt.logger).Log("msg", "error unmarshalling cached remote read result", "err", err)
+				continue
+			}
+			split.result = &result
+		}
+	}
+}
+
+// storeCachedResults stores the results of the cacheable split queries in the results cache.
+func (rt *remoteReadRoundTripper) storeCachedResults(ctx context.Context, tenantIDs []string, splits []*remoteReadSplitQuery) {
+	maxSize := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, rt.limits.ResultsCacheMaxItemSizeBytes)
+
+	for _, split := range splits {
+		if split.cacheKey == "" {
+			continue
+		}
+
+		any, err := types.MarshalAny(split.result)
+		if err != nil {
+			level.Warn(rt.logger).Log("msg", "error marshalling remote read result", "err", err)
+			continue
+		}
+		data, err := proto.Marshal(&CachedResponse{
+			Key: split.cacheKey,
+			Extents: []Extent{{
+				Start:    split.query.StartTimestampMs,
+				End:      split.query.EndTimestampMs,
+				Response: any,
+			}},
+		})
+		if err != nil {
+			level.Warn(rt.logger).Log("msg", "error marshalling cached remote read result", "err", err)
+			continue
+		}
+
+		if maxSize > 0 && len(data) > maxSize {
+			level.Debug(rt.logger).Log("msg", "skipped caching the remote read result because it exceeds the results cache max item size", "size", len(data), "maxSize", maxSize)
+			continue
+		}
+
+		rt.cache.Store(ctx, map[string][]byte{cacheHashKey(split.cacheKey): data}, resultsCacheTTL(rt.limits, tenantIDs, split.query.EndTimestampMs))
+	}
+}
+
+// mergeRemoteReadResults merges the results of the split queries into the response of a remote read
+// request with numQueries queries. The split queries of each query must be in time order.
+func mergeRemoteReadResults(numQueries int, splits []*remoteReadSplitQuery) *prompb.ReadResponse {
+	resp := &prompb.ReadResponse{Results: make([]*prompb.QueryResult, numQueries)}
+
+	seriesByQuery := make([]map[string]*prompb.TimeSeries, numQueries)
+	for _, split := range splits {
+		if seriesByQuery[split.queryIdx] == nil {
+			seriesByQuery[split.queryIdx] = map[string]*prompb.TimeSeries{}
+		}
+		series := seriesByQuery[split.queryIdx]
+
+		for _, ts := range split.result.Timeseries {
+			key := labelsToString(ts.Labels)
+			if existing, ok := series[key]; ok {
+				existing.Samples = append(existing.Samples, ts.Samples...)
+				continue
+			}
+			series[key] = &prompb.TimeSeries{Labels: ts.Labels, Samples: ts.Samples}
+		}
+	}
+
+	for idx := range resp.Results {
+		result := &prompb.QueryResult{}
+		for _, ts := range seriesByQuery[idx] {
+			result.Timeseries = append(result.Timeseries, ts)
+		}
+		sort.Slice(result.Timeseries, func(i, j int) bool {
+			return labels.Compare(labelProtosToLabels(result.Timeseries[i].Labels), labelProtosToLabels(result.Timeseries[j].Labels)) < 0
+		})
+		resp.Results[idx] = result
+	}
+	return resp
+}
+
+func labelsToString(lbls []prompb.Label) string {
+	return labelProtosToLabels(lbls).String()
+}
+
+func labelProtosToLabels(lbls []prompb.Label) labels.Labels {
+	result := make(labels.Labels, 0, len(lbls))
+	for _, l := range lbls {
+		result = append(result, labels.Label{Name: l.Name, Value: l.Value})
+	}
+	return result
+}
+
+// withRemoteReadRequest returns a copy of the HTTP request r with the body replaced by the remote read request.
+func withRemoteReadRequest(r *http.Request, req *prompb.ReadRequest) *http.Request {
+	data, err := proto.Marshal(req)
+	if err != nil {
+		// Marshalling a request which has been unmarshalled never fails.
+		panic(err)
+	}
+	body := snappy.Encode(nil, data)
+
+	r = r.Clone(r.Context())
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return r
+}
+
+// decodeRemoteReadResponse returns the result of the single query remote read response, or nil if the
+// response is not successful.
+func decodeRemoteReadResponse(resp *http.Response) (*prompb.QueryResult, error) {
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	compressed, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeInternal, err.Error())
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeInternal, err.Error())
+	}
+
+	var readResp prompb.ReadResponse
+	if err := proto.Unmarshal(data, &readResp); err != nil {
+		return nil, apierror.New(apierror.TypeInternal, err.Error())
+	}
+	if len(readResp.Results) != 1 {
+		return nil, apierror.New(apierror.TypeInternal, fmt.Sprintf("unexpected number of remote read query results: %d", len(readResp.Results)))
+	}
+	return readResp.Results[0], nil
+}
+
+func encodeRemoteReadResponse(resp *prompb.ReadResponse) (*http.Response, error) {
+	data, err := proto.Marshal(resp)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeInternal, err.Error())
+	}
+	body := snappy.Encode(nil, data)
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":     []string{"application/x-protobuf"},
+			"Content-Encoding": []string{"snappy"},
+		},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}, nil
+}
@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/cache"
+)
+
+func TestRemoteReadRoundTripper(t *testing.T) {
+	now := time.Now()
+	start := now.Add(-10 * 24 * time.Hour).Truncate(24 * time.Hour).Add(time.Hour)
+	end := start.Add(2 * 24 * time.Hour)
+
+	query := &prompb.Query{
+		StartTimestampMs: start.UnixMilli(),
+		EndTimestampMs:   end.UnixMilli(),
+		Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "metric"}},
+	}
+	otherQuery := &prompb.Query{
+		StartTimestampMs: start.UnixMilli(),
+		EndTimestampMs:   start.Add(time.Hour).UnixMilli(),
+		Matchers:         []*prompb.LabelMatcher{{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "other"}},
+	}
+
+	t.Run("should split the queries by interval and merge the results", func(t *testing.T) {
+		downstream := &remoteReadDownstream{}
+		metrics := newRemoteReadMetrics(nil)
+		rt := newRemoteReadRoundTripper(downstream, mockLimits{}, 24*time.Hour, nil, log.NewNopLogger(), metrics)
+
+		resp, err := rt.RoundTrip(newRemoteReadHTTPRequest(t, []prompb.ReadRequest_ResponseType{prompb.ReadRequest_SAMPLES}, query, otherQuery))
+		require.NoError(t, err)
+
+		// The first query is split in 3 queries, the other query is not split.
+		assert.Len(t, downstream.getQueries(), 4)
+		assert.Equal(t, float64(4), testutil.ToFloat64(metrics.splitQueriesCount))
+		assert.Equal(t, []*prompb.QueryResult{
+			remoteReadDownstreamResult(query, "metric"),
+			remoteReadDownstreamResult(otherQuery, "other"),
+		}, decodeRemoteReadHTTPResponse(t, resp).Results)
+	})
+
+	t.Run("should fetch the split queries results from the results cache", func(t *testing.T) {
+		downstream := &remoteReadDownstream{}
+		metrics := newRemoteReadMetrics(nil)
+		rt := newRemoteReadRoundTripper(downstream, mockLimits{}, 24*time.Hour, cache.NewMockCache(), log.NewNopLogger(), metrics)
+
+		for i := 0; i < 2; i++ {
+			resp, err := rt.RoundTrip(newRemoteReadHTTPRequest(t, nil, query))
+			require.NoError(t, err)
+			assert.Equal(t, []*prompb.QueryResult{remoteReadDownstreamResult(query, "metric")}, decodeRemoteReadHTTPResponse(t, resp).Results)
+		}
+
+		// The second request is fully served from the results cache.
+		assert.Len(t, downstream.getQueries(), 3)
+		assert.Equal(t, float64(3), testutil.ToFloat64(metrics.cachedQueriesCount))
+
+		// The results cache is skipped if the request disables it.
+		req := newRemoteReadHTTPRequest(t, nil, query)
+		req.Header.Set(cacheControlHeader, noStoreValue)
+		_, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		assert.Len(t, downstream.getQueries(), 6)
+	})
+
+	t.Run("should not cache the results of recent split queries", func(t *testing.T) {
+		downstream := &remoteReadDownstream{}
+		rt := newRemoteReadRoundTripper(downstream, mockLimits{maxCacheFreshness: 10 * time.Minute}, 24*time.Hour, cache.NewMockCache(), log.NewNopLogger(), newRemoteReadMetrics(nil))
+
+		recentQuery := &prompb.Query{StartTimestampMs: now.Add(-time.Hour).UnixMilli(), EndTimestampMs: now.UnixMilli(), Matchers: query.Matchers}
+		for i := 0; i < 2; i++ {
+			_, err := rt.RoundTrip(newRemoteReadHTTPRequest(t, nil, recentQuery))
+			require.NoError(t, err)
+		}
+		assert.Len(t, downstream.getQueries(), 2*len(splitRemoteReadQueryByInterval(recentQuery, 24*time.Hour)))
+	})
+
+	t.Run("should enforce the limits", func(t *testing.T) {
+		downstream := &remoteReadDownstream{}
+		rt := newRemoteReadRoundTripper(downstream, mockLimits{maxTotalQueryLength: 24 * time.Hour}, 24*time.Hour, nil, log.NewNopLogger(), newRemoteReadMetrics(nil))
+
+		_, err := rt.RoundTrip(newRemoteReadHTTPRequest(t, nil, query))
+		require.Error(t, err)
+		assert.True(t, apierror.IsAPIError(err))
+		assert.Empty(t, downstream.getQueries())
+
+		// The queries outside the max query lookback are not executed, and the others are clamped.
+		rt = newRemoteReadRoundTripper(downstream, mockLimits{maxQueryLookback: 9 * 24 * time.Hour, compactorBlocksRetentionPeriod: 9 * 24 * time.Hour}, 0, nil, log.NewNopLogger(), newRemoteReadMetrics(nil))
+		recentQuery := &prompb.Query{StartTimestampMs: start.UnixMilli(), EndTimestampMs: now.UnixMilli(), Matchers: query.Matchers}
+		resp, err := rt.RoundTrip(newRemoteReadHTTPRequest(t, nil, otherQuery, recentQuery))
+		require.NoError(t, err)
+
+		queries := downstream.getQueries()
+		require.Len(t, queries, 1)
+		assert.Greater(t, queries[0].StartTimestampMs, start.Add(24*time.Hour).UnixMilli())
+		assert.Equal(t, now.UnixMilli(), queries[0].EndTimestampMs)
+
+		results := decodeRemoteReadHTTPResponse(t, resp).Results
+		require.Len(t, results, 2)
+		assert.Empty(t, results[0].Timeseries)
+		assert.Len(t, results[1].Timeseries, 1)
+
+		// The queries after the max query into future are not executed, and the others are clamped.
+		downstream = &remoteReadDownstream{}
+		rt = newRemoteReadRoundTripper(downstream, mockLimits{maxQueryIntoFuture: time.Hour}, 0, nil, log.NewNopLogger(), newRemoteReadMetrics(nil))
+		futureQuery := &prompb.Query{StartTimestampMs: now.Add(2 * time.Hour).UnixMilli(), EndTimestampMs: now.Add(3 * time.Hour).UnixMilli(), Matchers: query.Matchers}
+		overlappingQuery := &prompb.Query{StartTimestampMs: now.UnixMilli(), EndTimestampMs: now.Add(3 * time.Hour).UnixMilli(), Matchers: query.Matchers}
+		_, err = rt.RoundTrip(newRemoteReadHTTPRequest(t, nil, futureQuery, overlappingQuery))
+		require.NoError(t, err)
+
+		queries = downstream.getQueries()
+		require.Len(t, queries, 1)
+		assert.Equal(t, now.UnixMilli(), queries[0].StartTimestampMs)
+		assert.InDelta(t, now.Add(time.Hour).UnixMilli(), queries[0].EndTimestampMs, float64(5000))
+	})
+
+	t.Run("should send the requests not accepting the samples response type downstream as is", func(t *testing.T) {
+		downstream := &remoteReadDownstream{}
+		rt := newRemoteReadRoundTripper(downstream, mockLimits{}, 24*time.Hour, nil, log.NewNopLogger(), newRemoteReadMetrics(nil))
+
+		_, err := rt.RoundTrip(newRemoteReadHTTPRequest(t, []prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS}, query))
+		require.NoError(t, err)
+		assert.Equal(t, []*prompb.Query{query}, downstream.getQueries())
+	})
+
+	t.Run("should return the failed downstream response", func(t *testing.T) {
+		downstream := &remoteReadDownstream{statusCode: http.StatusServiceUnavailable}
+		rt := newRemoteReadRoundTripper(downstream, mockLimits{}, 24*time.Hour, nil, log.NewNopLogger(), newRemoteReadMetrics(nil))
+
+		resp, err := rt.RoundTrip(newRemoteReadHTTPRequest(t, nil, query))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	})
+}
+
+func TestSplitRemoteReadQueryByInterval(t *testing.T) {
+	hour := time.Hour.Milliseconds()
+	query := &prompb.Query{StartTimestampMs: 30 * 60 * 1000, EndTimestampMs: 2 * hour, Hints: &prompb.ReadHints{StartMs: 30 * 60 * 1000, EndMs: 2 * hour}}
+
+	splits := splitRemoteReadQueryByInterval(query, time.Hour)
+	require.Len(t, splits, 3)
+	for i, expected := range [][2]int64{{30 * 60 * 1000, hour - 1}, {hour, 2*hour - 1}, {2 * hour, 2 * hour}} {
+		assert.Equal(t, expected[0], splits[i].StartTimestampMs)
+		assert.Equal(t, expected[1], splits[i].EndTimestampMs)
+		assert.Equal(t, expected[0], splits[i].Hints.StartMs)
+		assert.Equal(t, expected[1], splits[i].Hints.EndMs)
+	}
+
+	// The original query is not modified.
+	assert.Equal(t, int64(30*60*1000), query.Hints.StartMs)
+	assert.Equal(t, []*prompb.Query{query}, splitRemoteReadQueryByInterval(query, 0))
+}
+
+// remoteReadDownstream is a remote read endpoint returning, for each query, a series named after the
+// value of the first matcher, with a sample every minute.
+type remoteReadDownstream struct {
+	statusCode int
+
+	mtx     sync.Mutex
+	queries []*prompb.Query
+}
+
+func (d *remoteReadDownstream) RoundTrip(r *http.Request) (*http.Response, error) {
+	req, err := remote.DecodeReadRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mtx.Lock()
+	d.queries = append(d.queries, req.Queries...)
+	d.mtx.Unlock()
+
+	if d.statusCode != 0 {
+		return &http.Response{StatusCode: d.statusCode, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+	}
+
+	resp := &prompb.ReadResponse{}
+	for _, q := range req.Queries {
+		resp.Results = append(resp.Results, remoteReadDownstreamResult(q, q.Matchers[0].Value))
+	}
+	return encodeRemoteReadResponse(resp)
+}
+
+func (d *remoteReadDownstream) getQueries() []*prompb.Query {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	return append([]*prompb.Query(nil), d.queries...)
+}
+
+func remoteReadDownstreamResult(q *prompb.Query, name string) *prompb.QueryResult {
+	ts := &prompb.TimeSeries{Labels: []prompb.Label{{Name: "__name__", Value: name}}}
+	minute := time.Minute.Milliseconds()
+	for t := (q.StartTimestampMs + minute - 1) / minute * minute; t <= q.EndTimestampMs; t += minute {
+		ts.Samples = append(ts.Samples, prompb.Sample{Timestamp: t, Value: float64(t)})
+	}
+	return &prompb.QueryResult{Timeseries: []*prompb.TimeSeries{ts}}
+}
+
+func newRemoteReadHTTPRequest(t *testing.T, accepted []prompb.ReadRequest_ResponseType, queries ...*prompb.Query) *http.Request {
+	data, err := proto.Marshal(&prompb.ReadRequest{Queries: queries, AcceptedResponseTypes: accepted})
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, "/prometheus"+remoteReadPathSuffix, bytes.NewReader(snappy.Encode(nil, data)))
+	require.NoError(t, err)
+	return req.WithContext(user.InjectOrgID(context.Background(), "user-1"))
+}
+
+func decodeRemoteReadHTTPResponse(t *testing.T, resp *http.Response) *prompb.ReadResponse {
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	compressed, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	data, err := snappy.Decode(nil, compressed)
+	require.NoError(t, err)
+
+	var readResp prompb.ReadResponse
+	require.NoError(t, proto.Unmarshal(data, &readResp))
+	return &readResp
+}
@@ -25,9 +25,13 @@ import (
 	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/uber/jaeger-client-go"
 
+	"github.com/grafana/dskit/tenant"
+
 	"github.com/grafana/mimir/pkg/cache"
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/util"
+	util_math "github.com/grafana/mimir/pkg/util/math"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
@@ -99,6 +103,135 @@ func newResultsCache(cfg ResultsCacheConfig, logger log.Logger, reg prometheus.R
 	), nil
 }
 
+// resultsCacheTTL returns the TTL of the results cache entries of the input tenants, ending at the
+// input timestamp (in milliseconds). The entries overlapping the out-of-order time window are cached
+// with a lower TTL, because they may change when out-of-order samples are ingested.
+func resultsCacheTTL(limits Limits, tenantIDs []string, end int64) time.Duration {
+	ttl := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, limits.ResultsCacheTTL)
+	if ttl == 0 {
+		ttl = defaultResultsCacheTTL
+	}
+
+	lowerTTLWithinTimePeriod := validation.MaxDurationPerTenant(tenantIDs, func(tenantID string) time.Duration {
+		return time.Duration(limits.OutOfOrderTimeWindow(tenantID))
+	})
+	if lowerTTLWithinTimePeriod > 0 && end >= time.Now().Add(-lowerTTLWithinTimePeriod).UnixMilli() {
+		ttl = util_math.MinDuration(ttl, resultsCacheLowerTTL)
+	}
+
+	return ttl
+}
+
+// tenantCompressionCache is a cache.Cache compressing the entries with the results cache compression
+// of the tenants in the context, falling back to the configured compression if the tenants don't
+// override it or override it with different values.
+type tenantCompressionCache struct {
+	next               cache.Cache
+	limits             Limits
+	defaultCompression string
+	logger             log.Logger
+
+	// Compression caches wrapping next, by compression.
+	caches map[string]cache.Cache
+}
+
+// newTenantCompressionCache makes a new tenantCompressionCache. The entries compressed with a
+// compression different from the configured one are stored with a key suffixed by the compression,
+// so that changing the compression of a tenant doesn't cause decoding failures of its cached entries.
+func newTenantCompressionCache(cfg cache.CompressionConfig, next cache.Cache, limits Limits, logger log.Logger) cache.Cache {
+	return &tenantCompressionCache{
+		next:               next,
+		limits:             limits,
+		defaultCompression: cfg.Compression,
+		logger:             logger,
+		caches: map[string]cache.Cache{
+			"":                      next,
+			cache.CompressionSnappy: cache.NewSnappy(next, logger),
+			cache.CompressionZstd:   cache.NewZstd(next, logger),
+		},
+	}
+}
+
+// Store implements cache.Cache.
+func (t *tenantCompressionCache) Store(ctx context.Context, data map[string][]byte, ttl time.Duration) {
+	compression := t.compression(ctx)
+	if compression == t.defaultCompression {
+		t.caches[compression].Store(ctx, data, ttl)
+		return
+	}
+
+	suffix := compressionKeySuffix(compression)
+	suffixed := make(map[string][]byte, len(data))
+	for key, value := range data {
+		suffixed[key+suffix] = value
+	}
+	t.caches[compression].Store(ctx, suffixed, ttl)
+}
+
+// Fetch implements cache.Cache.
+func (t *tenantCompressionCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	compression := t.compression(ctx)
+	if compression == t.defaultCompression {
+		return t.caches[compression].Fetch(ctx, keys)
+	}
+
+	suffix := compressionKeySuffix(compression)
+	suffixed := make([]string, 0, len(keys))
+	for _, key := range keys {
+		suffixed = append(suffixed, key+suffix)
+	}
+
+	found := t.caches[compression].Fetch(ctx, suffixed)
+	result := make(map[string][]byte, len(found))
+	for key, value := range found {
+		result[strings.TrimSuffix(key, suffix)] = value
+	}
+	return result
+}
+
+// Name implements cache.Cache.
+func (t *tenantCompressionCache) Name() string {
+	return t.next.Name()
+}
+
+// compressionKeySuffix returns the suffix of the keys of the entries compressed with the input compression.
+func compressionKeySuffix(compression string) string {
+	if compression == "" {
+		return ":" + validation.ResultsCacheCompressionNone
+	}
+	return ":" + compression
+}
+
+// compression returns the compression of the results cache entries of the tenants in the context.
+func (t *tenantCompressionCache) compression(ctx context.Context) string {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return t.defaultCompression
+	}
+
+	compression := ""
+	for idx, tenantID := range tenantIDs {
+		tenantCompression := t.limits.ResultsCacheCompression(tenantID)
+		switch tenantCompression {
+		case "":
+			tenantCompression = t.defaultCompression
+		case validation.ResultsCacheCompressionNone:
+			tenantCompression = ""
+		}
+
+		if idx > 0 && tenantCompression != compression {
+			return t.defaultCompression
+		}
+		compression = tenantCompression
+	}
+
+	if _, ok := t.caches[compression]; !ok {
+		level.Warn(t.logger).Log("msg", "unsupported results cache compression, falling back to the configured one", "compression", compression)
+		return t.defaultCompression
+	}
+	return compression
+}
+
 // Extractor is used by the cache to extract a subset of a response from a cache entry.
 type Extractor interface {
 	// Extract extracts a subset of a response from the `start` and `end` timestamps in milliseconds in the `from` response.
@@ -172,6 +305,9 @@ type shouldCacheFn func(r Request) bool
 // resultsCacheAlwaysEnabled is a shouldCacheFn function always returning true.
 var resultsCacheAlwaysEnabled = func(_ Request) bool { return true }
 
+// resultsCacheEnabledByOption is a shouldCacheFn function returning true if caching is not disabled in the request options.
+var resultsCacheEnabledByOption = func(r Request) bool { return !r.GetOptions().CacheDisabled }
+
 // isRequestCachable says whether the request is eligible for caching.
 func isRequestCachable(req Request, maxCacheTime int64, cacheUnalignedRequests bool, logger log.Logger) (cachable bool, reason string) {
 	// We can run with step alignment disabled because Grafana does it already. Mimir automatically aligning start and end is not
@@ -15,8 +15,10 @@ import (
 	"github.com/go-kit/log"
 	"github.com/gogo/protobuf/types"
 	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/tenant"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
 
 	"github.com/grafana/mimir/pkg/cache"
 	mimir_tsdb "github.com/grafana/mimir/pkg/cache"
@@ -120,6 +122,80 @@ func mkExtentWithStep(start, end, step int64) Extent {
 	}
 }
 
+func TestTenantCompressionCache(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	value := []byte("value-value-value-value-value-value-value-value")
+
+	tests := map[string]struct {
+		tenantIDs           []string
+		compressions        map[string]string
+		expectedKey         string
+		expectedCompression string
+	}{
+		"should use the configured compression if the tenant doesn't override it": {
+			tenantIDs:           []string{"user-1"},
+			expectedKey:         "key",
+			expectedCompression: cache.CompressionSnappy,
+		},
+		"should use the tenant's compression": {
+			tenantIDs:           []string{"user-1"},
+			compressions:        map[string]string{"user-1": cache.CompressionZstd},
+			expectedKey:         "key:zstd",
+			expectedCompression: cache.CompressionZstd,
+		},
+		"should disable the compression if the tenant sets it to none": {
+			tenantIDs:           []string{"user-1"},
+			compressions:        map[string]string{"user-1": "none"},
+			expectedKey:         "key:none",
+			expectedCompression: "",
+		},
+		"should use the compression of multiple tenants if they all set the same one": {
+			tenantIDs:           []string{"user-1", "user-2"},
+			compressions:        map[string]string{"user-1": cache.CompressionZstd, "user-2": cache.CompressionZstd},
+			expectedKey:         "key:zstd",
+			expectedCompression: cache.CompressionZstd,
+		},
+		"should use the configured compression if multiple tenants set different ones": {
+			tenantIDs:           []string{"user-1", "user-2"},
+			compressions:        map[string]string{"user-1": cache.CompressionZstd},
+			expectedKey:         "key",
+			expectedCompression: cache.CompressionSnappy,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			backend := cache.NewMockCache()
+			limits := multiTenantResultsCacheCompressionLimits{compressions: testData.compressions}
+			c := newTenantCompressionCache(cache.CompressionConfig{Compression: cache.CompressionSnappy}, backend, limits, log.NewNopLogger())
+
+			ctx := user.InjectOrgID(context.Background(), tenant.JoinTenantIDs(testData.tenantIDs))
+			c.Store(ctx, map[string][]byte{"key": value}, time.Hour)
+
+			// The entry is stored with the expected key and compression.
+			stored := backend.Fetch(ctx, []string{testData.expectedKey})
+			require.Len(t, stored, 1)
+			expected := cache.NewMockCache()
+			cache.NewCompression(cache.CompressionConfig{Compression: testData.expectedCompression}, expected, log.NewNopLogger()).Store(ctx, map[string][]byte{"key": value}, time.Hour)
+			assert.Equal(t, expected.Fetch(ctx, []string{"key"})["key"], stored[testData.expectedKey])
+
+			// The entry is fetched back with the original key.
+			assert.Equal(t, map[string][]byte{"key": value}, c.Fetch(ctx, []string{"key"}))
+		})
+	}
+}
+
+type multiTenantResultsCacheCompressionLimits struct {
+	mockLimits
+	compressions map[string]string
+}
+
+func (m multiTenantResultsCacheCompressionLimits) ResultsCacheCompression(userID string) string {
+	return m.compressions[userID]
+}
+
 func TestIsRequestCachable(t *testing.T) {
 	maxCacheTime := int64(150 * 1000)
 
@@ -32,13 +32,16 @@ const (
 
 // Config for query_range middleware chain.
 type Config struct {
-	SplitQueriesByInterval time.Duration `yaml:"split_queries_by_interval" category:"advanced"`
-	AlignQueriesWithStep   bool          `yaml:"align_queries_with_step"`
-	ResultsCacheConfig     `yaml:"results_cache"`
-	CacheResults           bool `yaml:"cache_results"`
-	MaxRetries             int  `yaml:"max_retries" category:"advanced"`
-	ShardedQueries         bool `yaml:"parallelize_shardable_queries"`
-	CacheUnalignedRequests bool `yaml:"cache_unaligned_requests" category:"advanced"`
+	SplitQueriesByInterval  time.Duration `yaml:"split_queries_by_interval" category:"advanced"`
+	AlignQueriesWithStep    bool          `yaml:"align_queries_with_step"`
+	ResultsCacheConfig      `yaml:"results_cache"`
+	CacheResults            bool `yaml:"cache_results"`
+	MaxRetries              int  `yaml:"max_retries" category:"advanced"`
+	ShardedQueries          bool `yaml:"parallelize_shardable_queries"`
+	CacheUnalignedRequests  bool `yaml:"cache_unaligned_requests" category:"advanced"`
+	RemoteReadSplitAndCache bool `yaml:"remote_read_split_and_cache_enabled" category:"experimental"`
+	CacheInstantQueries     bool `yaml:"cache_instant_queries" category:"experimental"`
+	SpinOffSubqueries       bool `yaml:"spin_off_subqueries_enabled" category:"experimental"`
 
 	// CacheSplitter allows to inject a CacheSplitter to use for generating cache keys.
 	// If nil, the querymiddleware package uses a ConstSplitter with SplitQueriesByInterval.
@@ -55,6 +58,9 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.CacheResults, "query-frontend.cache-results", false, "Cache query results.")
 	f.BoolVar(&cfg.ShardedQueries, "query-frontend.parallelize-shardable-queries", false, "True to enable query sharding.")
 	f.BoolVar(&cfg.CacheUnalignedRequests, "query-frontend.cache-unaligned-requests", false, "Cache requests that are not step-aligned.")
+	f.BoolVar(&cfg.RemoteReadSplitAndCache, "query-frontend.remote-read-split-and-cache-enabled", false, "True to enforce the range queries limits on the remote read requests, split their queries by -query-frontend.split-queries-by-interval and cache the split queries results when -query-frontend.cache-results is enabled. Only the remote read requests accepting the samples response type are affected.")
+	f.BoolVar(&cfg.CacheInstantQueries, "query-frontend.cache-instant-queries", false, "True to cache the results of the instant queries whose evaluation time is older than -query-frontend.max-cache-freshness, when -query-frontend.cache-results is enabled.")
+	f.BoolVar(&cfg.SpinOffSubqueries, "query-frontend.spin-off-subqueries-enabled", false, "True to spin off the expensive subqueries of the instant queries into range queries, which are split, cached and sharded like any other range query, and stitch their results back together in the query-frontend. Only the subqueries with an explicit step, a range of at least 1h and at least 10 steps are spun off.")
 	cfg.ResultsCacheConfig.RegisterFlags(f)
 }
 
@@ -166,34 +172,39 @@ func newQueryTripperware(
 	// Metric used to keep track of each middleware execution duration.
 	metrics := newInstrumentMiddlewareMetrics(registerer)
 
-	queryRangeMiddleware := []Middleware{
+	// The middlewares enforcing the limits run before any other middleware. They're followed by the query
+	// cost and series count estimation middlewares, which are created along with the round tripper because
+	// they send requests downstream.
+	queryRangeLimitsMiddleware := []Middleware{
 		// Track query range statistics. Added first before any subsequent middleware modifies the request.
 		newQueryStatsMiddleware(registerer),
 		newLimitsMiddleware(limits, log),
 	}
-	if cfg.AlignQueriesWithStep {
-		queryRangeMiddleware = append(queryRangeMiddleware, newInstrumentMiddleware("step_align", metrics, log), newStepAlignMiddleware())
-	}
+	queryInstantLimitsMiddleware := []Middleware{newLimitsMiddleware(limits, log)}
+	queryCostMetrics := newQueryCostMiddlewareMetrics(registerer)
 
-	// Inject the middleware to split requests by interval + results cache (if at least one of the two is enabled).
-	if cfg.SplitQueriesByInterval > 0 || cfg.CacheResults {
-		var c cache.Cache
+	var estimatedSeriesCountMetrics *estimatedSeriesCountMiddlewareMetrics
+	if cfg.ShardedQueries {
+		estimatedSeriesCountMetrics = newEstimatedSeriesCountMiddlewareMetrics(registerer)
+	}
 
-		// Init the cache client.
-		if cfg.CacheResults {
-			var err error
+	// The step alignment can be enabled per tenant, so the middleware is always in the chain.
+	queryRangeMiddleware := []Middleware{newInstrumentMiddleware("step_align", metrics, log), newStepAlignMiddleware(limits, cfg.AlignQueriesWithStep, registerer)}
 
-			c, err = newResultsCache(cfg.ResultsCacheConfig, log, registerer)
-			if err != nil {
-				return nil, err
-			}
-			c = cache.NewCompression(cfg.ResultsCacheConfig.Compression, c, log)
-		}
+	// Init the cache client.
+	var c cache.Cache
+	if cfg.CacheResults {
+		var err error
 
-		shouldCache := func(r Request) bool {
-			return !r.GetOptions().CacheDisabled
+		c, err = newResultsCache(cfg.ResultsCacheConfig, log, registerer)
+		if err != nil {
+			return nil, err
 		}
+		c = newTenantCompressionCache(cfg.ResultsCacheConfig.Compression, c, limits, log)
+	}
 
+	// Inject the middleware to split requests by interval + results cache (if at least one of the two is enabled).
+	if cfg.SplitQueriesByInterval > 0 || cfg.CacheResults {
 		splitter := cfg.CacheSplitter
 		if splitter == nil {
 			splitter = ConstSplitter(cfg.SplitQueriesByInterval)
@@ -209,18 +220,32 @@ func newQueryTripperware(
 			c,
 			splitter,
 			cacheExtractor,
-			shouldCache,
+			resultsCacheEnabledByOption,
+			log,
+			registerer,
+		))
+	}
+
+	var queryInstantCacheMiddleware []Middleware
+	if cfg.CacheResults && cfg.CacheInstantQueries {
+		queryInstantCacheMiddleware = append(queryInstantCacheMiddleware, newInstrumentMiddleware("instant_query_results_cache", metrics, log), newInstantQueryCacheMiddleware(
+			limits,
+			c,
+			cacheExtractor,
+			resultsCacheEnabledByOption,
 			log,
 			registerer,
 		))
 	}
 
-	queryInstantMiddleware := []Middleware{newLimitsMiddleware(limits, log)}
+	// The middleware spinning off the subqueries is created along with the round tripper, because it
+	// runs the spun off subqueries through the range queries round tripper.
+	var spinOffMetrics spinOffSubqueriesMetrics
+	if cfg.SpinOffSubqueries {
+		spinOffMetrics = newSpinOffSubqueriesMetrics(registerer)
+	}
 
-	queryInstantMiddleware = append(
-		queryInstantMiddleware,
-		newSplitInstantQueryByIntervalMiddleware(limits, log, engine, registerer),
-	)
+	queryInstantMiddleware := []Middleware{newSplitInstantQueryByIntervalMiddleware(limits, log, engine, registerer)}
 
 	if cfg.ShardedQueries {
 		queryshardingMiddleware := newQueryShardingMiddleware(
@@ -247,18 +272,45 @@ func newQueryTripperware(
 		queryInstantMiddleware = append(queryInstantMiddleware, newInstrumentMiddleware("retry", metrics, log), newRetryMiddleware(log, cfg.MaxRetries, retryMiddlewareMetrics))
 	}
 
+	var remoteReadMetrics *remoteReadMetrics
+	if cfg.RemoteReadSplitAndCache {
+		remoteReadMetrics = newRemoteReadMetrics(registerer)
+	}
+
 	return func(next http.RoundTripper) http.RoundTripper {
-		queryrange := newLimitedParallelismRoundTripper(next, codec, limits, queryRangeMiddleware...)
+		seriesCountEstimator := newCardinalitySeriesCountEstimator(next)
+		queryEstimationMiddleware := []Middleware{newQueryCostMiddleware(seriesCountEstimator, limits, log, queryCostMetrics)}
+		if cfg.ShardedQueries {
+			// The series count is estimated before the query is split, so that it's estimated only once.
+			queryEstimationMiddleware = append(queryEstimationMiddleware, newEstimatedSeriesCountMiddleware(seriesCountEstimator, limits, log, estimatedSeriesCountMetrics))
+		}
+
+		queryrange := newLimitedParallelismRoundTripper(next, codec, limits, mergeMiddlewareLists(queryRangeLimitsMiddleware, queryEstimationMiddleware, queryRangeMiddleware)...)
+		var querySpinOffMiddleware []Middleware
+		if cfg.SpinOffSubqueries {
+			rangeHandler := roundTripperHandler{logger: log, next: queryrange, codec: codec}
+			querySpinOffMiddleware = append(querySpinOffMiddleware, newInstrumentMiddleware("spin_off_subqueries", metrics, log), newSpinOffSubqueriesMiddleware(rangeHandler, log, engine, spinOffMetrics))
+		}
+
 		instant := defaultInstantQueryParamsRoundTripper(
-			newLimitedParallelismRoundTripper(next, codec, limits, queryInstantMiddleware...),
+			newLimitedParallelismRoundTripper(next, codec, limits, mergeMiddlewareLists(queryInstantLimitsMiddleware, queryEstimationMiddleware, queryInstantCacheMiddleware, querySpinOffMiddleware, queryInstantMiddleware)...),
 			time.Now,
 		)
+		explain := newQueryExplainRoundTripper(cfg, limits, c, log)
+		remoteRead := next
+		if cfg.RemoteReadSplitAndCache {
+			remoteRead = newRemoteReadRoundTripper(next, limits, cfg.SplitQueriesByInterval, c, log, remoteReadMetrics)
+		}
 		return RoundTripFunc(func(r *http.Request) (*http.Response, error) {
 			switch {
 			case isRangeQuery(r.URL.Path):
 				return queryrange.RoundTrip(r)
 			case isInstantQuery(r.URL.Path):
 				return instant.RoundTrip(r)
+			case isRemoteRead(r.URL.Path):
+				return remoteRead.RoundTrip(r)
+			case isQueryExplain(r.URL.Path):
+				return explain.RoundTrip(r)
 			default:
 				return next.RoundTrip(r)
 			}
@@ -266,6 +318,15 @@ func newQueryTripperware(
 	}, nil
 }
 
+// mergeMiddlewareLists returns a new list with the middlewares of all lists, in order.
+func mergeMiddlewareLists(lists ...[]Middleware) []Middleware {
+	var merged []Middleware
+	for _, list := range lists {
+		merged = append(merged, list...)
+	}
+	return merged
+}
+
 func newActiveUsersTripperware(registerer prometheus.Registerer) Tripperware {
 	// Per tenant query metrics.
 	queriesPerTenant := promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
@@ -284,6 +345,8 @@ func newActiveUsersTripperware(registerer prometheus.Registerer) Tripperware {
 			op := "query"
 			if isRangeQuery(r.URL.Path) {
 				op = "query_range"
+			} else if isRemoteRead(r.URL.Path) {
+				op = "remote_read"
 			}
 
 			tenantIDs, err := tenant.TenantIDs(r.Context())
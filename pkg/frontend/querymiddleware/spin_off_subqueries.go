@@ -0,0 +1,300 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/frontend/querymiddleware/astmapper"
+	"github.com/grafana/mimir/pkg/storage/lazyquery"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+const skippedReasonNoSubqueries = "no-subqueries"
+
+var (
+	errMissingSubqueryQuery = errors.New("missing spun off subquery query")
+	errMissingSubqueryStep  = errors.New("missing spun off subquery step")
+	errMissingSubqueryHints = errors.New("missing select hints for spun off subquery")
+)
+
+type spinOffSubqueriesMetrics struct {
+	spinOffAttempts   prometheus.Counter
+	spinOffSuccesses  prometheus.Counter
+	spinOffSkipped    *prometheus.CounterVec
+	spunOffSubqueries prometheus.Counter
+}
+
+func newSpinOffSubqueriesMetrics(registerer prometheus.Registerer) spinOffSubqueriesMetrics {
+	m := spinOffSubqueriesMetrics{
+		spinOffAttempts: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_subquery_spin_off_attempts_total",
+			Help: "Total number of instant queries the query-frontend attempted to spin off subqueries from.",
+		}),
+		spinOffSuccesses: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_subquery_spin_off_successes_total",
+			Help: "Total number of instant queries the query-frontend successfully spun off subqueries from.",
+		}),
+		spinOffSkipped: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_frontend_subquery_spin_off_skipped_total",
+			Help: "Total number of instant queries the query-frontend skipped or failed to spin off subqueries from.",
+		}, []string{"reason"}),
+		spunOffSubqueries: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_frontend_spun_off_subqueries_total",
+			Help: "Total number of subqueries that were spun off into range queries.",
+		}),
+	}
+
+	// Initialize known label values.
+	for _, reason := range []string{skippedReasonParsingFailed, skippedReasonMappingFailed, skippedReasonNoSubqueries} {
+		m.spinOffSkipped.WithLabelValues(reason)
+	}
+
+	return m
+}
+
+// spinOffSubqueriesMiddleware is a Middleware which spins off the expensive subqueries of the instant
+// queries into range queries, executed through the range queries middlewares (so that they're split,
+// cached and sharded) and stitched back together by running the instant query in the query-frontend.
+type spinOffSubqueriesMiddleware struct {
+	next         Handler
+	rangeHandler Handler
+	logger       log.Logger
+
+	engine *promql.Engine
+
+	metrics spinOffSubqueriesMetrics
+}
+
+// newSpinOffSubqueriesMiddleware makes a new spinOffSubqueriesMiddleware. The spun off subqueries
+// are run through the rangeHandler, while the rest of the query is run through the next handler.
+func newSpinOffSubqueriesMiddleware(
+	rangeHandler Handler,
+	logger log.Logger,
+	engine *promql.Engine,
+	metrics spinOffSubqueriesMetrics,
+) Middleware {
+	return MiddlewareFunc(func(next Handler) Handler {
+		return &spinOffSubqueriesMiddleware{
+			next:         next,
+			rangeHandler: rangeHandler,
+			logger:       logger,
+			engine:       engine,
+			metrics:      metrics,
+		}
+	})
+}
+
+func (s *spinOffSubqueriesMiddleware) Do(ctx context.Context, req Request) (Response, error) {
+	// Log the instant query and its timestamp in every error log, so that we have more information for debugging failures.
+	logger := log.With(s.logger, "query", req.GetQuery(), "query_timestamp", req.GetStart())
+
+	spanLog, ctx := spanlogger.NewWithLogger(ctx, logger, "spinOffSubqueriesMiddleware.Do")
+	defer spanLog.Span.Finish()
+
+	// Only the instant queries can be evaluated at a single point in time with the spun off subqueries.
+	instantReq, ok := req.(*PrometheusInstantQueryRequest)
+	if !ok {
+		return s.next.Do(ctx, req)
+	}
+
+	s.metrics.spinOffAttempts.Inc()
+
+	mapperStats := astmapper.NewSubquerySpinOffMapperStats()
+	mapperCtx, cancel := context.WithTimeout(ctx, shardingTimeout)
+	defer cancel()
+	mapper := astmapper.NewSubquerySpinOffMapper(mapperCtx, mapperStats)
+
+	expr, err := parser.ParseExpr(req.GetQuery())
+	if err != nil {
+		level.Warn(spanLog).Log("msg", "failed to parse query", "err", err)
+		s.metrics.spinOffSkipped.WithLabelValues(skippedReasonParsingFailed).Inc()
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	spinOffQuery, err := mapper.Map(expr)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			level.Error(spanLog).Log("msg", "timeout while spinning off subqueries, please fill in a bug report with this query, falling back to try executing without spinning off subqueries", "err", err)
+		} else {
+			level.Error(spanLog).Log("msg", "failed to map the input query, falling back to try executing without spinning off subqueries", "err", err)
+		}
+		s.metrics.spinOffSkipped.WithLabelValues(skippedReasonMappingFailed).Inc()
+		return s.next.Do(ctx, req)
+	}
+
+	if mapperStats.GetSpunOffSubqueries() == 0 {
+		level.Debug(spanLog).Log("msg", "input query has no subqueries to spin off, falling back to try executing without spinning off subqueries")
+		s.metrics.spinOffSkipped.WithLabelValues(skippedReasonNoSubqueries).Inc()
+		return s.next.Do(ctx, req)
+	}
+
+	level.Debug(spanLog).Log("msg", "subqueries have been spun off from the instant query", "rewritten", spinOffQuery, "spun_off_subqueries", mapperStats.GetSpunOffSubqueries())
+
+	// Update metrics.
+	s.metrics.spinOffSuccesses.Inc()
+	s.metrics.spunOffSubqueries.Add(float64(mapperStats.GetSpunOffSubqueries()))
+
+	req = req.WithQuery(spinOffQuery.String())
+	rangePath := strings.TrimSuffix(instantReq.GetPath(), instantQueryPathSuffix) + queryRangePathSuffix
+	spinOffQueryable := newSpinOffSubqueriesQueryable(req, s.next, s.rangeHandler, rangePath)
+
+	qry, err := newQuery(req, s.engine, lazyquery.NewLazyQueryable(spinOffQueryable))
+	if err != nil {
+		level.Warn(spanLog).Log("msg", "failed to create new query from spun off request", "err", err)
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	res := qry.Exec(ctx)
+	extracted, err := promqlResultToSamples(res)
+	if err != nil {
+		level.Warn(spanLog).Log("msg", "failed to execute instant query with spun off subqueries", "err", err)
+		return nil, mapEngineError(err)
+	}
+	return &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: string(res.Value.Type()),
+			Result:     extracted,
+		},
+		Headers: spinOffQueryable.getResponseHeaders(),
+	}, nil
+}
+
+// spinOffSubqueriesQueryable is an implementor of the Queryable interface, which runs the spun off
+// subqueries through the range handler and the embedded queries through the next handler.
+type spinOffSubqueriesQueryable struct {
+	*shardedQueryable
+
+	rangeHandler Handler
+	rangePath    string
+}
+
+// newSpinOffSubqueriesQueryable makes a new spinOffSubqueriesQueryable. Like for the shardedQueryable,
+// we expect a new queryable is created for each query. The range queries are sent with the rangePath.
+func newSpinOffSubqueriesQueryable(req Request, next, rangeHandler Handler, rangePath string) *spinOffSubqueriesQueryable {
+	return &spinOffSubqueriesQueryable{
+		shardedQueryable: newShardedQueryable(req, next),
+		rangeHandler:     rangeHandler,
+		rangePath:        rangePath,
+	}
+}
+
+// Querier implements storage.Queryable.
+func (q *spinOffSubqueriesQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	return &spinOffSubqueriesQuerier{
+		shardedQuerier: &shardedQuerier{ctx: ctx, req: q.req, handler: q.handler, responseHeaders: q.responseHeaders},
+		rangeHandler:   q.rangeHandler,
+		rangePath:      q.rangePath,
+	}, nil
+}
+
+// spinOffSubqueriesQuerier implements the storage.Querier interface. The series of the
+// astmapper.SubqueryMetricName metric are the results of the range queries run through the
+// range handler, while the embedded queries are run by the shardedQuerier.
+type spinOffSubqueriesQuerier struct {
+	*shardedQuerier
+
+	rangeHandler Handler
+	rangePath    string
+}
+
+// Select implements storage.Querier.
+func (q *spinOffSubqueriesQuerier) Select(sorted bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	var query, step string
+	var isSubquery bool
+	for _, matcher := range matchers {
+		switch matcher.Name {
+		case labels.MetricName:
+			isSubquery = matcher.Value == astmapper.SubqueryMetricName
+		case astmapper.SubqueryQueryLabelName:
+			query = matcher.Value
+		case astmapper.SubqueryStepLabelName:
+			step = matcher.Value
+		}
+	}
+
+	if !isSubquery {
+		return q.shardedQuerier.Select(sorted, hints, matchers...)
+	}
+	if query == "" {
+		return storage.ErrSeriesSet(errMissingSubqueryQuery)
+	}
+	if step == "" {
+		return storage.ErrSeriesSet(errMissingSubqueryStep)
+	}
+	if hints == nil {
+		return storage.ErrSeriesSet(errMissingSubqueryHints)
+	}
+
+	stepDuration, err := model.ParseDuration(step)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
+	return q.handleSubquery(query, int64(stepDuration)/1e6, hints)
+}
+
+// handleSubquery runs the subquery inner query as a range query over the time range selected by the PromQL engine.
+// The range query start and end are aligned to the step, like the PromQL engine does when evaluating a subquery.
+func (q *spinOffSubqueriesQuerier) handleSubquery(query string, stepMs int64, hints *storage.SelectHints) storage.SeriesSet {
+	start := ceilToStep(hints.Start, stepMs)
+	end := floorToStep(hints.End, stepMs)
+	if start > end {
+		return storage.EmptySeriesSet()
+	}
+
+	req := &PrometheusRangeQueryRequest{
+		Path:    q.rangePath,
+		Start:   start,
+		End:     end,
+		Step:    stepMs,
+		Query:   query,
+		Options: q.req.GetOptions(),
+	}
+
+	resp, err := q.rangeHandler.Do(q.ctx, req)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
+	streams, err := responseToSamples(resp)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+	q.responseHeaders.mergeHeaders(resp.(*PrometheusResponse).Headers)
+
+	// The results of the range query are the points of the subquery, so no stale marker has to be injected.
+	return newSeriesSetFromEmbeddedQueriesResults([][]SampleStream{streams}, nil)
+}
+
+// ceilToStep returns the smallest multiple of step greater than or equal to t.
+func ceilToStep(t, step int64) int64 {
+	aligned := floorToStep(t, step)
+	if aligned < t {
+		aligned += step
+	}
+	return aligned
+}
+
+// floorToStep returns the largest multiple of step less than or equal to t.
+func floorToStep(t, step int64) int64 {
+	aligned := (t / step) * step
+	if aligned > t {
+		aligned -= step
+	}
+	return aligned
+}
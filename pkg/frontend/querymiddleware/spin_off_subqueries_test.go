@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+func TestSpinOffSubqueriesCorrectness(t *testing.T) {
+	var (
+		end        = time.Now().Truncate(time.Minute)
+		start      = end.Add(-26 * time.Hour)
+		numSeries  = 10
+		seriesStep = 30 * time.Second
+	)
+
+	series := make([]*promql.StorageSeries, 0, numSeries)
+	for i := 0; i < numSeries; i++ {
+		series = append(series, newSeries(newTestCounterLabels(i), start, end, seriesStep, arithmeticSequence(float64(i))))
+	}
+	queryable := storageSeriesQueryable(series)
+
+	tests := map[string]struct {
+		query                     string
+		expectedSpunOffSubqueries int
+		expectedRangeQueries      int
+	}{
+		"max_over_time of rate": {
+			query:                     `max_over_time(rate(metric_counter[5m])[1d:1m])`,
+			expectedSpunOffSubqueries: 1,
+			expectedRangeQueries:      1,
+		},
+		"aggregation of subquery with offset": {
+			query:                     `sum(avg_over_time(rate(metric_counter[5m])[12h:5m] offset 1h))`,
+			expectedSpunOffSubqueries: 1,
+			expectedRangeQueries:      1,
+		},
+		"binary expression with embedded query": {
+			query:                     `max_over_time(rate(metric_counter[5m])[1d:1m]) / on(unique) rate(metric_counter[5m])`,
+			expectedSpunOffSubqueries: 1,
+			expectedRangeQueries:      1,
+		},
+		"multiple subqueries": {
+			query:                     `max_over_time(rate(metric_counter[5m])[1d:1m]) - min_over_time(rate(metric_counter[5m])[2h:1m])`,
+			expectedSpunOffSubqueries: 2,
+			expectedRangeQueries:      2,
+		},
+		"subquery not expensive enough": {
+			query:                     `max_over_time(rate(metric_counter[5m])[30m:1m])`,
+			expectedSpunOffSubqueries: 0,
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			t.Parallel()
+
+			req := &PrometheusInstantQueryRequest{
+				Path:  "/api/v1/query",
+				Time:  util.TimeToMillis(end),
+				Query: testData.query,
+			}
+
+			reg := prometheus.NewPedanticRegistry()
+			engine := newEngine()
+			downstream := &downstreamHandler{engine: engine, queryable: queryable}
+
+			rangeQueries := atomic.NewInt32(0)
+			rangeHandler := HandlerFunc(func(ctx context.Context, r Request) (Response, error) {
+				rangeQueries.Inc()
+				require.IsType(t, &PrometheusRangeQueryRequest{}, r)
+				assert.Equal(t, "/api/v1/query_range", r.(*PrometheusRangeQueryRequest).GetPath())
+				return downstream.Do(ctx, r)
+			})
+
+			// Run the query with the normal engine.
+			expectedRes, err := downstream.Do(context.Background(), req)
+			require.NoError(t, err)
+			expectedPrometheusRes := expectedRes.(*PrometheusResponse)
+			sort.Sort(byLabels(expectedPrometheusRes.Data.Result))
+			require.NotEmpty(t, expectedPrometheusRes.Data.Result)
+
+			// Run the query spinning off the subqueries.
+			spinOff := newSpinOffSubqueriesMiddleware(rangeHandler, log.NewNopLogger(), engine, newSpinOffSubqueriesMetrics(reg))
+			spinOffRes, err := spinOff.Wrap(downstream).Do(context.Background(), req)
+			require.NoError(t, err)
+			spinOffPrometheusRes := spinOffRes.(*PrometheusResponse)
+			sort.Sort(byLabels(spinOffPrometheusRes.Data.Result))
+
+			approximatelyEquals(t, expectedPrometheusRes, spinOffPrometheusRes)
+			assert.Equal(t, int32(testData.expectedRangeQueries), rangeQueries.Load())
+
+			expectedSuccesses, expectedNoSubqueries := 1, 0
+			if testData.expectedSpunOffSubqueries == 0 {
+				expectedSuccesses, expectedNoSubqueries = 0, 1
+			}
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP cortex_frontend_subquery_spin_off_attempts_total Total number of instant queries the query-frontend attempted to spin off subqueries from.
+				# TYPE cortex_frontend_subquery_spin_off_attempts_total counter
+				cortex_frontend_subquery_spin_off_attempts_total 1
+
+				# HELP cortex_frontend_subquery_spin_off_successes_total Total number of instant queries the query-frontend successfully spun off subqueries from.
+				# TYPE cortex_frontend_subquery_spin_off_successes_total counter
+				cortex_frontend_subquery_spin_off_successes_total %d
+
+				# HELP cortex_frontend_subquery_spin_off_skipped_total Total number of instant queries the query-frontend skipped or failed to spin off subqueries from.
+				# TYPE cortex_frontend_subquery_spin_off_skipped_total counter
+				cortex_frontend_subquery_spin_off_skipped_total{reason="mapping-failed"} 0
+				cortex_frontend_subquery_spin_off_skipped_total{reason="no-subqueries"} %d
+				cortex_frontend_subquery_spin_off_skipped_total{reason="parsing-failed"} 0
+
+				# HELP cortex_frontend_spun_off_subqueries_total Total number of subqueries that were spun off into range queries.
+				# TYPE cortex_frontend_spun_off_subqueries_total counter
+				cortex_frontend_spun_off_subqueries_total %d
+			`, expectedSuccesses, expectedNoSubqueries, testData.expectedSpunOffSubqueries))))
+		})
+	}
+}
+
+func TestSpinOffSubqueries_ShouldReturnErrorOnRangeHandlerFailure(t *testing.T) {
+	engine := newEngine()
+	downstream := &downstreamHandler{engine: engine, queryable: storageSeriesQueryable(nil)}
+	rangeHandler := mockHandlerWith(nil, errors.New("range query failed"))
+
+	spinOff := newSpinOffSubqueriesMiddleware(rangeHandler, log.NewNopLogger(), engine, newSpinOffSubqueriesMetrics(nil))
+	_, err := spinOff.Wrap(downstream).Do(context.Background(), &PrometheusInstantQueryRequest{
+		Path:  "/api/v1/query",
+		Time:  util.TimeToMillis(time.Now()),
+		Query: `max_over_time(rate(metric_counter[5m])[1d:1m])`,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "range query failed")
+}
+
+func TestSpinOffSubqueries_ShouldIgnoreRangeQueries(t *testing.T) {
+	rangeHandler := HandlerFunc(func(context.Context, Request) (Response, error) {
+		require.Fail(t, "the range handler should not be called")
+		return nil, nil
+	})
+	expected := &PrometheusResponse{Status: statusSuccess}
+
+	spinOff := newSpinOffSubqueriesMiddleware(rangeHandler, log.NewNopLogger(), newEngine(), newSpinOffSubqueriesMetrics(nil))
+	res, err := spinOff.Wrap(mockHandlerWith(expected, nil)).Do(context.Background(), &PrometheusRangeQueryRequest{
+		Path:  "/api/v1/query_range",
+		Start: 0,
+		End:   util.TimeToMillis(time.Now()),
+		Step:  60000,
+		Query: `max_over_time(rate(metric_counter[5m])[1d:1m])`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, expected, res)
+}
+
+func TestCeilAndFloorToStep(t *testing.T) {
+	for _, tt := range []struct {
+		t, step, ceil, floor int64
+	}{
+		{t: 0, step: 60, ceil: 0, floor: 0},
+		{t: 60, step: 60, ceil: 60, floor: 60},
+		{t: 61, step: 60, ceil: 120, floor: 60},
+		{t: -1, step: 60, ceil: 0, floor: -60},
+		{t: -61, step: 60, ceil: -60, floor: -120},
+	} {
+		assert.Equal(t, tt.ceil, ceilToStep(tt.t, tt.step), "ceil of %d", tt.t)
+		assert.Equal(t, tt.floor, floorToStep(tt.t, tt.step), "floor of %d", tt.t)
+	}
+}
@@ -29,18 +29,21 @@ import (
 	apierror "github.com/grafana/mimir/pkg/api/error"
 	"github.com/grafana/mimir/pkg/cache"
 	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/spanlogger"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
-	// Cache entries for 7 days. We're not disabling TTL because the backend client currently doesn't support it.
-	resultsCacheTTL = 7 * 24 * time.Hour
+	// Cache entries for 7 days if the tenant's TTL is not set. We're not disabling TTL because the backend
+	// client currently doesn't support it.
+	defaultResultsCacheTTL = 7 * 24 * time.Hour
 	// resultsCacheLowerTTL is the smaller TTL used in specific cases. For example OOO queries.
 	resultsCacheLowerTTL                  = 10 * time.Minute
 	notCachableReasonUnalignedTimeRange   = "unaligned-time-range"
 	notCachableReasonTooNew               = "too-new"
 	notCachableReasonModifiersNotCachable = "has-modifiers"
+	notCachableReasonTooLarge             = "too-large"
 )
 
 var (
@@ -53,6 +56,9 @@ type splitAndCacheMiddlewareMetrics struct {
 	splitQueriesCount              prometheus.Counter
 	queryResultCacheAttemptedCount prometheus.Counter
 	queryResultCacheSkippedCount   *prometheus.CounterVec
+	queryResultCacheRequestsCount  *prometheus.CounterVec
+	queryResultCacheHitsCount      *prometheus.CounterVec
+	activeUsers                    *util.ActiveUsersCleanupService
 }
 
 func newSplitAndCacheMiddlewareMetrics(reg prometheus.Registerer) *splitAndCacheMiddlewareMetrics {
@@ -69,14 +75,30 @@ func newSplitAndCacheMiddlewareMetrics(reg prometheus.Registerer) *splitAndCache
 			Name: "cortex_frontend_query_result_cache_skipped_total",
 			Help: "Total number of times a query was not cacheable because of a reason. This metric is tracked for each partial query when time-splitting is enabled.",
 		}, []string{"reason"}),
+		queryResultCacheRequestsCount: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_frontend_query_result_cache_requests_total",
+			Help: "Total number of queries looked up in the results cache, per tenant.",
+		}, []string{"user"}),
+		queryResultCacheHitsCount: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_frontend_query_result_cache_hits_total",
+			Help: "Total number of queries whose results have been found, fully or partially, in the results cache, per tenant.",
+		}, []string{"user"}),
 	}
 
 	// Initialize known label values.
 	for _, reason := range []string{notCachableReasonUnalignedTimeRange, notCachableReasonTooNew,
-		notCachableReasonModifiersNotCachable} {
+		notCachableReasonModifiersNotCachable, notCachableReasonTooLarge} {
 		m.queryResultCacheSkippedCount.WithLabelValues(reason)
 	}
 
+	m.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(func(user string) {
+		m.queryResultCacheRequestsCount.DeleteLabelValues(user)
+		m.queryResultCacheHitsCount.DeleteLabelValues(user)
+	})
+
+	// Start cleanup. If cleaner stops or fail, we will simply not clean the metrics for inactive users.
+	_ = m.activeUsers.StartAsync(context.Background())
+
 	return m
 }
 
@@ -178,6 +200,20 @@ func (s *splitAndCacheMiddleware) Do(ctx context.Context, req Request) (Response
 		// Lookup all keys from cache.
 		fetchedExtents := s.fetchCacheExtents(ctx, lookupKeys)
 
+		if len(lookupKeys) > 0 {
+			userID := tenant.JoinTenantIDs(tenantIDs)
+			s.metrics.activeUsers.UpdateUserTimestamp(userID, time.Now())
+			s.metrics.queryResultCacheRequestsCount.WithLabelValues(userID).Add(float64(len(lookupKeys)))
+
+			hits := 0
+			for _, extents := range fetchedExtents {
+				if len(extents) > 0 {
+					hits++
+				}
+			}
+			s.metrics.queryResultCacheHitsCount.WithLabelValues(userID).Add(float64(hits))
+		}
+
 		for lookupIdx, extents := range fetchedExtents {
 			if len(extents) == 0 {
 				// We just need to run the request as is because no part of it has been cached yet.
@@ -384,15 +420,6 @@ func (s *splitAndCacheMiddleware) fetchCacheExtents(ctx context.Context, keys []
 
 // storeCacheExtents stores the extents for given key in the cache.
 func (s *splitAndCacheMiddleware) storeCacheExtents(ctx context.Context, key string, tenantIDs []string, extents []Extent) {
-	ttl := resultsCacheTTL
-	lowerTTLWithinTimePeriod := validation.MaxDurationPerTenant(tenantIDs, func(tenantID string) time.Duration {
-		return time.Duration(s.limits.OutOfOrderTimeWindow(tenantID))
-	})
-	if lowerTTLWithinTimePeriod > 0 && len(extents) > 0 &&
-		extents[len(extents)-1].End >= time.Now().Add(-lowerTTLWithinTimePeriod).UnixMilli() {
-		ttl = resultsCacheLowerTTL
-	}
-
 	buf, err := proto.Marshal(&CachedResponse{
 		Key:     key,
 		Extents: extents,
@@ -402,7 +429,17 @@ func (s *splitAndCacheMiddleware) storeCacheExtents(ctx context.Context, key str
 		return
 	}
 
-	s.cache.Store(ctx, map[string][]byte{cacheHashKey(key): buf}, ttl)
+	if maxSize := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, s.limits.ResultsCacheMaxItemSizeBytes); maxSize > 0 && len(buf) > maxSize {
+		level.Debug(s.logger).Log("msg", "skipped caching the query results because they exceed the results cache max item size", "size", len(buf), "maxSize", maxSize)
+		s.metrics.queryResultCacheSkippedCount.WithLabelValues(notCachableReasonTooLarge).Inc()
+		return
+	}
+
+	end := int64(0)
+	if len(extents) > 0 {
+		end = extents[len(extents)-1].End
+	}
+	s.cache.Store(ctx, map[string][]byte{cacheHashKey(key): buf}, resultsCacheTTL(s.limits, tenantIDs, end))
 }
 
 // splitRequest holds information about a split request.
@@ -449,15 +486,13 @@ func (s *splitRequests) countDownstreamRequests() int {
 // prepareDownstreamRequests injects a unique ID and hints to all downstream requests and
 // initialize downstream responses slice to have the same length of requests.
 func (s *splitRequests) prepareDownstreamRequests() []Request {
-	// Count the total number of downstream requests to run and build the hints we're going
+	// Count the total number of downstream requests to run, used to build the hints we're going
 	// to attach to each request.
 	numDownstreamRequests := s.countDownstreamRequests()
 	if numDownstreamRequests == 0 {
 		return nil
 	}
 
-	hints := &Hints{TotalQueries: int32(numDownstreamRequests)}
-
 	// Build the whole list of requests to execute. For each downstream request,
 	// inject hints and a unique ID used to correlate responses once executed.
 	// ID intentionally start at 1 to detect any bug in case the default zero value is used.
@@ -466,6 +501,12 @@ func (s *splitRequests) prepareDownstreamRequests() []Request {
 	execReqs := make([]Request, 0, numDownstreamRequests)
 	for _, splitReq := range *s {
 		for i := 0; i < len(splitReq.downstreamRequests); i++ {
+			// The estimated series count previously attached to the request is preserved.
+			hints := &Hints{
+				TotalQueries:         int32(numDownstreamRequests),
+				EstimatedSeriesCount: splitReq.downstreamRequests[i].GetHints().GetEstimatedSeriesCount(),
+			}
+
 			splitReq.downstreamRequests[i] = splitReq.downstreamRequests[i].WithID(nextReqID).WithHints(hints)
 			nextReqID++
 		}
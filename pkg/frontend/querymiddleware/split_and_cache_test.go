@@ -133,6 +133,7 @@ func TestSplitAndCacheMiddleware_SplitByInterval(t *testing.T) {
 		# HELP cortex_frontend_query_result_cache_skipped_total Total number of times a query was not cacheable because of a reason. This metric is tracked for each partial query when time-splitting is enabled.
 		# TYPE cortex_frontend_query_result_cache_skipped_total counter
 		cortex_frontend_query_result_cache_skipped_total{reason="has-modifiers"} 0
+		cortex_frontend_query_result_cache_skipped_total{reason="too-large"} 0
 		cortex_frontend_query_result_cache_skipped_total{reason="too-new"} 0
 		cortex_frontend_query_result_cache_skipped_total{reason="unaligned-time-range"} 0
 		# HELP cortex_frontend_split_queries_total Total number of underlying query requests after the split by interval is applied.
@@ -302,6 +303,7 @@ func TestSplitAndCacheMiddleware_ResultsCache_ShouldNotLookupCacheIfStepIsNotAli
 		# HELP cortex_frontend_query_result_cache_skipped_total Total number of times a query was not cacheable because of a reason. This metric is tracked for each partial query when time-splitting is enabled.
 		# TYPE cortex_frontend_query_result_cache_skipped_total counter
 		cortex_frontend_query_result_cache_skipped_total{reason="has-modifiers"} 0
+		cortex_frontend_query_result_cache_skipped_total{reason="too-large"} 0
 		cortex_frontend_query_result_cache_skipped_total{reason="too-new"} 0
 		cortex_frontend_query_result_cache_skipped_total{reason="unaligned-time-range"} 1
 		# HELP cortex_frontend_split_queries_total Total number of underlying query requests after the split by interval is applied.
@@ -400,6 +402,113 @@ func TestSplitAndCacheMiddleware_ResultsCache_EnabledCachingOfStepUnalignedReque
 	assert.Equal(t, uint32(2), queryStats.LoadSplitQueries())
 }
 
+func TestSplitAndCacheMiddleware_ResultsCache_ShouldTrackRequestsAndHitsPerTenant(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	mw := newSplitAndCacheMiddleware(
+		true,
+		true,
+		24*time.Hour,
+		false,
+		mockLimits{maxCacheFreshness: 10 * time.Minute},
+		PrometheusCodec,
+		cache.NewMockCache(),
+		ConstSplitter(day),
+		PrometheusResponseExtractor{},
+		resultsCacheAlwaysEnabled,
+		log.NewNopLogger(),
+		reg,
+	)
+
+	rc := mw.Wrap(HandlerFunc(func(_ context.Context, req Request) (Response, error) {
+		return &PrometheusResponse{Status: "success", Data: &PrometheusData{ResultType: model.ValMatrix.String()}}, nil
+	}))
+
+	// The query is split in 2 queries.
+	req := Request(&PrometheusRangeQueryRequest{
+		Path:  "/api/v1/query_range",
+		Start: parseTimeRFC3339(t, "2021-10-15T10:00:00Z").Unix() * 1000,
+		End:   parseTimeRFC3339(t, "2021-10-16T12:00:00Z").Unix() * 1000,
+		Step:  60 * 1000,
+		Query: `{__name__=~".+"}`,
+	})
+
+	for _, userID := range []string{"user-1", "user-1", "user-2"} {
+		_, err := rc.Do(user.InjectOrgID(context.Background(), userID), req)
+		require.NoError(t, err)
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_frontend_query_result_cache_hits_total Total number of queries whose results have been found, fully or partially, in the results cache, per tenant.
+		# TYPE cortex_frontend_query_result_cache_hits_total counter
+		cortex_frontend_query_result_cache_hits_total{user="user-1"} 2
+		cortex_frontend_query_result_cache_hits_total{user="user-2"} 0
+		# HELP cortex_frontend_query_result_cache_requests_total Total number of queries looked up in the results cache, per tenant.
+		# TYPE cortex_frontend_query_result_cache_requests_total counter
+		cortex_frontend_query_result_cache_requests_total{user="user-1"} 4
+		cortex_frontend_query_result_cache_requests_total{user="user-2"} 2
+	`), "cortex_frontend_query_result_cache_hits_total", "cortex_frontend_query_result_cache_requests_total"))
+}
+
+func TestSplitAndCacheMiddleware_ResultsCache_ShouldNotCacheResponsesLargerThanMaxItemSize(t *testing.T) {
+	cacheBackend := cache.NewInstrumentedMockCache()
+	reg := prometheus.NewPedanticRegistry()
+
+	mw := newSplitAndCacheMiddleware(
+		false,
+		true,
+		0,
+		false,
+		mockLimits{maxCacheFreshness: 10 * time.Minute, resultsCacheMaxItemSizeBytes: 100},
+		PrometheusCodec,
+		cacheBackend,
+		ConstSplitter(day),
+		PrometheusResponseExtractor{},
+		resultsCacheAlwaysEnabled,
+		log.NewNopLogger(),
+		reg,
+	)
+
+	samples := make([]mimirpb.Sample, 0, 100)
+	for i := 0; i < 100; i++ {
+		samples = append(samples, mimirpb.Sample{Value: float64(i), TimestampMs: 1634292000000 + int64(i)*60000})
+	}
+
+	rc := mw.Wrap(HandlerFunc(func(_ context.Context, req Request) (Response, error) {
+		return &PrometheusResponse{
+			Status: "success",
+			Data: &PrometheusData{
+				ResultType: model.ValMatrix.String(),
+				Result: []SampleStream{{
+					Labels:  []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}},
+					Samples: samples,
+				}},
+			},
+		}, nil
+	}))
+
+	req := Request(&PrometheusRangeQueryRequest{
+		Path:  "/api/v1/query_range",
+		Start: parseTimeRFC3339(t, "2021-10-15T10:00:00Z").Unix() * 1000,
+		End:   parseTimeRFC3339(t, "2021-10-15T12:00:00Z").Unix() * 1000,
+		Step:  60 * 1000,
+		Query: `{__name__=~".+"}`,
+	})
+
+	_, err := rc.Do(user.InjectOrgID(context.Background(), "user-1"), req)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, cacheBackend.CountFetchCalls())
+	assert.Equal(t, 0, cacheBackend.CountStoreCalls())
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_frontend_query_result_cache_skipped_total Total number of times a query was not cacheable because of a reason. This metric is tracked for each partial query when time-splitting is enabled.
+		# TYPE cortex_frontend_query_result_cache_skipped_total counter
+		cortex_frontend_query_result_cache_skipped_total{reason="has-modifiers"} 0
+		cortex_frontend_query_result_cache_skipped_total{reason="too-large"} 1
+		cortex_frontend_query_result_cache_skipped_total{reason="too-new"} 0
+		cortex_frontend_query_result_cache_skipped_total{reason="unaligned-time-range"} 0
+	`), "cortex_frontend_query_result_cache_skipped_total"))
+}
+
 func TestSplitAndCacheMiddleware_ResultsCache_ShouldNotCacheRequestEarlierThanMaxCacheFreshness(t *testing.T) {
 	const (
 		maxCacheFreshness = 10 * time.Minute
@@ -438,6 +547,7 @@ func TestSplitAndCacheMiddleware_ResultsCache_ShouldNotCacheRequestEarlierThanMa
 				# HELP cortex_frontend_query_result_cache_skipped_total Total number of times a query was not cacheable because of a reason. This metric is tracked for each partial query when time-splitting is enabled.
 				# TYPE cortex_frontend_query_result_cache_skipped_total counter
 				cortex_frontend_query_result_cache_skipped_total{reason="has-modifiers"} 0
+				cortex_frontend_query_result_cache_skipped_total{reason="too-large"} 0
 				cortex_frontend_query_result_cache_skipped_total{reason="too-new"} 2
 				cortex_frontend_query_result_cache_skipped_total{reason="unaligned-time-range"} 0
 				# HELP cortex_frontend_split_queries_total Total number of underlying query requests after the split by interval is applied.
@@ -1051,6 +1161,18 @@ func TestSplitRequests_prepareDownstreamRequests(t *testing.T) {
 				(&PrometheusRangeQueryRequest{Start: 3}).WithID(3).WithHints(&Hints{TotalQueries: 3}),
 			},
 		},
+		"should preserve the estimated series count of downstream requests": {
+			input: splitRequests{
+				{downstreamRequests: []Request{
+					&PrometheusRangeQueryRequest{Start: 1, Hints: &Hints{EstimatedSeriesCount: 100}},
+					&PrometheusRangeQueryRequest{Start: 2, Hints: &Hints{EstimatedSeriesCount: 100}},
+				}},
+			},
+			expected: []Request{
+				(&PrometheusRangeQueryRequest{Start: 1}).WithID(1).WithHints(&Hints{TotalQueries: 2, EstimatedSeriesCount: 100}),
+				(&PrometheusRangeQueryRequest{Start: 2}).WithID(2).WithHints(&Hints{TotalQueries: 2, EstimatedSeriesCount: 100}),
+			},
+		},
 	}
 
 	for testName, testData := range tests {
@@ -1545,6 +1667,65 @@ func Test_evaluateAtModifier(t *testing.T) {
 	}
 }
 
+func TestSplitAndCacheMiddlewareTenantTTL(t *testing.T) {
+	mcache := cache.NewMockCache()
+	m := splitAndCacheMiddleware{
+		limits: mockLimits{
+			outOfOrderTimeWindow: model.Duration(time.Hour),
+			resultsCacheTTL:      time.Minute,
+		},
+		cache: mcache,
+	}
+
+	cases := []struct {
+		endTime time.Time
+		expTTL  time.Duration
+	}{
+		{
+			// The tenant's TTL is lower than the out-of-order one.
+			endTime: time.Now(),
+			expTTL:  time.Minute,
+		},
+		{
+			endTime: time.Now().Add(-2 * time.Hour),
+			expTTL:  time.Minute,
+		},
+	}
+
+	ctx := context.Background()
+	for i, c := range cases {
+		// Store.
+		key := fmt.Sprintf("k%d", i)
+		m.storeCacheExtents(ctx, key, []string{"ten1"}, []Extent{
+			{Start: 0, End: c.endTime.UnixMilli()},
+		})
+
+		// Check.
+		key = cacheHashKey(key)
+		ci := mcache.GetItems()[key]
+		actualTTL := time.Until(ci.ExpiresAt)
+		// We use a tolerance of 50ms to avoid flaky tests.
+		require.Greater(t, actualTTL, c.expTTL-(50*time.Millisecond))
+		require.Less(t, actualTTL, c.expTTL+(50*time.Millisecond))
+	}
+
+	// When the query is executed on behalf of multiple tenants, the smallest TTL is used.
+	m.limits = multiTenantResultsCacheTTLLimits{mockLimits: mockLimits{}, ttls: map[string]time.Duration{"ten1": time.Hour, "ten2": 30 * time.Minute}}
+	m.storeCacheExtents(ctx, "multi", []string{"ten1", "ten2"}, []Extent{{Start: 0, End: time.Now().Add(-2 * time.Hour).UnixMilli()}})
+	actualTTL := time.Until(mcache.GetItems()[cacheHashKey("multi")].ExpiresAt)
+	require.Greater(t, actualTTL, 30*time.Minute-(50*time.Millisecond))
+	require.Less(t, actualTTL, 30*time.Minute+(50*time.Millisecond))
+}
+
+type multiTenantResultsCacheTTLLimits struct {
+	mockLimits
+	ttls map[string]time.Duration
+}
+
+func (m multiTenantResultsCacheTTLLimits) ResultsCacheTTL(userID string) time.Duration {
+	return m.ttls[userID]
+}
+
 func TestSplitAndCacheMiddlewareLowerTTL(t *testing.T) {
 	mcache := cache.NewMockCache()
 	m := splitAndCacheMiddleware{
@@ -1572,15 +1753,15 @@ func TestSplitAndCacheMiddlewareLowerTTL(t *testing.T) {
 		},
 		{
 			endTime: time.Now().Add(-61 * time.Minute),
-			expTTL:  resultsCacheTTL,
+			expTTL:  defaultResultsCacheTTL,
 		},
 		{
 			endTime: time.Now().Add(-2 * time.Hour),
-			expTTL:  resultsCacheTTL,
+			expTTL:  defaultResultsCacheTTL,
 		},
 		{
 			endTime: time.Now().Add(-12 * time.Hour),
-			expTTL:  resultsCacheTTL,
+			expTTL:  defaultResultsCacheTTL,
 		},
 	}
 
@@ -162,7 +162,10 @@ func (s *splitInstantQueryByIntervalMiddleware) Do(ctx context.Context, req Requ
 	level.Debug(spanLog).Log("msg", "instant query has been split by interval", "rewritten", instantSplitQuery, "split_queries", mapperStats.GetSplitQueries())
 
 	// Send hint with number of embedded queries to the sharding middleware
-	hints := &Hints{TotalQueries: int32(mapperStats.GetSplitQueries())}
+	hints := &Hints{
+		TotalQueries:         int32(mapperStats.GetSplitQueries()),
+		EstimatedSeriesCount: req.GetHints().GetEstimatedSeriesCount(),
+	}
 
 	// Update query stats.
 	queryStats := stats.FromContext(ctx)
@@ -7,20 +7,90 @@ package querymiddleware
 
 import (
 	"context"
+
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+const (
+	stepAlignResultAligned        = "aligned"
+	stepAlignResultAlreadyAligned = "already-aligned"
+	stepAlignResultUnaligned      = "unaligned"
 )
 
+type stepAlignMiddleware struct {
+	next   Handler
+	limits Limits
+
+	// alignAll is true when the queries of every tenant should be aligned, regardless of the tenant's limits.
+	alignAll bool
+
+	queries *prometheus.CounterVec
+}
+
 // newStepAlignMiddleware creates a middleware that aligns the start and end of request to the step to
-// improved the cacheability of the query results.
-func newStepAlignMiddleware() Middleware {
+// improved the cacheability of the query results. Requests are aligned if alignAll is true or if the
+// alignment is enabled for the tenant, unless the client opted out with the Step-Align-Control header.
+func newStepAlignMiddleware(limits Limits, alignAll bool, registerer prometheus.Registerer) Middleware {
+	queries := promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_frontend_step_align_queries_total",
+		Help: "Total number of range queries processed by the query-frontend step alignment, by result.",
+	}, []string{"result"})
+
+	// Initialize known label values.
+	for _, result := range []string{stepAlignResultAligned, stepAlignResultAlreadyAligned, stepAlignResultUnaligned} {
+		queries.WithLabelValues(result)
+	}
+
 	return MiddlewareFunc(func(next Handler) Handler {
-		return HandlerFunc(func(ctx context.Context, r Request) (Response, error) {
-			start := (r.GetStart() / r.GetStep()) * r.GetStep()
-			end := (r.GetEnd() / r.GetStep()) * r.GetStep()
-			return next.Do(ctx, r.WithStartEnd(start, end))
-		})
+		return &stepAlignMiddleware{
+			next:     next,
+			limits:   limits,
+			alignAll: alignAll,
+			queries:  queries,
+		}
 	})
 }
 
+func (s *stepAlignMiddleware) Do(ctx context.Context, r Request) (Response, error) {
+	if isRequestStepAligned(r) {
+		s.queries.WithLabelValues(stepAlignResultAlreadyAligned).Inc()
+		return s.next.Do(ctx, r)
+	}
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	if r.GetOptions().StepAlignDisabled || !s.isEnabled(tenantIDs) {
+		s.queries.WithLabelValues(stepAlignResultUnaligned).Inc()
+		return s.next.Do(ctx, r)
+	}
+
+	s.queries.WithLabelValues(stepAlignResultAligned).Inc()
+	start := (r.GetStart() / r.GetStep()) * r.GetStep()
+	end := (r.GetEnd() / r.GetStep()) * r.GetStep()
+	return s.next.Do(ctx, r.WithStartEnd(start, end))
+}
+
+// isEnabled returns whether the step alignment is enabled for all the input tenants.
+func (s *stepAlignMiddleware) isEnabled(tenantIDs []string) bool {
+	if s.alignAll {
+		return true
+	}
+
+	for _, tenantID := range tenantIDs {
+		if !s.limits.QueryStepAlignEnabled(tenantID) {
+			return false
+		}
+	}
+	return len(tenantIDs) > 0
+}
+
 // isRequestStepAligned returns whether the Request start and end timestamps are aligned
 // with the step.
 func isRequestStepAligned(req Request) bool {
@@ -7,10 +7,17 @@ package querymiddleware
 
 import (
 	"context"
+	"fmt"
 	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
 )
 
 func TestStepAlignMiddleware(t *testing.T) {
@@ -50,14 +57,117 @@ func TestStepAlignMiddleware(t *testing.T) {
 				result = req.(*PrometheusRangeQueryRequest)
 				return nil, nil
 			})
-			s := newStepAlignMiddleware().Wrap(next)
-			_, err := s.Do(context.Background(), tc.input)
+			s := newStepAlignMiddleware(mockLimits{}, true, nil).Wrap(next)
+			_, err := s.Do(user.InjectOrgID(context.Background(), "test"), tc.input)
 			require.NoError(t, err)
 			require.Equal(t, tc.expected, result)
 		})
 	}
 }
 
+func TestStepAlignMiddleware_ShouldHonorTenantLimitsAndRequestOptions(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	tests := map[string]struct {
+		alignAll       bool
+		limits         Limits
+		tenantID       string
+		options        Options
+		input          *PrometheusRangeQueryRequest
+		expectedStart  int64
+		expectedEnd    int64
+		expectedResult string
+	}{
+		"should not align if disabled for the tenant": {
+			limits:         mockLimits{},
+			tenantID:       "user-1",
+			expectedStart:  2,
+			expectedEnd:    102,
+			expectedResult: stepAlignResultUnaligned,
+		},
+		"should align if enabled for the tenant": {
+			limits:         mockLimits{stepAlignEnabled: true},
+			tenantID:       "user-1",
+			expectedStart:  0,
+			expectedEnd:    100,
+			expectedResult: stepAlignResultAligned,
+		},
+		"should align if enabled for all tenants": {
+			limits:         mockLimits{},
+			alignAll:       true,
+			tenantID:       "user-1",
+			expectedStart:  0,
+			expectedEnd:    100,
+			expectedResult: stepAlignResultAligned,
+		},
+		"should not align if the client opted out": {
+			limits:         mockLimits{stepAlignEnabled: true},
+			alignAll:       true,
+			tenantID:       "user-1",
+			options:        Options{StepAlignDisabled: true},
+			expectedStart:  2,
+			expectedEnd:    102,
+			expectedResult: stepAlignResultUnaligned,
+		},
+		"should align if enabled for all the tenants of a federated query": {
+			limits:         multiTenantStepAlignLimits{enabled: map[string]bool{"user-1": true, "user-2": true}},
+			tenantID:       "user-1|user-2",
+			expectedStart:  0,
+			expectedEnd:    100,
+			expectedResult: stepAlignResultAligned,
+		},
+		"should not align if disabled for any tenant of a federated query": {
+			limits:         multiTenantStepAlignLimits{enabled: map[string]bool{"user-1": true}},
+			tenantID:       "user-1|user-2",
+			expectedStart:  2,
+			expectedEnd:    102,
+			expectedResult: stepAlignResultUnaligned,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var result *PrometheusRangeQueryRequest
+			next := HandlerFunc(func(_ context.Context, req Request) (Response, error) {
+				result = req.(*PrometheusRangeQueryRequest)
+				return nil, nil
+			})
+
+			reg := prometheus.NewPedanticRegistry()
+			s := newStepAlignMiddleware(testData.limits, testData.alignAll, reg).Wrap(next)
+			_, err := s.Do(user.InjectOrgID(context.Background(), testData.tenantID), &PrometheusRangeQueryRequest{
+				Start:   2,
+				End:     102,
+				Step:    10,
+				Options: testData.options,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedStart, result.GetStart())
+			assert.Equal(t, testData.expectedEnd, result.GetEnd())
+
+			expectedMetrics := map[string]int{stepAlignResultAligned: 0, stepAlignResultAlreadyAligned: 0, stepAlignResultUnaligned: 0}
+			expectedMetrics[testData.expectedResult] = 1
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP cortex_frontend_step_align_queries_total Total number of range queries processed by the query-frontend step alignment, by result.
+				# TYPE cortex_frontend_step_align_queries_total counter
+				cortex_frontend_step_align_queries_total{result="aligned"} %d
+				cortex_frontend_step_align_queries_total{result="already-aligned"} %d
+				cortex_frontend_step_align_queries_total{result="unaligned"} %d
+			`, expectedMetrics[stepAlignResultAligned], expectedMetrics[stepAlignResultAlreadyAligned], expectedMetrics[stepAlignResultUnaligned]))))
+		})
+	}
+}
+
+type multiTenantStepAlignLimits struct {
+	mockLimits
+	enabled map[string]bool
+}
+
+func (m multiTenantStepAlignLimits) QueryStepAlignEnabled(userID string) bool {
+	return m.enabled[userID]
+}
+
 func TestIsRequestStepAligned(t *testing.T) {
 	tests := map[string]struct {
 		req      Request
@@ -0,0 +1,373 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+)
+
+// Supported audit log sinks. A Kafka sink is not supported: the HTTP sink can be used to send the
+// entries to a Kafka REST proxy.
+const (
+	auditLogSinkFile = "file"
+	auditLogSinkHTTP = "http"
+)
+
+// auditLogMaxBatchSize is the max number of entries written to the sink at once.
+const auditLogMaxBatchSize = 100
+
+var auditLogSinks = []string{auditLogSinkFile, auditLogSinkHTTP}
+
+// AuditLogConfig configures the query audit log.
+type AuditLogConfig struct {
+	Sink         string        `yaml:"sink" category:"experimental"`
+	FilePath     string        `yaml:"file_path" category:"experimental"`
+	HTTPEndpoint string        `yaml:"http_endpoint" category:"experimental"`
+	HTTPTimeout  time.Duration `yaml:"http_timeout" category:"experimental"`
+	QueueSize    int           `yaml:"queue_size" category:"experimental"`
+}
+
+func (cfg *AuditLogConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Sink, prefix+"sink", "", fmt.Sprintf("Destination of the query audit log, where every query received by the query-frontend is written as a JSON object, independently of the application logs. Supported values: %s. Kafka is not supported as a sink. Empty to disable.", strings.Join(auditLogSinks, ", ")))
+	f.StringVar(&cfg.FilePath, prefix+"file-path", "", "Path of the file the query audit log is appended to, when the file sink is used.")
+	f.StringVar(&cfg.HTTPEndpoint, prefix+"http-endpoint", "", "URL the query audit log is sent to with POST requests carrying newline-delimited JSON, when the HTTP sink is used.")
+	f.DurationVar(&cfg.HTTPTimeout, prefix+"http-timeout", 10*time.Second, "Timeout of the requests sending the query audit log to the HTTP endpoint.")
+	f.IntVar(&cfg.QueueSize, prefix+"queue-size", 10000, "Max number of query audit log entries queued to be written to the sink. When the queue is full, new entries are dropped.")
+}
+
+func (cfg *AuditLogConfig) Validate() error {
+	switch cfg.Sink {
+	case "":
+		return nil
+	case auditLogSinkFile:
+		if cfg.FilePath == "" {
+			return errors.New("the query audit log file path is required when the file sink is used")
+		}
+	case auditLogSinkHTTP:
+		if _, err := url.ParseRequestURI(cfg.HTTPEndpoint); err != nil {
+			return errors.Wrap(err, "invalid query audit log HTTP endpoint")
+		}
+	default:
+		return fmt.Errorf("unsupported query audit log sink %q, supported values are: %s", cfg.Sink, strings.Join(auditLogSinks, ", "))
+	}
+
+	if cfg.QueueSize <= 0 {
+		return errors.New("the query audit log queue size must be greater than 0")
+	}
+	return nil
+}
+
+// auditLogEntry is a query written to the audit log.
+type auditLogEntry struct {
+	Timestamp           time.Time          `json:"timestamp"`
+	Tenant              string             `json:"tenant"`
+	RequestID           string             `json:"request_id,omitempty"`
+	Method              string             `json:"method"`
+	Path                string             `json:"path"`
+	Query               string             `json:"query,omitempty"`
+	Start               string             `json:"start,omitempty"`
+	End                 string             `json:"end,omitempty"`
+	Time                string             `json:"time,omitempty"`
+	Status              string             `json:"status"`
+	Result              string             `json:"result"`
+	StatusCode          int                `json:"status_code,omitempty"`
+	Error               string             `json:"error,omitempty"`
+	ResponseTimeSeconds float64            `json:"response_time_seconds"`
+	Stats               *queryStatsSummary `json:"stats,omitempty"`
+}
+
+// queryStatsSummary are the query stats included in the audit log entries and the query insights.
+type queryStatsSummary struct {
+	QuerierWallTimeSeconds float64 `json:"querierWallTimeSeconds"`
+	FetchedSeriesCount     uint64  `json:"fetchedSeriesCount"`
+	FetchedChunkBytes      uint64  `json:"fetchedChunkBytes"`
+	FetchedChunksCount     uint64  `json:"fetchedChunksCount"`
+	FetchedIndexBytes      uint64  `json:"fetchedIndexBytes"`
+	SamplesProcessed       uint64  `json:"samplesProcessed"`
+	ShardedQueries         uint32  `json:"shardedQueries"`
+	SplitQueries           uint32  `json:"splitQueries"`
+}
+
+func newQueryStatsSummary(stats *querier_stats.Stats) queryStatsSummary {
+	return queryStatsSummary{
+		QuerierWallTimeSeconds: stats.LoadWallTime().Seconds(),
+		FetchedSeriesCount:     stats.LoadFetchedSeries(),
+		FetchedChunkBytes:      stats.LoadFetchedChunkBytes(),
+		FetchedChunksCount:     stats.LoadFetchedChunks(),
+		FetchedIndexBytes:      stats.LoadFetchedIndexBytes(),
+		SamplesProcessed:       stats.LoadSamplesProcessed(),
+		ShardedQueries:         stats.LoadShardedQueries(),
+		SplitQueries:           stats.LoadSplitQueries(),
+	}
+}
+
+// auditLogSink writes the query audit log entries to a destination.
+type auditLogSink interface {
+	// Write writes a batch of entries, encoded as newline-delimited JSON. It's never called concurrently.
+	Write(ctx context.Context, batch []byte) error
+
+	// Close releases the resources of the sink. It's called once, after the last Write.
+	Close() error
+}
+
+func newAuditLogSink(cfg AuditLogConfig) (auditLogSink, error) {
+	switch cfg.Sink {
+	case auditLogSinkFile:
+		return &fileAuditLogSink{path: cfg.FilePath}, nil
+	case auditLogSinkHTTP:
+		return &httpAuditLogSink{endpoint: cfg.HTTPEndpoint, client: http.DefaultClient}, nil
+	default:
+		return nil, fmt.Errorf("unsupported query audit log sink %q", cfg.Sink)
+	}
+}
+
+// fileAuditLogSink appends the entries to a file. The file is opened on the first write,
+// and reopened on the next one if a write fails.
+type fileAuditLogSink struct {
+	path string
+	file *os.File
+}
+
+func (s *fileAuditLogSink) Write(_ context.Context, batch []byte) error {
+	if s.file == nil {
+		file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return errors.Wrap(err, "open query audit log file")
+		}
+		s.file = file
+	}
+
+	if _, err := s.file.Write(batch); err != nil {
+		_ = s.file.Close()
+		s.file = nil
+		return errors.Wrap(err, "write query audit log file")
+	}
+	return nil
+}
+
+func (s *fileAuditLogSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	return errors.Wrap(err, "close query audit log file")
+}
+
+// httpAuditLogSink sends the entries to an HTTP endpoint.
+type httpAuditLogSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+func (s *httpAuditLogSink) Write(ctx context.Context, batch []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(batch))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "send query audit log")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("send query audit log: unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *httpAuditLogSink) Close() error {
+	return nil
+}
+
+// auditLogger queues the audit log entries and writes them to the sink in the background, so that
+// a slow sink doesn't slow down the queries.
+type auditLogger struct {
+	sink        auditLogSink
+	httpTimeout time.Duration
+	logger      log.Logger
+
+	// Queue of the entries to write, closed by stop. mtx guards sending to it against closing it.
+	mtx     sync.RWMutex
+	stopped bool
+	entries chan auditLogEntry
+	done    chan struct{}
+
+	droppedEntries prometheus.Counter
+	writeFailures  prometheus.Counter
+}
+
+func newAuditLogger(cfg AuditLogConfig, sink auditLogSink, logger log.Logger, reg prometheus.Registerer) *auditLogger {
+	l := &auditLogger{
+		sink:        sink,
+		httpTimeout: cfg.HTTPTimeout,
+		logger:      logger,
+		entries:     make(chan auditLogEntry, cfg.QueueSize),
+		done:        make(chan struct{}),
+		droppedEntries: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_audit_log_dropped_entries_total",
+			Help: "Number of query audit log entries dropped because the queue was full or the audit log was stopped.",
+		}),
+		writeFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_audit_log_write_failures_total",
+			Help: "Number of batches of query audit log entries failed to be written to the sink.",
+		}),
+	}
+
+	go l.run()
+	return l
+}
+
+// log queues the entry to be written, dropping it if the queue is full or the audit log is stopped.
+func (l *auditLogger) log(entry auditLogEntry) {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	if l.stopped {
+		l.droppedEntries.Inc()
+		return
+	}
+
+	select {
+	case l.entries <- entry:
+	default:
+		l.droppedEntries.Inc()
+	}
+}
+
+// stop stops queueing new entries, and returns once the queued entries have been written and the
+// sink closed.
+func (l *auditLogger) stop() {
+	l.mtx.Lock()
+	if l.stopped {
+		l.mtx.Unlock()
+		return
+	}
+	l.stopped = true
+	close(l.entries)
+	l.mtx.Unlock()
+
+	<-l.done
+	if err := l.sink.Close(); err != nil {
+		level.Warn(l.logger).Log("msg", "failed to close the query audit log", "err", err)
+	}
+}
+
+// run writes the queued entries to the sink, in batches of the entries queued while the previous
+// batch was being written, until the queue is closed by stop.
+func (l *auditLogger) run() {
+	defer close(l.done)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	for entry := range l.entries {
+		buf.Reset()
+		l.encode(enc, entry)
+
+	batch:
+		for i := 1; i < auditLogMaxBatchSize; i++ {
+			select {
+			case entry, ok := <-l.entries:
+				if !ok {
+					break batch
+				}
+				l.encode(enc, entry)
+			default:
+				break batch
+			}
+		}
+
+		if buf.Len() == 0 {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), l.httpTimeout)
+		if err := l.sink.Write(ctx, buf.Bytes()); err != nil {
+			l.writeFailures.Inc()
+			level.Warn(l.logger).Log("msg", "failed to write the query audit log", "err", err)
+		}
+		cancel()
+	}
+}
+
+// encode appends the entry to the batch encoded by enc. The encoder appends a newline after each entry.
+func (l *auditLogger) encode(enc *json.Encoder, entry auditLogEntry) {
+	if err := enc.Encode(entry); err != nil {
+		level.Warn(l.logger).Log("msg", "failed to encode the query audit log entry", "err", err)
+	}
+}
+
+// auditQuery writes the query to the audit log, if enabled.
+func (f *Handler) auditQuery(r *http.Request, queryString url.Values, startTime time.Time, queryResponseTime time.Duration, statusCode int, stats *querier_stats.Stats, queryErr error) {
+	if f.auditLog == nil {
+		return
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return
+	}
+
+	entry := auditLogEntry{
+		Timestamp:           startTime,
+		Tenant:              tenant.JoinTenantIDs(tenantIDs),
+		Method:              r.Method,
+		Path:                r.URL.Path,
+		Query:               f.auditLogParam(queryString, "query"),
+		Start:               f.auditLogParam(queryString, "start"),
+		End:                 f.auditLogParam(queryString, "end"),
+		Time:                f.auditLogParam(queryString, "time"),
+		Status:              "success",
+		Result:              queryResult(queryErr),
+		StatusCode:          statusCode,
+		ResponseTimeSeconds: queryResponseTime.Seconds(),
+	}
+	if f.cfg.RequestIDHeader != "" {
+		entry.RequestID = r.Header.Get(f.cfg.RequestIDHeader)
+	}
+	if queryErr != nil {
+		entry.Status = "failed"
+		entry.Error = queryErr.Error()
+	}
+	if stats != nil {
+		entryStats := newQueryStatsSummary(stats)
+		entry.Stats = &entryStats
+	}
+
+	f.auditLog.log(entry)
+}
+
+// auditLogParam returns the value of the request parameter name, redacted if configured so.
+func (f *Handler) auditLogParam(queryString url.Values, name string) string {
+	value := queryString.Get(name)
+	if _, ok := f.redactedParams[name]; ok && value != "" {
+		return redactedParamValue
+	}
+	return value
+}
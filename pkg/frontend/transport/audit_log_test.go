@@ -0,0 +1,280 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestAuditLogConfig_Validate(t *testing.T) {
+	for name, test := range map[string]struct {
+		cfg         AuditLogConfig
+		expectedErr string
+	}{
+		"disabled": {
+			cfg: AuditLogConfig{},
+		},
+		"file sink": {
+			cfg: AuditLogConfig{Sink: auditLogSinkFile, FilePath: "/tmp/audit.log", QueueSize: 1},
+		},
+		"file sink without path": {
+			cfg:         AuditLogConfig{Sink: auditLogSinkFile, QueueSize: 1},
+			expectedErr: "file path is required",
+		},
+		"HTTP sink": {
+			cfg: AuditLogConfig{Sink: auditLogSinkHTTP, HTTPEndpoint: "http://localhost/audit", QueueSize: 1},
+		},
+		"HTTP sink with invalid endpoint": {
+			cfg:         AuditLogConfig{Sink: auditLogSinkHTTP, HTTPEndpoint: "localhost", QueueSize: 1},
+			expectedErr: "invalid query audit log HTTP endpoint",
+		},
+		"unsupported sink": {
+			cfg:         AuditLogConfig{Sink: "kafka", QueueSize: 1},
+			expectedErr: `unsupported query audit log sink "kafka"`,
+		},
+		"invalid queue size": {
+			cfg:         AuditLogConfig{Sink: auditLogSinkFile, FilePath: "/tmp/audit.log"},
+			expectedErr: "queue size must be greater than 0",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := test.cfg.Validate()
+			if test.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestHandler_AuditLog_FileSink(t *testing.T) {
+	auditLogPath := filepath.Join(t.TempDir(), "audit.log")
+
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("query") == "fail" {
+			return nil, errors.New("downstream failure")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	cfg := HandlerConfig{
+		QueryStatsEnabled:   true,
+		RequestIDHeader:     "X-Request-ID",
+		RedactedQueryParams: []string{"start"},
+		AuditLog:            AuditLogConfig{Sink: auditLogSinkFile, FilePath: auditLogPath, QueueSize: 10},
+	}
+	require.NoError(t, cfg.Validate())
+	handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	for _, query := range []string{"up", "fail"} {
+		req := httptest.NewRequest("GET", "/api/v1/query_range?query="+query+"&start=10&end=20", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+		req.Header.Set("X-Request-ID", "id-"+query)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Stopping the handler writes the queued entries and closes the file.
+	handler.Stop()
+	assert.Nil(t, handler.auditLog.sink.(*fileAuditLogSink).file)
+
+	entries := readAuditLogEntries(t, auditLogPath)
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "12345", entries[0].Tenant)
+	assert.Equal(t, "id-up", entries[0].RequestID)
+	assert.Equal(t, "/api/v1/query_range", entries[0].Path)
+	assert.Equal(t, "up", entries[0].Query)
+	assert.Equal(t, redactedParamValue, entries[0].Start)
+	assert.Equal(t, "20", entries[0].End)
+	assert.Equal(t, "success", entries[0].Status)
+	assert.Equal(t, resultSuccess, entries[0].Result)
+	assert.Equal(t, http.StatusOK, entries[0].StatusCode)
+	assert.NotNil(t, entries[0].Stats)
+
+	assert.Equal(t, "id-fail", entries[1].RequestID)
+	assert.Equal(t, "fail", entries[1].Query)
+	assert.Equal(t, "failed", entries[1].Status)
+	assert.Equal(t, resultError, entries[1].Result)
+	assert.Equal(t, "downstream failure", entries[1].Error)
+	assert.Zero(t, entries[1].StatusCode)
+}
+
+func TestHandler_AuditLog_HTTPSink(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		received [][]byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		mtx.Lock()
+		received = append(received, body)
+		mtx.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	// Query stats are disabled, so the entry has no stats.
+	cfg := HandlerConfig{AuditLog: AuditLogConfig{Sink: auditLogSinkHTTP, HTTPEndpoint: server.URL, HTTPTimeout: time.Second, QueueSize: 10}}
+	require.NoError(t, cfg.Validate())
+	reg := prometheus.NewPedanticRegistry()
+	handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), reg)
+
+	req := httptest.NewRequest("GET", "/api/v1/query?query=up&time=10", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(received) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	var entry auditLogEntry
+	require.NoError(t, json.Unmarshal(received[0], &entry))
+	assert.Equal(t, "12345", entry.Tenant)
+	assert.Equal(t, "up", entry.Query)
+	assert.Equal(t, "10", entry.Time)
+	assert.Nil(t, entry.Stats)
+
+	assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_frontend_audit_log_write_failures_total Number of batches of query audit log entries failed to be written to the sink.
+		# TYPE cortex_query_frontend_audit_log_write_failures_total counter
+		cortex_query_frontend_audit_log_write_failures_total 0
+	`), "cortex_query_frontend_audit_log_write_failures_total"))
+}
+
+func TestAuditLogger_ShouldDropEntriesWhenQueueIsFull(t *testing.T) {
+	sink := &blockingAuditLogSink{started: make(chan struct{}), unblock: make(chan struct{})}
+	reg := prometheus.NewPedanticRegistry()
+	l := newAuditLogger(AuditLogConfig{HTTPTimeout: time.Second, QueueSize: 1}, sink, log.NewNopLogger(), reg)
+
+	// The first entry is written while the sink is blocked, the second one is queued and the third one dropped.
+	l.log(auditLogEntry{Query: "1"})
+	<-sink.started
+	l.log(auditLogEntry{Query: "2"})
+	l.log(auditLogEntry{Query: "3"})
+	close(sink.unblock)
+
+	assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_frontend_audit_log_dropped_entries_total Number of query audit log entries dropped because the queue was full or the audit log was stopped.
+		# TYPE cortex_query_frontend_audit_log_dropped_entries_total counter
+		cortex_query_frontend_audit_log_dropped_entries_total 1
+	`), "cortex_query_frontend_audit_log_dropped_entries_total"))
+
+	l.stop()
+}
+
+func TestAuditLogger_ShouldWriteQueuedEntriesOnStop(t *testing.T) {
+	sink := &blockingAuditLogSink{started: make(chan struct{}), unblock: make(chan struct{})}
+	reg := prometheus.NewPedanticRegistry()
+	l := newAuditLogger(AuditLogConfig{HTTPTimeout: time.Second, QueueSize: 10}, sink, log.NewNopLogger(), reg)
+
+	// The first entry is written while the sink is blocked, and the other ones are queued.
+	l.log(auditLogEntry{Query: "1"})
+	<-sink.started
+	l.log(auditLogEntry{Query: "2"})
+	l.log(auditLogEntry{Query: "3"})
+
+	stopped := make(chan struct{})
+	go func() {
+		l.stop()
+		close(stopped)
+	}()
+	close(sink.unblock)
+	<-stopped
+
+	assert.Equal(t, []string{"1", "2", "3"}, sink.writtenQueries(t))
+	assert.True(t, sink.closed)
+
+	// The entries logged once stopped are dropped.
+	l.log(auditLogEntry{Query: "4"})
+	assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_frontend_audit_log_dropped_entries_total Number of query audit log entries dropped because the queue was full or the audit log was stopped.
+		# TYPE cortex_query_frontend_audit_log_dropped_entries_total counter
+		cortex_query_frontend_audit_log_dropped_entries_total 1
+	`), "cortex_query_frontend_audit_log_dropped_entries_total"))
+
+	// Stopping it again is a no-op.
+	l.stop()
+}
+
+// blockingAuditLogSink blocks the first write until unblock is closed, and records the written batches.
+type blockingAuditLogSink struct {
+	once    sync.Once
+	started chan struct{}
+	unblock chan struct{}
+
+	written bytes.Buffer
+	closed  bool
+}
+
+func (s *blockingAuditLogSink) Write(_ context.Context, batch []byte) error {
+	s.once.Do(func() {
+		close(s.started)
+		<-s.unblock
+	})
+	s.written.Write(batch)
+	return nil
+}
+
+func (s *blockingAuditLogSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+// writtenQueries returns the queries of the written entries. It must be called once the sink is closed.
+func (s *blockingAuditLogSink) writtenQueries(t *testing.T) []string {
+	var queries []string
+	dec := json.NewDecoder(&s.written)
+	for dec.More() {
+		var entry auditLogEntry
+		require.NoError(t, dec.Decode(&entry))
+		queries = append(queries, entry.Query)
+	}
+	return queries
+}
+
+func readAuditLogEntries(t *testing.T, path string) []auditLogEntry {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+
+	var entries []auditLogEntry
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		var entry auditLogEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
@@ -0,0 +1,58 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+// RequestAuthenticator authenticates and authorizes the requests received by the query-frontend,
+// before they're forwarded downstream. It's invoked by the Handler after the tenant ID has been
+// extracted from the request by the HTTP auth middleware.
+type RequestAuthenticator interface {
+	// Authenticate returns the context the request is executed with, derived from the request context,
+	// or an error to reject the request. The returned context can be annotated with the claims of the
+	// request, or with the tenant ID the request is executed for, which overrides the tenant ID received
+	// with the request. The request is rejected with the status code of the error if it's an httpgrpc
+	// or API error, 401 otherwise.
+	Authenticate(r *http.Request) (context.Context, error)
+}
+
+// RequestAuthenticatorFunc is to RequestAuthenticator what http.HandlerFunc is to http.Handler.
+type RequestAuthenticatorFunc func(r *http.Request) (context.Context, error)
+
+// Authenticate implements RequestAuthenticator.
+func (f RequestAuthenticatorFunc) Authenticate(r *http.Request) (context.Context, error) {
+	return f(r)
+}
+
+// authenticateRequest runs the request authenticators in order, each one receiving the request with
+// the context returned by the previous one, and returns the request with the resulting context.
+func (f *Handler) authenticateRequest(r *http.Request) (*http.Request, error) {
+	if len(f.cfg.RequestAuthenticators) == 0 {
+		return r, nil
+	}
+
+	for _, authenticator := range f.cfg.RequestAuthenticators {
+		ctx, err := authenticator.Authenticate(r)
+		if err != nil {
+			if _, ok := httpgrpc.HTTPResponseFromError(err); !ok && !apierror.IsAPIError(err) {
+				err = httpgrpc.Errorf(http.StatusUnauthorized, err.Error())
+			}
+			return r, err
+		}
+		r = r.WithContext(ctx)
+	}
+
+	// The tenant ID may have been overridden, so the header forwarded downstream is updated to match it.
+	if orgID, err := user.ExtractOrgID(r.Context()); err == nil {
+		r.Header.Set(user.OrgIDHeaderName, orgID)
+	}
+	return r, nil
+}
@@ -0,0 +1,123 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+)
+
+type claimsContextKey struct{}
+
+func TestHandler_RequestAuthenticators(t *testing.T) {
+	const rejectedRequestsMetric = `
+		# HELP cortex_query_frontend_rejected_requests_total Number of requests rejected by the query-frontend.
+		# TYPE cortex_query_frontend_rejected_requests_total counter
+		cortex_query_frontend_rejected_requests_total{reason="unauthenticated"} 1
+	`
+
+	annotateClaims := RequestAuthenticatorFunc(func(r *http.Request) (context.Context, error) {
+		return context.WithValue(r.Context(), claimsContextKey{}, "claims"), nil
+	})
+	overrideTenant := RequestAuthenticatorFunc(func(r *http.Request) (context.Context, error) {
+		if r.Header.Get("Authorization") != "Bearer tenant-2" {
+			return nil, errors.New("invalid token")
+		}
+		return user.InjectOrgID(r.Context(), "tenant-2"), nil
+	})
+	forbid := RequestAuthenticatorFunc(func(r *http.Request) (context.Context, error) {
+		return nil, httpgrpc.Errorf(http.StatusForbidden, "forbidden")
+	})
+
+	for name, test := range map[string]struct {
+		authenticators     []RequestAuthenticator
+		authorization      string
+		expectedStatusCode int
+		expectedOrgID      string
+		expectedClaims     interface{}
+		expectedMetrics    string
+	}{
+		"should forward the request if there are no authenticators": {
+			expectedStatusCode: http.StatusOK,
+			expectedOrgID:      "tenant-1",
+		},
+		"should forward the request with the context annotated by the authenticators": {
+			authenticators:     []RequestAuthenticator{annotateClaims},
+			expectedStatusCode: http.StatusOK,
+			expectedOrgID:      "tenant-1",
+			expectedClaims:     "claims",
+		},
+		"should forward the request with the tenant ID overridden by the authenticators": {
+			authenticators:     []RequestAuthenticator{annotateClaims, overrideTenant},
+			authorization:      "Bearer tenant-2",
+			expectedStatusCode: http.StatusOK,
+			expectedOrgID:      "tenant-2",
+			expectedClaims:     "claims",
+		},
+		"should reject the request with 401 if the authenticator returns a generic error": {
+			authenticators:     []RequestAuthenticator{overrideTenant},
+			authorization:      "Bearer invalid",
+			expectedStatusCode: http.StatusUnauthorized,
+			expectedMetrics:    rejectedRequestsMetric,
+		},
+		"should reject the request with the status code of the httpgrpc error returned by the authenticator": {
+			authenticators:     []RequestAuthenticator{annotateClaims, forbid},
+			expectedStatusCode: http.StatusForbidden,
+			expectedMetrics:    rejectedRequestsMetric,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				downstreamCalled bool
+				downstreamOrgID  string
+				downstreamHeader string
+				downstreamClaims interface{}
+			)
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				downstreamCalled = true
+				downstreamOrgID, _ = user.ExtractOrgID(req.Context())
+				downstreamHeader = req.Header.Get(user.OrgIDHeaderName)
+				downstreamClaims = req.Context().Value(claimsContextKey{})
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			})
+
+			reg := prometheus.NewPedanticRegistry()
+			cfg := HandlerConfig{RequestAuthenticators: test.authenticators}
+			handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), reg)
+
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), "tenant-1"))
+			req.Header.Set(user.OrgIDHeaderName, "tenant-1")
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, test.expectedStatusCode, resp.Code)
+			assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(test.expectedMetrics), "cortex_query_frontend_rejected_requests_total"))
+
+			if test.expectedStatusCode != http.StatusOK {
+				assert.False(t, downstreamCalled)
+				return
+			}
+
+			require.True(t, downstreamCalled)
+			assert.Equal(t, test.expectedOrgID, downstreamOrgID)
+			assert.Equal(t, test.expectedOrgID, downstreamHeader)
+			assert.Equal(t, test.expectedClaims, downstreamClaims)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// checkBlockedQuery returns an API error, rejecting the request with HTTP status code 422, if the PromQL
+// expression of the request matches one of the queries blocked for any of the request's tenants.
+//
+// To get the expression of POST requests the request body is read upfront, and it's replaced with a
+// reader of the bytes read. This only happens if the tenants have blocked queries.
+func (f *Handler) checkBlockedQuery(r *http.Request) error {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return nil
+	}
+
+	var blocked []*validation.BlockedQuery
+	for _, tenantID := range tenantIDs {
+		blocked = append(blocked, f.limits.BlockedQueries(tenantID)...)
+	}
+	if len(blocked) == 0 {
+		return nil
+	}
+
+	query, err := requestQuery(r)
+	if err != nil || query == "" {
+		return err
+	}
+
+	normalized := normalizeQuery(query)
+	for _, b := range blocked {
+		if b == nil || b.Pattern == "" {
+			continue
+		}
+
+		if b.Regex {
+			re, err := f.blockedQueryRegexp(b)
+			if err != nil {
+				level.Warn(util_log.WithContext(r.Context(), f.log)).Log("msg", "skipped blocked query with invalid regular expression", "pattern", b.Pattern, "err", err)
+				continue
+			}
+			if !re.MatchString(query) && !re.MatchString(normalized) {
+				continue
+			}
+		} else if normalizeQuery(b.Pattern) != normalized {
+			continue
+		}
+
+		f.rejectedRequests.WithLabelValues(reasonBlockedQuery).Inc()
+		level.Info(util_log.WithContext(r.Context(), f.log)).Log("msg", "rejected blocked query", "query", query, "pattern", b.Pattern, "regex", b.Regex)
+		return apierror.Newf(apierror.TypeExec, "the query is blocked by the administrator, because it matches the blocked query %q", b.Pattern)
+	}
+	return nil
+}
+
+// blockedQueryRegexp returns the compiled regular expression of the blocked query, caching it by pattern
+// so that it's compiled only once across requests. The patterns are validated when loading the limits.
+func (f *Handler) blockedQueryRegexp(b *validation.BlockedQuery) (*regexp.Regexp, error) {
+	if cached, ok := f.blockedQueryRegexps.Load(b.Pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	re, err := b.Regexp()
+	if err != nil {
+		return nil, err
+	}
+	f.blockedQueryRegexps.Store(b.Pattern, re)
+	return re, nil
+}
+
+// requestQuery returns the PromQL expression of the request, read from the URL query or the form body.
+// The request body is fully read and replaced with a reader of the bytes read.
+func requestQuery(r *http.Request) (string, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Parse the form of a copy of the request, so that the request isn't modified.
+	form := r.WithContext(r.Context())
+	form.Body = io.NopCloser(bytes.NewReader(body))
+	if err := form.ParseForm(); err != nil {
+		return "", nil
+	}
+	return form.Form.Get("query"), nil
+}
+
+// normalizeQuery returns the PromQL expression query formatted in a canonical way, so that
+// expressions differing only in formatting are equal. Invalid expressions are only trimmed.
+func normalizeQuery(query string) string {
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return strings.TrimSpace(query)
+	}
+	return expr.String()
+}
@@ -0,0 +1,158 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestHandler_BlockedQueries(t *testing.T) {
+	// Set a multi tenant resolver, restoring the default one at the end of the test.
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	limits := &mockLimits{blockedQueries: map[string][]*validation.BlockedQuery{
+		"tenant-a": {
+			{Pattern: `sum(rate(http_requests_total[5m]))`},
+			{Pattern: `[invalid`, Regex: true},
+			{Pattern: `.*expensive_metric.*`, Regex: true},
+		},
+		"tenant-b": {
+			{Pattern: `up`},
+		},
+	}}
+
+	for name, test := range map[string]struct {
+		orgID         string
+		method        string
+		query         string
+		expectBlocked bool
+	}{
+		"should not block queries of tenants without blocked queries": {
+			orgID:  "tenant-c",
+			method: http.MethodGet,
+			query:  `up`,
+		},
+		"should block a query equal to the blocked one": {
+			orgID:         "tenant-a",
+			method:        http.MethodGet,
+			query:         `sum(rate(http_requests_total[5m]))`,
+			expectBlocked: true,
+		},
+		"should block a query differing from the blocked one only in formatting": {
+			orgID:         "tenant-a",
+			method:        http.MethodGet,
+			query:         `sum( rate(http_requests_total[5m] ) )`,
+			expectBlocked: true,
+		},
+		"should not block a query containing the blocked one": {
+			orgID:  "tenant-a",
+			method: http.MethodGet,
+			query:  `sum(rate(http_requests_total[5m])) by (job)`,
+		},
+		"should block a query matching a blocked regular expression": {
+			orgID:         "tenant-a",
+			method:        http.MethodGet,
+			query:         `count(expensive_metric{job="test"})`,
+			expectBlocked: true,
+		},
+		"should block a query sent in the request body": {
+			orgID:         "tenant-a",
+			method:        http.MethodPost,
+			query:         `count(expensive_metric)`,
+			expectBlocked: true,
+		},
+		"should forward the request body of a query not blocked": {
+			orgID:  "tenant-a",
+			method: http.MethodPost,
+			query:  `up`,
+		},
+		"should block a query blocked for any of the tenants": {
+			orgID:         "tenant-a|tenant-c|tenant-b",
+			method:        http.MethodGet,
+			query:         `up`,
+			expectBlocked: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			roundTripperCalled := false
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				roundTripperCalled = true
+
+				// The downstream must receive the original request parameters.
+				require.NoError(t, req.ParseForm())
+				assert.Equal(t, test.query, req.Form.Get("query"))
+
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			})
+
+			reg := prometheus.NewPedanticRegistry()
+			handler := NewHandler(HandlerConfig{MaxBodySize: 1024}, limits, roundTripper, log.NewNopLogger(), reg)
+
+			params := url.Values{"query": []string{test.query}}
+			var req *http.Request
+			if test.method == http.MethodPost {
+				req = httptest.NewRequest(http.MethodPost, "/api/v1/query", strings.NewReader(params.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(http.MethodGet, "/api/v1/query?"+params.Encode(), nil)
+			}
+			req = req.WithContext(user.InjectOrgID(context.Background(), test.orgID))
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+
+			if !test.expectBlocked {
+				assert.Equal(t, http.StatusOK, resp.Code)
+				assert.True(t, roundTripperCalled)
+				return
+			}
+
+			assert.Equal(t, http.StatusUnprocessableEntity, resp.Code)
+			assert.False(t, roundTripperCalled)
+			assert.Contains(t, resp.Body.String(), `"errorType":"execution"`)
+			assert.Contains(t, resp.Body.String(), "the query is blocked by the administrator")
+
+			assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_query_frontend_rejected_requests_total Number of requests rejected by the query-frontend.
+				# TYPE cortex_query_frontend_rejected_requests_total counter
+				cortex_query_frontend_rejected_requests_total{reason="blocked_query"} 1
+			`), "cortex_query_frontend_rejected_requests_total"))
+		})
+	}
+}
+
+func TestHandler_blockedQueryRegexp(t *testing.T) {
+	handler := NewHandler(HandlerConfig{}, &mockLimits{}, nil, log.NewNopLogger(), nil)
+
+	first, err := handler.blockedQueryRegexp(&validation.BlockedQuery{Pattern: `.*expensive_metric.*`, Regex: true})
+	require.NoError(t, err)
+	assert.True(t, first.MatchString(`count(expensive_metric)`))
+	assert.False(t, first.MatchString(`up`))
+
+	// The regular expression is compiled only once for the same pattern.
+	second, err := handler.blockedQueryRegexp(&validation.BlockedQuery{Pattern: `.*expensive_metric.*`, Regex: true})
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	_, err = handler.blockedQueryRegexp(&validation.BlockedQuery{Pattern: `[invalid`, Regex: true})
+	require.Error(t, err)
+	_, cached := handler.blockedQueryRegexps.Load(`[invalid`)
+	assert.False(t, cached)
+}
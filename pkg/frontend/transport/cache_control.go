@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	cacheControlHeader  = "Cache-Control"
+	cacheControlNoCache = "no-cache"
+)
+
+// cacheControlRule sets the max-age of the responses to queries whose end is at least minEndAge in the past.
+type cacheControlRule struct {
+	minEndAge time.Duration
+	maxAge    time.Duration
+}
+
+// parseCacheControlRules parses the rules in the format <min end age>=<max-age>, and returns them
+// sorted by min end age.
+func parseCacheControlRules(values []string) ([]cacheControlRule, error) {
+	rules := make([]cacheControlRule, 0, len(values))
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid cache control rule %q, expected format is <min end age>=<max-age>", value)
+		}
+
+		minEndAge, err := model.ParseDuration(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid min end age in cache control rule %q", value)
+		}
+		maxAge, err := model.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid max-age in cache control rule %q", value)
+		}
+
+		rules = append(rules, cacheControlRule{minEndAge: time.Duration(minEndAge), maxAge: time.Duration(maxAge)})
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].minEndAge < rules[j].minEndAge
+	})
+	return rules, nil
+}
+
+// cacheControlValue returns the Cache-Control header value for a query with the given parameters, based
+// on how far in the past the query end is: the max-age of the rule with the greatest min end age not
+// exceeding it is used. Queries not matching any rule, like the ones ending now, get no-cache.
+func cacheControlValue(rules []cacheControlRule, queryString url.Values, now time.Time) string {
+	end := queryString.Get("end")
+	if end == "" {
+		// Instant queries.
+		end = queryString.Get("time")
+	}
+	if end == "" {
+		return cacheControlNoCache
+	}
+
+	endMillis, err := util.ParseTime(end)
+	if err != nil {
+		return cacheControlNoCache
+	}
+	endAge := now.Sub(util.TimeFromMillis(endMillis))
+
+	value := cacheControlNoCache
+	for _, rule := range rules {
+		if endAge < rule.minEndAge {
+			break
+		}
+		value = fmt.Sprintf("max-age=%d", int64(rule.maxAge.Seconds()))
+	}
+	return value
+}
+
+// setCacheControlHeader sets the Cache-Control header of a successful response based on the query end,
+// unless the downstream already set it.
+func (f *Handler) setCacheControlHeader(resp *http.Response, queryString url.Values) {
+	if len(f.cacheControlRules) == 0 || resp.StatusCode != http.StatusOK || resp.Header.Get(cacheControlHeader) != "" {
+		return
+	}
+
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.Header.Set(cacheControlHeader, cacheControlValue(f.cacheControlRules, queryString, time.Now()))
+}
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCacheControlRules(t *testing.T) {
+	for name, test := range map[string]struct {
+		values        []string
+		expectedRules []cacheControlRule
+		expectedErr   bool
+	}{
+		"no rules": {
+			expectedRules: []cacheControlRule{},
+		},
+		"rules are sorted by min end age": {
+			values:        []string{"24h=1h", "2h=5m"},
+			expectedRules: []cacheControlRule{{minEndAge: 2 * time.Hour, maxAge: 5 * time.Minute}, {minEndAge: 24 * time.Hour, maxAge: time.Hour}},
+		},
+		"rule without separator": {
+			values:      []string{"2h"},
+			expectedErr: true,
+		},
+		"invalid min end age": {
+			values:      []string{"abc=5m"},
+			expectedErr: true,
+		},
+		"invalid max-age": {
+			values:      []string{"2h=abc"},
+			expectedErr: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rules, err := parseCacheControlRules(test.values)
+			if test.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedRules, rules)
+		})
+	}
+}
+
+func TestCacheControlValue(t *testing.T) {
+	now := time.Now()
+	rules, err := parseCacheControlRules([]string{"2h=5m", "24h=1h"})
+	require.NoError(t, err)
+
+	unixSeconds := func(t time.Time) string {
+		return strconv.FormatInt(t.Unix(), 10)
+	}
+
+	for name, test := range map[string]struct {
+		queryString url.Values
+		expected    string
+	}{
+		"instant query without time": {
+			queryString: url.Values{"query": []string{"up"}},
+			expected:    cacheControlNoCache,
+		},
+		"query ending now": {
+			queryString: url.Values{"end": []string{unixSeconds(now)}},
+			expected:    cacheControlNoCache,
+		},
+		"query ending in the future": {
+			queryString: url.Values{"end": []string{unixSeconds(now.Add(time.Hour))}},
+			expected:    cacheControlNoCache,
+		},
+		"query ending 3 hours ago": {
+			queryString: url.Values{"end": []string{unixSeconds(now.Add(-3 * time.Hour))}},
+			expected:    "max-age=300",
+		},
+		"query ending 2 days ago, in RFC3339 format": {
+			queryString: url.Values{"end": []string{now.Add(-48 * time.Hour).Format(time.RFC3339)}},
+			expected:    "max-age=3600",
+		},
+		"instant query 3 hours ago": {
+			queryString: url.Values{"time": []string{unixSeconds(now.Add(-3 * time.Hour))}},
+			expected:    "max-age=300",
+		},
+		"invalid end": {
+			queryString: url.Values{"end": []string{"invalid"}},
+			expected:    cacheControlNoCache,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, cacheControlValue(rules, test.queryString, now))
+		})
+	}
+}
@@ -0,0 +1,256 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/httpgrpc"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// States of a tenant circuit breaker.
+const (
+	circuitBreakerClosed = iota
+	circuitBreakerOpen
+	circuitBreakerHalfOpen
+)
+
+// CircuitBreakerConfig configures the per-tenant circuit breaker of the query-frontend.
+type CircuitBreakerConfig struct {
+	Enabled              bool          `yaml:"enabled" category:"experimental"`
+	FailureRateThreshold float64       `yaml:"failure_rate_threshold" category:"experimental"`
+	LatencyThreshold     time.Duration `yaml:"latency_threshold" category:"experimental"`
+	MinRequests          int           `yaml:"min_requests" category:"experimental"`
+	Window               time.Duration `yaml:"window" category:"experimental"`
+	CoolDownPeriod       time.Duration `yaml:"cool_down_period" category:"experimental"`
+}
+
+func (cfg *CircuitBreakerConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "True to enable the per-tenant circuit breaker. When the failure rate of the queries of a tenant exceeds the threshold, the circuit breaker opens and the queries of the tenant are rejected with HTTP status code 503 for the cool-down period, protecting the queriers shared with the other tenants.")
+	f.Float64Var(&cfg.FailureRateThreshold, prefix+"failure-rate-threshold", 0.5, "Failure rate, between 0 and 1, at or above which the circuit breaker opens. Queries failed with a 5xx status code or timed out, and queries slower than the latency threshold, are counted as failures.")
+	f.DurationVar(&cfg.LatencyThreshold, prefix+"latency-threshold", 0, "Queries slower than this are counted as failures by the circuit breaker. 0 to only count the failed queries.")
+	f.IntVar(&cfg.MinRequests, prefix+"min-requests", 20, "Minimum number of queries of a tenant in the window before the circuit breaker can open.")
+	f.DurationVar(&cfg.Window, prefix+"window", time.Minute, "Window over which the failure rate of the queries of a tenant is computed.")
+	f.DurationVar(&cfg.CoolDownPeriod, prefix+"cool-down-period", 30*time.Second, "How long the circuit breaker stays open before letting a probe query through. If the probe query succeeds the circuit breaker closes, otherwise it stays open for another cool-down period.")
+}
+
+func (cfg *CircuitBreakerConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.FailureRateThreshold <= 0 || cfg.FailureRateThreshold > 1 {
+		return errors.New("the circuit breaker failure rate threshold must be greater than 0 and less than or equal to 1")
+	}
+	if cfg.MinRequests <= 0 {
+		return errors.New("the circuit breaker min requests must be greater than 0")
+	}
+	if cfg.Window <= 0 {
+		return errors.New("the circuit breaker window must be greater than 0")
+	}
+	if cfg.CoolDownPeriod <= 0 {
+		return errors.New("the circuit breaker cool-down period must be greater than 0")
+	}
+	return nil
+}
+
+// tenantCircuitBreaker is the circuit breaker state of a tenant.
+type tenantCircuitBreaker struct {
+	state int
+
+	// Queries and failures in the current window, while closed.
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	// When the cool-down period ends, while open.
+	openUntil time.Time
+
+	// Whether the probe query is in progress, while half-open.
+	probing bool
+}
+
+// circuitBreaker tracks the outcome of the queries of each tenant, and rejects the queries of
+// the tenants whose failure rate exceeds the threshold until the cool-down period ends.
+type circuitBreaker struct {
+	cfg CircuitBreakerConfig
+	now func() time.Time
+
+	mtx     sync.Mutex
+	tenants map[string]*tenantCircuitBreaker
+
+	// Deletes the state and metrics of the tenants without queries for a while.
+	activeTenants *util.ActiveUsersCleanupService
+
+	openedTotal *prometheus.CounterVec
+}
+
+func newCircuitBreaker(cfg CircuitBreakerConfig, reg prometheus.Registerer) *circuitBreaker {
+	cb := &circuitBreaker{
+		cfg:     cfg,
+		now:     time.Now,
+		tenants: map[string]*tenantCircuitBreaker{},
+		openedTotal: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_circuit_breaker_opened_total",
+			Help: "Number of times the circuit breaker of a tenant has been opened.",
+		}, []string{"user"}),
+	}
+	cb.activeTenants = util.NewActiveUsersCleanupWithDefaultValues(cb.deleteTenant)
+	return cb
+}
+
+// allow returns whether a query of the tenant can be executed. If not, it returns how long
+// the client should wait before retrying.
+func (cb *circuitBreaker) allow(tenantID string) (bool, time.Duration) {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+
+	t, ok := cb.tenants[tenantID]
+	if !ok {
+		return true, 0
+	}
+	cb.activeTenants.UpdateUserTimestamp(tenantID, time.Now())
+
+	switch t.state {
+	case circuitBreakerOpen:
+		if now := cb.now(); now.Before(t.openUntil) {
+			return false, t.openUntil.Sub(now)
+		}
+
+		// The cool-down period has ended: let a probe query through.
+		t.state = circuitBreakerHalfOpen
+		t.probing = true
+		return true, 0
+	case circuitBreakerHalfOpen:
+		if t.probing {
+			return false, cb.cfg.CoolDownPeriod
+		}
+		t.probing = true
+		return true, 0
+	default:
+		return true, 0
+	}
+}
+
+// record tracks the outcome of a query of the tenant allowed by allow.
+func (cb *circuitBreaker) record(tenantID string, failed bool) {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+
+	now := cb.now()
+	t, ok := cb.tenants[tenantID]
+	if !ok {
+		t = &tenantCircuitBreaker{windowStart: now}
+		cb.tenants[tenantID] = t
+	}
+	cb.activeTenants.UpdateUserTimestamp(tenantID, time.Now())
+
+	switch t.state {
+	case circuitBreakerHalfOpen:
+		// The outcome of the probe query decides whether the circuit breaker closes.
+		if failed {
+			cb.open(tenantID, t, now)
+			return
+		}
+		*t = tenantCircuitBreaker{windowStart: now}
+	case circuitBreakerClosed:
+		if now.Sub(t.windowStart) >= cb.cfg.Window {
+			t.windowStart, t.requests, t.failures = now, 0, 0
+		}
+
+		t.requests++
+		if failed {
+			t.failures++
+		}
+		if t.requests >= cb.cfg.MinRequests && float64(t.failures)/float64(t.requests) >= cb.cfg.FailureRateThreshold {
+			cb.open(tenantID, t, now)
+		}
+	}
+}
+
+// release tracks a query of the tenant allowed by allow whose outcome is unknown, like a query
+// canceled by the client. If it was the probe query, another one is let through.
+func (cb *circuitBreaker) release(tenantID string) {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+
+	if t, ok := cb.tenants[tenantID]; ok && t.state == circuitBreakerHalfOpen {
+		t.probing = false
+	}
+}
+
+func (cb *circuitBreaker) open(tenantID string, t *tenantCircuitBreaker, now time.Time) {
+	*t = tenantCircuitBreaker{state: circuitBreakerOpen, openUntil: now.Add(cb.cfg.CoolDownPeriod)}
+	cb.openedTotal.WithLabelValues(tenantID).Inc()
+}
+
+// deleteTenant removes the circuit breaker state and metrics of the tenant.
+func (cb *circuitBreaker) deleteTenant(tenantID string) {
+	cb.mtx.Lock()
+	defer cb.mtx.Unlock()
+
+	delete(cb.tenants, tenantID)
+	cb.openedTotal.DeleteLabelValues(tenantID)
+}
+
+// checkCircuitBreaker returns an error, rejecting the request with HTTP status code 503, if the circuit
+// breaker of the request's tenants is open. Otherwise it returns the function tracking the request outcome,
+// which must be called once the request has been executed.
+func (f *Handler) checkCircuitBreaker(w http.ResponseWriter, r *http.Request) (func(*http.Response, error, time.Duration), error) {
+	noop := func(*http.Response, error, time.Duration) {}
+	if f.circuitBreaker == nil {
+		return noop, nil
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return noop, nil
+	}
+	userID := tenant.JoinTenantIDs(tenantIDs)
+
+	if ok, retryAfter := f.circuitBreaker.allow(userID); !ok {
+		f.rejectedRequests.WithLabelValues(reasonCircuitBreakerOpen).Inc()
+		w.Header().Set("Retry-After", retryAfterSeconds(retryAfter, 0))
+		return noop, httpgrpc.Errorf(http.StatusServiceUnavailable, "the circuit breaker is open because of the high failure rate of the queries of the tenant %s, please retry later", userID)
+	}
+
+	return func(resp *http.Response, err error, queryResponseTime time.Duration) {
+		// The outcome of the queries canceled by the client is unknown.
+		if errors.Is(r.Context().Err(), context.Canceled) || errors.Is(err, context.Canceled) {
+			f.circuitBreaker.release(userID)
+			return
+		}
+		f.circuitBreaker.record(userID, isCircuitBreakerFailure(resp, err, queryResponseTime, f.cfg.CircuitBreaker.LatencyThreshold))
+	}, nil
+}
+
+// isCircuitBreakerFailure returns whether the outcome of a query is counted as a failure by the circuit breaker:
+// the query failed with a 5xx status code or timed out, or it's slower than the latency threshold, if any.
+func isCircuitBreakerFailure(resp *http.Response, err error, queryResponseTime, latencyThreshold time.Duration) bool {
+	if latencyThreshold > 0 && queryResponseTime > latencyThreshold {
+		return true
+	}
+	if err == nil {
+		return resp.StatusCode/100 == 5
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	if errResp, ok := apierror.HTTPResponseFromError(err); ok {
+		return errResp.Code/100 == 5
+	}
+	if errResp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		return errResp.Code/100 == 5
+	}
+	return true
+}
@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/services"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+func TestCircuitBreakerConfig_Validate(t *testing.T) {
+	valid := CircuitBreakerConfig{Enabled: true, FailureRateThreshold: 0.5, MinRequests: 10, Window: time.Minute, CoolDownPeriod: time.Minute}
+	require.NoError(t, valid.Validate())
+	require.NoError(t, (&CircuitBreakerConfig{}).Validate())
+
+	for name, update := range map[string]func(*CircuitBreakerConfig){
+		"zero failure rate threshold":        func(cfg *CircuitBreakerConfig) { cfg.FailureRateThreshold = 0 },
+		"failure rate threshold exceeding 1": func(cfg *CircuitBreakerConfig) { cfg.FailureRateThreshold = 1.5 },
+		"zero min requests":                  func(cfg *CircuitBreakerConfig) { cfg.MinRequests = 0 },
+		"zero window":                        func(cfg *CircuitBreakerConfig) { cfg.Window = 0 },
+		"zero cool-down period":              func(cfg *CircuitBreakerConfig) { cfg.CoolDownPeriod = 0 },
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := valid
+			update(&cfg)
+			require.Error(t, cfg.Validate())
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	cb := newCircuitBreaker(CircuitBreakerConfig{
+		Enabled:              true,
+		FailureRateThreshold: 0.5,
+		MinRequests:          4,
+		Window:               time.Minute,
+		CoolDownPeriod:       30 * time.Second,
+	}, nil)
+	cb.now = func() time.Time { return now }
+
+	// The circuit breaker doesn't open before the min requests.
+	for i := 0; i < 3; i++ {
+		ok, _ := cb.allow("user-1")
+		require.True(t, ok)
+		cb.record("user-1", true)
+	}
+
+	// The failures of a past window are not counted.
+	now = now.Add(time.Minute)
+	for _, failed := range []bool{true, false, false, true} {
+		ok, _ := cb.allow("user-1")
+		require.True(t, ok)
+		cb.record("user-1", failed)
+	}
+
+	// Other tenants are not affected.
+	cb.record("user-2", false)
+
+	ok, retryAfter := cb.allow("user-1")
+	require.False(t, ok)
+	assert.Equal(t, 30*time.Second, retryAfter)
+	ok, _ = cb.allow("user-2")
+	require.True(t, ok)
+	assert.Equal(t, float64(1), promtest.ToFloat64(cb.openedTotal.WithLabelValues("user-1")))
+
+	// A probe query is let through once the cool-down period ends, and the circuit breaker
+	// opens again if it fails.
+	now = now.Add(30 * time.Second)
+	ok, _ = cb.allow("user-1")
+	require.True(t, ok)
+	ok, _ = cb.allow("user-1")
+	require.False(t, ok, "only one probe query is let through")
+	cb.record("user-1", true)
+
+	ok, retryAfter = cb.allow("user-1")
+	require.False(t, ok)
+	assert.Equal(t, 30*time.Second, retryAfter)
+	assert.Equal(t, float64(2), promtest.ToFloat64(cb.openedTotal.WithLabelValues("user-1")))
+
+	// Another probe query is let through if the outcome of the previous one is unknown.
+	now = now.Add(30 * time.Second)
+	ok, _ = cb.allow("user-1")
+	require.True(t, ok)
+	cb.release("user-1")
+	ok, _ = cb.allow("user-1")
+	require.True(t, ok)
+
+	// The circuit breaker closes if the probe query succeeds.
+	cb.record("user-1", false)
+	for i := 0; i < 3; i++ {
+		ok, _ = cb.allow("user-1")
+		require.True(t, ok)
+		cb.record("user-1", true)
+	}
+	ok, _ = cb.allow("user-1")
+	require.True(t, ok)
+
+	cb.deleteTenant("user-1")
+	assert.Empty(t, cb.tenants["user-1"])
+}
+
+func TestCircuitBreaker_ShouldDeleteInactiveTenants(t *testing.T) {
+	cb := newCircuitBreaker(CircuitBreakerConfig{
+		Enabled:              true,
+		FailureRateThreshold: 0.5,
+		MinRequests:          1,
+		Window:               time.Minute,
+		CoolDownPeriod:       time.Hour,
+	}, nil)
+
+	// Clean up the inactive tenants quickly, to speed up the test.
+	cb.activeTenants = util.NewActiveUsersCleanupService(10*time.Millisecond, 100*time.Millisecond, cb.deleteTenant)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), cb.activeTenants))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), cb.activeTenants))
+	})
+
+	cb.record("user-1", true)
+	ok, _ := cb.allow("user-1")
+	require.False(t, ok)
+	require.Equal(t, 1, promtest.CollectAndCount(cb.openedTotal))
+
+	require.Eventually(t, func() bool {
+		cb.mtx.Lock()
+		defer cb.mtx.Unlock()
+		return len(cb.tenants) == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, promtest.CollectAndCount(cb.openedTotal))
+
+	ok, _ = cb.allow("user-1")
+	assert.True(t, ok)
+}
+
+func TestIsCircuitBreakerFailure(t *testing.T) {
+	ok := &http.Response{StatusCode: http.StatusOK}
+
+	assert.False(t, isCircuitBreakerFailure(ok, nil, time.Second, 0))
+	assert.False(t, isCircuitBreakerFailure(ok, nil, time.Second, 2*time.Second))
+	assert.True(t, isCircuitBreakerFailure(ok, nil, 3*time.Second, 2*time.Second))
+	assert.True(t, isCircuitBreakerFailure(&http.Response{StatusCode: http.StatusBadGateway}, nil, time.Second, 0))
+	assert.False(t, isCircuitBreakerFailure(&http.Response{StatusCode: http.StatusBadRequest}, nil, time.Second, 0))
+	assert.True(t, isCircuitBreakerFailure(nil, context.DeadlineExceeded, time.Second, 0))
+	assert.True(t, isCircuitBreakerFailure(nil, errors.New("connection refused"), time.Second, 0))
+	assert.True(t, isCircuitBreakerFailure(nil, httpgrpc.Errorf(http.StatusInternalServerError, "failed"), time.Second, 0))
+	assert.False(t, isCircuitBreakerFailure(nil, httpgrpc.Errorf(http.StatusTooManyRequests, "throttled"), time.Second, 0))
+	assert.False(t, isCircuitBreakerFailure(nil, apierror.New(apierror.TypeBadData, "invalid query"), time.Second, 0))
+}
+
+func TestHandler_CircuitBreaker(t *testing.T) {
+	failing := true
+	roundTripperCalls := 0
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		roundTripperCalls++
+		if failing {
+			return nil, httpgrpc.Errorf(http.StatusInternalServerError, "querier failure")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	reg := prometheus.NewPedanticRegistry()
+	cfg := HandlerConfig{CircuitBreaker: CircuitBreakerConfig{
+		Enabled:              true,
+		FailureRateThreshold: 1,
+		MinRequests:          2,
+		Window:               time.Minute,
+		CoolDownPeriod:       time.Minute,
+	}}
+	handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), reg)
+
+	serve := func(orgID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), orgID))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	// The circuit breaker opens after the failures.
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusInternalServerError, serve("tenant-1").Code)
+	}
+	require.Equal(t, 2, roundTripperCalls)
+
+	// The queries of the tenant are rejected without executing them.
+	resp := serve("tenant-1")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	assert.Contains(t, resp.Body.String(), "the circuit breaker is open")
+	assert.Equal(t, "60", resp.Header().Get("Retry-After"))
+	assert.Equal(t, 2, roundTripperCalls)
+
+	// The queries of other tenants are executed.
+	failing = false
+	assert.Equal(t, http.StatusOK, serve("tenant-2").Code)
+	assert.Equal(t, 3, roundTripperCalls)
+
+	assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_frontend_circuit_breaker_opened_total Number of times the circuit breaker of a tenant has been opened.
+		# TYPE cortex_query_frontend_circuit_breaker_opened_total counter
+		cortex_query_frontend_circuit_breaker_opened_total{user="tenant-1"} 1
+
+		# HELP cortex_query_frontend_rejected_requests_total Number of requests rejected by the query-frontend.
+		# TYPE cortex_query_frontend_rejected_requests_total counter
+		cortex_query_frontend_rejected_requests_total{reason="circuit_breaker_open"} 1
+	`), "cortex_query_frontend_circuit_breaker_opened_total", "cortex_query_frontend_rejected_requests_total"))
+}
@@ -14,23 +14,33 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/httpgrpc/server"
 
+	"github.com/grafana/dskit/backoff"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
 	"github.com/grafana/dskit/tenant"
 
 	apierror "github.com/grafana/mimir/pkg/api/error"
 	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/scheduler/queue"
 	"github.com/grafana/mimir/pkg/util"
 	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
@@ -39,46 +49,236 @@ const (
 	ServiceTimingHeaderName   = "Server-Timing"
 )
 
+// Reasons for requests rejected by the query-frontend.
+const (
+	reasonBodyTooLarge       = "body_too_large"
+	reasonQueryTimeout       = "query_timeout"
+	reasonBlockedQuery       = "blocked_query"
+	reasonResponseTooLarge   = "response_too_large"
+	reasonCircuitBreakerOpen = "circuit_breaker_open"
+	reasonUnauthenticated    = "unauthenticated"
+)
+
+// Outcomes of the queries received by the query-frontend.
+const (
+	resultSuccess        = "success"
+	resultClientCanceled = "client_canceled"
+	resultTimeout        = "timeout"
+	resultError          = "error"
+	resultPanic          = "panic"
+)
+
+// redactedParamValue replaces the values of the redacted request parameters in logs.
+const redactedParamValue = "***"
+
 var (
 	errCanceled              = httpgrpc.Errorf(StatusClientClosedRequest, context.Canceled.Error())
 	errDeadlineExceeded      = httpgrpc.Errorf(http.StatusGatewayTimeout, context.DeadlineExceeded.Error())
 	errRequestEntityTooLarge = httpgrpc.Errorf(http.StatusRequestEntityTooLarge, "http: request body too large")
+	errInternal              = httpgrpc.Errorf(http.StatusInternalServerError, "internal error")
+
+	// errResponseAborted is the panic value used to abort a response which has already started.
+	errResponseAborted = errors.New("response aborted")
 )
 
 // Config for a Handler.
 type HandlerConfig struct {
-	LogQueriesLongerThan time.Duration `yaml:"log_queries_longer_than"`
-	MaxBodySize          int64         `yaml:"max_body_size" category:"advanced"`
-	QueryStatsEnabled    bool          `yaml:"query_stats_enabled" category:"advanced"`
+	LogQueriesLongerThan time.Duration          `yaml:"log_queries_longer_than"`
+	MaxBodySize          int64                  `yaml:"max_body_size" category:"advanced"`
+	QueryStatsEnabled    bool                   `yaml:"query_stats_enabled" category:"advanced"`
+	StripResponseHeaders flagext.StringSliceCSV `yaml:"strip_response_headers" category:"experimental"`
+	HandlerMaxRetries    int                    `yaml:"handler_max_retries" category:"experimental"`
+	RetryMinBackoff      time.Duration          `yaml:"handler_retry_min_backoff" category:"experimental"`
+	RetryMaxBackoff      time.Duration          `yaml:"handler_retry_max_backoff" category:"experimental"`
+	TrustProxyHeaders    bool                   `yaml:"trust_proxy_headers" category:"advanced"`
+	RequestIDHeader      string                 `yaml:"request_id_header" category:"advanced"`
+
+	ServerTimingHeaderName         string `yaml:"server_timing_header_name" category:"advanced"`
+	ServerTimingExtraFieldsEnabled bool   `yaml:"server_timing_extra_fields_enabled" category:"experimental"`
+
+	QueryStatsExcludedPathPrefixes flagext.StringSliceCSV `yaml:"query_stats_excluded_path_prefixes" category:"experimental"`
+	StreamingPathPrefixes          flagext.StringSliceCSV `yaml:"streaming_path_prefixes" category:"experimental"`
+	RedactedQueryParams            flagext.StringSliceCSV `yaml:"redacted_query_params" category:"experimental"`
+
+	CacheControlMaxAgeRules flagext.StringSliceCSV `yaml:"cache_control_max_age_rules" category:"experimental"`
+
+	AsyncReportingWorkers   int `yaml:"async_reporting_workers" category:"experimental"`
+	AsyncReportingQueueSize int `yaml:"async_reporting_queue_size" category:"experimental"`
+
+	AuditLog       AuditLogConfig       `yaml:"audit_log"`
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	QueryInsights  QueryInsightsConfig  `yaml:"query_insights"`
+
+	ThrottledQueryRetryAfter time.Duration `yaml:"throttled_query_retry_after" category:"experimental"`
+
+	// RequestAuthenticators are invoked in order on every request before it's forwarded downstream.
+	// They're injected by the upstream caller.
+	RequestAuthenticators []RequestAuthenticator `yaml:"-"`
 }
 
 func (cfg *HandlerConfig) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.LogQueriesLongerThan, "query-frontend.log-queries-longer-than", 0, "Log queries that are slower than the specified duration. Set to 0 to disable. Set to < 0 to enable on all queries.")
 	f.Int64Var(&cfg.MaxBodySize, "query-frontend.max-body-size", 10*1024*1024, "Max body size for downstream prometheus.")
 	f.BoolVar(&cfg.QueryStatsEnabled, "query-frontend.query-stats-enabled", true, "False to disable query statistics tracking. When enabled, a message with some statistics is logged for every query.")
+	f.Var(&cfg.StripResponseHeaders, "query-frontend.strip-response-headers", "Comma-separated list of headers to remove from the downstream response before returning it to the client. Header names are case-insensitive.")
+	f.IntVar(&cfg.HandlerMaxRetries, "query-frontend.handler-max-retries", 0, "Maximum number of times an idempotent (GET or HEAD) request is retried when the downstream fails with a transient error: HTTP status code 502, 503 or 504, or the connection to the downstream refused or reset. This applies to every request received by the query-frontend, in addition to -query-frontend.max-retries-per-request. 0 to disable.")
+	f.DurationVar(&cfg.RetryMinBackoff, "query-frontend.handler-retry-min-backoff", 100*time.Millisecond, "Minimum delay before retrying a request failed with a transient downstream error.")
+	f.DurationVar(&cfg.RetryMaxBackoff, "query-frontend.handler-retry-max-backoff", time.Second, "Maximum delay before retrying a request failed with a transient downstream error.")
+	f.BoolVar(&cfg.TrustProxyHeaders, "query-frontend.trust-proxy-headers", false, "True to trust the X-Forwarded-For and X-Real-IP headers when logging the client address in the query stats. Enable it only if the query-frontend is behind a trusted proxy setting these headers.")
+	f.StringVar(&cfg.RequestIDHeader, "query-frontend.request-id-header", "X-Request-ID", "Name of the header carrying the request correlation ID. If the header is missing in the received request, a new ID is generated. The ID is forwarded downstream, returned in the response and logged as request_id. Set to empty to disable.")
+	f.StringVar(&cfg.ServerTimingHeaderName, "query-frontend.server-timing-header-name", ServiceTimingHeaderName, "Name of the response header carrying the query timings, when query statistics are enabled.")
+	f.BoolVar(&cfg.ServerTimingExtraFieldsEnabled, "query-frontend.server-timing-extra-fields-enabled", false, "True to include the number of fetched series, chunks, chunk bytes and index bytes, and the number of sharded and split queries, in the query timings response header, in addition to the querier wall time and response time. Clients can use them to show the query cost to users.")
+	f.Var(&cfg.QueryStatsExcludedPathPrefixes, "query-frontend.query-stats-excluded-path-prefixes", "Comma-separated list of request path prefixes (for example /prometheus/api/v1/labels) for which query statistics are not tracked and the request body is not buffered. Slow queries on these paths are logged without the request body parameters.")
+	f.Var(&cfg.StreamingPathPrefixes, "query-frontend.streaming-path-prefixes", "Comma-separated list of request path prefixes for which the response is flushed to the client as soon as each part of it is received from downstream, instead of being written in a single chunk at the end.")
+	f.Var(&cfg.RedactedQueryParams, "query-frontend.redacted-query-params", "Comma-separated list of request parameter names whose values are replaced with *** in the slow queries and query stats logs. Names are case-insensitive.")
+	f.Var(&cfg.CacheControlMaxAgeRules, "query-frontend.cache-control-max-age-rules", "Comma-separated list of rules in the format <min end age>=<max-age> (for example 2h=5m,24h=1h), used to set the Cache-Control header of successful responses not already having it. The max-age of the rule with the greatest min end age not exceeding how far in the past the query end is gets used. Queries not matching any rule, like the ones ending now, get no-cache. Empty to disable.")
+	f.IntVar(&cfg.AsyncReportingWorkers, "query-frontend.async-reporting-workers", 0, "Number of workers reporting the query stats and slow queries in the background, so that the request goroutine returns as soon as the response has been written. When the queue of pending reports is full, the report is done synchronously. 0 to report synchronously.")
+	f.IntVar(&cfg.AsyncReportingQueueSize, "query-frontend.async-reporting-queue-size", 1000, "Max number of pending reports queued for the async reporting workers.")
+	cfg.AuditLog.RegisterFlagsWithPrefix("query-frontend.audit-log.", f)
+	cfg.CircuitBreaker.RegisterFlagsWithPrefix("query-frontend.circuit-breaker.", f)
+	cfg.QueryInsights.RegisterFlagsWithPrefix("query-frontend.query-insights.", f)
+	f.DurationVar(&cfg.ThrottledQueryRetryAfter, "query-frontend.throttled-query-retry-after", 5*time.Second, "Base delay returned in the Retry-After header of the responses to queries throttled because of the per-tenant concurrency or rate limits, which are rejected with HTTP status code 429. A random jitter up to half of the base delay is added, so that the throttled clients don't retry all at once. 0 to not set the Retry-After header.")
+}
+
+func (cfg *HandlerConfig) Validate() error {
+	if _, err := parseCacheControlRules(cfg.CacheControlMaxAgeRules); err != nil {
+		return err
+	}
+	if err := cfg.AuditLog.Validate(); err != nil {
+		return err
+	}
+	if err := cfg.CircuitBreaker.Validate(); err != nil {
+		return err
+	}
+	return cfg.QueryInsights.Validate()
+}
+
+// Limits are the per-tenant limits enforced by the Handler.
+type Limits interface {
+	// QueryTimeout returns the maximum time a query can take to execute in the query-frontend.
+	QueryTimeout(userID string) time.Duration
+
+	// SlowQueryLogThreshold returns the duration above which queries are logged as slow.
+	// 0 means HandlerConfig.LogQueriesLongerThan applies.
+	SlowQueryLogThreshold(userID string) time.Duration
+
+	// BlockedQueries returns the queries rejected before being forwarded downstream.
+	BlockedQueries(userID string) []*validation.BlockedQuery
+
+	// MaxResponseSizeBytes returns the maximum size of a response returned to the client. 0 means no limit.
+	MaxResponseSizeBytes(userID string) int
 }
 
 // Handler accepts queries and forwards them to RoundTripper. It can log slow queries,
 // but all other logic is inside the RoundTripper.
 type Handler struct {
 	cfg          HandlerConfig
+	limits       Limits
 	log          log.Logger
 	roundTripper http.RoundTripper
 
+	// Lowercase names of the request parameters whose values are redacted in logs.
+	redactedParams map[string]struct{}
+
+	// Rules used to set the Cache-Control header of the responses, sorted by min end age.
+	cacheControlRules []cacheControlRule
+
 	// Metrics.
-	querySeconds *prometheus.CounterVec
-	querySeries  *prometheus.CounterVec
-	queryBytes   *prometheus.CounterVec
-	queryChunks  *prometheus.CounterVec
-	activeUsers  *util.ActiveUsersCleanupService
+	querySeconds  *prometheus.CounterVec
+	queryDuration *prometheus.HistogramVec
+	queryWallTime *prometheus.HistogramVec
+	querySeries   *prometheus.CounterVec
+	queryBytes    *prometheus.CounterVec
+	queryChunks   *prometheus.CounterVec
+	queryIndex    *prometheus.CounterVec
+	querySamples  *prometheus.CounterVec
+	activeUsers   *util.ActiveUsersCleanupService
+
+	rejectedRequests *prometheus.CounterVec
+	queryResults     *prometheus.CounterVec
+	throttledQueries *prometheus.CounterVec
+
+	// Query audit log, nil if disabled.
+	auditLog *auditLogger
+
+	// Per-tenant circuit breaker, nil if disabled.
+	circuitBreaker *circuitBreaker
+
+	// Compiled regular expressions of the blocked queries, keyed by pattern.
+	blockedQueryRegexps sync.Map
+
+	// Store of the recent queries served by the query insights endpoint, nil if disabled.
+	queryInsights *queryInsightsStore
+
+	// Queue of the reports run by the async reporting workers, nil if async reporting is disabled.
+	// The queue is closed by Stop, and reportsMtx guards sending to it against closing it.
+	reportsMtx       sync.RWMutex
+	reportsStopped   bool
+	reportsWorkers   sync.WaitGroup
+	reports          chan func()
+	syncReportsTotal prometheus.Counter
 }
 
 // NewHandler creates a new frontend handler.
-func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logger, reg prometheus.Registerer) http.Handler {
+func NewHandler(cfg HandlerConfig, limits Limits, roundTripper http.RoundTripper, log log.Logger, reg prometheus.Registerer) *Handler {
 	h := &Handler{
 		cfg:          cfg,
+		limits:       limits,
 		log:          log,
 		roundTripper: roundTripper,
+		rejectedRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_rejected_requests_total",
+			Help: "Number of requests rejected by the query-frontend.",
+		}, []string{"reason"}),
+		queryResults: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_query_results_total",
+			Help: "Number of queries received by the query-frontend, by result.",
+		}, []string{"result"}),
+		throttledQueries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_throttled_queries_total",
+			Help: "Number of queries rejected with HTTP status code 429 because of the per-tenant concurrency or rate limits.",
+		}, []string{"user"}),
+	}
+
+	if cfg.AsyncReportingWorkers > 0 {
+		h.reports = make(chan func(), cfg.AsyncReportingQueueSize)
+		h.syncReportsTotal = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_async_reporting_queue_full_total",
+			Help: "Number of query reports run synchronously because the async reporting queue was full.",
+		})
+
+		h.reportsWorkers.Add(cfg.AsyncReportingWorkers)
+		for i := 0; i < cfg.AsyncReportingWorkers; i++ {
+			go h.reportingWorker(h.reports)
+		}
+	}
+
+	// The config is expected to be validated, so invalid rules just disable the Cache-Control header.
+	h.cacheControlRules, _ = parseCacheControlRules(cfg.CacheControlMaxAgeRules)
+
+	if cfg.AuditLog.Sink != "" {
+		if sink, err := newAuditLogSink(cfg.AuditLog); err != nil {
+			level.Warn(log).Log("msg", "query audit log disabled", "err", err)
+		} else {
+			h.auditLog = newAuditLogger(cfg.AuditLog, sink, log, reg)
+		}
+	}
+
+	if cfg.CircuitBreaker.Enabled {
+		h.circuitBreaker = newCircuitBreaker(cfg.CircuitBreaker, reg)
+		// If cleaner stops or fail, we will simply not clean the circuit breaker of inactive users.
+		_ = h.circuitBreaker.activeTenants.StartAsync(context.Background())
+	}
+
+	if cfg.QueryInsights.Enabled {
+		h.queryInsights = newQueryInsightsStore(cfg.QueryInsights)
+	}
+
+	if len(cfg.RedactedQueryParams) > 0 {
+		h.redactedParams = make(map[string]struct{}, len(cfg.RedactedQueryParams))
+		for _, name := range cfg.RedactedQueryParams {
+			h.redactedParams[strings.ToLower(name)] = struct{}{}
+		}
 	}
 
 	if cfg.QueryStatsEnabled {
@@ -87,6 +287,18 @@ func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logge
 			Help: "Total amount of wall clock time spend processing queries.",
 		}, []string{"user", "sharded"})
 
+		h.queryDuration = promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_query_frontend_query_duration_seconds",
+			Help:    "Time taken by the query-frontend to execute a query.",
+			Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30, 60, 120},
+		}, []string{"user", "sharded"})
+
+		h.queryWallTime = promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_query_wall_time_seconds",
+			Help:    "Estimated wall clock time spent processing a query, summed across all the queriers executing it.",
+			Buckets: []float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300, 600},
+		}, []string{"user"})
+
 		h.querySeries = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "cortex_query_fetched_series_total",
 			Help: "Number of series fetched to execute a query.",
@@ -102,13 +314,17 @@ func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logge
 			Help: "Number of chunks fetched to execute a query.",
 		}, []string{"user"})
 
-		h.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(func(user string) {
-			h.querySeconds.DeleteLabelValues(user, "true")
-			h.querySeconds.DeleteLabelValues(user, "false")
-			h.querySeries.DeleteLabelValues(user)
-			h.queryBytes.DeleteLabelValues(user)
-			h.queryChunks.DeleteLabelValues(user)
-		})
+		h.queryIndex = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_fetched_index_bytes_total",
+			Help: "Number of TSDB index bytes fetched from store-gateway to execute a query.",
+		}, []string{"user"})
+
+		h.querySamples = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_samples_processed_total",
+			Help: "Number of samples processed to execute a query.",
+		}, []string{"user"})
+
+		h.activeUsers = util.NewActiveUsersCleanupWithDefaultValues(h.cleanupInactiveUserMetrics)
 		// If cleaner stops or fail, we will simply not clean the metrics for inactive users.
 		_ = h.activeUsers.StartAsync(context.Background())
 	}
@@ -116,65 +332,443 @@ func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logge
 	return h
 }
 
-func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+// cleanupInactiveUserMetrics removes the query stats metrics of a tenant without queries for a while.
+func (f *Handler) cleanupInactiveUserMetrics(user string) {
+	f.querySeconds.DeleteLabelValues(user, "true")
+	f.querySeconds.DeleteLabelValues(user, "false")
+	f.queryDuration.DeleteLabelValues(user, "true")
+	f.queryDuration.DeleteLabelValues(user, "false")
+	f.queryWallTime.DeleteLabelValues(user)
+	f.querySeries.DeleteLabelValues(user)
+	f.queryBytes.DeleteLabelValues(user)
+	f.queryChunks.DeleteLabelValues(user)
+	f.queryIndex.DeleteLabelValues(user)
+	f.querySamples.DeleteLabelValues(user)
+	f.throttledQueries.DeleteLabelValues(user)
+}
+
+// Stop stops the async reporting workers, once they have run the reports queued so far, the query audit
+// log, once it has written the entries queued so far, and the cleanup of the metrics and circuit breakers
+// of inactive tenants. The reports of the requests served after Stop are run synchronously, and their
+// audit log entries are dropped.
+func (f *Handler) Stop() {
+	f.reportsMtx.Lock()
+	if f.reports != nil && !f.reportsStopped {
+		f.reportsStopped = true
+		close(f.reports)
+	}
+	f.reportsMtx.Unlock()
+	f.reportsWorkers.Wait()
+
+	// The audit log is stopped after the workers, so that it writes the entries of the reports they have run.
+	if f.auditLog != nil {
+		f.auditLog.stop()
+	}
+
+	if f.activeUsers != nil {
+		_ = services.StopAndAwaitTerminated(context.Background(), f.activeUsers)
+	}
+	if f.circuitBreaker != nil {
+		_ = services.StopAndAwaitTerminated(context.Background(), f.circuitBreaker.activeTenants)
+	}
+}
+
+func (f *Handler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
 	var (
 		stats       *querier_stats.Stats
 		queryString url.Values
+		result      string
 	)
 
+	// Make sure the response header is written once, and track whether the response has started.
+	w := &headerTrackingResponseWriter{ResponseWriter: rw}
+
+	defer func() {
+		p := recover()
+		if p != nil && p != errResponseAborted {
+			result = resultPanic
+		}
+		if result != "" {
+			f.queryResults.WithLabelValues(result).Inc()
+		}
+		if p == nil {
+			return
+		}
+
+		if p != http.ErrAbortHandler && p != errResponseAborted {
+			level.Error(util_log.WithContext(r.Context(), f.log)).Log("msg", "panic while serving the request", "path", r.URL.Path, "panic", p, "stack", string(debug.Stack()))
+		}
+
+		// If the response has already started we can't write an error, so we abort it
+		// to make sure the client doesn't receive a truncated body as a valid response.
+		if w.wroteHeader {
+			panic(http.ErrAbortHandler)
+		}
+		writeError(w, errInternal)
+	}()
+
+	// Requests to excluded paths are served as if query stats were disabled, and their body is not buffered.
+	excluded := f.isExcludedFromQueryStats(r)
+	statsEnabled := f.cfg.QueryStatsEnabled && !excluded
+
 	// Initialise the stats in the context and make sure it's propagated
 	// down the request chain.
-	if f.cfg.QueryStatsEnabled {
+	if statsEnabled {
 		var ctx context.Context
 		stats, ctx = querier_stats.ContextWithEmptyStats(r.Context())
 		r = r.WithContext(ctx)
 	}
 
+	// Classify the query priority, propagated downstream so that the query-scheduler can dequeue
+	// higher priority queries first.
+	r = r.WithContext(queue.ContextWithPriorityClass(r.Context(), queue.ClassifyRequestPriority(r)))
+
 	defer func() {
 		_ = r.Body.Close()
 	}()
 
-	// Buffer the body for later use to track slow queries.
-	var buf bytes.Buffer
-	r.Body = http.MaxBytesReader(w, r.Body, f.cfg.MaxBodySize)
-	r.Body = io.NopCloser(io.TeeReader(r.Body, &buf))
+	// Ensure the request has a correlation ID, forwarded downstream and returned to the client.
+	if f.cfg.RequestIDHeader != "" {
+		requestID := r.Header.Get(f.cfg.RequestIDHeader)
+		if requestID == "" {
+			requestID = uuid.NewString()
+			r.Header.Set(f.cfg.RequestIDHeader, requestID)
+		}
+		w.Header().Set(f.cfg.RequestIDHeader, requestID)
+	}
+
+	// Authenticate the request before anything depending on its tenant ID, since it may be overridden.
+	r, authErr := f.authenticateRequest(r)
+
+	// The query insights are served by the query-frontend itself, and the request isn't tracked as a query.
+	if authErr == nil && f.isQueryInsightsRequest(r) {
+		if err := f.serveQueryInsights(w, r); err != nil {
+			writeError(w, err)
+		}
+		return
+	}
+
+	var (
+		buf     bytes.Buffer
+		bodyBuf *bytes.Buffer
+		body    io.Reader
+	)
+
+	r.Body = http.MaxBytesReader(rw, r.Body, f.cfg.MaxBodySize)
+
+	// Buffer the body for later use to track slow queries, unless the path is excluded.
+	if !excluded {
+		body = io.TeeReader(r.Body, &buf)
+		bodyBuf = &buf
+		r.Body = io.NopCloser(body)
+	}
+
+	// Enforce the per-tenant query timeout, if any.
+	parentCtx := r.Context()
+	timeout := f.queryTimeout(r)
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(parentCtx, timeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
 
 	startTime := time.Now()
-	resp, err := f.roundTripper.RoundTrip(r)
+	var (
+		resp                *http.Response
+		trackCircuitBreaker func(*http.Response, error, time.Duration)
+	)
+	err := authErr
+	if err == nil {
+		err = f.checkBlockedQuery(r)
+	}
+	if err == nil {
+		trackCircuitBreaker, err = f.checkCircuitBreaker(w, r)
+	}
+	if err == nil {
+		resp, err = f.roundTripWithRetries(r, body, bodyBuf)
+		trackCircuitBreaker(resp, err, time.Since(startTime))
+	}
 	queryResponseTime := time.Since(startTime)
 
+	// The response is discarded if the round trip failed, even if the downstream returned one.
+	if err != nil && resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+
+	// Make sure a query failed because the deadline has been exceeded is reported as such,
+	// regardless of the error returned by the downstream.
+	if err != nil && errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		err = context.DeadlineExceeded
+	}
+
+	// Flush the response as it gets copied for streaming endpoints, if the writer supports it.
+	flusher, streaming := rw.(http.Flusher)
+	streaming = streaming && f.isStreamingPath(r)
+
+	// Reject the responses exceeding the max response size before they start. Streamed responses
+	// can only be checked while copying them.
+	maxResponseSize := f.maxResponseSize(r)
+	responseTooLarge := false
+	if err == nil && maxResponseSize > 0 {
+		responseTooLarge, err = exceedsMaxResponseSize(resp, maxResponseSize, streaming)
+		if responseTooLarge {
+			err = apierror.New(apierror.TypeExec, validation.NewMaxResponseSizeError(int(maxResponseSize)).Error())
+		}
+	}
+
+	// Return the queries throttled downstream as errors telling the client when to retry.
+	throttled := false
+	if throttledErr := throttledQueryError(resp, err); throttledErr != nil {
+		err, throttled = throttledErr, true
+	}
+
+	result = queryResult(err)
+
 	if err != nil {
-		writeError(w, err)
-		queryString = f.parseRequestQueryString(r, buf)
-		f.reportQueryStats(r, queryString, queryResponseTime, stats, err)
+		// Track the requests rejected because of the limits enforced by the query-frontend.
+		if authErr != nil {
+			f.rejectedRequests.WithLabelValues(reasonUnauthenticated).Inc()
+		} else if util.IsRequestBodyTooLarge(err) {
+			f.rejectedRequests.WithLabelValues(reasonBodyTooLarge).Inc()
+		} else if timeout > 0 && errors.Is(err, context.DeadlineExceeded) && parentCtx.Err() == nil {
+			f.rejectedRequests.WithLabelValues(reasonQueryTimeout).Inc()
+		} else if responseTooLarge {
+			f.rejectedRequests.WithLabelValues(reasonResponseTooLarge).Inc()
+		} else if throttled {
+			f.trackThrottledQuery(w, r)
+		}
+
+		if w.wroteHeader {
+			level.Warn(util_log.WithContext(r.Context(), f.log)).Log("msg", "unable to write the error response because the response has already started", "err", err)
+		} else {
+			writeError(w, err)
+		}
+		if statsEnabled {
+			f.addQueryStatsToSpan(r, stats)
+		}
+		f.report(r, func(r *http.Request) {
+			queryString := f.parseRequestQueryString(r, buf)
+			f.reportQueryStats(r, queryString, queryResponseTime, stats, err)
+			f.auditQuery(r, queryString, startTime, queryResponseTime, 0, stats, err)
+			f.recordQueryInsight(r, queryString, startTime, queryResponseTime, stats, err)
+		})
 		return
 	}
 
+	slowQueryThreshold := f.slowQueryLogThreshold(r)
+	shouldReportSlowQuery := slowQueryThreshold > 0 && queryResponseTime > slowQueryThreshold
+
+	// The query string is parsed before writing the response only if it's needed to build it.
+	queryStringParsed := statsEnabled || len(f.cacheControlRules) > 0
+	if queryStringParsed {
+		queryString = f.parseRequestQueryString(r, buf)
+	}
+
+	// Include the query stats in the response body if requested by the client.
+	if statsEnabled && queryString.Get(queryStatsParam) != "" {
+		if err := embedQueryStatsInResponse(resp, stats); err != nil {
+			level.Warn(util_log.WithContext(r.Context(), f.log)).Log("msg", "failed to include query stats in the response", "err", err)
+		}
+	}
+
+	for _, h := range f.cfg.StripResponseHeaders {
+		resp.Header.Del(h)
+	}
+
+	f.setCacheControlHeader(resp, queryString)
+
 	hs := w.Header()
 	for h, vs := range resp.Header {
 		hs[h] = vs
 	}
 
-	if f.cfg.QueryStatsEnabled {
-		writeServiceTimingHeader(queryResponseTime, hs, stats)
+	// Streamed responses are sent with chunked transfer encoding, and their Server-Timing header is
+	// sent as a trailer once the response has been copied, so that the response time includes streaming it.
+	if streaming {
+		hs.Del("Content-Length")
+	}
+	if statsEnabled {
+		if streaming {
+			hs.Add("Trailer", f.serverTimingHeaderName())
+		} else {
+			f.writeServiceTimingHeader(queryResponseTime, hs, stats)
+		}
 	}
 
 	w.WriteHeader(resp.StatusCode)
-	// we don't check for copy error as there is no much we can do at this point
-	_, _ = io.Copy(w, resp.Body)
 
-	// Check whether we should parse the query string.
-	shouldReportSlowQuery := f.cfg.LogQueriesLongerThan > 0 && queryResponseTime > f.cfg.LogQueriesLongerThan
-	if shouldReportSlowQuery || f.cfg.QueryStatsEnabled {
-		queryString = f.parseRequestQueryString(r, buf)
+	var dst io.Writer = w
+	if streaming {
+		flusher.Flush()
+		dst = &flushWriter{Writer: w, flusher: flusher}
+	}
+
+	if exceeded := copyResponse(dst, resp.Body, maxResponseSize); exceeded {
+		f.rejectedRequests.WithLabelValues(reasonResponseTooLarge).Inc()
+		level.Warn(util_log.WithContext(r.Context(), f.log)).Log("msg", "aborted the response exceeding the max response size", "path", r.URL.Path, "max_response_size_bytes", maxResponseSize)
+
+		// The response has already started, so the only way to signal the client it's incomplete is aborting it.
+		result = resultError
+		panic(errResponseAborted)
 	}
 
-	if shouldReportSlowQuery {
-		f.reportSlowQuery(r, queryString, queryResponseTime)
+	if statsEnabled && streaming {
+		f.writeServiceTimingHeader(time.Since(startTime), hs, stats)
 	}
-	if f.cfg.QueryStatsEnabled {
-		f.reportQueryStats(r, queryString, queryResponseTime, stats, nil)
+
+	if !shouldReportSlowQuery && !statsEnabled && f.auditLog == nil && f.queryInsights == nil {
+		return
 	}
+
+	statusCode := resp.StatusCode
+
+	if statsEnabled {
+		f.addQueryStatsToSpan(r, stats)
+	}
+	f.report(r, func(r *http.Request) {
+		if !queryStringParsed {
+			queryString = f.parseRequestQueryString(r, buf)
+		}
+		if shouldReportSlowQuery {
+			f.reportSlowQuery(r, queryString, queryResponseTime)
+		}
+		if statsEnabled {
+			f.reportQueryStats(r, queryString, queryResponseTime, stats, nil)
+		}
+		f.auditQuery(r, queryString, startTime, queryResponseTime, statusCode, stats, nil)
+		f.recordQueryInsight(r, queryString, startTime, queryResponseTime, stats, nil)
+	})
+}
+
+// report runs the reporting function fn, in the async reporting workers if enabled. If the queue of
+// pending reports is full or the workers have been stopped, fn is run synchronously. fn receives a
+// shallow copy of the request, so that it can modify it even after ServeHTTP returned.
+func (f *Handler) report(r *http.Request, fn func(r *http.Request)) {
+	if f.reports == nil {
+		fn(r)
+		return
+	}
+
+	// The request span is finished once ServeHTTP returns, so the report only gets its span context.
+	r = r.WithContext(contextWithDetachedSpan(r.Context()))
+
+	f.reportsMtx.RLock()
+	stopped, queued := f.reportsStopped, false
+	if !stopped {
+		select {
+		case f.reports <- func() { fn(r) }:
+			queued = true
+		default:
+		}
+	}
+	f.reportsMtx.RUnlock()
+
+	if queued {
+		return
+	}
+	if !stopped {
+		f.syncReportsTotal.Inc()
+	}
+	fn(r)
+}
+
+// reportingWorker runs the reports queued by ServeHTTP, until the queue is closed by Stop.
+func (f *Handler) reportingWorker(reports <-chan func()) {
+	defer f.reportsWorkers.Done()
+
+	for report := range reports {
+		report()
+	}
+}
+
+// detachedSpan is a no-op span carrying the context of another span, so that the async reports can
+// log the trace ID without touching the request span once finished.
+type detachedSpan struct {
+	opentracing.Span
+	spanContext opentracing.SpanContext
+}
+
+func (s detachedSpan) Context() opentracing.SpanContext {
+	return s.spanContext
+}
+
+// contextWithDetachedSpan returns a copy of ctx whose span, if any, is replaced by a detachedSpan.
+func contextWithDetachedSpan(ctx context.Context) context.Context {
+	span := opentracing.SpanFromContext(ctx)
+	if span == nil {
+		return ctx
+	}
+
+	return opentracing.ContextWithSpan(ctx, detachedSpan{
+		Span:        opentracing.NoopTracer{}.StartSpan(""),
+		spanContext: span.Context(),
+	})
+}
+
+// roundTripWithRetries executes the request and, if enabled, retries idempotent requests
+// failing with a transient downstream error. The input body is the reader of the request body
+// not consumed yet, while buf holds the request body read so far. Requests are not retried
+// if buf is nil, because the request body can't be rewound.
+func (f *Handler) roundTripWithRetries(r *http.Request, body io.Reader, buf *bytes.Buffer) (*http.Response, error) {
+	resp, err := f.roundTripper.RoundTrip(r)
+	if f.cfg.HandlerMaxRetries <= 0 || !isIdempotentMethod(r.Method) || buf == nil {
+		return resp, err
+	}
+
+	retries := backoff.New(r.Context(), backoff.Config{
+		MinBackoff: f.cfg.RetryMinBackoff,
+		MaxBackoff: f.cfg.RetryMaxBackoff,
+		MaxRetries: f.cfg.HandlerMaxRetries,
+	})
+
+	for isRetryable(resp, err) && r.Context().Err() == nil && retries.Ongoing() {
+		retries.Wait()
+
+		// Stop immediately if the request has been canceled while waiting.
+		if r.Context().Err() != nil {
+			break
+		}
+
+		logger := util_log.WithContext(r.Context(), f.log)
+		if err != nil {
+			level.Warn(logger).Log("msg", "retrying request failed with a transient downstream error", "path", r.URL.Path, "err", err, "retry", retries.NumRetries())
+		} else {
+			level.Warn(logger).Log("msg", "retrying request failed with a transient downstream error", "path", r.URL.Path, "status_code", resp.StatusCode, "retry", retries.NumRetries())
+		}
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+		}
+
+		// Rewind the request body: what has been read so far is replayed from the buffer,
+		// while the rest (if any) is still read from the original body.
+		consumed := append([]byte(nil), buf.Bytes()...)
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(consumed), body))
+
+		resp, err = f.roundTripper.RoundTrip(r)
+	}
+
+	return resp, err
+}
+
+func isIdempotentMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// isRetryable returns whether the round trip failed with a transient downstream error: either a
+// response with a retryable status code, the same status code carried by an httpgrpc error, or the
+// connection to the downstream refused or reset.
+func isRetryable(resp *http.Response, err error) bool {
+	if err == nil {
+		return isRetryableStatusCode(resp.StatusCode)
+	}
+	if errResp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		return isRetryableStatusCode(int(errResp.Code))
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func isRetryableStatusCode(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
 }
 
 // reportSlowQuery reports slow queries.
@@ -185,11 +779,30 @@ func (f *Handler) reportSlowQuery(r *http.Request, queryString url.Values, query
 		"host", r.Host,
 		"path", r.URL.Path,
 		"time_taken", queryResponseTime.String(),
-	}, formatQueryString(queryString)...)
+	}, f.requestIDLogFields(r)...)
+	logMessage = append(logMessage, f.formatQueryString(queryString)...)
 
 	level.Info(util_log.WithContext(r.Context(), f.log)).Log(logMessage...)
 }
 
+// addQueryStatsToSpan adds the query stats to the active span, if any, so that they can be searched
+// in the tracing UI. It must be called before ServeHTTP returns, while the span is not finished yet.
+func (f *Handler) addQueryStatsToSpan(r *http.Request, stats *querier_stats.Stats) {
+	span := opentracing.SpanFromContext(r.Context())
+	if span == nil || stats == nil {
+		return
+	}
+
+	span.SetTag("query_wall_time_seconds", stats.LoadWallTime().Seconds())
+	span.SetTag("fetched_series_count", stats.LoadFetchedSeries())
+	span.SetTag("fetched_chunk_bytes", stats.LoadFetchedChunkBytes())
+	span.SetTag("fetched_chunks_count", stats.LoadFetchedChunks())
+	span.SetTag("fetched_index_bytes", stats.LoadFetchedIndexBytes())
+	span.SetTag("samples_processed", stats.LoadSamplesProcessed())
+	span.SetTag("sharded_queries", stats.LoadShardedQueries())
+	span.SetTag("split_queries", stats.LoadSplitQueries())
+}
+
 func (f *Handler) reportQueryStats(r *http.Request, queryString url.Values, queryResponseTime time.Duration, stats *querier_stats.Stats, queryErr error) {
 	tenantIDs, err := tenant.TenantIDs(r.Context())
 	if err != nil {
@@ -201,14 +814,19 @@ func (f *Handler) reportQueryStats(r *http.Request, queryString url.Values, quer
 	numBytes := stats.LoadFetchedChunkBytes()
 	numChunks := stats.LoadFetchedChunks()
 	numIndexBytes := stats.LoadFetchedIndexBytes()
+	numSamples := stats.LoadSamplesProcessed()
 	sharded := strconv.FormatBool(stats.GetShardedQueries() > 0)
 
 	if stats != nil {
 		// Track stats.
 		f.querySeconds.WithLabelValues(userID, sharded).Add(wallTime.Seconds())
+		f.queryDuration.WithLabelValues(userID, sharded).Observe(queryResponseTime.Seconds())
+		f.queryWallTime.WithLabelValues(userID).Observe(wallTime.Seconds())
 		f.querySeries.WithLabelValues(userID).Add(float64(numSeries))
 		f.queryBytes.WithLabelValues(userID).Add(float64(numBytes))
 		f.queryChunks.WithLabelValues(userID).Add(float64(numChunks))
+		f.queryIndex.WithLabelValues(userID).Add(float64(numIndexBytes))
+		f.querySamples.WithLabelValues(userID).Add(float64(numSamples))
 		f.activeUsers.UpdateUserTimestamp(userID, time.Now())
 	}
 
@@ -218,28 +836,160 @@ func (f *Handler) reportQueryStats(r *http.Request, queryString url.Values, quer
 		"component", "query-frontend",
 		"method", r.Method,
 		"path", r.URL.Path,
+		"remote_addr", clientAddress(r, f.cfg.TrustProxyHeaders),
+		"user_agent", r.UserAgent(),
 		"response_time", queryResponseTime,
 		"query_wall_time_seconds", wallTime.Seconds(),
 		"fetched_series_count", numSeries,
 		"fetched_chunk_bytes", numBytes,
 		"fetched_chunks_count", numChunks,
 		"fetched_index_bytes", numIndexBytes,
+		"samples_processed", numSamples,
 		"sharded_queries", stats.LoadShardedQueries(),
 		"split_queries", stats.LoadSplitQueries(),
-	}, formatQueryString(queryString)...)
+	}, f.requestIDLogFields(r)...)
+	logMessage = append(logMessage, f.formatQueryString(queryString)...)
 
 	if queryErr != nil {
 		logMessage = append(logMessage,
 			"status", "failed",
+			"result", queryResult(queryErr),
 			"err", queryErr)
 	} else {
 		logMessage = append(logMessage,
-			"status", "success")
+			"status", "success",
+			"result", resultSuccess)
 	}
 
 	level.Info(util_log.WithContext(r.Context(), f.log)).Log(logMessage...)
 }
 
+// headerTrackingResponseWriter wraps a http.ResponseWriter, making sure the response header is
+// written only once and tracking whether the response has started.
+type headerTrackingResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *headerTrackingResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write implements http.ResponseWriter.
+func (w *headerTrackingResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// flushWriter wraps an io.Writer, flushing the response after each write so that
+// the written bytes reach the client without waiting for the end of the response.
+type flushWriter struct {
+	io.Writer
+	flusher http.Flusher
+}
+
+// Write implements io.Writer.
+func (w *flushWriter) Write(b []byte) (int, error) {
+	n, err := w.Writer.Write(b)
+	if n > 0 {
+		w.flusher.Flush()
+	}
+	return n, err
+}
+
+// queryTimeout returns the query timeout to enforce for the request, as the smallest
+// non-zero timeout configured for the request's tenants, or 0 if no timeout should be enforced.
+func (f *Handler) queryTimeout(r *http.Request) time.Duration {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return 0
+	}
+
+	return validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, f.limits.QueryTimeout)
+}
+
+// maxResponseSize returns the max size of the response to the request, as the smallest non-zero
+// max size configured for the request's tenants, or 0 if the response size shouldn't be limited.
+func (f *Handler) maxResponseSize(r *http.Request) int64 {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return 0
+	}
+
+	return int64(validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, f.limits.MaxResponseSizeBytes))
+}
+
+// slowQueryLogThreshold returns the duration above which the request is logged as slow, as the smallest
+// non-zero threshold configured for the request's tenants, falling back to LogQueriesLongerThan for the
+// tenants without an override. 0 means slow queries shouldn't be logged.
+func (f *Handler) slowQueryLogThreshold(r *http.Request) time.Duration {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return f.cfg.LogQueriesLongerThan
+	}
+
+	return validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, func(tenantID string) time.Duration {
+		if threshold := f.limits.SlowQueryLogThreshold(tenantID); threshold > 0 {
+			return threshold
+		}
+		return f.cfg.LogQueriesLongerThan
+	})
+}
+
+// isExcludedFromQueryStats returns whether the request path matches one of the configured
+// path prefixes excluded from query stats tracking.
+func (f *Handler) isExcludedFromQueryStats(r *http.Request) bool {
+	for _, prefix := range f.cfg.QueryStatsExcludedPathPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStreamingPath returns whether the request path matches one of the configured
+// path prefixes for which the response is flushed as it's copied.
+func (f *Handler) isStreamingPath(r *http.Request) bool {
+	for _, prefix := range f.cfg.StreamingPathPrefixes {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestIDLogFields returns the log fields with the request correlation ID, if enabled.
+func (f *Handler) requestIDLogFields(r *http.Request) []interface{} {
+	if f.cfg.RequestIDHeader == "" {
+		return nil
+	}
+	return []interface{}{"request_id", r.Header.Get(f.cfg.RequestIDHeader)}
+}
+
+// clientAddress returns the address of the client which sent the request. If trustProxyHeaders is true,
+// the address is read from the X-Forwarded-For or X-Real-IP headers, when set.
+func clientAddress(r *http.Request, trustProxyHeaders bool) string {
+	if trustProxyHeaders {
+		// The left-most address in X-Forwarded-For is the original client.
+		if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+			if idx := strings.IndexByte(forwardedFor, ','); idx >= 0 {
+				forwardedFor = forwardedFor[:idx]
+			}
+			return strings.TrimSpace(forwardedFor)
+		}
+		if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+			return strings.TrimSpace(realIP)
+		}
+	}
+
+	return r.RemoteAddr
+}
+
 func (f *Handler) parseRequestQueryString(r *http.Request, bodyBuf bytes.Buffer) url.Values {
 	// Use previously buffered body.
 	r.Body = io.NopCloser(&bodyBuf)
@@ -254,18 +1004,38 @@ func (f *Handler) parseRequestQueryString(r *http.Request, bodyBuf bytes.Buffer)
 	return r.Form
 }
 
-func formatQueryString(queryString url.Values) (fields []interface{}) {
+// formatQueryString returns the log fields for the request parameters, replacing the values
+// of the redacted parameters with redactedParamValue.
+func (f *Handler) formatQueryString(queryString url.Values) (fields []interface{}) {
 	for k, v := range queryString {
-		fields = append(fields, fmt.Sprintf("param_%s", k), strings.Join(v, ","))
+		value := strings.Join(v, ",")
+		if _, ok := f.redactedParams[strings.ToLower(k)]; ok {
+			value = redactedParamValue
+		}
+		fields = append(fields, fmt.Sprintf("param_%s", k), value)
 	}
 	return fields
 }
 
-func writeError(w http.ResponseWriter, err error) {
+// queryResult classifies the outcome of a query based on the error returned by the downstream.
+func queryResult(err error) string {
 	switch {
+	case err == nil:
+		return resultSuccess
 	case errors.Is(err, context.Canceled):
-		err = errCanceled
+		return resultClientCanceled
 	case errors.Is(err, context.DeadlineExceeded):
+		return resultTimeout
+	default:
+		return resultError
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	switch queryResult(err) {
+	case resultClientCanceled:
+		err = errCanceled
+	case resultTimeout:
 		err = errDeadlineExceeded
 	default:
 		if util.IsRequestBodyTooLarge(err) {
@@ -282,16 +1052,94 @@ func writeError(w http.ResponseWriter, err error) {
 	server.WriteError(w, err)
 }
 
-func writeServiceTimingHeader(queryResponseTime time.Duration, headers http.Header, stats *querier_stats.Stats) {
+// serverTimingExtraFields are the query stats included in the Server-Timing header, after the timings,
+// when -query-frontend.server-timing-extra-fields-enabled is set.
+var serverTimingExtraFields = []struct {
+	name  string
+	value func(stats *querier_stats.Stats) uint64
+}{
+	{name: "fetched_series", value: (*querier_stats.Stats).LoadFetchedSeries},
+	{name: "fetched_chunk_bytes", value: (*querier_stats.Stats).LoadFetchedChunkBytes},
+	{name: "fetched_chunks", value: (*querier_stats.Stats).LoadFetchedChunks},
+	{name: "fetched_index_bytes", value: (*querier_stats.Stats).LoadFetchedIndexBytes},
+	{name: "sharded_queries", value: func(stats *querier_stats.Stats) uint64 { return uint64(stats.LoadShardedQueries()) }},
+	{name: "split_queries", value: func(stats *querier_stats.Stats) uint64 { return uint64(stats.LoadSplitQueries()) }},
+}
+
+func (f *Handler) writeServiceTimingHeader(queryResponseTime time.Duration, headers http.Header, stats *querier_stats.Stats) {
 	if stats != nil {
 		parts := make([]string, 0)
 		parts = append(parts, statsValue("querier_wall_time", stats.LoadWallTime()))
 		parts = append(parts, statsValue("response_time", queryResponseTime))
-		headers.Set(ServiceTimingHeaderName, strings.Join(parts, ", "))
+		if f.cfg.ServerTimingExtraFieldsEnabled {
+			for _, field := range serverTimingExtraFields {
+				parts = append(parts, countValue(field.name, field.value(stats)))
+			}
+		}
+
+		headers.Set(f.serverTimingHeaderName(), strings.Join(parts, ", "))
+	}
+}
+
+func (f *Handler) serverTimingHeaderName() string {
+	if f.cfg.ServerTimingHeaderName == "" {
+		return ServiceTimingHeaderName
 	}
+	return f.cfg.ServerTimingHeaderName
+}
+
+// exceedsMaxResponseSize returns whether the response exceeds the max size, in which case its body is closed.
+// The body of non-streamed responses whose size isn't known upfront is read up to the max size, and replaced
+// with the bytes read, so that they can be rejected before they start. Streamed responses are only checked
+// against their known size.
+func exceedsMaxResponseSize(resp *http.Response, maxSize int64, streaming bool) (bool, error) {
+	if resp.ContentLength > maxSize {
+		_ = resp.Body.Close()
+		return true, nil
+	}
+	if resp.ContentLength >= 0 || streaming {
+		return false, nil
+	}
+
+	// Read one byte more than the limit to find out whether the body exceeds it.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	_ = resp.Body.Close()
+	if err != nil {
+		return false, err
+	}
+	if int64(len(body)) > maxSize {
+		return true, nil
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	return false, nil
+}
+
+// copyResponse copies the response body to dst, up to maxSize bytes if greater than 0. It returns
+// whether the body exceeds maxSize, in which case only the bytes up to the limit are copied.
+func copyResponse(dst io.Writer, body io.Reader, maxSize int64) bool {
+	if maxSize <= 0 {
+		// we don't check for copy error as there is no much we can do at this point
+		_, _ = io.Copy(dst, body)
+		return false
+	}
+
+	if _, err := io.CopyN(dst, body, maxSize); err != nil {
+		// The body ended (or failed) before reaching the limit.
+		return false
+	}
+
+	// The body exceeds the limit if there's anything left to read.
+	n, _ := io.ReadFull(body, make([]byte, 1))
+	return n > 0
 }
 
 func statsValue(name string, d time.Duration) string {
 	durationInMs := strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)
 	return name + ";dur=" + durationInMs
 }
+
+func countValue(name string, v uint64) string {
+	return name + ";desc=" + strconv.FormatUint(v, 10)
+}
@@ -7,14 +7,22 @@ package transport
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/tenant"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	promtest "github.com/prometheus/client_golang/prometheus/testutil"
@@ -22,8 +30,34 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/user"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
+type mockLimits struct {
+	queryTimeout          map[string]time.Duration
+	slowQueryLogThreshold map[string]time.Duration
+	blockedQueries        map[string][]*validation.BlockedQuery
+	maxResponseSizeBytes  map[string]int
+}
+
+func (m *mockLimits) QueryTimeout(userID string) time.Duration {
+	return m.queryTimeout[userID]
+}
+
+func (m *mockLimits) SlowQueryLogThreshold(userID string) time.Duration {
+	return m.slowQueryLogThreshold[userID]
+}
+
+func (m *mockLimits) BlockedQueries(userID string) []*validation.BlockedQuery {
+	return m.blockedQueries[userID]
+}
+
+func (m *mockLimits) MaxResponseSizeBytes(userID string) int {
+	return m.maxResponseSizeBytes[userID]
+}
+
 type roundTripperFunc func(*http.Request) (*http.Response, error)
 
 func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
@@ -48,6 +82,69 @@ func TestWriteError(t *testing.T) {
 	}
 }
 
+func TestQueryResult(t *testing.T) {
+	for _, test := range []struct {
+		err      error
+		expected string
+	}{
+		{nil, resultSuccess},
+		{context.Canceled, resultClientCanceled},
+		{errors.Wrap(context.Canceled, "wrapped"), resultClientCanceled},
+		{context.DeadlineExceeded, resultTimeout},
+		{errors.Wrap(context.DeadlineExceeded, "wrapped"), resultTimeout},
+		{errors.New("unknown"), resultError},
+		{httpgrpc.Errorf(http.StatusBadRequest, ""), resultError},
+	} {
+		assert.Equal(t, test.expected, queryResult(test.err), "error: %v", test.err)
+	}
+}
+
+func TestHandler_ShouldTrackQueryResults(t *testing.T) {
+	for name, test := range map[string]struct {
+		queryErr       error
+		expectedResult string
+	}{
+		"successful query": {
+			expectedResult: resultSuccess,
+		},
+		"query canceled by the client": {
+			queryErr:       context.Canceled,
+			expectedResult: resultClientCanceled,
+		},
+		"query timed out": {
+			queryErr:       context.DeadlineExceeded,
+			expectedResult: resultTimeout,
+		},
+		"query failed": {
+			queryErr:       errors.New("unknown"),
+			expectedResult: resultError,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if test.queryErr != nil {
+					return nil, test.queryErr
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			})
+
+			reg := prometheus.NewPedanticRegistry()
+			logs := &concurrency.SyncBuffer{}
+			handler := NewHandler(HandlerConfig{QueryStatsEnabled: true}, &mockLimits{}, roundTripper, log.NewLogfmtLogger(logs), reg)
+
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+				# HELP cortex_query_frontend_query_results_total Number of queries received by the query-frontend, by result.
+				# TYPE cortex_query_frontend_query_results_total counter
+				cortex_query_frontend_query_results_total{result="%s"} 1
+			`, test.expectedResult)), "cortex_query_frontend_query_results_total"))
+			assert.Contains(t, logs.String(), "result="+test.expectedResult)
+		})
+	}
+}
+
 func TestHandler_ServeHTTP(t *testing.T) {
 	for _, tt := range []struct {
 		name            string
@@ -57,7 +154,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 		{
 			name:            "test handler with stats enabled",
 			cfg:             HandlerConfig{QueryStatsEnabled: true},
-			expectedMetrics: 4,
+			expectedMetrics: 8,
 		},
 		{
 			name:            "test handler with stats disabled",
@@ -74,7 +171,7 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			})
 
 			reg := prometheus.NewPedanticRegistry()
-			handler := NewHandler(tt.cfg, roundTripper, log.NewNopLogger(), reg)
+			handler := NewHandler(tt.cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), reg)
 
 			ctx := user.InjectOrgID(context.Background(), "12345")
 			req := httptest.NewRequest("GET", "/", nil)
@@ -88,9 +185,13 @@ func TestHandler_ServeHTTP(t *testing.T) {
 			count, err := promtest.GatherAndCount(
 				reg,
 				"cortex_query_seconds_total",
+				"cortex_query_frontend_query_duration_seconds",
+				"cortex_query_wall_time_seconds",
 				"cortex_query_fetched_series_total",
 				"cortex_query_fetched_chunk_bytes_total",
 				"cortex_query_fetched_chunks_total",
+				"cortex_query_fetched_index_bytes_total",
+				"cortex_query_samples_processed_total",
 			)
 
 			assert.NoError(t, err)
@@ -119,7 +220,7 @@ func TestHandler_FailedRoundTrip(t *testing.T) {
 		{
 			name:                "Failed round trip with no query params",
 			cfg:                 HandlerConfig{QueryStatsEnabled: true},
-			expectedMetrics:     4,
+			expectedMetrics:     8,
 			path:                "/api/v1/query",
 			expectQueryParamLog: false,
 			queryErr:            context.Canceled,
@@ -133,7 +234,7 @@ func TestHandler_FailedRoundTrip(t *testing.T) {
 			reg := prometheus.NewPedanticRegistry()
 			logs := &concurrency.SyncBuffer{}
 			logger := log.NewLogfmtLogger(logs)
-			handler := NewHandler(test.cfg, roundTripper, logger, reg)
+			handler := NewHandler(test.cfg, &mockLimits{}, roundTripper, logger, reg)
 
 			ctx := user.InjectOrgID(context.Background(), "12345")
 			req := httptest.NewRequest("GET", test.path, nil)
@@ -146,15 +247,22 @@ func TestHandler_FailedRoundTrip(t *testing.T) {
 			count, err := promtest.GatherAndCount(
 				reg,
 				"cortex_query_seconds_total",
+				"cortex_query_frontend_query_duration_seconds",
+				"cortex_query_wall_time_seconds",
 				"cortex_query_fetched_series_total",
 				"cortex_query_fetched_chunk_bytes_total",
 				"cortex_query_fetched_chunks_total",
+				"cortex_query_fetched_index_bytes_total",
+				"cortex_query_samples_processed_total",
 			)
 
 			require.NoError(t, err)
 
 			assert.Contains(t, strings.TrimSpace(logs.String()), "sharded_queries")
+			assert.Contains(t, strings.TrimSpace(logs.String()), "remote_addr")
+			assert.Contains(t, strings.TrimSpace(logs.String()), "user_agent")
 			assert.Contains(t, strings.TrimSpace(logs.String()), "status")
+			assert.Contains(t, strings.TrimSpace(logs.String()), "result=client_canceled")
 			if test.expectQueryParamLog {
 				assert.Contains(t, strings.TrimSpace(logs.String()), "param_query")
 			}
@@ -162,3 +270,1236 @@ func TestHandler_FailedRoundTrip(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_ShouldDiscardResponseReturnedWithError(t *testing.T) {
+	body := &closeTrackingReader{Reader: strings.NewReader(`{"status":"success"}`)}
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"X-Test": []string{"true"}}, Body: body}, errors.New("downstream failure")
+	})
+
+	reg := prometheus.NewPedanticRegistry()
+	handler := NewHandler(HandlerConfig{QueryStatsEnabled: true}, &mockLimits{}, roundTripper, log.NewNopLogger(), reg)
+
+	req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Contains(t, resp.Body.String(), "downstream failure")
+	assert.Empty(t, resp.Header().Get("X-Test"))
+	assert.True(t, body.closed)
+
+	assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_frontend_query_results_total Number of queries received by the query-frontend, by result.
+		# TYPE cortex_query_frontend_query_results_total counter
+		cortex_query_frontend_query_results_total{result="error"} 1
+	`), "cortex_query_frontend_query_results_total"))
+}
+
+func TestHandler_ShouldRecoverFromPanics(t *testing.T) {
+	const expectedMetrics = `
+		# HELP cortex_query_frontend_query_results_total Number of queries received by the query-frontend, by result.
+		# TYPE cortex_query_frontend_query_results_total counter
+		cortex_query_frontend_query_results_total{result="panic"} 1
+	`
+
+	t.Run("should write an error response if the panic happened before the response started", func(t *testing.T) {
+		roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			panic("round trip panic")
+		})
+
+		reg := prometheus.NewPedanticRegistry()
+		logs := &concurrency.SyncBuffer{}
+		handler := NewHandler(HandlerConfig{QueryStatsEnabled: true}, &mockLimits{}, roundTripper, log.NewLogfmtLogger(logs), reg)
+
+		req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+		resp := httptest.NewRecorder()
+		require.NotPanics(t, func() { handler.ServeHTTP(resp, req) })
+
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		assert.Contains(t, logs.String(), "panic while serving the request")
+		assert.Contains(t, logs.String(), "round trip panic")
+		assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "cortex_query_frontend_query_results_total"))
+	})
+
+	t.Run("should abort the response if the panic happened while writing the response body", func(t *testing.T) {
+		roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(panickingReader{})}, nil
+		})
+
+		reg := prometheus.NewPedanticRegistry()
+		logs := &concurrency.SyncBuffer{}
+		handler := NewHandler(HandlerConfig{QueryStatsEnabled: true}, &mockLimits{}, roundTripper, log.NewLogfmtLogger(logs), reg)
+
+		req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+		resp := httptest.NewRecorder()
+		require.PanicsWithValue(t, http.ErrAbortHandler, func() { handler.ServeHTTP(resp, req) })
+
+		// The status code written before the panic is not overwritten by an error.
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Contains(t, logs.String(), "panic while serving the request")
+		assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "cortex_query_frontend_query_results_total"))
+	})
+}
+
+type closeTrackingReader struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeTrackingReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+type panickingReader struct{}
+
+func (panickingReader) Read([]byte) (int, error) {
+	panic("read panic")
+}
+
+func TestHandler_StripResponseHeaders(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp := &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("{}")),
+		}
+		resp.Header.Set("Content-Type", "application/json")
+		resp.Header.Set("X-Internal-Header", "value")
+		resp.Header.Set("Content-Length", "100")
+		return resp, nil
+	})
+
+	for name, test := range map[string]struct {
+		stripHeaders    []string
+		expectedHeaders []string
+		removedHeaders  []string
+	}{
+		"no headers to strip": {
+			expectedHeaders: []string{"Content-Type", "X-Internal-Header", "Content-Length"},
+		},
+		"strip headers with case-insensitive matching": {
+			stripHeaders:    []string{"x-internal-header", "CONTENT-LENGTH", "X-Not-Existing"},
+			expectedHeaders: []string{"Content-Type"},
+			removedHeaders:  []string{"X-Internal-Header", "Content-Length"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := HandlerConfig{StripResponseHeaders: test.stripHeaders}
+			handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+			req := httptest.NewRequest("GET", "/", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			for _, h := range test.expectedHeaders {
+				assert.NotEmpty(t, resp.Header().Get(h), h)
+			}
+			for _, h := range test.removedHeaders {
+				assert.Empty(t, resp.Header().Get(h), h)
+			}
+		})
+	}
+}
+
+func TestHandler_RetryOnTransientDownstreamErrors(t *testing.T) {
+	for name, test := range map[string]struct {
+		method     string
+		maxRetries int
+		responses  []int
+		// errs are the errors returned by the round trips instead of the responses, if not nil.
+		errs                []error
+		expectedStatusCode  int
+		expectedRoundTrips  int
+		cancelAfterAttempts int
+	}{
+		"should not retry if retries are disabled": {
+			method:             http.MethodGet,
+			maxRetries:         0,
+			responses:          []int{http.StatusServiceUnavailable, http.StatusOK},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedRoundTrips: 1,
+		},
+		"should retry GET requests on transient errors until success": {
+			method:             http.MethodGet,
+			maxRetries:         3,
+			responses:          []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			expectedStatusCode: http.StatusOK,
+			expectedRoundTrips: 3,
+		},
+		"should retry HEAD requests on transient errors": {
+			method:             http.MethodHead,
+			maxRetries:         3,
+			responses:          []int{http.StatusGatewayTimeout, http.StatusOK},
+			expectedStatusCode: http.StatusOK,
+			expectedRoundTrips: 2,
+		},
+		"should stop retrying once the max number of retries has been reached": {
+			method:             http.MethodGet,
+			maxRetries:         2,
+			responses:          []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedRoundTrips: 3,
+		},
+		"should not retry on non-transient errors": {
+			method:             http.MethodGet,
+			maxRetries:         3,
+			responses:          []int{http.StatusInternalServerError, http.StatusOK},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedRoundTrips: 1,
+		},
+		"should never retry non-idempotent requests": {
+			method:             http.MethodPost,
+			maxRetries:         3,
+			responses:          []int{http.StatusServiceUnavailable, http.StatusOK},
+			expectedStatusCode: http.StatusServiceUnavailable,
+			expectedRoundTrips: 1,
+		},
+		"should retry on httpgrpc errors with a transient status code": {
+			method:             http.MethodGet,
+			maxRetries:         3,
+			responses:          []int{0, http.StatusOK},
+			errs:               []error{httpgrpc.Errorf(http.StatusServiceUnavailable, "unavailable"), nil},
+			expectedStatusCode: http.StatusOK,
+			expectedRoundTrips: 2,
+		},
+		"should not retry on httpgrpc errors with a non-transient status code": {
+			method:             http.MethodGet,
+			maxRetries:         3,
+			responses:          []int{0, http.StatusOK},
+			errs:               []error{httpgrpc.Errorf(http.StatusBadRequest, "bad request"), nil},
+			expectedStatusCode: http.StatusBadRequest,
+			expectedRoundTrips: 1,
+		},
+		"should retry when the connection to the downstream is reset or refused": {
+			method:             http.MethodGet,
+			maxRetries:         3,
+			responses:          []int{0, 0, http.StatusOK},
+			errs:               []error{&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, fmt.Errorf("dial: %w", syscall.ECONNREFUSED), nil},
+			expectedStatusCode: http.StatusOK,
+			expectedRoundTrips: 3,
+		},
+		"should not retry on other errors": {
+			method:             http.MethodGet,
+			maxRetries:         3,
+			responses:          []int{0, http.StatusOK},
+			errs:               []error{errors.New("unexpected error"), nil},
+			expectedStatusCode: http.StatusInternalServerError,
+			expectedRoundTrips: 1,
+		},
+		"should stop retrying when the request context is canceled": {
+			method:              http.MethodGet,
+			maxRetries:          3,
+			responses:           []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK},
+			expectedStatusCode:  http.StatusServiceUnavailable,
+			expectedRoundTrips:  1,
+			cancelAfterAttempts: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			const reqBody = "query=up"
+
+			ctx, cancel := context.WithCancel(user.InjectOrgID(context.Background(), "12345"))
+			defer cancel()
+
+			roundTrips := 0
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				// The whole request body must be received on every attempt.
+				body, err := io.ReadAll(req.Body)
+				require.NoError(t, err)
+				require.Equal(t, reqBody, string(body))
+
+				code := test.responses[roundTrips]
+				var roundTripErr error
+				if len(test.errs) > roundTrips {
+					roundTripErr = test.errs[roundTrips]
+				}
+				roundTrips++
+				if roundTrips == test.cancelAfterAttempts {
+					cancel()
+				}
+				if roundTripErr != nil {
+					return nil, roundTripErr
+				}
+
+				return &http.Response{
+					StatusCode: code,
+					Body:       io.NopCloser(strings.NewReader("{}")),
+				}, nil
+			})
+
+			cfg := HandlerConfig{MaxBodySize: 1024, HandlerMaxRetries: test.maxRetries, RetryMinBackoff: time.Millisecond, RetryMaxBackoff: time.Millisecond}
+			handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+			req := httptest.NewRequest(test.method, "/api/v1/query", strings.NewReader(reqBody)).WithContext(ctx)
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			assert.Equal(t, test.expectedStatusCode, resp.Code)
+			assert.Equal(t, test.expectedRoundTrips, roundTrips)
+		})
+	}
+}
+
+func TestHandler_RequestID(t *testing.T) {
+	for name, test := range map[string]struct {
+		requestIDHeader   string
+		receivedRequestID string
+		expectGenerated   bool
+	}{
+		"should propagate the received request ID": {
+			requestIDHeader:   "X-Request-ID",
+			receivedRequestID: "request-1",
+		},
+		"should generate a request ID if missing": {
+			requestIDHeader: "X-Request-ID",
+			expectGenerated: true,
+		},
+		"should support a custom header": {
+			requestIDHeader:   "X-Correlation-ID",
+			receivedRequestID: "request-2",
+		},
+		"should not track the request ID if disabled": {
+			requestIDHeader:   "",
+			receivedRequestID: "request-3",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var downstreamRequestID string
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if test.requestIDHeader != "" {
+					downstreamRequestID = req.Header.Get(test.requestIDHeader)
+				}
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader("{}")),
+				}, nil
+			})
+
+			logs := &concurrency.SyncBuffer{}
+			cfg := HandlerConfig{QueryStatsEnabled: true, LogQueriesLongerThan: time.Nanosecond, RequestIDHeader: test.requestIDHeader}
+			handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewLogfmtLogger(logs), prometheus.NewPedanticRegistry())
+
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+			if test.requestIDHeader != "" && test.receivedRequestID != "" {
+				req.Header.Set(test.requestIDHeader, test.receivedRequestID)
+			}
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			if test.requestIDHeader == "" {
+				assert.NotContains(t, logs.String(), "request_id")
+				return
+			}
+
+			returnedRequestID := resp.Header().Get(test.requestIDHeader)
+			if test.expectGenerated {
+				assert.NotEmpty(t, returnedRequestID)
+			} else {
+				assert.Equal(t, test.receivedRequestID, returnedRequestID)
+			}
+			assert.Equal(t, returnedRequestID, downstreamRequestID)
+
+			// Both the query stats and slow query log lines should contain the request ID.
+			assert.Equal(t, 2, strings.Count(logs.String(), "request_id="+returnedRequestID))
+		})
+	}
+}
+
+func TestHandler_QueryStatsExcludedPathPrefixes(t *testing.T) {
+	for name, test := range map[string]struct {
+		path               string
+		expectedStatsCount int
+	}{
+		"should track stats for a path not excluded": {
+			path:               "/prometheus/api/v1/query_range",
+			expectedStatsCount: 1,
+		},
+		"should not track stats for an excluded path": {
+			path:               "/prometheus/api/v1/labels",
+			expectedStatsCount: 0,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			const reqBody = "match[]=up"
+
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				// The request body should be forwarded downstream regardless of the exclusion.
+				body, err := io.ReadAll(req.Body)
+				require.NoError(t, err)
+				require.Equal(t, reqBody, string(body))
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Body:       io.NopCloser(strings.NewReader("{}")),
+				}, nil
+			})
+
+			reg := prometheus.NewPedanticRegistry()
+			logs := &concurrency.SyncBuffer{}
+			cfg := HandlerConfig{
+				MaxBodySize:                    1024,
+				QueryStatsEnabled:              true,
+				QueryStatsExcludedPathPrefixes: []string{"/prometheus/api/v1/labels", "/prometheus/api/v1/metadata"},
+			}
+			handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewLogfmtLogger(logs), reg)
+
+			req := httptest.NewRequest("POST", test.path, strings.NewReader(reqBody)).WithContext(user.InjectOrgID(context.Background(), "12345"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			assert.Equal(t, test.expectedStatsCount, strings.Count(logs.String(), "query stats"))
+			if test.expectedStatsCount > 0 {
+				assert.Contains(t, logs.String(), "param_match[]=up")
+				assert.NotEmpty(t, resp.Header().Get(ServiceTimingHeaderName))
+			} else {
+				assert.Empty(t, resp.Header().Get(ServiceTimingHeaderName))
+			}
+
+			count, err := promtest.GatherAndCount(reg, "cortex_query_fetched_series_total")
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedStatsCount, count)
+		})
+	}
+}
+
+func TestHandler_RedactedQueryParams(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("{}")),
+		}, nil
+	})
+
+	for name, test := range map[string]struct {
+		redactedParams []string
+		expectedLogs   []string
+		unexpectedLogs []string
+	}{
+		"should log all parameter values by default": {
+			expectedLogs: []string{"param_query=up", "param_Token=secret", "param_api_key=abc123"},
+		},
+		"should redact the configured parameters, case-insensitively": {
+			redactedParams: []string{"token", "API_KEY"},
+			expectedLogs:   []string{"param_query=up", "param_Token=***", "param_api_key=***"},
+			unexpectedLogs: []string{"secret", "abc123"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logs := &concurrency.SyncBuffer{}
+			cfg := HandlerConfig{
+				QueryStatsEnabled:    true,
+				LogQueriesLongerThan: time.Nanosecond,
+				RedactedQueryParams:  test.redactedParams,
+			}
+			handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewLogfmtLogger(logs), prometheus.NewPedanticRegistry())
+
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up&Token=secret&api_key=abc123", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			// Both the slow query and the query stats log lines include the parameters.
+			lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+			require.Len(t, lines, 2)
+			for _, line := range lines {
+				for _, expected := range test.expectedLogs {
+					assert.Contains(t, line, expected)
+				}
+				for _, unexpected := range test.unexpectedLogs {
+					assert.NotContains(t, line, unexpected)
+				}
+			}
+		})
+	}
+}
+
+func TestHandler_AsyncReporting(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		// Read the request body, so that it gets buffered by the handler.
+		if _, err := io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("{}")),
+		}, nil
+	})
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest("POST", "/api/v1/query", strings.NewReader("query=up")).WithContext(user.InjectOrgID(context.Background(), "12345"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req
+	}
+
+	cfg := HandlerConfig{
+		MaxBodySize:             1024,
+		QueryStatsEnabled:       true,
+		LogQueriesLongerThan:    time.Nanosecond,
+		AsyncReportingWorkers:   1,
+		AsyncReportingQueueSize: 10,
+	}
+
+	t.Run("should report the query in the background", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		logs := &concurrency.SyncBuffer{}
+		handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewLogfmtLogger(logs), reg)
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, newRequest())
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		require.Eventually(t, func() bool {
+			return strings.Count(logs.String(), "param_query=up") == 2
+		}, time.Second, 10*time.Millisecond)
+		assert.Contains(t, logs.String(), "slow query detected")
+		assert.Contains(t, logs.String(), "query stats")
+
+		count, err := promtest.GatherAndCount(reg, "cortex_query_seconds_total")
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_query_frontend_async_reporting_queue_full_total Number of query reports run synchronously because the async reporting queue was full.
+			# TYPE cortex_query_frontend_async_reporting_queue_full_total counter
+			cortex_query_frontend_async_reporting_queue_full_total 0
+		`), "cortex_query_frontend_async_reporting_queue_full_total"))
+	})
+
+	t.Run("should report the query synchronously if the queue is full", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		logs := &concurrency.SyncBuffer{}
+		handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewLogfmtLogger(logs), reg)
+
+		// Replace the queue with one which is always full.
+		handler.reports = make(chan func())
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, newRequest())
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		assert.Equal(t, 2, strings.Count(logs.String(), "param_query=up"))
+		assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_query_frontend_async_reporting_queue_full_total Number of query reports run synchronously because the async reporting queue was full.
+			# TYPE cortex_query_frontend_async_reporting_queue_full_total counter
+			cortex_query_frontend_async_reporting_queue_full_total 1
+		`), "cortex_query_frontend_async_reporting_queue_full_total"))
+	})
+
+	t.Run("should run the queued reports on stop, and report synchronously once stopped", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		logs := &concurrency.SyncBuffer{}
+		handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewLogfmtLogger(logs), reg)
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, newRequest())
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		// Stop returns once the workers have run the queued report and exited.
+		handler.Stop()
+		assert.Equal(t, 2, strings.Count(logs.String(), "param_query=up"))
+
+		resp = httptest.NewRecorder()
+		handler.ServeHTTP(resp, newRequest())
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		assert.Equal(t, 4, strings.Count(logs.String(), "param_query=up"))
+		assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_query_frontend_async_reporting_queue_full_total Number of query reports run synchronously because the async reporting queue was full.
+			# TYPE cortex_query_frontend_async_reporting_queue_full_total counter
+			cortex_query_frontend_async_reporting_queue_full_total 0
+		`), "cortex_query_frontend_async_reporting_queue_full_total"))
+
+		// Stopping the handler again is a no-op.
+		handler.Stop()
+	})
+}
+
+func TestHandler_CacheControlHeader(t *testing.T) {
+	end := strconv.FormatInt(time.Now().Add(-3*time.Hour).Unix(), 10)
+
+	for name, test := range map[string]struct {
+		rules          []string
+		downstreamCC   string
+		expectedHeader string
+	}{
+		"should not set the header if disabled": {
+			expectedHeader: "",
+		},
+		"should set the header based on the query end": {
+			rules:          []string{"2h=5m"},
+			expectedHeader: "max-age=300",
+		},
+		"should keep the header set by the downstream": {
+			rules:          []string{"2h=5m"},
+			downstreamCC:   "no-store",
+			expectedHeader: "no-store",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{},
+					Body:       io.NopCloser(strings.NewReader("{}")),
+				}
+				if test.downstreamCC != "" {
+					resp.Header.Set("Cache-Control", test.downstreamCC)
+				}
+				return resp, nil
+			})
+
+			cfg := HandlerConfig{CacheControlMaxAgeRules: test.rules}
+			require.NoError(t, cfg.Validate())
+			handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+			req := httptest.NewRequest("GET", "/api/v1/query_range?query=up&start=0&end="+end, nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, test.expectedHeader, resp.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestHandler_StreamingPathPrefixes(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(io.MultiReader(strings.NewReader("part1"), strings.NewReader("part2"))),
+		}, nil
+	})
+
+	cfg := HandlerConfig{StreamingPathPrefixes: []string{"/prometheus/api/v1/stream"}}
+	handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	for name, test := range map[string]struct {
+		path            string
+		expectedFlushes []string
+	}{
+		"should flush the response after each part for a streaming path": {
+			path:            "/prometheus/api/v1/stream",
+			expectedFlushes: []string{"", "part1", "part1part2"},
+		},
+		"should not flush the response for a non-streaming path": {
+			path: "/prometheus/api/v1/query",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", test.path, nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+			resp := &flushRecordingResponseWriter{ResponseRecorder: httptest.NewRecorder()}
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, "part1part2", resp.Body.String())
+			assert.Equal(t, test.expectedFlushes, resp.flushes)
+		})
+	}
+
+	t.Run("should not fail if the response writer doesn't support flushing", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/prometheus/api/v1/stream", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(struct{ http.ResponseWriter }{recorder}, req)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "part1part2", recorder.Body.String())
+	})
+}
+
+func TestHandler_StreamingPathPrefixes_ShouldSendServerTimingAsTrailer(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{"Content-Length": []string{"10"}},
+			Body:          io.NopCloser(strings.NewReader("part1part2")),
+			ContentLength: 10,
+		}, nil
+	})
+
+	cfg := HandlerConfig{QueryStatsEnabled: true, StreamingPathPrefixes: []string{"/prometheus/api/v1/stream"}}
+	handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	t.Run("streaming path", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/prometheus/api/v1/stream", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+		resp := recorder.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Empty(t, resp.Header.Get("Content-Length"))
+		assert.Empty(t, resp.Header.Get(ServiceTimingHeaderName))
+		assert.Contains(t, resp.Trailer.Get(ServiceTimingHeaderName), "response_time;dur=")
+	})
+
+	t.Run("non-streaming path", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/prometheus/api/v1/query", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+		resp := recorder.Result()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "10", resp.Header.Get("Content-Length"))
+		assert.Contains(t, resp.Header.Get(ServiceTimingHeaderName), "response_time;dur=")
+		assert.Empty(t, resp.Trailer)
+	})
+}
+
+func TestHandler_MaxResponseSizeBytes(t *testing.T) {
+	const body = "0123456789"
+
+	// Set a multi tenant resolver, restoring the default one at the end of the test.
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	for name, test := range map[string]struct {
+		orgID              string
+		maxResponseSize    map[string]int
+		knownContentLength bool
+		streaming          bool
+		expectedStatusCode int
+		expectedAbort      bool
+		expectedResult     string
+	}{
+		"should return the response if the limit is disabled": {
+			orgID:              "tenant-1",
+			knownContentLength: true,
+			expectedStatusCode: http.StatusOK,
+			expectedResult:     resultSuccess,
+		},
+		"should return the response if its size is equal to the limit": {
+			orgID:              "tenant-1",
+			maxResponseSize:    map[string]int{"tenant-1": len(body)},
+			expectedStatusCode: http.StatusOK,
+			expectedResult:     resultSuccess,
+		},
+		"should reject the response if its known size exceeds the limit": {
+			orgID:              "tenant-1",
+			maxResponseSize:    map[string]int{"tenant-1": len(body) - 1},
+			knownContentLength: true,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedResult:     resultError,
+		},
+		"should reject the response if its size exceeds the limit while reading it": {
+			orgID:              "tenant-1",
+			maxResponseSize:    map[string]int{"tenant-1": len(body) - 1},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedResult:     resultError,
+		},
+		"should reject the response if its size exceeds the smallest limit of the tenants": {
+			orgID:              "tenant-1|tenant-2",
+			maxResponseSize:    map[string]int{"tenant-1": len(body), "tenant-2": len(body) - 1},
+			knownContentLength: true,
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedResult:     resultError,
+		},
+		"should abort the streamed response if its size exceeds the limit while copying it": {
+			orgID:           "tenant-1",
+			maxResponseSize: map[string]int{"tenant-1": len(body) - 1},
+			streaming:       true,
+			expectedAbort:   true,
+			expectedResult:  resultError,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), ContentLength: -1}
+				if test.knownContentLength {
+					resp.ContentLength = int64(len(body))
+				}
+				return resp, nil
+			})
+
+			reg := prometheus.NewPedanticRegistry()
+			cfg := HandlerConfig{}
+			if test.streaming {
+				cfg.StreamingPathPrefixes = []string{"/api/v1/query_range"}
+			}
+			handler := NewHandler(cfg, &mockLimits{maxResponseSizeBytes: test.maxResponseSize}, roundTripper, log.NewNopLogger(), reg)
+
+			req := httptest.NewRequest("GET", "/api/v1/query_range", nil).WithContext(user.InjectOrgID(context.Background(), test.orgID))
+			resp := httptest.NewRecorder()
+
+			if test.expectedAbort {
+				assert.PanicsWithValue(t, http.ErrAbortHandler, func() { handler.ServeHTTP(resp, req) })
+				assert.Equal(t, http.StatusOK, resp.Code)
+				assert.Equal(t, body[:test.maxResponseSize[test.orgID]], resp.Body.String())
+			} else {
+				handler.ServeHTTP(resp, req)
+				assert.Equal(t, test.expectedStatusCode, resp.Code)
+				if test.expectedStatusCode == http.StatusOK {
+					assert.Equal(t, body, resp.Body.String())
+				} else {
+					assert.Contains(t, resp.Body.String(), `"errorType":"execution"`)
+					assert.Contains(t, resp.Body.String(), "err-mimir-max-response-size")
+				}
+			}
+
+			expectedRejected := 0
+			if test.expectedResult == resultError {
+				expectedRejected = 1
+			}
+			assert.Equal(t, float64(expectedRejected), promtest.ToFloat64(handler.rejectedRequests.WithLabelValues(reasonResponseTooLarge)))
+			assert.Equal(t, float64(1), promtest.ToFloat64(handler.queryResults.WithLabelValues(test.expectedResult)))
+		})
+	}
+}
+
+// flushRecordingResponseWriter records the response body written so far each time it's flushed.
+type flushRecordingResponseWriter struct {
+	*httptest.ResponseRecorder
+	flushes []string
+}
+
+func (w *flushRecordingResponseWriter) Flush() {
+	w.flushes = append(w.flushes, w.Body.String())
+	w.ResponseRecorder.Flush()
+}
+
+func TestHandler_QueryTimeout(t *testing.T) {
+	// Set a multi tenant resolver, restoring the default one at the end of the test.
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	limits := &mockLimits{queryTimeout: map[string]time.Duration{
+		"tenant-a": time.Minute,
+		"tenant-b": 100 * time.Millisecond,
+	}}
+
+	for name, test := range map[string]struct {
+		orgID              string
+		expectedTimeout    time.Duration
+		expectedStatusCode int
+	}{
+		"should not enforce a timeout if disabled for the tenant": {
+			orgID:              "tenant-c",
+			expectedStatusCode: http.StatusOK,
+		},
+		"should enforce the tenant timeout": {
+			orgID:              "tenant-b",
+			expectedTimeout:    100 * time.Millisecond,
+			expectedStatusCode: http.StatusGatewayTimeout,
+		},
+		"should enforce the smallest timeout across multiple tenants": {
+			orgID:              "tenant-a|tenant-b|tenant-c",
+			expectedTimeout:    100 * time.Millisecond,
+			expectedStatusCode: http.StatusGatewayTimeout,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				deadline, ok := req.Context().Deadline()
+				if test.expectedTimeout == 0 {
+					assert.False(t, ok)
+					return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+				}
+
+				require.True(t, ok)
+				assert.LessOrEqual(t, time.Until(deadline), test.expectedTimeout)
+
+				// Simulate a slow query.
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			})
+
+			handler := NewHandler(HandlerConfig{}, limits, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), test.orgID))
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			assert.Equal(t, test.expectedStatusCode, resp.Code)
+		})
+	}
+}
+
+func TestHandler_SlowQueryLogThreshold(t *testing.T) {
+	// Set a multi tenant resolver, restoring the default one at the end of the test.
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	limits := &mockLimits{slowQueryLogThreshold: map[string]time.Duration{
+		"tenant-a": time.Minute,
+		"tenant-b": time.Nanosecond,
+	}}
+
+	for name, test := range map[string]struct {
+		logQueriesLongerThan time.Duration
+		orgID                string
+		expectedThreshold    time.Duration
+		expectedSlowQuery    bool
+	}{
+		"should not log slow queries if disabled": {
+			orgID: "tenant-c",
+		},
+		"should use the default threshold for the tenants without an override": {
+			logQueriesLongerThan: time.Hour,
+			orgID:                "tenant-c",
+			expectedThreshold:    time.Hour,
+		},
+		"should use the tenant threshold": {
+			logQueriesLongerThan: time.Hour,
+			orgID:                "tenant-a",
+			expectedThreshold:    time.Minute,
+		},
+		"should log slow queries with the tenant threshold even if disabled by default": {
+			orgID:             "tenant-b",
+			expectedThreshold: time.Nanosecond,
+			expectedSlowQuery: true,
+		},
+		"should use the smallest threshold across multiple tenants": {
+			logQueriesLongerThan: time.Hour,
+			orgID:                "tenant-a|tenant-b|tenant-c",
+			expectedThreshold:    time.Nanosecond,
+			expectedSlowQuery:    true,
+		},
+		"should use the default threshold if smaller than the overrides of the other tenants": {
+			logQueriesLongerThan: time.Second,
+			orgID:                "tenant-a|tenant-c",
+			expectedThreshold:    time.Second,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			})
+
+			logs := &concurrency.SyncBuffer{}
+			cfg := HandlerConfig{LogQueriesLongerThan: test.logQueriesLongerThan}
+			handler := NewHandler(cfg, limits, roundTripper, log.NewLogfmtLogger(logs), prometheus.NewPedanticRegistry())
+
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), test.orgID))
+			assert.Equal(t, test.expectedThreshold, handler.slowQueryLogThreshold(req))
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			if test.expectedSlowQuery {
+				assert.Contains(t, logs.String(), "slow query detected")
+			} else {
+				assert.NotContains(t, logs.String(), "slow query detected")
+			}
+		})
+	}
+}
+
+func TestHandler_ShouldTrackRejectedRequests(t *testing.T) {
+	limits := &mockLimits{queryTimeout: map[string]time.Duration{"test": 100 * time.Millisecond}}
+
+	for name, test := range map[string]struct {
+		body               string
+		expectedStatusCode int
+		expectedMetrics    string
+	}{
+		"should not track a successful request": {
+			body:               "query=up",
+			expectedStatusCode: http.StatusOK,
+			expectedMetrics:    ``,
+		},
+		"should track a request rejected because the body is too large": {
+			body:               "query=" + strings.Repeat("a", 1024),
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedMetrics: `
+				# HELP cortex_query_frontend_rejected_requests_total Number of requests rejected by the query-frontend.
+				# TYPE cortex_query_frontend_rejected_requests_total counter
+				cortex_query_frontend_rejected_requests_total{reason="body_too_large"} 1
+			`,
+		},
+		"should track a request rejected because the query timeout has been reached": {
+			body:               "query=slow",
+			expectedStatusCode: http.StatusGatewayTimeout,
+			expectedMetrics: `
+				# HELP cortex_query_frontend_rejected_requests_total Number of requests rejected by the query-frontend.
+				# TYPE cortex_query_frontend_rejected_requests_total counter
+				cortex_query_frontend_rejected_requests_total{reason="query_timeout"} 1
+			`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				body, err := io.ReadAll(req.Body)
+				if err != nil {
+					return nil, err
+				}
+
+				if string(body) == "query=slow" {
+					<-req.Context().Done()
+					return nil, req.Context().Err()
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			})
+
+			reg := prometheus.NewPedanticRegistry()
+			handler := NewHandler(HandlerConfig{MaxBodySize: 512}, limits, roundTripper, log.NewNopLogger(), reg)
+
+			req := httptest.NewRequest("POST", "/api/v1/query", strings.NewReader(test.body)).WithContext(user.InjectOrgID(context.Background(), "test"))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			assert.Equal(t, test.expectedStatusCode, resp.Code)
+			assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(test.expectedMetrics), "cortex_query_frontend_rejected_requests_total"))
+		})
+	}
+}
+
+func TestHandler_ServerTimingHeader(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		stats := querier_stats.FromContext(req.Context())
+		stats.AddWallTime(1500 * time.Millisecond)
+		stats.AddFetchedSeries(10)
+		stats.AddFetchedChunkBytes(1024)
+		stats.AddFetchedChunks(20)
+		stats.AddFetchedIndexBytes(512)
+		stats.AddShardedQueries(16)
+		stats.AddSplitQueries(2)
+
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	for name, test := range map[string]struct {
+		cfg                HandlerConfig
+		expectedHeaderName string
+		expectedEntries    []string
+	}{
+		"should use the default header name and entries": {
+			cfg:                HandlerConfig{QueryStatsEnabled: true},
+			expectedHeaderName: ServiceTimingHeaderName,
+			expectedEntries:    []string{"querier_wall_time;dur=1500", "response_time;dur="},
+		},
+		"should use the configured header name": {
+			cfg:                HandlerConfig{QueryStatsEnabled: true, ServerTimingHeaderName: "X-Query-Timing"},
+			expectedHeaderName: "X-Query-Timing",
+			expectedEntries:    []string{"querier_wall_time;dur=1500", "response_time;dur="},
+		},
+		"should include the extra entries if enabled": {
+			cfg:                HandlerConfig{QueryStatsEnabled: true, ServerTimingExtraFieldsEnabled: true},
+			expectedHeaderName: ServiceTimingHeaderName,
+			expectedEntries:    []string{"querier_wall_time;dur=1500", "response_time;dur=", "fetched_series;desc=10", "fetched_chunk_bytes;desc=1024", "fetched_chunks;desc=20", "fetched_index_bytes;desc=512", "sharded_queries;desc=16", "split_queries;desc=2"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			handler := NewHandler(test.cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			entries := strings.Split(resp.Header().Get(test.expectedHeaderName), ", ")
+			require.Len(t, entries, len(test.expectedEntries))
+			for i, expected := range test.expectedEntries {
+				assert.True(t, strings.HasPrefix(entries[i], expected), "entry %q doesn't start with %q", entries[i], expected)
+			}
+
+			if test.expectedHeaderName != ServiceTimingHeaderName {
+				assert.Empty(t, resp.Header().Get(ServiceTimingHeaderName))
+			}
+		})
+	}
+}
+
+func TestHandler_ShouldIncludeQueryStatsInResponseWhenRequested(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		querier_stats.FromContext(req.Context()).AddSamplesProcessed(10)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"status":"success","data":{"resultType":"vector","result":[]}}`)),
+		}, nil
+	})
+
+	handler := NewHandler(HandlerConfig{QueryStatsEnabled: true}, &mockLimits{}, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	for path, expectStats := range map[string]bool{
+		"/api/v1/query?query=up":           false,
+		"/api/v1/query?query=up&stats=all": true,
+	} {
+		req := httptest.NewRequest("GET", path, nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		if expectStats {
+			assert.Contains(t, resp.Body.String(), `"samples":{"peakSamples":0,"totalQueryableSamples":10}`, path)
+		} else {
+			assert.Equal(t, `{"status":"success","data":{"resultType":"vector","result":[]}}`, resp.Body.String(), path)
+		}
+	}
+}
+
+func TestHandler_ShouldTrackQueryStatsMetrics(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		stats := querier_stats.FromContext(req.Context())
+		stats.AddFetchedIndexBytes(512)
+		stats.AddSamplesProcessed(2400)
+		stats.AddWallTime(3 * time.Second)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("{}")),
+		}, nil
+	})
+
+	reg := prometheus.NewPedanticRegistry()
+	handler := NewHandler(HandlerConfig{QueryStatsEnabled: true}, &mockLimits{}, roundTripper, log.NewNopLogger(), reg)
+
+	req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+	resp := httptest.NewRecorder()
+
+	handler.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_fetched_index_bytes_total Number of TSDB index bytes fetched from store-gateway to execute a query.
+		# TYPE cortex_query_fetched_index_bytes_total counter
+		cortex_query_fetched_index_bytes_total{user="12345"} 512
+
+		# HELP cortex_query_samples_processed_total Number of samples processed to execute a query.
+		# TYPE cortex_query_samples_processed_total counter
+		cortex_query_samples_processed_total{user="12345"} 2400
+
+		# HELP cortex_query_wall_time_seconds Estimated wall clock time spent processing a query, summed across all the queriers executing it.
+		# TYPE cortex_query_wall_time_seconds histogram
+		cortex_query_wall_time_seconds_bucket{user="12345",le="0.01"} 0
+		cortex_query_wall_time_seconds_bucket{user="12345",le="0.05"} 0
+		cortex_query_wall_time_seconds_bucket{user="12345",le="0.1"} 0
+		cortex_query_wall_time_seconds_bucket{user="12345",le="0.25"} 0
+		cortex_query_wall_time_seconds_bucket{user="12345",le="0.5"} 0
+		cortex_query_wall_time_seconds_bucket{user="12345",le="1"} 0
+		cortex_query_wall_time_seconds_bucket{user="12345",le="2.5"} 0
+		cortex_query_wall_time_seconds_bucket{user="12345",le="5"} 1
+		cortex_query_wall_time_seconds_bucket{user="12345",le="10"} 1
+		cortex_query_wall_time_seconds_bucket{user="12345",le="20"} 1
+		cortex_query_wall_time_seconds_bucket{user="12345",le="30"} 1
+		cortex_query_wall_time_seconds_bucket{user="12345",le="60"} 1
+		cortex_query_wall_time_seconds_bucket{user="12345",le="120"} 1
+		cortex_query_wall_time_seconds_bucket{user="12345",le="300"} 1
+		cortex_query_wall_time_seconds_bucket{user="12345",le="600"} 1
+		cortex_query_wall_time_seconds_bucket{user="12345",le="+Inf"} 1
+		cortex_query_wall_time_seconds_sum{user="12345"} 3
+		cortex_query_wall_time_seconds_count{user="12345"} 1
+	`), "cortex_query_fetched_index_bytes_total", "cortex_query_samples_processed_total", "cortex_query_wall_time_seconds"))
+
+	// The metrics of the tenant are removed once it's inactive.
+	handler.cleanupInactiveUserMetrics("12345")
+	count, err := promtest.GatherAndCount(reg, "cortex_query_fetched_index_bytes_total", "cortex_query_samples_processed_total", "cortex_query_wall_time_seconds")
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestHandler_ShouldAddQueryStatsToActiveSpan(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		stats := querier_stats.FromContext(req.Context())
+		stats.AddFetchedSeries(10)
+		stats.AddFetchedChunkBytes(1024)
+		stats.AddFetchedChunks(20)
+		stats.AddFetchedIndexBytes(512)
+		stats.AddSamplesProcessed(2400)
+		stats.AddWallTime(3 * time.Second)
+		stats.AddShardedQueries(16)
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("{}")),
+		}, nil
+	})
+
+	handler := NewHandler(HandlerConfig{QueryStatsEnabled: true}, &mockLimits{}, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	for name, cfg := range map[string]HandlerConfig{
+		"sync reporting":  {QueryStatsEnabled: true},
+		"async reporting": {QueryStatsEnabled: true, AsyncReportingWorkers: 1, AsyncReportingQueueSize: 10},
+	} {
+		t.Run("should add query stats as tags to the active span with "+name, func(t *testing.T) {
+			handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+			t.Cleanup(handler.Stop)
+
+			tracer := mocktracer.New()
+			span := tracer.StartSpan("query")
+
+			ctx := opentracing.ContextWithSpan(user.InjectOrgID(context.Background(), "12345"), span)
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(ctx)
+			resp := httptest.NewRecorder()
+
+			handler.ServeHTTP(resp, req)
+			require.Equal(t, http.StatusOK, resp.Code)
+
+			// The span is finished as soon as the request has been served, like the server tracing middleware does.
+			span.Finish()
+
+			tags := tracer.FinishedSpans()[0].Tags()
+			assert.Equal(t, uint64(10), tags["fetched_series_count"])
+			assert.Equal(t, uint64(1024), tags["fetched_chunk_bytes"])
+			assert.Equal(t, uint64(20), tags["fetched_chunks_count"])
+			assert.Equal(t, uint64(512), tags["fetched_index_bytes"])
+			assert.Equal(t, uint64(2400), tags["samples_processed"])
+			assert.Equal(t, uint32(16), tags["sharded_queries"])
+			assert.Equal(t, uint32(0), tags["split_queries"])
+			assert.Contains(t, tags, "query_wall_time_seconds")
+		})
+	}
+
+	t.Run("should not fail if there's no active span", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+		resp := httptest.NewRecorder()
+
+		handler.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+	})
+}
+
+func TestClientAddress(t *testing.T) {
+	for name, test := range map[string]struct {
+		headers           map[string]string
+		trustProxyHeaders bool
+		expected          string
+	}{
+		"no proxy headers": {
+			trustProxyHeaders: true,
+			expected:          "192.0.2.1:1234",
+		},
+		"proxy headers not trusted": {
+			headers:  map[string]string{"X-Forwarded-For": "10.0.0.1", "X-Real-IP": "10.0.0.2"},
+			expected: "192.0.2.1:1234",
+		},
+		"trusted X-Forwarded-For with a single address": {
+			headers:           map[string]string{"X-Forwarded-For": "10.0.0.1"},
+			trustProxyHeaders: true,
+			expected:          "10.0.0.1",
+		},
+		"trusted X-Forwarded-For with multiple addresses": {
+			headers:           map[string]string{"X-Forwarded-For": " 10.0.0.1 , 10.0.0.3, 10.0.0.4", "X-Real-IP": "10.0.0.2"},
+			trustProxyHeaders: true,
+			expected:          "10.0.0.1",
+		},
+		"trusted X-Real-IP": {
+			headers:           map[string]string{"X-Real-IP": "10.0.0.2"},
+			trustProxyHeaders: true,
+			expected:          "10.0.0.2",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+
+			assert.Equal(t, test.expected, clientAddress(req, test.trustProxyHeaders))
+		})
+	}
+}
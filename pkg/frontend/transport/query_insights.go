@@ -0,0 +1,277 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// queryInsightsPath is the path suffix of the endpoint serving the query insights.
+const queryInsightsPath = "/api/v1/query_insights"
+
+// Default and max number of queries returned by the query insights endpoint.
+const (
+	queryInsightsDefaultLimit = 10
+	queryInsightsMaxLimit     = 1000
+)
+
+// queryInsightsPruneInterval is how often the queries of all tenants are checked for expiration.
+const queryInsightsPruneInterval = time.Minute
+
+// Orders the queries can be sorted by when returned by the query insights endpoint, slowest
+// or most expensive first.
+var queryInsightsSortOrders = map[string]func(a, b *queryInsight) bool{
+	"response_time":       func(a, b *queryInsight) bool { return a.ResponseTimeSeconds > b.ResponseTimeSeconds },
+	"wall_time":           func(a, b *queryInsight) bool { return a.Stats.QuerierWallTimeSeconds > b.Stats.QuerierWallTimeSeconds },
+	"fetched_series":      func(a, b *queryInsight) bool { return a.Stats.FetchedSeriesCount > b.Stats.FetchedSeriesCount },
+	"fetched_chunk_bytes": func(a, b *queryInsight) bool { return a.Stats.FetchedChunkBytes > b.Stats.FetchedChunkBytes },
+	"samples_processed":   func(a, b *queryInsight) bool { return a.Stats.SamplesProcessed > b.Stats.SamplesProcessed },
+}
+
+// QueryInsightsConfig configures the query insights, the store of the recent queries and their stats
+// queryable through the query insights endpoint.
+type QueryInsightsConfig struct {
+	Enabled             bool          `yaml:"enabled" category:"experimental"`
+	RetentionPeriod     time.Duration `yaml:"retention_period" category:"experimental"`
+	MaxQueriesPerTenant int           `yaml:"max_queries_per_tenant" category:"experimental"`
+}
+
+func (cfg *QueryInsightsConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, fmt.Sprintf("True to keep the stats of the recent queries in memory, and serve the slowest or most expensive queries of a tenant over a time window from the <prometheus-http-prefix>%s endpoint.", queryInsightsPath))
+	f.DurationVar(&cfg.RetentionPeriod, prefix+"retention-period", time.Hour, "How long the stats of a query are kept.")
+	f.IntVar(&cfg.MaxQueriesPerTenant, prefix+"max-queries-per-tenant", 1000, "Max number of queries kept per tenant. When the limit is reached, the oldest queries are discarded.")
+}
+
+func (cfg *QueryInsightsConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.RetentionPeriod <= 0 {
+		return errors.New("the query insights retention period must be greater than 0")
+	}
+	if cfg.MaxQueriesPerTenant <= 0 {
+		return errors.New("the query insights max queries per tenant must be greater than 0")
+	}
+	return nil
+}
+
+// queryInsight is a query kept in the query insights store.
+type queryInsight struct {
+	Timestamp           time.Time         `json:"timestamp"`
+	RequestID           string            `json:"request_id,omitempty"`
+	Method              string            `json:"method"`
+	Path                string            `json:"path"`
+	Query               string            `json:"query,omitempty"`
+	Start               string            `json:"start,omitempty"`
+	End                 string            `json:"end,omitempty"`
+	Time                string            `json:"time,omitempty"`
+	Step                string            `json:"step,omitempty"`
+	Result              string            `json:"result"`
+	Error               string            `json:"error,omitempty"`
+	ResponseTimeSeconds float64           `json:"response_time_seconds"`
+	Stats               queryStatsSummary `json:"stats"`
+}
+
+// queryInsightsStore keeps the recent queries of each tenant in memory, in the order they've
+// completed, up to the max number of queries per tenant.
+type queryInsightsStore struct {
+	cfg QueryInsightsConfig
+
+	mtx       sync.Mutex
+	queries   map[string][]*queryInsight
+	lastPrune time.Time
+}
+
+func newQueryInsightsStore(cfg QueryInsightsConfig) *queryInsightsStore {
+	return &queryInsightsStore{
+		cfg:       cfg,
+		queries:   map[string][]*queryInsight{},
+		lastPrune: time.Now(),
+	}
+}
+
+// add stores the query of the tenant, discarding the expired queries.
+func (s *queryInsightsStore) add(tenantID string, query *queryInsight, now time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	queries := append(s.pruneTenant(tenantID, now), query)
+	if len(queries) > s.cfg.MaxQueriesPerTenant {
+		queries = queries[len(queries)-s.cfg.MaxQueriesPerTenant:]
+	}
+	s.queries[tenantID] = queries
+
+	// The queries of the tenants not querying anymore are discarded once expired.
+	if now.Sub(s.lastPrune) >= queryInsightsPruneInterval {
+		for id := range s.queries {
+			s.pruneTenant(id, now)
+		}
+		s.lastPrune = now
+	}
+}
+
+// pruneTenant discards the expired queries of the tenant, and returns the remaining ones.
+// It must be called with the lock held.
+func (s *queryInsightsStore) pruneTenant(tenantID string, now time.Time) []*queryInsight {
+	queries := s.queries[tenantID]
+
+	// Queries are stored when they complete, so they're not strictly sorted by timestamp.
+	var remaining []*queryInsight
+	for i, q := range queries {
+		if now.Sub(q.Timestamp) < s.cfg.RetentionPeriod {
+			if remaining != nil {
+				remaining = append(remaining, q)
+			}
+			continue
+		}
+		if remaining == nil {
+			// Copy the remaining queries, so that the expired ones can be garbage collected.
+			remaining = make([]*queryInsight, 0, len(queries)-1)
+			remaining = append(remaining, queries[:i]...)
+		}
+	}
+	if remaining == nil {
+		return queries
+	}
+
+	if len(remaining) == 0 {
+		delete(s.queries, tenantID)
+		return nil
+	}
+	s.queries[tenantID] = remaining
+	return remaining
+}
+
+// top returns up to limit queries of the tenant received between start and end, sorted by less.
+func (s *queryInsightsStore) top(tenantID string, start, end time.Time, less func(a, b *queryInsight) bool, limit int) []*queryInsight {
+	s.mtx.Lock()
+	queries := s.pruneTenant(tenantID, time.Now())
+	matching := make([]*queryInsight, 0, len(queries))
+	for _, q := range queries {
+		if !q.Timestamp.Before(start) && !q.Timestamp.After(end) {
+			matching = append(matching, q)
+		}
+	}
+	s.mtx.Unlock()
+
+	sort.SliceStable(matching, func(i, j int) bool {
+		return less(matching[i], matching[j])
+	})
+	if len(matching) > limit {
+		matching = matching[:limit]
+	}
+	return matching
+}
+
+// recordQueryInsight stores the query in the query insights store, if enabled.
+func (f *Handler) recordQueryInsight(r *http.Request, queryString url.Values, startTime time.Time, queryResponseTime time.Duration, stats *querier_stats.Stats, queryErr error) {
+	if f.queryInsights == nil {
+		return
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return
+	}
+
+	query := &queryInsight{
+		Timestamp:           startTime,
+		Method:              r.Method,
+		Path:                r.URL.Path,
+		Query:               f.auditLogParam(queryString, "query"),
+		Start:               f.auditLogParam(queryString, "start"),
+		End:                 f.auditLogParam(queryString, "end"),
+		Time:                f.auditLogParam(queryString, "time"),
+		Step:                f.auditLogParam(queryString, "step"),
+		Result:              queryResult(queryErr),
+		ResponseTimeSeconds: queryResponseTime.Seconds(),
+		Stats:               newQueryStatsSummary(stats),
+	}
+	if f.cfg.RequestIDHeader != "" {
+		query.RequestID = r.Header.Get(f.cfg.RequestIDHeader)
+	}
+	if queryErr != nil {
+		query.Error = queryErr.Error()
+	}
+
+	f.queryInsights.add(tenant.JoinTenantIDs(tenantIDs), query, time.Now())
+}
+
+// isQueryInsightsRequest returns whether the request is for the query insights endpoint, served by
+// the Handler itself when enabled.
+func (f *Handler) isQueryInsightsRequest(r *http.Request) bool {
+	return f.queryInsights != nil && strings.HasSuffix(r.URL.Path, queryInsightsPath)
+}
+
+// serveQueryInsights returns the top slowest or most expensive queries of the tenant over a time window.
+func (f *Handler) serveQueryInsights(w http.ResponseWriter, r *http.Request) error {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return apierror.New(apierror.TypeBadData, err.Error())
+	}
+	if err := r.ParseForm(); err != nil {
+		return apierror.New(apierror.TypeBadData, err.Error())
+	}
+
+	now := time.Now()
+	start, err := parseQueryInsightsTime(r.Form.Get("start"), now.Add(-f.cfg.QueryInsights.RetentionPeriod))
+	if err != nil {
+		return apierror.New(apierror.TypeBadData, fmt.Sprintf("invalid parameter \"start\": %s", err))
+	}
+	end, err := parseQueryInsightsTime(r.Form.Get("end"), now)
+	if err != nil {
+		return apierror.New(apierror.TypeBadData, fmt.Sprintf("invalid parameter \"end\": %s", err))
+	}
+	if end.Before(start) {
+		return apierror.New(apierror.TypeBadData, "invalid parameter \"end\": end timestamp must not be before start time")
+	}
+
+	sortBy := r.Form.Get("sort")
+	if sortBy == "" {
+		sortBy = "response_time"
+	}
+	less, ok := queryInsightsSortOrders[sortBy]
+	if !ok {
+		return apierror.New(apierror.TypeBadData, fmt.Sprintf("invalid parameter \"sort\": unsupported value %q", sortBy))
+	}
+
+	limit := queryInsightsDefaultLimit
+	if value := r.Form.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > queryInsightsMaxLimit {
+			return apierror.New(apierror.TypeBadData, fmt.Sprintf("invalid parameter \"limit\": must be a number between 1 and %d", queryInsightsMaxLimit))
+		}
+	}
+
+	util.WriteJSONResponse(w, map[string]interface{}{
+		"status": "success",
+		"data":   f.queryInsights.top(tenant.JoinTenantIDs(tenantIDs), start, end, less, limit),
+	})
+	return nil
+}
+
+func parseQueryInsightsTime(value string, defaultTime time.Time) (time.Time, error) {
+	if value == "" {
+		return defaultTime, nil
+	}
+	ms, err := util.ParseTime(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return util.TimeFromMillis(ms), nil
+}
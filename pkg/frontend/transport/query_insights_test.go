@@ -0,0 +1,162 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+)
+
+func TestQueryInsightsConfig_Validate(t *testing.T) {
+	for name, test := range map[string]struct {
+		cfg         QueryInsightsConfig
+		expectedErr string
+	}{
+		"disabled": {
+			cfg: QueryInsightsConfig{},
+		},
+		"enabled": {
+			cfg: QueryInsightsConfig{Enabled: true, RetentionPeriod: time.Hour, MaxQueriesPerTenant: 10},
+		},
+		"invalid retention period": {
+			cfg:         QueryInsightsConfig{Enabled: true, MaxQueriesPerTenant: 10},
+			expectedErr: "retention period must be greater than 0",
+		},
+		"invalid max queries per tenant": {
+			cfg:         QueryInsightsConfig{Enabled: true, RetentionPeriod: time.Hour},
+			expectedErr: "max queries per tenant must be greater than 0",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := test.cfg.Validate()
+			if test.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestQueryInsightsStore(t *testing.T) {
+	now := time.Now()
+	store := newQueryInsightsStore(QueryInsightsConfig{RetentionPeriod: time.Hour, MaxQueriesPerTenant: 3})
+
+	store.add("tenant-1", &queryInsight{Query: "expired", Timestamp: now.Add(-2 * time.Hour), ResponseTimeSeconds: 10}, now)
+	store.add("tenant-1", &queryInsight{Query: "a", Timestamp: now.Add(-30 * time.Minute), ResponseTimeSeconds: 1}, now)
+	store.add("tenant-1", &queryInsight{Query: "b", Timestamp: now.Add(-20 * time.Minute), ResponseTimeSeconds: 3}, now)
+	store.add("tenant-1", &queryInsight{Query: "c", Timestamp: now.Add(-10 * time.Minute), ResponseTimeSeconds: 2}, now)
+	store.add("tenant-2", &queryInsight{Query: "d", Timestamp: now.Add(-10 * time.Minute), ResponseTimeSeconds: 5}, now)
+
+	byResponseTime := queryInsightsSortOrders["response_time"]
+	queries := func(insights []*queryInsight) []string {
+		var queries []string
+		for _, q := range insights {
+			queries = append(queries, q.Query)
+		}
+		return queries
+	}
+
+	t.Run("should return the queries of the tenant sorted, excluding the expired ones", func(t *testing.T) {
+		assert.Equal(t, []string{"b", "c", "a"}, queries(store.top("tenant-1", now.Add(-time.Hour), now, byResponseTime, 10)))
+		assert.Equal(t, []string{"d"}, queries(store.top("tenant-2", now.Add(-time.Hour), now, byResponseTime, 10)))
+		assert.Empty(t, store.top("tenant-3", now.Add(-time.Hour), now, byResponseTime, 10))
+	})
+
+	t.Run("should return up to limit queries", func(t *testing.T) {
+		assert.Equal(t, []string{"b"}, queries(store.top("tenant-1", now.Add(-time.Hour), now, byResponseTime, 1)))
+	})
+
+	t.Run("should return only the queries in the time window", func(t *testing.T) {
+		assert.Equal(t, []string{"a"}, queries(store.top("tenant-1", now.Add(-time.Hour), now.Add(-25*time.Minute), byResponseTime, 10)))
+		assert.Equal(t, []string{"c"}, queries(store.top("tenant-1", now.Add(-15*time.Minute), now, byResponseTime, 10)))
+	})
+
+	t.Run("should discard the oldest queries when the max queries per tenant is reached", func(t *testing.T) {
+		store.add("tenant-1", &queryInsight{Query: "e", Timestamp: now.Add(-5 * time.Minute)}, now)
+		assert.Equal(t, []string{"b", "c", "e"}, queries(store.top("tenant-1", now.Add(-time.Hour), now, byResponseTime, 10)))
+	})
+}
+
+func TestHandler_QueryInsights(t *testing.T) {
+	roundTripper := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if stats := querier_stats.FromContext(req.Context()); stats != nil {
+			stats.AddFetchedSeries(uint64(len(req.URL.Query().Get("query"))))
+		}
+		if req.URL.Query().Get("query") == "fail" {
+			return nil, errors.New("query failed")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	cfg := HandlerConfig{
+		QueryStatsEnabled:   true,
+		RedactedQueryParams: []string{"start"},
+		QueryInsights:       QueryInsightsConfig{Enabled: true, RetentionPeriod: time.Hour, MaxQueriesPerTenant: 10},
+	}
+	handler := NewHandler(cfg, &mockLimits{}, roundTripper, log.NewNopLogger(), nil)
+
+	do := func(orgID, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil).WithContext(user.InjectOrgID(context.Background(), orgID))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		return resp
+	}
+
+	for _, query := range []string{"up", "sum(up)", "fail"} {
+		do("tenant-1", "/api/v1/query_range?"+url.Values{"query": {query}, "start": {"0"}}.Encode())
+	}
+	do("tenant-2", "/api/v1/query?query=count(up)")
+
+	t.Run("should return the most expensive queries of the tenant", func(t *testing.T) {
+		resp := do("tenant-1", "/prometheus/api/v1/query_insights?sort=fetched_series&limit=2")
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		var body struct {
+			Status string          `json:"status"`
+			Data   []*queryInsight `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &body))
+		assert.Equal(t, "success", body.Status)
+		require.Len(t, body.Data, 2)
+
+		assert.Equal(t, "sum(up)", body.Data[0].Query)
+		assert.Equal(t, "/api/v1/query_range", body.Data[0].Path)
+		assert.Equal(t, redactedParamValue, body.Data[0].Start)
+		assert.Equal(t, resultSuccess, body.Data[0].Result)
+		assert.Equal(t, uint64(7), body.Data[0].Stats.FetchedSeriesCount)
+
+		assert.Equal(t, "fail", body.Data[1].Query)
+		assert.Equal(t, resultError, body.Data[1].Result)
+		assert.Equal(t, "query failed", body.Data[1].Error)
+	})
+
+	t.Run("should not return the queries of the other tenants", func(t *testing.T) {
+		resp := do("tenant-2", "/prometheus/api/v1/query_insights")
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Contains(t, resp.Body.String(), "count(up)")
+		assert.NotContains(t, resp.Body.String(), "sum(up)")
+	})
+
+	t.Run("should reject invalid parameters", func(t *testing.T) {
+		for _, params := range []string{"sort=unknown", "limit=0", "limit=abc", "start=abc", "start=2&end=1"} {
+			resp := do("tenant-1", "/prometheus/api/v1/query_insights?"+params)
+			assert.Equal(t, http.StatusBadRequest, resp.Code, params)
+		}
+	})
+}
@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+)
+
+// queryStatsParam is the query parameter used by clients to request the query stats
+// to be included in the response, like in the Prometheus API.
+const queryStatsParam = "stats"
+
+// responseStats are the query stats embedded in the response body under data.stats, in the same shape of
+// the stats returned by the Prometheus API. The stats returned by the PromQL engine (if any) are kept as is,
+// including the ones unknown to the query-frontend, except for the ones tracked by the query-frontend across
+// all the queriers the query has been split and sharded to.
+type responseStats map[string]json.RawMessage
+
+// responseStatsTimingsKeys and responseStatsSamplesKeys are the keys always returned by the Prometheus API
+// in the timings and samples objects of the stats.
+var (
+	responseStatsTimingsKeys = []string{"evalTotalTime", "resultSortTime", "queryPreparationTime", "innerEvalTime", "execQueueTime", "execTotalTime"}
+	responseStatsSamplesKeys = []string{"totalQueryableSamples", "peakSamples"}
+)
+
+// merge overrides the stats with the ones tracked by the query-frontend.
+func (s responseStats) merge(stats *querier_stats.Stats) error {
+	if err := s.mergeObject("timings", responseStatsTimingsKeys, map[string]interface{}{
+		"evalTotalTime": stats.LoadWallTime().Seconds(),
+	}); err != nil {
+		return err
+	}
+
+	if err := s.mergeObject("samples", responseStatsSamplesKeys, map[string]interface{}{
+		"totalQueryableSamples": stats.LoadSamplesProcessed(),
+	}); err != nil {
+		return err
+	}
+
+	return s.mergeObject("fetched", nil, map[string]interface{}{
+		"seriesCount": stats.LoadFetchedSeries(),
+		"chunksCount": stats.LoadFetchedChunks(),
+		"chunkBytes":  stats.LoadFetchedChunkBytes(),
+		"indexBytes":  stats.LoadFetchedIndexBytes(),
+	})
+}
+
+// mergeObject sets the values in the stats object with the input key, keeping its other fields. The keys
+// missing from the object are set to zero.
+func (s responseStats) mergeObject(key string, keys []string, values map[string]interface{}) error {
+	var obj map[string]json.RawMessage
+	if raw, ok := s[key]; ok {
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return errors.Wrapf(err, "unmarshal response stats %s", key)
+		}
+	}
+	if obj == nil {
+		obj = make(map[string]json.RawMessage, len(keys)+len(values))
+	}
+
+	for _, k := range keys {
+		if _, ok := obj[k]; !ok {
+			obj[k] = json.RawMessage("0")
+		}
+	}
+	for k, v := range values {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		obj[k] = encoded
+	}
+
+	encoded, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	s[key] = encoded
+	return nil
+}
+
+// embedQueryStatsInResponse sets the query stats in the data.stats object of the JSON response body,
+// matching the shape of the Prometheus API response when stats are requested. The response is left
+// untouched if it's not a successful uncompressed JSON response.
+func embedQueryStatsInResponse(resp *http.Response, stats *querier_stats.Stats) error {
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "" || !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		return nil
+	}
+
+	original, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return errors.Wrap(err, "read response body")
+	}
+
+	body, err := mergeQueryStatsIntoBody(original, stats)
+	if err != nil {
+		// Restore the original body, so that the response can still be returned to the client.
+		body = original
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	if resp.Header.Get("Content-Length") != "" {
+		resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	return err
+}
+
+// mergeQueryStatsIntoBody splices the query stats into the data object of the response body, replacing
+// the data.stats value if it exists. The rest of the body is copied as is, without decoding it.
+func mergeQueryStatsIntoBody(body []byte, stats *querier_stats.Stats) ([]byte, error) {
+	loc, err := findDataStats(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var merged responseStats
+	if loc.found {
+		if err := json.Unmarshal(body[loc.start:loc.end], &merged); err != nil {
+			return nil, errors.Wrap(err, "unmarshal response stats")
+		}
+	}
+	if merged == nil {
+		merged = responseStats{}
+	}
+	if err := merged.merge(stats); err != nil {
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(body)+len(encoded)+len(`,"stats":`))
+	out = append(out, body[:loc.start]...)
+	if !loc.found {
+		if !loc.emptyData {
+			out = append(out, ',')
+		}
+		out = append(out, `"stats":`...)
+	}
+	out = append(out, encoded...)
+	return append(out, body[loc.end:]...), nil
+}
+
+// dataStatsLocation is the location of the data.stats value in the response body. If the value
+// doesn't exist, start and end are both the offset of the data object closing brace.
+type dataStatsLocation struct {
+	start, end int
+	found      bool
+	// emptyData is whether the data object has no fields, if the stats have not been found.
+	emptyData bool
+}
+
+// findDataStats returns the location of the data.stats value in the JSON response body.
+func findDataStats(body []byte) (dataStatsLocation, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return dataStatsLocation{}, errors.New("response body is not a JSON object")
+	}
+
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return dataStatsLocation{}, errors.Wrap(err, "decode response body")
+		}
+		if key != "data" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return dataStatsLocation{}, errors.Wrap(err, "decode response body")
+			}
+			continue
+		}
+
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return dataStatsLocation{}, errors.New("response body has no data object")
+		}
+
+		emptyData := true
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return dataStatsLocation{}, errors.Wrap(err, "decode response data")
+			}
+			var value json.RawMessage
+			if err := dec.Decode(&value); err != nil {
+				return dataStatsLocation{}, errors.Wrap(err, "decode response data")
+			}
+			if key == "stats" {
+				end := int(dec.InputOffset())
+				return dataStatsLocation{start: end - len(value), end: end, found: true}, nil
+			}
+			emptyData = false
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return dataStatsLocation{}, errors.Wrap(err, "decode response data")
+		}
+		// The offset is right after the closing brace of the data object.
+		closing := int(dec.InputOffset()) - 1
+		return dataStatsLocation{start: closing, end: closing, emptyData: emptyData}, nil
+	}
+
+	return dataStatsLocation{}, errors.New("response body has no data object")
+}
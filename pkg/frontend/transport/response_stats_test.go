@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+)
+
+func TestEmbedQueryStatsInResponse(t *testing.T) {
+	stats := &querier_stats.Stats{}
+	stats.AddWallTime(2 * time.Second)
+	stats.AddFetchedSeries(10)
+	stats.AddFetchedChunkBytes(1024)
+	stats.AddFetchedChunks(20)
+	stats.AddSamplesProcessed(2400)
+
+	stats.AddFetchedIndexBytes(512)
+
+	const expectedFetched = `"fetched":{"chunkBytes":1024,"chunksCount":20,"indexBytes":512,"seriesCount":10}`
+	const expectedStats = `{` + expectedFetched + `,"samples":{"peakSamples":0,"totalQueryableSamples":2400},"timings":{"evalTotalTime":2,"execQueueTime":0,"execTotalTime":0,"innerEvalTime":0,"queryPreparationTime":0,"resultSortTime":0}}`
+
+	for name, test := range map[string]struct {
+		statusCode   int
+		headers      map[string]string
+		body         string
+		expectedBody string
+		expectedErr  bool
+	}{
+		"should add the stats to a response without stats": {
+			statusCode:   http.StatusOK,
+			headers:      map[string]string{"Content-Type": "application/json", "Content-Length": "70"},
+			body:         `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			expectedBody: `{"status":"success","data":{"resultType":"vector","result":[],"stats":` + expectedStats + `}}`,
+		},
+		"should add the stats to an empty data object": {
+			statusCode:   http.StatusOK,
+			headers:      map[string]string{"Content-Type": "application/json"},
+			body:         `{"status":"success","data":{ }}`,
+			expectedBody: `{"status":"success","data":{ "stats":` + expectedStats + `}}`,
+		},
+		"should copy the rest of the body as is": {
+			statusCode:   http.StatusOK,
+			headers:      map[string]string{"Content-Type": "application/json"},
+			body:         `{"data": {"result": [{"metric": {"__name__": "up"}, "value": [1, "1.0"]}], "resultType": "vector"}, "status": "success", "warnings": ["a"]}`,
+			expectedBody: `{"data": {"result": [{"metric": {"__name__": "up"}, "value": [1, "1.0"]}], "resultType": "vector","stats":` + expectedStats + `}, "status": "success", "warnings": ["a"]}`,
+		},
+		"should merge the stats with the ones returned by the PromQL engine": {
+			statusCode:   http.StatusOK,
+			headers:      map[string]string{"Content-Type": "application/json"},
+			body:         `{"status":"success","data":{"resultType":"vector","result":[],"stats":{"timings":{"evalTotalTime":0.5,"execTotalTime":1.5},"samples":{"totalQueryableSamples":5,"peakSamples":3}}}}`,
+			expectedBody: `{"status":"success","data":{"resultType":"vector","result":[],"stats":{` + expectedFetched + `,"samples":{"peakSamples":3,"totalQueryableSamples":2400},"timings":{"evalTotalTime":2,"execQueueTime":0,"execTotalTime":1.5,"innerEvalTime":0,"queryPreparationTime":0,"resultSortTime":0}}}}`,
+		},
+		"should keep the stats unknown to the query-frontend": {
+			statusCode:   http.StatusOK,
+			headers:      map[string]string{"Content-Type": "application/json"},
+			body:         `{"status":"success","data":{"resultType":"vector","result":[],"stats":{"custom":{"a":1},"timings":{"evalTotalTime":0.5,"customTime":1},"samples":{"totalQueryableSamplesPerStep":[[1,5]],"peakSamples":3}}}}`,
+			expectedBody: `{"status":"success","data":{"resultType":"vector","result":[],"stats":{"custom":{"a":1},` + expectedFetched + `,"samples":{"peakSamples":3,"totalQueryableSamples":2400,"totalQueryableSamplesPerStep":[[1,5]]},"timings":{"customTime":1,"evalTotalTime":2,"execQueueTime":0,"execTotalTime":0,"innerEvalTime":0,"queryPreparationTime":0,"resultSortTime":0}}}}`,
+		},
+		"should add the stats if the data.stats value is null": {
+			statusCode:   http.StatusOK,
+			headers:      map[string]string{"Content-Type": "application/json"},
+			body:         `{"status":"success","data":{"resultType":"vector","result":[],"stats":null}}`,
+			expectedBody: `{"status":"success","data":{"resultType":"vector","result":[],"stats":` + expectedStats + `}}`,
+		},
+		"should not modify a non-successful response": {
+			statusCode:   http.StatusBadRequest,
+			headers:      map[string]string{"Content-Type": "application/json"},
+			body:         `{"status":"error","errorType":"bad_data","error":"invalid query"}`,
+			expectedBody: `{"status":"error","errorType":"bad_data","error":"invalid query"}`,
+		},
+		"should not modify a compressed response": {
+			statusCode:   http.StatusOK,
+			headers:      map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"},
+			body:         `compressed`,
+			expectedBody: `compressed`,
+		},
+		"should not modify a non-JSON response": {
+			statusCode:   http.StatusOK,
+			headers:      map[string]string{"Content-Type": "text/plain"},
+			body:         `OK`,
+			expectedBody: `OK`,
+		},
+		"should return the original body if it can't be parsed": {
+			statusCode:   http.StatusOK,
+			headers:      map[string]string{"Content-Type": "application/json"},
+			body:         `{"status":"success"`,
+			expectedBody: `{"status":"success"`,
+			expectedErr:  true,
+		},
+		"should return the original body if it has no data object": {
+			statusCode:   http.StatusOK,
+			headers:      map[string]string{"Content-Type": "application/json"},
+			body:         `{"status":"success"}`,
+			expectedBody: `{"status":"success"}`,
+			expectedErr:  true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: test.statusCode,
+				Header:     http.Header{},
+				Body:       io.NopCloser(strings.NewReader(test.body)),
+			}
+			for k, v := range test.headers {
+				resp.Header.Set(k, v)
+			}
+
+			err := embedQueryStatsInResponse(resp, stats)
+			if test.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedBody, string(body))
+
+			if test.headers["Content-Length"] != "" {
+				assert.Equal(t, strconv.Itoa(len(body)), resp.Header.Get("Content-Length"))
+			}
+		})
+	}
+}
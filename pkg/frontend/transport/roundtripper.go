@@ -10,9 +10,12 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/httpgrpc/server"
+
+	"github.com/grafana/mimir/pkg/scheduler/queue"
 )
 
 // GrpcRoundTripper is similar to http.RoundTripper, but works with HTTP requests converted to protobuf messages.
@@ -44,6 +47,12 @@ func (a *grpcRoundTripperAdapter) RoundTrip(r *http.Request) (*http.Response, er
 		return nil, err
 	}
 
+	// Propagate the priority class of the query, whose header is missing from the requests
+	// built by the query middlewares.
+	if class := queue.PriorityClassFromContext(r.Context()); class != "" {
+		setHeader(req, queue.PriorityHeader, class)
+	}
+
 	resp, err := a.roundTripper.RoundTripGRPC(r.Context(), req)
 	if err != nil {
 		var ok bool
@@ -63,3 +72,14 @@ func (a *grpcRoundTripperAdapter) RoundTrip(r *http.Request) (*http.Response, er
 	}
 	return httpResp, nil
 }
+
+// setHeader sets the header of the request to the value, replacing any existing value.
+func setHeader(req *httpgrpc.HTTPRequest, name, value string) {
+	for _, h := range req.Headers {
+		if strings.EqualFold(h.Key, name) {
+			h.Values = []string{value}
+			return
+		}
+	}
+	req.Headers = append(req.Headers, &httpgrpc.Header{Key: name, Values: []string{value}})
+}
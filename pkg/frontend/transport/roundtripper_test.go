@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/grafana/mimir/pkg/scheduler/queue"
+)
+
+func TestGrpcRoundTripperAdapter_ShouldPropagatePriorityClass(t *testing.T) {
+	for name, test := range map[string]struct {
+		class         string
+		header        string
+		expectedValue []string
+	}{
+		"no priority class": {
+			expectedValue: nil,
+		},
+		"priority class in the context": {
+			class:         queue.PriorityClassDashboard,
+			expectedValue: []string{queue.PriorityClassDashboard},
+		},
+		"priority class in the context overriding the header": {
+			class:         queue.PriorityClassAlerting,
+			header:        queue.PriorityClassAdHoc,
+			expectedValue: []string{queue.PriorityClassAlerting},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var received *httpgrpc.HTTPRequest
+			adapter := AdaptGrpcRoundTripperToHTTPRoundTripper(grpcRoundTripperFunc(func(_ context.Context, req *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
+				received = req
+				return &httpgrpc.HTTPResponse{Code: http.StatusOK}, nil
+			}))
+
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil)
+			if test.header != "" {
+				req.Header.Set(queue.PriorityHeader, test.header)
+			}
+			if test.class != "" {
+				req = req.WithContext(queue.ContextWithPriorityClass(req.Context(), test.class))
+			}
+
+			_, err := adapter.RoundTrip(req)
+			require.NoError(t, err)
+			require.NotNil(t, received)
+
+			var values []string
+			for _, h := range received.Headers {
+				if http.CanonicalHeaderKey(h.Key) == queue.PriorityHeader {
+					values = append(values, h.Values...)
+				}
+			}
+			assert.Equal(t, test.expectedValue, values)
+		})
+	}
+}
+
+type grpcRoundTripperFunc func(context.Context, *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error)
+
+func (f grpcRoundTripperFunc) RoundTripGRPC(ctx context.Context, req *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
+	return f(ctx, req)
+}
@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/dskit/tenant"
+	"github.com/weaveworks/common/httpgrpc"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+// maxThrottledResponseBodySize is the max number of bytes of a throttled downstream response body used
+// as the error message returned to the client.
+const maxThrottledResponseBodySize = 1024
+
+// errThrottledQuery is the message of the errors returned for queries throttled downstream without a message.
+const errThrottledQuery = "the query has been throttled because of the tenant limits, please retry later"
+
+// throttledQueryError returns an API error, rejecting the request with HTTP status code 429, if the query
+// has been throttled downstream because of the per-tenant concurrency or rate limits. It returns nil otherwise.
+//
+// The query is throttled if the downstream failed with an HTTP status code 429 error, or returned a response
+// with HTTP status code 429. In the latter case the response body is consumed and closed.
+func throttledQueryError(resp *http.Response, err error) error {
+	if err != nil {
+		if errResp, ok := apierror.HTTPResponseFromError(err); ok {
+			if errResp.Code == http.StatusTooManyRequests {
+				return err
+			}
+			return nil
+		}
+		if errResp, ok := httpgrpc.HTTPResponseFromError(err); ok && errResp.Code == http.StatusTooManyRequests {
+			return apierror.New(apierror.TypeTooManyRequests, throttledQueryMessage(errResp.Body))
+		}
+		return nil
+	}
+
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+
+	var body []byte
+	if resp.Body != nil {
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, maxThrottledResponseBodySize))
+		_ = resp.Body.Close()
+	}
+	return apierror.New(apierror.TypeTooManyRequests, throttledQueryMessage(body))
+}
+
+// throttledQueryMessage returns the error message carried by the body of a throttled downstream response,
+// which is either a JSON API error or plain text.
+func throttledQueryMessage(body []byte) string {
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error != "" {
+		return apiErr.Error
+	}
+
+	if msg := strings.TrimSpace(string(body)); msg != "" {
+		return msg
+	}
+	return errThrottledQuery
+}
+
+// trackThrottledQuery sets the Retry-After header of the response to a throttled query, and tracks
+// the query in the throttled queries metric.
+func (f *Handler) trackThrottledQuery(w http.ResponseWriter, r *http.Request) {
+	if f.cfg.ThrottledQueryRetryAfter > 0 {
+		w.Header().Set("Retry-After", retryAfterSeconds(f.cfg.ThrottledQueryRetryAfter, rand.Float64()))
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return
+	}
+	userID := tenant.JoinTenantIDs(tenantIDs)
+	f.throttledQueries.WithLabelValues(userID).Inc()
+	if f.activeUsers != nil {
+		f.activeUsers.UpdateUserTimestamp(userID, time.Now())
+	}
+}
+
+// retryAfterSeconds returns the Retry-After header value, in seconds, for the base delay. A jitter up to half
+// of the base delay, scaled by jitterFactor in [0, 1), is added so that the throttled clients don't retry all at once.
+func retryAfterSeconds(base time.Duration, jitterFactor float64) string {
+	delay := base.Seconds() * (1 + jitterFactor/2)
+	return strconv.FormatInt(int64(math.Max(1, math.Ceil(delay))), 10)
+}
@@ -0,0 +1,143 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+)
+
+func TestHandler_ThrottledQueries(t *testing.T) {
+	for name, test := range map[string]struct {
+		roundTripper       roundTripperFunc
+		retryAfter         time.Duration
+		expectedThrottled  bool
+		expectedMessage    string
+		expectedRetryAfter bool
+	}{
+		"should not throttle successful queries": {
+			roundTripper: func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+			},
+			retryAfter: 5 * time.Second,
+		},
+		"should not throttle queries failed with other errors": {
+			roundTripper: func(*http.Request) (*http.Response, error) {
+				return nil, httpgrpc.Errorf(http.StatusServiceUnavailable, "unavailable")
+			},
+			retryAfter: 5 * time.Second,
+		},
+		"should throttle queries rejected downstream with a 429 response": {
+			roundTripper: func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusTooManyRequests, Body: io.NopCloser(strings.NewReader("too many outstanding requests\n"))}, nil
+			},
+			retryAfter:         5 * time.Second,
+			expectedThrottled:  true,
+			expectedMessage:    "too many outstanding requests",
+			expectedRetryAfter: true,
+		},
+		"should throttle queries rejected downstream with a 429 JSON response": {
+			roundTripper: func(*http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusTooManyRequests, Body: io.NopCloser(strings.NewReader(`{"status":"error","errorType":"too_many_requests","error":"rate limited"}`))}, nil
+			},
+			retryAfter:         5 * time.Second,
+			expectedThrottled:  true,
+			expectedMessage:    "rate limited",
+			expectedRetryAfter: true,
+		},
+		"should throttle queries failed with a 429 httpgrpc error": {
+			roundTripper: func(*http.Request) (*http.Response, error) {
+				return nil, httpgrpc.Errorf(http.StatusTooManyRequests, "too many outstanding requests")
+			},
+			retryAfter:         5 * time.Second,
+			expectedThrottled:  true,
+			expectedMessage:    "too many outstanding requests",
+			expectedRetryAfter: true,
+		},
+		"should throttle queries failed with a too many requests API error": {
+			roundTripper: func(*http.Request) (*http.Response, error) {
+				return nil, apierror.New(apierror.TypeTooManyRequests, "too many outstanding requests")
+			},
+			retryAfter:         5 * time.Second,
+			expectedThrottled:  true,
+			expectedMessage:    "too many outstanding requests",
+			expectedRetryAfter: true,
+		},
+		"should not set the Retry-After header if disabled": {
+			roundTripper: func(*http.Request) (*http.Response, error) {
+				return nil, httpgrpc.Errorf(http.StatusTooManyRequests, "too many outstanding requests")
+			},
+			expectedThrottled: true,
+			expectedMessage:   "too many outstanding requests",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			handler := NewHandler(HandlerConfig{QueryStatsEnabled: true, ThrottledQueryRetryAfter: test.retryAfter}, &mockLimits{}, test.roundTripper, log.NewNopLogger(), reg)
+
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil).WithContext(user.InjectOrgID(context.Background(), "12345"))
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			if !test.expectedThrottled {
+				assert.NotEqual(t, http.StatusTooManyRequests, resp.Code)
+				assert.Empty(t, resp.Header().Get("Retry-After"))
+				assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(""), "cortex_query_frontend_throttled_queries_total"))
+				return
+			}
+
+			assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+			assert.Contains(t, resp.Body.String(), `"errorType":"too_many_requests"`)
+			assert.Contains(t, resp.Body.String(), `"error":"`+test.expectedMessage+`"`)
+
+			if test.expectedRetryAfter {
+				retryAfter, err := strconv.Atoi(resp.Header().Get("Retry-After"))
+				require.NoError(t, err)
+				assert.GreaterOrEqual(t, retryAfter, 5)
+				assert.LessOrEqual(t, retryAfter, 8)
+			} else {
+				assert.Empty(t, resp.Header().Get("Retry-After"))
+			}
+
+			assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_query_frontend_throttled_queries_total Number of queries rejected with HTTP status code 429 because of the per-tenant concurrency or rate limits.
+				# TYPE cortex_query_frontend_throttled_queries_total counter
+				cortex_query_frontend_throttled_queries_total{user="12345"} 1
+			`), "cortex_query_frontend_throttled_queries_total"))
+		})
+	}
+}
+
+func TestThrottledQueryError(t *testing.T) {
+	assert.Nil(t, throttledQueryError(nil, errors.New("failed")))
+	assert.Nil(t, throttledQueryError(nil, apierror.New(apierror.TypeBadData, "bad data")))
+	assert.Nil(t, throttledQueryError(&http.Response{StatusCode: http.StatusOK}, nil))
+
+	err := throttledQueryError(&http.Response{StatusCode: http.StatusTooManyRequests}, nil)
+	require.Error(t, err)
+	assert.Equal(t, errThrottledQuery, err.Error())
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	assert.Equal(t, "10", retryAfterSeconds(10*time.Second, 0))
+	assert.Equal(t, "13", retryAfterSeconds(10*time.Second, 0.5))
+	assert.Equal(t, "15", retryAfterSeconds(10*time.Second, 0.99))
+	assert.Equal(t, "1", retryAfterSeconds(100*time.Millisecond, 0))
+}
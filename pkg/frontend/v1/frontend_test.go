@@ -39,6 +39,7 @@ import (
 	"github.com/grafana/mimir/pkg/frontend/v1/frontendv1pb"
 	querier_worker "github.com/grafana/mimir/pkg/querier/worker"
 	"github.com/grafana/mimir/pkg/scheduler/queue"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
@@ -132,13 +133,13 @@ func TestFrontendCheckReady(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			f := &Frontend{
 				log: log.NewNopLogger(),
-				requestQueue: queue.NewRequestQueue(5, 0,
+				requestQueue: queue.NewRequestQueue(5, 0, nil,
 					promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
 					promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
 				),
 			}
 			for i := 0; i < tt.connectedClients; i++ {
-				f.requestQueue.RegisterQuerierConnection("test")
+				f.requestQueue.RegisterQuerierConnection("test", "")
 			}
 			err := f.CheckReady(context.Background())
 			errMsg := ""
@@ -266,7 +267,7 @@ func testFrontend(t *testing.T, config Config, handler http.Handler, test func(a
 	r.PathPrefix("/").Handler(middleware.Merge(
 		middleware.AuthenticateUser,
 		middleware.Tracer{},
-	).Wrap(transport.NewHandler(handlerCfg, rt, logger, nil)))
+	).Wrap(transport.NewHandler(handlerCfg, limits{}, rt, logger, nil)))
 
 	httpServer := http.Server{
 		Handler: r,
@@ -299,3 +300,19 @@ type limits struct {
 func (l limits) MaxQueriersPerUser(_ string) int {
 	return l.queriers
 }
+
+func (l limits) QueryTimeout(_ string) time.Duration {
+	return 0
+}
+
+func (l limits) SlowQueryLogThreshold(_ string) time.Duration {
+	return 0
+}
+
+func (l limits) BlockedQueries(_ string) []*validation.BlockedQuery {
+	return nil
+}
+
+func (l limits) MaxResponseSizeBytes(_ string) int {
+	return 0
+}
@@ -49,6 +49,9 @@ type Config struct {
 	Addr string `yaml:"address" category:"advanced"`
 	Port int    `category:"advanced"`
 
+	// Sent to scheduler, which preferably dispatches the queries to queriers in the same zone.
+	Zone string `yaml:"instance_availability_zone" category:"experimental"`
+
 	// This configuration is injected internally.
 	QuerySchedulerDiscovery schedulerdiscovery.Config `yaml:"-"`
 }
@@ -62,6 +65,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.Var((*flagext.StringSlice)(&cfg.InfNames), "query-frontend.instance-interface-names", "List of network interface names to look up when finding the instance IP address. This address is sent to query-scheduler and querier, which uses it to send the query response back to query-frontend.")
 	f.StringVar(&cfg.Addr, "query-frontend.instance-addr", "", "IP address to advertise to the querier (via scheduler) (default is auto-detected from network interfaces).")
 	f.IntVar(&cfg.Port, "query-frontend.instance-port", 0, "Port to advertise to querier (via scheduler) (defaults to server.grpc-listen-port).")
+	f.StringVar(&cfg.Zone, "query-frontend.instance-availability-zone", "", "The availability zone where this instance is running. If set, the query-scheduler preferably dispatches the queries of this query-frontend to queriers running in the same availability zone, and to queriers in other zones only if no querier in the same zone is available.")
 
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("query-frontend.grpc-client-config", f)
 }
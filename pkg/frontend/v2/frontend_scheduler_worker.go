@@ -134,7 +134,7 @@ func (f *frontendSchedulerWorkers) addScheduler(address string) {
 	}
 
 	// No worker for this address yet, start a new one.
-	w = newFrontendSchedulerWorker(conn, address, f.frontendAddress, f.requestsCh, f.cfg.WorkerConcurrency, f.enqueuedRequests.WithLabelValues(address), f.log)
+	w = newFrontendSchedulerWorker(conn, address, f.frontendAddress, f.cfg.Zone, f.requestsCh, f.cfg.WorkerConcurrency, f.enqueuedRequests.WithLabelValues(address), f.log)
 
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -213,6 +213,7 @@ type frontendSchedulerWorker struct {
 	concurrency   int
 	schedulerAddr string
 	frontendAddr  string
+	frontendZone  string
 
 	// Context and cancellation used by individual goroutines.
 	ctx    context.Context
@@ -230,13 +231,14 @@ type frontendSchedulerWorker struct {
 	enqueuedRequests prometheus.Counter
 }
 
-func newFrontendSchedulerWorker(conn *grpc.ClientConn, schedulerAddr string, frontendAddr string, requestCh <-chan *frontendRequest, concurrency int, enqueuedRequests prometheus.Counter, log log.Logger) *frontendSchedulerWorker {
+func newFrontendSchedulerWorker(conn *grpc.ClientConn, schedulerAddr string, frontendAddr string, frontendZone string, requestCh <-chan *frontendRequest, concurrency int, enqueuedRequests prometheus.Counter, log log.Logger) *frontendSchedulerWorker {
 	w := &frontendSchedulerWorker{
 		log:              log,
 		conn:             conn,
 		concurrency:      concurrency,
 		schedulerAddr:    schedulerAddr,
 		frontendAddr:     frontendAddr,
+		frontendZone:     frontendZone,
 		requestCh:        requestCh,
 		cancelCh:         make(chan uint64, schedulerWorkerCancelChanCapacity),
 		enqueuedRequests: enqueuedRequests,
@@ -307,6 +309,7 @@ func (w *frontendSchedulerWorker) schedulerLoop(loop schedulerpb.SchedulerForFro
 	if err := loop.Send(&schedulerpb.FrontendToScheduler{
 		Type:            schedulerpb.INIT,
 		FrontendAddress: w.frontendAddr,
+		FrontendZone:    w.frontendZone,
 	}); err != nil {
 		return err
 	}
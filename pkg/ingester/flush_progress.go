@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ingester
+
+import (
+	"sort"
+	"sync"
+)
+
+const (
+	flushPhaseStopping = "stopping"
+	flushPhaseFlushing = "flushing"
+	flushPhaseDone     = "done"
+)
+
+// FlushProgress is a point-in-time snapshot of the progress of flushing the TSDB heads
+// to blocks when the ingester is shutting down.
+type FlushProgress struct {
+	// Phase is one of "stopping", "flushing" and "done". The "done" phase is reported
+	// once all tenants have been flushed and the ingester has left the ring.
+	Phase   string   `json:"phase"`
+	Flushed []string `json:"flushed"`
+	Pending []string `json:"pending"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// flushProgress tracks which tenants have been flushed by Ingester.Flush().
+// The zero value is ready to use.
+type flushProgress struct {
+	mtx     sync.Mutex
+	started bool
+	tenants map[string]bool // Tenant ID -> flushed.
+}
+
+// start resets the progress, tracking the input tenants as pending.
+func (p *flushProgress) start(userIDs []string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.started = true
+	p.tenants = make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		p.tenants[userID] = false
+	}
+}
+
+// flushed marks the input tenant as flushed.
+func (p *flushProgress) flushed(userID string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.tenants[userID] = true
+}
+
+// snapshot returns the current progress. Tenants are sorted by ID.
+func (p *flushProgress) snapshot() FlushProgress {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	res := FlushProgress{
+		Phase:   flushPhaseStopping,
+		Flushed: []string{},
+		Pending: []string{},
+	}
+	if !p.started {
+		return res
+	}
+
+	res.Phase = flushPhaseFlushing
+	for userID, flushed := range p.tenants {
+		if flushed {
+			res.Flushed = append(res.Flushed, userID)
+		} else {
+			res.Pending = append(res.Pending, userID)
+		}
+	}
+	sort.Strings(res.Flushed)
+	sort.Strings(res.Pending)
+
+	return res
+}
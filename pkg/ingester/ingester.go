@@ -10,10 +10,12 @@ package ingester
 
 import (
 	"context"
+	"encoding/json"
 	"expvar"
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -226,6 +228,9 @@ type Ingester struct {
 	// Maps the per-block series ID with its labels hash.
 	seriesHashCache *hashcache.SeriesHashCache
 
+	// Progress of the flush of TSDB heads on shutdown.
+	flushProgress flushProgress
+
 	// Timeout chosen for idle compactions.
 	compactionIdleTimeout time.Duration
 
@@ -718,6 +723,7 @@ func (i *Ingester) PushWithCleanup(ctx context.Context, pushReq *push.Request) (
 	level.Debug(spanlog).Log("event", "got appender", "numSeries", len(req.Timeseries))
 
 	oooTW := i.limits.OutOfOrderTimeWindow(userID)
+	debugSeries := validation.NewDebugSeriesLogger(ctx, i.logger, i.limits, userID)
 	for _, ts := range req.Timeseries {
 		// The labels must be sorted (in our case, it's guaranteed a write request
 		// has sorted labels once hit the ingester).
@@ -734,6 +740,9 @@ func (i *Ingester) PushWithCleanup(ctx context.Context, pushReq *push.Request) (
 			updateFirstPartial(func() error {
 				return newIngestErrSampleTimestampTooOld(model.Time(ts.Samples[0].TimestampMs), ts.Labels)
 			})
+			if debugSeries.Matches(ts.Labels) {
+				debugSeries.Log(mimirpb.FromLabelAdaptersToLabels(ts.Labels), len(ts.Samples), "rejected", "err", storage.ErrOutOfBounds)
+			}
 			continue
 		}
 
@@ -742,6 +751,8 @@ func (i *Ingester) PushWithCleanup(ctx context.Context, pushReq *push.Request) (
 
 		// To find out if any sample was added to this series, we keep old value.
 		oldSucceededSamplesCount := succeededSamplesCount
+		oldFailedSamplesCount := failedSamplesCount
+		var firstSeriesErr error
 
 		for _, s := range ts.Samples {
 			var err error
@@ -764,6 +775,9 @@ func (i *Ingester) PushWithCleanup(ctx context.Context, pushReq *push.Request) (
 			}
 
 			failedSamplesCount++
+			if firstSeriesErr == nil {
+				firstSeriesErr = err
+			}
 
 			// Check if the error is a soft error we can proceed on. If so, we keep track
 			// of it, so that we can return it back to the distributor, which will return a
@@ -816,6 +830,14 @@ func (i *Ingester) PushWithCleanup(ctx context.Context, pushReq *push.Request) (
 			return nil, wrapWithUser(err, userID)
 		}
 
+		if debugSeries.Matches(ts.Labels) {
+			keyvals := []interface{}{"succeeded", succeededSamplesCount - oldSucceededSamplesCount, "failed", failedSamplesCount - oldFailedSamplesCount}
+			if firstSeriesErr != nil {
+				keyvals = append(keyvals, "err", firstSeriesErr)
+			}
+			debugSeries.Log(mimirpb.FromLabelAdaptersToLabels(ts.Labels), len(ts.Samples), "appended", keyvals...)
+		}
+
 		if i.cfg.ActiveSeriesMetricsEnabled && succeededSamplesCount > oldSucceededSamplesCount {
 			db.activeSeries.UpdateSeries(mimirpb.FromLabelAdaptersToLabels(ts.Labels), startAppend, func(l labels.Labels) labels.Labels {
 				// we must already have copied the labels if succeededSamplesCount has been incremented.
@@ -953,6 +975,14 @@ func (i *Ingester) QueryExemplars(ctx context.Context, req *client.ExemplarQuery
 
 	i.metrics.queries.Inc()
 
+	// The exemplars older than the retention period are not returned, even if they're still in memory.
+	if minTime := exemplarsMinTime(time.Now(), i.limits.ExemplarsRetentionPeriod(userID)); from < minTime {
+		from = minTime
+	}
+	if from > through {
+		return &client.ExemplarQueryResponse{}, nil
+	}
+
 	db := i.getTSDB(userID)
 	if db == nil {
 		return &client.ExemplarQueryResponse{}, nil
@@ -2110,10 +2140,21 @@ func (i *Ingester) Flush() {
 
 	ctx := context.Background()
 
-	i.compactBlocks(ctx, true, nil)
-	if i.cfg.BlocksStorageConfig.TSDB.IsBlocksShippingEnabled() {
-		i.shipBlocks(ctx, nil)
-	}
+	// Flush and ship tenants one by one, so that the progress can be tracked per tenant.
+	userIDs := i.getTSDBUsers()
+	i.flushProgress.start(userIDs)
+
+	_ = concurrency.ForEachUser(ctx, userIDs, i.cfg.BlocksStorageConfig.TSDB.HeadCompactionConcurrency, func(ctx context.Context, userID string) error {
+		allowed := util.NewAllowedTenants([]string{userID}, nil)
+
+		i.compactBlocks(ctx, true, allowed)
+		if i.cfg.BlocksStorageConfig.TSDB.IsBlocksShippingEnabled() {
+			i.shipBlocks(ctx, allowed)
+		}
+
+		i.flushProgress.flushed(userID)
+		return nil
+	})
 
 	level.Info(i.logger).Log("msg", "finished flushing and shipping TSDB blocks")
 }
@@ -2227,6 +2268,15 @@ func newIngestErrExemplarMissingSeries(timestamp model.Time, seriesLabels, exemp
 	)
 }
 
+// exemplarsMinTime returns the min timestamp, in milliseconds, of the exemplars within the
+// retention period. It returns math.MinInt64 if the retention period is disabled.
+func exemplarsMinTime(now time.Time, retention time.Duration) int64 {
+	if retention <= 0 {
+		return math.MinInt64
+	}
+	return now.Add(-retention).UnixMilli()
+}
+
 func wrappedTSDBIngestExemplarOtherErr(ingestErr error, timestamp model.Time, seriesLabels, exemplarLabels []mimirpb.LabelAdapter) error {
 	if ingestErr == nil {
 		return nil
@@ -2260,6 +2310,66 @@ func (i *Ingester) getInstanceLimits() *InstanceLimits {
 //   - Change the state of ring to stop accepting writes.
 //   - Flush all the chunks.
 func (i *Ingester) ShutdownHandler(w http.ResponseWriter, r *http.Request) {
+	_ = i.shutdownWithFlushAndUnregister()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// flushAndForgetProgressInterval is how often FlushAndForgetHandler reports the flush progress.
+var flushAndForgetProgressInterval = time.Second
+
+// FlushAndForgetHandler triggers the same operations as ShutdownHandler, but rather than returning
+// once the ingester has terminated it streams the progress of the flush as newline-delimited JSON
+// objects (see FlushProgress). The last object is reported with the "done" phase once all in-memory
+// series have been flushed and the ingester has been unregistered from the ring.
+func (i *Ingester) FlushAndForgetHandler(w http.ResponseWriter, r *http.Request) {
+	done := make(chan error, 1)
+	go func() {
+		done <- i.shutdownWithFlushAndUnregister()
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	enc := json.NewEncoder(w)
+	report := func(progress FlushProgress) {
+		if err := enc.Encode(progress); err != nil {
+			level.Warn(i.logger).Log("msg", "failed to write flush progress", "err", err)
+			return
+		}
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	ticker := time.NewTicker(flushAndForgetProgressInterval)
+	defer ticker.Stop()
+
+	report(i.flushProgress.snapshot())
+	for {
+		select {
+		case err := <-done:
+			progress := i.flushProgress.snapshot()
+			progress.Phase = flushPhaseDone
+			if err != nil {
+				progress.Error = err.Error()
+			}
+			report(progress)
+			return
+
+		case <-ticker.C:
+			report(i.flushProgress.snapshot())
+
+		case <-r.Context().Done():
+			// The client went away. The shutdown keeps running in background.
+			return
+		}
+	}
+}
+
+// shutdownWithFlushAndUnregister stops the ingester, flushing all in-memory series to storage and
+// unregistering it from the ring, regardless of how the ingester has been configured.
+func (i *Ingester) shutdownWithFlushAndUnregister() error {
 	originalFlush := i.lifecycler.FlushOnShutdown()
 	// We want to flush the chunks if transfer fails irrespective of original flag.
 	i.lifecycler.SetFlushOnShutdown(true)
@@ -2268,12 +2378,12 @@ func (i *Ingester) ShutdownHandler(w http.ResponseWriter, r *http.Request) {
 	originalUnregister := i.lifecycler.ShouldUnregisterOnShutdown()
 	i.lifecycler.SetUnregisterOnShutdown(true)
 
-	_ = services.StopAndAwaitTerminated(context.Background(), i)
+	err := services.StopAndAwaitTerminated(context.Background(), i)
 	// Set state back to original.
 	i.lifecycler.SetFlushOnShutdown(originalFlush)
 	i.lifecycler.SetUnregisterOnShutdown(originalUnregister)
 
-	w.WriteHeader(http.StatusNoContent)
+	return err
 }
 
 // Using block store, the ingester is only available when it is in a Running state. The ingester is not available
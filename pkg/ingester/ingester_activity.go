@@ -149,6 +149,15 @@ func (i *ActivityTrackerWrapper) ShutdownHandler(w http.ResponseWriter, r *http.
 	i.ing.ShutdownHandler(w, r)
 }
 
+func (i *ActivityTrackerWrapper) FlushAndForgetHandler(w http.ResponseWriter, r *http.Request) {
+	ix := i.tracker.Insert(func() string {
+		return requestActivity(r.Context(), "Ingester/FlushAndForgetHandler", nil)
+	})
+	defer i.tracker.Delete(ix)
+
+	i.ing.FlushAndForgetHandler(w, r)
+}
+
 func requestActivity(ctx context.Context, name string, req interface{}) string {
 	userID, _ := tenant.TenantID(ctx)
 	traceID, _ := tracing.ExtractSampledTraceID(ctx)
@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/dskit/concurrency"
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
 	"github.com/grafana/dskit/test"
@@ -825,6 +826,116 @@ func TestIngester_Push(t *testing.T) {
 	}
 }
 
+func TestIngester_QueryExemplars_ShouldNotReturnExemplarsOlderThanRetentionPeriod(t *testing.T) {
+	cfg := defaultIngesterTestConfig(t)
+	limits := defaultLimitsTestConfig()
+	limits.MaxGlobalExemplarsPerUser = 100
+	limits.ExemplarsRetentionPeriod = model.Duration(time.Hour)
+
+	i, err := prepareIngesterWithBlocksStorageAndLimits(t, cfg, limits, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	// Wait until the ingester is healthy
+	test.Poll(t, 100*time.Millisecond, 1, func() interface{} {
+		return i.lifecycler.HealthyInstancesCount()
+	})
+
+	ctx := user.InjectOrgID(context.Background(), userID)
+	oldTs := time.Now().Add(-2 * time.Hour).UnixMilli()
+	recentTs := time.Now().UnixMilli()
+	seriesLabels := mimirpb.FromLabelsToLabelAdapters(labels.FromStrings(labels.MetricName, "test"))
+
+	req := &mimirpb.WriteRequest{
+		Timeseries: []mimirpb.PreallocTimeseries{{
+			TimeSeries: &mimirpb.TimeSeries{
+				Labels:  seriesLabels,
+				Samples: []mimirpb.Sample{{Value: 1, TimestampMs: oldTs}, {Value: 2, TimestampMs: recentTs}},
+				Exemplars: []mimirpb.Exemplar{
+					{Labels: []mimirpb.LabelAdapter{{Name: "traceID", Value: "old"}}, Value: 1, TimestampMs: oldTs},
+					{Labels: []mimirpb.LabelAdapter{{Name: "traceID", Value: "recent"}}, Value: 2, TimestampMs: recentTs},
+				},
+			},
+		}},
+	}
+	_, err = i.Push(ctx, req)
+	require.NoError(t, err)
+
+	res, err := i.QueryExemplars(ctx, &client.ExemplarQueryRequest{
+		StartTimestampMs: math.MinInt64,
+		EndTimestampMs:   math.MaxInt64,
+		Matchers: []*client.LabelMatchers{
+			{Matchers: []*client.LabelMatcher{{Type: client.EQUAL, Name: labels.MetricName, Value: "test"}}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Timeseries, 1)
+	assert.Equal(t, []mimirpb.Exemplar{
+		{Labels: []mimirpb.LabelAdapter{{Name: "traceID", Value: "recent"}}, Value: 2, TimestampMs: recentTs},
+	}, res.Timeseries[0].Exemplars)
+
+	// A query ending before the retention period returns no exemplars.
+	res, err = i.QueryExemplars(ctx, &client.ExemplarQueryRequest{
+		StartTimestampMs: math.MinInt64,
+		EndTimestampMs:   oldTs,
+		Matchers: []*client.LabelMatchers{
+			{Matchers: []*client.LabelMatcher{{Type: client.EQUAL, Name: labels.MetricName, Value: "test"}}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, res.Timeseries)
+}
+
+func TestIngester_Push_ShouldLogDebugSeries(t *testing.T) {
+	cfg := defaultIngesterTestConfig(t)
+	limits := defaultLimitsTestConfig()
+	limits.DebugSeriesSelector = `{__name__="test"}`
+
+	i, err := prepareIngesterWithBlocksStorageAndLimits(t, cfg, limits, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	logs := &concurrency.SyncBuffer{}
+	i.logger = log.NewLogfmtLogger(logs)
+
+	// Wait until the ingester is healthy
+	test.Poll(t, 100*time.Millisecond, 1, func() interface{} {
+		return i.lifecycler.HealthyInstancesCount()
+	})
+
+	ctx := user.InjectOrgID(context.Background(), userID)
+	now := time.Now().UnixMilli()
+
+	_, err = i.Push(ctx, mimirpb.ToWriteRequest(
+		[]labels.Labels{labels.FromStrings(labels.MetricName, "test"), labels.FromStrings(labels.MetricName, "other")},
+		[]mimirpb.Sample{{Value: 1, TimestampMs: now}, {Value: 1, TimestampMs: now}},
+		nil, nil, mimirpb.API))
+	require.NoError(t, err)
+
+	// The first sample is out of order.
+	_, err = i.Push(ctx, &mimirpb.WriteRequest{
+		Timeseries: []mimirpb.PreallocTimeseries{{
+			TimeSeries: &mimirpb.TimeSeries{
+				Labels:  mimirpb.FromLabelsToLabelAdapters(labels.FromStrings(labels.MetricName, "test")),
+				Samples: []mimirpb.Sample{{Value: 2, TimestampMs: now - 1000}, {Value: 3, TimestampMs: now + 1000}},
+			},
+		}},
+	})
+	require.Error(t, err)
+
+	var lines []string
+	for _, line := range strings.Split(logs.String(), "\n") {
+		if strings.Contains(line, `msg="debug series"`) {
+			lines = append(lines, line)
+		}
+	}
+	require.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `series="{__name__=\"test\"}" samples=1 outcome=appended succeeded=1 failed=0`)
+	assert.Contains(t, lines[1], `series="{__name__=\"test\"}" samples=2 outcome=appended succeeded=1 failed=1 err="out of order sample"`)
+}
+
 func TestIngester_Push_ShouldCorrectlyTrackMetricsInMultiTenantScenario(t *testing.T) {
 	metricLabelAdapters := []mimirpb.LabelAdapter{{Name: labels.MetricName, Value: "test"}}
 	metricLabels := mimirpb.FromLabelAdaptersToLabels(metricLabelAdapters)
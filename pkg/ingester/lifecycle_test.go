@@ -7,6 +7,7 @@ package ingester
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -20,10 +21,13 @@ import (
 	"github.com/grafana/dskit/ring"
 	"github.com/grafana/dskit/services"
 	"github.com/grafana/dskit/test"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
 
 	"github.com/grafana/mimir/pkg/ingester/client"
+	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
@@ -130,6 +134,60 @@ func TestIngester_ShutdownHandler(t *testing.T) {
 	}
 }
 
+func TestIngester_FlushAndForgetHandler(t *testing.T) {
+	config := defaultIngesterTestConfig(t)
+	limits := defaultLimitsTestConfig()
+	config.IngesterRing.UnregisterOnShutdown = false
+
+	ing, err := prepareIngesterWithBlocksStorageAndLimits(t, config, limits, "", nil)
+	require.NoError(t, err)
+	defer services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), ing))
+
+	// Make sure the ingester has been added to the ring.
+	test.Poll(t, 100*time.Millisecond, 1, func() interface{} {
+		return numTokens(config.IngesterRing.KVStore.Mock, "localhost", IngesterRingKey)
+	})
+
+	tenants := []string{"user-1", "user-2"}
+	for _, tenantID := range tenants {
+		req, _, _, _ := mockWriteRequest(t, labels.FromStrings(labels.MetricName, "test"), 1, util.TimeToMillis(time.Now()))
+		_, err := ing.Push(user.InjectOrgID(context.Background(), tenantID), req)
+		require.NoError(t, err)
+	}
+
+	recorder := httptest.NewRecorder()
+	ing.FlushAndForgetHandler(recorder, httptest.NewRequest("POST", "/ingester/flush-and-forget", nil))
+	require.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+	require.Equal(t, "application/x-ndjson", recorder.Result().Header.Get("Content-Type"))
+
+	var reports []FlushProgress
+	dec := json.NewDecoder(recorder.Body)
+	for dec.More() {
+		var progress FlushProgress
+		require.NoError(t, dec.Decode(&progress))
+		reports = append(reports, progress)
+	}
+
+	// The first report is written before the shutdown has progressed, the last one once it has completed.
+	require.GreaterOrEqual(t, len(reports), 2)
+	assert.Equal(t, FlushProgress{Phase: "done", Flushed: tenants, Pending: []string{}}, reports[len(reports)-1])
+
+	// Make sure the ingester has been removed from the ring even when UnregisterFromRing is false.
+	assert.Equal(t, 0, numTokens(config.IngesterRing.KVStore.Mock, "localhost", IngesterRingKey))
+
+	// Make sure the in-memory series have been shipped to the storage.
+	for _, tenantID := range tenants {
+		var blocks []string
+		require.NoError(t, ing.bucket.Iter(context.Background(), tenantID+"/", func(name string) error {
+			blocks = append(blocks, name)
+			return nil
+		}))
+		assert.NotEmpty(t, blocks, "tenant: %s", tenantID)
+	}
+}
+
 // numTokens determines the number of tokens owned by the specified
 // address
 func numTokens(c kv.Client, name, ringKey string) int {
@@ -219,6 +219,9 @@ func (c *Config) Validate(log log.Logger) error {
 	if err := c.BlocksStorage.Validate(); err != nil {
 		return errors.Wrap(err, "invalid TSDB config")
 	}
+	if err := c.LimitsConfig.Validate(); err != nil {
+		return errors.Wrap(err, "invalid limits config")
+	}
 	if err := c.Distributor.Validate(c.LimitsConfig); err != nil {
 		return errors.Wrap(err, "invalid distributor config")
 	}
@@ -647,6 +650,7 @@ type Mimir struct {
 	QuerierQueryable         prom_storage.SampleAndChunkQueryable
 	ExemplarQueryable        prom_storage.ExemplarQueryable
 	MetadataSupplier         querier.MetadataSupplier
+	CardinalityAnalyzer      querier.CardinalityAnalyzer
 	QuerierEngine            *promql.Engine
 	QueryFrontendTripperware querymiddleware.Tripperware
 	Ruler                    *ruler.Ruler
@@ -307,8 +307,9 @@ func (t *Mimir) initQueryable() (serv services.Service, err error) {
 	// Create a querier queryable and PromQL engine
 	t.QuerierQueryable, t.ExemplarQueryable, t.QuerierEngine = querier.New(t.Cfg.Querier, t.Overrides, t.Distributor, t.StoreQueryables, querierRegisterer, util_log.Logger, t.ActivityTracker)
 
-	// Use the distributor to return metric metadata by default
+	// Use the distributor to return metric metadata and run the cardinality analysis by default
 	t.MetadataSupplier = t.Distributor
+	t.CardinalityAnalyzer = t.Distributor
 
 	// Register the default endpoints that are always enabled for the querier module
 	t.API.RegisterQueryable(t.QuerierQueryable, t.Distributor)
@@ -326,6 +327,7 @@ func (t *Mimir) initTenantFederation() (serv services.Service, err error) {
 		t.QuerierQueryable = querier.NewSampleAndChunkQueryable(tenantfederation.NewQueryable(t.QuerierQueryable, bypassForSingleQuerier, util_log.Logger))
 		t.ExemplarQueryable = tenantfederation.NewExemplarQueryable(t.ExemplarQueryable, bypassForSingleQuerier, util_log.Logger)
 		t.MetadataSupplier = tenantfederation.NewMetadataSupplier(t.MetadataSupplier, util_log.Logger)
+		t.CardinalityAnalyzer = tenantfederation.NewCardinalityAnalyzer(t.CardinalityAnalyzer, util_log.Logger)
 	}
 	return nil, nil
 }
@@ -390,8 +392,8 @@ func (t *Mimir) initQuerier() (serv services.Service, err error) {
 		t.QuerierQueryable,
 		t.ExemplarQueryable,
 		t.MetadataSupplier,
+		t.CardinalityAnalyzer,
 		t.QuerierEngine,
-		t.Distributor,
 		t.Registerer,
 		util_log.Logger,
 		t.Overrides,
@@ -540,21 +542,34 @@ func (t *Mimir) initQueryFrontend() (serv services.Service, err error) {
 	// Wrap roundtripper into Tripperware.
 	roundTripper = t.QueryFrontendTripperware(roundTripper)
 
-	handler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, util_log.Logger, t.Registerer)
+	handler := transport.NewHandler(t.Cfg.Frontend.Handler, t.Overrides, roundTripper, util_log.Logger, t.Registerer)
 	t.API.RegisterQueryFrontendHandler(handler, t.BuildInfoHandler)
 
+	var frontendSvc services.Service
 	if frontendV1 != nil {
 		t.API.RegisterQueryFrontend1(frontendV1)
 		t.Frontend = frontendV1
 
-		return frontendV1, nil
+		frontendSvc = frontendV1
 	} else if frontendV2 != nil {
 		t.API.RegisterQueryFrontend2(frontendV2)
 
-		return frontendV2, nil
+		frontendSvc = frontendV2
 	}
 
-	return nil, nil
+	// Stop the handler once the query-frontend has stopped, so that its pending reports are run before exiting.
+	if frontendSvc == nil {
+		return services.NewIdleService(nil, func(_ error) error {
+			handler.Stop()
+			return nil
+		}), nil
+	}
+	frontendSvc.AddListener(services.NewListener(nil, nil, nil,
+		func(_ services.State) { handler.Stop() },
+		func(_ services.State, _ error) { handler.Stop() },
+	))
+
+	return frontendSvc, nil
 }
 
 func (t *Mimir) initRulerStorage() (serv services.Service, err error) {
@@ -584,7 +599,11 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 		if err != nil {
 			return nil, err
 		}
-		remoteQuerier := ruler.NewRemoteQuerier(queryFrontendClient, t.Cfg.Querier.EngineConfig.Timeout, t.Cfg.API.PrometheusHTTPPrefix, util_log.Logger, ruler.WithOrgIDMiddleware)
+		timeout := t.Cfg.Ruler.QueryFrontend.Timeout
+		if timeout == 0 {
+			timeout = t.Cfg.Querier.EngineConfig.Timeout
+		}
+		remoteQuerier := ruler.NewRemoteQuerier(queryFrontendClient, timeout, t.Cfg.Ruler.QueryFrontend.RetryConfig(), t.Cfg.API.PrometheusHTTPPrefix, util_log.Logger, ruler.WithOrgIDMiddleware)
 
 		embeddedQueryable = prom_remote.NewSampleAndChunkQueryableClient(
 			remoteQuerier,
@@ -667,7 +686,7 @@ func (t *Mimir) initRuler() (serv services.Service, err error) {
 	t.API.RegisterRuler(t.Ruler)
 
 	// Expose HTTP configuration and prometheus-compatible Ruler APIs
-	t.API.RegisterRulerAPI(ruler.NewAPI(t.Ruler, t.RulerStorage, util_log.Logger), t.Cfg.Ruler.EnableAPI, t.BuildInfoHandler)
+	t.API.RegisterRulerAPI(ruler.NewAPI(t.Ruler, t.RulerStorage, queryFunc, util_log.Logger), t.Cfg.Ruler.EnableAPI, t.BuildInfoHandler)
 
 	return t.Ruler, nil
 }
@@ -6,6 +6,7 @@
 package querier
 
 import (
+	"fmt"
 	"math"
 	"sort"
 
@@ -48,6 +49,9 @@ type blockQuerierSeriesSet struct {
 	series   []*storepb.Series
 	warnings storage.Warnings
 
+	// Time range of the query, the samples of the boundary chunks are trimmed to.
+	mint, maxt int64
+
 	// next response to process
 	next int
 
@@ -74,7 +78,7 @@ func (bqss *blockQuerierSeriesSet) Next() bool {
 		bqss.next++
 	}
 
-	bqss.currSeries = newBlockQuerierSeries(currLabels, currChunks)
+	bqss.currSeries = newBlockQuerierSeries(currLabels, currChunks, bqss.mint, bqss.maxt)
 	return true
 }
 
@@ -91,17 +95,31 @@ func (bqss *blockQuerierSeriesSet) Warnings() storage.Warnings {
 }
 
 // newBlockQuerierSeries makes a new blockQuerierSeries. Input labels must be already sorted by name.
-func newBlockQuerierSeries(lbls []labels.Label, chunks []storepb.AggrChunk) *blockQuerierSeries {
+// The samples of the chunks flagged as boundary by the store-gateway are trimmed to mint and maxt.
+func newBlockQuerierSeries(lbls []labels.Label, chunks []storepb.AggrChunk, mint, maxt int64) *blockQuerierSeries {
 	sort.Slice(chunks, func(i, j int) bool {
 		return chunks[i].MinTime < chunks[j].MinTime
 	})
 
-	return &blockQuerierSeries{labels: lbls, chunks: chunks}
+	return &blockQuerierSeries{labels: lbls, chunks: chunks, mint: mint, maxt: maxt}
+}
+
+// UnsupportedChunkEncodingError is returned when iterating a series whose chunks can't be decoded by the
+// querier, like the native histogram chunks which are not supported by the vendored TSDB yet.
+type UnsupportedChunkEncodingError struct {
+	Encoding         storepb.Chunk_Encoding
+	Labels           labels.Labels
+	MinTime, MaxTime int64
+}
+
+func (e UnsupportedChunkEncodingError) Error() string {
+	return fmt.Sprintf("unsupported chunk encoding %s (series: %v min time: %d max time: %d)", e.Encoding, e.Labels, e.MinTime, e.MaxTime)
 }
 
 type blockQuerierSeries struct {
-	labels labels.Labels
-	chunks []storepb.AggrChunk
+	labels     labels.Labels
+	chunks     []storepb.AggrChunk
+	mint, maxt int64
 }
 
 func (bqs *blockQuerierSeries) Labels() labels.Labels {
@@ -117,18 +135,75 @@ func (bqs *blockQuerierSeries) Iterator() chunkenc.Iterator {
 	its := make([]iteratorWithMaxTime, 0, len(bqs.chunks))
 
 	for _, c := range bqs.chunks {
+		if c.Raw.Type != storepb.Chunk_XOR {
+			return series.NewErrIterator(UnsupportedChunkEncodingError{Encoding: c.Raw.Type, Labels: bqs.Labels(), MinTime: c.MinTime, MaxTime: c.MaxTime})
+		}
+
 		ch, err := chunkenc.FromData(chunkenc.EncXOR, c.Raw.Data)
 		if err != nil {
 			return series.NewErrIterator(errors.Wrapf(err, "failed to initialize chunk from XOR encoded raw data (series: %v min time: %d max time: %d)", bqs.Labels(), c.MinTime, c.MaxTime))
 		}
 
+		// Only the chunks straddling the query time range may have samples outside of it, so the
+		// chunks fully inside it are iterated without checking the timestamps.
 		it := ch.Iterator(nil)
+		if c.Boundary {
+			it = newTrimmedChunkIterator(it, bqs.mint, bqs.maxt)
+		}
 		its = append(its, iteratorWithMaxTime{it, c.MaxTime})
 	}
 
 	return newBlockQuerierSeriesIterator(bqs.Labels(), its)
 }
 
+// trimmedChunkIterator iterates the samples of a chunk within mint and maxt, both inclusive.
+type trimmedChunkIterator struct {
+	chunkenc.Iterator
+	mint, maxt int64
+	done       bool
+}
+
+func newTrimmedChunkIterator(it chunkenc.Iterator, mint, maxt int64) *trimmedChunkIterator {
+	return &trimmedChunkIterator{Iterator: it, mint: mint, maxt: maxt}
+}
+
+func (it *trimmedChunkIterator) Seek(t int64) bool {
+	if it.done {
+		return false
+	}
+	if t < it.mint {
+		t = it.mint
+	}
+	return it.checkMaxTime(it.Iterator.Seek(t))
+}
+
+func (it *trimmedChunkIterator) Next() bool {
+	if it.done {
+		return false
+	}
+
+	// The samples before mint can only be at the beginning of the chunk.
+	if !it.Iterator.Next() {
+		it.done = true
+		return false
+	}
+	if t, _ := it.Iterator.At(); t < it.mint {
+		return it.checkMaxTime(it.Iterator.Seek(it.mint))
+	}
+	return it.checkMaxTime(true)
+}
+
+// checkMaxTime returns ok, unless the current sample is after maxt, in which case the iterator is exhausted.
+func (it *trimmedChunkIterator) checkMaxTime(ok bool) bool {
+	if ok {
+		if t, _ := it.Iterator.At(); t <= it.maxt {
+			return true
+		}
+	}
+	it.done = true
+	return false
+}
+
 func newBlockQuerierSeriesIterator(labels labels.Labels, its []iteratorWithMaxTime) *blockQuerierSeriesIterator {
 	return &blockQuerierSeriesIterator{labels: labels, iterators: its, lastT: math.MinInt64}
 }
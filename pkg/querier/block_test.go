@@ -69,13 +69,23 @@ func TestBlockQuerierSeries(t *testing.T) {
 			expectedMetric: labels.FromStrings("foo", "bar"),
 			expectedErr:    `cannot iterate chunk for series: {foo="bar"}: EOF`,
 		},
+		"should return error on native histogram chunk": {
+			series: &storepb.Series{
+				Labels: []mimirpb.LabelAdapter{{Name: "foo", Value: "bar"}},
+				Chunks: []storepb.AggrChunk{
+					{MinTime: minTimestamp.Unix() * 1000, MaxTime: maxTimestamp.Unix() * 1000, Raw: &storepb.Chunk{Type: storepb.Chunk_Histogram, Data: []byte{0, 1}}},
+				},
+			},
+			expectedMetric: labels.FromStrings("foo", "bar"),
+			expectedErr:    `unsupported chunk encoding Chunk_Histogram (series: {foo="bar"} min time: 1000 max time: 10000)`,
+		},
 	}
 
 	for testName, testData := range tests {
 		testData := testData
 
 		t.Run(testName, func(t *testing.T) {
-			series := newBlockQuerierSeries(mimirpb.FromLabelAdaptersToLabels(testData.series.Labels), testData.series.Chunks)
+			series := newBlockQuerierSeries(mimirpb.FromLabelAdaptersToLabels(testData.series.Labels), testData.series.Chunks, math.MinInt64, math.MaxInt64)
 
 			assert.Equal(t, testData.expectedMetric, series.Labels())
 
@@ -101,6 +111,97 @@ func TestBlockQuerierSeries(t *testing.T) {
 	}
 }
 
+func TestBlockQuerierSeries_ShouldReturnUnsupportedChunkEncodingError(t *testing.T) {
+	chks := []storepb.AggrChunk{
+		{MinTime: 1000, MaxTime: 2000, Raw: &storepb.Chunk{Type: storepb.Chunk_XOR, Data: mockTSDBChunkData()}},
+		{MinTime: 3000, MaxTime: 4000, Raw: &storepb.Chunk{Type: storepb.Chunk_FloatHistogram, Data: []byte{0, 1}}},
+	}
+	series := newBlockQuerierSeries(labels.FromStrings("foo", "bar"), chks, math.MinInt64, math.MaxInt64)
+
+	it := series.Iterator()
+	require.False(t, it.Next())
+
+	var encErr UnsupportedChunkEncodingError
+	require.ErrorAs(t, it.Err(), &encErr)
+	assert.Equal(t, UnsupportedChunkEncodingError{Encoding: storepb.Chunk_FloatHistogram, Labels: labels.FromStrings("foo", "bar"), MinTime: 3000, MaxTime: 4000}, encErr)
+}
+
+func TestBlockQuerierSeries_ShouldTrimBoundaryChunks(t *testing.T) {
+	points := func(ts ...int64) []promql.Point {
+		var res []promql.Point
+		for _, t := range ts {
+			res = append(res, promql.Point{T: t, V: float64(t)})
+		}
+		return res
+	}
+	boundary := func(chk storepb.AggrChunk) storepb.AggrChunk {
+		chk.Boundary = true
+		return chk
+	}
+
+	tests := map[string]struct {
+		chunks     []storepb.AggrChunk
+		mint, maxt int64
+		expected   []int64
+	}{
+		"chunks fully inside the time range are not trimmed": {
+			chunks:   []storepb.AggrChunk{createAggrChunkWithSamples(points(10, 20, 30)...)},
+			mint:     20,
+			maxt:     20,
+			expected: []int64{10, 20, 30},
+		},
+		"boundary chunk straddling the start of the time range": {
+			chunks:   []storepb.AggrChunk{boundary(createAggrChunkWithSamples(points(10, 20, 30)...)), createAggrChunkWithSamples(points(40, 50)...)},
+			mint:     15,
+			maxt:     50,
+			expected: []int64{20, 30, 40, 50},
+		},
+		"boundary chunk straddling the end of the time range": {
+			chunks:   []storepb.AggrChunk{createAggrChunkWithSamples(points(10, 20)...), boundary(createAggrChunkWithSamples(points(30, 40, 50)...))},
+			mint:     10,
+			maxt:     40,
+			expected: []int64{10, 20, 30, 40},
+		},
+		"boundary chunk straddling the whole time range": {
+			chunks:   []storepb.AggrChunk{boundary(createAggrChunkWithSamples(points(10, 20, 30, 40)...))},
+			mint:     20,
+			maxt:     30,
+			expected: []int64{20, 30},
+		},
+		"boundary chunk without samples in the time range": {
+			chunks:   []storepb.AggrChunk{boundary(createAggrChunkWithSamples(points(10, 40)...))},
+			mint:     20,
+			maxt:     30,
+			expected: nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			series := newBlockQuerierSeries(labels.FromStrings("foo", "bar"), test.chunks, test.mint, test.maxt)
+
+			var actual []int64
+			it := series.Iterator()
+			for it.Next() {
+				ts, _ := it.At()
+				actual = append(actual, ts)
+			}
+			require.NoError(t, it.Err())
+			assert.Equal(t, test.expected, actual)
+
+			// Seeking before the time range returns the first sample within it.
+			it = series.Iterator()
+			if len(test.expected) == 0 {
+				assert.False(t, it.Seek(math.MinInt64))
+				return
+			}
+			require.True(t, it.Seek(math.MinInt64))
+			ts, _ := it.At()
+			assert.Equal(t, test.expected[0], ts)
+		})
+	}
+}
+
 func mockTSDBChunkData() []byte {
 	chunk := chunkenc.NewXORChunk()
 	appender, err := chunk.Appender()
@@ -448,7 +549,7 @@ func Benchmark_newBlockQuerierSeries(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		newBlockQuerierSeries(lbls, chunks)
+		newBlockQuerierSeries(lbls, chunks, math.MinInt64, math.MaxInt64)
 	}
 }
 
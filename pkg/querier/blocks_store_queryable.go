@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"sort"
 	"strings"
 	"sync"
@@ -32,6 +33,7 @@ import (
 	"go.uber.org/atomic"
 	"golang.org/x/sync/errgroup"
 	grpc_metadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 
 	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/bucket"
@@ -74,7 +76,7 @@ type BlocksStoreSet interface {
 	// GetClientsFor returns the store gateway clients that should be used to
 	// query the set of blocks in input. The exclude parameter is the map of
 	// blocks -> store-gateway addresses that should be excluded.
-	GetClientsFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error)
+	GetClientsFor(userID string, blocks bucketindex.Blocks, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error)
 }
 
 // BlocksFinder is the interface used to find blocks for a given user and time range.
@@ -103,6 +105,8 @@ type BlocksStoreLimits interface {
 	MaxLabelsQueryLength(userID string) time.Duration
 	MaxChunksPerQuery(userID string) int
 	StoreGatewayTenantShardSize(userID string) int
+	StoreGatewayRecentBlocksPeriod(userID string) time.Duration
+	StoreGatewayRecentBlocksReplicationFactor(userID string) int
 }
 
 type blocksStoreQueryableMetrics struct {
@@ -535,7 +539,7 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 
 	var (
 		// At the beginning the list of blocks to query are all known blocks.
-		remainingBlocks = knownBlocks.GetULIDs()
+		remainingBlocks = knownBlocks
 		attemptedBlocks = map[ulid.ULID][]string{}
 		touchedStores   = map[string]struct{}{}
 
@@ -589,12 +593,26 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 		level.Debug(logger).Log("msg", "consistency check failed", "attempt", attempt, "missing blocks", strings.Join(convertULIDsToString(missingBlocks), " "))
 
 		// The next attempt should just query the missing blocks.
-		remainingBlocks = missingBlocks
+		remainingBlocks = filterBlocksByID(knownBlocks, missingBlocks)
 	}
 
 	// We've not been able to query all expected blocks after all retries.
 	level.Warn(util_log.WithContext(ctx, logger)).Log("msg", "failed consistency check", "err", err)
-	return newStoreConsistencyCheckFailedError(remainingBlocks)
+	return newStoreConsistencyCheckFailedError(remainingBlocks.GetULIDs())
+}
+
+// filterBlocksByID returns the blocks whose ID is in the input list.
+func filterBlocksByID(blocks bucketindex.Blocks, ids []ulid.ULID) bucketindex.Blocks {
+	filtered := make(bucketindex.Blocks, 0, len(ids))
+	for _, b := range blocks {
+		for _, id := range ids {
+			if b.ID == id {
+				filtered = append(filtered, b)
+				break
+			}
+		}
+	}
+	return filtered
 }
 
 func newStoreConsistencyCheckFailedError(remainingBlocks []ulid.ULID) error {
@@ -726,6 +744,8 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 			myWarnings := storage.Warnings(nil)
 			myQueriedBlocks := []ulid.ULID(nil)
 			indexBytesFetched := uint64(0)
+			storeChunkBytesFetched := uint64(0)
+			storeChunksFetched := uint64(0)
 
 			for {
 				// Ensure the context hasn't been canceled in the meanwhile (eg. an error occurred
@@ -739,6 +759,15 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 					break
 				}
 				if err != nil {
+					// A query rejected by a per-query limit of the store-gateway would be rejected by the
+					// other store-gateways too, so it's not retried.
+					if msg, ok := storeGatewayLimitError(err); ok {
+						reqStats.AddFetchedChunkBytes(storeChunkBytesFetched)
+						reqStats.AddFetchedChunks(storeChunksFetched)
+						reqStats.AddFetchedIndexBytes(indexBytesFetched)
+						return validation.LimitError(msg)
+					}
+
 					level.Warn(spanLog).Log("msg", "failed to receive series", "remote", c.RemoteAddress(), "err", err)
 					return nil
 				}
@@ -790,12 +819,22 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 
 				if s := resp.GetStats(); s != nil {
 					indexBytesFetched += s.FetchedIndexBytes
+					storeChunkBytesFetched += s.FetchedChunkBytes
+					storeChunksFetched += s.FetchedChunks
 				}
 			}
 
 			numSeries := len(mySeries)
 			chunksFetched, chunkBytes := countChunksAndBytes(mySeries...)
 
+			// Prefer the chunk stats reported by the store-gateway, because they account for
+			// what has actually been read from the object storage. Store-gateways not reporting
+			// them (eg. during a rolling update) fall back to the size of the received chunks.
+			if storeChunksFetched > 0 || storeChunkBytesFetched > 0 {
+				chunksFetched = int(storeChunksFetched)
+				chunkBytes = int(storeChunkBytesFetched)
+			}
+
 			reqStats.AddFetchedSeries(uint64(numSeries))
 			reqStats.AddFetchedChunkBytes(uint64(chunkBytes))
 			reqStats.AddFetchedChunks(uint64(chunksFetched))
@@ -812,7 +851,7 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 
 			// Store the result.
 			mtx.Lock()
-			seriesSets = append(seriesSets, &blockQuerierSeriesSet{series: mySeries})
+			seriesSets = append(seriesSets, &blockQuerierSeriesSet{series: mySeries, mint: minT, maxt: maxT})
 			warnings = append(warnings, myWarnings...)
 			queriedBlocks = append(queriedBlocks, myQueriedBlocks...)
 			mtx.Unlock()
@@ -987,6 +1026,16 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 	return valueSets, warnings, queriedBlocks, nil
 }
 
+// storeGatewayLimitError returns the error message and true if err is a store-gateway error caused
+// by a per-query limit, like the max number of chunk bytes fetched.
+func storeGatewayLimitError(err error) (string, bool) {
+	s, ok := status.FromError(errors.Cause(err))
+	if !ok || s.Code() != http.StatusUnprocessableEntity {
+		return "", false
+	}
+	return s.Message(), true
+}
+
 func createSeriesRequest(minT, maxT int64, matchers []storepb.LabelMatcher, skipChunks bool, blockIDs []ulid.ULID) (*storepb.SeriesRequest, error) {
 	// Selectively query only specific blocks.
 	hints := &hintspb.SeriesRequestHints{
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net/http"
 	"sort"
 	"strings"
 	"testing"
@@ -29,10 +30,14 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/user"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/sharding"
 	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 	"github.com/grafana/mimir/pkg/storegateway/hintspb"
@@ -891,6 +896,152 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 	}
 }
 
+func TestBlocksStoreQuerier_Select_ShouldTrackFetchedStats(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1          = ulid.MustNew(1, nil)
+		block2          = ulid.MustNew(2, nil)
+		metricNameLabel = labels.FromStrings(labels.MetricName, metricName)
+	)
+
+	// The size of the chunks received by the querier, used when the store-gateway doesn't report chunk stats.
+	receivedChunks, receivedChunkBytes := countChunksAndBytes(
+		mockSeriesResponse(metricNameLabel, minT, 1).GetSeries(),
+		mockSeriesResponse(metricNameLabel, minT+1, 2).GetSeries(),
+	)
+
+	tests := map[string]struct {
+		statsResponse              *storepb.SeriesResponse
+		expectedFetchedChunks      uint64
+		expectedFetchedChunkBytes  uint64
+		expectedFetchedIndexBytes  uint64
+		expectedFetchedSeriesCount uint64
+	}{
+		"store-gateway reports chunk stats": {
+			statsResponse:              mockStatsResponseWithChunks(50, 2048, 3),
+			expectedFetchedChunks:      3,
+			expectedFetchedChunkBytes:  2048,
+			expectedFetchedIndexBytes:  50,
+			expectedFetchedSeriesCount: 2,
+		},
+		"store-gateway doesn't report chunk stats": {
+			statsResponse:              mockStatsResponse(50),
+			expectedFetchedChunks:      uint64(receivedChunks),
+			expectedFetchedChunkBytes:  uint64(receivedChunkBytes),
+			expectedFetchedIndexBytes:  50,
+			expectedFetchedSeriesCount: 2,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			queryStats, ctx := stats.ContextWithEmptyStats(context.Background())
+			ctx = limiter.AddQueryLimiterToContext(ctx, limiter.NewQueryLimiter(0, 0, 0))
+
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(metricNameLabel, minT, 1),
+						mockSeriesResponse(metricNameLabel, minT+1, 2),
+						mockHintsResponse(block1, block2),
+						testData.statsResponse,
+					}}: {block1, block2},
+				},
+			}}
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}, {ID: block2}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			q := &blocksStoreQuerier{
+				ctx:         ctx,
+				minT:        minT,
+				maxT:        maxT,
+				userID:      "user-1",
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+			for set.Next() {
+			}
+			require.NoError(t, set.Err())
+
+			assert.Equal(t, testData.expectedFetchedSeriesCount, queryStats.LoadFetchedSeries())
+			assert.Equal(t, testData.expectedFetchedChunks, queryStats.LoadFetchedChunks())
+			assert.Equal(t, testData.expectedFetchedChunkBytes, queryStats.LoadFetchedChunkBytes())
+			assert.Equal(t, testData.expectedFetchedIndexBytes, queryStats.LoadFetchedIndexBytes())
+		})
+	}
+}
+
+func TestBlocksStoreQuerier_Select_ShouldNotRetryStoreGatewayLimitErrors(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1          = ulid.MustNew(1, nil)
+		metricNameLabel = labels.FromStrings(labels.MetricName, metricName)
+		storeErr        = httpgrpc.Errorf(http.StatusUnprocessableEntity, "exceeded chunk bytes limit: limit 1024 violated (got 2048)")
+	)
+
+	queryStats, ctx := stats.ContextWithEmptyStats(context.Background())
+	ctx = limiter.AddQueryLimiterToContext(ctx, limiter.NewQueryLimiter(0, 0, 0))
+
+	// The second store-gateway would return the series, but it's not queried because the limit
+	// would be exceeded there too.
+	stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+		map[BlocksStoreClient][]ulid.ULID{
+			&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+				mockSeriesResponse(metricNameLabel, minT, 1),
+				mockStatsResponseWithChunks(50, 2048, 3),
+			}, mockedSeriesStreamErr: status.Error(codes.Code(http.StatusUnprocessableEntity), errors.Wrap(storeErr, "fetch series for block").Error())}: {block1},
+		},
+		map[BlocksStoreClient][]ulid.ULID{
+			&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{
+				mockSeriesResponse(metricNameLabel, minT, 1),
+				mockHintsResponse(block1),
+			}}: {block1},
+		},
+	}}
+	finder := &blocksFinderMock{}
+	finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block1}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+	q := &blocksStoreQuerier{
+		ctx:         ctx,
+		minT:        minT,
+		maxT:        maxT,
+		userID:      "user-1",
+		finder:      finder,
+		stores:      stores,
+		consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+		logger:      log.NewNopLogger(),
+		metrics:     newBlocksStoreQueryableMetrics(nil),
+		limits:      &blocksStoreLimitsMock{},
+	}
+
+	set := q.Select(true, &storage.SelectHints{Start: minT, End: maxT}, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+	require.False(t, set.Next())
+	var limitErr validation.LimitError
+	require.ErrorAs(t, set.Err(), &limitErr)
+	assert.Contains(t, set.Err().Error(), "exceeded chunk bytes limit")
+
+	// The data fetched before the limit was exceeded is tracked.
+	assert.Equal(t, uint64(3), queryStats.LoadFetchedChunks())
+	assert.Equal(t, uint64(2048), queryStats.LoadFetchedChunkBytes())
+	assert.Equal(t, uint64(50), queryStats.LoadFetchedIndexBytes())
+}
+
 func TestBlocksStoreQuerier_Labels(t *testing.T) {
 	const (
 		metricName = "test_metric"
@@ -1803,7 +1954,7 @@ type blocksStoreSetMock struct {
 	nextResult      int
 }
 
-func (m *blocksStoreSetMock) GetClientsFor(_ string, _ []ulid.ULID, _ map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
+func (m *blocksStoreSetMock) GetClientsFor(_ string, _ bucketindex.Blocks, _ map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
 	if m.nextResult >= len(m.mockedResponses) {
 		panic("not enough mocked results")
 	}
@@ -1835,6 +1986,7 @@ type storeGatewayClientMock struct {
 	remoteAddr                string
 	mockedSeriesResponses     []*storepb.SeriesResponse
 	mockedSeriesErr           error
+	mockedSeriesStreamErr     error
 	mockedLabelNamesResponse  *storepb.LabelNamesResponse
 	mockedLabelNamesErr       error
 	mockedLabelValuesResponse *storepb.LabelValuesResponse
@@ -1844,6 +1996,7 @@ type storeGatewayClientMock struct {
 func (m *storeGatewayClientMock) Series(ctx context.Context, in *storepb.SeriesRequest, opts ...grpc.CallOption) (storegatewaypb.StoreGateway_SeriesClient, error) {
 	seriesClient := &storeGatewaySeriesClientMock{
 		mockedResponses: m.mockedSeriesResponses,
+		mockedErr:       m.mockedSeriesStreamErr,
 	}
 
 	return seriesClient, m.mockedSeriesErr
@@ -1865,6 +2018,9 @@ type storeGatewaySeriesClientMock struct {
 	grpc.ClientStream
 
 	mockedResponses []*storepb.SeriesResponse
+
+	// Error returned once all the responses have been received, instead of io.EOF.
+	mockedErr error
 }
 
 func (m *storeGatewaySeriesClientMock) Recv() (*storepb.SeriesResponse, error) {
@@ -1872,6 +2028,9 @@ func (m *storeGatewaySeriesClientMock) Recv() (*storepb.SeriesResponse, error) {
 	time.Sleep(10 * time.Millisecond)
 
 	if len(m.mockedResponses) == 0 {
+		if m.mockedErr != nil {
+			return nil, m.mockedErr
+		}
 		return nil, io.EOF
 	}
 
@@ -1881,9 +2040,11 @@ func (m *storeGatewaySeriesClientMock) Recv() (*storepb.SeriesResponse, error) {
 }
 
 type blocksStoreLimitsMock struct {
-	maxLabelsQueryLength        time.Duration
-	maxChunksPerQuery           int
-	storeGatewayTenantShardSize int
+	maxLabelsQueryLength                      time.Duration
+	maxChunksPerQuery                         int
+	storeGatewayTenantShardSize               int
+	storeGatewayRecentBlocksPeriod            time.Duration
+	storeGatewayRecentBlocksReplicationFactor int
 }
 
 func (m *blocksStoreLimitsMock) MaxLabelsQueryLength(_ string) time.Duration {
@@ -1894,6 +2055,14 @@ func (m *blocksStoreLimitsMock) MaxChunksPerQuery(_ string) int {
 	return m.maxChunksPerQuery
 }
 
+func (m *blocksStoreLimitsMock) StoreGatewayRecentBlocksPeriod(_ string) time.Duration {
+	return m.storeGatewayRecentBlocksPeriod
+}
+
+func (m *blocksStoreLimitsMock) StoreGatewayRecentBlocksReplicationFactor(_ string) int {
+	return m.storeGatewayRecentBlocksReplicationFactor
+}
+
 func (m *blocksStoreLimitsMock) StoreGatewayTenantShardSize(_ string) int {
 	return m.storeGatewayTenantShardSize
 }
@@ -1937,6 +2106,10 @@ func mockStatsResponse(fetchedIndexBytes int) *storepb.SeriesResponse {
 	}
 }
 
+func mockStatsResponseWithChunks(fetchedIndexBytes, fetchedChunkBytes, fetchedChunks int) *storepb.SeriesResponse {
+	return storepb.NewStatsResponse(fetchedIndexBytes, fetchedChunkBytes, fetchedChunks)
+}
+
 func mockHintsResponse(ids ...ulid.ULID) *storepb.SeriesResponse {
 	hints := &hintspb.SeriesResponseHints{}
 	for _, id := range ids {
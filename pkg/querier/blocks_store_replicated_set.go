@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/ring"
@@ -18,7 +19,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 
-	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 	"github.com/grafana/mimir/pkg/storegateway"
 	"github.com/grafana/mimir/pkg/util"
 )
@@ -97,29 +98,30 @@ func (s *blocksStoreReplicationSet) stopping(_ error) error {
 	return services.StopManagerAndAwaitStopped(context.Background(), s.subservices)
 }
 
-func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blockIDs []ulid.ULID, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
+func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blocks bucketindex.Blocks, exclude map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
 	shards := map[string][]ulid.ULID{}
 
 	userRing := storegateway.GetShuffleShardingSubring(s.storesRing, userID, s.limits)
+	now := time.Now()
 
 	// Find the replication set of each block we need to query.
-	for _, blockID := range blockIDs {
+	for _, block := range blocks {
 		// Do not reuse the same buffer across multiple Get() calls because we do retain the
 		// returned replication set.
 		bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
 
-		set, err := userRing.Get(mimir_tsdb.HashBlockID(blockID), storegateway.BlocksRead, bufDescs, bufHosts, bufZones)
+		set, err := storegateway.GetBlockReplicationSet(userRing, userID, block.ID, block.MaxTime, now, storegateway.BlocksRead, s.limits, bufDescs, bufHosts, bufZones)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get store-gateway replication set owning the block %s", blockID.String())
+			return nil, errors.Wrapf(err, "failed to get store-gateway replication set owning the block %s", block.ID.String())
 		}
 
 		// Pick a non excluded store-gateway instance.
-		addr := getNonExcludedInstanceAddr(set, exclude[blockID], s.balancingStrategy)
+		addr := getNonExcludedInstanceAddr(set, exclude[block.ID], s.balancingStrategy)
 		if addr == "" {
-			return nil, fmt.Errorf("no store-gateway instance left after checking exclude for block %s", blockID.String())
+			return nil, fmt.Errorf("no store-gateway instance left after checking exclude for block %s", block.ID.String())
 		}
 
-		shards[addr] = append(shards[addr], blockID)
+		shards[addr] = append(shards[addr], block.ID)
 	}
 
 	clients := map[BlocksStoreClient][]ulid.ULID{}
@@ -25,6 +25,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 )
 
 func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
@@ -349,7 +350,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
 				return err == nil && len(all.Instances) > 0
 			})
 
-			clients, err := s.GetClientsFor(userID, testData.queryBlocks, testData.exclude)
+			clients, err := s.GetClientsFor(userID, blocksFromIDs(testData.queryBlocks...), testData.exclude)
 			assert.Equal(t, testData.expectedErr, err)
 
 			if testData.expectedErr == nil {
@@ -414,7 +415,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRandomLoadBalancin
 	distribution := map[string]int{}
 
 	for n := 0; n < numRuns; n++ {
-		clients, err := s.GetClientsFor(userID, []ulid.ULID{block1}, nil)
+		clients, err := s.GetClientsFor(userID, blocksFromIDs(block1), nil)
 		require.NoError(t, err)
 		require.Len(t, clients, 1)
 
@@ -431,6 +432,64 @@ func TestBlocksStoreReplicationSet_GetClientsFor_ShouldSupportRandomLoadBalancin
 	}
 }
 
+func TestBlocksStoreReplicationSet_GetClientsFor_ShouldQueryRecentBlocksFromAllTheirOwners(t *testing.T) {
+	const (
+		numRuns      = 1000
+		numInstances = 3
+	)
+
+	ctx := context.Background()
+	userID := "user-A"
+	registeredAt := time.Now()
+	recentBlock := &bucketindex.Block{ID: ulid.MustNew(1, nil), MaxTime: time.Now().Add(-time.Hour).UnixMilli()}
+	oldBlock := &bucketindex.Block{ID: ulid.MustNew(2, nil), MaxTime: time.Now().Add(-72 * time.Hour).UnixMilli()}
+
+	ringStore, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	require.NoError(t, ringStore.CAS(ctx, "test", func(in interface{}) (interface{}, bool, error) {
+		d := ring.NewDesc()
+		for n := 1; n <= numInstances; n++ {
+			d.AddIngester(fmt.Sprintf("instance-%d", n), fmt.Sprintf("127.0.0.%d", n), "", []uint32{uint32(n)}, ring.ACTIVE, registeredAt)
+		}
+		return d, true, nil
+	}))
+
+	// Configure a replication factor of 1, so that only the recent blocks are replicated.
+	ringCfg := ring.Config{}
+	flagext.DefaultValues(&ringCfg)
+	ringCfg.ReplicationFactor = 1
+
+	r, err := ring.NewWithStoreClientAndStrategy(ringCfg, "test", "test", ringStore, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), nil, log.NewNopLogger())
+	require.NoError(t, err)
+
+	limits := &blocksStoreLimitsMock{storeGatewayRecentBlocksPeriod: 48 * time.Hour, storeGatewayRecentBlocksReplicationFactor: numInstances}
+	s, err := newBlocksStoreReplicationSet(r, randomLoadBalancing, limits, ClientConfig{}, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+	defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
+
+	// Wait until the ring client has initialised the state.
+	test.Poll(t, time.Second, true, func() interface{} {
+		all, err := r.GetAllHealthy(ring.Read)
+		return err == nil && len(all.Instances) > 0
+	})
+
+	for block, expectedInstances := range map[*bucketindex.Block]int{recentBlock: numInstances, oldBlock: 1} {
+		distribution := map[string]int{}
+		for n := 0; n < numRuns; n++ {
+			clients, err := s.GetClientsFor(userID, bucketindex.Blocks{block}, nil)
+			require.NoError(t, err)
+			require.Len(t, clients, 1)
+
+			for addr := range getStoreGatewayClientAddrs(clients) {
+				distribution[addr]++
+			}
+		}
+		assert.Len(t, distribution, expectedInstances, "block max time: %d", block.MaxTime)
+	}
+}
+
 func getStoreGatewayClientAddrs(clients map[BlocksStoreClient][]ulid.ULID) map[string][]ulid.ULID {
 	addrs := map[string][]ulid.ULID{}
 	for c, blockIDs := range clients {
@@ -438,3 +497,11 @@ func getStoreGatewayClientAddrs(clients map[BlocksStoreClient][]ulid.ULID) map[s
 	}
 	return addrs
 }
+
+func blocksFromIDs(ids ...ulid.ULID) bucketindex.Blocks {
+	blocks := make(bucketindex.Blocks, 0, len(ids))
+	for _, id := range ids {
+		blocks = append(blocks, &bucketindex.Block{ID: id})
+	}
+	return blocks
+}
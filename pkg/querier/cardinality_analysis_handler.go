@@ -3,6 +3,7 @@
 package querier
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sort"
@@ -28,19 +29,22 @@ const (
 	defaultLimit = 20
 )
 
+// CardinalityAnalyzer is the cardinality analysis specific part of the Distributor interface. It
+// exists to allow us to wrap the default implementation (the distributor embedded in a querier)
+// with logic for handling tenant federated cardinality requests.
+type CardinalityAnalyzer interface {
+	LabelNamesAndValues(ctx context.Context, matchers []*labels.Matcher) (*ingester_client.LabelNamesAndValuesResponse, error)
+	LabelValuesCardinality(ctx context.Context, labelNames []model.LabelName, matchers []*labels.Matcher) (uint64, *ingester_client.LabelValuesCardinalityResponse, error)
+}
+
 // LabelNamesCardinalityHandler creates handler for label names cardinality endpoint.
-func LabelNamesCardinalityHandler(d Distributor, limits *validation.Overrides) http.Handler {
+func LabelNamesCardinalityHandler(d CardinalityAnalyzer, limits *validation.Overrides) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		tenantID, err := tenant.TenantID(ctx)
-		if err != nil {
+		if err := checkCardinalityAnalysisEnabled(ctx, limits); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if !limits.CardinalityAnalysisEnabled(tenantID) {
-			http.Error(w, fmt.Sprintf("cardinality analysis is disabled for the tenant: %v", tenantID), http.StatusBadRequest)
-			return
-		}
 		matchers, limit, err := extractLabelNamesRequestParams(r)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -57,19 +61,13 @@ func LabelNamesCardinalityHandler(d Distributor, limits *validation.Overrides) h
 }
 
 // LabelValuesCardinalityHandler creates handler for label values cardinality endpoint.
-func LabelValuesCardinalityHandler(distributor Distributor, limits *validation.Overrides) http.Handler {
+func LabelValuesCardinalityHandler(distributor CardinalityAnalyzer, limits *validation.Overrides) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
-		// Guarantee request's context is for a single tenant id
-		tenantID, err := tenant.TenantID(ctx)
-		if err != nil {
+		if err := checkCardinalityAnalysisEnabled(ctx, limits); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if !limits.CardinalityAnalysisEnabled(tenantID) {
-			http.Error(w, fmt.Sprintf("cardinality analysis is disabled for the tenant: %v", tenantID), http.StatusBadRequest)
-			return
-		}
 
 		labelNames, matchers, limit, err := extractLabelValuesRequestParams(r)
 		if err != nil {
@@ -87,6 +85,21 @@ func LabelValuesCardinalityHandler(distributor Distributor, limits *validation.O
 	})
 }
 
+// checkCardinalityAnalysisEnabled returns an error if the cardinality analysis is not enabled for
+// all the tenants of the request.
+func checkCardinalityAnalysisEnabled(ctx context.Context, limits *validation.Overrides) error {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, tenantID := range tenantIDs {
+		if !limits.CardinalityAnalysisEnabled(tenantID) {
+			return fmt.Errorf("cardinality analysis is disabled for the tenant: %v", tenantID)
+		}
+	}
+	return nil
+}
+
 func extractLabelNamesRequestParams(r *http.Request) ([]*labels.Matcher, int, error) {
 	err := r.ParseForm()
 	if err != nil {
@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/tenant"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/mock"
@@ -650,6 +651,64 @@ func TestLabelValuesCardinalityHandler_FeatureFlag(t *testing.T) {
 	}
 }
 
+func TestLabelValuesCardinalityHandler_FeatureFlagMultipleTenants(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	t.Cleanup(func() { tenant.WithDefaultResolver(tenant.NewSingleResolver()) })
+
+	const labelValuesURL = "/label_values?label_names[]=foo"
+
+	tests := map[string]struct {
+		tenantLimits         map[string]*validation.Limits
+		expectedStatusCode   int
+		expectedErrorMessage string
+	}{
+		"should return an error if the cardinality analysis feature is disabled for any of the tenants": {
+			tenantLimits: map[string]*validation.Limits{
+				"team-a": {CardinalityAnalysisEnabled: true},
+				"team-b": {CardinalityAnalysisEnabled: false},
+			},
+			expectedStatusCode:   http.StatusBadRequest,
+			expectedErrorMessage: "cardinality analysis is disabled for the tenant: team-b\n",
+		},
+		"should succeed if the cardinality analysis feature is enabled for all the tenants": {
+			tenantLimits: map[string]*validation.Limits{
+				"team-a": {CardinalityAnalysisEnabled: true},
+				"team-b": {CardinalityAnalysisEnabled: true},
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			distributor := mockDistributorLabelValuesCardinality(
+				[]model.LabelName{"foo"},
+				[]*labels.Matcher(nil),
+				uint64(0),
+				&client.LabelValuesCardinalityResponse{Items: []*client.LabelValueSeriesCount{}},
+				nil)
+
+			overrides, err := validation.NewOverrides(validation.Limits{}, validation.NewMockTenantLimits(testData.tenantLimits))
+			require.NoError(t, err)
+			handler := LabelValuesCardinalityHandler(distributor, overrides)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, createRequest(labelValuesURL, "team-a|team-b"))
+
+			require.Equal(t, testData.expectedStatusCode, recorder.Result().StatusCode)
+
+			if len(testData.expectedErrorMessage) > 0 {
+				body := recorder.Result().Body
+				defer func() { _ = body.Close() }()
+
+				bodyContent, err := io.ReadAll(body)
+				require.NoError(t, err)
+				require.Equal(t, testData.expectedErrorMessage, string(bodyContent))
+			}
+		})
+	}
+}
+
 func TestLabelValuesCardinalityHandler_ParseError(t *testing.T) {
 	distributor := mockDistributorLabelValuesCardinality(
 		[]model.LabelName{},
@@ -773,7 +832,7 @@ func TestLabelValuesCardinalityHandler_DistributorError(t *testing.T) {
 }
 
 // createEnabledHandler creates a cardinalityHandler that can be either a LabelNamesCardinalityHandler or a LabelValuesCardinalityHandler
-func createEnabledHandler(t *testing.T, cardinalityHandler func(Distributor, *validation.Overrides) http.Handler, distributor *mockDistributor) http.Handler {
+func createEnabledHandler(t *testing.T, cardinalityHandler func(CardinalityAnalyzer, *validation.Overrides) http.Handler, distributor *mockDistributor) http.Handler {
 	limits := validation.Limits{CardinalityAnalysisEnabled: true}
 	overrides, err := validation.NewOverrides(limits, nil)
 	require.NoError(t, err)
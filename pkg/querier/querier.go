@@ -27,6 +27,7 @@ import (
 	"github.com/grafana/mimir/pkg/querier/batch"
 	"github.com/grafana/mimir/pkg/querier/engine"
 	"github.com/grafana/mimir/pkg/querier/iterators"
+	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/chunk"
 	"github.com/grafana/mimir/pkg/storage/lazyquery"
 	"github.com/grafana/mimir/pkg/util"
@@ -275,8 +276,11 @@ func (q querier) Select(_ bool, sp *storage.SelectHints, matchers ...*labels.Mat
 		return storage.ErrSeriesSet(validation.NewMaxQueryLengthError(endTime.Sub(startTime), maxQueryLength))
 	}
 
+	// Track the number of samples iterated to execute the query (no-op if stats are disabled).
+	queryStats := stats.FromContext(ctx)
+
 	if len(q.queriers) == 1 {
-		return q.queriers[0].Select(true, sp, matchers...)
+		return newSamplesProcessedSeriesSet(q.queriers[0].Select(true, sp, matchers...), queryStats)
 	}
 
 	sets := make(chan storage.SeriesSet, len(q.queriers))
@@ -299,7 +303,7 @@ func (q querier) Select(_ bool, sp *storage.SelectHints, matchers ...*labels.Mat
 	// we have all the sets from different sources (chunk from store, chunks from ingesters,
 	// time series from store and time series from ingesters).
 	// mergeSeriesSets will return sorted set.
-	return q.mergeSeriesSets(result)
+	return newSamplesProcessedSeriesSet(q.mergeSeriesSets(result), queryStats)
 }
 
 // LabelValues implements storage.Querier.
@@ -155,7 +155,26 @@ func (s *Stats) LoadSplitQueries() uint32 {
 	return atomic.LoadUint32(&s.SplitQueries)
 }
 
-// Merge the provided Stats into this one.
+// AddSamplesProcessed adds some samples processed to the counter.
+func (s *Stats) AddSamplesProcessed(samples uint64) {
+	if s == nil {
+		return
+	}
+
+	atomic.AddUint64(&s.SamplesProcessed, samples)
+}
+
+// LoadSamplesProcessed returns the current number of samples processed.
+func (s *Stats) LoadSamplesProcessed() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&s.SamplesProcessed)
+}
+
+// Merge the provided Stats into this one. Each counter is added atomically, so Merge can be
+// called concurrently. It's a no-op if either the receiver or the provided Stats is nil.
 func (s *Stats) Merge(other *Stats) {
 	if s == nil || other == nil {
 		return
@@ -168,6 +187,7 @@ func (s *Stats) Merge(other *Stats) {
 	s.AddShardedQueries(other.LoadShardedQueries())
 	s.AddSplitQueries(other.LoadSplitQueries())
 	s.AddFetchedIndexBytes(other.LoadFetchedIndexBytes())
+	s.AddSamplesProcessed(other.LoadSamplesProcessed())
 }
 
 func ShouldTrackHTTPGRPCResponse(r *httpgrpc.HTTPResponse) bool {
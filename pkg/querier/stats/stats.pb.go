@@ -44,6 +44,8 @@ type Stats struct {
 	SplitQueries uint32 `protobuf:"varint,6,opt,name=split_queries,json=splitQueries,proto3" json:"split_queries,omitempty"`
 	// The number of index bytes fetched on the store-gateway for the query
 	FetchedIndexBytes uint64 `protobuf:"varint,7,opt,name=fetched_index_bytes,json=fetchedIndexBytes,proto3" json:"fetched_index_bytes,omitempty"`
+	// The number of samples processed by the querier to execute the query
+	SamplesProcessed uint64 `protobuf:"varint,8,opt,name=samples_processed,json=samplesProcessed,proto3" json:"samples_processed,omitempty"`
 }
 
 func (m *Stats) Reset()      { *m = Stats{} }
@@ -127,6 +129,13 @@ func (m *Stats) GetFetchedIndexBytes() uint64 {
 	return 0
 }
 
+func (m *Stats) GetSamplesProcessed() uint64 {
+	if m != nil {
+		return m.SamplesProcessed
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*Stats)(nil), "stats.Stats")
 }
@@ -134,30 +143,31 @@ func init() {
 func init() { proto.RegisterFile("stats.proto", fileDescriptor_b4756a0aec8b9d44) }
 
 var fileDescriptor_b4756a0aec8b9d44 = []byte{
-	// 357 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x91, 0xbf, 0x52, 0xe2, 0x50,
-	0x14, 0xc6, 0x73, 0x59, 0x60, 0xd9, 0xb0, 0xec, 0x8e, 0xd1, 0x22, 0x52, 0x1c, 0x18, 0x2d, 0xa4,
-	0x31, 0x38, 0x5a, 0xda, 0x38, 0x60, 0x63, 0x29, 0x58, 0xd9, 0x64, 0xf2, 0xe7, 0x92, 0x64, 0x0c,
-	0xb9, 0x98, 0x7b, 0x33, 0x6a, 0xe7, 0x23, 0x58, 0xfa, 0x08, 0x3e, 0x0a, 0x25, 0x25, 0x33, 0xce,
-	0xa8, 0x5c, 0x1a, 0x4b, 0x1e, 0xc1, 0xc9, 0x49, 0xa2, 0xd0, 0xe5, 0x9c, 0xdf, 0xf7, 0xcb, 0x77,
-	0x26, 0x51, 0xeb, 0x5c, 0x58, 0x82, 0x1b, 0x93, 0x98, 0x09, 0xa6, 0x55, 0x70, 0x68, 0x1e, 0x7a,
-	0x81, 0xf0, 0x13, 0xdb, 0x70, 0xd8, 0xb8, 0xeb, 0x31, 0x8f, 0x75, 0x91, 0xda, 0xc9, 0x08, 0x27,
-	0x1c, 0xf0, 0x29, 0xb3, 0x9a, 0xe0, 0x31, 0xe6, 0x85, 0xf4, 0x27, 0xe5, 0x26, 0xb1, 0x25, 0x02,
-	0x16, 0x65, 0x7c, 0xef, 0xb5, 0xa4, 0x56, 0x86, 0xe9, 0x8b, 0xb5, 0x33, 0xf5, 0xcf, 0x9d, 0x15,
-	0x86, 0xa6, 0x08, 0xc6, 0x54, 0x27, 0x6d, 0xd2, 0xa9, 0x1f, 0xef, 0x1a, 0x99, 0x6d, 0x14, 0xb6,
-	0x71, 0x9e, 0xdb, 0xbd, 0xda, 0xf4, 0xad, 0xa5, 0x3c, 0xbf, 0xb7, 0xc8, 0xa0, 0x96, 0x5a, 0x57,
-	0xc1, 0x98, 0x6a, 0x47, 0xea, 0xce, 0x88, 0x0a, 0xc7, 0xa7, 0xae, 0xc9, 0x69, 0x1c, 0x50, 0x6e,
-	0x3a, 0x2c, 0x89, 0x84, 0x5e, 0x6a, 0x93, 0x4e, 0x79, 0xa0, 0xe5, 0x6c, 0x88, 0xa8, 0x9f, 0x12,
-	0xcd, 0x50, 0xb7, 0x0b, 0xc3, 0xf1, 0x93, 0xe8, 0xc6, 0xb4, 0x1f, 0x04, 0xe5, 0xfa, 0x2f, 0x14,
-	0xb6, 0x72, 0xd4, 0x4f, 0x49, 0x2f, 0x05, 0xeb, 0x0d, 0x98, 0x2f, 0x1a, 0xca, 0x1b, 0x0d, 0x28,
-	0xe4, 0x0d, 0x07, 0xea, 0x7f, 0xee, 0x5b, 0xb1, 0x4b, 0x5d, 0xf3, 0x36, 0xc1, 0x66, 0xbd, 0xd2,
-	0x26, 0x9d, 0xc6, 0xe0, 0x5f, 0xbe, 0xbe, 0xcc, 0xb6, 0xda, 0xbe, 0xda, 0xe0, 0x93, 0x30, 0x10,
-	0xdf, 0xb1, 0x2a, 0xc6, 0xfe, 0xe2, 0xb2, 0x08, 0xad, 0xdd, 0x1b, 0x44, 0x2e, 0xbd, 0xcf, 0xef,
-	0xfd, 0xbd, 0x71, 0xef, 0x45, 0x4a, 0xf0, 0xde, 0xde, 0xe9, 0x6c, 0x01, 0xca, 0x7c, 0x01, 0xca,
-	0x6a, 0x01, 0xe4, 0x51, 0x02, 0x79, 0x91, 0x40, 0xa6, 0x12, 0xc8, 0x4c, 0x02, 0xf9, 0x90, 0x40,
-	0x3e, 0x25, 0x28, 0x2b, 0x09, 0xe4, 0x69, 0x09, 0xca, 0x6c, 0x09, 0xca, 0x7c, 0x09, 0xca, 0x75,
-	0xf6, 0xa7, 0xed, 0x2a, 0x7e, 0xf5, 0x93, 0xaf, 0x00, 0x00, 0x00, 0xff, 0xff, 0xc0, 0xfd, 0xec,
-	0x90, 0x06, 0x02, 0x00, 0x00,
+	// 378 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x54, 0x92, 0x3d, 0x53, 0xab, 0x40,
+	0x14, 0x86, 0xd9, 0x9b, 0x8f, 0x9b, 0xbb, 0xb9, 0xb9, 0xd7, 0xa0, 0x05, 0xa6, 0xd8, 0x64, 0xb4,
+	0x30, 0x33, 0x8e, 0xc4, 0xd1, 0xd2, 0xc6, 0x49, 0x6c, 0xec, 0x34, 0xb1, 0xb2, 0x61, 0xf8, 0xd8,
+	0x00, 0x23, 0xb0, 0xc8, 0x2e, 0xa3, 0x76, 0x96, 0x96, 0x96, 0xfe, 0x04, 0x7f, 0x4a, 0xca, 0x94,
+	0xa9, 0xd4, 0x90, 0xc6, 0x32, 0x3f, 0xc1, 0xe1, 0x00, 0x9a, 0x74, 0x9c, 0xf3, 0xbc, 0x0f, 0xef,
+	0x99, 0x01, 0x5c, 0xe7, 0x42, 0x17, 0x5c, 0x0d, 0x23, 0x26, 0x98, 0x5c, 0x81, 0xa1, 0x75, 0x60,
+	0xbb, 0xc2, 0x89, 0x0d, 0xd5, 0x64, 0x7e, 0xcf, 0x66, 0x36, 0xeb, 0x01, 0x35, 0xe2, 0x31, 0x4c,
+	0x30, 0xc0, 0x53, 0x66, 0xb5, 0x88, 0xcd, 0x98, 0xed, 0xd1, 0x9f, 0x94, 0x15, 0x47, 0xba, 0x70,
+	0x59, 0x90, 0xf1, 0x9d, 0xa7, 0x12, 0xae, 0x8c, 0xd2, 0x17, 0xcb, 0xa7, 0xf8, 0xcf, 0x9d, 0xee,
+	0x79, 0x9a, 0x70, 0x7d, 0xaa, 0xa0, 0x0e, 0xea, 0xd6, 0x8f, 0xb6, 0xd5, 0xcc, 0x56, 0x0b, 0x5b,
+	0x3d, 0xcb, 0xed, 0x7e, 0x6d, 0xf2, 0xd6, 0x96, 0x5e, 0xde, 0xdb, 0x68, 0x58, 0x4b, 0xad, 0x2b,
+	0xd7, 0xa7, 0xf2, 0x21, 0xde, 0x1a, 0x53, 0x61, 0x3a, 0xd4, 0xd2, 0x38, 0x8d, 0x5c, 0xca, 0x35,
+	0x93, 0xc5, 0x81, 0x50, 0x7e, 0x75, 0x50, 0xb7, 0x3c, 0x94, 0x73, 0x36, 0x02, 0x34, 0x48, 0x89,
+	0xac, 0xe2, 0xcd, 0xc2, 0x30, 0x9d, 0x38, 0xb8, 0xd1, 0x8c, 0x07, 0x41, 0xb9, 0x52, 0x02, 0xa1,
+	0x99, 0xa3, 0x41, 0x4a, 0xfa, 0x29, 0x58, 0x6d, 0x80, 0x7c, 0xd1, 0x50, 0x5e, 0x6b, 0x00, 0x21,
+	0x6f, 0xd8, 0xc3, 0xff, 0xb9, 0xa3, 0x47, 0x16, 0xb5, 0xb4, 0xdb, 0x18, 0x9a, 0x95, 0x4a, 0x07,
+	0x75, 0x1b, 0xc3, 0x7f, 0xf9, 0xfa, 0x32, 0xdb, 0xca, 0xbb, 0xb8, 0xc1, 0x43, 0xcf, 0x15, 0xdf,
+	0xb1, 0x2a, 0xc4, 0xfe, 0xc2, 0xb2, 0x08, 0xad, 0xdc, 0xeb, 0x06, 0x16, 0xbd, 0xcf, 0xef, 0xfd,
+	0xbd, 0x76, 0xef, 0x79, 0x4a, 0xb2, 0x7b, 0xf7, 0x71, 0x93, 0xeb, 0x7e, 0xe8, 0x51, 0xae, 0x85,
+	0x11, 0x33, 0x29, 0xe7, 0xd4, 0x52, 0x6a, 0x90, 0xde, 0xc8, 0xc1, 0x45, 0xb1, 0xef, 0x9f, 0x4c,
+	0xe7, 0x44, 0x9a, 0xcd, 0x89, 0xb4, 0x9c, 0x13, 0xf4, 0x98, 0x10, 0xf4, 0x9a, 0x10, 0x34, 0x49,
+	0x08, 0x9a, 0x26, 0x04, 0x7d, 0x24, 0x04, 0x7d, 0x26, 0x44, 0x5a, 0x26, 0x04, 0x3d, 0x2f, 0x88,
+	0x34, 0x5d, 0x10, 0x69, 0xb6, 0x20, 0xd2, 0x75, 0xf6, 0x5b, 0x18, 0x55, 0xf8, 0x44, 0xc7, 0x5f,
+	0x03, 0x00, 0xb9, 0xbd, 0xdc, 0xa9, 0x33, 0x02, 0x00, 0x00,
 }
 
 func (this *Stats) Equal(that interface{}) bool {
@@ -200,13 +210,16 @@ func (this *Stats) Equal(that interface{}) bool {
 	if this.FetchedIndexBytes != that1.FetchedIndexBytes {
 		return false
 	}
+	if this.SamplesProcessed != that1.SamplesProcessed {
+		return false
+	}
 	return true
 }
 func (this *Stats) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 11)
+	s := make([]string, 0, 12)
 	s = append(s, "&stats.Stats{")
 	s = append(s, "WallTime: "+fmt.Sprintf("%#v", this.WallTime)+",\n")
 	s = append(s, "FetchedSeriesCount: "+fmt.Sprintf("%#v", this.FetchedSeriesCount)+",\n")
@@ -215,6 +228,7 @@ func (this *Stats) GoString() string {
 	s = append(s, "ShardedQueries: "+fmt.Sprintf("%#v", this.ShardedQueries)+",\n")
 	s = append(s, "SplitQueries: "+fmt.Sprintf("%#v", this.SplitQueries)+",\n")
 	s = append(s, "FetchedIndexBytes: "+fmt.Sprintf("%#v", this.FetchedIndexBytes)+",\n")
+	s = append(s, "SamplesProcessed: "+fmt.Sprintf("%#v", this.SamplesProcessed)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -246,6 +260,11 @@ func (m *Stats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.SamplesProcessed != 0 {
+		i = encodeVarintStats(dAtA, i, uint64(m.SamplesProcessed))
+		i--
+		dAtA[i] = 0x40
+	}
 	if m.FetchedIndexBytes != 0 {
 		i = encodeVarintStats(dAtA, i, uint64(m.FetchedIndexBytes))
 		i--
@@ -324,6 +343,9 @@ func (m *Stats) Size() (n int) {
 	if m.FetchedIndexBytes != 0 {
 		n += 1 + sovStats(uint64(m.FetchedIndexBytes))
 	}
+	if m.SamplesProcessed != 0 {
+		n += 1 + sovStats(uint64(m.SamplesProcessed))
+	}
 	return n
 }
 
@@ -345,6 +367,7 @@ func (this *Stats) String() string {
 		`ShardedQueries:` + fmt.Sprintf("%v", this.ShardedQueries) + `,`,
 		`SplitQueries:` + fmt.Sprintf("%v", this.SplitQueries) + `,`,
 		`FetchedIndexBytes:` + fmt.Sprintf("%v", this.FetchedIndexBytes) + `,`,
+		`SamplesProcessed:` + fmt.Sprintf("%v", this.SamplesProcessed) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -533,6 +556,25 @@ func (m *Stats) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 8:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SamplesProcessed", wireType)
+			}
+			m.SamplesProcessed = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowStats
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SamplesProcessed |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipStats(dAtA[iNdEx:])
@@ -7,6 +7,7 @@ package stats
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -115,6 +116,23 @@ func TestStats_AddSplitQueries(t *testing.T) {
 	})
 }
 
+func TestStats_AddSamplesProcessed(t *testing.T) {
+	t.Run("add and load samples processed", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddSamplesProcessed(100)
+		stats.AddSamplesProcessed(20)
+
+		assert.Equal(t, uint64(120), stats.LoadSamplesProcessed())
+	})
+
+	t.Run("add and load samples processed nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddSamplesProcessed(10)
+
+		assert.Equal(t, uint64(0), stats.LoadSamplesProcessed())
+	})
+}
+
 func TestStats_Merge(t *testing.T) {
 	t.Run("merge two stats objects", func(t *testing.T) {
 		stats1 := &Stats{}
@@ -124,6 +142,8 @@ func TestStats_Merge(t *testing.T) {
 		stats1.AddFetchedChunks(10)
 		stats1.AddShardedQueries(20)
 		stats1.AddSplitQueries(10)
+		stats1.AddFetchedIndexBytes(1024)
+		stats1.AddSamplesProcessed(100)
 
 		stats2 := &Stats{}
 		stats2.AddWallTime(time.Second)
@@ -132,6 +152,8 @@ func TestStats_Merge(t *testing.T) {
 		stats2.AddFetchedChunks(11)
 		stats2.AddShardedQueries(21)
 		stats2.AddSplitQueries(11)
+		stats2.AddFetchedIndexBytes(2048)
+		stats2.AddSamplesProcessed(200)
 
 		stats1.Merge(stats2)
 
@@ -141,6 +163,52 @@ func TestStats_Merge(t *testing.T) {
 		assert.Equal(t, uint64(21), stats1.LoadFetchedChunks())
 		assert.Equal(t, uint32(41), stats1.LoadShardedQueries())
 		assert.Equal(t, uint32(21), stats1.LoadSplitQueries())
+		assert.Equal(t, uint64(3072), stats1.LoadFetchedIndexBytes())
+		assert.Equal(t, uint64(300), stats1.LoadSamplesProcessed())
+	})
+
+	t.Run("merge a nil stats object into a non-nil one", func(t *testing.T) {
+		stats1 := &Stats{}
+		stats1.AddFetchedSeries(50)
+		stats1.AddFetchedIndexBytes(1024)
+
+		stats1.Merge(nil)
+
+		assert.Equal(t, uint64(50), stats1.LoadFetchedSeries())
+		assert.Equal(t, uint64(1024), stats1.LoadFetchedIndexBytes())
+	})
+
+	t.Run("merge a non-nil stats object into a nil one", func(t *testing.T) {
+		var stats1 *Stats
+		stats2 := &Stats{}
+		stats2.AddFetchedSeries(50)
+
+		stats1.Merge(stats2)
+
+		assert.Equal(t, uint64(0), stats1.LoadFetchedSeries())
+		assert.Equal(t, uint64(50), stats2.LoadFetchedSeries())
+	})
+
+	t.Run("merge concurrently into the same stats object", func(t *testing.T) {
+		const concurrency = 10
+
+		stats1 := &Stats{}
+		stats2 := &Stats{}
+		stats2.AddFetchedChunks(1)
+		stats2.AddFetchedIndexBytes(2)
+
+		wg := sync.WaitGroup{}
+		wg.Add(concurrency)
+		for i := 0; i < concurrency; i++ {
+			go func() {
+				defer wg.Done()
+				stats1.Merge(stats2)
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, uint64(concurrency), stats1.LoadFetchedChunks())
+		assert.Equal(t, uint64(2*concurrency), stats1.LoadFetchedIndexBytes())
 	})
 
 	t.Run("merge two nil stats objects", func(t *testing.T) {
@@ -155,5 +223,7 @@ func TestStats_Merge(t *testing.T) {
 		assert.Equal(t, uint64(0), stats1.LoadFetchedChunks())
 		assert.Equal(t, uint32(0), stats1.LoadShardedQueries())
 		assert.Equal(t, uint32(0), stats1.LoadSplitQueries())
+		assert.Equal(t, uint64(0), stats1.LoadFetchedIndexBytes())
+		assert.Equal(t, uint64(0), stats1.LoadSamplesProcessed())
 	})
 }
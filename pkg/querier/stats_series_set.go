@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/grafana/mimir/pkg/querier/stats"
+)
+
+// samplesProcessedSeriesSet wraps a storage.SeriesSet and tracks the number of samples
+// iterated from its series in the query stats.
+type samplesProcessedSeriesSet struct {
+	storage.SeriesSet
+	stats *stats.Stats
+}
+
+// newSamplesProcessedSeriesSet returns a storage.SeriesSet tracking the number of samples processed
+// in the input stats. The input set is returned as is if stats tracking is disabled.
+func newSamplesProcessedSeriesSet(set storage.SeriesSet, s *stats.Stats) storage.SeriesSet {
+	if s == nil {
+		return set
+	}
+
+	return &samplesProcessedSeriesSet{SeriesSet: set, stats: s}
+}
+
+// At implements storage.SeriesSet.
+func (s *samplesProcessedSeriesSet) At() storage.Series {
+	series := s.SeriesSet.At()
+	if series == nil {
+		return nil
+	}
+
+	return &samplesProcessedSeries{Series: series, stats: s.stats}
+}
+
+type samplesProcessedSeries struct {
+	storage.Series
+	stats *stats.Stats
+}
+
+// Iterator implements storage.Series.
+func (s *samplesProcessedSeries) Iterator() chunkenc.Iterator {
+	return &samplesProcessedIterator{Iterator: s.Series.Iterator(), stats: s.stats}
+}
+
+// samplesProcessedIterator counts each sample the iterator is positioned on once,
+// regardless of whether it has been reached with Next() or Seek().
+type samplesProcessedIterator struct {
+	chunkenc.Iterator
+	stats *stats.Stats
+
+	hasSample bool
+	lastT     int64
+}
+
+// Next implements chunkenc.Iterator.
+func (it *samplesProcessedIterator) Next() bool {
+	if !it.Iterator.Next() {
+		return false
+	}
+
+	it.hasSample = true
+	it.lastT, _ = it.Iterator.At()
+	it.stats.AddSamplesProcessed(1)
+	return true
+}
+
+// Seek implements chunkenc.Iterator.
+func (it *samplesProcessedIterator) Seek(t int64) bool {
+	if !it.Iterator.Seek(t) {
+		return false
+	}
+
+	// Seek has no effect if the current sample already satisfies the requested timestamp,
+	// so we only count the sample if the iterator has moved.
+	if ts, _ := it.Iterator.At(); !it.hasSample || ts != it.lastT {
+		it.hasSample = true
+		it.lastT = ts
+		it.stats.AddSamplesProcessed(1)
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/storage/series"
+)
+
+func TestSamplesProcessedSeriesSet(t *testing.T) {
+	newSet := func() storage.SeriesSet {
+		return series.NewConcreteSeriesSet([]storage.Series{
+			series.NewConcreteSeries(labels.FromStrings("series", "1"), []model.SamplePair{{Timestamp: 10, Value: 1}, {Timestamp: 20, Value: 2}, {Timestamp: 30, Value: 3}}),
+			series.NewConcreteSeries(labels.FromStrings("series", "2"), []model.SamplePair{{Timestamp: 10, Value: 1}, {Timestamp: 20, Value: 2}}),
+		})
+	}
+
+	t.Run("should return the input set if stats are disabled", func(t *testing.T) {
+		set := newSet()
+		assert.Equal(t, set, newSamplesProcessedSeriesSet(set, nil))
+	})
+
+	t.Run("should count the samples iterated with Next()", func(t *testing.T) {
+		queryStats := &stats.Stats{}
+		set := newSamplesProcessedSeriesSet(newSet(), queryStats)
+
+		for set.Next() {
+			it := set.At().Iterator()
+			for it.Next() {
+			}
+			require.NoError(t, it.Err())
+		}
+		require.NoError(t, set.Err())
+
+		assert.Equal(t, uint64(5), queryStats.LoadSamplesProcessed())
+	})
+
+	t.Run("should count the samples iterated with Seek() only once", func(t *testing.T) {
+		queryStats := &stats.Stats{}
+		set := newSamplesProcessedSeriesSet(newSet(), queryStats)
+
+		require.True(t, set.Next())
+		it := set.At().Iterator()
+
+		require.True(t, it.Seek(20))
+		require.True(t, it.Seek(15)) // No effect, the iterator is already positioned on a sample >= 15.
+		require.True(t, it.Seek(20)) // No effect, the iterator is already positioned on 20.
+		require.True(t, it.Next())
+		require.False(t, it.Seek(40))
+
+		assert.Equal(t, uint64(2), queryStats.LoadSamplesProcessed())
+	})
+}
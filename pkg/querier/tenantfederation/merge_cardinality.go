@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package tenantfederation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/ingester/client"
+	"github.com/grafana/mimir/pkg/querier"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+// NewCardinalityAnalyzer returns a querier.CardinalityAnalyzer that runs the cardinality
+// analysis for all tenant IDs that are part of the request and merges the results.
+//
+// Label values are deduplicated across tenants, while the series counts of each label
+// value (and the total number of series) are summed up.
+func NewCardinalityAnalyzer(next querier.CardinalityAnalyzer, logger log.Logger) querier.CardinalityAnalyzer {
+	return &mergeCardinalityAnalyzer{
+		next:     next,
+		logger:   logger,
+		resolver: tenant.NewMultiResolver(),
+	}
+}
+
+type mergeCardinalityAnalyzer struct {
+	next     querier.CardinalityAnalyzer
+	resolver tenant.Resolver
+	logger   log.Logger
+}
+
+func (m *mergeCardinalityAnalyzer) LabelNamesAndValues(ctx context.Context, matchers []*labels.Matcher) (*client.LabelNamesAndValuesResponse, error) {
+	spanlog, ctx := spanlogger.NewWithLogger(ctx, m.logger, "mergeCardinalityAnalyzer.LabelNamesAndValues")
+	defer spanlog.Finish()
+
+	tenantIDs, err := m.resolver.TenantIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tenantIDs) == 1 {
+		level.Debug(spanlog).Log("msg", "only a single tenant, bypassing federated cardinality analyzer")
+		return m.next.LabelNamesAndValues(ctx, matchers)
+	}
+
+	results := make([]*client.LabelNamesAndValuesResponse, len(tenantIDs))
+	run := func(jobCtx context.Context, idx int) error {
+		tenantID := tenantIDs[idx]
+		res, err := m.next.LabelNamesAndValues(user.InjectOrgID(jobCtx, tenantID), matchers)
+		if err != nil {
+			return fmt.Errorf("unable to run federated label names and values request for %s: %w", tenantID, err)
+		}
+
+		level.Debug(spanlog).Log("msg", "adding results for tenant to merged results", "user", tenantID, "results", len(res.Items))
+		results[idx] = res
+		return nil
+	}
+
+	if err := concurrency.ForEachJob(ctx, len(tenantIDs), maxConcurrency, run); err != nil {
+		return nil, err
+	}
+
+	// Deduplicate the label values across tenants.
+	merged := map[string]map[string]struct{}{}
+	for _, res := range results {
+		for _, item := range res.Items {
+			values, ok := merged[item.LabelName]
+			if !ok {
+				values = make(map[string]struct{}, len(item.Values))
+				merged[item.LabelName] = values
+			}
+			for _, v := range item.Values {
+				values[v] = struct{}{}
+			}
+		}
+	}
+
+	out := &client.LabelNamesAndValuesResponse{Items: make([]*client.LabelValues, 0, len(merged))}
+	for name, values := range merged {
+		item := &client.LabelValues{LabelName: name, Values: make([]string, 0, len(values))}
+		for v := range values {
+			item.Values = append(item.Values, v)
+		}
+		sort.Strings(item.Values)
+		out.Items = append(out.Items, item)
+	}
+	sort.Slice(out.Items, func(i, j int) bool { return out.Items[i].LabelName < out.Items[j].LabelName })
+
+	return out, nil
+}
+
+func (m *mergeCardinalityAnalyzer) LabelValuesCardinality(ctx context.Context, labelNames []model.LabelName, matchers []*labels.Matcher) (uint64, *client.LabelValuesCardinalityResponse, error) {
+	spanlog, ctx := spanlogger.NewWithLogger(ctx, m.logger, "mergeCardinalityAnalyzer.LabelValuesCardinality")
+	defer spanlog.Finish()
+
+	tenantIDs, err := m.resolver.TenantIDs(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(tenantIDs) == 1 {
+		level.Debug(spanlog).Log("msg", "only a single tenant, bypassing federated cardinality analyzer")
+		return m.next.LabelValuesCardinality(ctx, labelNames, matchers)
+	}
+
+	seriesCounts := make([]uint64, len(tenantIDs))
+	results := make([]*client.LabelValuesCardinalityResponse, len(tenantIDs))
+	run := func(jobCtx context.Context, idx int) error {
+		tenantID := tenantIDs[idx]
+		seriesCount, res, err := m.next.LabelValuesCardinality(user.InjectOrgID(jobCtx, tenantID), labelNames, matchers)
+		if err != nil {
+			return fmt.Errorf("unable to run federated label values cardinality request for %s: %w", tenantID, err)
+		}
+
+		level.Debug(spanlog).Log("msg", "adding results for tenant to merged results", "user", tenantID, "results", len(res.Items))
+		seriesCounts[idx] = seriesCount
+		results[idx] = res
+		return nil
+	}
+
+	if err := concurrency.ForEachJob(ctx, len(tenantIDs), maxConcurrency, run); err != nil {
+		return 0, nil, err
+	}
+
+	// Sum up the series counts of each label value across tenants.
+	var seriesCountTotal uint64
+	merged := map[string]map[string]uint64{}
+	for idx, res := range results {
+		seriesCountTotal += seriesCounts[idx]
+
+		for _, item := range res.Items {
+			counts, ok := merged[item.LabelName]
+			if !ok {
+				counts = make(map[string]uint64, len(item.LabelValueSeries))
+				merged[item.LabelName] = counts
+			}
+			for value, count := range item.LabelValueSeries {
+				counts[value] += count
+			}
+		}
+	}
+
+	out := &client.LabelValuesCardinalityResponse{Items: make([]*client.LabelValueSeriesCount, 0, len(merged))}
+	for name, counts := range merged {
+		out.Items = append(out.Items, &client.LabelValueSeriesCount{LabelName: name, LabelValueSeries: counts})
+	}
+	sort.Slice(out.Items, func(i, j int) bool { return out.Items[i].LabelName < out.Items[j].LabelName })
+
+	return seriesCountTotal, out, nil
+}
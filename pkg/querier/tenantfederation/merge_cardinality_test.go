@@ -0,0 +1,163 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package tenantfederation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/grafana/mimir/pkg/ingester/client"
+	"github.com/grafana/mimir/pkg/util/test"
+)
+
+type mockCardinalityAnalyzer struct {
+	labelNamesAndValues    map[string]*client.LabelNamesAndValuesResponse
+	labelValuesCardinality map[string]*client.LabelValuesCardinalityResponse
+	seriesCountTotal       map[string]uint64
+	err                    error
+}
+
+func (m *mockCardinalityAnalyzer) LabelNamesAndValues(ctx context.Context, _ []*labels.Matcher) (*client.LabelNamesAndValuesResponse, error) {
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse single tenant ID from context: %w", err)
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	res, ok := m.labelNamesAndValues[tenantID]
+	if !ok {
+		return nil, fmt.Errorf("no mock results for tenant ID %s available", tenantID)
+	}
+
+	return res, nil
+}
+
+func (m *mockCardinalityAnalyzer) LabelValuesCardinality(ctx context.Context, _ []model.LabelName, _ []*labels.Matcher) (uint64, *client.LabelValuesCardinalityResponse, error) {
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to parse single tenant ID from context: %w", err)
+	}
+	if m.err != nil {
+		return 0, nil, m.err
+	}
+
+	res, ok := m.labelValuesCardinality[tenantID]
+	if !ok {
+		return 0, nil, fmt.Errorf("no mock results for tenant ID %s available", tenantID)
+	}
+
+	return m.seriesCountTotal[tenantID], res, nil
+}
+
+func TestMergeCardinalityAnalyzer_LabelNamesAndValues(t *testing.T) {
+	upstream := &mockCardinalityAnalyzer{
+		labelNamesAndValues: map[string]*client.LabelNamesAndValuesResponse{
+			"team-a": {Items: []*client.LabelValues{
+				{LabelName: "job", Values: []string{"api", "db"}},
+			}},
+			"team-b": {Items: []*client.LabelValues{
+				{LabelName: "job", Values: []string{"db", "cache"}},
+				{LabelName: "instance", Values: []string{"a"}},
+			}},
+		},
+	}
+
+	t.Run("invalid tenant IDs", func(t *testing.T) {
+		analyzer := NewCardinalityAnalyzer(upstream, test.NewTestingLogger(t))
+		_, err := analyzer.LabelNamesAndValues(context.Background(), nil)
+
+		assert.ErrorIs(t, err, user.ErrNoOrgID)
+	})
+
+	t.Run("single tenant bypass", func(t *testing.T) {
+		analyzer := NewCardinalityAnalyzer(upstream, test.NewTestingLogger(t))
+		res, err := analyzer.LabelNamesAndValues(user.InjectOrgID(context.Background(), "team-a"), nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, upstream.labelNamesAndValues["team-a"], res)
+	})
+
+	t.Run("multiple tenants", func(t *testing.T) {
+		analyzer := NewCardinalityAnalyzer(upstream, test.NewTestingLogger(t))
+		res, err := analyzer.LabelNamesAndValues(user.InjectOrgID(context.Background(), "team-a|team-b"), nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, &client.LabelNamesAndValuesResponse{Items: []*client.LabelValues{
+			{LabelName: "instance", Values: []string{"a"}},
+			{LabelName: "job", Values: []string{"api", "cache", "db"}},
+		}}, res)
+	})
+
+	t.Run("multiple tenants with error", func(t *testing.T) {
+		upstream := &mockCardinalityAnalyzer{err: errors.New("failed")}
+		analyzer := NewCardinalityAnalyzer(upstream, test.NewTestingLogger(t))
+		_, err := analyzer.LabelNamesAndValues(user.InjectOrgID(context.Background(), "team-a|team-b"), nil)
+
+		assert.ErrorIs(t, err, upstream.err)
+	})
+}
+
+func TestMergeCardinalityAnalyzer_LabelValuesCardinality(t *testing.T) {
+	upstream := &mockCardinalityAnalyzer{
+		labelValuesCardinality: map[string]*client.LabelValuesCardinalityResponse{
+			"team-a": {Items: []*client.LabelValueSeriesCount{
+				{LabelName: "job", LabelValueSeries: map[string]uint64{"api": 10, "db": 5}},
+			}},
+			"team-b": {Items: []*client.LabelValueSeriesCount{
+				{LabelName: "job", LabelValueSeries: map[string]uint64{"db": 3, "cache": 2}},
+				{LabelName: "instance", LabelValueSeries: map[string]uint64{"a": 1}},
+			}},
+		},
+		seriesCountTotal: map[string]uint64{
+			"team-a": 100,
+			"team-b": 50,
+		},
+	}
+
+	t.Run("invalid tenant IDs", func(t *testing.T) {
+		analyzer := NewCardinalityAnalyzer(upstream, test.NewTestingLogger(t))
+		_, _, err := analyzer.LabelValuesCardinality(context.Background(), nil, nil)
+
+		assert.ErrorIs(t, err, user.ErrNoOrgID)
+	})
+
+	t.Run("single tenant bypass", func(t *testing.T) {
+		analyzer := NewCardinalityAnalyzer(upstream, test.NewTestingLogger(t))
+		seriesCountTotal, res, err := analyzer.LabelValuesCardinality(user.InjectOrgID(context.Background(), "team-a"), nil, nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, uint64(100), seriesCountTotal)
+		assert.Equal(t, upstream.labelValuesCardinality["team-a"], res)
+	})
+
+	t.Run("multiple tenants", func(t *testing.T) {
+		analyzer := NewCardinalityAnalyzer(upstream, test.NewTestingLogger(t))
+		seriesCountTotal, res, err := analyzer.LabelValuesCardinality(user.InjectOrgID(context.Background(), "team-a|team-b"), nil, nil)
+
+		require.NoError(t, err)
+		assert.Equal(t, uint64(150), seriesCountTotal)
+		assert.Equal(t, &client.LabelValuesCardinalityResponse{Items: []*client.LabelValueSeriesCount{
+			{LabelName: "instance", LabelValueSeries: map[string]uint64{"a": 1}},
+			{LabelName: "job", LabelValueSeries: map[string]uint64{"api": 10, "cache": 2, "db": 8}},
+		}}, res)
+	})
+
+	t.Run("multiple tenants with error", func(t *testing.T) {
+		upstream := &mockCardinalityAnalyzer{err: errors.New("failed")}
+		analyzer := NewCardinalityAnalyzer(upstream, test.NewTestingLogger(t))
+		_, _, err := analyzer.LabelValuesCardinality(user.InjectOrgID(context.Background(), "team-a|team-b"), nil, nil)
+
+		assert.ErrorIs(t, err, upstream.err)
+	})
+}
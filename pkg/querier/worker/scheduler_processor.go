@@ -43,6 +43,7 @@ func newSchedulerProcessor(cfg Config, handler RequestHandler, log log.Logger, r
 		handler:        handler,
 		maxMessageSize: cfg.GRPCClientConfig.MaxSendMsgSize,
 		querierID:      cfg.QuerierID,
+		querierZone:    cfg.QuerierZone,
 		grpcConfig:     cfg.GRPCClientConfig,
 
 		schedulerClientFactory: func(conn *grpc.ClientConn) schedulerpb.SchedulerForQuerierClient {
@@ -78,6 +79,7 @@ type schedulerProcessor struct {
 	grpcConfig     grpcclient.Config
 	maxMessageSize int
 	querierID      string
+	querierZone    string
 
 	frontendPool                  *client.Pool
 	frontendClientRequestDuration *prometheus.HistogramVec
@@ -108,7 +110,7 @@ func (sp *schedulerProcessor) processQueriesOnSingleStream(workerCtx context.Con
 	for backoff.Ongoing() {
 		c, err := schedulerClient.QuerierLoop(execCtx)
 		if err == nil {
-			err = c.Send(&schedulerpb.QuerierToScheduler{QuerierID: sp.querierID})
+			err = c.Send(&schedulerpb.QuerierToScheduler{QuerierID: sp.querierID, QuerierZone: sp.querierZone})
 		}
 
 		if err != nil {
@@ -31,6 +31,7 @@ type Config struct {
 	SchedulerAddress string            `yaml:"scheduler_address"`
 	DNSLookupPeriod  time.Duration     `yaml:"dns_lookup_duration" category:"advanced"`
 	QuerierID        string            `yaml:"id" category:"advanced"`
+	QuerierZone      string            `yaml:"availability_zone" category:"experimental"`
 	GRPCClientConfig grpcclient.Config `yaml:"grpc_client_config" doc:"description=Configures the gRPC client used to communicate between the queriers and the query-frontends / query-schedulers."`
 
 	// This configuration is injected internally.
@@ -43,6 +44,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&cfg.FrontendAddress, "querier.frontend-address", "", "Address of the query-frontend component, in host:port format. If multiple query-frontends are running, the host should be a DNS resolving to all query-frontend instances. This option should be set only when query-scheduler component is not in use.")
 	f.DurationVar(&cfg.DNSLookupPeriod, "querier.dns-lookup-period", 10*time.Second, "How often to query DNS for query-frontend or query-scheduler address.")
 	f.StringVar(&cfg.QuerierID, "querier.id", "", "Querier ID, sent to the query-frontend to identify requests from the same querier. Defaults to hostname.")
+	f.StringVar(&cfg.QuerierZone, "querier.availability-zone", "", "The availability zone where this querier is running, sent to the query-scheduler. If set, the query-scheduler preferably dispatches to this querier the queries of query-frontends running in the same availability zone.")
 
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("querier.frontend-client", f)
 }
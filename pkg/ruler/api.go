@@ -6,6 +6,7 @@
 package ruler
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -22,6 +23,7 @@ import (
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/rules"
 	"github.com/weaveworks/common/user"
 	"gopkg.in/yaml.v3"
 
@@ -33,6 +35,10 @@ import (
 	util_log "github.com/grafana/mimir/pkg/util/log"
 )
 
+// RuleGroupsAuthorHeader is the header of the requests changing the rule groups with the author of the change,
+// recorded in the history of the namespace.
+const RuleGroupsAuthorHeader = "X-Mimir-Rules-Author"
+
 // In order to reimplement the prometheus rules API, a large amount of code was copied over
 // This is required because the prometheus api implementation does not allow us to return errors
 // on rule lookups, which might fail in Mimir's case.
@@ -128,18 +134,21 @@ func respondError(logger log.Logger, w http.ResponseWriter, msg string) {
 
 // API is used to handle HTTP requests for the ruler service
 type API struct {
-	ruler *Ruler
-	store rulestore.RuleStore
+	ruler     *Ruler
+	store     rulestore.RuleStore
+	queryFunc rules.QueryFunc
 
 	logger log.Logger
 }
 
-// NewAPI returns a new API struct with the provided ruler and rule store
-func NewAPI(r *Ruler, s rulestore.RuleStore, logger log.Logger) *API {
+// NewAPI returns a new API struct with the provided ruler and rule store. The query function
+// is used to evaluate the rule groups submitted to the dry-run endpoint.
+func NewAPI(r *Ruler, s rulestore.RuleStore, queryFunc rules.QueryFunc, logger log.Logger) *API {
 	return &API{
-		ruler:  r,
-		store:  s,
-		logger: logger,
+		ruler:     r,
+		store:     s,
+		queryFunc: queryFunc,
+		logger:    logger,
 	}
 }
 
@@ -296,6 +305,14 @@ var (
 	ErrNoRuleGroups = errors.New("no rule groups found")
 	// ErrBadRuleGroup is returned when the provided rule group can not be unmarshalled
 	ErrBadRuleGroup = errors.New("unable to decode rule group")
+	// ErrFederatedRuleGroupNotAllowed is returned when the tenant is not allowed to have federated rule groups
+	ErrFederatedRuleGroupNotAllowed = errors.New("the tenant is not allowed to have federated rule groups (rule groups with source tenants)")
+	// ErrFederatedRuleGroupNotEnabled is returned when the dry-run of a federated rule group is requested but the federated rule groups are not evaluated
+	ErrFederatedRuleGroupNotEnabled = errors.New("the federated rule groups (rule groups with source tenants) are not evaluated for the tenant")
+	// ErrNoVersion signals a version url parameter was not found
+	ErrNoVersion = errors.New("a version must be provided in the request")
+	// ErrRuleGroupsHistoryDisabled is returned when the history of the namespaces is requested but it is not kept
+	ErrRuleGroupsHistoryDisabled = errors.New("the history of the rule groups is disabled")
 )
 
 func marshalAndSend(output interface{}, w http.ResponseWriter, logger log.Logger) {
@@ -448,19 +465,14 @@ func (a *API) GetRuleGroup(w http.ResponseWriter, req *http.Request) {
 	marshalAndSend(formatted, w, logger)
 }
 
-func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
-	logger := util_log.WithContext(req.Context(), a.logger)
-	userID, namespace, _, err := parseRequest(req, true, false)
-	if err != nil {
-		respondError(logger, w, err.Error())
-		return
-	}
-
+// readRuleGroup reads and validates the rule group in the request body. It returns false if the rule group
+// is not valid, after responding with the error.
+func (a *API) readRuleGroup(w http.ResponseWriter, req *http.Request, userID string, logger log.Logger) (rulefmt.RuleGroup, bool) {
 	payload, err := io.ReadAll(req.Body)
 	if err != nil {
 		level.Error(logger).Log("msg", "unable to read rule group payload", "err", err.Error())
 		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return rulefmt.RuleGroup{}, false
 	}
 
 	level.Debug(logger).Log("msg", "attempting to unmarshal rulegroup", "userID", userID, "group", string(payload))
@@ -470,7 +482,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 	if err != nil {
 		level.Error(logger).Log("msg", "unable to unmarshal rule group payload", "err", err.Error())
 		http.Error(w, ErrBadRuleGroup.Error(), http.StatusBadRequest)
-		return
+		return rulefmt.RuleGroup{}, false
 	}
 
 	errs := a.ruler.manager.ValidateRuleGroup(rg)
@@ -482,12 +494,34 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		}
 
 		http.Error(w, strings.Join(e, ", "), http.StatusBadRequest)
-		return
+		return rulefmt.RuleGroup{}, false
+	}
+
+	if len(rg.SourceTenants) > 0 && !a.ruler.cfg.TenantFederation.isTenantAllowed(userID) {
+		level.Error(logger).Log("msg", "federated rule group not allowed", "user", userID)
+		http.Error(w, ErrFederatedRuleGroupNotAllowed.Error(), http.StatusBadRequest)
+		return rulefmt.RuleGroup{}, false
 	}
 
 	if err := a.ruler.AssertMaxRulesPerRuleGroup(userID, len(rg.Rules)); err != nil {
 		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
 		http.Error(w, err.Error(), http.StatusBadRequest)
+		return rulefmt.RuleGroup{}, false
+	}
+
+	return rg, true
+}
+
+func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, namespace, _, err := parseRequest(req, true, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	rg, ok := a.readRuleGroup(w, req, userID, logger)
+	if !ok {
 		return
 	}
 
@@ -505,6 +539,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 	}
 
 	rgProto := rulespb.ToProto(userID, namespace, rg)
+	a.initNamespaceHistory(req.Context(), logger, userID, namespace)
 
 	level.Debug(logger).Log("msg", "attempting to store rulegroup", "userID", userID, "group", rgProto.String())
 	err = a.store.SetRuleGroup(req.Context(), userID, namespace, rgProto)
@@ -514,9 +549,49 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	a.saveNamespaceVersion(req.Context(), logger, userID, namespace, req.Header.Get(RuleGroupsAuthorHeader))
 	respondAccepted(w, logger)
 }
 
+// DryRunRuleGroup evaluates the rule group in the request body once against the current data, and responds
+// with the resulting series and alerts. Nothing is stored, written to the ingesters or sent to the Alertmanager.
+func (a *API) DryRunRuleGroup(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+	userID, _, _, err := parseRequest(req, false, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	rg, ok := a.readRuleGroup(w, req, userID, logger)
+	if !ok {
+		return
+	}
+
+	if len(rg.SourceTenants) > 0 && !a.ruler.cfg.TenantFederation.isTenantEnabled(userID) {
+		level.Error(logger).Log("msg", "federated rule group not evaluated", "user", userID)
+		http.Error(w, ErrFederatedRuleGroupNotEnabled.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := dryRunRuleGroup(req.Context(), rg, a.queryFunc, time.Now(), a.ruler.limits.EvaluationDelay(userID), a.ruler.cfg.ExternalURL.URL, logger)
+
+	b, err := json.Marshal(&response{
+		Status: "success",
+		Data:   result,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
 func (a *API) DeleteNamespace(w http.ResponseWriter, req *http.Request) {
 	logger := util_log.WithContext(req.Context(), a.logger)
 
@@ -526,6 +601,8 @@ func (a *API) DeleteNamespace(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	a.initNamespaceHistory(req.Context(), logger, userID, namespace)
+
 	err = a.store.DeleteNamespace(req.Context(), userID, namespace)
 	if err != nil {
 		if errors.Is(err, rulestore.ErrGroupNamespaceNotFound) {
@@ -536,6 +613,7 @@ func (a *API) DeleteNamespace(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	a.saveNamespaceVersion(req.Context(), logger, userID, namespace, req.Header.Get(RuleGroupsAuthorHeader))
 	respondAccepted(w, logger)
 }
 
@@ -548,6 +626,8 @@ func (a *API) DeleteRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	a.initNamespaceHistory(req.Context(), logger, userID, namespace)
+
 	err = a.store.DeleteRuleGroup(req.Context(), userID, namespace, groupName)
 	if err != nil {
 		if errors.Is(err, rulestore.ErrGroupNotFound) {
@@ -558,5 +638,232 @@ func (a *API) DeleteRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	a.saveNamespaceVersion(req.Context(), logger, userID, namespace, req.Header.Get(RuleGroupsAuthorHeader))
 	respondAccepted(w, logger)
 }
+
+// namespaceVersion is a version of a namespace, as listed by the ruler config API.
+type namespaceVersion struct {
+	Version    string    `yaml:"version"`
+	Author     string    `yaml:"author,omitempty"`
+	Timestamp  time.Time `yaml:"timestamp"`
+	RuleGroups []string  `yaml:"rule_groups"`
+}
+
+// ListNamespaceVersions lists the versions of a namespace kept in the history, most recent first.
+func (a *API) ListNamespaceVersions(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+
+	userID, namespace, _, err := parseRequest(req, true, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	history, ok := a.namespaceHistory()
+	if !ok {
+		http.Error(w, ErrRuleGroupsHistoryDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	versions, err := history.ListNamespaceVersions(req.Context(), userID, namespace)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	formatted := make([]namespaceVersion, 0, len(versions))
+	for _, v := range versions {
+		groups := make([]string, 0, len(v.RuleGroups))
+		for _, rg := range v.RuleGroups {
+			groups = append(groups, rg.Name)
+		}
+		formatted = append(formatted, namespaceVersion{
+			Version:    v.ID,
+			Author:     v.Author,
+			Timestamp:  v.Timestamp,
+			RuleGroups: groups,
+		})
+	}
+	marshalAndSend(formatted, w, logger)
+}
+
+// GetNamespaceVersion returns the rule groups of a version of a namespace, in the same format as ListRules.
+func (a *API) GetNamespaceVersion(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+
+	userID, namespace, version, ok := a.parseVersionRequest(w, req, logger)
+	if !ok {
+		return
+	}
+
+	history, ok := a.namespaceHistory()
+	if !ok {
+		http.Error(w, ErrRuleGroupsHistoryDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	v, err := history.GetNamespaceVersion(req.Context(), userID, namespace, version)
+	if err != nil {
+		if errors.Is(err, rulestore.ErrNamespaceVersionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	marshalAndSend(v.RuleGroups.Formatted(), w, logger)
+}
+
+// RollbackNamespace replaces the rule groups of a namespace with the rule groups of one of its versions.
+// The rollback is recorded as a new version of the namespace.
+func (a *API) RollbackNamespace(w http.ResponseWriter, req *http.Request) {
+	logger := util_log.WithContext(req.Context(), a.logger)
+
+	userID, namespace, version, ok := a.parseVersionRequest(w, req, logger)
+	if !ok {
+		return
+	}
+
+	history, ok := a.namespaceHistory()
+	if !ok {
+		http.Error(w, ErrRuleGroupsHistoryDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	v, err := history.GetNamespaceVersion(req.Context(), userID, namespace, version)
+	if err != nil {
+		if errors.Is(err, rulestore.ErrNamespaceVersionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		respondError(logger, w, err.Error())
+		return
+	}
+
+	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, "")
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to fetch current rule groups for validation", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The limits and the tenants allowed to have federated rule groups may have changed since the version was stored.
+	current := map[string]bool{}
+	for _, rg := range rgs {
+		if rg.Namespace == namespace {
+			current[rg.Name] = true
+		}
+	}
+	if err := a.ruler.AssertMaxRuleGroups(userID, len(rgs)-len(current)+len(v.RuleGroups)); err != nil {
+		level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, rg := range v.RuleGroups {
+		if err := a.ruler.AssertMaxRulesPerRuleGroup(userID, len(rg.Rules)); err != nil {
+			level.Error(logger).Log("msg", "limit validation failure", "err", err.Error(), "user", userID)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(rg.SourceTenants) > 0 && !a.ruler.cfg.TenantFederation.isTenantAllowed(userID) {
+			level.Error(logger).Log("msg", "federated rule group not allowed", "user", userID)
+			http.Error(w, ErrFederatedRuleGroupNotAllowed.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	a.initNamespaceHistory(req.Context(), logger, userID, namespace)
+
+	level.Info(logger).Log("msg", "rolling back namespace", "user", userID, "namespace", namespace, "version", version)
+	for _, rg := range v.RuleGroups {
+		delete(current, rg.Name)
+		if err := a.store.SetRuleGroup(req.Context(), userID, namespace, rg); err != nil {
+			level.Error(logger).Log("msg", "unable to store rule group", "err", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	for groupName := range current {
+		if err := a.store.DeleteRuleGroup(req.Context(), userID, namespace, groupName); err != nil && !errors.Is(err, rulestore.ErrGroupNotFound) {
+			level.Error(logger).Log("msg", "unable to delete rule group", "err", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	a.saveNamespaceVersion(req.Context(), logger, userID, namespace, req.Header.Get(RuleGroupsAuthorHeader))
+	respondAccepted(w, logger)
+}
+
+// parseVersionRequest parses the user, the namespace and the version of the request. It returns false if the
+// request is not valid, after responding with the error.
+func (a *API) parseVersionRequest(w http.ResponseWriter, req *http.Request, logger log.Logger) (string, string, string, bool) {
+	userID, namespace, _, err := parseRequest(req, true, false)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return "", "", "", false
+	}
+
+	version := mux.Vars(req)["version"]
+	if version == "" {
+		respondError(logger, w, ErrNoVersion.Error())
+		return "", "", "", false
+	}
+
+	return userID, namespace, version, true
+}
+
+// namespaceHistory returns the store of the namespace versions, if the history of the rule groups is enabled.
+func (a *API) namespaceHistory() (rulestore.RuleStoreHistory, bool) {
+	if a.ruler.cfg.RuleGroupsHistoryMaxVersions <= 0 {
+		return nil, false
+	}
+	history, ok := a.store.(rulestore.RuleStoreHistory)
+	return history, ok
+}
+
+// initNamespaceHistory stores the current rule groups of the namespace as its first version if the namespace has
+// no version yet, so that a namespace created before the history was enabled can be rolled back to its initial state.
+func (a *API) initNamespaceHistory(ctx context.Context, logger log.Logger, userID, namespace string) {
+	history, ok := a.namespaceHistory()
+	if !ok {
+		return
+	}
+
+	exists, err := history.HasNamespaceVersions(ctx, userID, namespace)
+	if err != nil {
+		level.Warn(logger).Log("msg", "unable to check namespace versions", "user", userID, "namespace", namespace, "err", err)
+		return
+	}
+	if exists {
+		return
+	}
+
+	a.saveNamespaceVersion(ctx, logger, userID, namespace, "")
+}
+
+// saveNamespaceVersion stores the current rule groups of the namespace as a new version of the namespace. The failures
+// are only logged, because the change of the namespace has already been applied.
+func (a *API) saveNamespaceVersion(ctx context.Context, logger log.Logger, userID, namespace, author string) {
+	history, ok := a.namespaceHistory()
+	if !ok {
+		return
+	}
+
+	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+	if err == nil && len(rgs) > 0 {
+		err = a.store.LoadRuleGroups(ctx, map[string]rulespb.RuleGroupList{userID: rgs})
+	}
+	if err == nil {
+		err = history.SaveNamespaceVersion(ctx, userID, namespace, rulestore.NamespaceVersion{
+			Author:     author,
+			Timestamp:  time.Now(),
+			RuleGroups: rgs,
+		}, a.ruler.cfg.RuleGroupsHistoryMaxVersions)
+	}
+	if err != nil {
+		level.Warn(logger).Log("msg", "unable to save namespace version", "user", userID, "namespace", namespace, "err", err)
+	}
+}
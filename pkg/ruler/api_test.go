@@ -20,11 +20,17 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/grafana/dskit/services"
 	"github.com/grafana/dskit/test"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/timestamp"
+	"github.com/prometheus/prometheus/promql"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
 	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v3"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore/bucketclient"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
@@ -258,7 +264,7 @@ func TestRuler(t *testing.T) {
 				return len(rls.Groups)
 			})
 
-			a := NewAPI(r, r.store, log.NewNopLogger())
+			a := NewAPI(r, r.store, nil, log.NewNopLogger())
 
 			req := requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/rules", nil, userID)
 			w := httptest.NewRecorder()
@@ -311,7 +317,7 @@ func TestRuler_alerts(t *testing.T) {
 		return len(rls.Groups)
 	})
 
-	a := NewAPI(r, r.store, log.NewNopLogger())
+	a := NewAPI(r, r.store, nil, log.NewNopLogger())
 
 	req := requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/api/v1/alerts", nil, "user1")
 	w := httptest.NewRecorder()
@@ -343,7 +349,7 @@ func TestRuler_Create(t *testing.T) {
 	cfg := defaultRulerConfig(t)
 
 	r := prepareRuler(t, cfg, newMockRuleStore(make(map[string]rulespb.RuleGroupList)), withStart())
-	a := NewAPI(r, r.store, log.NewNopLogger())
+	a := NewAPI(r, r.store, nil, log.NewNopLogger())
 
 	tc := []struct {
 		name   string
@@ -426,6 +432,219 @@ rules:
 	}
 }
 
+func TestRuler_CreateFederatedRuleGroup(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	cfg.TenantFederation.Enabled = true
+	cfg.TenantFederation.AllowedTenants = []string{"user1"}
+
+	r := prepareRuler(t, cfg, newMockRuleStore(make(map[string]rulespb.RuleGroupList)), withStart())
+	a := NewAPI(r, r.store, nil, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/prometheus/config/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+
+	const input = `
+name: test
+source_tenants: [tenant-a, tenant-b]
+rules:
+- record: up_rule
+  expr: up{}
+`
+
+	for userID, expectedStatus := range map[string]int{"user1": http.StatusAccepted, "user2": http.StatusBadRequest} {
+		t.Run(userID, func(t *testing.T) {
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/namespace", strings.NewReader(input), userID)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, expectedStatus, w.Code)
+			if expectedStatus == http.StatusBadRequest {
+				require.Equal(t, ErrFederatedRuleGroupNotAllowed.Error()+"\n", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRuler_DryRunRuleGroup(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	cfg.TenantFederation.Enabled = true
+	cfg.TenantFederation.AllowedTenants = []string{"user1"}
+
+	store := newMockRuleStore(make(map[string]rulespb.RuleGroupList))
+	r := prepareRuler(t, cfg, store, withStart())
+
+	var queriedTenants []string
+	queryFunc := func(ctx context.Context, qs string, ts time.Time) (promql.Vector, error) {
+		tenantIDs, err := ExtractTenantIDs(ctx)
+		require.NoError(t, err)
+		queriedTenants = append(queriedTenants, tenantIDs)
+
+		if qs == "fail" {
+			return nil, errors.New("query failed")
+		}
+		return promql.Vector{{Point: promql.Point{T: timestamp.FromTime(ts), V: 1}, Metric: labels.FromStrings("job", "test")}}, nil
+	}
+	a := NewAPI(r, r.store, queryFunc, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/prometheus/config/v1/rules/dry-run").Methods("POST").HandlerFunc(a.DryRunRuleGroup)
+
+	tests := map[string]struct {
+		userID          string
+		input           string
+		expectedStatus  int
+		expectedError   string
+		expectedTenants []string
+		expectedRules   []dryRunRule
+	}{
+		"should evaluate the recording and alerting rules": {
+			userID: "user1",
+			input: `
+name: test
+rules:
+- record: up_rule
+  expr: up
+- alert: UpAlert
+  expr: up
+  labels:
+    severity: critical
+- alert: UpPendingAlert
+  expr: up
+  for: 5m
+- record: failing_rule
+  expr: fail
+`,
+			expectedStatus:  http.StatusOK,
+			expectedTenants: []string{"user1", "user1", "user1", "user1"},
+			expectedRules: []dryRunRule{
+				{
+					Name:      "up_rule",
+					Query:     "up",
+					Type:      "recording",
+					Series:    []*dryRunSeries{{Labels: labels.FromStrings(labels.MetricName, "up_rule", "job", "test"), Value: "1e+00"}},
+					Health:    "ok",
+					LastError: "",
+				},
+				{
+					Name:   "UpAlert",
+					Query:  "up",
+					Type:   "alerting",
+					Series: []*dryRunSeries{{Labels: labels.FromStrings(labels.MetricName, "ALERTS", labels.AlertName, "UpAlert", "alertstate", "firing", "job", "test", "severity", "critical"), Value: "1e+00"}},
+					Alerts: []*Alert{{Labels: labels.FromStrings(labels.AlertName, "UpAlert", "job", "test", "severity", "critical"), Annotations: labels.Labels{}, State: "firing", Value: "1e+00"}},
+					Health: "ok",
+				},
+				{
+					Name:   "UpPendingAlert",
+					Query:  "up",
+					Type:   "alerting",
+					Series: []*dryRunSeries{{Labels: labels.FromStrings(labels.MetricName, "ALERTS", labels.AlertName, "UpPendingAlert", "alertstate", "pending", "job", "test"), Value: "1e+00"}},
+					Alerts: []*Alert{{Labels: labels.FromStrings(labels.AlertName, "UpPendingAlert", "job", "test"), Annotations: labels.Labels{}, State: "pending", Value: "1e+00"}},
+					Health: "ok",
+				},
+				{
+					Name:      "failing_rule",
+					Query:     "fail",
+					Type:      "recording",
+					Series:    []*dryRunSeries{},
+					Health:    "err",
+					LastError: "query failed",
+				},
+			},
+		},
+		"should evaluate the federated rule groups against the source tenants": {
+			userID: "user1",
+			input: `
+name: test
+source_tenants: [tenant-a, tenant-b]
+rules:
+- record: up_rule
+  expr: up
+`,
+			expectedStatus:  http.StatusOK,
+			expectedTenants: []string{"tenant-a|tenant-b"},
+			expectedRules: []dryRunRule{
+				{
+					Name:   "up_rule",
+					Query:  "up",
+					Type:   "recording",
+					Series: []*dryRunSeries{{Labels: labels.FromStrings(labels.MetricName, "up_rule", "job", "test"), Value: "1e+00"}},
+					Health: "ok",
+				},
+			},
+		},
+		"should reject the federated rule groups of the tenants not allowed": {
+			userID: "user2",
+			input: `
+name: test
+source_tenants: [tenant-a, tenant-b]
+rules:
+- record: up_rule
+  expr: up
+`,
+			expectedStatus: http.StatusBadRequest,
+			expectedError:  ErrFederatedRuleGroupNotAllowed.Error(),
+		},
+		"should reject an invalid rule group": {
+			userID: "user1",
+			input: `
+name: test
+rules:
+- record: up_rule
+  expr: up{
+`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			queriedTenants = nil
+
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules/dry-run", strings.NewReader(testData.input), testData.userID)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, testData.expectedStatus, w.Code, w.Body.String())
+			if testData.expectedError != "" {
+				require.Equal(t, testData.expectedError+"\n", w.Body.String())
+			}
+			if testData.expectedStatus != http.StatusOK {
+				return
+			}
+
+			res := struct {
+				Status string       `json:"status"`
+				Data   dryRunResult `json:"data"`
+			}{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			require.Equal(t, "success", res.Status)
+			require.Equal(t, "test", res.Data.Name)
+			require.Equal(t, testData.expectedTenants, queriedTenants)
+
+			// The alerts are active since the evaluation, and their ALERTS_FOR_STATE series value is the active at timestamp.
+			for i := range res.Data.Rules {
+				r := &res.Data.Rules[i]
+				for _, a := range r.Alerts {
+					require.NotNil(t, a.ActiveAt)
+					a.ActiveAt = nil
+				}
+				if r.Type != "alerting" {
+					continue
+				}
+				require.Len(t, r.Series, 2)
+				require.Equal(t, "ALERTS_FOR_STATE", r.Series[1].Labels.Get(labels.MetricName))
+				r.Series = r.Series[:1]
+			}
+			require.Equal(t, testData.expectedRules, res.Data.Rules)
+
+			// Nothing has been stored.
+			groups, err := store.ListRuleGroupsForUserAndNamespace(context.Background(), testData.userID, "")
+			require.NoError(t, err)
+			require.Empty(t, groups)
+		})
+	}
+}
+
 func TestRuler_DeleteNamespace(t *testing.T) {
 	cfg := defaultRulerConfig(t)
 
@@ -451,7 +670,7 @@ func TestRuler_DeleteNamespace(t *testing.T) {
 	}
 
 	r := prepareRuler(t, cfg, newMockRuleStore(mockRulesNamespaces), withStart())
-	a := NewAPI(r, r.store, log.NewNopLogger())
+	a := NewAPI(r, r.store, nil, log.NewNopLogger())
 
 	router := mux.NewRouter()
 	router.Path("/prometheus/config/v1/rules/{namespace}").Methods(http.MethodDelete).HandlerFunc(a.DeleteNamespace)
@@ -482,6 +701,94 @@ func TestRuler_DeleteNamespace(t *testing.T) {
 	require.Equal(t, "{\"status\":\"error\",\"data\":null,\"errorType\":\"server_error\",\"error\":\"unable to delete rg\"}", w.Body.String())
 }
 
+func TestRuler_NamespaceHistory(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+	cfg.RuleGroupsHistoryMaxVersions = 5
+
+	store := bucketclient.NewBucketRuleStore(objstore.NewInMemBucket(), nil, log.NewNopLogger())
+	require.NoError(t, store.SetRuleGroup(context.Background(), "user1", "namespace1", &rulespb.RuleGroupDesc{
+		Name:      "group1",
+		Namespace: "namespace1",
+		User:      "user1",
+		Rules:     []*rulespb.RuleDesc{mockRecordingRuleDesc("UP_RULE", "up")},
+		Interval:  interval,
+	}))
+
+	r := prepareRuler(t, cfg, store, withStart())
+	a := NewAPI(r, r.store, nil, log.NewNopLogger())
+
+	router := mux.NewRouter()
+	router.Path("/prometheus/config/v1/rules/{namespace}").Methods(http.MethodGet).HandlerFunc(a.ListRules)
+	router.Path("/prometheus/config/v1/rules/{namespace}").Methods(http.MethodDelete).HandlerFunc(a.DeleteNamespace)
+	router.Path("/prometheus/config/v1/rules-history/{namespace}").Methods(http.MethodGet).HandlerFunc(a.ListNamespaceVersions)
+	router.Path("/prometheus/config/v1/rules-history/{namespace}/{version}").Methods(http.MethodGet).HandlerFunc(a.GetNamespaceVersion)
+	router.Path("/prometheus/config/v1/rules-history/{namespace}/{version}/rollback").Methods(http.MethodPost).HandlerFunc(a.RollbackNamespace)
+
+	listVersions := func() []namespaceVersion {
+		req := requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules-history/namespace1", nil, "user1")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var versions []namespaceVersion
+		require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &versions))
+		return versions
+	}
+	expectedRules := "namespace1:\n    - name: group1\n      interval: 1m\n      rules:\n        - record: UP_RULE\n          expr: up\n"
+
+	// Accidentally delete the namespace created before the history was enabled.
+	req := requestFor(t, http.MethodDelete, "https://localhost:8080/prometheus/config/v1/rules/namespace1", nil, "user1")
+	req.Header.Set(RuleGroupsAuthorHeader, "alice")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	versions := listVersions()
+	require.Len(t, versions, 2)
+	assert.Equal(t, "alice", versions[0].Author)
+	assert.Empty(t, versions[0].RuleGroups)
+	assert.Equal(t, "", versions[1].Author)
+	assert.Equal(t, []string{"group1"}, versions[1].RuleGroups)
+
+	req = requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules-history/namespace1/"+versions[1].Version, nil, "user1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, expectedRules, w.Body.String())
+
+	// Roll back the namespace to the version before the deletion.
+	req = requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules-history/namespace1/"+versions[1].Version+"/rollback", nil, "user1")
+	req.Header.Set(RuleGroupsAuthorHeader, "bob")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	req = requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules/namespace1", nil, "user1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, expectedRules, w.Body.String())
+
+	versions = listVersions()
+	require.Len(t, versions, 3)
+	assert.Equal(t, "bob", versions[0].Author)
+	assert.Equal(t, []string{"group1"}, versions[0].RuleGroups)
+
+	// Unknown versions are not found.
+	req = requestFor(t, http.MethodPost, "https://localhost:8080/prometheus/config/v1/rules-history/namespace1/1/rollback", nil, "user1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	// The history is disabled when no version is kept.
+	r.cfg.RuleGroupsHistoryMaxVersions = 0
+	req = requestFor(t, http.MethodGet, "https://localhost:8080/prometheus/config/v1/rules-history/namespace1", nil, "user1")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotImplemented, w.Code)
+	require.Equal(t, ErrRuleGroupsHistoryDisabled.Error()+"\n", w.Body.String())
+}
+
 func TestRuler_LimitsPerGroup(t *testing.T) {
 	cfg := defaultRulerConfig(t)
 
@@ -490,7 +797,7 @@ func TestRuler_LimitsPerGroup(t *testing.T) {
 		defaults.RulerMaxRulesPerRuleGroup = 1
 	})))
 
-	a := NewAPI(r, r.store, log.NewNopLogger())
+	a := NewAPI(r, r.store, nil, log.NewNopLogger())
 
 	tc := []struct {
 		name   string
@@ -543,7 +850,7 @@ func TestRuler_RulerGroupLimits(t *testing.T) {
 		defaults.RulerMaxRulesPerRuleGroup = 1
 	})))
 
-	a := NewAPI(r, r.store, log.NewNopLogger())
+	a := NewAPI(r, r.store, nil, log.NewNopLogger())
 
 	tc := []struct {
 		name   string
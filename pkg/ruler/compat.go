@@ -127,6 +127,8 @@ type RulesLimits interface {
 	RulerMaxRulesPerRuleGroup(userID string) int
 	RulerRecordingRulesEvaluationEnabled(userID string) bool
 	RulerAlertingRulesEvaluationEnabled(userID string) bool
+	RulerMaxConcurrentEvaluations(userID string) int
+	RulerRuleGroupEvaluationDeadline(userID string) time.Duration
 }
 
 func MetricsQueryFunc(qf rules.QueryFunc, queries, failedQueries prometheus.Counter) rules.QueryFunc {
@@ -167,6 +169,46 @@ func MetricsQueryFunc(qf rules.QueryFunc, queries, failedQueries prometheus.Coun
 	}
 }
 
+const (
+	evaluationFailureReasonQuery = "query"
+	evaluationFailureReasonWrite = "write"
+)
+
+// FailuresQueryFunc counts the failed queries as rule evaluation failures caused by the query.
+func FailuresQueryFunc(qf rules.QueryFunc, failures prometheus.Counter) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		result, err := qf(ctx, qs, t)
+		if err != nil {
+			failures.Inc()
+		}
+		return result, err
+	}
+}
+
+// failuresAppendable wraps a storage.Appendable, counting the failed commits as rule evaluation failures
+// caused by the write of the results.
+type failuresAppendable struct {
+	storage.Appendable
+	failures prometheus.Counter
+}
+
+func (a failuresAppendable) Appender(ctx context.Context) storage.Appender {
+	return failuresAppender{Appender: a.Appendable.Appender(ctx), failures: a.failures}
+}
+
+type failuresAppender struct {
+	storage.Appender
+	failures prometheus.Counter
+}
+
+func (a failuresAppender) Commit() error {
+	err := a.Appender.Commit()
+	if err != nil {
+		a.failures.Inc()
+	}
+	return err
+}
+
 func RecordAndReportRuleQueryMetrics(qf rules.QueryFunc, queryTime prometheus.Counter, logger log.Logger) rules.QueryFunc {
 	if queryTime == nil {
 		return qf
@@ -265,17 +307,35 @@ func DefaultTenantManagerFactory(
 		if rulerQuerySeconds != nil {
 			queryTime = rulerQuerySeconds.WithLabelValues(userID)
 		}
+		// Unlike the Prometheus rule evaluation failures, these are split by whether the query or the write failed.
+		evaluationFailures := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "ruler_evaluation_failures_total",
+			Help: "The total number of rule evaluation failures, by reason: the query of the rule failed, or the write of its results.",
+		}, []string{"reason"})
+
 		var wrappedQueryFunc rules.QueryFunc
 
 		wrappedQueryFunc = MetricsQueryFunc(queryFunc, totalQueries, failedQueries)
+		wrappedQueryFunc = FailuresQueryFunc(wrappedQueryFunc, evaluationFailures.WithLabelValues(evaluationFailureReasonQuery))
 		wrappedQueryFunc = RecordAndReportRuleQueryMetrics(wrappedQueryFunc, queryTime, logger)
+		wrappedQueryFunc = ruleGroupEvaluationQueryFunc(
+			wrappedQueryFunc,
+			newEvaluationConcurrencyLimiter(func() int { return overrides.RulerMaxConcurrentEvaluations(userID) }),
+			func() time.Duration { return overrides.RulerRuleGroupEvaluationDeadline(userID) },
+			newRuleGroupEvaluationMetrics(reg),
+		)
+
+		appendable := failuresAppendable{
+			Appendable: NewPusherAppendable(p, userID, overrides, totalWrites, failedWrites),
+			failures:   evaluationFailures.WithLabelValues(evaluationFailureReasonWrite),
+		}
 
 		return rules.NewManager(&rules.ManagerOptions{
-			Appendable:                 NewPusherAppendable(p, userID, overrides, totalWrites, failedWrites),
+			Appendable:                 appendable,
 			Queryable:                  embeddedQueryable,
 			QueryFunc:                  wrappedQueryFunc,
 			Context:                    user.InjectOrgID(ctx, userID),
-			GroupEvaluationContextFunc: FederatedGroupContextFunc,
+			GroupEvaluationContextFunc: ruleGroupEvaluationContextFunc,
 			ExternalURL:                cfg.ExternalURL.URL,
 			NotifyFunc:                 rules.SendAlerts(notifier, cfg.ExternalURL.String()),
 			Logger:                     log.With(logger, "user", userID),
@@ -241,6 +241,71 @@ func TestMetricsQueryFuncErrors(t *testing.T) {
 	}
 }
 
+func TestFailuresQueryFunc(t *testing.T) {
+	failures := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+
+	var returnedError error
+	mockFunc := func(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
+		return promql.Vector{}, returnedError
+	}
+	qf := FailuresQueryFunc(mockFunc, failures)
+
+	_, err := qf(context.Background(), "test", time.Now())
+	require.NoError(t, err)
+	require.Equal(t, 0, int(testutil.ToFloat64(failures)))
+
+	// Any error is a failure of the evaluation, including the client ones.
+	returnedError = httpgrpc.Errorf(http.StatusBadRequest, "test error")
+	_, err = qf(context.Background(), "test", time.Now())
+	require.Equal(t, returnedError, err)
+	require.Equal(t, 1, int(testutil.ToFloat64(failures)))
+}
+
+func TestFailuresAppendable(t *testing.T) {
+	for name, tc := range map[string]struct {
+		returnedError    error
+		expectedFailures int
+	}{
+		"no error": {
+			expectedFailures: 0,
+		},
+		"400 error": {
+			returnedError:    httpgrpc.Errorf(http.StatusBadRequest, "test error"),
+			expectedFailures: 1,
+		},
+		"500 error": {
+			returnedError:    httpgrpc.Errorf(http.StatusInternalServerError, "test error"),
+			expectedFailures: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			pusher := &fakePusher{err: tc.returnedError, response: &mimirpb.WriteResponse{}}
+
+			writes := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+			failedWrites := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+			failures := promauto.With(nil).NewCounter(prometheus.CounterOpts{})
+			limits := validation.MockOverrides(func(defaults *validation.Limits, _ map[string]*validation.Limits) {
+				defaults.RulerEvaluationDelay = 0
+			})
+
+			fa := failuresAppendable{
+				Appendable: NewPusherAppendable(pusher, "user-1", limits, writes, failedWrites),
+				failures:   failures,
+			}
+
+			lbls, err := parser.ParseMetric("foo_bar")
+			require.NoError(t, err)
+
+			a := fa.Appender(context.Background())
+			_, err = a.Append(0, lbls, int64(model.Now()), 123456)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.returnedError, a.Commit())
+			require.Equal(t, tc.expectedFailures, int(testutil.ToFloat64(failures)))
+		})
+	}
+}
+
 func TestRecordAndReportRuleQueryMetrics(t *testing.T) {
 	queryTime := promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"})
 
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+)
+
+// dryRunResult has the results of the dry-run evaluation of a rule group.
+type dryRunResult struct {
+	Name string `json:"name"`
+	// EvaluationTimestamp is the timestamp the rules have been evaluated at, taking in account the evaluation delay.
+	EvaluationTimestamp time.Time    `json:"evaluationTimestamp"`
+	Rules               []dryRunRule `json:"rules"`
+}
+
+type dryRunRule struct {
+	Name  string      `json:"name"`
+	Query string      `json:"query"`
+	Type  v1.RuleType `json:"type"`
+	// Series are the series resulting from the evaluation of a recording rule, or the ALERTS series of an alerting rule.
+	Series []*dryRunSeries `json:"series"`
+	// Alerts are the alerts of an alerting rule. The alerts of a rule with a `for` duration are pending.
+	Alerts    []*Alert `json:"alerts,omitempty"`
+	Health    string   `json:"health"`
+	LastError string   `json:"lastError"`
+}
+
+// dryRunSeries is a series resulting from the dry-run evaluation of a rule.
+type dryRunSeries struct {
+	Labels labels.Labels `json:"labels"`
+	Value  string        `json:"value"`
+}
+
+// dryRunRuleGroup evaluates the rules of the rule group once at ts, the same way the ruler evaluates them,
+// without writing the resulting series and sending the alerts. The rules are evaluated independently from
+// each other, so the rules using the series recorded by the previous rules of the group see the previous
+// evaluations of these rules, if any.
+func dryRunRuleGroup(ctx context.Context, rg rulefmt.RuleGroup, queryFunc rules.QueryFunc, ts time.Time, evaluationDelay time.Duration, externalURL *url.URL, logger log.Logger) *dryRunResult {
+	if rg.EvaluationDelay != nil {
+		evaluationDelay = time.Duration(*rg.EvaluationDelay)
+	}
+	if len(rg.SourceTenants) > 0 {
+		ctx = context.WithValue(ctx, federatedGroupSourceTenants, rg.SourceTenants)
+	}
+	var externalURLString string
+	if externalURL != nil {
+		externalURLString = externalURL.String()
+	}
+
+	result := &dryRunResult{
+		Name:                rg.Name,
+		EvaluationTimestamp: ts.Add(-evaluationDelay),
+		Rules:               make([]dryRunRule, 0, len(rg.Rules)),
+	}
+
+	for _, rn := range rg.Rules {
+		r := dryRunRule{
+			Query:  rn.Expr.Value,
+			Series: []*dryRunSeries{},
+			Health: string(rules.HealthGood),
+		}
+
+		// The rule group has been validated, so the expression can be parsed.
+		expr, err := parser.ParseExpr(rn.Expr.Value)
+		if err != nil {
+			r.Health = string(rules.HealthBad)
+			r.LastError = err.Error()
+			result.Rules = append(result.Rules, r)
+			continue
+		}
+
+		var promRule rules.Rule
+		var alerting *rules.AlertingRule
+		if rn.Alert.Value != "" {
+			r.Name = rn.Alert.Value
+			r.Type = v1.RuleTypeAlerting
+			alerting = rules.NewAlertingRule(rn.Alert.Value, expr, time.Duration(rn.For), labels.FromMap(rn.Labels), labels.FromMap(rn.Annotations), nil, externalURLString, true, logger)
+			promRule = alerting
+		} else {
+			r.Name = rn.Record.Value
+			r.Type = v1.RuleTypeRecording
+			promRule = rules.NewRecordingRule(rn.Record.Value, expr, labels.FromMap(rn.Labels))
+		}
+
+		vector, err := promRule.Eval(ctx, evaluationDelay, ts, queryFunc, externalURL, rg.Limit)
+		if err != nil {
+			r.Health = string(rules.HealthBad)
+			r.LastError = err.Error()
+			result.Rules = append(result.Rules, r)
+			continue
+		}
+
+		for _, s := range vector {
+			r.Series = append(r.Series, &dryRunSeries{
+				Labels: s.Metric,
+				Value:  strconv.FormatFloat(s.V, 'e', -1, 64),
+			})
+		}
+		if alerting != nil {
+			for _, a := range alerting.ActiveAlerts() {
+				activeAt := a.ActiveAt
+				r.Alerts = append(r.Alerts, &Alert{
+					Labels:      a.Labels,
+					Annotations: a.Annotations,
+					State:       a.State.String(),
+					ActiveAt:    &activeAt,
+					Value:       strconv.FormatFloat(a.Value, 'e', -1, 64),
+				})
+			}
+		}
+
+		result.Rules = append(result.Rules, r)
+	}
+
+	return result
+}
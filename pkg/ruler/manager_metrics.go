@@ -27,6 +27,10 @@ type ManagerMetrics struct {
 	GroupLastDuration    *prometheus.Desc
 	GroupRules           *prometheus.Desc
 	GroupLastEvalSamples *prometheus.Desc
+	EvalFailuresByCause  *prometheus.Desc
+	GroupEvalsLate       *prometheus.Desc
+	RuleEvalsSkipped     *prometheus.Desc
+	RuleEvalsThrottled   *prometheus.Desc
 }
 
 // NewManagerMetrics returns a ManagerMetrics struct
@@ -100,6 +104,30 @@ func NewManagerMetrics() *ManagerMetrics {
 			[]string{"user", "rule_group"},
 			nil,
 		),
+		EvalFailuresByCause: prometheus.NewDesc(
+			"cortex_ruler_evaluation_failures_total",
+			"The total number of rule evaluation failures, by reason: the query of the rule failed, or the write of its results.",
+			[]string{"user", "reason"},
+			nil,
+		),
+		GroupEvalsLate: prometheus.NewDesc(
+			"cortex_ruler_rule_group_evaluations_late_total",
+			"The total number of rule group evaluations which exceeded their deadline.",
+			[]string{"user"},
+			nil,
+		),
+		RuleEvalsSkipped: prometheus.NewDesc(
+			"cortex_ruler_rule_evaluations_skipped_total",
+			"The total number of rule evaluations skipped because the evaluation of their rule group exceeded its deadline.",
+			[]string{"user"},
+			nil,
+		),
+		RuleEvalsThrottled: prometheus.NewDesc(
+			"cortex_ruler_rule_evaluations_throttled_total",
+			"The total number of rule evaluations delayed because the tenant reached the maximum number of concurrent rule group evaluations.",
+			[]string{"user"},
+			nil,
+		),
 	}
 }
 
@@ -126,6 +154,10 @@ func (m *ManagerMetrics) Describe(out chan<- *prometheus.Desc) {
 	out <- m.GroupLastDuration
 	out <- m.GroupRules
 	out <- m.GroupLastEvalSamples
+	out <- m.EvalFailuresByCause
+	out <- m.GroupEvalsLate
+	out <- m.RuleEvalsSkipped
+	out <- m.RuleEvalsThrottled
 }
 
 // Collect implements the Collector interface
@@ -148,4 +180,8 @@ func (m *ManagerMetrics) Collect(out chan<- prometheus.Metric) {
 	data.SendSumOfGaugesPerUserWithLabels(out, m.GroupLastDuration, "prometheus_rule_group_last_duration_seconds", "rule_group")
 	data.SendSumOfGaugesPerUserWithLabels(out, m.GroupRules, "prometheus_rule_group_rules", "rule_group")
 	data.SendSumOfGaugesPerUserWithLabels(out, m.GroupLastEvalSamples, "prometheus_rule_group_last_evaluation_samples", "rule_group")
+	data.SendSumOfCountersPerUser(out, m.EvalFailuresByCause, "ruler_evaluation_failures_total", util.WithLabels("reason"))
+	data.SendSumOfCountersPerUser(out, m.GroupEvalsLate, "ruler_rule_group_evaluations_late_total")
+	data.SendSumOfCountersPerUser(out, m.RuleEvalsSkipped, "ruler_rule_evaluations_skipped_total")
+	data.SendSumOfCountersPerUser(out, m.RuleEvalsThrottled, "ruler_rule_evaluations_throttled_total")
 }
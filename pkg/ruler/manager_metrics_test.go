@@ -135,6 +135,29 @@ cortex_prometheus_rule_group_rules{rule_group="group_one",user="user3"} 100000
 cortex_prometheus_rule_group_rules{rule_group="group_two",user="user1"} 1000
 cortex_prometheus_rule_group_rules{rule_group="group_two",user="user2"} 10000
 cortex_prometheus_rule_group_rules{rule_group="group_two",user="user3"} 100000
+# HELP cortex_ruler_evaluation_failures_total The total number of rule evaluation failures, by reason: the query of the rule failed, or the write of its results.
+# TYPE cortex_ruler_evaluation_failures_total counter
+cortex_ruler_evaluation_failures_total{reason="query",user="user1"} 1
+cortex_ruler_evaluation_failures_total{reason="query",user="user2"} 10
+cortex_ruler_evaluation_failures_total{reason="query",user="user3"} 100
+cortex_ruler_evaluation_failures_total{reason="write",user="user1"} 2
+cortex_ruler_evaluation_failures_total{reason="write",user="user2"} 20
+cortex_ruler_evaluation_failures_total{reason="write",user="user3"} 200
+# HELP cortex_ruler_rule_evaluations_skipped_total The total number of rule evaluations skipped because the evaluation of their rule group exceeded its deadline.
+# TYPE cortex_ruler_rule_evaluations_skipped_total counter
+cortex_ruler_rule_evaluations_skipped_total{user="user1"} 2
+cortex_ruler_rule_evaluations_skipped_total{user="user2"} 20
+cortex_ruler_rule_evaluations_skipped_total{user="user3"} 200
+# HELP cortex_ruler_rule_evaluations_throttled_total The total number of rule evaluations delayed because the tenant reached the maximum number of concurrent rule group evaluations.
+# TYPE cortex_ruler_rule_evaluations_throttled_total counter
+cortex_ruler_rule_evaluations_throttled_total{user="user1"} 3
+cortex_ruler_rule_evaluations_throttled_total{user="user2"} 30
+cortex_ruler_rule_evaluations_throttled_total{user="user3"} 300
+# HELP cortex_ruler_rule_group_evaluations_late_total The total number of rule group evaluations which exceeded their deadline.
+# TYPE cortex_ruler_rule_group_evaluations_late_total counter
+cortex_ruler_rule_group_evaluations_late_total{user="user1"} 1
+cortex_ruler_rule_group_evaluations_late_total{user="user2"} 10
+cortex_ruler_rule_group_evaluations_late_total{user="user3"} 100
 `))
 	require.NoError(t, err)
 }
@@ -168,6 +191,18 @@ func populateManager(base float64) *prometheus.Registry {
 	metrics.groupLastEvalSamples.WithLabelValues("group_one").Add(base * 1000)
 	metrics.groupLastEvalSamples.WithLabelValues("group_two").Add(base * 1000)
 
+	evaluationFailures := promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+		Name: "ruler_evaluation_failures_total",
+		Help: "The total number of rule evaluation failures, by reason: the query of the rule failed, or the write of its results.",
+	}, []string{"reason"})
+	evaluationFailures.WithLabelValues(evaluationFailureReasonQuery).Add(base)
+	evaluationFailures.WithLabelValues(evaluationFailureReasonWrite).Add(base * 2)
+
+	evaluationMetrics := newRuleGroupEvaluationMetrics(r)
+	evaluationMetrics.lateEvaluations.Add(base)
+	evaluationMetrics.skippedEvaluations.Add(base * 2)
+	evaluationMetrics.throttledEvaluations.Add(base * 3)
+
 	return r
 }
 
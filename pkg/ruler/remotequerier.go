@@ -46,8 +46,6 @@ const (
 	mimeTypeFormPost = "application/x-www-form-urlencoded"
 
 	statusError = "error"
-
-	maxRequestRetries = 3
 )
 
 var userAgent = fmt.Sprintf("mimir/%s", version.Version)
@@ -59,6 +57,11 @@ type QueryFrontendConfig struct {
 
 	// GRPCClientConfig contains gRPC specific config options.
 	GRPCClientConfig grpcclient.Config `yaml:"grpc_client_config" doc:"description=Configures the gRPC client used to communicate between the rulers and query-frontends."`
+
+	Timeout    time.Duration `yaml:"timeout" category:"experimental"`
+	MaxRetries int           `yaml:"max_retries" category:"experimental"`
+	MinBackoff time.Duration `yaml:"retry_min_backoff" category:"experimental"`
+	MaxBackoff time.Duration `yaml:"retry_max_backoff" category:"experimental"`
 }
 
 func (c *QueryFrontendConfig) RegisterFlags(f *flag.FlagSet) {
@@ -69,6 +72,20 @@ func (c *QueryFrontendConfig) RegisterFlags(f *flag.FlagSet) {
 			"to enable client side load balancing.")
 
 	c.GRPCClientConfig.RegisterFlagsWithPrefix("ruler.query-frontend.grpc-client-config", f)
+
+	f.DurationVar(&c.Timeout, "ruler.query-frontend.timeout", 0, "Timeout of the queries sent to the query-frontend to evaluate the rules. The queries are also canceled when the evaluation of their rule group exceeds its deadline, see -ruler.rule-group-evaluation-deadline. 0 to use -querier.timeout.")
+	f.IntVar(&c.MaxRetries, "ruler.query-frontend.max-retries", 3, "Maximum number of times a query sent to the query-frontend is retried on transient errors. The queries failed with a 4xx status code are not retried. 0 to disable retries.")
+	f.DurationVar(&c.MinBackoff, "ruler.query-frontend.retry-min-backoff", 100*time.Millisecond, "Minimum delay before retrying a query sent to the query-frontend.")
+	f.DurationVar(&c.MaxBackoff, "ruler.query-frontend.retry-max-backoff", 2*time.Second, "Maximum delay before retrying a query sent to the query-frontend.")
+}
+
+// RetryConfig returns the backoff config used to retry the queries sent to the query-frontend.
+func (c *QueryFrontendConfig) RetryConfig() backoff.Config {
+	return backoff.Config{
+		MinBackoff: c.MinBackoff,
+		MaxBackoff: c.MaxBackoff,
+		MaxRetries: c.MaxRetries,
+	}
 }
 
 // DialQueryFrontend creates and initializes a new httpgrpc.HTTPClient taking a QueryFrontendConfig configuration.
@@ -96,6 +113,7 @@ type Middleware func(ctx context.Context, req *httpgrpc.HTTPRequest) error
 type RemoteQuerier struct {
 	client         httpgrpc.HTTPClient
 	timeout        time.Duration
+	retryConfig    backoff.Config
 	middlewares    []Middleware
 	promHTTPPrefix string
 	logger         log.Logger
@@ -105,6 +123,7 @@ type RemoteQuerier struct {
 func NewRemoteQuerier(
 	client httpgrpc.HTTPClient,
 	timeout time.Duration,
+	retryConfig backoff.Config,
 	prometheusHTTPPrefix string,
 	logger log.Logger,
 	middlewares ...Middleware,
@@ -112,6 +131,7 @@ func NewRemoteQuerier(
 	return &RemoteQuerier{
 		client:         client,
 		timeout:        timeout,
+		retryConfig:    retryConfig,
 		middlewares:    middlewares,
 		promHTTPPrefix: prometheusHTTPPrefix,
 		logger:         logger,
@@ -259,19 +279,18 @@ func (q *RemoteQuerier) query(ctx context.Context, query string, ts time.Time, l
 func (q *RemoteQuerier) sendRequest(ctx context.Context, req *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
 	// Ongoing request may be cancelled during evaluation due to some transient error or server shutdown,
 	// so we'll keep retrying until we get a successful response or backoff is terminated.
-	retryConfig := backoff.Config{
-		MinBackoff: 100 * time.Millisecond,
-		MaxBackoff: 2 * time.Second,
-		MaxRetries: maxRequestRetries,
-	}
-	retry := backoff.New(ctx, retryConfig)
+	retry := backoff.New(ctx, q.retryConfig)
 
 	for {
 		resp, err := q.client.Handle(ctx, req)
 		if err == nil {
 			return resp, nil
 		}
-		if !retry.Ongoing() {
+		// The requests failed because of the query itself, like a bad or too expensive query, fail the same on retry.
+		if resp, ok := httpgrpc.HTTPResponseFromError(err); ok && resp.Code/100 == 4 {
+			return nil, err
+		}
+		if q.retryConfig.MaxRetries <= 0 || !retry.Ongoing() {
 			return nil, err
 		}
 		level.Warn(q.logger).Log("msg", "failed to remotely evaluate query expression, will retry", "err", err)
@@ -14,6 +14,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/status"
 	"github.com/golang/snappy"
+	"github.com/grafana/dskit/backoff"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/httpgrpc"
@@ -21,6 +22,12 @@ import (
 	"google.golang.org/grpc/codes"
 )
 
+var testRetryConfig = backoff.Config{
+	MinBackoff: 100 * time.Millisecond,
+	MaxBackoff: 2 * time.Second,
+	MaxRetries: 3,
+}
+
 type mockHTTPGRPCClient func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error)
 
 func (c mockHTTPGRPCClient) Handle(ctx context.Context, req *httpgrpc.HTTPRequest, opts ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
@@ -47,7 +54,7 @@ func TestRemoteQuerier_ReadReq(t *testing.T) {
 			Body: snappy.Encode(nil, b),
 		}, nil
 	}
-	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, "/prometheus", log.NewNopLogger())
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, testRetryConfig, "/prometheus", log.NewNopLogger())
 
 	_, err := q.Read(context.Background(), &prompb.Query{})
 	require.NoError(t, err)
@@ -63,7 +70,7 @@ func TestRemoteQuerier_ReadReqTimeout(t *testing.T) {
 		<-ctx.Done()
 		return nil, ctx.Err()
 	}
-	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Second, "/prometheus", log.NewNopLogger())
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Second, testRetryConfig, "/prometheus", log.NewNopLogger())
 
 	_, err := q.Read(context.Background(), &prompb.Query{})
 	require.Error(t, err)
@@ -77,7 +84,7 @@ func TestRemoteQuerier_QueryReq(t *testing.T) {
 							"status": "success","data": {"resultType":"vector","result":[]}
 						}`)}, nil
 	}
-	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, "/prometheus", log.NewNopLogger())
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, testRetryConfig, "/prometheus", log.NewNopLogger())
 
 	tm := time.Unix(1649092025, 515834)
 	_, err := q.Query(context.Background(), "qs", tm)
@@ -94,7 +101,7 @@ func TestRemoteQuerier_QueryReqTimeout(t *testing.T) {
 		<-ctx.Done()
 		return nil, ctx.Err()
 	}
-	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Second, "/prometheus", log.NewNopLogger())
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Second, testRetryConfig, "/prometheus", log.NewNopLogger())
 
 	tm := time.Unix(1649092025, 515834)
 	_, err := q.Query(context.Background(), "qs", tm)
@@ -108,10 +115,10 @@ func TestRemoteQuerier_BackoffRetry(t *testing.T) {
 		requestDeadline time.Duration
 	}{
 		"succeed on failed requests <= max retries": {
-			failedRequests: maxRequestRetries,
+			failedRequests: testRetryConfig.MaxRetries,
 		},
 		"fail on failed requests > max retries": {
-			failedRequests: maxRequestRetries + 1,
+			failedRequests: testRetryConfig.MaxRetries + 1,
 			expectedError:  "failed request: 4",
 		},
 		"return last known error on context cancellation": {
@@ -132,7 +139,7 @@ func TestRemoteQuerier_BackoffRetry(t *testing.T) {
 							"status": "success","data": {"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[1,"773054.5916666666"]}]}
 						}`)}, nil
 			}
-			q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, "/prometheus", log.NewNopLogger())
+			q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, testRetryConfig, "/prometheus", log.NewNopLogger())
 
 			ctx := context.Background()
 			if tc.requestDeadline > 0 {
@@ -158,7 +165,7 @@ func TestRemoteQuerier_StatusErrorResponses(t *testing.T) {
 							"status": "error","errorType": "execution"
 						}`)}, nil
 	}
-	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, "/prometheus", log.NewNopLogger())
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, testRetryConfig, "/prometheus", log.NewNopLogger())
 
 	tm := time.Unix(1649092025, 515834)
 
@@ -171,3 +178,56 @@ func TestRemoteQuerier_StatusErrorResponses(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, codes.Code(http.StatusUnprocessableEntity), st.Code())
 }
+
+func TestRemoteQuerier_ShouldNotRetryClientErrors(t *testing.T) {
+	requests := 0
+	mockClientFn := func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+		requests++
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "bad query")
+	}
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, testRetryConfig, "/prometheus", log.NewNopLogger())
+
+	_, err := q.Query(context.Background(), "qs", time.Now())
+	require.Error(t, err)
+	require.Equal(t, 1, requests)
+}
+
+func TestRemoteQuerier_ShouldNotRetryWhenRetriesAreDisabled(t *testing.T) {
+	requests := 0
+	mockClientFn := func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+		requests++
+		return nil, fmt.Errorf("failed request: %d", requests)
+	}
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, backoff.Config{}, "/prometheus", log.NewNopLogger())
+
+	_, err := q.Query(context.Background(), "qs", time.Now())
+	require.EqualError(t, err, "failed request: 1")
+	require.Equal(t, 1, requests)
+}
+
+func TestRemoteQuerier_TimeoutCappedByContextDeadline(t *testing.T) {
+	var requestDeadline time.Time
+	mockClientFn := func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+		requestDeadline, _ = ctx.Deadline()
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, "bad query")
+	}
+	q := NewRemoteQuerier(mockHTTPGRPCClient(mockClientFn), time.Minute, testRetryConfig, "/prometheus", log.NewNopLogger())
+
+	// The deadline of the rule group evaluation applies when it's shorter than the configured timeout.
+	groupDeadline := time.Now().Add(10 * time.Second)
+	ctx, cancel := context.WithDeadline(context.Background(), groupDeadline)
+	defer cancel()
+
+	_, err := q.Query(ctx, "qs", time.Now())
+	require.Error(t, err)
+	require.Equal(t, groupDeadline, requestDeadline)
+
+	// The configured timeout applies when it's shorter than the rule group evaluation deadline.
+	groupDeadline = time.Now().Add(time.Hour)
+	ctx, cancel = context.WithDeadline(context.Background(), groupDeadline)
+	defer cancel()
+
+	_, err = q.Query(ctx, "qs", time.Now())
+	require.Error(t, err)
+	require.True(t, requestDeadline.Before(groupDeadline))
+}
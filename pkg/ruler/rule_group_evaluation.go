@@ -0,0 +1,184 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+)
+
+const ruleGroupEvaluationKey contextKey = 2
+
+var errRuleGroupEvaluationDeadlineExceeded = errors.New("the rule group evaluation exceeded its deadline, the evaluation of the rule has been skipped")
+
+// ruleGroupEvaluation holds the state shared by the evaluations of the rules of a rule group.
+type ruleGroupEvaluation struct {
+	interval        time.Duration
+	evaluationDelay func() time.Duration
+
+	mtx sync.Mutex
+	// lastLateEvaluation is the query timestamp of the last evaluation which exceeded its deadline.
+	lastLateEvaluation time.Time
+}
+
+// deadline returns the deadline of the rule group evaluation whose rules are queried at ts.
+// The deadline can't be past the next evaluation of the rule group.
+func (e *ruleGroupEvaluation) deadline(ts time.Time, maxDuration time.Duration) time.Time {
+	duration := e.interval
+	if maxDuration > 0 && maxDuration < duration {
+		duration = maxDuration
+	}
+
+	// The rules are queried at the evaluation timestamp minus the evaluation delay.
+	return ts.Add(e.evaluationDelay()).Add(duration)
+}
+
+// markLate records that the rule group evaluation whose rules are queried at ts exceeded its deadline.
+// It returns false if the evaluation has already been recorded.
+func (e *ruleGroupEvaluation) markLate(ts time.Time) bool {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.lastLateEvaluation.Equal(ts) {
+		return false
+	}
+	e.lastLateEvaluation = ts
+	return true
+}
+
+// ruleGroupEvaluationContextFunc prepares the context for the evaluation of a rule group. On top of
+// FederatedGroupContextFunc, it adds the state used to enforce the deadline of the rule group evaluations.
+func ruleGroupEvaluationContextFunc(ctx context.Context, g *rules.Group) context.Context {
+	ctx = FederatedGroupContextFunc(ctx, g)
+	return context.WithValue(ctx, ruleGroupEvaluationKey, &ruleGroupEvaluation{
+		interval:        g.Interval(),
+		evaluationDelay: g.EvaluationDelay,
+	})
+}
+
+func ruleGroupEvaluationFromContext(ctx context.Context) (*ruleGroupEvaluation, bool) {
+	evaluation, ok := ctx.Value(ruleGroupEvaluationKey).(*ruleGroupEvaluation)
+	return evaluation, ok
+}
+
+// evaluationConcurrencyLimiter limits the number of concurrent rule evaluations of a tenant. The rules of a
+// rule group are evaluated sequentially, so this limits the number of rule groups concurrently evaluated.
+type evaluationConcurrencyLimiter struct {
+	limit func() int
+
+	mtx      sync.Mutex
+	inflight int
+	// released is closed, and replaced, each time an evaluation completes.
+	released chan struct{}
+}
+
+func newEvaluationConcurrencyLimiter(limit func() int) *evaluationConcurrencyLimiter {
+	return &evaluationConcurrencyLimiter{
+		limit:    limit,
+		released: make(chan struct{}),
+	}
+}
+
+// acquire waits until the evaluation is allowed to run, or the context is done. It returns whether
+// the evaluation had to wait because the limit was reached.
+func (l *evaluationConcurrencyLimiter) acquire(ctx context.Context) (throttled bool, _ error) {
+	for {
+		l.mtx.Lock()
+		if limit := l.limit(); limit <= 0 || l.inflight < limit {
+			l.inflight++
+			l.mtx.Unlock()
+			return throttled, nil
+		}
+		released := l.released
+		l.mtx.Unlock()
+
+		throttled = true
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return throttled, ctx.Err()
+		}
+	}
+}
+
+func (l *evaluationConcurrencyLimiter) release() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.inflight--
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+type ruleGroupEvaluationMetrics struct {
+	lateEvaluations      prometheus.Counter
+	skippedEvaluations   prometheus.Counter
+	throttledEvaluations prometheus.Counter
+}
+
+func newRuleGroupEvaluationMetrics(reg prometheus.Registerer) *ruleGroupEvaluationMetrics {
+	return &ruleGroupEvaluationMetrics{
+		lateEvaluations: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "ruler_rule_group_evaluations_late_total",
+			Help: "The total number of rule group evaluations which exceeded their deadline.",
+		}),
+		skippedEvaluations: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "ruler_rule_evaluations_skipped_total",
+			Help: "The total number of rule evaluations skipped because the evaluation of their rule group exceeded its deadline.",
+		}),
+		throttledEvaluations: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "ruler_rule_evaluations_throttled_total",
+			Help: "The total number of rule evaluations delayed because the tenant reached the maximum number of concurrent rule group evaluations.",
+		}),
+	}
+}
+
+// ruleGroupEvaluationQueryFunc enforces the deadline of the rule group evaluations, skipping the evaluation
+// of the remaining rules of a rule group once its deadline is exceeded, and the maximum number of concurrent
+// rule group evaluations of the tenant.
+func ruleGroupEvaluationQueryFunc(qf rules.QueryFunc, limiter *evaluationConcurrencyLimiter, maxDuration func() time.Duration, metrics *ruleGroupEvaluationMetrics) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		evaluation, ok := ruleGroupEvaluationFromContext(ctx)
+		if !ok {
+			return qf(ctx, qs, t)
+		}
+
+		skip := func() (promql.Vector, error) {
+			if evaluation.markLate(t) {
+				metrics.lateEvaluations.Inc()
+			}
+			metrics.skippedEvaluations.Inc()
+			return nil, errRuleGroupEvaluationDeadlineExceeded
+		}
+
+		deadline := evaluation.deadline(t, maxDuration())
+		if !time.Now().Before(deadline) {
+			return skip()
+		}
+
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+
+		throttled, err := limiter.acquire(ctx)
+		if throttled {
+			metrics.throttledEvaluations.Inc()
+		}
+		if err != nil {
+			return skip()
+		}
+		defer limiter.release()
+
+		result, err := qf(ctx, qs, t)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) && evaluation.markLate(t) {
+			metrics.lateEvaluations.Inc()
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleGroupEvaluation_Deadline(t *testing.T) {
+	ts := time.Unix(1000, 0)
+	evaluation := &ruleGroupEvaluation{
+		interval:        time.Minute,
+		evaluationDelay: func() time.Duration { return 10 * time.Second },
+	}
+
+	assert.Equal(t, ts.Add(10*time.Second).Add(time.Minute), evaluation.deadline(ts, 0))
+	assert.Equal(t, ts.Add(10*time.Second).Add(30*time.Second), evaluation.deadline(ts, 30*time.Second))
+
+	// The deadline can't be past the next evaluation of the rule group.
+	assert.Equal(t, ts.Add(10*time.Second).Add(time.Minute), evaluation.deadline(ts, time.Hour))
+}
+
+func TestEvaluationConcurrencyLimiter(t *testing.T) {
+	limit := 2
+	l := newEvaluationConcurrencyLimiter(func() int { return limit })
+
+	for i := 0; i < 2; i++ {
+		throttled, err := l.acquire(context.Background())
+		require.NoError(t, err)
+		assert.False(t, throttled)
+	}
+
+	// The limit is reached, so the evaluation waits until the context is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	throttled, err := l.acquire(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, throttled)
+
+	// The waiting evaluation runs once another evaluation completes.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		throttled, err := l.acquire(context.Background())
+		assert.NoError(t, err)
+		assert.True(t, throttled)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	l.release()
+	<-done
+
+	// The limit is disabled with 0.
+	limit = 0
+	throttled, err = l.acquire(context.Background())
+	require.NoError(t, err)
+	assert.False(t, throttled)
+}
+
+func TestRuleGroupEvaluationQueryFunc(t *testing.T) {
+	newQueryFunc := func(limit int, maxDuration time.Duration, queryDuration time.Duration) (*prometheus.Registry, func(ctx context.Context, ts time.Time) error) {
+		reg := prometheus.NewPedanticRegistry()
+		mockFunc := func(ctx context.Context, q string, t time.Time) (promql.Vector, error) {
+			select {
+			case <-time.After(queryDuration):
+				return promql.Vector{}, nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		qf := ruleGroupEvaluationQueryFunc(
+			mockFunc,
+			newEvaluationConcurrencyLimiter(func() int { return limit }),
+			func() time.Duration { return maxDuration },
+			newRuleGroupEvaluationMetrics(reg),
+		)
+
+		return reg, func(ctx context.Context, ts time.Time) error {
+			_, err := qf(ctx, "test", ts)
+			return err
+		}
+	}
+
+	evaluationContext := func() context.Context {
+		return context.WithValue(context.Background(), ruleGroupEvaluationKey, &ruleGroupEvaluation{
+			interval:        time.Minute,
+			evaluationDelay: func() time.Duration { return 0 },
+		})
+	}
+
+	t.Run("should run the query if the rule group evaluation is within its deadline", func(t *testing.T) {
+		reg, query := newQueryFunc(0, 0, 0)
+
+		require.NoError(t, query(evaluationContext(), time.Now()))
+		assertRuleGroupEvaluationMetrics(t, reg, 0, 0, 0)
+	})
+
+	t.Run("should run the query outside of a rule group evaluation", func(t *testing.T) {
+		reg, query := newQueryFunc(0, 0, 0)
+
+		require.NoError(t, query(context.Background(), time.Now().Add(-time.Hour)))
+		assertRuleGroupEvaluationMetrics(t, reg, 0, 0, 0)
+	})
+
+	t.Run("should skip the remaining rules once the rule group evaluation exceeded its deadline", func(t *testing.T) {
+		reg, query := newQueryFunc(0, 0, 0)
+		ctx := evaluationContext()
+		ts := time.Now().Add(-2 * time.Minute)
+
+		require.ErrorIs(t, query(ctx, ts), errRuleGroupEvaluationDeadlineExceeded)
+		require.ErrorIs(t, query(ctx, ts), errRuleGroupEvaluationDeadlineExceeded)
+		assertRuleGroupEvaluationMetrics(t, reg, 1, 2, 0)
+
+		// The next evaluation of the rule group is counted separately.
+		require.ErrorIs(t, query(ctx, ts.Add(time.Minute)), errRuleGroupEvaluationDeadlineExceeded)
+		assertRuleGroupEvaluationMetrics(t, reg, 2, 3, 0)
+	})
+
+	t.Run("should cancel the query when the rule group evaluation exceeds its deadline", func(t *testing.T) {
+		reg, query := newQueryFunc(0, 100*time.Millisecond, time.Minute)
+
+		require.ErrorIs(t, query(evaluationContext(), time.Now()), context.DeadlineExceeded)
+		assertRuleGroupEvaluationMetrics(t, reg, 1, 0, 0)
+	})
+
+	t.Run("should wait for the concurrent rule group evaluations to complete when the limit is reached", func(t *testing.T) {
+		reg, query := newQueryFunc(1, 0, 100*time.Millisecond)
+
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				errs <- query(evaluationContext(), time.Now())
+			}()
+		}
+		require.NoError(t, <-errs)
+		require.NoError(t, <-errs)
+		assertRuleGroupEvaluationMetrics(t, reg, 0, 0, 1)
+	})
+
+	t.Run("should skip the rule if the rule group evaluation exceeds its deadline while waiting for the concurrent evaluations", func(t *testing.T) {
+		reg, query := newQueryFunc(1, 200*time.Millisecond, time.Second)
+
+		errs := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			go func() {
+				errs <- query(evaluationContext(), time.Now())
+			}()
+		}
+
+		// One evaluation is canceled while running the query, the other is skipped while waiting.
+		var skipped int
+		for i := 0; i < 2; i++ {
+			if err := <-errs; errors.Is(err, errRuleGroupEvaluationDeadlineExceeded) {
+				skipped++
+			} else {
+				require.ErrorIs(t, err, context.DeadlineExceeded)
+			}
+		}
+		assert.Equal(t, 1, skipped)
+		assertRuleGroupEvaluationMetrics(t, reg, 2, 1, 1)
+	})
+}
+
+func assertRuleGroupEvaluationMetrics(t *testing.T, reg *prometheus.Registry, late, skipped, throttled int) {
+	metrics, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := map[string]float64{}
+	for _, m := range metrics {
+		values[m.GetName()] = m.GetMetric()[0].GetCounter().GetValue()
+	}
+	assert.Equal(t, float64(late), values["ruler_rule_group_evaluations_late_total"])
+	assert.Equal(t, float64(skipped), values["ruler_rule_evaluations_skipped_total"])
+	assert.Equal(t, float64(throttled), values["ruler_rule_evaluations_throttled_total"])
+}
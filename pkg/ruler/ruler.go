@@ -114,6 +114,8 @@ type Config struct {
 	QueryFrontend QueryFrontendConfig `yaml:"query_frontend"`
 
 	TenantFederation TenantFederationConfig `yaml:"tenant_federation"`
+
+	RuleGroupsHistoryMaxVersions int `yaml:"rule_groups_history_max_versions" category:"experimental"`
 }
 
 // Validate config and returns error on failure
@@ -156,6 +158,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.Var(&cfg.DisabledTenants, "ruler.disabled-tenants", "Comma separated list of tenants whose rules this ruler cannot evaluate. If specified, a ruler that would normally pick the specified tenant(s) for processing will ignore them instead. Subject to sharding.")
 
 	f.BoolVar(&cfg.EnableQueryStats, "ruler.query-stats-enabled", false, "Report the wall time for ruler queries to complete as a per-tenant metric and as an info level log message.")
+	f.IntVar(&cfg.RuleGroupsHistoryMaxVersions, "ruler.rule-groups-history-max-versions", 0, "Maximum number of versions of each namespace to keep in the ruler storage when its rule groups are changed through the ruler config API, to allow rolling back the namespace to a previous version. 0 to disable. Requires an object storage backend.")
 
 	cfg.RingCheckPeriod = 5 * time.Second
 }
@@ -894,6 +897,13 @@ func (r *Ruler) DeleteTenantConfiguration(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	if history, ok := r.store.(rulestore.RuleStoreHistory); ok {
+		if err := history.DeleteNamespaceVersions(req.Context(), userID, ""); err != nil {
+			respondError(logger, w, err.Error())
+			return
+		}
+	}
+
 	level.Info(logger).Log("msg", "deleted all tenant rule groups", "user", userID)
 	w.WriteHeader(http.StatusOK)
 }
@@ -29,6 +29,9 @@ const (
 	// RulesPrefix is the bucket prefix under which all tenants rule groups are stored.
 	RulesPrefix = "rules"
 
+	// RulesHistoryPrefix is the bucket prefix under which all tenants namespace versions are stored.
+	RulesHistoryPrefix = "rules-history"
+
 	loadConcurrency = 10
 )
 
@@ -42,16 +45,18 @@ var (
 // BucketRuleStore is used to support the RuleStore interface against an object storage backend. It is implemented
 // using the Thanos objstore.Bucket interface
 type BucketRuleStore struct {
-	bucket      objstore.Bucket
-	cfgProvider bucket.TenantConfigProvider
-	logger      log.Logger
+	bucket        objstore.Bucket
+	historyBucket objstore.Bucket
+	cfgProvider   bucket.TenantConfigProvider
+	logger        log.Logger
 }
 
 func NewBucketRuleStore(bkt objstore.Bucket, cfgProvider bucket.TenantConfigProvider, logger log.Logger) *BucketRuleStore {
 	return &BucketRuleStore{
-		bucket:      bucket.NewPrefixedBucketClient(bkt, RulesPrefix),
-		cfgProvider: cfgProvider,
-		logger:      logger,
+		bucket:        bucket.NewPrefixedBucketClient(bkt, RulesPrefix),
+		historyBucket: bucket.NewPrefixedBucketClient(bkt, RulesHistoryPrefix),
+		cfgProvider:   cfgProvider,
+		logger:        logger,
 	}
 }
 
@@ -0,0 +1,217 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+// namespaceVersionObject is the content of the object storing a version of a namespace.
+type namespaceVersionObject struct {
+	Author    string    `json:"author"`
+	Timestamp time.Time `json:"timestamp"`
+	// RuleGroups are the protobuf encoded rule groups of the namespace.
+	RuleGroups [][]byte `json:"rule_groups"`
+}
+
+// SaveNamespaceVersion implements rulestore.RuleStoreHistory.
+func (b *BucketRuleStore) SaveNamespaceVersion(ctx context.Context, userID, namespace string, version rulestore.NamespaceVersion, maxVersions int) error {
+	obj := namespaceVersionObject{
+		Author:     version.Author,
+		Timestamp:  version.Timestamp,
+		RuleGroups: make([][]byte, 0, len(version.RuleGroups)),
+	}
+	for _, rg := range version.RuleGroups {
+		data, err := proto.Marshal(rg)
+		if err != nil {
+			return err
+		}
+		obj.RuleGroups = append(obj.RuleGroups, data)
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, b.historyBucket, b.cfgProvider)
+	id := strconv.FormatInt(version.Timestamp.UnixNano(), 10)
+	if err := userBucket.Upload(ctx, getNamespaceVersionObjectKey(namespace, id), bytes.NewBuffer(data)); err != nil {
+		return err
+	}
+
+	ids, err := b.listNamespaceVersionIDs(ctx, userID, namespace)
+	if err != nil {
+		return err
+	}
+	for i := maxVersions; i < len(ids); i++ {
+		objectKey := getNamespaceVersionObjectKey(namespace, ids[i])
+		level.Debug(b.logger).Log("msg", "deleting namespace version", "user", userID, "namespace", namespace, "key", objectKey)
+		if err := userBucket.Delete(ctx, objectKey); err != nil && !userBucket.IsObjNotFoundErr(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListNamespaceVersions implements rulestore.RuleStoreHistory.
+func (b *BucketRuleStore) ListNamespaceVersions(ctx context.Context, userID, namespace string) ([]rulestore.NamespaceVersion, error) {
+	ids, err := b.listNamespaceVersionIDs(ctx, userID, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]rulestore.NamespaceVersion, 0, len(ids))
+	for _, id := range ids {
+		version, err := b.GetNamespaceVersion(ctx, userID, namespace, id)
+		if errors.Is(err, rulestore.ErrNamespaceVersionNotFound) {
+			// The version has been deleted since it was listed.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
+// errStopIter stops the iteration of the bucket objects.
+var errStopIter = errors.New("stop iteration")
+
+// HasNamespaceVersions implements rulestore.RuleStoreHistory.
+func (b *BucketRuleStore) HasNamespaceVersions(ctx context.Context, userID, namespace string) (bool, error) {
+	userBucket := bucket.NewUserBucketClient(userID, b.historyBucket, b.cfgProvider)
+	prefix := getNamespacePrefix(namespace)
+
+	found := false
+	err := userBucket.Iter(ctx, prefix, func(key string) error {
+		if _, err := strconv.ParseInt(strings.TrimPrefix(key, prefix), 10, 64); err != nil {
+			// Spurious items in the bucket are not versions.
+			return nil
+		}
+
+		found = true
+		return errStopIter
+	})
+	if err != nil && !errors.Is(err, errStopIter) {
+		return false, err
+	}
+	return found, nil
+}
+
+// GetNamespaceVersion implements rulestore.RuleStoreHistory.
+func (b *BucketRuleStore) GetNamespaceVersion(ctx context.Context, userID, namespace, id string) (rulestore.NamespaceVersion, error) {
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return rulestore.NamespaceVersion{}, rulestore.ErrNamespaceVersionNotFound
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, b.historyBucket, b.cfgProvider)
+	objectKey := getNamespaceVersionObjectKey(namespace, id)
+
+	reader, err := userBucket.Get(ctx, objectKey)
+	if userBucket.IsObjNotFoundErr(err) {
+		return rulestore.NamespaceVersion{}, rulestore.ErrNamespaceVersionNotFound
+	}
+	if err != nil {
+		return rulestore.NamespaceVersion{}, errors.Wrapf(err, "failed to get namespace version %s", objectKey)
+	}
+	defer func() { _ = reader.Close() }()
+
+	buf, err := io.ReadAll(reader)
+	if err != nil {
+		return rulestore.NamespaceVersion{}, errors.Wrapf(err, "failed to read namespace version %s", objectKey)
+	}
+
+	obj := namespaceVersionObject{}
+	if err := json.Unmarshal(buf, &obj); err != nil {
+		return rulestore.NamespaceVersion{}, errors.Wrapf(err, "failed to unmarshal namespace version %s", objectKey)
+	}
+
+	version := rulestore.NamespaceVersion{
+		ID:         id,
+		Author:     obj.Author,
+		Timestamp:  obj.Timestamp,
+		RuleGroups: make(rulespb.RuleGroupList, 0, len(obj.RuleGroups)),
+	}
+	for _, data := range obj.RuleGroups {
+		rg := &rulespb.RuleGroupDesc{}
+		if err := proto.Unmarshal(data, rg); err != nil {
+			return rulestore.NamespaceVersion{}, errors.Wrapf(err, "failed to unmarshal rule group of namespace version %s", objectKey)
+		}
+		version.RuleGroups = append(version.RuleGroups, rg)
+	}
+
+	return version, nil
+}
+
+// DeleteNamespaceVersions implements rulestore.RuleStoreHistory.
+func (b *BucketRuleStore) DeleteNamespaceVersions(ctx context.Context, userID, namespace string) error {
+	prefix := ""
+	if namespace != "" {
+		prefix = getNamespacePrefix(namespace)
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, b.historyBucket, b.cfgProvider)
+	deleted, err := bucket.DeletePrefix(ctx, userBucket, prefix, b.logger)
+	if err != nil {
+		return err
+	}
+
+	level.Debug(b.logger).Log("msg", "deleted namespace versions", "user", userID, "namespace", namespace, "deleted", deleted)
+	return nil
+}
+
+// listNamespaceVersionIDs returns the IDs of the versions of a namespace, most recent first.
+func (b *BucketRuleStore) listNamespaceVersionIDs(ctx context.Context, userID, namespace string) ([]string, error) {
+	userBucket := bucket.NewUserBucketClient(userID, b.historyBucket, b.cfgProvider)
+	prefix := getNamespacePrefix(namespace)
+
+	var timestamps []int64
+	err := userBucket.Iter(ctx, prefix, func(key string) error {
+		ts, err := strconv.ParseInt(strings.TrimPrefix(key, prefix), 10, 64)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "invalid namespace version object key found while listing namespace versions", "user", userID, "key", key, "err", err)
+
+			// Do not fail just because of a spurious item in the bucket.
+			return nil
+		}
+
+		timestamps = append(timestamps, ts)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] > timestamps[j] })
+
+	ids := make([]string, 0, len(timestamps))
+	for _, ts := range timestamps {
+		ids = append(ids, strconv.FormatInt(ts, 10))
+	}
+	return ids, nil
+}
+
+func getNamespaceVersionObjectKey(namespace, id string) string {
+	return getNamespacePrefix(namespace) + id
+}
+
+var _ rulestore.RuleStoreHistory = &BucketRuleStore{}
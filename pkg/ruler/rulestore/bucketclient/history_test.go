@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/rulestore"
+)
+
+func TestNamespaceVersions(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	rs := NewBucketRuleStore(bkt, nil, log.NewNopLogger())
+
+	rg := func(name string) *rulespb.RuleGroupDesc {
+		return rulespb.ToProto("user1", "namespace", rulefmt.RuleGroup{Name: name, Rules: []rulefmt.RuleNode{}})
+	}
+	ts := time.Unix(1000, 0)
+
+	exists, err := rs.HasNamespaceVersions(ctx, "user1", "namespace")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	require.NoError(t, rs.SaveNamespaceVersion(ctx, "user1", "namespace", rulestore.NamespaceVersion{Author: "alice", Timestamp: ts, RuleGroups: rulespb.RuleGroupList{rg("first")}}, 2))
+	require.NoError(t, rs.SaveNamespaceVersion(ctx, "user1", "namespace", rulestore.NamespaceVersion{Author: "bob", Timestamp: ts.Add(time.Second), RuleGroups: rulespb.RuleGroupList{rg("first"), rg("second")}}, 2))
+	require.NoError(t, rs.SaveNamespaceVersion(ctx, "user1", "other", rulestore.NamespaceVersion{Timestamp: ts}, 2))
+
+	exists, err = rs.HasNamespaceVersions(ctx, "user1", "namespace")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	versions, err := rs.ListNamespaceVersions(ctx, "user1", "namespace")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "bob", versions[0].Author)
+	assert.True(t, ts.Add(time.Second).Equal(versions[0].Timestamp))
+	assert.Equal(t, []string{"first", "second"}, []string{versions[0].RuleGroups[0].Name, versions[0].RuleGroups[1].Name})
+	assert.Equal(t, "alice", versions[1].Author)
+	assert.Equal(t, "user1", versions[1].RuleGroups[0].User)
+	assert.Equal(t, "namespace", versions[1].RuleGroups[0].Namespace)
+
+	version, err := rs.GetNamespaceVersion(ctx, "user1", "namespace", versions[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, versions[1], version)
+
+	// The versions are not listed as rule groups.
+	groups, err := rs.ListRuleGroupsForUserAndNamespace(ctx, "user1", "")
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+	users, err := rs.ListAllUsers(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	// The oldest versions in excess of the maximum are deleted.
+	require.NoError(t, rs.SaveNamespaceVersion(ctx, "user1", "namespace", rulestore.NamespaceVersion{Author: "carol", Timestamp: ts.Add(2 * time.Second)}, 2))
+	versions, err = rs.ListNamespaceVersions(ctx, "user1", "namespace")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "carol", versions[0].Author)
+	assert.Empty(t, versions[0].RuleGroups)
+	assert.Equal(t, "bob", versions[1].Author)
+
+	_, err = rs.GetNamespaceVersion(ctx, "user1", "namespace", version.ID)
+	assert.ErrorIs(t, err, rulestore.ErrNamespaceVersionNotFound)
+	_, err = rs.GetNamespaceVersion(ctx, "user1", "namespace", "../other")
+	assert.ErrorIs(t, err, rulestore.ErrNamespaceVersionNotFound)
+
+	require.NoError(t, rs.DeleteNamespaceVersions(ctx, "user1", "namespace"))
+	versions, err = rs.ListNamespaceVersions(ctx, "user1", "namespace")
+	require.NoError(t, err)
+	assert.Empty(t, versions)
+	exists, err = rs.HasNamespaceVersions(ctx, "user1", "namespace")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	versions, err = rs.ListNamespaceVersions(ctx, "user1", "other")
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	require.NoError(t, rs.DeleteNamespaceVersions(ctx, "user1", ""))
+	assert.Empty(t, bkt.Objects())
+}
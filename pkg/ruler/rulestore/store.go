@@ -8,6 +8,7 @@ package rulestore
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 )
@@ -19,6 +20,8 @@ var (
 	ErrGroupNamespaceNotFound = errors.New("group namespace does not exist")
 	// ErrUserNotFound is returned if the user does not currently exist
 	ErrUserNotFound = errors.New("no rule groups found for user")
+	// ErrNamespaceVersionNotFound is returned if a version of a namespace does not exist
+	ErrNamespaceVersionNotFound = errors.New("namespace version does not exist")
 )
 
 // RuleStore is used to store and retrieve rules.
@@ -51,3 +54,33 @@ type RuleStore interface {
 	// If namespace is empty, deletes all rule groups for user.
 	DeleteNamespace(ctx context.Context, userID, namespace string) error
 }
+
+// NamespaceVersion is a version of the rule groups of a namespace, stored each time the namespace is changed.
+type NamespaceVersion struct {
+	// ID of the version. The IDs of the versions of a namespace are ordered by time.
+	ID        string
+	Author    string
+	Timestamp time.Time
+	// RuleGroups of the namespace in this version. It's empty if the namespace has been deleted.
+	RuleGroups rulespb.RuleGroupList
+}
+
+// RuleStoreHistory is implemented by the rule stores keeping the history of the changes of the namespaces.
+type RuleStoreHistory interface {
+	// SaveNamespaceVersion stores the version of a namespace, and deletes the oldest versions of the namespace
+	// in excess of maxVersions. The ID of the version is computed from its timestamp.
+	SaveNamespaceVersion(ctx context.Context, userID, namespace string, version NamespaceVersion, maxVersions int) error
+
+	// ListNamespaceVersions returns the versions of a namespace, most recent first.
+	ListNamespaceVersions(ctx context.Context, userID, namespace string) ([]NamespaceVersion, error)
+
+	// HasNamespaceVersions returns whether a namespace has at least one version, without reading the versions.
+	HasNamespaceVersions(ctx context.Context, userID, namespace string) (bool, error)
+
+	// GetNamespaceVersion returns a version of a namespace, or ErrNamespaceVersionNotFound if it doesn't exist.
+	GetNamespaceVersion(ctx context.Context, userID, namespace, id string) (NamespaceVersion, error)
+
+	// DeleteNamespaceVersions deletes all versions of a namespace.
+	// If namespace is empty, deletes the versions of all namespaces of the user.
+	DeleteNamespaceVersions(ctx context.Context, userID, namespace string) error
+}
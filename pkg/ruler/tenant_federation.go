@@ -11,17 +11,31 @@ import (
 	"github.com/prometheus/prometheus/rules"
 	"github.com/weaveworks/common/user"
 
+	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/tenant"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/util"
 )
 
 type TenantFederationConfig struct {
-	Enabled bool `yaml:"enabled"`
+	Enabled        bool                   `yaml:"enabled"`
+	AllowedTenants flagext.StringSliceCSV `yaml:"allowed_tenants" category:"experimental"`
 }
 
 func (cfg *TenantFederationConfig) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.Enabled, "ruler.tenant-federation.enabled", false, "Enable running rule groups against multiple tenants. The tenant IDs involved need to be in the rule group's 'source_tenants' field. If this flag is set to 'false' when there are already created federated rule groups, then these rules groups will be skipped during evaluations.")
+	f.Var(&cfg.AllowedTenants, "ruler.tenant-federation.allowed-tenants", "Comma separated list of tenants allowed to have federated rule groups. If specified, the federated rule groups of the other tenants are rejected by the ruler API and skipped during evaluations, otherwise all tenants are allowed.")
+}
+
+// isTenantAllowed returns whether the tenant is in the list of tenants allowed to have federated rule groups.
+func (cfg *TenantFederationConfig) isTenantAllowed(userID string) bool {
+	return len(cfg.AllowedTenants) == 0 || util.StringsContain(cfg.AllowedTenants, userID)
+}
+
+// isTenantEnabled returns whether the federated rule groups of the tenant should be evaluated.
+func (cfg *TenantFederationConfig) isTenantEnabled(userID string) bool {
+	return cfg.Enabled && cfg.isTenantAllowed(userID)
 }
 
 type contextKey int
@@ -55,8 +69,13 @@ func TenantFederationQueryFunc(regularQueryable, federatedQueryable rules.QueryF
 	}
 }
 
-func RemoveFederatedRuleGroups(groups map[string]rulespb.RuleGroupList) {
+// RemoveFederatedRuleGroups removes the federated rule groups of the tenants for which isEnabled returns false.
+func RemoveFederatedRuleGroups(groups map[string]rulespb.RuleGroupList, isEnabled func(userID string) bool) {
 	for userID, groupList := range groups {
+		if isEnabled(userID) {
+			continue
+		}
+
 		amended := make(rulespb.RuleGroupList, 0, len(groupList))
 		for _, group := range groupList {
 			if len(group.GetSourceTenants()) > 0 {
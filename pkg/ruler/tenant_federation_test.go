@@ -39,6 +39,7 @@ func TestRuler_TenantFederationFlag(t *testing.T) {
 
 	testCases := map[string]struct {
 		tenantFederationEnabled bool
+		allowedTenants          []string
 		existingRules           rulespb.RuleGroupList
 
 		expectedRunningGroupsNames []string
@@ -67,6 +68,20 @@ func TestRuler_TenantFederationFlag(t *testing.T) {
 
 			expectedRunningGroupsNames: []string{regularGroup.Name, federatedGroupWithOneTenant.Name, federatedGroupWithMultipleTenants.Name},
 		},
+		"tenant federation enabled and tenant in the allowed tenants": {
+			tenantFederationEnabled: true,
+			allowedTenants:          []string{userID},
+			existingRules:           rulespb.RuleGroupList{regularGroup, federatedGroupWithOneTenant},
+
+			expectedRunningGroupsNames: []string{regularGroup.Name, federatedGroupWithOneTenant.Name},
+		},
+		"tenant federation enabled and tenant not in the allowed tenants": {
+			tenantFederationEnabled: true,
+			allowedTenants:          []string{"tenant-2"},
+			existingRules:           rulespb.RuleGroupList{regularGroup, federatedGroupWithOneTenant},
+
+			expectedRunningGroupsNames: []string{regularGroup.Name},
+		},
 	}
 
 	for name, tc := range testCases {
@@ -74,6 +89,7 @@ func TestRuler_TenantFederationFlag(t *testing.T) {
 
 			cfg := defaultRulerConfig(t)
 			cfg.TenantFederation.Enabled = tc.tenantFederationEnabled
+			cfg.TenantFederation.AllowedTenants = tc.allowedTenants
 			existingRules := map[string]rulespb.RuleGroupList{userID: tc.existingRules}
 
 			r := prepareRulerManager(t, cfg)
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package queue
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Priority classes of the queries, from the highest to the lowest priority.
+const (
+	// PriorityClassAlerting is the class of the rule evaluations of the ruler.
+	PriorityClassAlerting = "alerting"
+	// PriorityClassDashboard is the class of the queries of dashboard panels.
+	PriorityClassDashboard = "dashboard"
+	// PriorityClassAdHoc is the class of all other queries.
+	PriorityClassAdHoc = "ad-hoc"
+)
+
+// PriorityClasses are the supported priority classes. The index of a class is the priority
+// of the requests of that class.
+var PriorityClasses = []string{PriorityClassAlerting, PriorityClassDashboard, PriorityClassAdHoc}
+
+// PriorityHeader is the HTTP header carrying the priority class of a query. It can be set by clients
+// to choose the class explicitly, and it's set by the query-frontend on the requests sent to the
+// query-scheduler.
+const PriorityHeader = "X-Mimir-Query-Priority"
+
+const (
+	// rulerUserAgentPrefix is the prefix of the User-Agent of the ruler remote rule evaluations.
+	rulerUserAgentPrefix = "mimir/"
+
+	// dashboardUIDHeader is the header set by Grafana on the queries of dashboard panels.
+	dashboardUIDHeader = "X-Dashboard-Uid"
+)
+
+// ClassifyRequestPriority returns the priority class of the request: the class in the PriorityHeader
+// if valid, otherwise the class inferred from the request source.
+func ClassifyRequestPriority(r *http.Request) string {
+	if class := strings.ToLower(r.Header.Get(PriorityHeader)); PriorityClassIndex(class) >= 0 {
+		return class
+	}
+
+	switch {
+	case strings.HasPrefix(r.UserAgent(), rulerUserAgentPrefix):
+		return PriorityClassAlerting
+	case r.Header.Get(dashboardUIDHeader) != "":
+		return PriorityClassDashboard
+	default:
+		return PriorityClassAdHoc
+	}
+}
+
+// PriorityClassIndex returns the index of the priority class, or -1 if the class is not supported.
+func PriorityClassIndex(class string) int {
+	for i, c := range PriorityClasses {
+		if c == class {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParsePriorityWeights parses a comma-separated list of <priority class>=<weight> pairs, and returns the
+// weight of each class of PriorityClasses. Classes not listed get weight 1. If value is empty, nil is returned.
+func ParsePriorityWeights(value string) ([]int, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	weights := make([]int, len(PriorityClasses))
+	for i := range weights {
+		weights[i] = 1
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid priority weight %q, expected format is <priority class>=<weight>", pair)
+		}
+
+		class := strings.TrimSpace(parts[0])
+		idx := PriorityClassIndex(class)
+		if idx < 0 {
+			return nil, fmt.Errorf("unsupported priority class %q, supported values are: %s", class, strings.Join(PriorityClasses, ", "))
+		}
+
+		weight, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("invalid weight of priority class %q, must be an integer greater than 0", class)
+		}
+		weights[idx] = weight
+	}
+	return weights, nil
+}
+
+type priorityClassContextKey int
+
+const priorityClassKey priorityClassContextKey = 0
+
+// ContextWithPriorityClass returns a new context carrying the priority class.
+func ContextWithPriorityClass(ctx context.Context, class string) context.Context {
+	return context.WithValue(ctx, priorityClassKey, class)
+}
+
+// PriorityClassFromContext returns the priority class carried by the context, or an empty string if none.
+func PriorityClassFromContext(ctx context.Context) string {
+	class, _ := ctx.Value(priorityClassKey).(string)
+	return class
+}
@@ -0,0 +1,97 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package queue
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyRequestPriority(t *testing.T) {
+	for name, test := range map[string]struct {
+		headers       map[string]string
+		expectedClass string
+	}{
+		"ad-hoc query": {
+			headers:       map[string]string{"User-Agent": "curl/7.85.0"},
+			expectedClass: PriorityClassAdHoc,
+		},
+		"ruler rule evaluation": {
+			headers:       map[string]string{"User-Agent": "mimir/2.5.0"},
+			expectedClass: PriorityClassAlerting,
+		},
+		"Grafana dashboard panel": {
+			headers:       map[string]string{"User-Agent": "Grafana/9.2.0", "X-Dashboard-Uid": "abc"},
+			expectedClass: PriorityClassDashboard,
+		},
+		"class set by the client": {
+			headers:       map[string]string{"User-Agent": "Grafana/9.2.0", "X-Dashboard-Uid": "abc", PriorityHeader: "Ad-Hoc"},
+			expectedClass: PriorityClassAdHoc,
+		},
+		"invalid class set by the client": {
+			headers:       map[string]string{"User-Agent": "mimir/2.5.0", PriorityHeader: "urgent"},
+			expectedClass: PriorityClassAlerting,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil)
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+
+			assert.Equal(t, test.expectedClass, ClassifyRequestPriority(req))
+		})
+	}
+}
+
+func TestParsePriorityWeights(t *testing.T) {
+	for name, test := range map[string]struct {
+		value           string
+		expectedWeights []int
+		expectedErr     string
+	}{
+		"empty": {
+			value: "",
+		},
+		"all classes": {
+			value:           "ad-hoc=1, dashboard=4, alerting=10",
+			expectedWeights: []int{10, 4, 1},
+		},
+		"some classes": {
+			value:           "alerting=5",
+			expectedWeights: []int{5, 1, 1},
+		},
+		"invalid format": {
+			value:       "alerting",
+			expectedErr: `invalid priority weight "alerting"`,
+		},
+		"unsupported class": {
+			value:       "urgent=10",
+			expectedErr: `unsupported priority class "urgent"`,
+		},
+		"invalid weight": {
+			value:       "alerting=0",
+			expectedErr: `invalid weight of priority class "alerting"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			weights, err := ParsePriorityWeights(test.value)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedWeights, weights)
+		})
+	}
+}
+
+func TestPriorityClassContext(t *testing.T) {
+	assert.Equal(t, "", PriorityClassFromContext(context.Background()))
+	assert.Equal(t, PriorityClassDashboard, PriorityClassFromContext(ContextWithPriorityClass(context.Background(), PriorityClassDashboard)))
+}
@@ -65,9 +65,11 @@ type RequestQueue struct {
 	discardedRequests *prometheus.CounterVec // Per user.
 }
 
-func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, queueLength *prometheus.GaugeVec, discardedRequests *prometheus.CounterVec) *RequestQueue {
+// NewRequestQueue creates a new RequestQueue. Each user queue has a priority lane for each of the priorityWeights,
+// or a single lane if priorityWeights is empty. See EnqueueRequest.
+func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, priorityWeights []int, queueLength *prometheus.GaugeVec, discardedRequests *prometheus.CounterVec) *RequestQueue {
 	q := &RequestQueue{
-		queues:                  newUserQueues(maxOutstandingPerTenant, forgetDelay),
+		queues:                  newUserQueues(maxOutstandingPerTenant, forgetDelay, priorityWeights),
 		connectedQuerierWorkers: atomic.NewInt32(0),
 		queueLength:             queueLength,
 		discardedRequests:       discardedRequests,
@@ -83,8 +85,16 @@ func NewRequestQueue(maxOutstandingPerTenant int, forgetDelay time.Duration, que
 // this user use (zero or negative = all queriers). It is passed to each EnqueueRequest, because it can change
 // between calls.
 //
+// The request is added to the user queue lane of the given priority, which is the index of the lane weight.
+// Requests of the same user are dequeued from the lanes by weighted round-robin, and the max number of outstanding
+// requests per tenant applies to all lanes together.
+//
+// The zone is the availability zone the request is enqueued from, or empty if unknown. A request is preferably
+// handled by a querier in the same zone, and by a querier in a different zone only if no querier in its zone
+// which can handle the user requests is waiting for a request.
+//
 // If request is successfully enqueued, successFn is called with the lock held, before any querier can receive the request.
-func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers int, successFn func()) error {
+func (q *RequestQueue) EnqueueRequest(userID string, req Request, priority int, zone string, maxQueriers int, successFn func()) error {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 
@@ -98,19 +108,19 @@ func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers in
 		return errors.New("no queue found")
 	}
 
-	select {
-	case queue <- req:
-		q.queueLength.WithLabelValues(userID).Inc()
-		q.cond.Broadcast()
-		// Call this function while holding a lock. This guarantees that no querier can fetch the request before function returns.
-		if successFn != nil {
-			successFn()
-		}
-		return nil
-	default:
+	if queue.length >= q.queues.maxUserQueueSize {
 		q.discardedRequests.WithLabelValues(userID).Inc()
 		return ErrTooManyRequests
 	}
+
+	queue.enqueue(req, priority, zone)
+	q.queueLength.WithLabelValues(userID).Inc()
+	q.cond.Broadcast()
+	// Call this function while holding a lock. This guarantees that no querier can fetch the request before function returns.
+	if successFn != nil {
+		successFn()
+	}
+	return nil
 }
 
 // GetNextRequestForQuerier find next user queue and takes the next request off of it. Will block if there are no requests.
@@ -126,7 +136,11 @@ FindQueue:
 	// We need to wait if there are no users, or no pending requests for given querier.
 	for (q.queues.len() == 0 || querierWait) && ctx.Err() == nil && !q.stopped {
 		querierWait = false
+
+		// Track the querier as idle while waiting, so that requests from its zone aren't handled by queriers in other zones.
+		q.queues.setQuerierIdle(querierID, true)
 		q.cond.Wait(ctx)
+		q.queues.setQuerierIdle(querierID, false)
 	}
 
 	if q.stopped {
@@ -134,10 +148,13 @@ FindQueue:
 	}
 
 	if err := ctx.Err(); err != nil {
+		// The querier is not idle anymore, so queriers in other zones may now handle the requests from its zone.
+		q.cond.Broadcast()
 		return nil, last, err
 	}
 
-	for {
+	// Look at each user queue at most once, because a queue may only have requests which the querier shouldn't handle.
+	for attempts := len(q.queues.users); attempts > 0; attempts-- {
 		queue, userID, idx := q.queues.getNextQueueForQuerier(last.last, querierID)
 		last.last = idx
 		if queue == nil {
@@ -145,19 +162,21 @@ FindQueue:
 		}
 
 		// Pick next request from the queue.
-		for {
-			request := <-queue
-			if len(queue) == 0 {
-				q.queues.deleteQueue(userID)
-			}
+		request := queue.dequeue(q.queues.priorityWeights, q.queues.zoneAcceptor(querierID, queue))
+		if queue.length == 0 {
+			q.queues.deleteQueue(userID)
+		}
+		if request == nil {
+			// The queue has no request for this querier, look for another one.
+			continue
+		}
 
-			q.queueLength.WithLabelValues(userID).Dec()
+		q.queueLength.WithLabelValues(userID).Dec()
 
-			// Tell close() we've processed a request.
-			q.cond.Broadcast()
+		// Tell close() we've processed a request.
+		q.cond.Broadcast()
 
-			return request, last, nil
-		}
+		return request, last, nil
 	}
 
 	// There are no unexpired requests, so we can get back
@@ -196,12 +215,13 @@ func (q *RequestQueue) stopping(_ error) error {
 	return nil
 }
 
-func (q *RequestQueue) RegisterQuerierConnection(querier string) {
+// RegisterQuerierConnection registers a connection of the querier running in the zone. The zone is empty if unknown.
+func (q *RequestQueue) RegisterQuerierConnection(querier, zone string) {
 	q.connectedQuerierWorkers.Inc()
 
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
-	q.queues.addQuerierConnection(querier)
+	q.queues.addQuerierConnection(querier, zone)
 }
 
 func (q *RequestQueue) UnregisterQuerierConnection(querier string) {
@@ -28,21 +28,21 @@ func BenchmarkGetNextRequest(b *testing.B) {
 	queues := make([]*RequestQueue, 0, b.N)
 
 	for n := 0; n < b.N; n++ {
-		queue := NewRequestQueue(maxOutstandingPerTenant, 0,
+		queue := NewRequestQueue(maxOutstandingPerTenant, 0, nil,
 			promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
 			promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
 		)
 		queues = append(queues, queue)
 
 		for ix := 0; ix < queriers; ix++ {
-			queue.RegisterQuerierConnection(fmt.Sprintf("querier-%d", ix))
+			queue.RegisterQuerierConnection(fmt.Sprintf("querier-%d", ix), "")
 		}
 
 		for i := 0; i < maxOutstandingPerTenant; i++ {
 			for j := 0; j < numTenants; j++ {
 				userID := strconv.Itoa(j)
 
-				err := queue.EnqueueRequest(userID, "request", 0, nil)
+				err := queue.EnqueueRequest(userID, "request", 0, "", 0, nil)
 				if err != nil {
 					b.Fatal(err)
 				}
@@ -85,13 +85,13 @@ func BenchmarkQueueRequest(b *testing.B) {
 	requests := make([]string, 0, numTenants)
 
 	for n := 0; n < b.N; n++ {
-		q := NewRequestQueue(maxOutstandingPerTenant, 0,
+		q := NewRequestQueue(maxOutstandingPerTenant, 0, nil,
 			promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
 			promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
 		)
 
 		for ix := 0; ix < queriers; ix++ {
-			q.RegisterQuerierConnection(fmt.Sprintf("querier-%d", ix))
+			q.RegisterQuerierConnection(fmt.Sprintf("querier-%d", ix), "")
 		}
 
 		queues = append(queues, q)
@@ -106,7 +106,7 @@ func BenchmarkQueueRequest(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		for i := 0; i < maxOutstandingPerTenant; i++ {
 			for j := 0; j < numTenants; j++ {
-				err := queues[n].EnqueueRequest(users[j], requests[j], 0, nil)
+				err := queues[n].EnqueueRequest(users[j], requests[j], 0, "", 0, nil)
 				if err != nil {
 					b.Fatal(err)
 				}
@@ -118,7 +118,7 @@ func BenchmarkQueueRequest(b *testing.B) {
 func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBecauseQuerierHasBeenForgotten(t *testing.T) {
 	const forgetDelay = 3 * time.Second
 
-	queue := NewRequestQueue(1, forgetDelay,
+	queue := NewRequestQueue(1, forgetDelay, nil,
 		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
 		promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}))
 
@@ -130,8 +130,8 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 	})
 
 	// Two queriers connect.
-	queue.RegisterQuerierConnection("querier-1")
-	queue.RegisterQuerierConnection("querier-2")
+	queue.RegisterQuerierConnection("querier-1", "")
+	queue.RegisterQuerierConnection("querier-2", "")
 
 	// Querier-2 waits for a new request.
 	querier2wg := sync.WaitGroup{}
@@ -147,7 +147,7 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 
 	// Enqueue a request from an user which would be assigned to querier-1.
 	// NOTE: "user-1" hash falls in the querier-1 shard.
-	require.NoError(t, queue.EnqueueRequest("user-1", "request", 1, nil))
+	require.NoError(t, queue.EnqueueRequest("user-1", "request", 0, "", 1, nil))
 
 	startTime := time.Now()
 	querier2wg.Wait()
@@ -157,6 +157,102 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 	assert.GreaterOrEqual(t, waitTime.Milliseconds(), forgetDelay.Milliseconds())
 }
 
+func TestRequestQueue_GetNextRequestForQuerier_ShouldDequeueRequestsByPriorityWeights(t *testing.T) {
+	queue := NewRequestQueue(100, 0, []int{4, 2, 1},
+		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
+		promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}))
+
+	ctx := context.Background()
+	require.NoError(t, services.StartAndAwaitRunning(ctx, queue))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, queue))
+	})
+	// Unregister the querier before stopping the queue, which otherwise waits for the queued requests to be dequeued.
+	queue.RegisterQuerierConnection("querier-1", "")
+	t.Cleanup(func() { queue.UnregisterQuerierConnection("querier-1") })
+
+	// The lowest priority requests are enqueued first.
+	for priority := 2; priority >= 0; priority-- {
+		for i := 0; i < 7; i++ {
+			require.NoError(t, queue.EnqueueRequest("user-1", fmt.Sprintf("priority-%d-%d", priority, i), priority, "", 0, nil))
+		}
+	}
+
+	var dequeued []string
+	for i := 0; i < 7; i++ {
+		req, _, err := queue.GetNextRequestForQuerier(ctx, FirstUser(), "querier-1")
+		require.NoError(t, err)
+		dequeued = append(dequeued, req.(string))
+	}
+
+	// Out of 7 requests, 4 are of the highest priority, 2 of the middle one and 1 of the lowest, evenly interleaved.
+	assert.Equal(t, []string{
+		"priority-0-0", "priority-1-0", "priority-0-1", "priority-2-0", "priority-0-2", "priority-1-1", "priority-0-3",
+	}, dequeued)
+}
+
+func TestRequestQueue_EnqueueRequest_ShouldLimitOutstandingRequestsAcrossPriorities(t *testing.T) {
+	discardedRequests := promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"})
+	queue := NewRequestQueue(2, 0, []int{2, 1},
+		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
+		discardedRequests)
+
+	require.NoError(t, queue.EnqueueRequest("user-1", "request-1", 0, "", 0, nil))
+	require.NoError(t, queue.EnqueueRequest("user-1", "request-2", 1, "", 0, nil))
+	require.Equal(t, ErrTooManyRequests, queue.EnqueueRequest("user-1", "request-3", 0, "", 0, nil))
+}
+
+func TestRequestQueue_GetNextRequestForQuerier_ShouldPreferQueriersInTheSameZone(t *testing.T) {
+	queue := NewRequestQueue(100, 0, nil,
+		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
+		promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}))
+
+	ctx := context.Background()
+	require.NoError(t, services.StartAndAwaitRunning(ctx, queue))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, queue))
+	})
+	queue.RegisterQuerierConnection("querier-a", "zone-a")
+	queue.RegisterQuerierConnection("querier-b", "zone-b")
+	t.Cleanup(func() {
+		queue.UnregisterQuerierConnection("querier-a")
+		queue.UnregisterQuerierConnection("querier-b")
+	})
+
+	// No querier in zone-a is waiting for a request, so the request spills over to querier-b.
+	require.NoError(t, queue.EnqueueRequest("user-1", "request-1", 0, "zone-a", 0, nil))
+	req, _, err := queue.GetNextRequestForQuerier(ctx, FirstUser(), "querier-b")
+	require.NoError(t, err)
+	assert.Equal(t, "request-1", req)
+
+	// Both queriers wait for a request.
+	querierAReq := make(chan Request, 1)
+	go func() {
+		req, _, err := queue.GetNextRequestForQuerier(ctx, FirstUser(), "querier-a")
+		assert.NoError(t, err)
+		querierAReq <- req
+	}()
+
+	querierBCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	t.Cleanup(cancel)
+	querierBErr := make(chan error, 1)
+	go func() {
+		_, _, err := queue.GetNextRequestForQuerier(querierBCtx, FirstUser(), "querier-b")
+		querierBErr <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		queue.mtx.Lock()
+		defer queue.mtx.Unlock()
+		return queue.queues.queriers["querier-a"].idleConnections == 1 && queue.queues.queriers["querier-b"].idleConnections == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// Querier-a is waiting for a request, so the request is handled by querier-a only.
+	require.NoError(t, queue.EnqueueRequest("user-1", "request-2", 0, "zone-a", 0, nil))
+	assert.Equal(t, "request-2", <-querierAReq)
+	assert.ErrorIs(t, <-querierBErr, context.DeadlineExceeded)
+}
+
 func TestContextCond(t *testing.T) {
 	t.Run("wait until broadcast", func(t *testing.T) {
 		t.Parallel()
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/grafana/mimir/pkg/util"
+	util_math "github.com/grafana/mimir/pkg/util/math"
 )
 
 // querier holds information about a querier registered in the queue.
@@ -18,6 +19,12 @@ type querier struct {
 	// Number of active connections.
 	connections int
 
+	// Availability zone of the querier, or empty if unknown.
+	zone string
+
+	// Number of connections waiting for a request to handle.
+	idleConnections int
+
 	// True if the querier notified it's gracefully shutting down.
 	shuttingDown bool
 
@@ -37,6 +44,10 @@ type queues struct {
 
 	maxUserQueueSize int
 
+	// Weight of each priority lane of the user queues. Each user queue has one lane per weight,
+	// or a single lane if there are no weights.
+	priorityWeights []int
+
 	// How long to wait before removing a querier which has got disconnected
 	// but hasn't notified about a graceful shutdown.
 	forgetDelay time.Duration
@@ -48,8 +59,21 @@ type queues struct {
 	sortedQueriers []string
 }
 
+// queuedRequest is a request queued in a user queue, with the availability zone it has been enqueued from.
+type queuedRequest struct {
+	req  Request
+	zone string
+}
+
 type userQueue struct {
-	ch chan Request
+	// Requests queued in each priority lane, in FIFO order.
+	lanes [][]queuedRequest
+
+	// Credits of each priority lane, used to pick the lane to dequeue from by smooth weighted round-robin.
+	credits []int
+
+	// Total number of requests queued in all lanes.
+	length int
 
 	// If not nil, only these queriers can handle user requests. If nil, all queriers can.
 	// We set this to nil if number of available queriers <= maxQueriers.
@@ -64,11 +88,12 @@ type userQueue struct {
 	index int
 }
 
-func newUserQueues(maxUserQueueSize int, forgetDelay time.Duration) *queues {
+func newUserQueues(maxUserQueueSize int, forgetDelay time.Duration, priorityWeights []int) *queues {
 	return &queues{
 		userQueues:       map[string]*userQueue{},
 		users:            nil,
 		maxUserQueueSize: maxUserQueueSize,
+		priorityWeights:  priorityWeights,
 		forgetDelay:      forgetDelay,
 		queriers:         map[string]*querier{},
 		sortedQueriers:   nil,
@@ -98,7 +123,7 @@ func (q *queues) deleteQueue(userID string) {
 // MaxQueriers is used to compute which queriers should handle requests for this user.
 // If maxQueriers is <= 0, all queriers can handle this user's requests.
 // If maxQueriers has changed since the last call, queriers for this are recomputed.
-func (q *queues) getOrAddQueue(userID string, maxQueriers int) chan Request {
+func (q *queues) getOrAddQueue(userID string, maxQueriers int) *userQueue {
 	// Empty user is not allowed, as that would break our users list ("" is used for free spot).
 	if userID == "" {
 		return nil
@@ -111,10 +136,12 @@ func (q *queues) getOrAddQueue(userID string, maxQueriers int) chan Request {
 	uq := q.userQueues[userID]
 
 	if uq == nil {
+		lanes := util_math.Max(1, len(q.priorityWeights))
 		uq = &userQueue{
-			ch:    make(chan Request, q.maxUserQueueSize),
-			seed:  util.ShuffleShardSeed(userID, ""),
-			index: -1,
+			lanes:   make([][]queuedRequest, lanes),
+			credits: make([]int, lanes),
+			seed:    util.ShuffleShardSeed(userID, ""),
+			index:   -1,
 		}
 		q.userQueues[userID] = uq
 
@@ -139,13 +166,13 @@ func (q *queues) getOrAddQueue(userID string, maxQueriers int) chan Request {
 		uq.queriers = shuffleQueriersForUser(uq.seed, maxQueriers, q.sortedQueriers, nil)
 	}
 
-	return uq.ch
+	return uq
 }
 
 // Finds next queue for the querier. To support fair scheduling between users, client is expected
 // to pass last user index returned by this function as argument. Is there was no previous
 // last user index, use -1.
-func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (chan Request, string, int) {
+func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (*userQueue, string, int) {
 	uid := lastUserIndex
 
 	// Ensure the querier is not shutting down. If the querier is shutting down, we shouldn't forward
@@ -177,15 +204,136 @@ func (q *queues) getNextQueueForQuerier(lastUserIndex int, querierID string) (ch
 			}
 		}
 
-		return q.ch, u, uid
+		return q, u, uid
 	}
 	return nil, "", uid
 }
 
-func (q *queues) addQuerierConnection(querierID string) {
+// enqueue adds the request enqueued from the zone to the lane of the priority. Priorities out of the
+// lanes range are added to the last lane.
+func (uq *userQueue) enqueue(req Request, priority int, zone string) {
+	if priority < 0 || priority >= len(uq.lanes) {
+		priority = len(uq.lanes) - 1
+	}
+
+	uq.lanes[priority] = append(uq.lanes[priority], queuedRequest{req: req, zone: zone})
+	uq.length++
+}
+
+// dequeue removes and returns the first request of a lane accepted by accept, or nil if there is no such
+// request. If accept is nil, all requests are accepted. If more than one lane has accepted requests, the
+// lane is picked by smooth weighted round-robin: each lane with accepted requests gains its weight in credits,
+// and the lane with the most credits is picked and loses the credits gained by all lanes. This way a lane with
+// weight N gets N times the requests dequeued from a lane with weight 1, evenly interleaved. Ties are won by
+// the lane with the lower index.
+func (uq *userQueue) dequeue(weights []int, accept func(zone string) bool) Request {
+	selected, selectedIdx := -1, -1
+	total := 0
+	for i, lane := range uq.lanes {
+		idx := firstAcceptedRequest(lane, accept)
+		if idx < 0 {
+			continue
+		}
+
+		weight := 1
+		if i < len(weights) {
+			weight = weights[i]
+		}
+		uq.credits[i] += weight
+		total += weight
+
+		if selected < 0 || uq.credits[i] > uq.credits[selected] {
+			selected, selectedIdx = i, idx
+		}
+	}
+	if selected < 0 {
+		return nil
+	}
+
+	lane := uq.lanes[selected]
+	req := lane[selectedIdx].req
+	if selectedIdx == 0 {
+		lane[0] = queuedRequest{}
+		uq.lanes[selected] = lane[1:]
+	} else {
+		copy(lane[selectedIdx:], lane[selectedIdx+1:])
+		lane[len(lane)-1] = queuedRequest{}
+		uq.lanes[selected] = lane[:len(lane)-1]
+	}
+	uq.length--
+
+	uq.credits[selected] -= total
+	if len(uq.lanes[selected]) == 0 {
+		// Lanes don't accumulate credits while they're empty.
+		uq.credits[selected] = 0
+	}
+	return req
+}
+
+// firstAcceptedRequest returns the index of the first request of the lane accepted by accept, or -1 if there is none.
+func firstAcceptedRequest(lane []queuedRequest, accept func(zone string) bool) int {
+	for i, r := range lane {
+		if accept == nil || accept(r.zone) {
+			return i
+		}
+	}
+	return -1
+}
+
+// zoneAcceptor returns the function used to check whether the querier should handle the requests of the
+// user queue enqueued from a zone. Requests are preferably handled by queriers in the zone they've been
+// enqueued from, so a querier in a different zone handles them only if no querier in their zone which can
+// handle the user requests is waiting for a request. Returns nil if all requests are accepted.
+func (q *queues) zoneAcceptor(querierID string, uq *userQueue) func(zone string) bool {
+	info := q.queriers[querierID]
+	if info == nil || info.zone == "" {
+		return nil
+	}
+
+	var idleZones map[string]bool
+	return func(zone string) bool {
+		if zone == "" || zone == info.zone {
+			return true
+		}
+
+		// Lazily compute the zones with idle queriers, only if there are requests from other zones.
+		if idleZones == nil {
+			idleZones = map[string]bool{}
+			for id, other := range q.queriers {
+				if other.idleConnections == 0 || other.shuttingDown {
+					continue
+				}
+				if uq.queriers != nil {
+					if _, ok := uq.queriers[id]; !ok {
+						continue
+					}
+				}
+				idleZones[other.zone] = true
+			}
+		}
+		return !idleZones[zone]
+	}
+}
+
+// setQuerierIdle records whether a connection of the querier is waiting for a request.
+func (q *queues) setQuerierIdle(querierID string, idle bool) {
+	info := q.queriers[querierID]
+	if info == nil {
+		return
+	}
+
+	if idle {
+		info.idleConnections++
+	} else if info.idleConnections > 0 {
+		info.idleConnections--
+	}
+}
+
+func (q *queues) addQuerierConnection(querierID, zone string) {
 	info := q.queriers[querierID]
 	if info != nil {
 		info.connections++
+		info.zone = zone
 
 		// Reset in case the querier re-connected while it was in the forget waiting period.
 		info.shuttingDown = false
@@ -195,7 +343,7 @@ func (q *queues) addQuerierConnection(querierID string) {
 	}
 
 	// First connection from this querier.
-	q.queriers[querierID] = &querier{connections: 1}
+	q.queriers[querierID] = &querier{connections: 1, zone: zone}
 	q.sortedQueriers = append(q.sortedQueriers, querierID)
 	sort.Strings(q.sortedQueriers)
 
@@ -18,12 +18,12 @@ import (
 )
 
 func TestQueues(t *testing.T) {
-	uq := newUserQueues(0, 0)
+	uq := newUserQueues(0, 0, nil)
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
-	uq.addQuerierConnection("querier-1")
-	uq.addQuerierConnection("querier-2")
+	uq.addQuerierConnection("querier-1", "")
+	uq.addQuerierConnection("querier-2", "")
 
 	q, u, lastUserIndex := uq.getNextQueueForQuerier(-1, "querier-1")
 	assert.Nil(t, q)
@@ -76,12 +76,12 @@ func TestQueues(t *testing.T) {
 }
 
 func TestQueuesOnTerminatingQuerier(t *testing.T) {
-	uq := newUserQueues(0, 0)
+	uq := newUserQueues(0, 0, nil)
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
-	uq.addQuerierConnection("querier-1")
-	uq.addQuerierConnection("querier-2")
+	uq.addQuerierConnection("querier-1", "")
+	uq.addQuerierConnection("querier-2", "")
 
 	// Add queues: [one, two]
 	qOne := getOrAdd(t, uq, "one", 0)
@@ -106,7 +106,7 @@ func TestQueuesOnTerminatingQuerier(t *testing.T) {
 }
 
 func TestQueuesWithQueriers(t *testing.T) {
-	uq := newUserQueues(0, 0)
+	uq := newUserQueues(0, 0, nil)
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
@@ -117,7 +117,7 @@ func TestQueuesWithQueriers(t *testing.T) {
 	// Add some queriers.
 	for ix := 0; ix < queriers; ix++ {
 		qid := fmt.Sprintf("querier-%d", ix)
-		uq.addQuerierConnection(qid)
+		uq.addQuerierConnection(qid, "")
 
 		// No querier has any queues yet.
 		q, u, _ := uq.getNextQueueForQuerier(-1, qid)
@@ -183,7 +183,7 @@ func TestQueuesConsistency(t *testing.T) {
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
-			uq := newUserQueues(0, testData.forgetDelay)
+			uq := newUserQueues(0, testData.forgetDelay, nil)
 			assert.NotNil(t, uq)
 			assert.NoError(t, isConsistent(uq))
 
@@ -205,7 +205,7 @@ func TestQueuesConsistency(t *testing.T) {
 					uq.deleteQueue(generateTenant(r))
 				case 3:
 					q := generateQuerier(r)
-					uq.addQuerierConnection(q)
+					uq.addQuerierConnection(q, "")
 					conns[q]++
 				case 4:
 					q := generateQuerier(r)
@@ -232,14 +232,14 @@ func TestQueues_ForgetDelay(t *testing.T) {
 	)
 
 	now := time.Now()
-	uq := newUserQueues(0, forgetDelay)
+	uq := newUserQueues(0, forgetDelay, nil)
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
 	// 3 queriers open 2 connections each.
 	for i := 1; i <= 3; i++ {
-		uq.addQuerierConnection(fmt.Sprintf("querier-%d", i))
-		uq.addQuerierConnection(fmt.Sprintf("querier-%d", i))
+		uq.addQuerierConnection(fmt.Sprintf("querier-%d", i), "")
+		uq.addQuerierConnection(fmt.Sprintf("querier-%d", i), "")
 	}
 
 	// Add user queues.
@@ -267,8 +267,8 @@ func TestQueues_ForgetDelay(t *testing.T) {
 	}
 
 	// Querier-1 reconnects.
-	uq.addQuerierConnection("querier-1")
-	uq.addQuerierConnection("querier-1")
+	uq.addQuerierConnection("querier-1", "")
+	uq.addQuerierConnection("querier-1", "")
 
 	// We expect the initial querier-1 users have got back to querier-1.
 	for _, userID := range querier1Users {
@@ -324,14 +324,14 @@ func TestQueues_ForgetDelay_ShouldCorrectlyHandleQuerierReconnectingBeforeForget
 	)
 
 	now := time.Now()
-	uq := newUserQueues(0, forgetDelay)
+	uq := newUserQueues(0, forgetDelay, nil)
 	assert.NotNil(t, uq)
 	assert.NoError(t, isConsistent(uq))
 
 	// 3 queriers open 2 connections each.
 	for i := 1; i <= 3; i++ {
-		uq.addQuerierConnection(fmt.Sprintf("querier-%d", i))
-		uq.addQuerierConnection(fmt.Sprintf("querier-%d", i))
+		uq.addQuerierConnection(fmt.Sprintf("querier-%d", i), "")
+		uq.addQuerierConnection(fmt.Sprintf("querier-%d", i), "")
 	}
 
 	// Add user queues.
@@ -363,8 +363,8 @@ func TestQueues_ForgetDelay_ShouldCorrectlyHandleQuerierReconnectingBeforeForget
 	uq.forgetDisconnectedQueriers(now.Add(90 * time.Second))
 
 	// Querier-1 reconnects.
-	uq.addQuerierConnection("querier-1")
-	uq.addQuerierConnection("querier-1")
+	uq.addQuerierConnection("querier-1", "")
+	uq.addQuerierConnection("querier-1", "")
 
 	assert.Contains(t, uq.queriers, "querier-1")
 	assert.NoError(t, isConsistent(uq))
@@ -389,6 +389,39 @@ func TestQueues_ForgetDelay_ShouldCorrectlyHandleQuerierReconnectingBeforeForget
 	}
 }
 
+func TestQueues_ZoneAcceptor(t *testing.T) {
+	uq := newUserQueues(0, 0, nil)
+	uq.addQuerierConnection("querier-a", "zone-a")
+	uq.addQuerierConnection("querier-b", "zone-b")
+	uq.addQuerierConnection("querier-no-zone", "")
+
+	q := getOrAdd(t, uq, "user-1", 0)
+	q.enqueue("request-zone-a", 0, "zone-a")
+	q.enqueue("request-zone-b", 0, "zone-b")
+	q.enqueue("request-no-zone", 0, "")
+
+	// Queriers without a zone handle the requests from all zones.
+	assert.Nil(t, uq.zoneAcceptor("querier-no-zone", q))
+
+	// Querier-b doesn't handle the requests from zone-a while querier-a is waiting for a request.
+	uq.setQuerierIdle("querier-a", true)
+	assert.Equal(t, "request-zone-b", q.dequeue(nil, uq.zoneAcceptor("querier-b", q)))
+	assert.Equal(t, "request-no-zone", q.dequeue(nil, uq.zoneAcceptor("querier-b", q)))
+	assert.Nil(t, q.dequeue(nil, uq.zoneAcceptor("querier-b", q)))
+
+	// Queriers shutting down don't handle requests, so the requests from their zone spill over.
+	uq.notifyQuerierShutdown("querier-a")
+	assert.Equal(t, "request-zone-a", q.dequeue(nil, uq.zoneAcceptor("querier-b", q)))
+	assert.Equal(t, 0, q.length)
+
+	// Queriers which can't handle the user requests because of shuffle sharding don't prevent the spill over.
+	uq.addQuerierConnection("querier-a", "zone-a")
+	uq.setQuerierIdle("querier-a", true)
+	q.enqueue("request-zone-a", 0, "zone-a")
+	q.queriers = map[string]struct{}{"querier-b": {}}
+	assert.Equal(t, "request-zone-a", q.dequeue(nil, uq.zoneAcceptor("querier-b", q)))
+}
+
 func generateTenant(r *rand.Rand) string {
 	return fmt.Sprint("tenant-", r.Int()%5)
 }
@@ -397,7 +430,7 @@ func generateQuerier(r *rand.Rand) string {
 	return fmt.Sprint("querier-", r.Int()%5)
 }
 
-func getOrAdd(t *testing.T, uq *queues, tenant string, maxQueriers int) chan Request {
+func getOrAdd(t *testing.T, uq *queues, tenant string, maxQueriers int) *userQueue {
 	q := uq.getOrAddQueue(tenant, maxQueriers)
 	assert.NotNil(t, q)
 	assert.NoError(t, isConsistent(uq))
@@ -405,8 +438,8 @@ func getOrAdd(t *testing.T, uq *queues, tenant string, maxQueriers int) chan Req
 	return q
 }
 
-func confirmOrderForQuerier(t *testing.T, uq *queues, querier string, lastUserIndex int, qs ...chan Request) int {
-	var n chan Request
+func confirmOrderForQuerier(t *testing.T, uq *queues, querier string, lastUserIndex int, qs ...*userQueue) int {
+	var n *userQueue
 	for _, q := range qs {
 		n, _, lastUserIndex = uq.getNextQueueForQuerier(lastUserIndex, querier)
 		assert.Equal(t, q, n)
@@ -8,8 +8,10 @@ package scheduler
 import (
 	"context"
 	"flag"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -72,6 +74,7 @@ type Scheduler struct {
 	connectedFrontendClients prometheus.GaugeFunc
 	queueDuration            prometheus.Histogram
 	inflightRequests         prometheus.Summary
+	crossZoneRequests        prometheus.Counter
 }
 
 type requestKey struct {
@@ -91,6 +94,7 @@ type connectedFrontend struct {
 type Config struct {
 	MaxOutstandingPerTenant int                       `yaml:"max_outstanding_requests_per_tenant"`
 	QuerierForgetDelay      time.Duration             `yaml:"querier_forget_delay" category:"experimental"`
+	PriorityWeights         string                    `yaml:"priority_weights" category:"experimental"`
 	GRPCClientConfig        grpcclient.Config         `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
 	ServiceDiscovery        schedulerdiscovery.Config `yaml:",inline"`
 }
@@ -98,11 +102,15 @@ type Config struct {
 func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.IntVar(&cfg.MaxOutstandingPerTenant, "query-scheduler.max-outstanding-requests-per-tenant", 100, "Maximum number of outstanding requests per tenant per query-scheduler. In-flight requests above this limit will fail with HTTP response status code 429.")
 	f.DurationVar(&cfg.QuerierForgetDelay, "query-scheduler.querier-forget-delay", 0, "If a querier disconnects without sending notification about graceful shutdown, the query-scheduler will keep the querier in the tenant's shard until the forget delay has passed. This feature is useful to reduce the blast radius when shuffle-sharding is enabled.")
+	f.StringVar(&cfg.PriorityWeights, "query-scheduler.priority-weights", "", fmt.Sprintf("Comma-separated list of <priority class>=<weight> pairs, enabling priority lanes in the tenant queues. The query-frontend classifies each query as %s (rule evaluations of the ruler), %s (queries of Grafana dashboard panels) or %s (all other queries), unless the client sets the class with the %s request header. When a querier picks a query of a tenant, queries of a class with weight N are dequeued N times as often as queries of a class with weight 1, while both classes have queued queries. Classes not listed get weight 1. Empty to disable priority lanes.", queue.PriorityClassAlerting, queue.PriorityClassDashboard, queue.PriorityClassAdHoc, queue.PriorityHeader))
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("query-scheduler.grpc-client-config", f)
 	cfg.ServiceDiscovery.RegisterFlags(f, logger)
 }
 
 func (cfg *Config) Validate() error {
+	if _, err := queue.ParsePriorityWeights(cfg.PriorityWeights); err != nil {
+		return errors.Wrap(err, "invalid query-scheduler priority weights")
+	}
 	return cfg.ServiceDiscovery.Validate()
 }
 
@@ -129,13 +137,21 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 		Name: "cortex_query_scheduler_discarded_requests_total",
 		Help: "Total number of query requests discarded.",
 	}, []string{"user"})
-	s.requestQueue = queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, cfg.QuerierForgetDelay, s.queueLength, s.discardedRequests)
+	priorityWeights, err := queue.ParsePriorityWeights(cfg.PriorityWeights)
+	if err != nil {
+		return nil, err
+	}
+	s.requestQueue = queue.NewRequestQueue(cfg.MaxOutstandingPerTenant, cfg.QuerierForgetDelay, priorityWeights, s.queueLength, s.discardedRequests)
 
 	s.queueDuration = promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
 		Name:    "cortex_query_scheduler_queue_duration_seconds",
 		Help:    "Time spend by requests in queue before getting picked up by a querier.",
 		Buckets: prometheus.DefBuckets,
 	})
+	s.crossZoneRequests = promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_query_scheduler_cross_zone_requests_total",
+		Help: "Total number of requests dispatched to a querier in a different availability zone than the query-frontend which enqueued them.",
+	})
 	s.connectedQuerierClients = promauto.With(registerer).NewGaugeFunc(prometheus.GaugeOpts{
 		Name: "cortex_query_scheduler_connected_querier_clients",
 		Help: "Number of querier worker clients currently connected to the query-scheduler.",
@@ -188,6 +204,9 @@ type schedulerRequest struct {
 	request         *httpgrpc.HTTPRequest
 	statsEnabled    bool
 
+	// Availability zone of the frontend which enqueued the request, or empty if unknown.
+	zone string
+
 	enqueueTime time.Time
 
 	ctx       context.Context
@@ -200,7 +219,7 @@ type schedulerRequest struct {
 
 // FrontendLoop handles connection from frontend.
 func (s *Scheduler) FrontendLoop(frontend schedulerpb.SchedulerForFrontend_FrontendLoopServer) error {
-	frontendAddress, frontendCtx, err := s.frontendConnected(frontend)
+	frontendAddress, frontendZone, frontendCtx, err := s.frontendConnected(frontend)
 	if err != nil {
 		return err
 	}
@@ -233,7 +252,7 @@ func (s *Scheduler) FrontendLoop(frontend schedulerpb.SchedulerForFrontend_Front
 
 		switch msg.GetType() {
 		case schedulerpb.ENQUEUE:
-			err = s.enqueueRequest(frontendCtx, frontendAddress, msg)
+			err = s.enqueueRequest(frontendCtx, frontendAddress, frontendZone, msg)
 			switch {
 			case err == nil:
 				resp = &schedulerpb.SchedulerToFrontend{Status: schedulerpb.OK}
@@ -263,13 +282,14 @@ func (s *Scheduler) FrontendLoop(frontend schedulerpb.SchedulerForFrontend_Front
 	return frontend.Send(&schedulerpb.SchedulerToFrontend{Status: schedulerpb.SHUTTING_DOWN})
 }
 
-func (s *Scheduler) frontendConnected(frontend schedulerpb.SchedulerForFrontend_FrontendLoopServer) (string, context.Context, error) {
+// frontendConnected handles the INIT message of a frontend connection, and returns the address and zone of the frontend.
+func (s *Scheduler) frontendConnected(frontend schedulerpb.SchedulerForFrontend_FrontendLoopServer) (string, string, context.Context, error) {
 	msg, err := frontend.Recv()
 	if err != nil {
-		return "", nil, err
+		return "", "", nil, err
 	}
 	if msg.Type != schedulerpb.INIT || msg.FrontendAddress == "" {
-		return "", nil, errors.New("no frontend address")
+		return "", "", nil, errors.New("no frontend address")
 	}
 
 	s.connectedFrontendsMu.Lock()
@@ -285,7 +305,7 @@ func (s *Scheduler) frontendConnected(frontend schedulerpb.SchedulerForFrontend_
 	}
 
 	cf.connections++
-	return msg.FrontendAddress, cf.ctx, nil
+	return msg.FrontendAddress, msg.FrontendZone, cf.ctx, nil
 }
 
 func (s *Scheduler) frontendDisconnected(frontendAddress string) {
@@ -300,7 +320,7 @@ func (s *Scheduler) frontendDisconnected(frontendAddress string) {
 	}
 }
 
-func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr string, msg *schedulerpb.FrontendToScheduler) error {
+func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr, frontendZone string, msg *schedulerpb.FrontendToScheduler) error {
 	// Create new context for this request, to support cancellation.
 	ctx, cancel := context.WithCancel(frontendContext)
 	shouldCancel := true
@@ -326,6 +346,7 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 		queryID:         msg.QueryID,
 		request:         msg.HttpRequest,
 		statsEnabled:    msg.StatsEnabled,
+		zone:            frontendZone,
 	}
 
 	now := time.Now()
@@ -343,7 +364,7 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 	maxQueriers := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, s.limits.MaxQueriersPerUser)
 
 	s.activeUsers.UpdateUserTimestamp(userID, now)
-	return s.requestQueue.EnqueueRequest(userID, req, maxQueriers, func() {
+	return s.requestQueue.EnqueueRequest(userID, req, requestPriority(msg.HttpRequest), frontendZone, maxQueriers, func() {
 		shouldCancel = false
 
 		s.pendingRequestsMu.Lock()
@@ -352,6 +373,22 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 	})
 }
 
+// requestPriority returns the priority of the request, based on the priority class set by the query-frontend.
+// Requests without a valid class get the lowest priority.
+func requestPriority(req *httpgrpc.HTTPRequest) int {
+	if req != nil {
+		for _, h := range req.Headers {
+			if !strings.EqualFold(h.Key, queue.PriorityHeader) || len(h.Values) == 0 {
+				continue
+			}
+			if priority := queue.PriorityClassIndex(strings.ToLower(h.Values[0])); priority >= 0 {
+				return priority
+			}
+		}
+	}
+	return len(queue.PriorityClasses) - 1
+}
+
 // This method doesn't do removal from the queue.
 func (s *Scheduler) cancelRequestAndRemoveFromPending(frontendAddr string, queryID uint64) {
 	s.pendingRequestsMu.Lock()
@@ -374,8 +411,9 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 	}
 
 	querierID := resp.GetQuerierID()
+	querierZone := resp.GetQuerierZone()
 
-	s.requestQueue.RegisterQuerierConnection(querierID)
+	s.requestQueue.RegisterQuerierConnection(querierID, querierZone)
 	defer s.requestQueue.UnregisterQuerierConnection(querierID)
 
 	lastUserIndex := queue.FirstUser()
@@ -418,6 +456,10 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 			continue
 		}
 
+		if r.zone != "" && querierZone != "" && r.zone != querierZone {
+			s.crossZoneRequests.Inc()
+		}
+
 		if err := s.forwardRequestToQuerier(querier, r); err != nil {
 			return err
 		}
@@ -29,6 +29,7 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/grafana/mimir/pkg/frontend/v2/frontendv2pb"
+	"github.com/grafana/mimir/pkg/scheduler/queue"
 	"github.com/grafana/mimir/pkg/scheduler/schedulerpb"
 	"github.com/grafana/mimir/pkg/util/httpgrpcutil"
 )
@@ -40,6 +41,10 @@ func setupScheduler(t *testing.T, reg prometheus.Registerer) (*Scheduler, schedu
 	flagext.DefaultValues(&cfg)
 	cfg.MaxOutstandingPerTenant = testMaxOutstandingPerTenant
 
+	return setupSchedulerWithConfig(t, cfg, reg)
+}
+
+func setupSchedulerWithConfig(t *testing.T, cfg Config, reg prometheus.Registerer) (*Scheduler, schedulerpb.SchedulerForFrontendClient, schedulerpb.SchedulerForQuerierClient) {
 	s, err := NewScheduler(cfg, &limits{queriers: 2}, log.NewNopLogger(), reg)
 	require.NoError(t, err)
 
@@ -101,6 +106,79 @@ func TestSchedulerBasicEnqueue(t *testing.T) {
 	verifyNoPendingRequestsLeft(t, scheduler)
 }
 
+func TestSchedulerEnqueueWithPriorityWeights(t *testing.T) {
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.MaxOutstandingPerTenant = testMaxOutstandingPerTenant
+	cfg.PriorityWeights = "alerting=10,dashboard=5"
+	require.NoError(t, cfg.Validate())
+
+	scheduler, frontendClient, querierClient := setupSchedulerWithConfig(t, cfg, nil)
+
+	frontendLoop := initFrontendLoop(t, frontendClient, "frontend-12345")
+	for queryID, class := range []string{"", "dashboard", "alerting"} {
+		req := &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"}
+		if class != "" {
+			req.Headers = []*httpgrpc.Header{{Key: queue.PriorityHeader, Values: []string{class}}}
+		}
+
+		frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+			Type:        schedulerpb.ENQUEUE,
+			QueryID:     uint64(queryID),
+			UserID:      "test",
+			HttpRequest: req,
+		})
+	}
+
+	// The queries are received from the highest to the lowest priority.
+	querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+	for _, expectedQueryID := range []uint64{2, 1, 0} {
+		msg, err := querierLoop.Recv()
+		require.NoError(t, err)
+		require.Equal(t, expectedQueryID, msg.QueryID)
+		require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+	}
+
+	verifyNoPendingRequestsLeft(t, scheduler)
+}
+
+func TestSchedulerEnqueueWithZones(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	scheduler, frontendClient, querierClient := setupScheduler(t, reg)
+
+	frontendLoop, err := frontendClient.FrontendLoop(context.Background())
+	require.NoError(t, err)
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:            schedulerpb.INIT,
+		FrontendAddress: "frontend-12345",
+		FrontendZone:    "zone-a",
+	})
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:        schedulerpb.ENQUEUE,
+		QueryID:     1,
+		UserID:      "test",
+		HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"},
+	})
+
+	// There is no querier in zone-a, so the query spills over to a querier in another zone.
+	querierLoop, err := querierClient.QuerierLoop(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{QuerierID: "querier-1", QuerierZone: "zone-b"}))
+
+	msg, err := querierLoop.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), msg.QueryID)
+	require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+
+	verifyNoPendingRequestsLeft(t, scheduler)
+
+	require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_scheduler_cross_zone_requests_total Total number of requests dispatched to a querier in a different availability zone than the query-frontend which enqueued them.
+		# TYPE cortex_query_scheduler_cross_zone_requests_total counter
+		cortex_query_scheduler_cross_zone_requests_total 1
+	`), "cortex_query_scheduler_cross_zone_requests_total"))
+}
+
 func TestSchedulerEnqueueWithCancel(t *testing.T) {
 	scheduler, frontendClient, querierClient := setupScheduler(t, nil)
 
@@ -86,6 +86,9 @@ func (SchedulerToFrontendStatus) EnumDescriptor() ([]byte, []int) {
 // To signal that querier is ready to accept another request, querier sends empty message.
 type QuerierToScheduler struct {
 	QuerierID string `protobuf:"bytes,1,opt,name=querierID,proto3" json:"querierID,omitempty"`
+	// Availability zone of the querier, reported when it connects. Used by the scheduler to preferably
+	// dispatch queries to queriers in the same availability zone as the frontend which enqueued them.
+	QuerierZone string `protobuf:"bytes,2,opt,name=querierZone,proto3" json:"querierZone,omitempty"`
 }
 
 func (m *QuerierToScheduler) Reset()      { *m = QuerierToScheduler{} }
@@ -127,6 +130,13 @@ func (m *QuerierToScheduler) GetQuerierID() string {
 	return ""
 }
 
+func (m *QuerierToScheduler) GetQuerierZone() string {
+	if m != nil {
+		return m.QuerierZone
+	}
+	return ""
+}
+
 type SchedulerToQuerier struct {
 	// Query ID as reported by frontend. When querier sends the response back to frontend (using frontendAddress),
 	// it identifies the query by using this ID.
@@ -219,6 +229,8 @@ type FrontendToScheduler struct {
 	UserID       string                `protobuf:"bytes,4,opt,name=userID,proto3" json:"userID,omitempty"`
 	HttpRequest  *httpgrpc.HTTPRequest `protobuf:"bytes,5,opt,name=httpRequest,proto3" json:"httpRequest,omitempty"`
 	StatsEnabled bool                  `protobuf:"varint,6,opt,name=statsEnabled,proto3" json:"statsEnabled,omitempty"`
+	// Used by INIT message. Availability zone of the frontend, applied to all requests enqueued by the frontend.
+	FrontendZone string `protobuf:"bytes,7,opt,name=frontendZone,proto3" json:"frontendZone,omitempty"`
 }
 
 func (m *FrontendToScheduler) Reset()      { *m = FrontendToScheduler{} }
@@ -295,6 +307,13 @@ func (m *FrontendToScheduler) GetStatsEnabled() bool {
 	return false
 }
 
+func (m *FrontendToScheduler) GetFrontendZone() string {
+	if m != nil {
+		return m.FrontendZone
+	}
+	return ""
+}
+
 type SchedulerToFrontend struct {
 	Status SchedulerToFrontendStatus `protobuf:"varint,1,opt,name=status,proto3,enum=schedulerpb.SchedulerToFrontendStatus" json:"status,omitempty"`
 	Error  string                    `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
@@ -438,48 +457,49 @@ func init() {
 func init() { proto.RegisterFile("scheduler.proto", fileDescriptor_2b3fc28395a6d9c5) }
 
 var fileDescriptor_2b3fc28395a6d9c5 = []byte{
-	// 650 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0xcf, 0x4f, 0x13, 0x41,
-	0x14, 0xde, 0x29, 0x6d, 0x81, 0x57, 0x94, 0x75, 0x00, 0xad, 0x0d, 0x0e, 0x4d, 0x63, 0x4c, 0x25,
-	0xb1, 0x35, 0xd5, 0x44, 0x0f, 0xc4, 0xa4, 0xc2, 0x22, 0x8d, 0xb8, 0x85, 0xe9, 0x34, 0xfe, 0xb8,
-	0x34, 0xb4, 0x1d, 0x5a, 0x02, 0xec, 0x2c, 0xb3, 0xbb, 0x92, 0xde, 0x3c, 0x7a, 0xf4, 0xcf, 0xf0,
-	0x4f, 0xf1, 0x62, 0xc2, 0x91, 0x83, 0x07, 0x59, 0x2e, 0x1e, 0xf9, 0x13, 0x0c, 0xd3, 0x6d, 0xdd,
-	0xd6, 0x16, 0xb8, 0xbd, 0xf7, 0xf6, 0xfb, 0x76, 0xde, 0xf7, 0xbd, 0x37, 0x03, 0xb3, 0x4e, 0xa3,
-	0xcd, 0x9b, 0xde, 0x01, 0x97, 0x39, 0x5b, 0x0a, 0x57, 0xe0, 0x44, 0xbf, 0x60, 0xd7, 0x53, 0x4f,
-	0x5a, 0x7b, 0x6e, 0xdb, 0xab, 0xe7, 0x1a, 0xe2, 0x30, 0xdf, 0x12, 0x2d, 0x91, 0x57, 0x98, 0xba,
-	0xb7, 0xab, 0x32, 0x95, 0xa8, 0xa8, 0xcb, 0x4d, 0x3d, 0x0f, 0xc1, 0x8f, 0xf9, 0xce, 0x67, 0x7e,
-	0x2c, 0xe4, 0xbe, 0x93, 0x6f, 0x88, 0xc3, 0x43, 0x61, 0xe5, 0xdb, 0xae, 0x6b, 0xb7, 0xa4, 0xdd,
-	0xe8, 0x07, 0x5d, 0x56, 0xa6, 0x00, 0x78, 0xdb, 0xe3, 0x72, 0x8f, 0x4b, 0x26, 0x2a, 0xbd, 0xc3,
-	0xf1, 0x22, 0x4c, 0x1f, 0x75, 0xab, 0xa5, 0xb5, 0x24, 0x4a, 0xa3, 0xec, 0x34, 0xfd, 0x57, 0xc8,
-	0xfc, 0x44, 0x80, 0xfb, 0x58, 0x26, 0x02, 0x3e, 0x4e, 0xc2, 0xe4, 0x25, 0xa6, 0x13, 0x50, 0xa2,
-	0xb4, 0x97, 0xe2, 0x17, 0x90, 0xb8, 0x3c, 0x96, 0xf2, 0x23, 0x8f, 0x3b, 0x6e, 0x32, 0x92, 0x46,
-	0xd9, 0x44, 0x61, 0x21, 0xd7, 0x6f, 0x65, 0x83, 0xb1, 0xad, 0xe0, 0x23, 0x0d, 0x23, 0x71, 0x16,
-	0x66, 0x77, 0xa5, 0xb0, 0x5c, 0x6e, 0x35, 0x8b, 0xcd, 0xa6, 0xe4, 0x8e, 0x93, 0x9c, 0x50, 0xdd,
-	0x0c, 0x97, 0xf1, 0x5d, 0x88, 0x7b, 0x8e, 0x6a, 0x37, 0xaa, 0x00, 0x41, 0x86, 0x33, 0x30, 0xe3,
-	0xb8, 0x3b, 0xae, 0x63, 0x58, 0x3b, 0xf5, 0x03, 0xde, 0x4c, 0xc6, 0xd2, 0x28, 0x3b, 0x45, 0x07,
-	0x6a, 0x99, 0xaf, 0x11, 0x98, 0x5b, 0x0f, 0xfe, 0x17, 0x76, 0xe1, 0x25, 0x44, 0xdd, 0x8e, 0xcd,
-	0x95, 0x9a, 0xdb, 0x85, 0x87, 0xb9, 0xd0, 0x70, 0x72, 0x23, 0xf0, 0xac, 0x63, 0x73, 0xaa, 0x18,
-	0xa3, 0xfa, 0x8e, 0x8c, 0xee, 0x3b, 0x64, 0xda, 0xc4, 0xa0, 0x69, 0xe3, 0x14, 0x0d, 0x99, 0x19,
-	0xbb, 0xb1, 0x99, 0xc3, 0x56, 0xc4, 0x47, 0x58, 0xb1, 0x0f, 0x73, 0xa1, 0xc9, 0xf6, 0x44, 0xe2,
-	0x57, 0x10, 0xbf, 0x84, 0x79, 0x4e, 0xe0, 0xc5, 0xa3, 0x01, 0x2f, 0x46, 0x30, 0x2a, 0x0a, 0x4d,
-	0x03, 0x16, 0x9e, 0x87, 0x18, 0x97, 0x52, 0xc8, 0xc0, 0x85, 0x6e, 0x92, 0x59, 0x81, 0x45, 0x53,
-	0xb8, 0x7b, 0xbb, 0x9d, 0x60, 0x83, 0x2a, 0x6d, 0xcf, 0x6d, 0x8a, 0x63, 0xab, 0xd7, 0xf0, 0xd5,
-	0x5b, 0xb8, 0x04, 0x0f, 0xc6, 0xb0, 0x1d, 0x5b, 0x58, 0x0e, 0x5f, 0x5e, 0x81, 0x7b, 0x63, 0xa6,
-	0x84, 0xa7, 0x20, 0x5a, 0x32, 0x4b, 0x4c, 0xd7, 0x70, 0x02, 0x26, 0x0d, 0x73, 0xbb, 0x6a, 0x54,
-	0x0d, 0x1d, 0x61, 0x80, 0xf8, 0x6a, 0xd1, 0x5c, 0x35, 0x36, 0xf5, 0xc8, 0x72, 0x03, 0xee, 0x8f,
-	0xd5, 0x85, 0xe3, 0x10, 0x29, 0xbf, 0xd5, 0x35, 0x9c, 0x86, 0x45, 0x56, 0x2e, 0xd7, 0xde, 0x15,
-	0xcd, 0x8f, 0x35, 0x6a, 0x6c, 0x57, 0x8d, 0x0a, 0xab, 0xd4, 0xb6, 0x0c, 0x5a, 0x63, 0x86, 0x59,
-	0x34, 0x99, 0x8e, 0xf0, 0x34, 0xc4, 0x0c, 0x4a, 0xcb, 0x54, 0x8f, 0xe0, 0x3b, 0x70, 0xab, 0xb2,
-	0x51, 0x65, 0xac, 0x64, 0xbe, 0xa9, 0xad, 0x95, 0xdf, 0x9b, 0xfa, 0x44, 0xe1, 0x17, 0x0a, 0xf9,
-	0xbd, 0x2e, 0x64, 0xef, 0x2a, 0x55, 0x21, 0x11, 0x84, 0x9b, 0x42, 0xd8, 0x78, 0x69, 0xc0, 0xee,
-	0xff, 0xef, 0x6b, 0x6a, 0x69, 0xdc, 0x3c, 0x02, 0x6c, 0x46, 0xcb, 0xa2, 0xa7, 0x08, 0x5b, 0xb0,
-	0x30, 0xd2, 0x32, 0xfc, 0x78, 0x80, 0x7f, 0xd5, 0x50, 0x52, 0xcb, 0x37, 0x81, 0x76, 0x27, 0x50,
-	0xb0, 0x61, 0x3e, 0xac, 0xae, 0xbf, 0x4e, 0x1f, 0x60, 0xa6, 0x17, 0x2b, 0x7d, 0xe9, 0xeb, 0xae,
-	0x56, 0x2a, 0x7d, 0xdd, 0xc2, 0x75, 0x15, 0xbe, 0x2e, 0x9e, 0x9c, 0x11, 0xed, 0xf4, 0x8c, 0x68,
-	0x17, 0x67, 0x04, 0x7d, 0xf1, 0x09, 0xfa, 0xee, 0x13, 0xf4, 0xc3, 0x27, 0xe8, 0xc4, 0x27, 0xe8,
-	0xb7, 0x4f, 0xd0, 0x1f, 0x9f, 0x68, 0x17, 0x3e, 0x41, 0xdf, 0xce, 0x89, 0x76, 0x72, 0x4e, 0xb4,
-	0xd3, 0x73, 0xa2, 0x7d, 0x0a, 0x3f, 0xbb, 0xf5, 0xb8, 0x7a, 0x18, 0x9f, 0xfd, 0x0d, 0x00, 0x00,
-	0xff, 0xff, 0x49, 0x11, 0xf9, 0x34, 0x9d, 0x05, 0x00, 0x00,
+	// 671 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x54, 0x4f, 0x4f, 0x13, 0x41,
+	0x14, 0xdf, 0x29, 0x6d, 0x81, 0x57, 0x94, 0x75, 0x00, 0xad, 0x0d, 0x0e, 0xcd, 0xc6, 0x98, 0x4a,
+	0x62, 0x6b, 0xaa, 0x89, 0x1e, 0x88, 0x49, 0x85, 0x45, 0x1a, 0x71, 0x0b, 0xdb, 0x69, 0x54, 0x2e,
+	0x0d, 0x6d, 0x87, 0x96, 0x00, 0x3b, 0xcb, 0xfe, 0x91, 0xf4, 0xe6, 0x47, 0xf0, 0x63, 0x78, 0xf0,
+	0x83, 0x78, 0x31, 0xe1, 0xc8, 0xc1, 0x83, 0x2c, 0x17, 0x8f, 0x7c, 0x04, 0xd3, 0xe9, 0x6e, 0xdd,
+	0x2d, 0x2d, 0x70, 0x7b, 0xef, 0xcd, 0xef, 0xbd, 0x79, 0xbf, 0xdf, 0x7b, 0x33, 0x30, 0x6b, 0x37,
+	0x3b, 0xac, 0xe5, 0x1e, 0x32, 0x2b, 0x6f, 0x5a, 0xdc, 0xe1, 0x38, 0x35, 0x08, 0x98, 0x8d, 0xcc,
+	0xb3, 0xf6, 0xbe, 0xd3, 0x71, 0x1b, 0xf9, 0x26, 0x3f, 0x2a, 0xb4, 0x79, 0x9b, 0x17, 0x04, 0xa6,
+	0xe1, 0xee, 0x09, 0x4f, 0x38, 0xc2, 0xea, 0xe7, 0x66, 0x5e, 0x86, 0xe0, 0x27, 0x6c, 0xf7, 0x0b,
+	0x3b, 0xe1, 0xd6, 0x81, 0x5d, 0x68, 0xf2, 0xa3, 0x23, 0x6e, 0x14, 0x3a, 0x8e, 0x63, 0xb6, 0x2d,
+	0xb3, 0x39, 0x30, 0xfa, 0x59, 0x0a, 0x05, 0xbc, 0xed, 0x32, 0x6b, 0x9f, 0x59, 0x94, 0x57, 0x83,
+	0xcb, 0xf1, 0x22, 0x4c, 0x1f, 0xf7, 0xa3, 0xe5, 0xb5, 0x34, 0xca, 0xa2, 0xdc, 0xb4, 0xfe, 0x3f,
+	0x80, 0xb3, 0x90, 0xf2, 0x9d, 0x1d, 0x6e, 0xb0, 0x74, 0x4c, 0x9c, 0x87, 0x43, 0xca, 0x2f, 0x04,
+	0x78, 0x50, 0x8d, 0x72, 0xff, 0x06, 0x9c, 0x86, 0xc9, 0x1e, 0xaa, 0xeb, 0x17, 0x8d, 0xeb, 0x81,
+	0x8b, 0x5f, 0x41, 0xaa, 0xd7, 0x98, 0xce, 0x8e, 0x5d, 0x66, 0x3b, 0xa2, 0x64, 0xaa, 0xb8, 0x90,
+	0x1f, 0x34, 0xbb, 0x41, 0xe9, 0x96, 0x7f, 0xa8, 0x87, 0x91, 0x38, 0x07, 0xb3, 0x7b, 0x16, 0x37,
+	0x1c, 0x66, 0xb4, 0x4a, 0xad, 0x96, 0xc5, 0x6c, 0x3b, 0x3d, 0x21, 0xfa, 0x19, 0x0e, 0xe3, 0xfb,
+	0x90, 0x74, 0x6d, 0x41, 0x28, 0x2e, 0x00, 0xbe, 0x87, 0x15, 0x98, 0xb1, 0x9d, 0x5d, 0xc7, 0x56,
+	0x8d, 0xdd, 0xc6, 0x21, 0x6b, 0xa5, 0x13, 0x59, 0x94, 0x9b, 0xd2, 0x23, 0x31, 0xe5, 0x47, 0x0c,
+	0xe6, 0xd6, 0xfd, 0x7a, 0x61, 0x9d, 0x5e, 0x43, 0xdc, 0xe9, 0x9a, 0x4c, 0xb0, 0xb9, 0x5b, 0x7c,
+	0x9c, 0x0f, 0x8d, 0x2f, 0x3f, 0x02, 0x4f, 0xbb, 0x26, 0xd3, 0x45, 0xc6, 0xa8, 0xbe, 0x63, 0xa3,
+	0xfb, 0x0e, 0x89, 0x36, 0x11, 0x15, 0x6d, 0x1c, 0xa3, 0x21, 0x31, 0x13, 0xb7, 0x16, 0x73, 0x58,
+	0x8a, 0xe4, 0x55, 0x29, 0x7a, 0x98, 0xa0, 0x43, 0x31, 0xfd, 0x49, 0x71, 0x75, 0x24, 0xa6, 0x1c,
+	0xc0, 0x5c, 0x68, 0xfa, 0x81, 0x10, 0xf8, 0x0d, 0x24, 0x7b, 0xa5, 0x5c, 0xdb, 0xd7, 0xeb, 0x49,
+	0x44, 0xaf, 0x11, 0x19, 0x55, 0x81, 0xd6, 0xfd, 0x2c, 0x3c, 0x0f, 0x09, 0x66, 0x59, 0xdc, 0xf2,
+	0x95, 0xea, 0x3b, 0xca, 0x0a, 0x2c, 0x6a, 0xdc, 0xd9, 0xdf, 0xeb, 0xfa, 0x5b, 0x56, 0xed, 0xb8,
+	0x4e, 0x8b, 0x9f, 0x18, 0x01, 0xa9, 0x6b, 0x77, 0x59, 0x59, 0x82, 0x47, 0x63, 0xb2, 0x6d, 0x93,
+	0x1b, 0x36, 0x5b, 0x5e, 0x81, 0x07, 0x63, 0x26, 0x89, 0xa7, 0x20, 0x5e, 0xd6, 0xca, 0x54, 0x96,
+	0x70, 0x0a, 0x26, 0x55, 0x6d, 0xbb, 0xa6, 0xd6, 0x54, 0x19, 0x61, 0x80, 0xe4, 0x6a, 0x49, 0x5b,
+	0x55, 0x37, 0xe5, 0xd8, 0x72, 0x13, 0x1e, 0x8e, 0xe5, 0x85, 0x93, 0x10, 0xab, 0xbc, 0x97, 0x25,
+	0x9c, 0x85, 0x45, 0x5a, 0xa9, 0xd4, 0x3f, 0x94, 0xb4, 0xcf, 0x75, 0x5d, 0xdd, 0xae, 0xa9, 0x55,
+	0x5a, 0xad, 0x6f, 0xa9, 0x7a, 0x9d, 0xaa, 0x5a, 0x49, 0xa3, 0x32, 0xc2, 0xd3, 0x90, 0x50, 0x75,
+	0xbd, 0xa2, 0xcb, 0x31, 0x7c, 0x0f, 0xee, 0x54, 0x37, 0x6a, 0x94, 0x96, 0xb5, 0x77, 0xf5, 0xb5,
+	0xca, 0x47, 0x4d, 0x9e, 0x28, 0xfe, 0x46, 0x21, 0xbd, 0xd7, 0xb9, 0x15, 0x3c, 0xb7, 0x1a, 0xa4,
+	0x7c, 0x73, 0x93, 0x73, 0x13, 0x2f, 0x45, 0xe4, 0xbe, 0xfa, 0xea, 0x33, 0x4b, 0xe3, 0xe6, 0xe1,
+	0x63, 0x15, 0x29, 0x87, 0x9e, 0x23, 0x6c, 0xc0, 0xc2, 0x48, 0xc9, 0xf0, 0xd3, 0x48, 0xfe, 0x75,
+	0x43, 0xc9, 0x2c, 0xdf, 0x06, 0xda, 0x9f, 0x40, 0xd1, 0x84, 0xf9, 0x30, 0xbb, 0xc1, 0x3a, 0x7d,
+	0x82, 0x99, 0xc0, 0x16, 0xfc, 0xb2, 0x37, 0x3d, 0xbf, 0x4c, 0xf6, 0xa6, 0x85, 0xeb, 0x33, 0x7c,
+	0x5b, 0x3a, 0x3d, 0x27, 0xd2, 0xd9, 0x39, 0x91, 0x2e, 0xcf, 0x09, 0xfa, 0xea, 0x11, 0xf4, 0xdd,
+	0x23, 0xe8, 0xa7, 0x47, 0xd0, 0xa9, 0x47, 0xd0, 0x1f, 0x8f, 0xa0, 0xbf, 0x1e, 0x91, 0x2e, 0x3d,
+	0x82, 0xbe, 0x5d, 0x10, 0xe9, 0xf4, 0x82, 0x48, 0x67, 0x17, 0x44, 0xda, 0x09, 0x7f, 0xde, 0x8d,
+	0xa4, 0xf8, 0x5e, 0x5f, 0xfc, 0x1b, 0x00, 0xd2, 0x7a, 0xa6, 0x82, 0xe3, 0x05, 0x00, 0x00,
 }
 
 func (x FrontendToSchedulerType) String() string {
@@ -518,6 +538,9 @@ func (this *QuerierToScheduler) Equal(that interface{}) bool {
 	if this.QuerierID != that1.QuerierID {
 		return false
 	}
+	if this.QuerierZone != that1.QuerierZone {
+		return false
+	}
 	return true
 }
 func (this *SchedulerToQuerier) Equal(that interface{}) bool {
@@ -593,6 +616,9 @@ func (this *FrontendToScheduler) Equal(that interface{}) bool {
 	if this.StatsEnabled != that1.StatsEnabled {
 		return false
 	}
+	if this.FrontendZone != that1.FrontendZone {
+		return false
+	}
 	return true
 }
 func (this *SchedulerToFrontend) Equal(that interface{}) bool {
@@ -671,9 +697,10 @@ func (this *QuerierToScheduler) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 5)
+	s := make([]string, 0, 6)
 	s = append(s, "&schedulerpb.QuerierToScheduler{")
 	s = append(s, "QuerierID: "+fmt.Sprintf("%#v", this.QuerierID)+",\n")
+	s = append(s, "QuerierZone: "+fmt.Sprintf("%#v", this.QuerierZone)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -697,7 +724,7 @@ func (this *FrontendToScheduler) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 10)
+	s := make([]string, 0, 11)
 	s = append(s, "&schedulerpb.FrontendToScheduler{")
 	s = append(s, "Type: "+fmt.Sprintf("%#v", this.Type)+",\n")
 	s = append(s, "FrontendAddress: "+fmt.Sprintf("%#v", this.FrontendAddress)+",\n")
@@ -707,6 +734,7 @@ func (this *FrontendToScheduler) GoString() string {
 		s = append(s, "HttpRequest: "+fmt.Sprintf("%#v", this.HttpRequest)+",\n")
 	}
 	s = append(s, "StatsEnabled: "+fmt.Sprintf("%#v", this.StatsEnabled)+",\n")
+	s = append(s, "FrontendZone: "+fmt.Sprintf("%#v", this.FrontendZone)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1048,6 +1076,13 @@ func (m *QuerierToScheduler) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.QuerierZone) > 0 {
+		i -= len(m.QuerierZone)
+		copy(dAtA[i:], m.QuerierZone)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.QuerierZone)))
+		i--
+		dAtA[i] = 0x12
+	}
 	if len(m.QuerierID) > 0 {
 		i -= len(m.QuerierID)
 		copy(dAtA[i:], m.QuerierID)
@@ -1142,6 +1177,13 @@ func (m *FrontendToScheduler) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.FrontendZone) > 0 {
+		i -= len(m.FrontendZone)
+		copy(dAtA[i:], m.FrontendZone)
+		i = encodeVarintScheduler(dAtA, i, uint64(len(m.FrontendZone)))
+		i--
+		dAtA[i] = 0x3a
+	}
 	if m.StatsEnabled {
 		i--
 		if m.StatsEnabled {
@@ -1300,6 +1342,10 @@ func (m *QuerierToScheduler) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovScheduler(uint64(l))
 	}
+	l = len(m.QuerierZone)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
 	return n
 }
 
@@ -1357,6 +1403,10 @@ func (m *FrontendToScheduler) Size() (n int) {
 	if m.StatsEnabled {
 		n += 2
 	}
+	l = len(m.FrontendZone)
+	if l > 0 {
+		n += 1 + l + sovScheduler(uint64(l))
+	}
 	return n
 }
 
@@ -1410,6 +1460,7 @@ func (this *QuerierToScheduler) String() string {
 	}
 	s := strings.Join([]string{`&QuerierToScheduler{`,
 		`QuerierID:` + fmt.Sprintf("%v", this.QuerierID) + `,`,
+		`QuerierZone:` + fmt.Sprintf("%v", this.QuerierZone) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1439,6 +1490,7 @@ func (this *FrontendToScheduler) String() string {
 		`UserID:` + fmt.Sprintf("%v", this.UserID) + `,`,
 		`HttpRequest:` + strings.Replace(fmt.Sprintf("%v", this.HttpRequest), "HTTPRequest", "httpgrpc.HTTPRequest", 1) + `,`,
 		`StatsEnabled:` + fmt.Sprintf("%v", this.StatsEnabled) + `,`,
+		`FrontendZone:` + fmt.Sprintf("%v", this.FrontendZone) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1542,6 +1594,38 @@ func (m *QuerierToScheduler) Unmarshal(dAtA []byte) error {
 			}
 			m.QuerierID = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QuerierZone", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.QuerierZone = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
@@ -1945,6 +2029,38 @@ func (m *FrontendToScheduler) Unmarshal(dAtA []byte) error {
 				}
 			}
 			m.StatsEnabled = bool(v != 0)
+		case 7:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FrontendZone", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowScheduler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthScheduler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.FrontendZone = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipScheduler(dAtA[iNdEx:])
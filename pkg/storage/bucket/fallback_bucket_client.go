@@ -0,0 +1,167 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucket
+
+import (
+	"context"
+	"io"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/multierror"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+)
+
+// FallbackBucketClient is a wrapper around a primary objstore.Bucket which reads the objects from
+// a secondary bucket, for example a replica of the primary bucket in another region, when a read
+// from the primary bucket fails. Objects not found in the primary bucket are not read from the
+// secondary bucket. Listing, uploads and deletions are issued to the primary bucket only.
+type FallbackBucketClient struct {
+	primary   objstore.Bucket
+	secondary objstore.Bucket
+	logger    log.Logger
+	metrics   *fallbackBucketMetrics
+}
+
+type fallbackBucketMetrics struct {
+	fallbacks        *prometheus.CounterVec
+	fallbackFailures *prometheus.CounterVec
+}
+
+// NewFallbackBucketClient makes a new FallbackBucketClient.
+func NewFallbackBucketClient(primary, secondary objstore.Bucket, logger log.Logger, reg prometheus.Registerer) *FallbackBucketClient {
+	return &FallbackBucketClient{
+		primary:   primary,
+		secondary: secondary,
+		logger:    logger,
+		metrics: &fallbackBucketMetrics{
+			fallbacks: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+				Name: "cortex_bucket_fallback_operations_total",
+				Help: "Total number of read operations issued to the secondary bucket because the operation against the primary bucket failed.",
+			}, []string{"operation"}),
+			fallbackFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+				Name: "cortex_bucket_fallback_operation_failures_total",
+				Help: "Total number of read operations issued to the secondary bucket which failed too.",
+			}, []string{"operation"}),
+		},
+	}
+}
+
+// shouldFallback returns whether an operation against the primary bucket which failed with err
+// should be issued to the secondary bucket.
+func (b *FallbackBucketClient) shouldFallback(ctx context.Context, err error) bool {
+	return err != nil && ctx.Err() == nil && !b.primary.IsObjNotFoundErr(err)
+}
+
+// fallback records an operation issued to the secondary bucket after failing against the primary
+// bucket with primaryErr, and completed with err.
+func (b *FallbackBucketClient) fallback(op, name string, primaryErr, err error) {
+	b.metrics.fallbacks.WithLabelValues(op).Inc()
+	if err != nil && !b.secondary.IsObjNotFoundErr(err) {
+		b.metrics.fallbackFailures.WithLabelValues(op).Inc()
+	}
+	level.Warn(b.logger).Log("msg", "operation against the primary bucket failed, read from the secondary bucket", "operation", op, "object", name, "primary_err", primaryErr, "err", err)
+}
+
+// Close implements io.Closer
+func (b *FallbackBucketClient) Close() error {
+	var me multierror.MultiError
+	me.Add(b.primary.Close())
+	me.Add(b.secondary.Close())
+	return me.Err()
+}
+
+// Upload the contents of the reader as an object into the primary bucket.
+func (b *FallbackBucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	return b.primary.Upload(ctx, name, r)
+}
+
+// Delete removes the object with the given name from the primary bucket.
+func (b *FallbackBucketClient) Delete(ctx context.Context, name string) error {
+	return b.primary.Delete(ctx, name)
+}
+
+// Name returns the primary bucket name for the provider.
+func (b *FallbackBucketClient) Name() string { return b.primary.Name() }
+
+// Iter calls f for each entry in the given directory of the primary bucket (not recursive.).
+func (b *FallbackBucketClient) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	return b.primary.Iter(ctx, dir, f, options...)
+}
+
+// Get returns a reader for the given object name.
+func (b *FallbackBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	reader, err := b.primary.Get(ctx, name)
+	if !b.shouldFallback(ctx, err) {
+		return reader, err
+	}
+
+	reader, secondaryErr := b.secondary.Get(ctx, name)
+	b.fallback(objstore.OpGet, name, err, secondaryErr)
+	return reader, secondaryErr
+}
+
+// GetRange returns a new range reader for the given object name and range.
+func (b *FallbackBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	reader, err := b.primary.GetRange(ctx, name, off, length)
+	if !b.shouldFallback(ctx, err) {
+		return reader, err
+	}
+
+	reader, secondaryErr := b.secondary.GetRange(ctx, name, off, length)
+	b.fallback(objstore.OpGetRange, name, err, secondaryErr)
+	return reader, secondaryErr
+}
+
+// Exists checks if the given object exists in the bucket.
+func (b *FallbackBucketClient) Exists(ctx context.Context, name string) (bool, error) {
+	exists, err := b.primary.Exists(ctx, name)
+	if !b.shouldFallback(ctx, err) {
+		return exists, err
+	}
+
+	exists, secondaryErr := b.secondary.Exists(ctx, name)
+	b.fallback(objstore.OpExists, name, err, secondaryErr)
+	return exists, secondaryErr
+}
+
+// IsObjNotFoundErr returns true if error means that object is not found, in either bucket.
+func (b *FallbackBucketClient) IsObjNotFoundErr(err error) bool {
+	return b.primary.IsObjNotFoundErr(err) || b.secondary.IsObjNotFoundErr(err)
+}
+
+// Attributes returns attributes of the specified object.
+func (b *FallbackBucketClient) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	attrs, err := b.primary.Attributes(ctx, name)
+	if !b.shouldFallback(ctx, err) {
+		return attrs, err
+	}
+
+	attrs, secondaryErr := b.secondary.Attributes(ctx, name)
+	b.fallback(objstore.OpAttributes, name, err, secondaryErr)
+	return attrs, secondaryErr
+}
+
+// WithExpectedErrs implements objstore.InstrumentedBucket.
+func (b *FallbackBucketClient) WithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.Bucket {
+	return &FallbackBucketClient{
+		primary:   withExpectedErrs(b.primary, fn),
+		secondary: withExpectedErrs(b.secondary, fn),
+		logger:    b.logger,
+		metrics:   b.metrics,
+	}
+}
+
+// ReaderWithExpectedErrs implements objstore.InstrumentedBucketReader.
+func (b *FallbackBucketClient) ReaderWithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.BucketReader {
+	return b.WithExpectedErrs(fn)
+}
+
+func withExpectedErrs(bkt objstore.Bucket, fn objstore.IsOpFailureExpectedFunc) objstore.Bucket {
+	if ib, ok := bkt.(objstore.InstrumentedBucket); ok {
+		return ib.WithExpectedErrs(fn)
+	}
+	return bkt
+}
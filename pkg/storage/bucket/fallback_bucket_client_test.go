@@ -0,0 +1,132 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+)
+
+func TestFallbackBucketClient(t *testing.T) {
+	errUnavailable := errors.New("primary bucket unavailable")
+
+	setup := func(t *testing.T) (*FallbackBucketClient, *prometheus.Registry) {
+		primary := &ClientMock{}
+		primary.MockGet("primary", "primary content", nil)
+		primary.MockGet("missing", "", nil)
+		primary.On("Get", mock.Anything, "failing").Return(nil, errUnavailable)
+		primary.On("Get", mock.Anything, "failing-everywhere").Return(nil, errUnavailable)
+		primary.On("GetRange", mock.Anything, "failing", int64(2), int64(3)).Return(io.NopCloser(bytes.NewReader(nil)), errUnavailable)
+		primary.On("Exists", mock.Anything, "failing").Return(false, errUnavailable)
+		primary.On("Attributes", mock.Anything, "failing").Return(objstore.ObjectAttributes{}, errUnavailable)
+		primary.MockIter("", []string{"primary"}, nil)
+
+		secondary := objstore.NewInMemBucket()
+		require.NoError(t, secondary.Upload(context.Background(), "primary", strings.NewReader("secondary content")))
+		require.NoError(t, secondary.Upload(context.Background(), "failing", strings.NewReader("secondary content")))
+
+		reg := prometheus.NewPedanticRegistry()
+		return NewFallbackBucketClient(primary, secondary, log.NewNopLogger(), reg), reg
+	}
+
+	readAll := func(t *testing.T, reader io.ReadCloser) string {
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		return string(data)
+	}
+
+	t.Run("should read from the primary bucket if the read succeeds", func(t *testing.T) {
+		client, _ := setup(t)
+
+		reader, err := client.Get(context.Background(), "primary")
+		require.NoError(t, err)
+		assert.Equal(t, "primary content", readAll(t, reader))
+		assert.Equal(t, 0, testutil.CollectAndCount(client.metrics.fallbacks))
+	})
+
+	t.Run("should not read from the secondary bucket if the object doesn't exist in the primary bucket", func(t *testing.T) {
+		client, _ := setup(t)
+
+		_, err := client.Get(context.Background(), "missing")
+		require.Error(t, err)
+		assert.True(t, client.IsObjNotFoundErr(err))
+		assert.Equal(t, 0, testutil.CollectAndCount(client.metrics.fallbacks))
+	})
+
+	t.Run("should read from the secondary bucket if the primary bucket read fails", func(t *testing.T) {
+		client, reg := setup(t)
+		ctx := context.Background()
+
+		reader, err := client.Get(ctx, "failing")
+		require.NoError(t, err)
+		assert.Equal(t, "secondary content", readAll(t, reader))
+
+		reader, err = client.GetRange(ctx, "failing", 2, 3)
+		require.NoError(t, err)
+		assert.Equal(t, "con", readAll(t, reader))
+
+		exists, err := client.Exists(ctx, "failing")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		attrs, err := client.Attributes(ctx, "failing")
+		require.NoError(t, err)
+		assert.Equal(t, int64(len("secondary content")), attrs.Size)
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_bucket_fallback_operations_total Total number of read operations issued to the secondary bucket because the operation against the primary bucket failed.
+			# TYPE cortex_bucket_fallback_operations_total counter
+			cortex_bucket_fallback_operations_total{operation="attributes"} 1
+			cortex_bucket_fallback_operations_total{operation="exists"} 1
+			cortex_bucket_fallback_operations_total{operation="get"} 1
+			cortex_bucket_fallback_operations_total{operation="get_range"} 1
+		`), "cortex_bucket_fallback_operations_total", "cortex_bucket_fallback_operation_failures_total"))
+	})
+
+	t.Run("should return the secondary bucket error if both reads fail", func(t *testing.T) {
+		client, _ := setup(t)
+
+		_, err := client.Get(context.Background(), "failing-everywhere")
+		require.Error(t, err)
+		assert.True(t, client.IsObjNotFoundErr(err))
+
+		// The object is not found in the secondary bucket, so the fallback isn't counted as failed.
+		assert.Equal(t, float64(1), testutil.ToFloat64(client.metrics.fallbacks.WithLabelValues(objstore.OpGet)))
+		assert.Equal(t, 0, testutil.CollectAndCount(client.metrics.fallbackFailures))
+	})
+
+	t.Run("should not read from the secondary bucket if the context is canceled", func(t *testing.T) {
+		client, _ := setup(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := client.Get(ctx, "failing")
+		require.ErrorIs(t, err, errUnavailable)
+		assert.Equal(t, 0, testutil.CollectAndCount(client.metrics.fallbacks))
+	})
+
+	t.Run("should list the objects of the primary bucket only", func(t *testing.T) {
+		client, _ := setup(t)
+
+		var names []string
+		require.NoError(t, client.Iter(context.Background(), "", func(name string) error {
+			names = append(names, name)
+			return nil
+		}))
+		assert.Equal(t, []string{"primary"}, names)
+	})
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package tsdb
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/units"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/mimir/pkg/cache"
+	"github.com/grafana/mimir/pkg/storegateway/chunkscache"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	// ChunkRangesCacheBackendInMemory is the value for the in-memory chunk ranges cache backend.
+	ChunkRangesCacheBackendInMemory = "inmemory"
+
+	// ChunkRangesCacheBackendMemcached is the value for the memcached chunk ranges cache backend.
+	ChunkRangesCacheBackendMemcached = cache.BackendMemcached
+)
+
+var (
+	supportedChunkRangesCacheBackends = []string{ChunkRangesCacheBackendInMemory, ChunkRangesCacheBackendMemcached}
+
+	errUnsupportedChunkRangesCacheBackend = errors.New("unsupported chunk ranges cache backend")
+	errInvalidChunkRangesCacheMaxItemSize = errors.New("the chunk ranges cache max item size must be greater than 0")
+)
+
+type ChunkRangesCacheConfig struct {
+	cache.BackendConfig `yaml:",inline"`
+	InMemory            InMemoryChunkRangesCacheConfig `yaml:"inmemory"`
+
+	TTL              time.Duration `yaml:"ttl" category:"experimental"`
+	MaxItemSizeBytes int64         `yaml:"max_item_size_bytes" category:"experimental"`
+}
+
+func (cfg *ChunkRangesCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.StringVar(&cfg.Backend, prefix+"backend", "", fmt.Sprintf("Backend for the cache of the chunk ranges read by the store-gateway, if not empty. Repeated queries reading the same chunk ranges are served from the cache instead of the object storage. Supported values: %s.", strings.Join(supportedChunkRangesCacheBackends, ", ")))
+
+	cfg.InMemory.RegisterFlagsWithPrefix(f, prefix+"inmemory.")
+	cfg.Memcached.RegisterFlagsWithPrefix(f, prefix+"memcached.")
+
+	f.DurationVar(&cfg.TTL, prefix+"ttl", 24*time.Hour, "TTL for caching the chunk ranges.")
+	f.Int64Var(&cfg.MaxItemSizeBytes, prefix+"max-item-size-bytes", int64(units.MiB), "Max size - in bytes - of a chunk range to cache. Larger chunk ranges are read from the object storage without caching them. If the memcached backend is used, this should not exceed its max item size.")
+}
+
+// Validate the config.
+func (cfg *ChunkRangesCacheConfig) Validate() error {
+	if cfg.Backend == "" {
+		return nil
+	}
+	if !util.StringsContain(supportedChunkRangesCacheBackends, cfg.Backend) {
+		return errUnsupportedChunkRangesCacheBackend
+	}
+	if cfg.MaxItemSizeBytes <= 0 {
+		return errInvalidChunkRangesCacheMaxItemSize
+	}
+
+	if cfg.Backend == ChunkRangesCacheBackendMemcached {
+		if err := cfg.Memcached.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type InMemoryChunkRangesCacheConfig struct {
+	MaxSizeBytes uint64 `yaml:"max_size_bytes" category:"experimental"`
+}
+
+func (cfg *InMemoryChunkRangesCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.Uint64Var(&cfg.MaxSizeBytes, prefix+"max-size-bytes", uint64(1*units.Gibibyte), "Maximum size in bytes of the in-memory chunk ranges cache (shared between all tenants).")
+}
+
+// NewChunkRangesCache creates a new chunk ranges cache based on the input configuration.
+// It returns nil if no backend is configured.
+func NewChunkRangesCache(cfg ChunkRangesCacheConfig, logger log.Logger, registerer prometheus.Registerer) (chunkscache.Cache, error) {
+	var (
+		client cache.Cache
+		err    error
+	)
+
+	switch cfg.Backend {
+	case "":
+		return nil, nil
+	case ChunkRangesCacheBackendInMemory:
+		client, err = chunkscache.NewInMemoryCache("chunk-ranges-cache", cfg.InMemory.MaxSizeBytes, registerer)
+	case ChunkRangesCacheBackendMemcached:
+		client, err = cache.CreateClient("chunk-ranges-cache", cfg.BackendConfig, logger, registerer)
+		if err == nil {
+			client = cache.NewSpanlessTracingCache(client, logger)
+		}
+	default:
+		return nil, errUnsupportedChunkRangesCacheBackend
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "create chunk ranges cache")
+	}
+
+	return chunkscache.NewChunkRangesCache(logger, client, cfg.TTL, cfg.MaxItemSizeBytes, registerer), nil
+}
@@ -0,0 +1,73 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package tsdb
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/go-kit/log"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/cache"
+)
+
+func TestChunkRangesCacheConfig_Validate(t *testing.T) {
+	defaultConfig := func() ChunkRangesCacheConfig {
+		cfg := ChunkRangesCacheConfig{}
+		cfg.RegisterFlagsWithPrefix(flag.NewFlagSet("", flag.PanicOnError), "")
+		return cfg
+	}
+
+	tests := map[string]struct {
+		cfg      func(*ChunkRangesCacheConfig)
+		expected error
+	}{
+		"default config should pass": {
+			cfg: func(*ChunkRangesCacheConfig) {},
+		},
+		"in-memory backend should pass": {
+			cfg: func(cfg *ChunkRangesCacheConfig) { cfg.Backend = ChunkRangesCacheBackendInMemory },
+		},
+		"unsupported backend should fail": {
+			cfg:      func(cfg *ChunkRangesCacheConfig) { cfg.Backend = "xxx" },
+			expected: errUnsupportedChunkRangesCacheBackend,
+		},
+		"no memcached addresses should fail": {
+			cfg:      func(cfg *ChunkRangesCacheConfig) { cfg.Backend = ChunkRangesCacheBackendMemcached },
+			expected: cache.ErrNoMemcachedAddresses,
+		},
+		"zero max item size should fail": {
+			cfg: func(cfg *ChunkRangesCacheConfig) {
+				cfg.Backend = ChunkRangesCacheBackendInMemory
+				cfg.MaxItemSizeBytes = 0
+			},
+			expected: errInvalidChunkRangesCacheMaxItemSize,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := defaultConfig()
+			testData.cfg(&cfg)
+			assert.Equal(t, testData.expected, cfg.Validate())
+		})
+	}
+}
+
+func TestNewChunkRangesCache(t *testing.T) {
+	cfg := ChunkRangesCacheConfig{}
+	cfg.RegisterFlagsWithPrefix(flag.NewFlagSet("", flag.PanicOnError), "")
+
+	c, err := NewChunkRangesCache(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	cfg.Backend = ChunkRangesCacheBackendInMemory
+	c, err = NewChunkRangesCache(cfg, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, cfg.MaxItemSizeBytes, c.MaxItemSizeBytes())
+}
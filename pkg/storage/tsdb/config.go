@@ -76,6 +76,13 @@ var (
 	errInvalidWALSegmentSizeBytes   = errors.New("invalid TSDB WAL segment size bytes")
 	errInvalidStripeSize            = errors.New("invalid TSDB stripe size")
 	errEmptyBlockranges             = errors.New("empty block ranges for TSDB")
+
+	errInvalidChunkRangesMaxDiscardRatio   = errors.New("invalid chunk ranges max discard ratio, supported values are between 0 and 1")
+	errInvalidStreamingBatchSize           = errors.New("invalid series batch size, it must be greater than or equal to 0")
+	errInvalidMaxConcurrentChunkFetches    = errors.New("invalid max concurrent chunk fetches per query, it must be greater than or equal to 0")
+	errInvalidChunkRangesHedgingPercentile = errors.New("invalid chunk ranges hedging percentile, it must be greater than or equal to 0 and less than 100")
+
+	errInvalidIndexHeaderLazyLoadingMemoryCheckInterval = errors.New("invalid index-header lazy loading memory check interval, it must be greater than 0 when the memory budget is set")
 )
 
 // BlocksStorageConfig holds the config information for the blocks storage.
@@ -261,19 +268,20 @@ func (cfg *TSDBConfig) IsBlocksShippingEnabled() bool {
 
 // BucketStoreConfig holds the config information for Bucket Stores used by the querier and store-gateway.
 type BucketStoreConfig struct {
-	SyncDir                  string              `yaml:"sync_dir"`
-	SyncInterval             time.Duration       `yaml:"sync_interval" category:"advanced"`
-	MaxConcurrent            int                 `yaml:"max_concurrent" category:"advanced"`
-	TenantSyncConcurrency    int                 `yaml:"tenant_sync_concurrency" category:"advanced"`
-	BlockSyncConcurrency     int                 `yaml:"block_sync_concurrency" category:"advanced"`
-	MetaSyncConcurrency      int                 `yaml:"meta_sync_concurrency" category:"advanced"`
-	ConsistencyDelay         time.Duration       `yaml:"consistency_delay" category:"advanced"`
-	IndexCache               IndexCacheConfig    `yaml:"index_cache"`
-	ChunksCache              ChunksCacheConfig   `yaml:"chunks_cache"`
-	MetadataCache            MetadataCacheConfig `yaml:"metadata_cache"`
-	IgnoreDeletionMarksDelay time.Duration       `yaml:"ignore_deletion_mark_delay" category:"advanced"`
-	BucketIndex              BucketIndexConfig   `yaml:"bucket_index"`
-	IgnoreBlocksWithin       time.Duration       `yaml:"ignore_blocks_within" category:"advanced"`
+	SyncDir                  string                 `yaml:"sync_dir"`
+	SyncInterval             time.Duration          `yaml:"sync_interval" category:"advanced"`
+	MaxConcurrent            int                    `yaml:"max_concurrent" category:"advanced"`
+	TenantSyncConcurrency    int                    `yaml:"tenant_sync_concurrency" category:"advanced"`
+	BlockSyncConcurrency     int                    `yaml:"block_sync_concurrency" category:"advanced"`
+	MetaSyncConcurrency      int                    `yaml:"meta_sync_concurrency" category:"advanced"`
+	ConsistencyDelay         time.Duration          `yaml:"consistency_delay" category:"advanced"`
+	IndexCache               IndexCacheConfig       `yaml:"index_cache"`
+	ChunksCache              ChunksCacheConfig      `yaml:"chunks_cache"`
+	ChunkRangesCache         ChunkRangesCacheConfig `yaml:"chunk_ranges_cache"`
+	MetadataCache            MetadataCacheConfig    `yaml:"metadata_cache"`
+	IgnoreDeletionMarksDelay time.Duration          `yaml:"ignore_deletion_mark_delay" category:"advanced"`
+	BucketIndex              BucketIndexConfig      `yaml:"bucket_index"`
+	IgnoreBlocksWithin       time.Duration          `yaml:"ignore_blocks_within" category:"advanced"`
 
 	// Chunk pool.
 	MaxChunkPoolBytes           uint64 `yaml:"max_chunk_pool_bytes" category:"advanced"`
@@ -287,9 +295,59 @@ type BucketStoreConfig struct {
 	IndexHeaderLazyLoadingEnabled     bool          `yaml:"index_header_lazy_loading_enabled" category:"advanced"`
 	IndexHeaderLazyLoadingIdleTimeout time.Duration `yaml:"index_header_lazy_loading_idle_timeout" category:"advanced"`
 
+	// Controls the unloading of the lazy loaded index-headers because of memory pressure.
+	IndexHeaderLazyLoadingMemoryBudgetBytes   uint64        `yaml:"index_header_lazy_loading_memory_budget_bytes" category:"experimental"`
+	IndexHeaderLazyLoadingMemoryCheckInterval time.Duration `yaml:"index_header_lazy_loading_memory_check_interval" category:"experimental"`
+
+	// Controls the index-header downloads while syncing blocks, shared across all tenants.
+	IndexHeaderDownloadConcurrency    int    `yaml:"index_header_download_concurrency" category:"experimental"`
+	IndexHeaderDownloadRateLimitBytes uint64 `yaml:"index_header_download_rate_limit_bytes" category:"experimental"`
+
 	// Controls the partitioner, used to aggregate multiple GET object API requests.
 	PartitionerMaxGapBytes uint64 `yaml:"partitioner_max_gap_bytes" category:"advanced"`
 
+	// Controls the coalescing of chunk range reads which are close together after partitioning.
+	ChunkRangesMergeGapBytes uint64 `yaml:"chunk_ranges_merge_gap_bytes" category:"experimental"`
+
+	// Controls when a chunk range read is split, instead of discarding a large gap of unused bytes.
+	ChunkRangesMaxDiscardRatio float64 `yaml:"chunk_ranges_max_discard_ratio" category:"experimental"`
+
+	// Max number of unused bytes discarded before the next chunk within a chunk range read.
+	ChunkRangesMaxDiscardBytes uint64 `yaml:"chunk_ranges_max_discard_bytes" category:"experimental"`
+
+	// Controls whether the chunk range reads of a segment file are pipelined instead of issued concurrently.
+	ChunkRangesReadAheadEnabled bool `yaml:"chunk_ranges_read_ahead_enabled" category:"experimental"`
+
+	// Controls whether the estimated vs actual chunk bytes fetched are logged for each block queried.
+	ChunksFetchEstimateLoggingEnabled bool `yaml:"chunks_fetch_estimate_logging_enabled" category:"experimental"`
+
+	// Max time a single chunk range read from the bucket can take.
+	ChunkRangesReadTimeout time.Duration `yaml:"chunk_ranges_read_timeout" category:"experimental"`
+
+	// Max total size of the small chunk range reads of contiguous segment files issued sequentially.
+	ChunkRangesBatchMaxBytes uint64 `yaml:"chunk_ranges_batch_max_bytes" category:"experimental"`
+
+	// Max number of series whose chunks are loaded and sent at once by each Series() call.
+	StreamingBatchSize int `yaml:"batch_series_size" category:"experimental"`
+
+	// Controls whether the length of the chunks to read is estimated from the chunks read so far.
+	ChunksAdaptiveLengthEstimationEnabled bool `yaml:"chunks_adaptive_length_estimation_enabled" category:"experimental"`
+
+	// Controls whether the checksum of the chunks read from the bucket is validated.
+	ChunksChecksumValidationEnabled bool `yaml:"chunks_checksum_validation_enabled" category:"experimental"`
+
+	// Max number of concurrent chunk range reads issued by each Series() call.
+	MaxConcurrentChunkFetchesPerQuery int `yaml:"max_concurrent_chunk_fetches_per_query" category:"experimental"`
+
+	// Max number of chunk bytes fetched by each Series() call.
+	MaxFetchedChunkBytesPerQuery uint64 `yaml:"max_fetched_chunk_bytes_per_query" category:"experimental"`
+
+	// Max size of the slabs retained by the chunk slab pool to be reused across Series() calls.
+	ChunkSlabPoolMaxRetainedBytes uint64 `yaml:"chunk_slab_pool_max_retained_bytes" category:"experimental"`
+
+	// Latency percentile of the chunk range reads after which a slow range read is hedged.
+	ChunkRangesHedgingPercentile float64 `yaml:"chunk_ranges_hedging_percentile" category:"experimental"`
+
 	// Controls what is the ratio of postings offsets store will hold in memory.
 	// Larger value will keep less offsets, which will increase CPU cycles needed for query touching those postings.
 	// It's meant for setups that want low baseline memory pressure and where less traffic is expected.
@@ -302,12 +360,16 @@ type BucketStoreConfig struct {
 
 	// Controls what to do when MaxConcurrent is exceeded: fail immediately or wait for a slot to run.
 	MaxConcurrentRejectOverLimit bool `yaml:"max_concurrent_reject_over_limit" category:"experimental"`
+
+	// Controls the max number of concurrent chunk range reads, shared across all queries and tenants.
+	MaxConcurrentChunksFetches int `yaml:"max_concurrent_chunks_fetches" category:"experimental"`
 }
 
 // RegisterFlags registers the BucketStore flags
 func (cfg *BucketStoreConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.IndexCache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.index-cache.")
 	cfg.ChunksCache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.chunks-cache.")
+	cfg.ChunkRangesCache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.chunk-ranges-cache.")
 	cfg.MetadataCache.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.metadata-cache.")
 	cfg.BucketIndex.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.bucket-index.")
 	cfg.IndexHeader.RegisterFlagsWithPrefix(f, "blocks-storage.bucket-store.index-header.")
@@ -319,6 +381,7 @@ func (cfg *BucketStoreConfig) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.ChunkPoolMaxBucketSizeBytes, "blocks-storage.bucket-store.chunk-pool-max-bucket-size-bytes", ChunkPoolDefaultMaxBucketSize, "Size - in bytes - of the largest chunks pool bucket.")
 	f.Uint64Var(&cfg.SeriesHashCacheMaxBytes, "blocks-storage.bucket-store.series-hash-cache-max-size-bytes", uint64(1*units.Gibibyte), "Max size - in bytes - of the in-memory series hash cache. The cache is shared across all tenants and it's used only when query sharding is enabled.")
 	f.IntVar(&cfg.MaxConcurrent, "blocks-storage.bucket-store.max-concurrent", 100, "Max number of concurrent queries to execute against the long-term storage. The limit is shared across all tenants.")
+	f.IntVar(&cfg.MaxConcurrentChunksFetches, "blocks-storage.bucket-store.max-concurrent-chunks-fetches", 0, "Max number of concurrent chunk range reads from the long-term storage. The limit is shared across all queries and tenants. Range reads above the limit wait until a slot is available, or the query is canceled. 0 to disable.")
 	f.BoolVar(&cfg.MaxConcurrentRejectOverLimit, "blocks-storage.bucket-store.max-concurrent-reject-over-limit", false, "True to reject queries above the max number of concurrent queries to execute against long-term storage. If false, queries will block until they are able to run.")
 	f.IntVar(&cfg.TenantSyncConcurrency, "blocks-storage.bucket-store.tenant-sync-concurrency", 10, "Maximum number of concurrent tenants synching blocks.")
 	f.IntVar(&cfg.BlockSyncConcurrency, "blocks-storage.bucket-store.block-sync-concurrency", 20, "Maximum number of concurrent blocks synching per tenant.")
@@ -330,7 +393,25 @@ func (cfg *BucketStoreConfig) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.PostingOffsetsInMemSampling, "blocks-storage.bucket-store.posting-offsets-in-mem-sampling", DefaultPostingOffsetInMemorySampling, "Controls what is the ratio of postings offsets that the store will hold in memory.")
 	f.BoolVar(&cfg.IndexHeaderLazyLoadingEnabled, "blocks-storage.bucket-store.index-header-lazy-loading-enabled", true, "If enabled, store-gateway will lazy load an index-header only once required by a query.")
 	f.DurationVar(&cfg.IndexHeaderLazyLoadingIdleTimeout, "blocks-storage.bucket-store.index-header-lazy-loading-idle-timeout", 60*time.Minute, "If index-header lazy loading is enabled and this setting is > 0, the store-gateway will offload unused index-headers after 'idle timeout' inactivity.")
+	f.Uint64Var(&cfg.IndexHeaderLazyLoadingMemoryBudgetBytes, "blocks-storage.bucket-store.index-header-lazy-loading-memory-budget-bytes", 0, "If index-header lazy loading is enabled and this setting is > 0, the store-gateway unloads the least recently used index-headers when its resident memory (RSS) exceeds this budget - in bytes - until the size of the unloaded index-headers covers the memory in excess, regardless of the idle timeout. The unloaded index-headers are reloaded upon next usage. Only supported on Linux. 0 to disable.")
+	f.DurationVar(&cfg.IndexHeaderLazyLoadingMemoryCheckInterval, "blocks-storage.bucket-store.index-header-lazy-loading-memory-check-interval", 10*time.Second, "How frequently the store-gateway checks its resident memory against the index-headers memory budget.")
+	f.IntVar(&cfg.IndexHeaderDownloadConcurrency, "blocks-storage.bucket-store.index-header-download-concurrency", 0, "Max number of concurrent index-header downloads while syncing blocks. The limit is shared across all tenants. Blocks whose index-header is already on disk are not limited. 0 to disable the limit.")
+	f.Uint64Var(&cfg.IndexHeaderDownloadRateLimitBytes, "blocks-storage.bucket-store.index-header-download-rate-limit-bytes", 0, "Max aggregate bandwidth - in bytes per second - used to download index-headers while syncing blocks. The limit is shared across all tenants. 0 to disable the limit.")
 	f.Uint64Var(&cfg.PartitionerMaxGapBytes, "blocks-storage.bucket-store.partitioner-max-gap-bytes", DefaultPartitionerMaxGapSize, "Max size - in bytes - of a gap for which the partitioner aggregates together two bucket GET object requests.")
+	f.Uint64Var(&cfg.ChunkRangesMergeGapBytes, "blocks-storage.bucket-store.chunk-ranges-merge-gap-bytes", 0, "Max size - in bytes - of unused data between two partitioned chunk range reads for which the store-gateway coalesces them into a single bucket GET object request. 0 to disable.")
+	f.Float64Var(&cfg.ChunkRangesMaxDiscardRatio, "blocks-storage.bucket-store.chunk-ranges-max-discard-ratio", 0, "Max ratio - between 0 and 1 - of a chunk range read that the store-gateway discards to skip the unused bytes before the next chunk. When a gap exceeds it, the store-gateway stops reading the range and issues a new bucket GET object request starting from the next chunk. 0 to disable.")
+	f.Uint64Var(&cfg.ChunkRangesMaxDiscardBytes, "blocks-storage.bucket-store.chunk-ranges-max-discard-bytes", 0, "Max size - in bytes - of unused data before the next chunk that the store-gateway discards while reading a chunk range. When a gap exceeds it, the store-gateway stops reading the range and issues a new bucket GET object request starting from the next chunk. 0 to disable.")
+	f.BoolVar(&cfg.ChunksFetchEstimateLoggingEnabled, "blocks-storage.bucket-store.chunks-fetch-estimate-logging-enabled", false, "If enabled, the store-gateway logs at debug level the estimated chunk bytes to fetch, based on the estimated max chunk size, compared to the actual chunk bytes fetched, for each block queried.")
+	f.DurationVar(&cfg.ChunkRangesReadTimeout, "blocks-storage.bucket-store.chunk-ranges-read-timeout", time.Minute, "Max time a chunk range read - from issuing the bucket GET object request to reading its last byte - can take. When the timeout expires, the query fails instead of waiting for a stuck request. 0 to disable.")
+	f.Uint64Var(&cfg.ChunkRangesBatchMaxBytes, "blocks-storage.bucket-store.chunk-ranges-batch-max-bytes", 0, "Max total size - in bytes - of the chunk ranges of contiguous segment files that the store-gateway reads one after the other within a single fetch, instead of issuing concurrent bucket GET object requests. Each segment file is a separate object, so this reduces the concurrent requests and connections rather than the total number of requests. 0 to disable.")
+	f.IntVar(&cfg.StreamingBatchSize, "blocks-storage.bucket-store.batch-series-size", 0, "If greater than 0, the store-gateway loads the chunks of the series selected by a query in batches of this number of series, and sends each batch to the querier before loading the next one, instead of loading the chunks of all series before sending them. This bounds the store-gateway memory used by queries selecting many series. 0 to disable.")
+	f.BoolVar(&cfg.ChunksAdaptiveLengthEstimationEnabled, "blocks-storage.bucket-store.chunks-adaptive-length-estimation-enabled", false, "If enabled, the store-gateway sizes the chunk range reads of each segment file of a block based on the length of the chunks of that segment file read so far, instead of the max estimated chunk size. This reduces the bytes fetched but unused, while the chunks longer than the estimate are refetched.")
+	f.BoolVar(&cfg.ChunksChecksumValidationEnabled, "blocks-storage.bucket-store.chunks-checksum-validation-enabled", false, "If enabled, the store-gateway validates the CRC32 checksum of every chunk read from the bucket, and fails the query if a chunk is corrupted. The block, segment file and offset of the corrupted chunks are logged, so that the block can be quarantined.")
+	f.IntVar(&cfg.MaxConcurrentChunkFetchesPerQuery, "blocks-storage.bucket-store.max-concurrent-chunk-fetches-per-query", 0, "Max number of concurrent chunk range reads from the bucket issued by a single query to the store-gateway, so that a query can't saturate the bucket connection pool. 0 to disable the limit.")
+	f.Uint64Var(&cfg.MaxFetchedChunkBytesPerQuery, "blocks-storage.bucket-store.max-fetched-chunk-bytes-per-query", 0, "Max number of chunk bytes that a single query to the store-gateway can fetch from the bucket. The query fails with a limit error once the limit is exceeded. 0 to disable the limit.")
+	f.Uint64Var(&cfg.ChunkSlabPoolMaxRetainedBytes, "blocks-storage.bucket-store.chunk-slab-pool-max-retained-bytes", uint64(128*units.Mebibyte), "Max size - in bytes - of the slabs that the store-gateway retains to copy the loaded chunks to, so that they're reused across queries instead of being allocated for each query. The slab pool is shared across all tenants. 0 to disable pooling.")
+	f.Float64Var(&cfg.ChunkRangesHedgingPercentile, "blocks-storage.bucket-store.chunk-ranges-hedging-percentile", 0, "If greater than 0, the store-gateway hedges the chunk range reads from the bucket: when a bucket GET object request doesn't return within this latency percentile - between 0 and 100 - of the recent chunk range reads, the store-gateway issues a second identical request and uses the first one to return. No request is hedged until enough requests have been observed. 0 to disable.")
+	f.BoolVar(&cfg.ChunkRangesReadAheadEnabled, "blocks-storage.bucket-store.chunk-ranges-read-ahead-enabled", false, "If enabled, the store-gateway reads the chunk ranges of a segment file one after the other, issuing the bucket GET object request for the next range while the current one is processed. This limits the concurrent requests to two per segment file, while hiding the object storage latency.")
 }
 
 // Validate the config.
@@ -347,6 +428,25 @@ func (cfg *BucketStoreConfig) Validate() error {
 	if err != nil {
 		return errors.Wrap(err, "metadata-cache configuration")
 	}
+	err = cfg.ChunkRangesCache.Validate()
+	if err != nil {
+		return errors.Wrap(err, "chunk-ranges-cache configuration")
+	}
+	if cfg.ChunkRangesMaxDiscardRatio < 0 || cfg.ChunkRangesMaxDiscardRatio > 1 {
+		return errInvalidChunkRangesMaxDiscardRatio
+	}
+	if cfg.StreamingBatchSize < 0 {
+		return errInvalidStreamingBatchSize
+	}
+	if cfg.MaxConcurrentChunkFetchesPerQuery < 0 {
+		return errInvalidMaxConcurrentChunkFetches
+	}
+	if cfg.ChunkRangesHedgingPercentile < 0 || cfg.ChunkRangesHedgingPercentile >= 100 {
+		return errInvalidChunkRangesHedgingPercentile
+	}
+	if cfg.IndexHeaderLazyLoadingMemoryBudgetBytes > 0 && cfg.IndexHeaderLazyLoadingMemoryCheckInterval <= 0 {
+		return errInvalidIndexHeaderLazyLoadingMemoryCheckInterval
+	}
 	return nil
 }
 
@@ -121,6 +121,55 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			expectedErr: errInvalidWALSegmentSizeBytes,
 		},
+		"should fail on negative chunk ranges max discard ratio": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.ChunkRangesMaxDiscardRatio = -0.1
+			},
+			expectedErr: errInvalidChunkRangesMaxDiscardRatio,
+		},
+		"should fail on chunk ranges max discard ratio greater than 1": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.ChunkRangesMaxDiscardRatio = 1.5
+			},
+			expectedErr: errInvalidChunkRangesMaxDiscardRatio,
+		},
+		"should fail on negative series batch size": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.StreamingBatchSize = -1
+			},
+			expectedErr: errInvalidStreamingBatchSize,
+		},
+		"should fail on negative max concurrent chunk fetches per query": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.MaxConcurrentChunkFetchesPerQuery = -1
+			},
+			expectedErr: errInvalidMaxConcurrentChunkFetches,
+		},
+		"should fail on negative chunk ranges hedging percentile": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.ChunkRangesHedgingPercentile = -1
+			},
+			expectedErr: errInvalidChunkRangesHedgingPercentile,
+		},
+		"should fail on chunk ranges hedging percentile equal to 100": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.ChunkRangesHedgingPercentile = 100
+			},
+			expectedErr: errInvalidChunkRangesHedgingPercentile,
+		},
+		"should fail on index-header memory budget without check interval": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.IndexHeaderLazyLoadingMemoryBudgetBytes = 1024
+				cfg.BucketStore.IndexHeaderLazyLoadingMemoryCheckInterval = 0
+			},
+			expectedErr: errInvalidIndexHeaderLazyLoadingMemoryCheckInterval,
+		},
+		"should pass on valid chunk ranges max discard ratio": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.ChunkRangesMaxDiscardRatio = 0.5
+			},
+			expectedErr: nil,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -80,11 +80,13 @@ func (cfg *InMemoryIndexCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, pr
 	f.Uint64Var(&cfg.MaxSizeBytes, prefix+"max-size-bytes", uint64(1*units.Gibibyte), "Maximum size in bytes of in-memory index cache used to speed up blocks index lookups (shared between all tenants).")
 }
 
-// NewIndexCache creates a new index cache based on the input configuration.
-func NewIndexCache(cfg IndexCacheConfig, logger log.Logger, registerer prometheus.Registerer) (indexcache.IndexCache, error) {
+// NewIndexCache creates a new index cache based on the input configuration. The tenantMaxSize function
+// returns the max size in bytes of the items of a tenant, 0 for no quota. Quotas are only enforced
+// by the in-memory backend.
+func NewIndexCache(cfg IndexCacheConfig, tenantMaxSize func(userID string) uint64, logger log.Logger, registerer prometheus.Registerer) (indexcache.IndexCache, error) {
 	switch cfg.Backend {
 	case IndexCacheBackendInMemory:
-		return newInMemoryIndexCache(cfg.InMemory, logger, registerer)
+		return newInMemoryIndexCache(cfg.InMemory, tenantMaxSize, logger, registerer)
 	case IndexCacheBackendMemcached:
 		return newMemcachedIndexCache(cfg.Memcached, logger, registerer)
 	default:
@@ -92,7 +94,7 @@ func NewIndexCache(cfg IndexCacheConfig, logger log.Logger, registerer prometheu
 	}
 }
 
-func newInMemoryIndexCache(cfg InMemoryIndexCacheConfig, logger log.Logger, registerer prometheus.Registerer) (indexcache.IndexCache, error) {
+func newInMemoryIndexCache(cfg InMemoryIndexCacheConfig, tenantMaxSize func(userID string) uint64, logger log.Logger, registerer prometheus.Registerer) (indexcache.IndexCache, error) {
 	maxCacheSize := flagext.Bytes(cfg.MaxSizeBytes)
 
 	// Calculate the max item size.
@@ -102,8 +104,9 @@ func newInMemoryIndexCache(cfg InMemoryIndexCacheConfig, logger log.Logger, regi
 	}
 
 	return indexcache.NewInMemoryIndexCacheWithConfig(logger, registerer, indexcache.InMemoryIndexCacheConfig{
-		MaxSize:     maxCacheSize,
-		MaxItemSize: maxItemSize,
+		MaxSize:       maxCacheSize,
+		MaxItemSize:   maxItemSize,
+		TenantMaxSize: tenantMaxSize,
 	})
 }
 
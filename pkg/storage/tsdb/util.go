@@ -20,3 +20,13 @@ func HashBlockID(id ulid.ULID) uint32 {
 	}
 	return h
 }
+
+// HashInstanceBlockID returns a 32-bit hash of the instance address and block ID, used to
+// rank the instances of a ring as candidate owners of the block.
+func HashInstanceBlockID(instanceAddr string, id ulid.ULID) uint32 {
+	h := client.HashAdd32(client.HashNew32(), instanceAddr)
+	for _, b := range id {
+		h = client.HashAddByte32(h, b)
+	}
+	return h
+}
@@ -0,0 +1,130 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"context"
+	"io"
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+	"golang.org/x/time/rate"
+
+	"github.com/grafana/mimir/pkg/util/gate"
+)
+
+// blockSyncLimiter limits the number of concurrent index-header downloads and their aggregate
+// bandwidth while syncing the blocks of all tenants, so that the store-gateway startup doesn't
+// saturate the object storage. It also tracks the progress of the blocks sync.
+type blockSyncLimiter struct {
+	// Gate used to limit the concurrent index-header downloads.
+	downloadGate gate.Gate
+
+	// Token bucket limiting the bytes per second of the index-header downloads. Nil if disabled.
+	downloadLimiter *rate.Limiter
+
+	// Metrics.
+	pendingBlocks   prometheus.Gauge
+	downloadedBytes prometheus.Counter
+}
+
+// newBlockSyncLimiter makes a new blockSyncLimiter. A maxConcurrentDownloads or bytesPerSecond
+// equal to 0 disables the respective limit.
+func newBlockSyncLimiter(maxConcurrentDownloads int, bytesPerSecond uint64, reg prometheus.Registerer) *blockSyncLimiter {
+	l := &blockSyncLimiter{
+		downloadGate: gate.NewNoop(),
+		pendingBlocks: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_bucket_store_blocks_sync_pending",
+			Help: "Number of blocks discovered by the running syncs and not loaded yet.",
+		}),
+		downloadedBytes: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_bucket_store_indexheader_download_bytes_total",
+			Help: "Total number of bytes read from the bucket to build the index-headers.",
+		}),
+	}
+
+	if maxConcurrentDownloads > 0 {
+		gateReg := prometheus.WrapRegistererWithPrefix("cortex_bucket_stores_indexheader_download_", reg)
+		l.downloadGate = gate.NewInstrumented(gateReg, maxConcurrentDownloads, gate.NewBlocking(maxConcurrentDownloads))
+	}
+	if bytesPerSecond > 0 {
+		burst := int(math.Min(float64(bytesPerSecond), math.MaxInt32))
+		l.downloadLimiter = rate.NewLimiter(rate.Limit(bytesPerSecond), burst)
+	}
+
+	return l
+}
+
+// startDownload waits until an index-header download can start, and returns the bucket to download
+// it from, along with the function to call once the download has completed. It's safe to call
+// startDownload on a nil limiter.
+func (l *blockSyncLimiter) startDownload(ctx context.Context, bkt objstore.BucketReader) (objstore.BucketReader, func(), error) {
+	if l == nil {
+		return bkt, func() {}, nil
+	}
+
+	if err := l.downloadGate.Start(ctx); err != nil {
+		return nil, nil, err
+	}
+	return &rateLimitedBucketReader{BucketReader: bkt, l: l}, l.downloadGate.Done, nil
+}
+
+// addPending adds delta to the number of blocks pending to be loaded. It's safe to call addPending
+// on a nil limiter.
+func (l *blockSyncLimiter) addPending(delta int) {
+	if l == nil {
+		return
+	}
+	l.pendingBlocks.Add(float64(delta))
+}
+
+// rateLimitedBucketReader is an objstore.BucketReader whose object reads are accounted and
+// throttled by the blockSyncLimiter.
+type rateLimitedBucketReader struct {
+	objstore.BucketReader
+	l *blockSyncLimiter
+}
+
+func (b *rateLimitedBucketReader) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	rc, err := b.BucketReader.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimitedReader{ReadCloser: rc, ctx: ctx, l: b.l}, nil
+}
+
+func (b *rateLimitedBucketReader) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	rc, err := b.BucketReader.GetRange(ctx, name, off, length)
+	if err != nil {
+		return nil, err
+	}
+	return &rateLimitedReader{ReadCloser: rc, ctx: ctx, l: b.l}, nil
+}
+
+// rateLimitedReader waits for the bytes read to be allowed by the rate limit before returning
+// them, reading at most a burst of bytes at once.
+type rateLimitedReader struct {
+	io.ReadCloser
+	ctx context.Context
+	l   *blockSyncLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	limiter := r.l.downloadLimiter
+	if limiter != nil && len(p) > limiter.Burst() {
+		p = p[:limiter.Burst()]
+	}
+
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.l.downloadedBytes.Add(float64(n))
+		if limiter != nil {
+			if waitErr := limiter.WaitN(r.ctx, n); waitErr != nil {
+				return n, waitErr
+			}
+		}
+	}
+	return n, err
+}
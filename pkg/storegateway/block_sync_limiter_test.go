@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket/filesystem"
+)
+
+func TestBlockSyncLimiter_ShouldLimitTheDownloadBandwidth(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	require.NoError(t, bkt.Upload(ctx, "object", bytes.NewReader(make([]byte, 2000))))
+
+	reg := prometheus.NewPedanticRegistry()
+	l := newBlockSyncLimiter(1, 1000, reg)
+
+	limitedBkt, done, err := l.startDownload(ctx, bkt)
+	require.NoError(t, err)
+
+	// The gate doesn't allow another download until the first one is done.
+	timeoutCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	_, _, err = l.startDownload(timeoutCtx, bkt)
+	cancel()
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The first 1000 bytes are within the burst, the next 1000 bytes take a second.
+	start := time.Now()
+	rc, err := limitedBkt.Get(ctx, "object")
+	require.NoError(t, err)
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	done()
+
+	assert.Len(t, data, 2000)
+	assert.GreaterOrEqual(t, time.Since(start), 900*time.Millisecond)
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_store_indexheader_download_bytes_total Total number of bytes read from the bucket to build the index-headers.
+		# TYPE cortex_bucket_store_indexheader_download_bytes_total counter
+		cortex_bucket_store_indexheader_download_bytes_total 2000
+	`), "cortex_bucket_store_indexheader_download_bytes_total"))
+
+	_, done, err = l.startDownload(ctx, bkt)
+	require.NoError(t, err)
+	done()
+}
+
+func TestBucketStores_SyncBlocksWithIndexHeaderDownloadLimits(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	cfg := prepareStorageConfig(t)
+	cfg.BucketStore.IndexHeaderDownloadConcurrency = 1
+	cfg.BucketStore.IndexHeaderDownloadRateLimitBytes = 10 << 20
+
+	storageDir := t.TempDir()
+	generateStorageBlock(t, storageDir, userID, "series_1", 0, 100, 15)
+	generateStorageBlock(t, storageDir, userID, "series_2", 100, 200, 15)
+	generateStorageBlock(t, storageDir, userID, "series_3", 200, 300, 15)
+
+	bucket, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	stores, err := NewBucketStores(cfg, newNoShardingStrategy(), bucket, defaultLimitsOverrides(t), mockLoggingLevel(), log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	require.NoError(t, stores.InitialSync(ctx))
+
+	assert.Equal(t, 3, stores.getStore(userID).Stats().BlocksLoaded)
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_store_blocks_sync_pending Number of blocks discovered by the running syncs and not loaded yet.
+		# TYPE cortex_bucket_store_blocks_sync_pending gauge
+		cortex_bucket_store_blocks_sync_pending 0
+
+		# HELP cortex_bucket_store_block_loads_total Total number of remote block loading attempts.
+		# TYPE cortex_bucket_store_block_loads_total counter
+		cortex_bucket_store_block_loads_total 3
+	`), "cortex_bucket_store_blocks_sync_pending", "cortex_bucket_store_block_loads_total"))
+
+	assert.Greater(t, testutil.ToFloat64(stores.blockSyncLimiter.downloadedBytes), float64(0))
+}
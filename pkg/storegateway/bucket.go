@@ -6,11 +6,13 @@
 package storegateway
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"fmt"
 	"io"
 	"math"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -42,8 +44,10 @@ import (
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/storage/sharding"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
 	"github.com/grafana/mimir/pkg/storage/tsdb/metadata"
+	"github.com/grafana/mimir/pkg/storegateway/chunkscache"
 	"github.com/grafana/mimir/pkg/storegateway/hintspb"
 	"github.com/grafana/mimir/pkg/storegateway/indexcache"
 	"github.com/grafana/mimir/pkg/storegateway/indexheader"
@@ -102,8 +106,14 @@ type BucketStore struct {
 
 	// Verbose enabled additional logging.
 	debugLogging bool
+	// Log the estimated vs actual chunk bytes fetched for each block queried.
+	chunksFetchEstimateLogging bool
 	// Number of goroutines to use when syncing blocks from object storage.
 	blockSyncConcurrency int
+	// Limits the index-header downloads while syncing blocks. Nil if not limited.
+	blockSyncLimiter *blockSyncLimiter
+	// Max number of series whose chunks are loaded and sent at once by each Series() call. 0 to load all chunks before sending the series.
+	maxSeriesPerBatch int
 
 	// Query gate which limits the maximum amount of concurrent queries.
 	queryGate gate.Gate
@@ -115,11 +125,20 @@ type BucketStore struct {
 	seriesLimiterFactory SeriesLimiterFactory
 	partitioner          Partitioner
 
+	// fetchedBytesRateLimiter limits the rate of the chunk and index bytes fetched by the tenant from the bucket.
+	fetchedBytesRateLimiter *fetchedBytesRateLimiter
+
+	// Settings used by the chunk readers of all blocks.
+	chunkReaderCfg chunkReaderConfig
+
 	// Every how many posting offset entry we pool in heap memory. Default in Prometheus is 32.
 	postingOffsetsInMemSampling int
 
 	// Additional configuration for experimental indexheader.BinaryReader behaviour.
 	indexHeaderCfg indexheader.BinaryReaderConfig
+
+	// Unloads the lazy loaded index-headers because of memory pressure, shared across all tenants. Nil if disabled.
+	indexHeaderEvictor *indexheader.MemoryPressureEvictor
 }
 
 type noopCache struct{}
@@ -176,6 +195,42 @@ func WithIndexCache(cache indexcache.IndexCache) BucketStoreOption {
 	}
 }
 
+// WithChunkRangesCache sets the cache of the chunk ranges read from the bucket. Nil disables caching.
+func WithChunkRangesCache(cache chunkscache.Cache) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.cache = cache
+	}
+}
+
+// WithFetchedBytesRateLimiter sets the limiter of the rate of the chunk and index bytes fetched from the bucket. Nil means no limit.
+func WithFetchedBytesRateLimiter(l *fetchedBytesRateLimiter) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.fetchedBytesRateLimiter = l
+	}
+}
+
+// WithChunkSlabPool sets the pool of the slabs the loaded chunks are copied to. Nil means the slabs are not pooled.
+func WithChunkSlabPool(p *chunkSlabPool) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.slabPool = p
+	}
+}
+
+// WithChunkRangesHedger sets the hedger of the chunk range reads from the bucket. Nil disables hedging.
+func WithChunkRangesHedger(hedger *chunkRangeHedger) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.hedger = hedger
+	}
+}
+
+// WithIndexHeaderMemoryPressureEvictor sets the evictor unloading the lazy loaded index-headers
+// when the memory usage exceeds its budget.
+func WithIndexHeaderMemoryPressureEvictor(evictor *indexheader.MemoryPressureEvictor) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.indexHeaderEvictor = evictor
+	}
+}
+
 // WithQueryGate sets a queryGate to use instead of a noopGate.
 func WithQueryGate(queryGate gate.Gate) BucketStoreOption {
 	return func(s *BucketStore) {
@@ -190,6 +245,121 @@ func WithChunkPool(chunkPool pool.Bytes) BucketStoreOption {
 	}
 }
 
+// WithChunkRangesMergeGap sets the max number of unused bytes between two chunk range reads
+// for which they're coalesced together into a single bucket GET object request.
+func WithChunkRangesMergeGap(mergeGapBytes uint64) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.mergeGapBytes = mergeGapBytes
+	}
+}
+
+// WithChunkRangesMaxDiscardRatio sets the max ratio of a chunk range read which can be discarded
+// to skip unused bytes, before splitting the read into a new bucket GET object request.
+func WithChunkRangesMaxDiscardRatio(ratio float64) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.maxDiscardRatio = ratio
+	}
+}
+
+// WithChunkRangesMaxDiscardBytes sets the max number of unused bytes of a chunk range read which can be
+// discarded to skip to the next chunk, before splitting the read into a new bucket GET object request.
+func WithChunkRangesMaxDiscardBytes(maxBytes uint64) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.maxDiscardBytes = maxBytes
+	}
+}
+
+// WithChunkRangesReadAhead enables issuing the range read of the next partition of a segment file
+// while the current one is processed, instead of issuing all of them concurrently.
+func WithChunkRangesReadAhead(enabled bool) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.readAhead = enabled
+	}
+}
+
+// WithChunkRangesBatchMaxBytes sets the max total size of the small chunk range reads of contiguous
+// segment files which are batched together and issued sequentially, instead of concurrently. 0 disables it.
+func WithChunkRangesBatchMaxBytes(maxBytes uint64) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.batchMaxBytes = maxBytes
+	}
+}
+
+// WithChunkRangesReadTimeout sets the max time a chunk range read can take. 0 disables the timeout.
+func WithChunkRangesReadTimeout(timeout time.Duration) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.fetchTimeout = timeout
+	}
+}
+
+// WithAdaptiveChunkLengthEstimation enables estimating the length of the chunks to read from the
+// length of the chunks of the same segment file read so far, instead of assuming the max chunk size.
+func WithAdaptiveChunkLengthEstimation(enabled bool) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.adaptiveChunkLength = enabled
+	}
+}
+
+// WithChunksChecksumValidation enables validating the CRC32 checksum of the chunks read from the bucket.
+func WithChunksChecksumValidation(enabled bool) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.validateChecksums = enabled
+	}
+}
+
+// WithMaxConcurrentChunkFetchesPerQuery sets the max number of concurrent chunk range reads issued by
+// a single Series() call. 0 disables the limit.
+func WithMaxConcurrentChunkFetchesPerQuery(maxConcurrent int) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.maxConcurrentFetchesPerQuery = maxConcurrent
+	}
+}
+
+// WithMaxFetchedChunkBytesPerQuery sets the max number of chunk bytes fetched by a single Series() call.
+// Once exceeded, the query fails with a limit error. 0 disables the limit.
+func WithMaxFetchedChunkBytesPerQuery(maxBytes uint64) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.maxFetchedBytesPerQuery = maxBytes
+	}
+}
+
+// WithChunksFetchGate sets the gate limiting the number of concurrent chunk range reads.
+func WithChunksFetchGate(fetchGate gate.Gate) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.fetchGate = fetchGate
+	}
+}
+
+// WithChunksPartitioner sets the partitioner used to partition the chunks to load into range reads,
+// instead of the one used for the index.
+func WithChunksPartitioner(p Partitioner) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunkReaderCfg.partitioner = p
+	}
+}
+
+// WithChunksFetchEstimateLogging enables logging the estimated vs actual chunk bytes fetched for each block queried.
+func WithChunksFetchEstimateLogging() BucketStoreOption {
+	return func(s *BucketStore) {
+		s.chunksFetchEstimateLogging = true
+	}
+}
+
+// WithStreamingSeriesPerBatch makes Series() load and send the chunks of at most maxSeriesPerBatch series at once,
+// instead of loading the chunks of all series before sending them. 0 disables streaming.
+func WithStreamingSeriesPerBatch(maxSeriesPerBatch int) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.maxSeriesPerBatch = maxSeriesPerBatch
+	}
+}
+
+// WithBlockSyncLimiter sets the limiter of the index-header downloads while syncing blocks.
+func WithBlockSyncLimiter(l *blockSyncLimiter) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.blockSyncLimiter = l
+	}
+}
+
 // WithDebugLogging enables debug logging.
 func WithDebugLogging() BucketStoreOption {
 	return func(s *BucketStore) {
@@ -242,7 +412,7 @@ func NewBucketStore(
 	}
 
 	// Depend on the options
-	s.indexReaderPool = indexheader.NewReaderPool(s.logger, lazyIndexReaderEnabled, lazyIndexReaderIdleTimeout, metrics.indexHeaderReaderMetrics)
+	s.indexReaderPool = indexheader.NewReaderPool(s.logger, lazyIndexReaderEnabled, lazyIndexReaderIdleTimeout, s.indexHeaderEvictor, metrics.indexHeaderReaderMetrics)
 
 	if err := os.MkdirAll(dir, 0750); err != nil {
 		return nil, errors.Wrap(err, "create dir")
@@ -288,20 +458,27 @@ func (s *BucketStore) SyncBlocks(ctx context.Context) error {
 		wg.Add(1)
 		go func() {
 			for meta := range blockc {
-				if err := s.addBlock(ctx, meta); err != nil {
-					continue
-				}
+				_ = s.addBlock(ctx, meta)
+				s.blockSyncLimiter.addPending(-1)
 			}
 			wg.Done()
 		}()
 	}
 
+	var newMetas []*metadata.Meta
 	for id, meta := range metas {
 		if b := s.getBlock(id); b != nil {
 			continue
 		}
+		newMetas = append(newMetas, meta)
+	}
+
+	s.blockSyncLimiter.addPending(len(newMetas))
+	for _, meta := range newMetas {
 		select {
 		case <-ctx.Done():
+			// The block isn't loaded, so it's not pending anymore.
+			s.blockSyncLimiter.addPending(-1)
 		case blockc <- meta:
 		}
 	}
@@ -384,10 +561,20 @@ func (s *BucketStore) addBlock(ctx context.Context, meta *metadata.Meta) (err er
 	}()
 	s.metrics.blockLoads.Inc()
 
+	// The index-header download is limited only if it's not already on disk.
+	var bkt objstore.BucketReader = s.bkt
+	if _, statErr := os.Stat(filepath.Join(dir, block.IndexHeaderFilename)); os.IsNotExist(statErr) {
+		var done func()
+		if bkt, done, err = s.blockSyncLimiter.startDownload(ctx, s.bkt); err != nil {
+			return errors.Wrap(err, "wait for index header download")
+		}
+		defer done()
+	}
+
 	indexHeaderReader, err := s.indexReaderPool.NewBinaryReader(
 		ctx,
 		s.logger,
-		s.bkt,
+		bkt,
 		s.dir,
 		meta.ULID,
 		s.postingOffsetsInMemSampling,
@@ -415,10 +602,12 @@ func (s *BucketStore) addBlock(ctx context.Context, meta *metadata.Meta) (err er
 		s.chunkPool,
 		indexHeaderReader,
 		s.partitioner,
+		s.chunkReaderCfg,
 	)
 	if err != nil {
 		return errors.Wrap(err, "new bucket block")
 	}
+	b.fetchedBytesRateLimiter = s.fetchedBytesRateLimiter
 	defer func() {
 		if err != nil {
 			runutil.CloseWithErrCapture(&err, b, "index-header")
@@ -561,6 +750,39 @@ func blockSeries(
 	loadAggregates []storepb.Aggr, // List of aggregates to load when loading chunks.
 	logger log.Logger,
 ) (storepb.SeriesSet, *safeQueryStats, error) {
+	res, indexStats, err := blockSeriesEntries(ctx, indexr, chunkr, matchers, shard, seriesHashCache, chunksLimiter, seriesLimiter, skipChunks, minTime, maxTime, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	if skipChunks || len(res) == 0 {
+		return newBucketSeriesSet(res), indexStats, nil
+	}
+
+	if err := chunkr.load(res, loadAggregates); err != nil {
+		// The stats of the chunks fetched before the failure are returned, because the query may have
+		// failed due to a per-query limit, and they're sent to the querier.
+		return nil, indexStats.merge(chunkr.stats), errors.Wrap(err, "load chunks")
+	}
+
+	return newBucketSeriesSet(res), indexStats.merge(chunkr.stats), nil
+}
+
+// blockSeriesEntries returns the series matching given matchers, that have some data in given time range, like blockSeries.
+// If chunks are not skipped, the chunks of the series are added to chunkr, but not loaded. If chunkr is nil, the series
+// chunks are only referenced by the series entries, so that they can be loaded later.
+func blockSeriesEntries(
+	ctx context.Context,
+	indexr *bucketIndexReader,
+	chunkr *bucketChunkReader,
+	matchers []*labels.Matcher,
+	shard *sharding.ShardSelector,
+	seriesHashCache *hashcache.BlockSeriesHashCache,
+	chunksLimiter ChunksLimiter,
+	seriesLimiter SeriesLimiter,
+	skipChunks bool,
+	minTime, maxTime int64,
+	logger log.Logger,
+) ([]seriesEntry, *safeQueryStats, error) {
 	span, ctx := tracing.StartSpan(ctx, "blockSeries()")
 	span.LogKV(
 		"block ID", indexr.block.meta.ULID.String(),
@@ -578,7 +800,7 @@ func blockSeries(
 		res, ok := fetchCachedSeries(ctx, indexr.block.userID, indexr.block.indexCache, indexr.block.meta.ULID, matchers, shard, logger)
 		if ok {
 			span.LogKV("msg", "using cached result", "len", len(res))
-			return newBucketSeriesSet(res), indexStats, nil
+			return res, indexStats, nil
 		}
 	}
 
@@ -596,7 +818,7 @@ func blockSeries(
 	}
 
 	if len(ps) == 0 {
-		return storepb.EmptySeriesSet(), indexStats, nil
+		return nil, indexStats, nil
 	}
 
 	// Preload all series index data.
@@ -674,16 +896,21 @@ func blockSeries(
 				// Schedule loading chunks.
 				s.refs = make([]chunks.ChunkRef, 0, len(chks))
 				s.chks = make([]storepb.AggrChunk, 0, len(chks))
-				for j, meta := range chks {
-					// seriesEntry s is appended to res, but not at every outer loop iteration,
-					// therefore len(res) is the index we need here, not outer loop iteration number.
-					if err := chunkr.addLoad(meta.Ref, len(res), j); err != nil {
-						lookupErr = errors.Wrap(err, "add chunk load")
-						return
+				for _, meta := range chks {
+					if chunkr != nil {
+						// seriesEntry s is appended to res, but not at every outer loop iteration,
+						// therefore len(res) is the index we need here, not outer loop iteration number.
+						if err := chunkr.addLoad(meta.Ref, len(res), len(s.chks)); err != nil {
+							lookupErr = errors.Wrap(err, "add chunk load")
+							return
+						}
 					}
 					s.chks = append(s.chks, storepb.AggrChunk{
 						MinTime: meta.MinTime,
 						MaxTime: meta.MaxTime,
+						// The chunks not overlapping the queried time range have already been skipped when
+						// decoding the series, but the ones overlapping its edges need to be trimmed.
+						Boundary: meta.MinTime < minTime || meta.MaxTime > maxTime,
 					})
 					s.refs = append(s.refs, meta.Ref)
 				}
@@ -705,14 +932,9 @@ func blockSeries(
 
 	if skipChunks {
 		storeCachedSeries(ctx, indexr.block.indexCache, indexr.block.userID, indexr.block.meta.ULID, matchers, shard, res, logger)
-		return newBucketSeriesSet(res), indexStats.merge(&seriesCacheStats), nil
-	}
-
-	if err := chunkr.load(res, loadAggregates); err != nil {
-		return nil, nil, errors.Wrap(err, "load chunks")
 	}
 
-	return newBucketSeriesSet(res), indexStats.merge(chunkr.stats).merge(&seriesCacheStats), nil
+	return res, indexStats.merge(&seriesCacheStats), nil
 }
 
 type seriesCacheEntry struct {
@@ -766,15 +988,24 @@ func storeCachedSeries(ctx context.Context, indexCache indexcache.IndexCache, us
 }
 
 func populateChunk(out *storepb.AggrChunk, in chunkenc.Chunk, aggrs []storepb.Aggr, save func([]byte) ([]byte, error)) error {
-	if in.Encoding() == chunkenc.EncXOR {
-		b, err := save(in.Bytes())
-		if err != nil {
-			return err
-		}
-		out.Raw = &storepb.Chunk{Type: storepb.Chunk_XOR, Data: b}
-		return nil
+	var typ storepb.Chunk_Encoding
+	switch in.Encoding() {
+	case chunkenc.EncXOR:
+		typ = storepb.Chunk_XOR
+	case encHistogram:
+		typ = storepb.Chunk_Histogram
+	case encFloatHistogram:
+		typ = storepb.Chunk_FloatHistogram
+	default:
+		return errors.Errorf("unsupported chunk encoding %d", in.Encoding())
+	}
+
+	b, err := save(in.Bytes())
+	if err != nil {
+		return err
 	}
-	return errors.Errorf("unsupported chunk encoding %d", in.Encoding())
+	out.Raw = &storepb.Chunk{Type: typ, Data: b}
+	return nil
 }
 
 // debugFoundBlockSetOverview logs on debug level what exactly blocks we used for query in terms of
@@ -840,12 +1071,15 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		ctx              = srv.Context()
 		stats            = &queryStats{}
 		res              []storepb.SeriesSet
+		streamingRes     []blockEntries
+		streaming        = s.maxSeriesPerBatch > 0 && !req.SkipChunks
 		mtx              sync.Mutex
 		g, gctx          = errgroup.WithContext(ctx)
 		resHints         = &hintspb.SeriesResponseHints{}
 		reqBlockMatchers []*labels.Matcher
 		chunksLimiter    = s.chunksLimiterFactory(s.metrics.queriesDropped.WithLabelValues("chunks"))
 		seriesLimiter    = s.seriesLimiterFactory(s.metrics.queriesDropped.WithLabelValues("series"))
+		fetchLimits      = newQueryFetchLimits(s.chunkReaderCfg, s.metrics.queriesDropped.WithLabelValues("chunk_bytes"))
 	)
 
 	if req.Hints != nil {
@@ -888,8 +1122,9 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		var chunkr *bucketChunkReader
 		// We must keep the readers open until all their data has been sent.
 		indexr := b.indexReader()
-		if !req.SkipChunks {
+		if !req.SkipChunks && !streaming {
 			chunkr = b.chunkReader(gctx)
+			chunkr.setFetchLimits(fetchLimits)
 			defer runutil.CloseWithLogOnErr(s.logger, chunkr, "series block")
 		}
 
@@ -903,6 +1138,36 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 			blockSeriesHashCache = s.seriesHashCache.GetBlockCache(b.meta.ULID.String())
 		}
 
+		if streaming {
+			// The chunks are loaded in batches once the series of all blocks have been looked up.
+			g.Go(func() error {
+				entries, pstats, err := blockSeriesEntries(
+					gctx,
+					indexr,
+					nil,
+					matchers,
+					shardSelector,
+					blockSeriesHashCache,
+					chunksLimiter,
+					seriesLimiter,
+					false,
+					req.MinTime, req.MaxTime,
+					s.logger,
+				)
+				if err != nil {
+					return errors.Wrapf(err, "fetch series for block %s", b.meta.ULID)
+				}
+
+				mtx.Lock()
+				streamingRes = append(streamingRes, blockEntries{block: b, entries: entries})
+				stats = stats.merge(pstats.export())
+				mtx.Unlock()
+
+				return nil
+			})
+			continue
+		}
+
 		g.Go(func() error {
 			part, pstats, err := blockSeries(
 				gctx,
@@ -919,9 +1184,28 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 				s.logger,
 			)
 			if err != nil {
+				if pstats != nil {
+					mtx.Lock()
+					stats = stats.merge(pstats.export())
+					mtx.Unlock()
+				}
 				return errors.Wrapf(err, "fetch series for block %s", b.meta.ULID)
 			}
 
+			if s.chunksFetchEstimateLogging && !req.SkipChunks {
+				blockStats := pstats.export()
+				level.Debug(spanLogger).Log(
+					"msg", "chunks fetch estimate",
+					"block", b.meta.ULID,
+					"chunks", blockStats.chunksFetched,
+					"estimated_chunk_bytes", blockStats.chunksEstimatedSizeSum,
+					"fetched_chunk_bytes", blockStats.chunksFetchedSizeSum,
+					"refetched_chunks", blockStats.chunksRefetches,
+					"delta_bytes", blockStats.chunksFetchedSizeSum-blockStats.chunksEstimatedSizeSum,
+					"ratio", blockStats.chunksFetchedEstimateRatio(),
+				)
+			}
+
 			mtx.Lock()
 			res = append(res, part)
 			stats = stats.merge(pstats.export())
@@ -946,6 +1230,12 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		s.metrics.seriesDataFetched.WithLabelValues("chunks").Observe(float64(stats.chunksFetched))
 		s.metrics.seriesDataSizeTouched.WithLabelValues("chunks").Observe(float64(stats.chunksTouchedSizeSum))
 		s.metrics.seriesDataSizeFetched.WithLabelValues("chunks").Observe(float64(stats.chunksFetchedSizeSum))
+		s.metrics.seriesChunksSkippedBytes.Add(float64(stats.chunksSkippedBytes))
+		s.metrics.seriesChunkRangeReseeks.Add(float64(stats.chunksRangeReseeks))
+		s.metrics.seriesChunkBatchedReads.Add(float64(stats.chunksBatchedFetches))
+		s.metrics.seriesChunkRefetches.Add(float64(stats.chunksRefetches))
+		s.metrics.seriesHistogramChunks.WithLabelValues("histogram").Add(float64(stats.histogramChunksTouched))
+		s.metrics.seriesHistogramChunks.WithLabelValues("float_histogram").Add(float64(stats.floatHistogramChunksTouched))
 		s.metrics.resultSeriesCount.Observe(float64(stats.mergedSeriesCount))
 		s.metrics.cachedPostingsCompressions.WithLabelValues(labelEncode).Add(float64(stats.cachedPostingsCompressions))
 		s.metrics.cachedPostingsCompressions.WithLabelValues(labelDecode).Add(float64(stats.cachedPostingsDecompressions))
@@ -968,13 +1258,11 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		err = g.Wait()
 		gspan.Finish()
 		if err != nil {
-			code := codes.Aborted
-			if s, ok := status.FromError(errors.Cause(err)); ok {
-				code = s.Code()
-			}
+			code := errorCode(err, codes.Aborted)
+			s.sendStatsOnLimitError(srv, code, stats)
 			return status.Error(code, err.Error())
 		}
-		stats.blocksQueried = len(res)
+		stats.blocksQueried = len(res) + len(streamingRes)
 		stats.getAllDuration = time.Since(begin)
 		s.metrics.seriesGetAllDuration.Observe(stats.getAllDuration.Seconds())
 		s.metrics.seriesBlocksQueried.Observe(float64(stats.blocksQueried))
@@ -985,7 +1273,17 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 
 		// NOTE: We "carefully" assume series and chunks are sorted within each SeriesSet. This should be guaranteed by
 		// blockSeries method. In worst case deduplication logic won't deduplicate correctly, which will be accounted later.
-		set := storepb.MergeSeriesSets(res...)
+		var set storepb.SeriesSet
+		if streaming {
+			batchSet := newSeriesBatchSet(ctx, s.logger, streamingRes, s.maxSeriesPerBatch, req.Aggregates, fetchLimits)
+			defer func() {
+				batchSet.Close()
+				stats = stats.merge(batchSet.stats)
+			}()
+			set = batchSet
+		} else {
+			set = storepb.MergeSeriesSets(res...)
+		}
 		for set.Next() {
 			var series storepb.Series
 
@@ -1007,7 +1305,7 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 			}
 		}
 		if set.Err() != nil {
-			err = status.Error(codes.Unknown, errors.Wrap(set.Err(), "expand series set").Error())
+			err = status.Error(errorCode(set.Err(), codes.Unknown), errors.Wrap(set.Err(), "expand series set").Error())
 			return
 		}
 		stats.mergeDuration = time.Since(begin)
@@ -1015,6 +1313,10 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 
 		err = nil
 	})
+	if err != nil {
+		s.sendStatsOnLimitError(srv, status.Code(err), stats)
+		return err
+	}
 
 	var anyHints *types.Any
 	if anyHints, err = types.MarshalAny(resHints); err != nil {
@@ -1027,7 +1329,7 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 		return
 	}
 
-	if err = srv.Send(storepb.NewStatsResponse(stats.postingsFetchedSizeSum + stats.seriesFetchedSizeSum)); err != nil {
+	if err = srv.Send(storepb.NewStatsResponse(stats.postingsFetchedSizeSum+stats.seriesFetchedSizeSum, stats.chunksFetchedSizeSum, stats.chunksFetched)); err != nil {
 		err = status.Error(codes.Unknown, errors.Wrap(err, "sends series response stats").Error())
 		return
 	}
@@ -1035,6 +1337,25 @@ func (s *BucketStore) Series(req *storepb.SeriesRequest, srv storepb.Store_Serie
 	return err
 }
 
+// errorCode returns the gRPC status code of the error cause, if any, or the fallback code otherwise.
+func errorCode(err error, fallback codes.Code) codes.Code {
+	if s, ok := status.FromError(errors.Cause(err)); ok {
+		return s.Code()
+	}
+	return fallback
+}
+
+// sendStatsOnLimitError sends the stats of the data fetched so far, if the query failed with the code
+// of a limit error, so that the querier can track what has been fetched before the limit was reached.
+func (s *BucketStore) sendStatsOnLimitError(srv storepb.Store_SeriesServer, code codes.Code, stats *queryStats) {
+	if code != http.StatusUnprocessableEntity {
+		return
+	}
+	if err := srv.Send(storepb.NewStatsResponse(stats.postingsFetchedSizeSum+stats.seriesFetchedSizeSum, stats.chunksFetchedSizeSum, stats.chunksFetched)); err != nil {
+		level.Warn(s.logger).Log("msg", "failed to send series response stats", "err", err)
+	}
+}
+
 func chunksSize(chks []storepb.AggrChunk) (size int) {
 	for _, chk := range chks {
 		size += chk.Size() // This gets the encoded proto size.
@@ -1515,7 +1836,17 @@ type bucketBlock struct {
 
 	pendingReaders sync.WaitGroup
 
-	partitioner Partitioner
+	partitioner    Partitioner
+	chunkReaderCfg chunkReaderConfig
+
+	// Pool of buffered readers used to read chunks, to avoid allocating a new buffer for each range read.
+	chunkBufReaders sync.Pool
+
+	// Estimator of the length of the chunks of each segment file, nil if adaptive estimation is disabled.
+	chunkLengths *chunkLengthEstimator
+
+	// Limiter of the rate of the bytes fetched by the tenant, shared by all the tenant blocks. Nil means no limit.
+	fetchedBytesRateLimiter *fetchedBytesRateLimiter
 
 	// Block's labels used by block-level matchers to filter blocks to query. These are used to select blocks using
 	// request hints' BlockMatchers.
@@ -1536,6 +1867,7 @@ func newBucketBlock(
 	chunkPool pool.Bytes,
 	indexHeadReader indexheader.Reader,
 	p Partitioner,
+	chunkReaderCfg chunkReaderConfig,
 ) (b *bucketBlock, err error) {
 	b = &bucketBlock{
 		userID:            userID,
@@ -1546,11 +1878,15 @@ func newBucketBlock(
 		chunkPool:         chunkPool,
 		dir:               dir,
 		partitioner:       p,
+		chunkReaderCfg:    chunkReaderCfg,
 		meta:              meta,
 		indexHeaderReader: indexHeadReader,
 		// Inject the block ID as a label to allow to match blocks by ID.
 		blockLabels: labels.FromStrings(block.BlockIDLabel, meta.ULID.String()),
 	}
+	if chunkReaderCfg.adaptiveChunkLength {
+		b.chunkLengths = newChunkLengthEstimator()
+	}
 
 	// Get object handles for all chunk files (segment files) from meta.json, if available.
 	if len(meta.Thanos.SegmentFiles) > 0 {
@@ -1572,11 +1908,30 @@ func newBucketBlock(
 	return b, nil
 }
 
+// estimatedChunkLength returns the estimated max length of the chunks of the segment file seq.
+func (b *bucketBlock) estimatedChunkLength(seq int) int {
+	if b.chunkLengths == nil {
+		return mimir_tsdb.EstimatedMaxChunkSize
+	}
+	return b.chunkLengths.estimate(seq)
+}
+
+// observeChunkLength records the length of a chunk read from the segment file seq, if adaptive estimation is enabled.
+func (b *bucketBlock) observeChunkLength(seq, length int) {
+	if b.chunkLengths != nil {
+		b.chunkLengths.observe(seq, length)
+	}
+}
+
 func (b *bucketBlock) indexFilename() string {
 	return path.Join(b.meta.ULID.String(), block.IndexFilename)
 }
 
 func (b *bucketBlock) readIndexRange(ctx context.Context, off, length int64) ([]byte, error) {
+	if err := b.fetchedBytesRateLimiter.reserve(uint64(length)); err != nil {
+		return nil, err
+	}
+
 	r, err := b.bkt.GetRange(ctx, b.indexFilename(), off, length)
 	if err != nil {
 		return nil, errors.Wrap(err, "get range reader")
@@ -1600,7 +1955,7 @@ func (b *bucketBlock) readChunkRange(ctx context.Context, seq int, off, length i
 	}
 
 	// Get a reader for the required range.
-	reader, err := b.bkt.GetRange(ctx, b.chunkObjs[seq], off, length)
+	reader, err := b.getChunkRange(ctx, seq, off, length)
 	if err != nil {
 		return nil, errors.Wrap(err, "get range reader")
 	}
@@ -1612,10 +1967,12 @@ func (b *bucketBlock) readChunkRange(ctx context.Context, seq int, off, length i
 		return nil, errors.Wrap(err, "allocate chunk bytes")
 	}
 
-	*chunkBuffer, err = readByteRanges(reader, *chunkBuffer, chunkRanges)
+	data, err := readByteRanges(reader, *chunkBuffer, chunkRanges)
 	if err != nil {
+		b.chunkPool.Put(chunkBuffer)
 		return nil, err
 	}
+	*chunkBuffer = data
 
 	return chunkBuffer, nil
 }
@@ -1625,9 +1982,82 @@ func (b *bucketBlock) chunkRangeReader(ctx context.Context, seq int, off, length
 		return nil, errors.Errorf("unknown segment file for index %d", seq)
 	}
 
+	cache := b.chunkReaderCfg.cache
+	if cache == nil || length > cache.MaxItemSizeBytes() {
+		return b.getChunkRange(ctx, seq, off, length)
+	}
+
+	rng := chunkscache.Range{BlockID: b.meta.ULID, Seq: seq, Start: off, Length: length}
+	if data, ok := cache.FetchChunkRange(ctx, b.userID, rng); ok {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	reader, err := b.getChunkRange(ctx, seq, off, length)
+	if err != nil {
+		return nil, err
+	}
+	return &cachingChunkRangeReader{ctx: ctx, reader: reader, cache: cache, userID: b.userID, rng: rng, buf: make([]byte, 0, length)}, nil
+}
+
+// getChunkRange reads a range of the segment file seq from the bucket, hedging the range read if enabled.
+func (b *bucketBlock) getChunkRange(ctx context.Context, seq int, off, length int64) (io.ReadCloser, error) {
+	if hedger := b.chunkReaderCfg.hedger; hedger != nil {
+		return hedger.getRange(ctx, b.bkt, b.chunkObjs[seq], off, length)
+	}
 	return b.bkt.GetRange(ctx, b.chunkObjs[seq], off, length)
 }
 
+// cachingChunkRangeReader reads a chunk range from the bucket and stores it in the cache once
+// it has been read entirely. The chunk ranges which are only partially read are not cached.
+type cachingChunkRangeReader struct {
+	ctx    context.Context
+	reader io.ReadCloser
+	cache  chunkscache.Cache
+	userID string
+	rng    chunkscache.Range
+
+	buf []byte
+	eof bool
+	err bool
+}
+
+func (r *cachingChunkRangeReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.buf = append(r.buf, p[:n]...)
+	if err == io.EOF {
+		r.eof = true
+	} else if err != nil {
+		r.err = true
+	}
+	return n, err
+}
+
+func (r *cachingChunkRangeReader) Close() error {
+	// The range may be shorter than requested if it ends after the end of the segment file.
+	if !r.err && (r.eof || int64(len(r.buf)) == r.rng.Length) {
+		r.cache.StoreChunkRange(r.ctx, r.userID, r.rng, r.buf)
+	}
+	return r.reader.Close()
+}
+
+// getChunkBufReader returns a buffered reader from the pool, reading from r.
+// The returned reader must be returned to the pool calling putChunkBufReader() once done.
+func (b *bucketBlock) getChunkBufReader(r io.Reader) *bufio.Reader {
+	if bufReader, ok := b.chunkBufReaders.Get().(*bufio.Reader); ok {
+		bufReader.Reset(r)
+		return bufReader
+	}
+
+	// All pooled readers are created with the same size, so that they can always be reused.
+	return bufio.NewReaderSize(r, mimir_tsdb.EstimatedMaxChunkSize)
+}
+
+func (b *bucketBlock) putChunkBufReader(bufReader *bufio.Reader) {
+	// Do not retain the underlying reader.
+	bufReader.Reset(nil)
+	b.chunkBufReaders.Put(bufReader)
+}
+
 func (b *bucketBlock) indexReader() *bucketIndexReader {
 	b.pendingReaders.Add(1)
 	return newBucketIndexReader(b)
@@ -1638,6 +2068,14 @@ func (b *bucketBlock) chunkReader(ctx context.Context) *bucketChunkReader {
 	return newBucketChunkReader(ctx, b)
 }
 
+// chunksPartitioner returns the partitioner used to partition the chunks to load into range reads.
+func (b *bucketBlock) chunksPartitioner() Partitioner {
+	if b.chunkReaderCfg.partitioner != nil {
+		return b.chunkReaderCfg.partitioner
+	}
+	return b.partitioner
+}
+
 // matchLabels verifies whether the block matches the given matchers.
 func (b *bucketBlock) matchLabels(matchers []*labels.Matcher) bool {
 	for _, m := range matchers {
@@ -6,35 +6,198 @@
 package storegateway
 
 import (
-	"bufio"
 	"context"
 	"encoding/binary"
+	"fmt"
+	"hash/crc32"
 	"io"
+	"net/http"
 	"sort"
 	"sync"
 	"time"
 
+	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/runutil"
+	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/weaveworks/common/httpgrpc"
 	"golang.org/x/sync/errgroup"
 
 	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storegateway/chunkscache"
 	"github.com/grafana/mimir/pkg/storegateway/storepb"
+	"github.com/grafana/mimir/pkg/util/gate"
 )
 
+// chunkReaderConfig holds the settings used by bucketChunkReader to fetch chunks from the bucket.
+// It's shared by all blocks of a BucketStore.
+type chunkReaderConfig struct {
+	// mergeGapBytes is the max number of unused bytes between two partitions for which they're
+	// coalesced together into a single range read. 0 disables coalescing.
+	mergeGapBytes uint64
+
+	// maxDiscardRatio is the max ratio of a partition size which is discarded to skip the unused bytes
+	// before the next chunk. Larger gaps are skipped by issuing a new range read. 0 disables it.
+	maxDiscardRatio float64
+
+	// maxDiscardBytes is the max number of unused bytes before the next chunk which are discarded.
+	// Larger gaps are skipped by issuing a new range read. 0 disables it.
+	maxDiscardBytes uint64
+
+	// readAhead enables loading the partitions of a segment file sequentially, issuing the
+	// range read of the next partition while the current one is processed.
+	readAhead bool
+
+	// fetchGate limits the number of concurrent chunk range reads. It's shared by all the
+	// BucketStores of a store-gateway. Nil means no limit.
+	fetchGate gate.Gate
+
+	// partitioner partitions the chunks to load into range reads. Partitions may start before their
+	// first chunk, for example to align the range reads. Nil means the block's partitioner is used.
+	partitioner Partitioner
+
+	// fetchTimeout is the max time a chunks range read, from issuing it to reading the last byte,
+	// can take before failing. 0 disables the timeout.
+	fetchTimeout time.Duration
+
+	// batchMaxBytes is the max total size of the range reads of contiguous segment files which are
+	// batched together and issued sequentially by a single fetch task. 0 disables batching.
+	batchMaxBytes uint64
+
+	// cache caches the chunk ranges read from the bucket. It's shared by all the tenants. Nil disables caching.
+	cache chunkscache.Cache
+
+	// slabPool pools the slabs the loaded chunks are copied to. It's shared by all the BucketStores
+	// of a store-gateway. Nil means the slabs are allocated for each chunk reader.
+	slabPool *chunkSlabPool
+
+	// hedger hedges the chunk range reads from the bucket which are slower than the usual latency.
+	// It's shared by all the BucketStores of a store-gateway. Nil disables hedging.
+	hedger *chunkRangeHedger
+
+	// adaptiveChunkLength enables estimating the length of the chunks to read from the length of the
+	// chunks of the same segment file read so far, instead of always assuming EstimatedMaxChunkSize.
+	adaptiveChunkLength bool
+
+	// maxConcurrentFetchesPerQuery is the max number of concurrent chunk range reads issued by a single
+	// query, across all the blocks it queries. 0 disables the limit.
+	maxConcurrentFetchesPerQuery int
+
+	// maxFetchedBytesPerQuery is the max number of chunk bytes fetched by a single query, across all
+	// the blocks it queries. 0 disables the limit.
+	maxFetchedBytesPerQuery uint64
+
+	// validateChecksums enables validating the CRC32 checksum stored after each chunk in the segment
+	// files, which requires reading it along with the chunk.
+	validateChecksums bool
+}
+
+// queryFetchLimits are the limits on the chunk range reads issued by a single query. They're shared by the
+// chunk readers of all the blocks queried, so that a query can't saturate the bucket connection pool.
+type queryFetchLimits struct {
+	// gate limits the number of concurrent chunk range reads of the query.
+	gate gate.Gate
+
+	// bytesLimiter limits the number of chunk bytes fetched by the query.
+	bytesLimiter *Limiter
+}
+
+func newQueryFetchLimits(cfg chunkReaderConfig, failedCounter prometheus.Counter) *queryFetchLimits {
+	l := &queryFetchLimits{
+		gate:         gate.NewNoop(),
+		bytesLimiter: NewLimiter(cfg.maxFetchedBytesPerQuery, failedCounter),
+	}
+	if cfg.maxConcurrentFetchesPerQuery > 0 {
+		l.gate = gate.NewBlocking(cfg.maxConcurrentFetchesPerQuery)
+	}
+	return l
+}
+
+// reserveBytes reserves num chunk bytes to fetch. It returns a limit error if the query exceeded the max
+// number of chunk bytes it can fetch.
+func (l *queryFetchLimits) reserveBytes(num uint64) error {
+	if err := l.bytesLimiter.Reserve(num); err != nil {
+		return httpgrpc.Errorf(http.StatusUnprocessableEntity, "exceeded chunk bytes limit: %s", err)
+	}
+	return nil
+}
+
+// ChunkRefOutOfRangeError is returned when a chunk reference points to a segment file which doesn't exist
+// in the block. It may be caused by a corrupted or stale block, for example when the block is deleted
+// while being queried, so callers may invalidate the block metadata and retry.
+type ChunkRefOutOfRangeError struct {
+	BlockID ulid.ULID
+	// Seq is the segment file sequence number of the chunk reference.
+	Seq int
+	// SegmentFiles is the number of segment files in the block: valid sequence numbers are in the range [0, SegmentFiles).
+	SegmentFiles int
+}
+
+func (e ChunkRefOutOfRangeError) Error() string {
+	return fmt.Sprintf("reference sequence %d out of range [0, %d) for block %s", e.Seq, e.SegmentFiles, e.BlockID)
+}
+
+// ChunkChecksumMismatchError is returned when the CRC32 checksum of a chunk read from the bucket doesn't match
+// the one stored after the chunk in the segment file. It means the block is corrupted, so it should be quarantined.
+type ChunkChecksumMismatchError struct {
+	BlockID ulid.ULID
+	Seq     int
+	Offset  uint32
+
+	Expected, Actual uint32
+}
+
+func (e ChunkChecksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch of the chunk in block %s, segment file %d, offset %x: expected %x, actual %x", e.BlockID, e.Seq, e.Offset, e.Expected, e.Actual)
+}
+
+// castagnoliTable is the table of the CRC32 checksum of the chunks, as written by the TSDB.
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// chunksFetchTimeoutError is returned when a chunks range read doesn't complete within the configured timeout.
+type chunksFetchTimeoutError struct {
+	timeout time.Duration
+	err     error
+}
+
+func (e chunksFetchTimeoutError) Error() string {
+	return fmt.Sprintf("chunks range read did not complete within %s: %s", e.timeout, e.err)
+}
+
+func (e chunksFetchTimeoutError) Unwrap() error {
+	return e.err
+}
+
 type bucketChunkReader struct {
 	ctx   context.Context
 	block *bucketBlock
 
 	toLoad [][]loadIdx
 
+	// Whether the loaded chunks are decoded and validated, instead of being forwarded as raw bytes.
+	decodeChunks bool
+
+	// Limits of the query the chunks are loaded for, if any.
+	fetchLimits *queryFetchLimits
+
 	// Mutex protects access to following fields, when updated from chunks-loading goroutines.
-	// After chunks are loaded, mutex is no longer used.
+	// After chunks are loaded, mutex is only used to close the reader and get the touched segment files.
 	mtx        sync.Mutex
 	stats      *queryStats
-	chunkBytes []*[]byte // Byte slice to return to the chunk pool on close.
+	chunkSlabs []*[]byte // Slabs holding the loaded chunks, to return to the slab pool on close.
+	closed     bool
+
+	// Sequence numbers of the segment files which have been read.
+	touchedSeqs map[int]struct{}
+}
+
+// segmentFileRef identifies a segment file of a block.
+type segmentFileRef struct {
+	blockID ulid.ULID
+	seq     int
 }
 
 func newBucketChunkReader(ctx context.Context, block *bucketBlock) *bucketChunkReader {
@@ -46,15 +209,55 @@ func newBucketChunkReader(ctx context.Context, block *bucketBlock) *bucketChunkR
 	}
 }
 
+// Close returns all the chunk slabs to the pool. It's safe to call Close multiple times.
 func (r *bucketChunkReader) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
 	r.block.pendingReaders.Done()
 
-	for _, b := range r.chunkBytes {
-		r.block.chunkPool.Put(b)
+	for _, b := range r.chunkSlabs {
+		r.block.chunkReaderCfg.slabPool.put(b)
 	}
+	r.chunkSlabs = nil
 	return nil
 }
 
+// setFetchLimits sets the limits of the query the chunks are loaded for, which may be shared with the
+// chunk readers of other blocks.
+func (r *bucketChunkReader) setFetchLimits(limits *queryFetchLimits) {
+	r.fetchLimits = limits
+}
+
+// reserveFetchedBytes reserves num chunk bytes to fetch from the query limits and the tenant rate limit, if any.
+func (r *bucketChunkReader) reserveFetchedBytes(num uint64) error {
+	if r.fetchLimits != nil {
+		if err := r.fetchLimits.reserveBytes(num); err != nil {
+			return err
+		}
+	}
+	return r.block.fetchedBytesRateLimiter.reserve(num)
+}
+
+// queryFetchGate returns the gate limiting the concurrent chunk range reads of the query, if any.
+func (r *bucketChunkReader) queryFetchGate() gate.Gate {
+	if r.fetchLimits == nil {
+		return gate.NewNoop()
+	}
+	return r.fetchLimits.gate
+}
+
+// enableChunksDecoding makes the reader decode and validate the loaded chunks, failing the load
+// if any of them is corrupted. It's meant for query paths which need to catch corrupted chunks
+// early, at the cost of iterating all the samples of the loaded chunks.
+func (r *bucketChunkReader) enableChunksDecoding() {
+	r.decodeChunks = true
+}
+
 // addLoad adds the chunk with id to the data set to be fetched.
 // Chunk will be fetched and saved to res[seriesEntry][chunk] upon r.load(res, <...>) call.
 func (r *bucketChunkReader) addLoad(id chunks.ChunkRef, seriesEntry, chunk int) error {
@@ -63,48 +266,341 @@ func (r *bucketChunkReader) addLoad(id chunks.ChunkRef, seriesEntry, chunk int)
 		off = uint32(id)
 	)
 	if seq >= len(r.toLoad) {
-		return errors.Errorf("reference sequence %d out of range", seq)
+		return ChunkRefOutOfRangeError{BlockID: r.block.meta.ULID, Seq: seq, SegmentFiles: len(r.toLoad)}
 	}
 	r.toLoad[seq] = append(r.toLoad[seq], loadIdx{off, seriesEntry, chunk})
 	return nil
 }
 
 // load loads all added chunks and saves resulting aggrs to res.
+// Chunks added multiple times are read only once.
 func (r *bucketChunkReader) load(res []seriesEntry, aggrs []storepb.Aggr) error {
 	g, ctx := errgroup.WithContext(r.ctx)
+	if maxConcurrent := r.block.chunkReaderCfg.maxConcurrentFetchesPerQuery; maxConcurrent > 0 {
+		// Don't spawn more goroutines than range reads the query can issue concurrently.
+		g.SetLimit(maxConcurrent)
+	}
+
+	var (
+		duplicates []duplicateLoadIdx
+		batch      segmentsBatch
+		batchSaved int
+	)
+	flushBatch := func() {
+		if len(batch.segments) == 0 {
+			return
+		}
+		segments := batch.segments
+		batchSaved += batch.parts - 1
+		g.Go(func() error {
+			for _, s := range segments {
+				if err := r.loadPartitionsSequentially(ctx, res, aggrs, s.seq, s.parts, s.pIdxs, r.block.chunkReaderCfg.readAhead); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		batch = segmentsBatch{}
+	}
 
 	for seq, pIdxs := range r.toLoad {
-		sort.Slice(pIdxs, func(i, j int) bool {
+		sort.SliceStable(pIdxs, func(i, j int) bool {
 			return pIdxs[i].offset < pIdxs[j].offset
 		})
-		parts := r.block.partitioner.Partition(len(pIdxs), func(i int) (start, end uint64) {
-			return uint64(pIdxs[i].offset), uint64(pIdxs[i].offset) + mimir_tsdb.EstimatedMaxChunkSize
+
+		var seqDuplicates []duplicateLoadIdx
+		pIdxs, seqDuplicates = dedupLoadIdxs(pIdxs)
+		duplicates = append(duplicates, seqDuplicates...)
+
+		// The estimate is taken once per segment file, because it may change while the chunks are loaded.
+		chunkLen := r.block.estimatedChunkLength(seq) + r.checksumLen()
+		parts := r.block.chunksPartitioner().Partition(len(pIdxs), func(i int) (start, end uint64) {
+			return uint64(pIdxs[i].offset), uint64(pIdxs[i].offset) + uint64(chunkLen)
 		})
+		parts = coalesceParts(parts, r.block.chunkReaderCfg.mergeGapBytes)
+		if len(parts) == 0 {
+			continue
+		}
+
+		r.mtx.Lock()
+		r.stats.chunksEstimatedSizeSum += len(pIdxs) * chunkLen
+		r.mtx.Unlock()
+
+		seq := seq
+		pIdxs := pIdxs
+		if maxBytes := r.block.chunkReaderCfg.batchMaxBytes; maxBytes > 0 {
+			if size := partsSize(parts); size <= maxBytes {
+				if !batch.canAppend(seq, size, maxBytes) {
+					flushBatch()
+				}
+				batch.append(segmentParts{seq: seq, parts: parts, pIdxs: pIdxs}, size)
+				continue
+			}
+		}
+
+		if r.block.chunkReaderCfg.readAhead {
+			g.Go(func() error {
+				return r.loadPartitionsSequentially(ctx, res, aggrs, seq, parts, pIdxs, true)
+			})
+			continue
+		}
 
 		for _, p := range parts {
-			seq := seq
 			p := p
 			indices := pIdxs[p.ElemRng[0]:p.ElemRng[1]]
 			g.Go(func() error {
-				return r.loadChunks(ctx, res, aggrs, seq, p, indices)
+				return r.loadChunks(ctx, res, aggrs, seq, p, indices, r.openChunkRange(ctx, seq, p))
 			})
 		}
 	}
-	return g.Wait()
+	flushBatch()
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	// Populate the chunks added multiple times from the copy which has been loaded.
+	for _, d := range duplicates {
+		src := res[d.src.seriesEntry].chks[d.src.chunk].Raw
+		if src == nil {
+			continue
+		}
+		res[d.dst.seriesEntry].chks[d.dst.chunk].Raw = &storepb.Chunk{Type: src.Type, Data: src.Data}
+	}
+
+	r.mtx.Lock()
+	r.stats.chunksDeduped += len(duplicates)
+	r.stats.chunksBatchedFetches += batchSaved
+	r.mtx.Unlock()
+
+	return nil
 }
 
-// loadChunks will read range [start, end] from the segment file with sequence number seq.
-// This data range covers chunks starting at supplied offsets.
-func (r *bucketChunkReader) loadChunks(ctx context.Context, res []seriesEntry, aggrs []storepb.Aggr, seq int, part Part, pIdxs []loadIdx) error {
-	fetchBegin := time.Now()
+// segmentParts are the partitions of a segment file to load, along with the chunks they cover.
+type segmentParts struct {
+	seq   int
+	parts []Part
+	pIdxs []loadIdx
+}
+
+// segmentsBatch is a group of small range reads of contiguous segment files, which are issued
+// sequentially by a single fetch task instead of concurrently.
+//
+// Each segment file is a separate object in the bucket, so the range reads of different segment
+// files can't be merged into a single bucket GET object request. Batching them doesn't reduce the
+// number of requests to the object storage, but the number of concurrent ones: the batched range
+// reads occupy a single slot in the chunks fetch gate at a time and, being issued one after the
+// other, they're likely to reuse the same pooled connection instead of opening new ones.
+type segmentsBatch struct {
+	segments []segmentParts
+	parts    int
+	size     uint64
+}
+
+// canAppend returns whether the range reads of the segment file seq, whose total size is size, can be
+// added to the batch: the segment file must follow the last one in the batch, and the batch total size
+// must not exceed maxBytes.
+func (b *segmentsBatch) canAppend(seq int, size, maxBytes uint64) bool {
+	if len(b.segments) == 0 {
+		return true
+	}
+	return b.segments[len(b.segments)-1].seq == seq-1 && b.size+size <= maxBytes
+}
+
+func (b *segmentsBatch) append(s segmentParts, size uint64) {
+	b.segments = append(b.segments, s)
+	b.parts += len(s.parts)
+	b.size += size
+}
+
+// partsSize returns the total number of bytes read by the partitions.
+func partsSize(parts []Part) uint64 {
+	var size uint64
+	for _, p := range parts {
+		size += p.End - p.Start
+	}
+	return size
+}
+
+// dedupLoadIdxs removes from pIdxs, sorted by offset, the entries referencing the same chunk of a previous entry.
+// It returns the unique entries, preserving their order, and the removed ones along with the entry they duplicate.
+// The input slice is modified in place.
+func dedupLoadIdxs(pIdxs []loadIdx) ([]loadIdx, []duplicateLoadIdx) {
+	var duplicates []duplicateLoadIdx
+
+	unique := pIdxs[:0]
+	for _, pIdx := range pIdxs {
+		if len(unique) > 0 && unique[len(unique)-1].offset == pIdx.offset {
+			duplicates = append(duplicates, duplicateLoadIdx{src: unique[len(unique)-1], dst: pIdx})
+			continue
+		}
+		unique = append(unique, pIdx)
+	}
+	return unique, duplicates
+}
+
+// trackChunksFetchDuration tracks the duration of a chunks range read. It must be called while holding the mutex.
+func (r *bucketChunkReader) trackChunksFetchDuration(d time.Duration) {
+	r.stats.chunksFetchDurationSum += d
+	r.block.metrics.chunksFetchDuration.Observe(d.Seconds())
+}
+
+// shouldSkipGap returns whether the gap of unused bytes before the next chunk in the part is large enough
+// to be skipped with a new range read, rather than being read and discarded.
+func (r *bucketChunkReader) shouldSkipGap(part Part, gap int) bool {
+	if maxBytes := r.block.chunkReaderCfg.maxDiscardBytes; maxBytes > 0 && uint64(gap) > maxBytes {
+		return true
+	}
+
+	ratio := r.block.chunkReaderCfg.maxDiscardRatio
+	if ratio <= 0 || part.End <= part.Start {
+		return false
+	}
+
+	return float64(gap)/float64(part.End-part.Start) > ratio
+}
+
+// withFetchTimeout returns a context expiring once the chunks fetch timeout elapsed, if it's enabled.
+func (r *bucketChunkReader) withFetchTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := r.block.chunkReaderCfg.fetchTimeout; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// fetchError returns a chunksFetchTimeoutError if err has been caused by the fetch context fetchCtx
+// timing out while the parent context ctx is still valid, or err otherwise.
+func (r *bucketChunkReader) fetchError(ctx, fetchCtx context.Context, err error) error {
+	if err == nil || ctx.Err() != nil || !errors.Is(fetchCtx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return chunksFetchTimeoutError{timeout: r.block.chunkReaderCfg.fetchTimeout, err: err}
+}
+
+// chunkRange is the result of a range read of a partition of a segment file.
+type chunkRange struct {
+	reader        io.ReadCloser
+	fetchDuration time.Duration
+	err           error
+
+	// ctx is the context of the range read, expiring once the chunks fetch timeout elapsed.
+	// It must be used for any further range read of the partition.
+	ctx context.Context
+
+	// done releases the slots acquired in the chunks fetch gates and cancels ctx. It must be called
+	// once the range has been read, and it's nil if err is not nil.
+	done func()
+}
 
-	// Get a reader for the required range.
-	reader, err := r.block.chunkRangeReader(ctx, seq, int64(part.Start), int64(part.End-part.Start))
+// openChunkRange issues the range read of the partition part of the segment file seq, once a slot
+// is available in both the query and the store-gateway chunks fetch gates.
+func (r *bucketChunkReader) openChunkRange(ctx context.Context, seq int, part Part) chunkRange {
+	if err := r.reserveFetchedBytes(part.End - part.Start); err != nil {
+		return chunkRange{err: err}
+	}
+
+	// The query gate is acquired first, so that a query waiting for its own range reads to complete
+	// doesn't hold a slot of the gate shared with the other queries.
+	queryGate := r.queryFetchGate()
+	if err := queryGate.Start(ctx); err != nil {
+		return chunkRange{err: errors.Wrap(err, "wait for query chunks fetch gate")}
+	}
+	fetchGate := r.block.chunkReaderCfg.fetchGate
+	if fetchGate == nil {
+		fetchGate = gate.NewNoop()
+	}
+	if err := fetchGate.Start(ctx); err != nil {
+		queryGate.Done()
+		return chunkRange{err: errors.Wrap(err, "wait for chunks fetch gate")}
+	}
+
+	// The timeout starts once the range read is issued, so that waiting for the gate doesn't count.
+	fetchCtx, cancel := r.withFetchTimeout(ctx)
+	fetchBegin := time.Now()
+	reader, err := r.block.chunkRangeReader(fetchCtx, seq, int64(part.Start), int64(part.End-part.Start))
 	if err != nil {
-		return errors.Wrap(err, "get range reader")
+		err = r.fetchError(ctx, fetchCtx, err)
+		cancel()
+		fetchGate.Done()
+		queryGate.Done()
+		return chunkRange{err: errors.Wrap(err, "get range reader")}
+	}
+
+	done := func() {
+		cancel()
+		fetchGate.Done()
+		queryGate.Done()
+	}
+	return chunkRange{reader: reader, fetchDuration: time.Since(fetchBegin), ctx: fetchCtx, done: done}
+}
+
+// prefetchChunkRange issues the range read of the partition part of the segment file seq in the background.
+// The result is sent to the returned channel, and the caller is responsible for closing its reader.
+func (r *bucketChunkReader) prefetchChunkRange(ctx context.Context, seq int, part Part) <-chan chunkRange {
+	ch := make(chan chunkRange, 1)
+	go func() {
+		ch <- r.openChunkRange(ctx, seq, part)
+	}()
+	return ch
+}
+
+// loadPartitionsSequentially loads the partitions parts of the segment file seq one after the other.
+// If readAhead is enabled, the range read of the next partition is issued while the current one is
+// processed, so that the object storage latency is pipelined with the chunks decoding.
+func (r *bucketChunkReader) loadPartitionsSequentially(ctx context.Context, res []seriesEntry, aggrs []storepb.Aggr, seq int, parts []Part, pIdxs []loadIdx, readAhead bool) error {
+	var next <-chan chunkRange
+	defer func() {
+		// Close the range reader prefetched for a partition which will not be loaded because of an error.
+		if next != nil {
+			if rng := <-next; rng.err == nil {
+				runutil.CloseWithLogOnErr(r.block.logger, rng.reader, "readChunkRange close prefetched range reader")
+				rng.done()
+			}
+		}
+	}()
+
+	for i, p := range parts {
+		var rng chunkRange
+		if next != nil {
+			rng = <-next
+			next = nil
+		} else {
+			rng = r.openChunkRange(ctx, seq, p)
+		}
+
+		if readAhead && rng.err == nil && i+1 < len(parts) {
+			next = r.prefetchChunkRange(ctx, seq, parts[i+1])
+		}
+
+		if err := r.loadChunks(ctx, res, aggrs, seq, p, pIdxs[p.ElemRng[0]:p.ElemRng[1]], rng); err != nil {
+			return err
+		}
 	}
-	defer runutil.CloseWithLogOnErr(r.block.logger, reader, "readChunkRange close range reader")
-	bufReader := bufio.NewReaderSize(reader, mimir_tsdb.EstimatedMaxChunkSize)
+	return nil
+}
+
+// loadChunks will read range [start, end] from the segment file with sequence number seq, using the
+// range reader rng which is closed once done. This data range covers chunks starting at supplied offsets.
+// If the range read doesn't complete within the chunks fetch timeout, a chunksFetchTimeoutError is returned.
+func (r *bucketChunkReader) loadChunks(ctx context.Context, res []seriesEntry, aggrs []storepb.Aggr, seq int, part Part, pIdxs []loadIdx, rng chunkRange) (err error) {
+	if rng.err != nil {
+		return rng.err
+	}
+	defer rng.done()
+	defer func() {
+		err = r.fetchError(ctx, rng.ctx, err)
+	}()
+
+	var (
+		fetchBegin time.Time
+		reader     = rng.reader
+	)
+	bufReader := r.block.getChunkBufReader(reader)
+	defer func() {
+		// The reader may be replaced while skipping large gaps, so we close the current one.
+		r.block.putChunkBufReader(bufReader)
+		runutil.CloseWithLogOnErr(r.block.logger, reader, "readChunkRange close range reader")
+	}()
 
 	locked := true
 	r.mtx.Lock()
@@ -115,23 +611,54 @@ func (r *bucketChunkReader) loadChunks(ctx context.Context, res []seriesEntry, a
 		}
 	}()
 
+	if r.touchedSeqs == nil {
+		r.touchedSeqs = map[int]struct{}{}
+	}
+	r.touchedSeqs[seq] = struct{}{}
+
 	r.stats.chunksFetchCount++
 	r.stats.chunksFetched += len(pIdxs)
-	r.stats.chunksFetchDurationSum += time.Since(fetchBegin)
+	r.trackChunksFetchDuration(rng.fetchDuration)
 	r.stats.chunksFetchedSizeSum += int(part.End - part.Start)
 
 	var (
 		buf        = make([]byte, mimir_tsdb.EstimatedMaxChunkSize)
-		readOffset = int(pIdxs[0].offset)
+		readOffset = int(part.Start)
+		crcLen     = r.checksumLen()
 
 		// Save a few allocations.
 		written  int64
 		diff     uint32
 		chunkLen int
 		n        int
+		chk      chunkenc.Chunk
 	)
 
 	for i, pIdx := range pIdxs {
+		// Skip a large gap with a new range read starting from the chunk, instead of discarding it.
+		if gap := int(pIdx.offset) - readOffset; gap > 0 && r.shouldSkipGap(part, gap) {
+			r.mtx.Unlock()
+			locked = false
+
+			fetchBegin = time.Now()
+			nextReader, err := r.block.chunkRangeReader(rng.ctx, seq, int64(pIdx.offset), int64(part.End)-int64(pIdx.offset))
+			if err != nil {
+				return errors.Wrap(err, "get range reader")
+			}
+			runutil.CloseWithLogOnErr(r.block.logger, reader, "readChunkRange close range reader")
+			reader = nextReader
+			bufReader.Reset(reader)
+			readOffset = int(pIdx.offset)
+
+			r.mtx.Lock()
+			locked = true
+
+			r.stats.chunksFetchCount++
+			r.stats.chunksRangeReseeks++
+			r.trackChunksFetchDuration(time.Since(fetchBegin))
+			r.stats.chunksFetchedSizeSum -= gap
+		}
+
 		// Fast forward range reader to the next chunk start in case of sparse (for our purposes) byte range.
 		for readOffset < int(pIdx.offset) {
 			written, err = io.CopyN(io.Discard, bufReader, int64(pIdx.offset)-int64(readOffset))
@@ -139,15 +666,20 @@ func (r *bucketChunkReader) loadChunks(ctx context.Context, res []seriesEntry, a
 				return errors.Wrap(err, "fast forward range reader")
 			}
 			readOffset += int(written)
+			r.stats.chunksSkippedBytes += int(written)
 		}
-		// Presume chunk length to be reasonably large for common use cases.
-		// However, declaration for EstimatedMaxChunkSize warns us some chunks could be larger in some rare cases.
-		// This is handled further down below.
+		// Presume chunk length to be reasonably large for common use cases: read up to the next chunk or,
+		// for the last one, up to the end of the partition, which is sized on the estimated chunk length.
+		// However, declaration for EstimatedMaxChunkSize warns us some chunks could be larger in some rare cases,
+		// and the estimated chunk length may be exceeded too. This is handled further down below.
 		chunkLen = mimir_tsdb.EstimatedMaxChunkSize
 		if i+1 < len(pIdxs) {
-			if diff = pIdxs[i+1].offset - pIdx.offset; int(diff) < chunkLen {
-				chunkLen = int(diff)
-			}
+			diff = pIdxs[i+1].offset - pIdx.offset
+		} else {
+			diff = uint32(part.End) - pIdx.offset
+		}
+		if int(diff) < chunkLen {
+			chunkLen = int(diff)
 		}
 		cb := buf[:chunkLen]
 		n, err = io.ReadFull(bufReader, cb)
@@ -165,13 +697,19 @@ func (r *bucketChunkReader) loadChunks(ctx context.Context, res []seriesEntry, a
 		// Chunk length is n (number of bytes used to encode chunk data), 1 for chunk encoding and chunkDataLen for actual chunk data.
 		// There is also crc32 after the chunk, but we ignore that.
 		chunkLen = n + 1 + int(chunkDataLen)
-		if chunkLen <= len(cb) {
-			err = populateChunk(&(res[pIdx.seriesEntry].chks[pIdx.chunk]), rawChunk(cb[n:chunkLen]), aggrs, r.save)
+		r.block.observeChunkLength(seq, chunkLen)
+		if chunkLen+crcLen <= len(cb) {
+			if err = r.checkChunkChecksum(cb[n:chunkLen+crcLen], seq, pIdx.offset); err != nil {
+				return err
+			}
+			if chk, err = r.toChunk(rawChunk(cb[n:chunkLen]), seq, pIdx.offset); err != nil {
+				return err
+			}
+			err = populateChunk(&(res[pIdx.seriesEntry].chks[pIdx.chunk]), chk, aggrs, r.save)
 			if err != nil {
 				return errors.Wrap(err, "populate chunk")
 			}
-			r.stats.chunksTouched++
-			r.stats.chunksTouchedSizeSum += int(chunkDataLen)
+			r.trackChunkTouched(chk, int(chunkDataLen))
 			continue
 		}
 
@@ -179,50 +717,180 @@ func (r *bucketChunkReader) loadChunks(ctx context.Context, res []seriesEntry, a
 		r.mtx.Unlock()
 		locked = false
 
+		if err := r.reserveFetchedBytes(uint64(chunkLen + crcLen)); err != nil {
+			return err
+		}
 		fetchBegin = time.Now()
 
-		// Read entire chunk into new buffer.
+		// Read entire chunk, and its checksum if it's validated, into new buffer.
 		// TODO: readChunkRange call could be avoided for any chunk but last in this particular part.
-		nb, err := r.block.readChunkRange(ctx, seq, int64(pIdx.offset), int64(chunkLen), []byteRange{{offset: 0, length: chunkLen}})
+		nb, err := r.block.readChunkRange(rng.ctx, seq, int64(pIdx.offset), int64(chunkLen+crcLen), []byteRange{{offset: 0, length: chunkLen + crcLen}})
 		if err != nil {
-			return errors.Wrapf(err, "preloaded chunk too small, expecting %d, and failed to fetch full chunk", chunkLen)
+			return errors.Wrapf(err, "preloaded chunk too small, expecting %d, and failed to fetch full chunk", chunkLen+crcLen)
 		}
-		if len(*nb) != chunkLen {
-			return errors.Errorf("preloaded chunk too small, expecting %d", chunkLen)
+		if len(*nb) != chunkLen+crcLen {
+			r.block.chunkPool.Put(nb)
+			return errors.Errorf("preloaded chunk too small, expecting %d", chunkLen+crcLen)
 		}
 
 		r.mtx.Lock()
 		locked = true
 
 		r.stats.chunksFetchCount++
-		r.stats.chunksFetchDurationSum += time.Since(fetchBegin)
+		r.stats.chunksRefetches++
+		r.trackChunksFetchDuration(time.Since(fetchBegin))
 		r.stats.chunksFetchedSizeSum += len(*nb)
-		err = populateChunk(&(res[pIdx.seriesEntry].chks[pIdx.chunk]), rawChunk((*nb)[n:]), aggrs, r.save)
-		if err != nil {
-			r.block.chunkPool.Put(nb)
-			return errors.Wrap(err, "populate chunk")
-		}
-		r.stats.chunksTouched++
-		r.stats.chunksTouchedSizeSum += int(chunkDataLen)
 
+		// The chunk is copied by populateChunk(), so the refetched buffer can be returned to the pool right after.
+		err = r.checkChunkChecksum((*nb)[n:], seq, pIdx.offset)
+		if err == nil {
+			chk, err = r.toChunk(rawChunk((*nb)[n:chunkLen]), seq, pIdx.offset)
+		}
+		if err == nil {
+			err = errors.Wrap(populateChunk(&(res[pIdx.seriesEntry].chks[pIdx.chunk]), chk, aggrs, r.save), "populate chunk")
+		}
 		r.block.chunkPool.Put(nb)
+		if err != nil {
+			return err
+		}
+		r.trackChunkTouched(chk, int(chunkDataLen))
 	}
 	return nil
 }
 
-// save saves a copy of b's payload to a memory pool of its own and returns a new byte slice referencing said copy.
-// Returned slice becomes invalid once r.block.chunkPool.Put() is called.
+// trackChunkTouched tracks the chunk of the given data length in the reader stats, once it has been populated.
+func (r *bucketChunkReader) trackChunkTouched(chk chunkenc.Chunk, dataLen int) {
+	r.stats.chunksTouched++
+	r.stats.chunksTouchedSizeSum += dataLen
+
+	switch chk.Encoding() {
+	case encHistogram:
+		r.stats.histogramChunksTouched++
+	case encFloatHistogram:
+		r.stats.floatHistogramChunksTouched++
+	}
+}
+
+// touchedSegmentFiles returns the segment files, sorted by sequence number, which chunks have been read from.
+// It can be called once loading completed, even if it failed.
+func (r *bucketChunkReader) touchedSegmentFiles() []segmentFileRef {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	refs := make([]segmentFileRef, 0, len(r.touchedSeqs))
+	for seq := range r.touchedSeqs {
+		refs = append(refs, segmentFileRef{blockID: r.block.meta.ULID, seq: seq})
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].seq < refs[j].seq
+	})
+	return refs
+}
+
+// Encodings of the native histogram chunks, as defined by upstream Prometheus. The vendored TSDB
+// can't decode them, so they're served as they're read from the bucket.
+const (
+	encHistogram      = chunkenc.Encoding(2)
+	encFloatHistogram = chunkenc.Encoding(3)
+)
+
+// UnknownChunkEncodingError is returned when the encoding of a chunk read from the bucket isn't one
+// of the known encodings. It means the block is corrupted.
+type UnknownChunkEncodingError struct {
+	BlockID  ulid.ULID
+	Seq      int
+	Offset   uint32
+	Encoding chunkenc.Encoding
+}
+
+func (e UnknownChunkEncodingError) Error() string {
+	return fmt.Sprintf("unknown chunk encoding %d in block %s, segment file %d, offset %x", e.Encoding, e.BlockID, e.Seq, e.Offset)
+}
+
+// checkChunkEncoding returns an UnknownChunkEncodingError if the encoding of the chunk read from the
+// segment file seq at the given offset is not one of the known encodings: XOR, histogram and float histogram.
+func (r *bucketChunkReader) checkChunkEncoding(chk rawChunk, seq int, offset uint32) error {
+	switch chk.Encoding() {
+	case chunkenc.EncXOR, encHistogram, encFloatHistogram:
+		return nil
+	default:
+		return UnknownChunkEncodingError{BlockID: r.block.meta.ULID, Seq: seq, Offset: offset, Encoding: chk.Encoding()}
+	}
+}
+
+// checksumLen returns the number of bytes of the checksum following each chunk which are read
+// along with the chunk, which is 0 if the checksums are not validated.
+func (r *bucketChunkReader) checksumLen() int {
+	if r.block.chunkReaderCfg.validateChecksums {
+		return crc32.Size
+	}
+	return 0
+}
+
+// checkChunkChecksum returns a ChunkChecksumMismatchError if the chunk read from the segment file seq at the given
+// offset is corrupted, when the checksums are validated. The chunk includes its encoding and is followed by its checksum.
+func (r *bucketChunkReader) checkChunkChecksum(chk []byte, seq int, offset uint32) error {
+	if !r.block.chunkReaderCfg.validateChecksums {
+		return nil
+	}
+
+	data, sum := chk[:len(chk)-crc32.Size], chk[len(chk)-crc32.Size:]
+	expected, actual := binary.BigEndian.Uint32(sum), crc32.Checksum(data, castagnoliTable)
+	if expected == actual {
+		return nil
+	}
+
+	r.block.metrics.corruptedChunks.Inc()
+	level.Warn(r.block.logger).Log("msg", "corrupted chunk read from the bucket", "block", r.block.meta.ULID, "segment_file", seq, "offset", fmt.Sprintf("%x", offset))
+	return ChunkChecksumMismatchError{BlockID: r.block.meta.ULID, Seq: seq, Offset: offset, Expected: expected, Actual: actual}
+}
+
+// toChunk returns the chunk read from the segment file seq at the given offset, after checking its encoding.
+// If chunks decoding is enabled, the XOR chunk is decoded and all its samples are iterated to validate it,
+// otherwise the raw chunk is returned as is.
+func (r *bucketChunkReader) toChunk(raw rawChunk, seq int, offset uint32) (chunkenc.Chunk, error) {
+	if err := r.checkChunkEncoding(raw, seq, offset); err != nil {
+		return nil, err
+	}
+	if !r.decodeChunks {
+		return raw, nil
+	}
+	if raw.Encoding() != chunkenc.EncXOR {
+		// The native histogram chunks can't be decoded by the vendored TSDB.
+		return raw, nil
+	}
+
+	chk, err := chunkenc.FromData(raw.Encoding(), raw.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "decode chunk in block %s, segment file %d, offset %x", r.block.meta.ULID, seq, offset)
+	}
+	if chk.NumSamples() <= 0 {
+		return nil, errors.Errorf("empty chunk in block %s, segment file %d, offset %x", r.block.meta.ULID, seq, offset)
+	}
+
+	samples := 0
+	it := chk.Iterator(nil)
+	for it.Next() {
+		samples++
+	}
+	if err := it.Err(); err != nil {
+		return nil, errors.Wrapf(err, "iterate chunk in block %s, segment file %d, offset %x", r.block.meta.ULID, seq, offset)
+	}
+	if samples != chk.NumSamples() {
+		return nil, errors.Errorf("corrupted chunk in block %s, segment file %d, offset %x: expected %d samples, got %d", r.block.meta.ULID, seq, offset, chk.NumSamples(), samples)
+	}
+	return chk, nil
+}
+
+// save saves a copy of b's payload to a slab got from the slab pool and returns a new byte slice referencing said copy.
+// Returned slice becomes invalid once the reader is closed.
 func (r *bucketChunkReader) save(b []byte) ([]byte, error) {
 	// Ensure we never grow slab beyond original capacity.
-	if len(r.chunkBytes) == 0 ||
-		cap(*r.chunkBytes[len(r.chunkBytes)-1])-len(*r.chunkBytes[len(r.chunkBytes)-1]) < len(b) {
-		s, err := r.block.chunkPool.Get(len(b))
-		if err != nil {
-			return nil, errors.Wrap(err, "allocate chunk bytes")
-		}
-		r.chunkBytes = append(r.chunkBytes, s)
+	if len(r.chunkSlabs) == 0 ||
+		cap(*r.chunkSlabs[len(r.chunkSlabs)-1])-len(*r.chunkSlabs[len(r.chunkSlabs)-1]) < len(b) {
+		r.chunkSlabs = append(r.chunkSlabs, r.block.chunkReaderCfg.slabPool.get(len(b)))
 	}
-	slab := r.chunkBytes[len(r.chunkBytes)-1]
+	slab := r.chunkSlabs[len(r.chunkSlabs)-1]
 	*slab = append(*slab, b...)
 	return (*slab)[len(*slab)-len(b):], nil
 }
@@ -234,6 +902,12 @@ type loadIdx struct {
 	chunk       int
 }
 
+// duplicateLoadIdx is a chunk added to the reader multiple times. The chunk is loaded
+// into src, and then copied to dst.
+type duplicateLoadIdx struct {
+	src, dst loadIdx
+}
+
 // rawChunk is a helper type that wraps a chunk's raw bytes and implements the chunkenc.Chunk
 // interface over it.
 // It is used to Store API responses which don't need to introspect and validate the chunk's contents.
@@ -0,0 +1,1316 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"go.uber.org/atomic"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/metadata"
+	"github.com/grafana/mimir/pkg/storegateway/chunkscache"
+	"github.com/grafana/mimir/pkg/storegateway/storepb"
+	"github.com/grafana/mimir/pkg/util/gate"
+	"github.com/grafana/mimir/pkg/util/pool"
+)
+
+func TestBucketChunkReader_load(t *testing.T) {
+	const chunksDistance = 20000
+
+	// The test block partitioner has no max gap, so each chunk gets its own partition unless coalesced.
+	// Each partition spans EstimatedMaxChunkSize (16000) bytes from its chunk offset, so there are
+	// chunksDistance-EstimatedMaxChunkSize = 4000 unused bytes between the end of a partition and the
+	// start of the next one, except for the last partition, which starts 7*chunksDistance-EstimatedMaxChunkSize
+	// = 124000 bytes after the end of the previous one. Partitions are coalesced when separated by less than
+	// the merge gap, so only a merge gap greater than 4000 bytes coalesces the first four partitions, and only
+	// one greater than 124000 bytes coalesces the last one too.
+	offsets := []uint32{8, 8 + chunksDistance, 8 + 2*chunksDistance, 8 + 3*chunksDistance, 8 + 10*chunksDistance}
+
+	tests := map[string]struct {
+		mergeGapBytes      uint64
+		expectedRangeReads int
+	}{
+		"coalescing disabled": {
+			mergeGapBytes:      0,
+			expectedRangeReads: 5,
+		},
+		"merge gap smaller than the gap between partitions": {
+			mergeGapBytes:      1024,
+			expectedRangeReads: 5,
+		},
+		"merge gap coalescing adjacent partitions": {
+			mergeGapBytes:      32 * 1024,
+			expectedRangeReads: 2,
+		},
+		"merge gap coalescing all partitions": {
+			mergeGapBytes:      1024 * 1024,
+			expectedRangeReads: 1,
+		},
+	}
+
+	for testName, testData := range tests {
+		for _, readAhead := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s, read-ahead: %t", testName, readAhead), func(t *testing.T) {
+				chks := newTestXORChunks(t, len(offsets))
+				blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{mergeGapBytes: testData.mergeGapBytes, readAhead: readAhead})
+				reg := prometheus.NewPedanticRegistry()
+				blk.metrics = NewBucketStoreMetrics(reg)
+
+				r := blk.chunkReader(context.Background())
+				defer func() { assert.NoError(t, r.Close()) }()
+
+				loaded, err := loadTestChunks(t, r, offsets)
+				require.NoError(t, err)
+
+				assert.Equal(t, testData.expectedRangeReads, int(bkt.getRangeCalls.Load()))
+				assert.Zero(t, bkt.openReaders.Load())
+
+				// Each range read is tracked in the fetch duration histogram.
+				metrics, err := reg.Gather()
+				require.NoError(t, err)
+				fetchDuration := findMetricFamily(metrics, "cortex_bucket_store_chunk_fetch_duration_seconds")
+				require.NotNil(t, fetchDuration)
+				require.Len(t, fetchDuration.GetMetric(), 1)
+				assert.Equal(t, uint64(testData.expectedRangeReads), fetchDuration.GetMetric()[0].GetHistogram().GetSampleCount())
+				for i, chk := range chks {
+					require.NotNil(t, loaded[i].Raw)
+					assert.Equal(t, chk.Bytes(), loaded[i].Raw.Data)
+				}
+			})
+		}
+	}
+}
+
+func TestBucketChunkReader_load_ShouldSkipLargeGaps(t *testing.T) {
+	const chunksDistance = 20000
+
+	// All chunks are coalesced into a single partition. The gap between the first chunks is 4000 bytes
+	// (because the estimated chunk size is read for each chunk), while the gap before the last one is 124000 bytes.
+	offsets := []uint32{8, 8 + chunksDistance, 8 + 2*chunksDistance, 8 + 3*chunksDistance, 8 + 10*chunksDistance}
+	partitionSize := 10*chunksDistance + mimir_tsdb.EstimatedMaxChunkSize
+	smallGap := chunksDistance - mimir_tsdb.EstimatedMaxChunkSize
+	largeGap := 7*chunksDistance - mimir_tsdb.EstimatedMaxChunkSize
+
+	tests := map[string]struct {
+		maxDiscardRatio    float64
+		maxDiscardBytes    uint64
+		expectedRangeReads int
+		expectedSkipped    int
+		expectedFetched    int
+	}{
+		"skipping gaps disabled": {
+			maxDiscardRatio:    0,
+			expectedRangeReads: 1,
+			expectedSkipped:    3*smallGap + largeGap,
+			expectedFetched:    partitionSize,
+		},
+		"max discard ratio smaller than the large gap only": {
+			maxDiscardRatio:    0.5,
+			expectedRangeReads: 2,
+			expectedSkipped:    3 * smallGap,
+			expectedFetched:    partitionSize - largeGap,
+		},
+		"max discard ratio smaller than all gaps": {
+			maxDiscardRatio:    0.01,
+			expectedRangeReads: 5,
+			expectedSkipped:    0,
+			expectedFetched:    partitionSize - 3*smallGap - largeGap,
+		},
+		"max discard bytes smaller than the large gap only": {
+			maxDiscardBytes:    uint64(largeGap - 1),
+			expectedRangeReads: 2,
+			expectedSkipped:    3 * smallGap,
+			expectedFetched:    partitionSize - largeGap,
+		},
+		"max discard bytes equal to the large gap": {
+			maxDiscardBytes:    uint64(largeGap),
+			expectedRangeReads: 1,
+			expectedSkipped:    3*smallGap + largeGap,
+			expectedFetched:    partitionSize,
+		},
+		"max discard bytes smaller than all gaps": {
+			maxDiscardBytes:    uint64(smallGap - 1),
+			expectedRangeReads: 5,
+			expectedSkipped:    0,
+			expectedFetched:    partitionSize - 3*smallGap - largeGap,
+		},
+		"max discard bytes smaller than the large gap and max discard ratio smaller than all gaps": {
+			maxDiscardRatio:    0.01,
+			maxDiscardBytes:    uint64(largeGap - 1),
+			expectedRangeReads: 5,
+			expectedSkipped:    0,
+			expectedFetched:    partitionSize - 3*smallGap - largeGap,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			chks := newTestXORChunks(t, len(offsets))
+			blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{mergeGapBytes: 1024 * 1024, maxDiscardRatio: testData.maxDiscardRatio, maxDiscardBytes: testData.maxDiscardBytes})
+
+			r := blk.chunkReader(context.Background())
+			defer func() { assert.NoError(t, r.Close()) }()
+
+			loaded, err := loadTestChunks(t, r, offsets)
+			require.NoError(t, err)
+
+			assert.Equal(t, testData.expectedRangeReads, int(bkt.getRangeCalls.Load()))
+			assert.Equal(t, testData.expectedRangeReads, r.stats.chunksFetchCount)
+			assert.Equal(t, testData.expectedRangeReads-1, r.stats.chunksRangeReseeks)
+			assert.Equal(t, testData.expectedSkipped, r.stats.chunksSkippedBytes)
+			assert.Equal(t, testData.expectedFetched, r.stats.chunksFetchedSizeSum)
+			for i, chk := range chks {
+				require.NotNil(t, loaded[i].Raw)
+				assert.Equal(t, chk.Bytes(), loaded[i].Raw.Data)
+			}
+		})
+	}
+}
+
+func TestBucketChunkReader_load_ShouldReadDuplicatedChunksOnce(t *testing.T) {
+	// Chunks are far enough from each other to be read with a range read each.
+	offsets := []uint32{8, 20000, 40000}
+	chks := newTestXORChunks(t, len(offsets))
+	blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{})
+
+	r := blk.chunkReader(context.Background())
+	defer func() { assert.NoError(t, r.Close()) }()
+
+	// The first series references all chunks, the second one references the last two chunks,
+	// and the third one references the second chunk twice.
+	refs := [][]int{{0, 1, 2}, {1, 2}, {1, 1}}
+
+	res := make([]seriesEntry, len(refs))
+	for seriesEntry, chunkIdxs := range refs {
+		res[seriesEntry].chks = make([]storepb.AggrChunk, len(chunkIdxs))
+		for i, chunkIdx := range chunkIdxs {
+			require.NoError(t, r.addLoad(chunks.ChunkRef(offsets[chunkIdx]), seriesEntry, i))
+		}
+	}
+	require.NoError(t, r.load(res, nil))
+
+	for seriesEntry, chunkIdxs := range refs {
+		for i, chunkIdx := range chunkIdxs {
+			require.NotNil(t, res[seriesEntry].chks[i].Raw)
+			assert.Equal(t, storepb.Chunk_XOR, res[seriesEntry].chks[i].Raw.Type)
+			assert.Equal(t, chks[chunkIdx].Bytes(), res[seriesEntry].chks[i].Raw.Data)
+		}
+	}
+
+	assert.Equal(t, len(offsets), int(bkt.getRangeCalls.Load()))
+	assert.Equal(t, len(offsets), r.stats.chunksFetched)
+	assert.Equal(t, len(offsets), r.stats.chunksTouched)
+	assert.Equal(t, 4, r.stats.chunksDeduped)
+}
+
+func TestBucketChunkReader_load_ShouldTrackEstimatedChunksSize(t *testing.T) {
+	offsets := []uint32{8, 20000, 40000}
+	chks := newTestXORChunks(t, len(offsets))
+	blk, _ := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{})
+
+	r := blk.chunkReader(context.Background())
+	defer func() { assert.NoError(t, r.Close()) }()
+
+	_, err := loadTestChunks(t, r, offsets)
+	require.NoError(t, err)
+
+	assert.Equal(t, len(offsets)*mimir_tsdb.EstimatedMaxChunkSize, r.stats.chunksEstimatedSizeSum)
+	assert.Equal(t, len(offsets)*mimir_tsdb.EstimatedMaxChunkSize, r.stats.chunksFetchedSizeSum)
+	assert.Equal(t, 1.0, r.stats.chunksFetchedEstimateRatio())
+}
+
+func TestBucketChunkReader_load_ShouldUseCustomPartitioner(t *testing.T) {
+	const alignment = 16 * 1024
+
+	offsets := []uint32{8, 20000, 40000}
+	chks := newTestXORChunks(t, len(offsets))
+	blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{
+		partitioner: alignedPartitioner{Partitioner: newGapBasedPartitioner(0, nil), alignment: alignment},
+	})
+
+	r := blk.chunkReader(context.Background())
+	defer func() { assert.NoError(t, r.Close()) }()
+
+	loaded, err := loadTestChunks(t, r, offsets)
+	require.NoError(t, err)
+
+	for i, chk := range chks {
+		require.NotNil(t, loaded[i].Raw)
+		assert.Equal(t, chk.Bytes(), loaded[i].Raw.Data)
+	}
+
+	// Each chunk gets its own partition, expanded to the alignment boundaries.
+	sort.Slice(bkt.rangeReads, func(i, j int) bool {
+		return bkt.rangeReads[i][0] < bkt.rangeReads[j][0]
+	})
+	assert.Equal(t, [][2]int64{
+		{0, alignment},
+		{alignment, 3 * alignment},
+		{2 * alignment, 4 * alignment},
+	}, bkt.rangeReads)
+}
+
+func TestDedupLoadIdxs(t *testing.T) {
+	input := []loadIdx{
+		{offset: 8, seriesEntry: 0, chunk: 0},
+		{offset: 8, seriesEntry: 1, chunk: 0},
+		{offset: 100, seriesEntry: 0, chunk: 1},
+		{offset: 200, seriesEntry: 1, chunk: 1},
+		{offset: 200, seriesEntry: 2, chunk: 0},
+		{offset: 200, seriesEntry: 3, chunk: 0},
+	}
+
+	unique, duplicates := dedupLoadIdxs(input)
+	assert.Equal(t, []loadIdx{
+		{offset: 8, seriesEntry: 0, chunk: 0},
+		{offset: 100, seriesEntry: 0, chunk: 1},
+		{offset: 200, seriesEntry: 1, chunk: 1},
+	}, unique)
+	assert.Equal(t, []duplicateLoadIdx{
+		{src: loadIdx{offset: 8, seriesEntry: 0, chunk: 0}, dst: loadIdx{offset: 8, seriesEntry: 1, chunk: 0}},
+		{src: loadIdx{offset: 200, seriesEntry: 1, chunk: 1}, dst: loadIdx{offset: 200, seriesEntry: 2, chunk: 0}},
+		{src: loadIdx{offset: 200, seriesEntry: 1, chunk: 1}, dst: loadIdx{offset: 200, seriesEntry: 3, chunk: 0}},
+	}, duplicates)
+}
+
+func TestBucketChunkReader_load_ShouldRespectChunksFetchGate(t *testing.T) {
+	const chunksDistance = 20000
+
+	// The test block partitioner has no max gap, so each chunk gets its own partition.
+	offsets := []uint32{8, 8 + chunksDistance, 8 + 2*chunksDistance, 8 + 3*chunksDistance, 8 + 4*chunksDistance}
+	chks := newTestXORChunks(t, len(offsets))
+
+	t.Run("should limit the number of concurrent range reads", func(t *testing.T) {
+		for _, readAhead := range []bool{false, true} {
+			t.Run(fmt.Sprintf("read-ahead: %t", readAhead), func(t *testing.T) {
+				fetchGate := gate.NewBlocking(1)
+				blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{fetchGate: fetchGate, readAhead: readAhead})
+
+				r := blk.chunkReader(context.Background())
+				defer func() { assert.NoError(t, r.Close()) }()
+
+				loaded, err := loadTestChunks(t, r, offsets)
+				require.NoError(t, err)
+
+				assert.Equal(t, len(offsets), int(bkt.getRangeCalls.Load()))
+				assert.Equal(t, 1, int(bkt.maxOpenReaders.Load()))
+				assert.Zero(t, bkt.openReaders.Load())
+				for i, chk := range chks {
+					require.NotNil(t, loaded[i].Raw)
+					assert.Equal(t, chk.Bytes(), loaded[i].Raw.Data)
+				}
+
+				// All slots have been released.
+				require.NoError(t, fetchGate.Start(context.Background()))
+				fetchGate.Done()
+			})
+		}
+	})
+
+	t.Run("should stop waiting for the gate once the context is canceled", func(t *testing.T) {
+		fetchGate := gate.NewBlocking(1)
+		blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{fetchGate: fetchGate})
+
+		// Take the only slot available.
+		require.NoError(t, fetchGate.Start(context.Background()))
+		defer fetchGate.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		r := blk.chunkReader(ctx)
+		defer func() { assert.NoError(t, r.Close()) }()
+
+		_, err := loadTestChunks(t, r, offsets)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Zero(t, bkt.getRangeCalls.Load())
+	})
+}
+
+func TestBucketChunkReader_load_ShouldRespectQueryFetchLimits(t *testing.T) {
+	const chunksDistance = 20000
+
+	// The test block partitioner has no max gap, so each chunk gets its own partition.
+	offsets := []uint32{8, 8 + chunksDistance, 8 + 2*chunksDistance, 8 + 3*chunksDistance, 8 + 4*chunksDistance}
+	chks := newTestXORChunks(t, len(offsets))
+
+	t.Run("should limit the number of concurrent range reads of the query", func(t *testing.T) {
+		for _, readAhead := range []bool{false, true} {
+			t.Run(fmt.Sprintf("read-ahead: %t", readAhead), func(t *testing.T) {
+				cfg := chunkReaderConfig{readAhead: readAhead, maxConcurrentFetchesPerQuery: 1}
+				blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, cfg)
+				limits := newQueryFetchLimits(cfg, prometheus.NewCounter(prometheus.CounterOpts{}))
+
+				// The readers of the same query share the limits.
+				g := errgroup.Group{}
+				for i := 0; i < 2; i++ {
+					r := blk.chunkReader(context.Background())
+					r.setFetchLimits(limits)
+					defer func() { assert.NoError(t, r.Close()) }()
+
+					g.Go(func() error {
+						_, err := loadTestChunks(t, r, offsets)
+						return err
+					})
+				}
+				require.NoError(t, g.Wait())
+
+				assert.Equal(t, 2*len(offsets), int(bkt.getRangeCalls.Load()))
+				assert.Equal(t, 1, int(bkt.maxOpenReaders.Load()))
+				assert.Zero(t, bkt.openReaders.Load())
+			})
+		}
+	})
+
+	t.Run("should fail once the query exceeds the max fetched chunk bytes", func(t *testing.T) {
+		cfg := chunkReaderConfig{maxConcurrentFetchesPerQuery: 1, maxFetchedBytesPerQuery: uint64(len(offsets) * mimir_tsdb.EstimatedMaxChunkSize)}
+		blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, cfg)
+		failed := prometheus.NewCounter(prometheus.CounterOpts{})
+		limits := newQueryFetchLimits(cfg, failed)
+
+		r1 := blk.chunkReader(context.Background())
+		r1.setFetchLimits(limits)
+		defer func() { assert.NoError(t, r1.Close()) }()
+
+		_, err := loadTestChunks(t, r1, offsets)
+		require.NoError(t, err)
+		require.Equal(t, len(offsets), int(bkt.getRangeCalls.Load()))
+
+		// The chunks of the query loaded by another reader exceed the limit.
+		r2 := blk.chunkReader(context.Background())
+		r2.setFetchLimits(limits)
+		defer func() { assert.NoError(t, r2.Close()) }()
+
+		_, err = loadTestChunks(t, r2, offsets)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeded chunk bytes limit")
+		assert.Equal(t, codes.Code(http.StatusUnprocessableEntity), errorCode(err, codes.Unknown))
+		assert.Equal(t, len(offsets), int(bkt.getRangeCalls.Load()))
+		assert.Equal(t, float64(1), promtest.ToFloat64(failed))
+	})
+}
+
+func TestBucketChunkReader_load_ShouldFailOnUnknownChunkEncoding(t *testing.T) {
+	offsets := []uint32{8, 1000, 2000}
+	chks := newTestXORChunks(t, len(offsets))
+
+	// Corrupt the encoding of the second chunk.
+	chks[1] = rawChunk(append([]byte{0xff}, chks[1].Bytes()...))
+
+	blk, _ := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{})
+	r := blk.chunkReader(context.Background())
+	defer func() { assert.NoError(t, r.Close()) }()
+
+	_, err := loadTestChunks(t, r, offsets)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown chunk encoding 255 in block "+blk.meta.ULID.String()+", segment file 0, offset 3e8")
+}
+
+func TestBucketChunkReader_load_ShouldValidateChunkChecksumsIfEnabled(t *testing.T) {
+	offsets := []uint32{8, 1000, 2000}
+
+	for name, partitioner := range map[string]Partitioner{
+		// All chunks and their checksums are read with a single range read.
+		"chunks read with the partition": nil,
+		// Each chunk is refetched, because the partitions are smaller than the chunks.
+		"chunks refetched": truncatingPartitioner{maxLength: 16},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Run("should load valid chunks", func(t *testing.T) {
+				chks := newTestXORChunks(t, len(offsets))
+				blk, _ := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{validateChecksums: true, partitioner: partitioner})
+
+				r := blk.chunkReader(context.Background())
+				defer func() { assert.NoError(t, r.Close()) }()
+
+				loaded, err := loadTestChunks(t, r, offsets)
+				require.NoError(t, err)
+				for i, chk := range chks {
+					require.NotNil(t, loaded[i].Raw)
+					assert.Equal(t, chk.Bytes(), loaded[i].Raw.Data)
+				}
+				assert.Zero(t, promtest.ToFloat64(blk.metrics.corruptedChunks))
+			})
+
+			t.Run("should fail on a corrupted chunk", func(t *testing.T) {
+				chks := newTestXORChunks(t, len(offsets))
+				blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{validateChecksums: true, partitioner: partitioner})
+
+				// Corrupt the data of the second chunk, after its length and encoding.
+				segment, err := bkt.Get(context.Background(), blk.chunkObjs[0])
+				require.NoError(t, err)
+				data, err := io.ReadAll(segment)
+				require.NoError(t, err)
+				data[offsets[1]+4] ^= 0xff
+				require.NoError(t, bkt.Upload(context.Background(), blk.chunkObjs[0], bytes.NewReader(data)))
+
+				r := blk.chunkReader(context.Background())
+				defer func() { assert.NoError(t, r.Close()) }()
+
+				_, err = loadTestChunks(t, r, offsets)
+				var checksumErr ChunkChecksumMismatchError
+				require.ErrorAs(t, err, &checksumErr)
+				assert.Equal(t, blk.meta.ULID, checksumErr.BlockID)
+				assert.Equal(t, 0, checksumErr.Seq)
+				assert.Equal(t, offsets[1], checksumErr.Offset)
+				assert.Equal(t, float64(1), promtest.ToFloat64(blk.metrics.corruptedChunks))
+			})
+		})
+	}
+
+	t.Run("should not validate the checksums if disabled", func(t *testing.T) {
+		chks := newTestXORChunks(t, len(offsets))
+		blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{})
+
+		// Corrupt the data of the second chunk, after its length and encoding.
+		segment, err := bkt.Get(context.Background(), blk.chunkObjs[0])
+		require.NoError(t, err)
+		data, err := io.ReadAll(segment)
+		require.NoError(t, err)
+		data[offsets[1]+4] ^= 0xff
+		require.NoError(t, bkt.Upload(context.Background(), blk.chunkObjs[0], bytes.NewReader(data)))
+
+		r := blk.chunkReader(context.Background())
+		defer func() { assert.NoError(t, r.Close()) }()
+
+		_, err = loadTestChunks(t, r, offsets)
+		require.NoError(t, err)
+		assert.Zero(t, promtest.ToFloat64(blk.metrics.corruptedChunks))
+	})
+}
+
+func TestBucketChunkReader_load_ShouldOnlyServeSupportedChunkEncodings(t *testing.T) {
+	tests := map[string]struct {
+		encoding      chunkenc.Encoding
+		expectUnknown bool
+		expectedType  storepb.Chunk_Encoding
+
+		expectedHistograms, expectedFloatHistograms int
+	}{
+		"XOR": {
+			encoding:     chunkenc.EncXOR,
+			expectedType: storepb.Chunk_XOR,
+		},
+		"none": {
+			encoding:      chunkenc.EncNone,
+			expectUnknown: true,
+		},
+		"out-of-order XOR": {
+			encoding:      chunkenc.EncOOOXOR,
+			expectUnknown: true,
+		},
+		"histogram": {
+			encoding:           encHistogram,
+			expectedType:       storepb.Chunk_Histogram,
+			expectedHistograms: 1,
+		},
+		"float histogram": {
+			encoding:                encFloatHistogram,
+			expectedType:            storepb.Chunk_FloatHistogram,
+			expectedFloatHistograms: 1,
+		},
+	}
+
+	for testName, testData := range tests {
+		// Test both the chunks read from the partition and the ones larger than the
+		// estimated max chunk size, which are refetched with a dedicated range read.
+		for _, chunkSize := range []int{16, 2 * mimir_tsdb.EstimatedMaxChunkSize} {
+			t.Run(fmt.Sprintf("%s, chunk size: %d", testName, chunkSize), func(t *testing.T) {
+				offsets := []uint32{8, 1000, 1000 + 3*mimir_tsdb.EstimatedMaxChunkSize}
+				chks := newTestXORChunks(t, len(offsets))
+
+				data := make([]byte, chunkSize)
+				for i := range data {
+					data[i] = byte(i)
+				}
+				chks[1] = rawChunk(append([]byte{byte(testData.encoding)}, data...))
+
+				blk, _ := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{mergeGapBytes: mimir_tsdb.DefaultPartitionerMaxGapSize})
+				r := blk.chunkReader(context.Background())
+				defer func() { assert.NoError(t, r.Close()) }()
+
+				loaded, err := loadTestChunks(t, r, offsets)
+				if testData.expectUnknown {
+					var encErr UnknownChunkEncodingError
+					require.ErrorAs(t, err, &encErr)
+					assert.Equal(t, UnknownChunkEncodingError{BlockID: blk.meta.ULID, Seq: 0, Offset: 1000, Encoding: testData.encoding}, encErr)
+					return
+				}
+
+				// The chunks are returned byte-identical, with the Store API type matching their encoding.
+				require.NoError(t, err)
+				assert.Equal(t, len(chks), r.stats.chunksTouched)
+				assert.Equal(t, testData.expectedHistograms, r.stats.histogramChunksTouched)
+				assert.Equal(t, testData.expectedFloatHistograms, r.stats.floatHistogramChunksTouched)
+				for i, chk := range chks {
+					expectedType := storepb.Chunk_XOR
+					if i == 1 {
+						expectedType = testData.expectedType
+					}
+					require.NotNil(t, loaded[i].Raw)
+					assert.Equal(t, expectedType, loaded[i].Raw.Type)
+					assert.Equal(t, chk.Bytes(), loaded[i].Raw.Data)
+				}
+			})
+		}
+	}
+}
+
+func TestBucketChunkReader_load_ShouldDecodeChunksIfEnabled(t *testing.T) {
+	offsets := []uint32{8, 1000}
+	valid := newTestXORChunks(t, 2)[1]
+
+	// The truncated chunk keeps the original number of samples in its header, but misses part of them.
+	truncated := rawChunk(append([]byte{byte(chunkenc.EncXOR)}, valid.Bytes()[:len(valid.Bytes())/2]...))
+
+	tests := map[string]struct {
+		chk         chunkenc.Chunk
+		decode      bool
+		expectedErr string
+	}{
+		"valid chunk, decoding disabled": {
+			chk: valid,
+		},
+		"valid chunk, decoding enabled": {
+			chk:    valid,
+			decode: true,
+		},
+		"empty chunk, decoding disabled": {
+			chk: chunkenc.NewXORChunk(),
+		},
+		"empty chunk, decoding enabled": {
+			chk:         chunkenc.NewXORChunk(),
+			decode:      true,
+			expectedErr: "empty chunk",
+		},
+		"truncated chunk, decoding disabled": {
+			chk: truncated,
+		},
+		"truncated chunk, decoding enabled": {
+			chk:         truncated,
+			decode:      true,
+			expectedErr: "iterate chunk",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			chks := []chunkenc.Chunk{newTestXORChunks(t, 1)[0], testData.chk}
+			blk, _ := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{})
+
+			r := blk.chunkReader(context.Background())
+			defer func() { assert.NoError(t, r.Close()) }()
+			if testData.decode {
+				r.enableChunksDecoding()
+			}
+
+			loaded, err := loadTestChunks(t, r, offsets)
+			if testData.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			for i, chk := range chks {
+				require.NotNil(t, loaded[i].Raw)
+				assert.Equal(t, chk.Bytes(), loaded[i].Raw.Data)
+			}
+		})
+	}
+}
+
+func TestBucketChunkReader_load_ShouldReturnPoolBuffersOnError(t *testing.T) {
+	offsets := []uint32{8, 1000, 1000 + 3*mimir_tsdb.EstimatedMaxChunkSize}
+
+	// The second chunk is larger than the estimated max chunk size, so it gets refetched with a second range read.
+	chks := newTestXORChunks(t, len(offsets))
+	chks[1] = rawChunk(append([]byte{byte(chunkenc.EncXOR)}, make([]byte, 2*mimir_tsdb.EstimatedMaxChunkSize)...))
+
+	for _, failingRangeRead := range []int32{1, 2} {
+		for _, readAhead := range []bool{false, true} {
+			t.Run(fmt.Sprintf("failing range read: %d, read-ahead: %t", failingRangeRead, readAhead), func(t *testing.T) {
+				chunkPool := &mockedPool{parent: pool.NoopBytes{}}
+
+				blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{mergeGapBytes: mimir_tsdb.DefaultPartitionerMaxGapSize, readAhead: readAhead})
+				blk.chunkPool = chunkPool
+				bkt.failingRangeRead = failingRangeRead
+
+				r := blk.chunkReader(context.Background())
+				_, err := loadTestChunks(t, r, offsets)
+				require.ErrorIs(t, err, errRangeReadFailure)
+
+				// Close is idempotent.
+				require.NoError(t, r.Close())
+				require.NoError(t, r.Close())
+
+				assert.Equal(t, chunkPool.gets.Load(), chunkPool.puts.Load())
+				assert.Zero(t, chunkPool.balance.Load())
+				assert.Zero(t, bkt.openReaders.Load())
+			})
+		}
+	}
+}
+
+func TestBucketChunkReader_load_ShouldFailOnRangeReadTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	offsets := []uint32{8, 1000, 1000 + 3*mimir_tsdb.EstimatedMaxChunkSize}
+
+	// The second chunk is larger than the estimated max chunk size, so it gets refetched with a second range read.
+	chks := newTestXORChunks(t, len(offsets))
+	chks[1] = rawChunk(append([]byte{byte(chunkenc.EncXOR)}, make([]byte, 2*mimir_tsdb.EstimatedMaxChunkSize)...))
+
+	for _, stuckRangeRead := range []int32{1, 2} {
+		for _, readAhead := range []bool{false, true} {
+			t.Run(fmt.Sprintf("stuck range read: %d, read-ahead: %t", stuckRangeRead, readAhead), func(t *testing.T) {
+				chunkPool := &mockedPool{parent: pool.NoopBytes{}}
+
+				blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{mergeGapBytes: mimir_tsdb.DefaultPartitionerMaxGapSize, readAhead: readAhead, fetchTimeout: timeout})
+				blk.chunkPool = chunkPool
+				bkt.stuckRangeRead = stuckRangeRead
+
+				r := blk.chunkReader(context.Background())
+				_, err := loadTestChunks(t, r, offsets)
+				require.Error(t, err)
+
+				var timeoutErr chunksFetchTimeoutError
+				require.True(t, errors.As(err, &timeoutErr), "unexpected error: %v", err)
+				assert.Equal(t, timeout, timeoutErr.timeout)
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+				require.NoError(t, r.Close())
+
+				assert.Equal(t, chunkPool.gets.Load(), chunkPool.puts.Load())
+				assert.Zero(t, chunkPool.balance.Load())
+				assert.Zero(t, bkt.openReaders.Load())
+			})
+		}
+	}
+
+	t.Run("should not return a timeout error if the query is canceled", func(t *testing.T) {
+		blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{fetchTimeout: time.Minute})
+		bkt.stuckRangeRead = 1
+
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		r := blk.chunkReader(ctx)
+		defer func() { assert.NoError(t, r.Close()) }()
+
+		_, err := loadTestChunks(t, r, offsets)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		var timeoutErr chunksFetchTimeoutError
+		assert.False(t, errors.As(err, &timeoutErr))
+	})
+}
+
+func TestBucketChunkReader_addLoad_ShouldFailOnChunkRefOutOfRange(t *testing.T) {
+	offsets := []uint32{8}
+	blk, _ := prepareChunkReaderTestBlock(t, offsets, newTestXORChunks(t, len(offsets)), chunkReaderConfig{})
+
+	r := blk.chunkReader(context.Background())
+	defer func() { assert.NoError(t, r.Close()) }()
+
+	// The block has a single segment file, so the chunk reference to the second one is out of range.
+	err := r.addLoad(chunks.ChunkRef(uint64(1)<<32|8), 0, 0)
+	require.Error(t, err)
+
+	var refErr ChunkRefOutOfRangeError
+	require.True(t, errors.As(errors.Wrap(err, "add chunk load"), &refErr))
+	assert.Equal(t, ChunkRefOutOfRangeError{BlockID: blk.meta.ULID, Seq: 1, SegmentFiles: 1}, refErr)
+	assert.Equal(t, fmt.Sprintf("reference sequence 1 out of range [0, 1) for block %s", blk.meta.ULID), err.Error())
+}
+
+func TestBucketChunkReader_touchedSegmentFiles(t *testing.T) {
+	offsets := []uint32{8, 1000}
+	blk, bkt := prepareChunkReaderTestBlock(t, offsets, newTestXORChunks(t, len(offsets)), chunkReaderConfig{})
+
+	// Add two more segment files to the block, with the same content of the first one.
+	for _, name := range []string{"000002", "000003"} {
+		segment, err := bkt.Get(context.Background(), blk.chunkObjs[0])
+		require.NoError(t, err)
+		segmentName := path.Join(blk.meta.ULID.String(), "chunks", name)
+		require.NoError(t, bkt.Upload(context.Background(), segmentName, segment))
+		blk.chunkObjs = append(blk.chunkObjs, segmentName)
+	}
+
+	loadFromSegmentFiles := func(r *bucketChunkReader, seqs ...int) error {
+		res := []seriesEntry{{chks: make([]storepb.AggrChunk, len(seqs)*len(offsets))}}
+		for i, seq := range seqs {
+			for j, offset := range offsets {
+				err := r.addLoad(chunks.ChunkRef(uint64(seq)<<32|uint64(offset)), 0, i*len(offsets)+j)
+				require.NoError(t, err)
+			}
+		}
+		return r.load(res, nil)
+	}
+
+	t.Run("no chunks loaded", func(t *testing.T) {
+		r := blk.chunkReader(context.Background())
+		defer func() { assert.NoError(t, r.Close()) }()
+
+		require.NoError(t, r.load(nil, nil))
+		assert.Empty(t, r.touchedSegmentFiles())
+	})
+
+	t.Run("chunks loaded from some segment files", func(t *testing.T) {
+		r := blk.chunkReader(context.Background())
+		defer func() { assert.NoError(t, r.Close()) }()
+
+		require.NoError(t, loadFromSegmentFiles(r, 2, 0))
+		assert.Equal(t, []segmentFileRef{{blockID: blk.meta.ULID, seq: 0}, {blockID: blk.meta.ULID, seq: 2}}, r.touchedSegmentFiles())
+	})
+
+	t.Run("loading chunks failed", func(t *testing.T) {
+		bkt.getRangeCalls.Store(0)
+		bkt.failingRangeRead = 1
+		t.Cleanup(func() { bkt.failingRangeRead = 0 })
+
+		r := blk.chunkReader(context.Background())
+		defer func() { assert.NoError(t, r.Close()) }()
+
+		require.ErrorIs(t, loadFromSegmentFiles(r, 1), errRangeReadFailure)
+		assert.Equal(t, []segmentFileRef{{blockID: blk.meta.ULID, seq: 1}}, r.touchedSegmentFiles())
+	})
+}
+
+func TestBucketChunkReader_load_ShouldEstimateChunkLengthFromChunksRead(t *testing.T) {
+	const chunksDistance = 20000
+
+	// All chunks are small, except the last one which is longer than the estimate based on the other ones.
+	offsets := make([]uint32, 0, chunkLengthEstimatorMinObservations)
+	for i := 0; i < cap(offsets); i++ {
+		offsets = append(offsets, uint32(8+i*chunksDistance))
+	}
+	chks := newTestXORChunks(t, len(offsets)-1)
+	largeChk := chunkenc.NewXORChunk()
+	app, err := largeChk.Appender()
+	require.NoError(t, err)
+	rnd := rand.New(rand.NewSource(1))
+	for ts := int64(0); ts < 200; ts++ {
+		app.Append(ts*1000+rnd.Int63n(1000), rnd.Float64())
+	}
+	require.Greater(t, len(largeChk.Bytes()), 256)
+	chks = append(chks, largeChk)
+
+	blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{adaptiveChunkLength: true})
+
+	load := func() *queryStats {
+		r := blk.chunkReader(context.Background())
+		defer func() { assert.NoError(t, r.Close()) }()
+
+		loaded, err := loadTestChunks(t, r, offsets)
+		require.NoError(t, err)
+		for i, chk := range chks {
+			require.NotNil(t, loaded[i].Raw)
+			assert.Equal(t, chk.Bytes(), loaded[i].Raw.Data)
+		}
+		return r.stats
+	}
+
+	// Without enough chunks read, their length is estimated to be the max chunk size.
+	stats := load()
+	assert.Equal(t, len(offsets)*mimir_tsdb.EstimatedMaxChunkSize, stats.chunksEstimatedSizeSum)
+	assert.Zero(t, stats.chunksRefetches)
+	assert.Equal(t, 256, blk.estimatedChunkLength(0))
+	assert.Equal(t, mimir_tsdb.EstimatedMaxChunkSize, blk.estimatedChunkLength(1))
+
+	// Then their length is estimated from the chunks read, and the longer chunk is refetched.
+	bkt.getRangeCalls.Store(0)
+	stats = load()
+	assert.Equal(t, len(offsets)*256, stats.chunksEstimatedSizeSum)
+	assert.Equal(t, 1, stats.chunksRefetches)
+	assert.Equal(t, len(offsets)+1, int(bkt.getRangeCalls.Load()))
+}
+
+func TestBucketChunkReader_load_ShouldCacheChunkRanges(t *testing.T) {
+	// Chunks are far enough from each other to be read with a range read each.
+	offsets := []uint32{8, 20000, 40000}
+	chks := newTestXORChunks(t, len(offsets))
+
+	backend, err := chunkscache.NewInMemoryCache("test", 1024*1024, nil)
+	require.NoError(t, err)
+	reg := prometheus.NewPedanticRegistry()
+	cache := chunkscache.NewChunkRangesCache(log.NewNopLogger(), backend, time.Hour, mimir_tsdb.EstimatedMaxChunkSize, reg)
+
+	blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{cache: cache})
+	blk.userID = "user-1"
+
+	load := func() {
+		r := blk.chunkReader(context.Background())
+		defer func() { assert.NoError(t, r.Close()) }()
+
+		loaded, err := loadTestChunks(t, r, offsets)
+		require.NoError(t, err)
+		for i, chk := range chks {
+			require.NotNil(t, loaded[i].Raw)
+			assert.Equal(t, chk.Bytes(), loaded[i].Raw.Data)
+		}
+	}
+
+	// The chunk ranges are read from the bucket the first time, and from the cache afterwards.
+	load()
+	assert.Equal(t, len(offsets), int(bkt.getRangeCalls.Load()))
+	load()
+	assert.Equal(t, len(offsets), int(bkt.getRangeCalls.Load()))
+
+	metrics, err := reg.Gather()
+	require.NoError(t, err)
+	hits := findMetricFamily(metrics, "cortex_bucket_store_chunk_ranges_cache_hits_total")
+	require.NotNil(t, hits)
+	assert.Equal(t, float64(len(offsets)), hits.GetMetric()[0].GetCounter().GetValue())
+
+	// The chunk ranges larger than the max item size are not cached.
+	cache = chunkscache.NewChunkRangesCache(log.NewNopLogger(), backend, time.Hour, mimir_tsdb.EstimatedMaxChunkSize-1, nil)
+	blk, bkt = prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{cache: cache})
+	load()
+	load()
+	assert.Equal(t, 2*len(offsets), int(bkt.getRangeCalls.Load()))
+}
+
+func TestBucketChunkReader_load_ShouldBatchRangeReadsOfContiguousSegmentFiles(t *testing.T) {
+	const chunksDistance = 20000
+
+	// The test block partitioner has no max gap, so each chunk gets its own partition, reading EstimatedMaxChunkSize bytes.
+	offsets := []uint32{8, 8 + chunksDistance}
+	chks := newTestXORChunks(t, len(offsets))
+	const segmentReadSize = 2 * mimir_tsdb.EstimatedMaxChunkSize
+
+	for name, test := range map[string]struct {
+		batchMaxBytes          uint64
+		seqs                   []int
+		expectedBatchedFetches int
+		expectedMaxOpenReaders int
+	}{
+		"should batch the range reads of contiguous segment files": {
+			batchMaxBytes:          4 * segmentReadSize,
+			seqs:                   []int{0, 1, 2, 3},
+			expectedBatchedFetches: 4*len(offsets) - 1,
+			expectedMaxOpenReaders: 1,
+		},
+		"should not batch the range reads of segment files which are not contiguous": {
+			batchMaxBytes:          4 * segmentReadSize,
+			seqs:                   []int{0, 1, 3},
+			expectedBatchedFetches: (2*len(offsets) - 1) + (len(offsets) - 1),
+			expectedMaxOpenReaders: 2,
+		},
+		"should split the batches exceeding the max size": {
+			batchMaxBytes:          2 * segmentReadSize,
+			seqs:                   []int{0, 1, 2, 3},
+			expectedBatchedFetches: 2 * (2*len(offsets) - 1),
+			expectedMaxOpenReaders: 2,
+		},
+		"should not batch the range reads of segment files exceeding the max size": {
+			batchMaxBytes:          segmentReadSize - 1,
+			seqs:                   []int{0, 1, 2, 3},
+			expectedBatchedFetches: 0,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			blk, bkt := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{batchMaxBytes: test.batchMaxBytes})
+
+			// Add three more segment files to the block, with the same content of the first one.
+			for _, name := range []string{"000002", "000003", "000004"} {
+				segment, err := bkt.Get(context.Background(), blk.chunkObjs[0])
+				require.NoError(t, err)
+				segmentName := path.Join(blk.meta.ULID.String(), "chunks", name)
+				require.NoError(t, bkt.Upload(context.Background(), segmentName, segment))
+				blk.chunkObjs = append(blk.chunkObjs, segmentName)
+			}
+
+			r := blk.chunkReader(context.Background())
+			defer func() { assert.NoError(t, r.Close()) }()
+
+			res := []seriesEntry{{chks: make([]storepb.AggrChunk, len(test.seqs)*len(offsets))}}
+			for i, seq := range test.seqs {
+				for j, offset := range offsets {
+					err := r.addLoad(chunks.ChunkRef(uint64(seq)<<32|uint64(offset)), 0, i*len(offsets)+j)
+					require.NoError(t, err)
+				}
+			}
+			require.NoError(t, r.load(res, nil))
+
+			for i, chk := range res[0].chks {
+				require.NotNil(t, chk.Raw)
+				assert.Equal(t, chks[i%len(offsets)].Bytes(), chk.Raw.Data)
+			}
+
+			// Batching doesn't change the number of range reads, only how many of them are issued concurrently.
+			assert.Equal(t, len(test.seqs)*len(offsets), int(bkt.getRangeCalls.Load()))
+			assert.Equal(t, test.expectedBatchedFetches, r.stats.chunksBatchedFetches)
+			if test.expectedMaxOpenReaders > 0 {
+				assert.LessOrEqual(t, int(bkt.maxOpenReaders.Load()), test.expectedMaxOpenReaders)
+			}
+		})
+	}
+}
+
+func BenchmarkBucketChunkReader_load_HighLatencyBucket(b *testing.B) {
+	// Chunks are far enough apart to be loaded from different partitions.
+	offsets := make([]uint32, 0, 20)
+	for i := 0; i < cap(offsets); i++ {
+		offsets = append(offsets, uint32(8+i*2*mimir_tsdb.EstimatedMaxChunkSize))
+	}
+	chks := newTestXORChunks(b, len(offsets))
+
+	load := func(r *bucketChunkReader, res []seriesEntry) error {
+		return r.load(res, nil)
+	}
+	loadSequentially := func(r *bucketChunkReader, res []seriesEntry) error {
+		pIdxs := r.toLoad[0]
+		parts := r.block.partitioner.Partition(len(pIdxs), func(i int) (start, end uint64) {
+			return uint64(pIdxs[i].offset), uint64(pIdxs[i].offset) + mimir_tsdb.EstimatedMaxChunkSize
+		})
+		return r.loadPartitionsSequentially(r.ctx, res, nil, 0, parts, pIdxs, false)
+	}
+
+	for name, test := range map[string]struct {
+		cfg  chunkReaderConfig
+		load func(r *bucketChunkReader, res []seriesEntry) error
+	}{
+		"concurrent partitions":                 {cfg: chunkReaderConfig{}, load: load},
+		"sequential partitions":                 {cfg: chunkReaderConfig{}, load: loadSequentially},
+		"sequential partitions with read-ahead": {cfg: chunkReaderConfig{readAhead: true}, load: load},
+	} {
+		b.Run(name, func(b *testing.B) {
+			blk, bkt := prepareChunkReaderTestBlock(b, offsets, chks, test.cfg)
+			blk.bkt = &highLatencyBucket{Bucket: bkt, latency: time.Millisecond, transferTime: time.Millisecond}
+
+			b.ResetTimer()
+			b.ReportAllocs()
+
+			for n := 0; n < b.N; n++ {
+				r := blk.chunkReader(context.Background())
+				res := []seriesEntry{{chks: make([]storepb.AggrChunk, len(offsets))}}
+				for i, offset := range offsets {
+					if err := r.addLoad(chunks.ChunkRef(offset), 0, i); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := test.load(r, res); err != nil {
+					b.Fatal(err)
+				}
+				if err := r.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBucketChunkReader_load(b *testing.B) {
+	// Chunks are far enough apart to be loaded from different partitions.
+	offsets := make([]uint32, 0, 100)
+	for i := 0; i < cap(offsets); i++ {
+		offsets = append(offsets, uint32(8+i*2*mimir_tsdb.EstimatedMaxChunkSize))
+	}
+
+	blk, _ := prepareChunkReaderTestBlock(b, offsets, newTestXORChunks(b, len(offsets)), chunkReaderConfig{})
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for n := 0; n < b.N; n++ {
+		r := blk.chunkReader(context.Background())
+		if _, err := loadTestChunks(b, r, offsets); err != nil {
+			b.Fatal(err)
+		}
+		if err := r.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCoalesceParts(t *testing.T) {
+	parts := []Part{
+		{Start: 0, End: 10, ElemRng: [2]int{0, 2}},
+		{Start: 15, End: 20, ElemRng: [2]int{2, 3}},
+		{Start: 40, End: 50, ElemRng: [2]int{3, 5}},
+		{Start: 54, End: 60, ElemRng: [2]int{5, 6}},
+	}
+
+	for mergeGap, expected := range map[uint64][]Part{
+		0: parts,
+		4: parts,
+		5: {
+			{Start: 0, End: 10, ElemRng: [2]int{0, 2}},
+			{Start: 15, End: 20, ElemRng: [2]int{2, 3}},
+			{Start: 40, End: 60, ElemRng: [2]int{3, 6}},
+		},
+		6: {
+			{Start: 0, End: 20, ElemRng: [2]int{0, 3}},
+			{Start: 40, End: 60, ElemRng: [2]int{3, 6}},
+		},
+		21: {
+			{Start: 0, End: 60, ElemRng: [2]int{0, 6}},
+		},
+	} {
+		input := append([]Part(nil), parts...)
+		assert.Equal(t, expected, coalesceParts(input, mergeGap), "merge gap: %d", mergeGap)
+	}
+}
+
+// prepareChunkReaderTestBlock uploads a single segment file containing chks at the given offsets,
+// and returns a block reading from it along with the bucket counting the range reads.
+func prepareChunkReaderTestBlock(t testing.TB, offsets []uint32, chks []chunkenc.Chunk, cfg chunkReaderConfig) (*bucketBlock, *rangeReadsCountingBucket) {
+	require.Len(t, chks, len(offsets))
+
+	var segment []byte
+	for i, offset := range offsets {
+		require.GreaterOrEqual(t, int(offset), len(segment), "offsets must be sorted and not overlapping")
+		segment = append(segment, make([]byte, int(offset)-len(segment))...)
+		segment = appendChunk(segment, chks[i])
+	}
+
+	bkt := &rangeReadsCountingBucket{Bucket: objstore.NewInMemBucket()}
+	blockID := ulid.MustNew(1, nil)
+	segmentName := path.Join(blockID.String(), "chunks", "000001")
+	require.NoError(t, bkt.Upload(context.Background(), segmentName, bytes.NewReader(segment)))
+
+	blk := &bucketBlock{
+		logger:         log.NewNopLogger(),
+		metrics:        NewBucketStoreMetrics(nil),
+		bkt:            bkt,
+		meta:           &metadata.Meta{BlockMeta: tsdb.BlockMeta{ULID: blockID}},
+		chunkPool:      pool.NoopBytes{},
+		chunkObjs:      []string{segmentName},
+		partitioner:    newGapBasedPartitioner(0, nil),
+		chunkReaderCfg: cfg,
+	}
+	if cfg.adaptiveChunkLength {
+		blk.chunkLengths = newChunkLengthEstimator()
+	}
+	return blk, bkt
+}
+
+// newTestXORChunks returns count XOR chunks, each one with a different set of samples.
+func newTestXORChunks(t testing.TB, count int) []chunkenc.Chunk {
+	chks := make([]chunkenc.Chunk, 0, count)
+	for i := 0; i < count; i++ {
+		chk := chunkenc.NewXORChunk()
+		app, err := chk.Appender()
+		require.NoError(t, err)
+		for ts := int64(0); ts < 10; ts++ {
+			app.Append(ts, float64(i)*float64(ts))
+		}
+		chks = append(chks, chk)
+	}
+	return chks
+}
+
+// loadTestChunks loads the chunks at the given offsets of the first segment file using r, as chunks of a single series.
+func loadTestChunks(t testing.TB, r *bucketChunkReader, offsets []uint32) ([]storepb.AggrChunk, error) {
+	res := make([]seriesEntry, 1)
+	res[0].chks = make([]storepb.AggrChunk, len(offsets))
+
+	for i, offset := range offsets {
+		err := r.addLoad(chunks.ChunkRef(offset), 0, i)
+		require.NoError(t, err)
+	}
+	return res[0].chks, r.load(res, nil)
+}
+
+// appendChunk appends chk to segment using the segment file format: the chunk data length,
+// the encoding, the chunk data and its CRC32.
+func appendChunk(segment []byte, chk chunkenc.Chunk) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	segment = append(segment, buf[:binary.PutUvarint(buf, uint64(len(chk.Bytes())))]...)
+
+	start := len(segment)
+	segment = append(segment, byte(chk.Encoding()))
+	segment = append(segment, chk.Bytes()...)
+
+	binary.BigEndian.PutUint32(buf, crc32.Checksum(segment[start:], crc32.MakeTable(crc32.Castagnoli)))
+	return append(segment, buf[:crc32.Size]...)
+}
+
+var errRangeReadFailure = errors.New("range read failure")
+
+// rangeReadsCountingBucket counts the range reads, and optionally fails one of them mid-way.
+type rangeReadsCountingBucket struct {
+	objstore.Bucket
+
+	getRangeCalls atomic.Int32
+
+	// rangeReads are the [start, end) byte ranges read, in the order they have been requested.
+	rangeReadsMtx sync.Mutex
+	rangeReads    [][2]int64
+
+	// openReaders is the number of range readers which haven't been closed yet,
+	// and maxOpenReaders the highest number of them open at the same time.
+	openReaders    atomic.Int32
+	maxOpenReaders atomic.Int32
+
+	// failingRangeRead is the number of the range read (starting from 1) which fails after
+	// returning a few bytes. 0 to never fail.
+	failingRangeRead int32
+
+	// stuckRangeRead is the number of the range read (starting from 1) which blocks after
+	// returning a few bytes, until its context is done. 0 to never block.
+	stuckRangeRead int32
+}
+
+func (b *rangeReadsCountingBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	call := b.getRangeCalls.Inc()
+
+	b.rangeReadsMtx.Lock()
+	b.rangeReads = append(b.rangeReads, [2]int64{off, off + length})
+	b.rangeReadsMtx.Unlock()
+
+	rc, err := b.Bucket.GetRange(ctx, name, off, length)
+	if err != nil {
+		return nil, err
+	}
+
+	open := b.openReaders.Inc()
+	for max := b.maxOpenReaders.Load(); open > max && !b.maxOpenReaders.CAS(max, open); max = b.maxOpenReaders.Load() {
+	}
+	var reader io.Reader = rc
+	if call == b.failingRangeRead {
+		reader = io.MultiReader(io.LimitReader(rc, 16), iotest.ErrReader(errRangeReadFailure))
+	}
+	if call == b.stuckRangeRead {
+		reader = io.MultiReader(io.LimitReader(rc, 16), contextBlockingReader{ctx: ctx})
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: reader,
+		Closer: closerFunc(func() error {
+			b.openReaders.Dec()
+			return rc.Close()
+		}),
+	}, nil
+}
+
+// alignedPartitioner expands the partitions returned by the wrapped Partitioner to alignment boundaries.
+type alignedPartitioner struct {
+	Partitioner
+	alignment uint64
+}
+
+func (p alignedPartitioner) Partition(length int, rng func(int) (uint64, uint64)) []Part {
+	parts := p.Partitioner.Partition(length, rng)
+	for i := range parts {
+		parts[i].Start -= parts[i].Start % p.alignment
+		if rem := parts[i].End % p.alignment; rem > 0 {
+			parts[i].End += p.alignment - rem
+		}
+	}
+	return parts
+}
+
+// truncatingPartitioner returns a partition for each element, which is truncated to maxLength.
+type truncatingPartitioner struct {
+	maxLength uint64
+}
+
+func (p truncatingPartitioner) Partition(length int, rng func(int) (uint64, uint64)) []Part {
+	parts := make([]Part, 0, length)
+	for i := 0; i < length; i++ {
+		start, end := rng(i)
+		if end-start > p.maxLength {
+			end = start + p.maxLength
+		}
+		parts = append(parts, Part{Start: start, End: end, ElemRng: [2]int{i, i + 1}})
+	}
+	return parts
+}
+
+// contextBlockingReader blocks reads until ctx is done, like a stuck connection.
+type contextBlockingReader struct {
+	ctx context.Context
+}
+
+func (r contextBlockingReader) Read([]byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
+
+func findMetricFamily(families []*dto.MetricFamily, name string) *dto.MetricFamily {
+	for _, family := range families {
+		if family.GetName() == name {
+			return family
+		}
+	}
+	return nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// highLatencyBucket simulates an object storage with a latency before a range read starts
+// returning data, and a transfer time to read it.
+type highLatencyBucket struct {
+	objstore.Bucket
+
+	latency      time.Duration
+	transferTime time.Duration
+}
+
+func (b *highLatencyBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	time.Sleep(b.latency)
+
+	rc, err := b.Bucket.GetRange(ctx, name, off, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: &slowReader{Reader: rc, delay: b.transferTime},
+		Closer: rc,
+	}, nil
+}
+
+// slowReader delays the first read from Reader.
+type slowReader struct {
+	io.Reader
+	delay time.Duration
+	read  bool
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if !r.read {
+		r.read = true
+		time.Sleep(r.delay)
+	}
+	return r.Reader.Read(p)
+}
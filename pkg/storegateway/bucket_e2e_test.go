@@ -8,6 +8,7 @@ package storegateway
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,6 +18,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/gogo/status"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/prometheus/prometheus/tsdb/hashcache"
@@ -129,7 +131,7 @@ func newCustomSeriesLimiterFactory(limit uint64, code codes.Code) SeriesLimiterF
 	}
 }
 
-func prepareStoreWithTestBlocks(t testing.TB, dir string, bkt objstore.Bucket, manyParts bool, chunksLimiterFactory ChunksLimiterFactory, seriesLimiterFactory SeriesLimiterFactory) *storeSuite {
+func prepareStoreWithTestBlocks(t testing.TB, dir string, bkt objstore.Bucket, manyParts bool, chunksLimiterFactory ChunksLimiterFactory, seriesLimiterFactory SeriesLimiterFactory, opts ...BucketStoreOption) *storeSuite {
 	series := []labels.Labels{
 		labels.FromStrings("a", "1", "b", "1"),
 		labels.FromStrings("a", "1", "b", "2"),
@@ -140,10 +142,10 @@ func prepareStoreWithTestBlocks(t testing.TB, dir string, bkt objstore.Bucket, m
 		labels.FromStrings("a", "2", "c", "1"),
 		labels.FromStrings("a", "2", "c", "2"),
 	}
-	return prepareStoreWithTestBlocksForSeries(t, dir, bkt, manyParts, chunksLimiterFactory, seriesLimiterFactory, series)
+	return prepareStoreWithTestBlocksForSeries(t, dir, bkt, manyParts, chunksLimiterFactory, seriesLimiterFactory, series, opts...)
 }
 
-func prepareStoreWithTestBlocksForSeries(t testing.TB, dir string, bkt objstore.Bucket, manyParts bool, chunksLimiterFactory ChunksLimiterFactory, seriesLimiterFactory SeriesLimiterFactory, series []labels.Labels) *storeSuite {
+func prepareStoreWithTestBlocksForSeries(t testing.TB, dir string, bkt objstore.Bucket, manyParts bool, chunksLimiterFactory ChunksLimiterFactory, seriesLimiterFactory SeriesLimiterFactory, series []labels.Labels, opts ...BucketStoreOption) *storeSuite {
 	extLset := labels.FromStrings("ext1", "value1")
 
 	minTime, maxTime := prepareTestBlocks(t, time.Now(), 3, dir, bkt, series, extLset)
@@ -173,8 +175,7 @@ func prepareStoreWithTestBlocksForSeries(t testing.TB, dir string, bkt objstore.
 		time.Minute,
 		hashcache.NewSeriesHashCache(1024*1024),
 		NewBucketStoreMetrics(nil),
-		WithLogger(s.logger),
-		WithIndexCache(s.cache),
+		append([]BucketStoreOption{WithLogger(s.logger), WithIndexCache(s.cache)}, opts...)...,
 	)
 	assert.NoError(t, err)
 	t.Cleanup(func() {
@@ -447,6 +448,42 @@ func TestBucketStore_e2e(t *testing.T) {
 	})
 }
 
+func TestBucketStore_StreamingSeries_e2e(t *testing.T) {
+	foreachStore(t, func(t *testing.T, bkt objstore.Bucket) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		dir := t.TempDir()
+
+		s := prepareStoreWithTestBlocks(t, dir, bkt, false, NewChunksLimiterFactory(0), NewSeriesLimiterFactory(0))
+		s.cache.SwapWith(noopCache{})
+
+		for _, batchSize := range []int{1, 3, 100} {
+			if ok := t.Run(fmt.Sprintf("batch size: %d", batchSize), func(t *testing.T) {
+				s.store.maxSeriesPerBatch = batchSize
+				testBucketStore_e2e(t, ctx, s)
+			}); !ok {
+				return
+			}
+		}
+	})
+}
+
+func TestBucketStore_ChunksChecksumValidation_e2e(t *testing.T) {
+	foreachStore(t, func(t *testing.T, bkt objstore.Bucket) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		dir := t.TempDir()
+
+		s := prepareStoreWithTestBlocks(t, dir, bkt, false, NewChunksLimiterFactory(0), NewSeriesLimiterFactory(0), WithChunksChecksumValidation(true))
+		s.cache.SwapWith(noopCache{})
+
+		testBucketStore_e2e(t, ctx, s)
+		assert.Zero(t, testutil.ToFloat64(s.store.metrics.corruptedChunks))
+	})
+}
+
 type naivePartitioner struct{}
 
 func (g naivePartitioner) Partition(length int, rng func(int) (uint64, uint64)) (parts []Part) {
@@ -547,6 +584,154 @@ func TestBucketStore_Series_ChunksLimiter_e2e(t *testing.T) {
 	}
 }
 
+func TestBucketStore_Series_MaxFetchedChunkBytes_e2e(t *testing.T) {
+	cases := map[string]struct {
+		maxFetchedBytes uint64
+		expectedErr     string
+	}{
+		"should succeed if the max fetched chunk bytes limit is not exceeded": {
+			maxFetchedBytes: 10 * 1024 * 1024,
+		},
+		"should fail if the max fetched chunk bytes limit is exceeded": {
+			maxFetchedBytes: 1,
+			expectedErr:     "exceeded chunk bytes limit",
+		},
+	}
+
+	for testName, testData := range cases {
+		for _, batchSize := range []int{0, 1} {
+			t.Run(fmt.Sprintf("%s, batch size: %d", testName, batchSize), func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				s := prepareStoreWithTestBlocks(t, t.TempDir(), objstore.NewInMemBucket(), false, NewChunksLimiterFactory(0), NewSeriesLimiterFactory(0),
+					WithStreamingSeriesPerBatch(batchSize),
+					WithMaxFetchedChunkBytesPerQuery(testData.maxFetchedBytes),
+					WithMaxConcurrentChunkFetchesPerQuery(1),
+				)
+				s.cache.SwapWith(noopCache{})
+
+				req := &storepb.SeriesRequest{
+					Matchers: []storepb.LabelMatcher{
+						{Type: storepb.LabelMatcher_EQ, Name: "a", Value: "1"},
+					},
+					MinTime: timestamp.FromTime(minTime),
+					MaxTime: timestamp.FromTime(maxTime),
+				}
+
+				srv := newBucketStoreSeriesServer(ctx)
+				err := s.store.Series(req, srv)
+
+				if testData.expectedErr == "" {
+					assert.NoError(t, err)
+					assert.Len(t, srv.SeriesSet, 4)
+					return
+				}
+
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+				status, ok := status.FromError(err)
+				assert.True(t, ok)
+				assert.Equal(t, codes.Code(http.StatusUnprocessableEntity), status.Code())
+				assert.Equal(t, float64(1), testutil.ToFloat64(s.store.metrics.queriesDropped.WithLabelValues("chunk_bytes")))
+
+				// The stats of the data fetched before the limit was exceeded are sent.
+				assert.NotZero(t, srv.Stats.FetchedIndexBytes)
+				assert.Zero(t, srv.Stats.FetchedChunkBytes)
+			})
+		}
+	}
+}
+
+func TestBucketStore_Series_FetchedBytesRateLimit_e2e(t *testing.T) {
+	cases := map[string]struct {
+		limits      staticFetchedBytesRateLimits
+		expectedErr string
+	}{
+		"should succeed if the fetched bytes rate limit is not exceeded": {
+			limits: staticFetchedBytesRateLimits{rate: 100 * 1024 * 1024},
+		},
+		"should fail if the fetched bytes rate limit is exceeded": {
+			limits:      staticFetchedBytesRateLimits{rate: 1},
+			expectedErr: "exceeded fetched bytes rate limit",
+		},
+	}
+
+	for testName, testData := range cases {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			limited := prometheus.NewCounter(prometheus.CounterOpts{Name: "limited"})
+			s := prepareStoreWithTestBlocks(t, t.TempDir(), objstore.NewInMemBucket(), false, NewChunksLimiterFactory(0), NewSeriesLimiterFactory(0),
+				WithFetchedBytesRateLimiter(newFetchedBytesRateLimiter("tenant", testData.limits, limited)),
+			)
+			s.cache.SwapWith(noopCache{})
+
+			req := &storepb.SeriesRequest{
+				Matchers: []storepb.LabelMatcher{
+					{Type: storepb.LabelMatcher_EQ, Name: "a", Value: "1"},
+				},
+				MinTime: timestamp.FromTime(minTime),
+				MaxTime: timestamp.FromTime(maxTime),
+			}
+
+			srv := newBucketStoreSeriesServer(ctx)
+			err := s.store.Series(req, srv)
+
+			if testData.expectedErr == "" {
+				assert.NoError(t, err)
+				assert.Len(t, srv.SeriesSet, 4)
+				assert.Zero(t, testutil.ToFloat64(limited))
+				return
+			}
+
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), testData.expectedErr)
+			status, ok := status.FromError(err)
+			assert.True(t, ok)
+			assert.Equal(t, codes.ResourceExhausted, status.Code())
+			assert.NotZero(t, testutil.ToFloat64(limited))
+		})
+	}
+}
+
+func TestBucketStore_Series_ChunkSlabPool_e2e(t *testing.T) {
+	for _, batchSize := range []int{0, 1} {
+		t.Run(fmt.Sprintf("batch size: %d", batchSize), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			slabPool := newChunkSlabPool(chunkSlabPoolShards*1024*1024, nil)
+			s := prepareStoreWithTestBlocks(t, t.TempDir(), objstore.NewInMemBucket(), false, NewChunksLimiterFactory(0), NewSeriesLimiterFactory(0),
+				WithStreamingSeriesPerBatch(batchSize),
+				WithChunkSlabPool(slabPool),
+			)
+			s.cache.SwapWith(noopCache{})
+
+			req := &storepb.SeriesRequest{
+				Matchers: []storepb.LabelMatcher{
+					{Type: storepb.LabelMatcher_EQ, Name: "a", Value: "1"},
+				},
+				MinTime: timestamp.FromTime(minTime),
+				MaxTime: timestamp.FromTime(maxTime),
+			}
+
+			// The slabs are returned to the pool once the Series() call completes,
+			// and reused by the next Series() calls.
+			for i := 0; i < 2*chunkSlabPoolShards; i++ {
+				srv := newBucketStoreSeriesServer(ctx)
+				assert.NoError(t, s.store.Series(req, srv))
+				assert.Len(t, srv.SeriesSet, 4)
+			}
+			assert.NotZero(t, slabPool.retainedBytes())
+			assert.NotZero(t, testutil.ToFloat64(slabPool.hits))
+		})
+	}
+}
+
 func TestBucketStore_LabelNames_e2e(t *testing.T) {
 	foreachStore(t, func(t *testing.T, bkt objstore.Bucket) {
 		ctx, cancel := context.WithCancel(context.Background())
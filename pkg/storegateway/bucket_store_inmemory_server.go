@@ -28,6 +28,7 @@ type bucketStoreSeriesServer struct {
 	SeriesSet []*storepb.Series
 	Warnings  storage.Warnings
 	Hints     hintspb.SeriesResponseHints
+	Stats     storepb.Stats
 }
 
 func newBucketStoreSeriesServer(ctx context.Context) *bucketStoreSeriesServer {
@@ -46,6 +47,12 @@ func (s *bucketStoreSeriesServer) Send(r *storepb.SeriesResponse) error {
 		}
 	}
 
+	if recvStats := r.GetStats(); recvStats != nil {
+		s.Stats.FetchedIndexBytes += recvStats.FetchedIndexBytes
+		s.Stats.FetchedChunkBytes += recvStats.FetchedChunkBytes
+		s.Stats.FetchedChunks += recvStats.FetchedChunks
+	}
+
 	if recvSeries := r.GetSeries(); recvSeries != nil {
 		// Thanos uses a pool for the chunks and may use other pools in the future.
 		// Given we need to retain the reference after the pooled slices are recycled,
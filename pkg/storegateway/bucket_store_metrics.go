@@ -36,6 +36,12 @@ type BucketStoreMetrics struct {
 	queriesDropped        *prometheus.CounterVec
 	seriesRefetches       prometheus.Counter
 
+	seriesChunksSkippedBytes prometheus.Counter
+	seriesChunkRangeReseeks  prometheus.Counter
+	seriesChunkBatchedReads  prometheus.Counter
+	seriesChunkRefetches     prometheus.Counter
+	seriesHistogramChunks    *prometheus.CounterVec
+
 	cachedPostingsCompressions           *prometheus.CounterVec
 	cachedPostingsCompressionErrors      *prometheus.CounterVec
 	cachedPostingsCompressionTimeSeconds *prometheus.CounterVec
@@ -45,8 +51,11 @@ type BucketStoreMetrics struct {
 	seriesHashCacheRequests prometheus.Counter
 	seriesHashCacheHits     prometheus.Counter
 
-	seriesFetchDuration   prometheus.Histogram
-	postingsFetchDuration prometheus.Histogram
+	seriesFetchDuration     prometheus.Histogram
+	postingsFetchDuration   prometheus.Histogram
+	chunksFetchDuration     prometheus.Histogram
+	corruptedChunks         prometheus.Counter
+	fetchedBytesRateLimited prometheus.Counter
 
 	indexHeaderReaderMetrics *indexheader.ReaderPoolMetrics
 }
@@ -107,13 +116,33 @@ func NewBucketStoreMetrics(reg prometheus.Registerer) *BucketStoreMetrics {
 		Name: "cortex_bucket_store_series_refetches_total",
 		Help: "Total number of cases where the built-in max series size was not enough to fetch series from index, resulting in refetch.",
 	})
+	m.seriesChunksSkippedBytes = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_series_chunks_skipped_bytes_total",
+		Help: "Total number of chunk bytes fetched from the bucket and discarded because they don't belong to any requested chunk.",
+	})
+	m.seriesChunkRangeReseeks = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_series_chunk_range_reseeks_total",
+		Help: "Total number of chunk range reads interrupted to skip a large gap of unused bytes, by issuing a new range read starting from the next chunk.",
+	})
+	m.seriesChunkBatchedReads = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_series_chunk_batched_range_reads_total",
+		Help: "Total number of chunk range reads of contiguous segment files batched with other ones, and so issued sequentially instead of as separate concurrent requests.",
+	})
+	m.seriesChunkRefetches = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_series_chunk_refetches_total",
+		Help: "Total number of chunks refetched with a dedicated range read, because they're longer than the estimated chunk length.",
+	})
+	m.seriesHistogramChunks = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_series_histogram_chunks_touched_total",
+		Help: "Total number of native histogram chunks touched to satisfy queries, by encoding.",
+	}, []string{"encoding"})
 	m.resultSeriesCount = promauto.With(reg).NewSummary(prometheus.SummaryOpts{
 		Name: "cortex_bucket_store_series_result_series",
 		Help: "Number of series observed in the final result of a query.",
 	})
 	m.queriesDropped = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 		Name: "cortex_bucket_store_queries_dropped_total",
-		Help: "Number of queries that were dropped due to the max chunks per query limit.",
+		Help: "Number of queries that were dropped due to a per-query limit.",
 	}, []string{"reason"})
 
 	m.cachedPostingsCompressions = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
@@ -156,6 +185,19 @@ func NewBucketStoreMetrics(reg prometheus.Registerer) *BucketStoreMetrics {
 		Help:    "Time it takes to fetch postings to respond a request sent to store-gateway. It includes both the time to fetch it from cache and from storage in case of cache misses.",
 		Buckets: []float64{0.001, 0.01, 0.1, 0.3, 0.6, 1, 3, 6, 9, 20, 30, 60, 90, 120},
 	})
+	m.chunksFetchDuration = promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+		Name:    "cortex_bucket_store_chunk_fetch_duration_seconds",
+		Help:    "Time it takes to fetch a range of chunks from the object storage, for each range read.",
+		Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	})
+	m.corruptedChunks = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_corrupted_chunks_total",
+		Help: "Total number of chunks read from the object storage whose checksum doesn't match.",
+	})
+	m.fetchedBytesRateLimited = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_fetched_bytes_rate_limited_total",
+		Help: "Total number of chunk and index range reads rejected because the tenant exceeded the fetched bytes rate limit.",
+	})
 
 	m.seriesHashCacheRequests = promauto.With(reg).NewCounter(prometheus.CounterOpts{
 		Name: "cortex_bucket_store_series_hash_cache_requests_total",
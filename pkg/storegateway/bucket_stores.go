@@ -30,7 +30,9 @@ import (
 	"github.com/grafana/mimir/pkg/storage/bucket"
 	"github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/storegateway/chunkscache"
 	"github.com/grafana/mimir/pkg/storegateway/indexcache"
+	"github.com/grafana/mimir/pkg/storegateway/indexheader"
 	"github.com/grafana/mimir/pkg/storegateway/storepb"
 	"github.com/grafana/mimir/pkg/util/gate"
 	util_log "github.com/grafana/mimir/pkg/util/log"
@@ -58,6 +60,9 @@ type BucketStores struct {
 	// Index cache shared across all tenants.
 	indexCache indexcache.IndexCache
 
+	// Chunk ranges cache shared between all tenants, nil if disabled.
+	chunkRangesCache chunkscache.Cache
+
 	// Series hash cache shared across all tenants.
 	seriesHashCache *hashcache.SeriesHashCache
 
@@ -70,10 +75,29 @@ type BucketStores struct {
 	// Gate used to limit query concurrency across all tenants.
 	queryGate gate.Gate
 
+	// Gate used to limit chunk range reads concurrency across all tenants.
+	chunksFetchGate gate.Gate
+
+	// Hedger of the chunk range reads across all tenants. Nil if hedging is disabled.
+	chunkRangesHedger *chunkRangeHedger
+
+	// Pool of the slabs the loaded chunks are copied to, shared across all tenants.
+	chunkSlabPool *chunkSlabPool
+
+	// Limits the index-header downloads while syncing the blocks of all tenants.
+	blockSyncLimiter *blockSyncLimiter
+
+	// Unloads the lazy loaded index-headers of all tenants because of memory pressure. Nil if disabled.
+	indexHeaderEvictor *indexheader.MemoryPressureEvictor
+
 	// Keeps a bucket store for each tenant.
 	storesMu sync.RWMutex
 	stores   map[string]*BucketStore
 
+	// Keeps the last warmup of the blocks of each tenant.
+	warmupsMu sync.Mutex
+	warmups   map[string]*blocksWarmup
+
 	// Metrics.
 	syncTimes         prometheus.Histogram
 	syncLastSuccess   prometheus.Gauge
@@ -99,6 +123,30 @@ func NewBucketStores(cfg tsdb.BlocksStorageConfig, shardingStrategy ShardingStra
 	}
 	queryGate = gate.NewInstrumented(queryGateReg, cfg.BucketStore.MaxConcurrent, queryGate)
 
+	// The number of concurrent chunk range reads against the tenants BucketStores is limited too, if configured.
+	chunksFetchGate := gate.NewNoop()
+	if cfg.BucketStore.MaxConcurrentChunksFetches > 0 {
+		chunksFetchGateReg := prometheus.WrapRegistererWithPrefix("cortex_bucket_stores_chunks_fetch_", reg)
+		chunksFetchGate = gate.NewInstrumented(chunksFetchGateReg, cfg.BucketStore.MaxConcurrentChunksFetches, gate.NewBlocking(cfg.BucketStore.MaxConcurrentChunksFetches))
+	}
+
+	// The slow chunk range reads are hedged, if configured. The latency is tracked across all the tenants.
+	var chunkRangesHedger *chunkRangeHedger
+	if cfg.BucketStore.ChunkRangesHedgingPercentile > 0 {
+		chunkRangesHedger = newChunkRangeHedger(cfg.BucketStore.ChunkRangesHedgingPercentile, reg)
+	}
+
+	// The lazy loaded index-headers are unloaded when the memory usage exceeds the budget, if configured.
+	var indexHeaderEvictor *indexheader.MemoryPressureEvictor
+	if cfg.BucketStore.IndexHeaderLazyLoadingEnabled && cfg.BucketStore.IndexHeaderLazyLoadingMemoryBudgetBytes > 0 {
+		indexHeaderEvictor = indexheader.NewMemoryPressureEvictor(
+			cfg.BucketStore.IndexHeaderLazyLoadingMemoryBudgetBytes,
+			cfg.BucketStore.IndexHeaderLazyLoadingMemoryCheckInterval,
+			logger,
+			prometheus.WrapRegistererWithPrefix("cortex_bucket_store_", reg),
+		)
+	}
+
 	u := &BucketStores{
 		logger:             logger,
 		cfg:                cfg,
@@ -106,10 +154,16 @@ func NewBucketStores(cfg tsdb.BlocksStorageConfig, shardingStrategy ShardingStra
 		bucket:             cachingBucket,
 		shardingStrategy:   shardingStrategy,
 		stores:             map[string]*BucketStore{},
+		warmups:            map[string]*blocksWarmup{},
 		logLevel:           logLevel,
 		bucketStoreMetrics: NewBucketStoreMetrics(reg),
 		metaFetcherMetrics: NewMetadataFetcherMetrics(),
 		queryGate:          queryGate,
+		chunksFetchGate:    chunksFetchGate,
+		chunkRangesHedger:  chunkRangesHedger,
+		chunkSlabPool:      newChunkSlabPool(cfg.BucketStore.ChunkSlabPoolMaxRetainedBytes, reg),
+		indexHeaderEvictor: indexHeaderEvictor,
+		blockSyncLimiter:   newBlockSyncLimiter(cfg.BucketStore.IndexHeaderDownloadConcurrency, cfg.BucketStore.IndexHeaderDownloadRateLimitBytes, reg),
 		partitioner:        newGapBasedPartitioner(cfg.BucketStore.PartitionerMaxGapBytes, reg),
 		seriesHashCache:    hashcache.NewSeriesHashCache(cfg.BucketStore.SeriesHashCacheMaxBytes),
 		syncBackoffConfig: backoff.Config{
@@ -143,10 +197,15 @@ func NewBucketStores(cfg tsdb.BlocksStorageConfig, shardingStrategy ShardingStra
 	}, u.getBlocksLoadedMetric)
 
 	// Init the index cache.
-	if u.indexCache, err = tsdb.NewIndexCache(cfg.BucketStore.IndexCache, logger, reg); err != nil {
+	if u.indexCache, err = tsdb.NewIndexCache(cfg.BucketStore.IndexCache, u.indexCacheTenantMaxSize, logger, reg); err != nil {
 		return nil, errors.Wrap(err, "create index cache")
 	}
 
+	// Init the chunk ranges cache.
+	if u.chunkRangesCache, err = tsdb.NewChunkRangesCache(cfg.BucketStore.ChunkRangesCache, logger, reg); err != nil {
+		return nil, errors.Wrap(err, "create chunk ranges cache")
+	}
+
 	// Init the chunks bytes pool.
 	if u.chunksPool, err = newChunkBytesPool(cfg.BucketStore.ChunkPoolMinBucketSizeBytes, cfg.BucketStore.ChunkPoolMaxBucketSizeBytes, cfg.BucketStore.MaxChunkPoolBytes, reg); err != nil {
 		return nil, errors.Wrap(err, "create chunks bytes pool")
@@ -159,6 +218,17 @@ func NewBucketStores(cfg tsdb.BlocksStorageConfig, shardingStrategy ShardingStra
 	return u, nil
 }
 
+// indexCacheTenantMaxSize returns the quota of the tenant in the index cache.
+func (u *BucketStores) indexCacheTenantMaxSize(userID string) uint64 {
+	if u.limits == nil {
+		return 0
+	}
+	if size := u.limits.StoreGatewayIndexCacheMaxSizeBytes(userID); size > 0 {
+		return uint64(size)
+	}
+	return 0
+}
+
 // InitialSync does an initial synchronization of blocks for all users.
 func (u *BucketStores) InitialSync(ctx context.Context) error {
 	level.Info(u.logger).Log("msg", "synchronizing TSDB blocks for all users")
@@ -388,7 +458,11 @@ func (u *BucketStores) closeBucketStore(userID string) error {
 	unlockInDefer = false
 	u.storesMu.Unlock()
 
+	u.removeWarmup(userID)
 	u.metaFetcherMetrics.RemoveUserRegistry(userID)
+	if u.chunkRangesCache != nil {
+		u.chunkRangesCache.RemoveUser(userID)
+	}
 	return bs.RemoveBlocksAndClose()
 }
 
@@ -461,12 +535,33 @@ func (u *BucketStores) getOrCreateStore(userID string) (*BucketStore, error) {
 	bucketStoreOpts := []BucketStoreOption{
 		WithLogger(userLogger),
 		WithIndexCache(u.indexCache),
+		WithChunkRangesCache(u.chunkRangesCache),
 		WithQueryGate(u.queryGate),
+		WithChunksFetchGate(u.chunksFetchGate),
+		WithChunkRangesHedger(u.chunkRangesHedger),
+		WithChunkSlabPool(u.chunkSlabPool),
+		WithIndexHeaderMemoryPressureEvictor(u.indexHeaderEvictor),
+		WithBlockSyncLimiter(u.blockSyncLimiter),
+		WithFetchedBytesRateLimiter(newFetchedBytesRateLimiter(userID, u.limits, u.bucketStoreMetrics.fetchedBytesRateLimited)),
 		WithChunkPool(u.chunksPool),
+		WithChunkRangesMergeGap(u.cfg.BucketStore.ChunkRangesMergeGapBytes),
+		WithChunkRangesMaxDiscardRatio(u.cfg.BucketStore.ChunkRangesMaxDiscardRatio),
+		WithChunkRangesMaxDiscardBytes(u.cfg.BucketStore.ChunkRangesMaxDiscardBytes),
+		WithChunkRangesReadAhead(u.cfg.BucketStore.ChunkRangesReadAheadEnabled),
+		WithChunkRangesReadTimeout(u.cfg.BucketStore.ChunkRangesReadTimeout),
+		WithChunkRangesBatchMaxBytes(u.cfg.BucketStore.ChunkRangesBatchMaxBytes),
+		WithAdaptiveChunkLengthEstimation(u.cfg.BucketStore.ChunksAdaptiveLengthEstimationEnabled),
+		WithStreamingSeriesPerBatch(u.cfg.BucketStore.StreamingBatchSize),
+		WithChunksChecksumValidation(u.cfg.BucketStore.ChunksChecksumValidationEnabled),
+		WithMaxConcurrentChunkFetchesPerQuery(u.cfg.BucketStore.MaxConcurrentChunkFetchesPerQuery),
+		WithMaxFetchedChunkBytesPerQuery(u.cfg.BucketStore.MaxFetchedChunkBytesPerQuery),
 	}
 	if u.logLevel.String() == "debug" {
 		bucketStoreOpts = append(bucketStoreOpts, WithDebugLogging())
 	}
+	if u.cfg.BucketStore.ChunksFetchEstimateLoggingEnabled {
+		bucketStoreOpts = append(bucketStoreOpts, WithChunksFetchEstimateLogging())
+	}
 
 	bs, err := NewBucketStore(
 		userID,
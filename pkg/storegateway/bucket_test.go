@@ -80,7 +80,7 @@ func TestBucketBlock_matchLabels(t *testing.T) {
 		},
 	}
 
-	b, err := newBucketBlock(context.Background(), "test", log.NewNopLogger(), NewBucketStoreMetrics(nil), meta, bkt, path.Join(dir, blockID.String()), nil, nil, nil, nil)
+	b, err := newBucketBlock(context.Background(), "test", log.NewNopLogger(), NewBucketStoreMetrics(nil), meta, bkt, path.Join(dir, blockID.String()), nil, nil, nil, nil, chunkReaderConfig{})
 	assert.NoError(t, err)
 
 	cases := []struct {
@@ -1121,6 +1121,7 @@ type mockedPool struct {
 	parent  pool.Bytes
 	balance atomic.Uint64
 	gets    atomic.Uint64
+	puts    atomic.Uint64
 }
 
 func (m *mockedPool) Get(sz int) (*[]byte, error) {
@@ -1135,6 +1136,7 @@ func (m *mockedPool) Get(sz int) (*[]byte, error) {
 
 func (m *mockedPool) Put(b *[]byte) {
 	m.balance.Sub(uint64(cap(*b)))
+	m.puts.Add(uint64(1))
 	m.parent.Put(b)
 }
 
@@ -1256,7 +1258,7 @@ func TestBucketSeries_OneBlock_InMemIndexCacheSegfault(t *testing.T) {
 		bkt:             objstore.WithNoopInstr(bkt),
 		logger:          logger,
 		indexCache:      indexCache,
-		indexReaderPool: indexheader.NewReaderPool(log.NewNopLogger(), false, 0, indexheader.NewReaderPoolMetrics(nil)),
+		indexReaderPool: indexheader.NewReaderPool(log.NewNopLogger(), false, 0, nil, indexheader.NewReaderPoolMetrics(nil)),
 		metrics:         NewBucketStoreMetrics(nil),
 		blockSet:        &bucketBlockSet{blocks: [][]*bucketBlock{{b1, b2}}},
 		blocks: map[ulid.ULID]*bucketBlock{
@@ -1514,26 +1516,38 @@ func TestSeries_BlockWithMultipleChunks(t *testing.T) {
 		reqMinTime      int64
 		reqMaxTime      int64
 		expectedSamples int
+		// expectedBoundaries tells, for each returned chunk, whether it straddles the queried time range edges.
+		expectedBoundaries []bool
 	}{
 		"query the entire block": {
-			reqMinTime:      math.MinInt64,
-			reqMaxTime:      math.MaxInt64,
-			expectedSamples: 10000,
+			reqMinTime:         math.MinInt64,
+			reqMaxTime:         math.MaxInt64,
+			expectedSamples:    10000,
+			expectedBoundaries: make([]bool, (10000+MaxSamplesPerChunk-1)/MaxSamplesPerChunk),
+		},
+		"query exactly the first chunk": {
+			reqMinTime:         0,
+			reqMaxTime:         MaxSamplesPerChunk - 1,
+			expectedSamples:    MaxSamplesPerChunk,
+			expectedBoundaries: []bool{false},
 		},
 		"query the beginning of the block": {
-			reqMinTime:      0,
-			reqMaxTime:      100,
-			expectedSamples: MaxSamplesPerChunk,
+			reqMinTime:         0,
+			reqMaxTime:         100,
+			expectedSamples:    MaxSamplesPerChunk,
+			expectedBoundaries: []bool{true},
 		},
 		"query the middle of the block": {
-			reqMinTime:      4000,
-			reqMaxTime:      4050,
-			expectedSamples: MaxSamplesPerChunk,
+			reqMinTime:         4000,
+			reqMaxTime:         4050,
+			expectedSamples:    MaxSamplesPerChunk,
+			expectedBoundaries: []bool{true},
 		},
 		"query the end of the block": {
-			reqMinTime:      9800,
-			reqMaxTime:      10000,
-			expectedSamples: (MaxSamplesPerChunk * 2) + (10000 % MaxSamplesPerChunk),
+			reqMinTime:         9800,
+			reqMaxTime:         10000,
+			expectedSamples:    (MaxSamplesPerChunk * 2) + (10000 % MaxSamplesPerChunk),
+			expectedBoundaries: []bool{true, false, false},
 		},
 	}
 
@@ -1553,15 +1567,24 @@ func TestSeries_BlockWithMultipleChunks(t *testing.T) {
 			assert.True(t, len(srv.SeriesSet) == 1)
 
 			// Count the number of samples in the returned chunks.
-			numSamples := 0
+			numSamples, numChunkBytes := 0, 0
+			boundaries := make([]bool, 0, len(srv.SeriesSet[0].Chunks))
 			for _, rawChunk := range srv.SeriesSet[0].Chunks {
+				boundaries = append(boundaries, rawChunk.Boundary)
+
 				decodedChunk, err := chunkenc.FromData(chunkenc.EncXOR, rawChunk.Raw.Data)
 				assert.NoError(t, err)
 
 				numSamples += decodedChunk.NumSamples()
+				numChunkBytes += len(rawChunk.Raw.Data)
 			}
 
 			assert.True(t, testData.expectedSamples == numSamples, "expected: %d, actual: %d", testData.expectedSamples, numSamples)
+			assert.Equal(t, testData.expectedBoundaries, boundaries)
+
+			// The chunk reader stats should be reported back in the response stats.
+			assert.Equal(t, uint64(len(srv.SeriesSet[0].Chunks)), srv.Stats.FetchedChunks)
+			assert.GreaterOrEqual(t, srv.Stats.FetchedChunkBytes, uint64(numChunkBytes))
 		})
 	}
 }
@@ -1879,7 +1902,7 @@ func BenchmarkBucketBlock_readChunkRange(b *testing.B) {
 	assert.NoError(b, err)
 
 	// Create a bucket block with only the dependencies we need for the benchmark.
-	blk, err := newBucketBlock(context.Background(), "tenant", logger, NewBucketStoreMetrics(nil), blockMeta, bkt, tmpDir, nil, chunkPool, nil, nil)
+	blk, err := newBucketBlock(context.Background(), "tenant", logger, NewBucketStoreMetrics(nil), blockMeta, bkt, tmpDir, nil, chunkPool, nil, nil, chunkReaderConfig{})
 	assert.NoError(b, err)
 
 	b.ResetTimer()
@@ -1960,7 +1983,7 @@ func prepareBucket(b *testing.B) (*bucketBlock, *metadata.Meta) {
 	assert.NoError(b, err)
 
 	// Create a bucket block with only the dependencies we need for the benchmark.
-	blk, err := newBucketBlock(context.Background(), "tenant", logger, NewBucketStoreMetrics(nil), blockMeta, bkt, tmpDir, indexCache, chunkPool, indexHeaderReader, partitioner)
+	blk, err := newBucketBlock(context.Background(), "tenant", logger, NewBucketStoreMetrics(nil), blockMeta, bkt, tmpDir, indexCache, chunkPool, indexHeaderReader, partitioner, chunkReaderConfig{})
 	assert.NoError(b, err)
 	return blk, blockMeta
 }
@@ -0,0 +1,240 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/runutil"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+const (
+	warmupStateRunning  = "running"
+	warmupStateDone     = "done"
+	warmupStateCanceled = "canceled"
+
+	// Max number of block errors reported by the warmup status.
+	maxWarmupErrors = 10
+)
+
+var (
+	errWarmupInProgress = errors.New("a warmup of the tenant blocks is already in progress")
+	errWarmupNotFound   = errors.New("no warmup of the tenant blocks has been started")
+)
+
+// warmupStatus is the progress of the warmup of the blocks of a tenant.
+type warmupStatus struct {
+	Tenant       string     `json:"tenant"`
+	MinTime      int64      `json:"min_time"`
+	MaxTime      int64      `json:"max_time"`
+	IndexCache   bool       `json:"index_cache"`
+	State        string     `json:"state"`
+	StartedAt    time.Time  `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	TotalBlocks  int        `json:"total_blocks"`
+	WarmedBlocks int        `json:"warmed_blocks"`
+	FailedBlocks int        `json:"failed_blocks"`
+	Errors       []string   `json:"errors,omitempty"`
+}
+
+// blocksWarmup is a warmup of the blocks of a tenant running in the background.
+type blocksWarmup struct {
+	cancel context.CancelFunc
+
+	mtx    sync.Mutex
+	status warmupStatus
+}
+
+// snapshot returns a copy of the current status of the warmup.
+func (w *blocksWarmup) snapshot() warmupStatus {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	status := w.status
+	status.Errors = append([]string(nil), w.status.Errors...)
+	return status
+}
+
+func (w *blocksWarmup) running() bool {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return w.status.State == warmupStateRunning
+}
+
+func (w *blocksWarmup) start(totalBlocks int) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.status.TotalBlocks = totalBlocks
+}
+
+func (w *blocksWarmup) blockDone(id ulid.ULID, err error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if err == nil {
+		w.status.WarmedBlocks++
+		return
+	}
+	w.status.FailedBlocks++
+	if len(w.status.Errors) < maxWarmupErrors {
+		w.status.Errors = append(w.status.Errors, errors.Wrapf(err, "block %s", id).Error())
+	}
+}
+
+func (w *blocksWarmup) finish(state string) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	now := time.Now()
+	w.status.State = state
+	w.status.FinishedAt = &now
+}
+
+// StartWarmup starts warming up in the background the blocks of the tenant overlapping [mint, maxt]: their
+// index-headers are loaded and, if warmIndexCache is true, the index cache is populated with their label
+// names, metric names and metric names postings. Only one warmup per tenant can run at a time.
+func (u *BucketStores) StartWarmup(userID string, mint, maxt int64, warmIndexCache bool) (warmupStatus, error) {
+	store := u.getStore(userID)
+	if store == nil {
+		return warmupStatus{}, errBucketStoreNotFound
+	}
+
+	u.warmupsMu.Lock()
+	defer u.warmupsMu.Unlock()
+
+	if w, ok := u.warmups[userID]; ok && w.running() {
+		return warmupStatus{}, errWarmupInProgress
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &blocksWarmup{
+		cancel: cancel,
+		status: warmupStatus{
+			Tenant:     userID,
+			MinTime:    mint,
+			MaxTime:    maxt,
+			IndexCache: warmIndexCache,
+			State:      warmupStateRunning,
+			StartedAt:  time.Now(),
+		},
+	}
+	u.warmups[userID] = w
+
+	go func() {
+		defer cancel()
+
+		store.warmupBlocks(ctx, mint, maxt, warmIndexCache, w.start, w.blockDone)
+		if ctx.Err() != nil {
+			w.finish(warmupStateCanceled)
+		} else {
+			w.finish(warmupStateDone)
+		}
+
+		status := w.snapshot()
+		level.Info(u.logger).Log("msg", "warmup of tenant blocks completed", "user", userID, "state", status.State, "warmed", status.WarmedBlocks, "failed", status.FailedBlocks, "duration", status.FinishedAt.Sub(status.StartedAt))
+	}()
+
+	return w.snapshot(), nil
+}
+
+// WarmupStatus returns the status of the last warmup of the blocks of the tenant.
+func (u *BucketStores) WarmupStatus(userID string) (warmupStatus, error) {
+	u.warmupsMu.Lock()
+	defer u.warmupsMu.Unlock()
+
+	w, ok := u.warmups[userID]
+	if !ok {
+		return warmupStatus{}, errWarmupNotFound
+	}
+	return w.snapshot(), nil
+}
+
+// cancelWarmups cancels the running warmups of all tenants.
+func (u *BucketStores) cancelWarmups() {
+	u.warmupsMu.Lock()
+	defer u.warmupsMu.Unlock()
+
+	for _, w := range u.warmups {
+		w.cancel()
+	}
+}
+
+// removeWarmup cancels and forgets the warmup of the tenant, if any.
+func (u *BucketStores) removeWarmup(userID string) {
+	u.warmupsMu.Lock()
+	defer u.warmupsMu.Unlock()
+
+	if w, ok := u.warmups[userID]; ok {
+		w.cancel()
+		delete(u.warmups, userID)
+	}
+}
+
+// warmupBlocks warms up the blocks overlapping [mint, maxt], calling onStart with the number of blocks to
+// warm up, and then onBlock once each block has been warmed up. The warmup of a block failing doesn't stop
+// the warmup of the other blocks.
+func (s *BucketStore) warmupBlocks(ctx context.Context, mint, maxt int64, warmIndexCache bool, onStart func(int), onBlock func(ulid.ULID, error)) {
+	// The index readers are created while holding the lock, so that the blocks are not closed while warmed up.
+	s.mtx.RLock()
+	var readers []*bucketIndexReader
+	for _, b := range s.blocks {
+		if b.overlapsClosedInterval(mint, maxt) {
+			readers = append(readers, b.indexReader())
+		}
+	}
+	s.mtx.RUnlock()
+
+	onStart(len(readers))
+
+	_ = concurrency.ForEachJob(ctx, len(readers), s.blockSyncConcurrency, func(ctx context.Context, idx int) error {
+		indexr := readers[idx]
+		onBlock(indexr.block.meta.ULID, s.warmupBlock(ctx, indexr, warmIndexCache))
+		return nil
+	})
+
+	// The readers of the blocks not warmed up because the warmup has been canceled need to be closed too.
+	for _, indexr := range readers {
+		runutil.CloseWithLogOnErr(s.logger, indexr, "warmup")
+	}
+}
+
+// warmupBlock loads the index-header of the block and, if warmIndexCache is true, populates the index
+// cache with the label names, the metric names and their postings.
+func (s *BucketStore) warmupBlock(ctx context.Context, indexr *bucketIndexReader, warmIndexCache bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Any index-header read loads a lazy loaded index-header.
+	if _, err := indexr.block.indexHeaderReader.IndexVersion(); err != nil {
+		return errors.Wrap(err, "load index-header")
+	}
+	if !warmIndexCache {
+		return nil
+	}
+
+	stats := newSafeQueryStats()
+	if _, err := blockLabelNames(ctx, indexr, nil, s.seriesLimiterFactory(s.metrics.queriesDropped.WithLabelValues("series")), s.logger); err != nil {
+		return errors.Wrap(err, "label names")
+	}
+	metricNames, err := blockLabelValues(ctx, indexr, labels.MetricName, nil, s.logger, stats)
+	if err != nil {
+		return errors.Wrap(err, "metric names")
+	}
+
+	keys := make([]labels.Label, 0, len(metricNames))
+	for _, name := range metricNames {
+		keys = append(keys, labels.Label{Name: labels.MetricName, Value: name})
+	}
+	if _, err := indexr.FetchPostings(ctx, keys, stats); err != nil {
+		return errors.Wrap(err, "metric names postings")
+	}
+	return nil
+}
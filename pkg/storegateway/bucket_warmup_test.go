@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/bucket/filesystem"
+)
+
+func TestBucketStores_Warmup(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	cfg := prepareStorageConfig(t)
+	cfg.BucketStore.IndexHeaderLazyLoadingEnabled = true
+	cfg.BucketStore.IndexHeaderLazyLoadingIdleTimeout = time.Minute
+
+	storageDir := t.TempDir()
+	generateStorageBlock(t, storageDir, userID, "series_1", 0, 100, 1)
+	generateStorageBlock(t, storageDir, userID, "series_2", 1000, 1100, 1)
+
+	bucket, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	stores, err := NewBucketStores(cfg, newNoShardingStrategy(), bucket, defaultLimitsOverrides(t), mockLoggingLevel(), log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	require.NoError(t, stores.InitialSync(ctx))
+
+	_, err = stores.WarmupStatus(userID)
+	assert.ErrorIs(t, err, errWarmupNotFound)
+	_, err = stores.StartWarmup("user-2", 0, 500, true)
+	assert.ErrorIs(t, err, errBucketStoreNotFound)
+
+	// Warm up the first block only.
+	status, err := stores.StartWarmup(userID, 0, 500, true)
+	require.NoError(t, err)
+	assert.Equal(t, warmupStateRunning, status.State)
+
+	require.Eventually(t, func() bool {
+		status, err = stores.WarmupStatus(userID)
+		return err == nil && status.State != warmupStateRunning
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, warmupStateDone, status.State)
+	assert.Equal(t, 1, status.TotalBlocks)
+	assert.Equal(t, 1, status.WarmedBlocks)
+	assert.Equal(t, 0, status.FailedBlocks)
+	assert.Empty(t, status.Errors)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_store_indexheader_lazy_load_total Total number of index-header lazy load operations.
+		# TYPE cortex_bucket_store_indexheader_lazy_load_total counter
+		cortex_bucket_store_indexheader_lazy_load_total 1
+	`), "cortex_bucket_store_indexheader_lazy_load_total"))
+
+	// The postings of the metric names of the warmed up block have been cached.
+	store := stores.getStore(userID)
+	for id, b := range store.blocks {
+		metricName := labels.Label{Name: labels.MetricName, Value: "series_1"}
+		if b.meta.MinTime >= 1000 {
+			metricName.Value = "series_2"
+		}
+		hits, _ := stores.indexCache.FetchMultiPostings(ctx, userID, id, []labels.Label{metricName})
+		assert.Equal(t, b.meta.MinTime < 1000, len(hits) == 1, "block %s", id)
+	}
+
+	// A new warmup can be started once the previous one has completed.
+	_, err = stores.StartWarmup(userID, 0, 500, false)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		status, err = stores.WarmupStatus(userID)
+		return err == nil && status.State == warmupStateDone
+	}, 5*time.Second, 10*time.Millisecond)
+}
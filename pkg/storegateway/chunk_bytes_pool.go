@@ -18,6 +18,7 @@ type chunkBytesPool struct {
 	// Metrics.
 	requestedBytes prometheus.Counter
 	returnedBytes  prometheus.Counter
+	releasedBytes  prometheus.Counter
 }
 
 func newChunkBytesPool(minBucketSize, maxBucketSize int, maxChunkPoolBytes uint64, reg prometheus.Registerer) (*chunkBytesPool, error) {
@@ -26,6 +27,21 @@ func newChunkBytesPool(minBucketSize, maxBucketSize int, maxChunkPoolBytes uint6
 		return nil, err
 	}
 
+	// The pool already tracks the used bytes and allocations, so we expose them without adding
+	// any overhead to Get() and Put().
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cortex_bucket_store_chunk_pool_used_bytes",
+		Help: "Bytes got from the chunk bytes pool and not put back yet.",
+	}, func() float64 {
+		return float64(upstream.UsedBytes())
+	})
+	promauto.With(reg).NewCounterFunc(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_chunk_pool_allocations_total",
+		Help: "Total number of buffers allocated by the chunk bytes pool because no pooled buffer could be reused.",
+	}, func() float64 {
+		return float64(upstream.Allocations())
+	})
+
 	return &chunkBytesPool{
 		pool: upstream,
 		requestedBytes: promauto.With(reg).NewCounter(prometheus.CounterOpts{
@@ -36,6 +52,10 @@ func newChunkBytesPool(minBucketSize, maxBucketSize int, maxChunkPoolBytes uint6
 			Name: "cortex_bucket_store_chunk_pool_returned_bytes_total",
 			Help: "Total bytes returned by the chunk bytes pool.",
 		}),
+		releasedBytes: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_bucket_store_chunk_pool_released_bytes_total",
+			Help: "Total bytes put back into the chunk bytes pool.",
+		}),
 	}, nil
 }
 
@@ -52,5 +72,8 @@ func (p *chunkBytesPool) Get(sz int) (*[]byte, error) {
 }
 
 func (p *chunkBytesPool) Put(b *[]byte) {
+	if b != nil {
+		p.releasedBytes.Add(float64(cap(*b)))
+	}
 	p.pool.Put(b)
 }
@@ -37,5 +37,53 @@ func TestChunkBytesPool_Get(t *testing.T) {
 		# HELP cortex_bucket_store_chunk_pool_returned_bytes_total Total bytes returned by the chunk bytes pool.
 		# TYPE cortex_bucket_store_chunk_pool_returned_bytes_total counter
 		cortex_bucket_store_chunk_pool_returned_bytes_total %d
-	`, mimir_tsdb.EstimatedMaxChunkSize*2, mimir_tsdb.EstimatedMaxChunkSize*3))))
+
+		# HELP cortex_bucket_store_chunk_pool_released_bytes_total Total bytes put back into the chunk bytes pool.
+		# TYPE cortex_bucket_store_chunk_pool_released_bytes_total counter
+		cortex_bucket_store_chunk_pool_released_bytes_total 0
+
+		# HELP cortex_bucket_store_chunk_pool_used_bytes Bytes got from the chunk bytes pool and not put back yet.
+		# TYPE cortex_bucket_store_chunk_pool_used_bytes gauge
+		cortex_bucket_store_chunk_pool_used_bytes %d
+
+		# HELP cortex_bucket_store_chunk_pool_allocations_total Total number of buffers allocated by the chunk bytes pool because no pooled buffer could be reused.
+		# TYPE cortex_bucket_store_chunk_pool_allocations_total counter
+		cortex_bucket_store_chunk_pool_allocations_total 2
+	`, mimir_tsdb.EstimatedMaxChunkSize*2, mimir_tsdb.EstimatedMaxChunkSize*3, mimir_tsdb.EstimatedMaxChunkSize*3))))
+}
+
+func TestChunkBytesPool_Put(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	p, err := newChunkBytesPool(mimir_tsdb.ChunkPoolDefaultMinBucketSize, mimir_tsdb.ChunkPoolDefaultMaxBucketSize, 0, reg)
+	require.NoError(t, err)
+
+	first, err := p.Get(mimir_tsdb.EstimatedMaxChunkSize)
+	require.NoError(t, err)
+	second, err := p.Get(mimir_tsdb.EstimatedMaxChunkSize)
+	require.NoError(t, err)
+
+	p.Put(first)
+	p.Put(nil)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(fmt.Sprintf(`
+		# HELP cortex_bucket_store_chunk_pool_released_bytes_total Total bytes put back into the chunk bytes pool.
+		# TYPE cortex_bucket_store_chunk_pool_released_bytes_total counter
+		cortex_bucket_store_chunk_pool_released_bytes_total %d
+
+		# HELP cortex_bucket_store_chunk_pool_used_bytes Bytes got from the chunk bytes pool and not put back yet.
+		# TYPE cortex_bucket_store_chunk_pool_used_bytes gauge
+		cortex_bucket_store_chunk_pool_used_bytes %d
+	`, cap(*first), cap(*second))), "cortex_bucket_store_chunk_pool_released_bytes_total", "cortex_bucket_store_chunk_pool_used_bytes"))
+
+	p.Put(second)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(fmt.Sprintf(`
+		# HELP cortex_bucket_store_chunk_pool_released_bytes_total Total bytes put back into the chunk bytes pool.
+		# TYPE cortex_bucket_store_chunk_pool_released_bytes_total counter
+		cortex_bucket_store_chunk_pool_released_bytes_total %d
+
+		# HELP cortex_bucket_store_chunk_pool_used_bytes Bytes got from the chunk bytes pool and not put back yet.
+		# TYPE cortex_bucket_store_chunk_pool_used_bytes gauge
+		cortex_bucket_store_chunk_pool_used_bytes 0
+	`, cap(*first)+cap(*second))), "cortex_bucket_store_chunk_pool_released_bytes_total", "cortex_bucket_store_chunk_pool_used_bytes"))
 }
@@ -0,0 +1,93 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"sync"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+)
+
+const (
+	// chunkLengthEstimatorMinObservations is the min number of chunks observed in a segment file
+	// before their lengths are used to estimate the length of the next chunks to read.
+	chunkLengthEstimatorMinObservations = 100
+
+	// chunkLengthEstimatorPercentile is the percentile of the observed chunk lengths used as estimate.
+	// The chunks longer than the estimate are refetched with a dedicated range read.
+	chunkLengthEstimatorPercentile = 99
+)
+
+// chunkLengthBuckets are the upper bounds of the buckets of observed chunk lengths. The last bucket
+// is mimir_tsdb.EstimatedMaxChunkSize, which is the estimate used without enough observations.
+var chunkLengthBuckets = []int{256, 384, 512, 768, 1024, 1536, 2048, 3072, 4096, 6144, 8192, 12288, mimir_tsdb.EstimatedMaxChunkSize}
+
+// chunkLengthEstimator estimates the length of the chunks of each segment file of a block from
+// the length of the chunks read so far, so that the range reads can be sized based on the actual
+// chunks instead of always assuming the max chunk size.
+type chunkLengthEstimator struct {
+	mtx  sync.Mutex
+	seqs map[int]*chunkLengthHistogram
+}
+
+// chunkLengthHistogram counts the observed chunk lengths by bucket.
+type chunkLengthHistogram struct {
+	counts   []uint64
+	total    uint64
+	estimate int
+}
+
+func newChunkLengthEstimator() *chunkLengthEstimator {
+	return &chunkLengthEstimator{seqs: map[int]*chunkLengthHistogram{}}
+}
+
+// observe records the length of a chunk read from the segment file seq. The length includes the chunk
+// data length, the encoding and the chunk data.
+func (e *chunkLengthEstimator) observe(seq, length int) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	h, ok := e.seqs[seq]
+	if !ok {
+		h = &chunkLengthHistogram{counts: make([]uint64, len(chunkLengthBuckets)), estimate: mimir_tsdb.EstimatedMaxChunkSize}
+		e.seqs[seq] = h
+	}
+
+	bucket := len(chunkLengthBuckets) - 1
+	for i, upper := range chunkLengthBuckets {
+		if length <= upper {
+			bucket = i
+			break
+		}
+	}
+	h.counts[bucket]++
+	h.total++
+
+	if h.total >= chunkLengthEstimatorMinObservations {
+		h.estimate = h.percentile(chunkLengthEstimatorPercentile)
+	}
+}
+
+// estimate returns the estimated max length of the chunks of the segment file seq.
+func (e *chunkLengthEstimator) estimate(seq int) int {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if h, ok := e.seqs[seq]; ok {
+		return h.estimate
+	}
+	return mimir_tsdb.EstimatedMaxChunkSize
+}
+
+// percentile returns the upper bound of the bucket containing the p-th percentile of the observed lengths.
+func (h *chunkLengthHistogram) percentile(p uint64) int {
+	threshold := (h.total*p + 99) / 100
+	var count uint64
+	for i, c := range h.counts {
+		count += c
+		if count >= threshold {
+			return chunkLengthBuckets[i]
+		}
+	}
+	return chunkLengthBuckets[len(chunkLengthBuckets)-1]
+}
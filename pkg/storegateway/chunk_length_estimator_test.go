@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+)
+
+func TestChunkLengthEstimator(t *testing.T) {
+	e := newChunkLengthEstimator()
+	assert.Equal(t, mimir_tsdb.EstimatedMaxChunkSize, e.estimate(0))
+
+	// The estimate doesn't change until enough chunks have been observed.
+	for i := 0; i < chunkLengthEstimatorMinObservations-1; i++ {
+		e.observe(0, 100)
+	}
+	assert.Equal(t, mimir_tsdb.EstimatedMaxChunkSize, e.estimate(0))
+
+	e.observe(0, 300)
+	assert.Equal(t, 256, e.estimate(0))
+
+	// Once more than 1% of the chunks are longer, the estimate covers the 99th percentile.
+	e.observe(0, 1000)
+	assert.Equal(t, 384, e.estimate(0))
+
+	// Chunks longer than the max chunk size are tracked in the last bucket.
+	for i := 0; i < chunkLengthEstimatorMinObservations; i++ {
+		e.observe(0, 2*mimir_tsdb.EstimatedMaxChunkSize)
+	}
+	assert.Equal(t, mimir_tsdb.EstimatedMaxChunkSize, e.estimate(0))
+
+	// Each segment file has its own estimate.
+	assert.Equal(t, mimir_tsdb.EstimatedMaxChunkSize, e.estimate(1))
+}
@@ -0,0 +1,203 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/objstore"
+)
+
+const (
+	// chunkRangeHedgerMinObservations is the min number of range reads observed before their latency
+	// is used to compute the hedging delay. No range read is hedged until then.
+	chunkRangeHedgerMinObservations = 100
+
+	// chunkRangeHedgerMaxObservations is the number of observations at which the observed counts are
+	// halved, so that the hedging delay follows the recent latency of the bucket.
+	chunkRangeHedgerMaxObservations = 10000
+)
+
+// chunkRangeLatencyBuckets are the upper bounds of the buckets of observed range read latencies. The
+// hedging delay is the upper bound of the bucket containing the configured percentile.
+var chunkRangeLatencyBuckets = []time.Duration{
+	5 * time.Millisecond, 10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond, 50 * time.Millisecond,
+	75 * time.Millisecond, 100 * time.Millisecond, 150 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond,
+	500 * time.Millisecond, 750 * time.Millisecond, time.Second, 1500 * time.Millisecond, 2 * time.Second,
+	3 * time.Second, 5 * time.Second, 10 * time.Second,
+}
+
+// chunkRangeHedger hedges the chunk range reads from the bucket: when a range read doesn't return
+// within the configured percentile of the latency of the range reads observed so far, a second
+// identical range read is issued and the first one to return is used, while the other one is canceled.
+// It's shared by all the BucketStores of a store-gateway.
+type chunkRangeHedger struct {
+	percentile float64
+
+	mtx    sync.Mutex
+	counts []uint64
+	total  uint64
+	delay  time.Duration
+
+	hedgedRequests    prometheus.Counter
+	hedgedRequestsWon prometheus.Counter
+}
+
+func newChunkRangeHedger(percentile float64, reg prometheus.Registerer) *chunkRangeHedger {
+	return &chunkRangeHedger{
+		percentile: percentile,
+		counts:     make([]uint64, len(chunkRangeLatencyBuckets)),
+		hedgedRequests: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_bucket_stores_chunk_range_hedged_requests_total",
+			Help: "Total number of hedged chunk range reads issued to the bucket because the original range read was slower than the hedging latency percentile.",
+		}),
+		hedgedRequestsWon: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_bucket_stores_chunk_range_hedged_requests_won_total",
+			Help: "Total number of hedged chunk range reads which returned before the original range read.",
+		}),
+	}
+}
+
+// getRange reads the range of the object name, hedging the range read if it's slower than the hedging delay.
+func (h *chunkRangeHedger) getRange(ctx context.Context, bkt objstore.BucketReader, name string, off, length int64) (io.ReadCloser, error) {
+	delay := h.hedgeDelay()
+	if delay == 0 {
+		return h.observedGetRange(ctx, bkt, name, off, length)
+	}
+
+	type result struct {
+		reader io.ReadCloser
+		err    error
+		hedged bool
+	}
+
+	// Buffered, so that the request which isn't used doesn't block once getRange has returned.
+	results := make(chan result, 2)
+	cancels := map[bool]context.CancelFunc{}
+	issue := func(hedged bool) {
+		reqCtx, cancel := context.WithCancel(ctx)
+		cancels[hedged] = cancel
+		go func() {
+			reader, err := h.observedGetRange(reqCtx, bkt, name, off, length)
+			if err != nil {
+				cancel()
+				results <- result{err: err, hedged: hedged}
+				return
+			}
+			results <- result{reader: &cancelOnCloseReader{ReadCloser: reader, cancel: cancel}, hedged: hedged}
+		}()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	issue(false)
+	inflight := 1
+	for {
+		select {
+		case <-timer.C:
+			issue(true)
+			inflight++
+			h.hedgedRequests.Inc()
+
+		case res := <-results:
+			inflight--
+			if res.err != nil {
+				// Errors are not hedged: if the original range read fails before the hedging delay
+				// the error is returned, otherwise the other range read is waited for.
+				if inflight > 0 {
+					continue
+				}
+				return nil, res.err
+			}
+
+			if res.hedged {
+				h.hedgedRequestsWon.Inc()
+			}
+			if inflight > 0 {
+				cancels[!res.hedged]()
+				go func() {
+					if other := <-results; other.err == nil {
+						_ = other.reader.Close()
+					}
+				}()
+			}
+			return res.reader, nil
+		}
+	}
+}
+
+// observedGetRange reads the range of the object name, and observes the latency of successful range reads.
+func (h *chunkRangeHedger) observedGetRange(ctx context.Context, bkt objstore.BucketReader, name string, off, length int64) (io.ReadCloser, error) {
+	start := time.Now()
+	reader, err := bkt.GetRange(ctx, name, off, length)
+	if err == nil {
+		h.observe(time.Since(start))
+	}
+	return reader, err
+}
+
+// observe records the latency of a range read, from issuing it to getting the reader.
+func (h *chunkRangeHedger) observe(latency time.Duration) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	bucket := len(chunkRangeLatencyBuckets) - 1
+	for i, upper := range chunkRangeLatencyBuckets {
+		if latency <= upper {
+			bucket = i
+			break
+		}
+	}
+	h.counts[bucket]++
+	h.total++
+
+	if h.total >= chunkRangeHedgerMaxObservations {
+		h.total = 0
+		for i := range h.counts {
+			h.counts[i] /= 2
+			h.total += h.counts[i]
+		}
+	}
+	if h.total >= chunkRangeHedgerMinObservations {
+		h.delay = h.latencyPercentile()
+	}
+}
+
+// hedgeDelay returns the time after which a range read is hedged, or 0 if range reads are not hedged yet.
+func (h *chunkRangeHedger) hedgeDelay() time.Duration {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	return h.delay
+}
+
+// latencyPercentile returns the upper bound of the bucket containing the configured percentile of the observed latencies.
+func (h *chunkRangeHedger) latencyPercentile() time.Duration {
+	threshold := float64(h.total) * h.percentile / 100
+	var count uint64
+	for i, c := range h.counts {
+		count += c
+		if float64(count) >= threshold {
+			return chunkRangeLatencyBuckets[i]
+		}
+	}
+	return chunkRangeLatencyBuckets[len(chunkRangeLatencyBuckets)-1]
+}
+
+// cancelOnCloseReader cancels the context of the range read once its reader is closed.
+type cancelOnCloseReader struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelOnCloseReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
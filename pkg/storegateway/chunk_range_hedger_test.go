@@ -0,0 +1,182 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+	"go.uber.org/atomic"
+)
+
+func TestChunkRangeHedger_hedgeDelay(t *testing.T) {
+	h := newChunkRangeHedger(99, nil)
+	assert.Equal(t, time.Duration(0), h.hedgeDelay())
+
+	// Range reads are not hedged until enough range reads have been observed.
+	for i := 0; i < chunkRangeHedgerMinObservations-1; i++ {
+		h.observe(8 * time.Millisecond)
+	}
+	assert.Equal(t, time.Duration(0), h.hedgeDelay())
+
+	h.observe(40 * time.Millisecond)
+	assert.Equal(t, 10*time.Millisecond, h.hedgeDelay())
+
+	// Once more than 1% of the range reads are slower, the delay covers the 99th percentile.
+	h.observe(40 * time.Millisecond)
+	assert.Equal(t, 50*time.Millisecond, h.hedgeDelay())
+
+	// Latencies higher than the last bucket are tracked in the last bucket.
+	for i := 0; i < chunkRangeHedgerMinObservations; i++ {
+		h.observe(time.Minute)
+	}
+	assert.Equal(t, 10*time.Second, h.hedgeDelay())
+
+	// The observed counts are halved once the max observations are reached, so that
+	// the delay follows the recent latency.
+	for i := 0; i < chunkRangeHedgerMaxObservations; i++ {
+		h.observe(8 * time.Millisecond)
+	}
+	assert.Less(t, h.total, uint64(chunkRangeHedgerMaxObservations))
+	assert.Equal(t, 10*time.Millisecond, h.hedgeDelay())
+}
+
+func TestChunkRangeHedger_getRange(t *testing.T) {
+	const content = "0123456789"
+
+	tests := map[string]struct {
+		latencies        map[int32]time.Duration
+		failingRangeRead int32
+		expectedErr      bool
+		expectedHedged   int
+		expectedWon      int
+		expectedCalls    int32
+	}{
+		"should not hedge a range read faster than the hedging delay": {
+			expectedCalls: 1,
+		},
+		"should hedge a range read slower than the hedging delay, and use the hedged range read": {
+			latencies:      map[int32]time.Duration{1: time.Second},
+			expectedHedged: 1,
+			expectedWon:    1,
+			expectedCalls:  2,
+		},
+		"should use the original range read if it returns before the hedged one": {
+			latencies:      map[int32]time.Duration{1: 20 * time.Millisecond, 2: time.Second},
+			expectedHedged: 1,
+			expectedCalls:  2,
+		},
+		"should not hedge a range read failing before the hedging delay": {
+			failingRangeRead: 1,
+			expectedErr:      true,
+			expectedCalls:    1,
+		},
+		"should use the hedged range read if the original one fails after the hedging delay": {
+			latencies:        map[int32]time.Duration{1: 20 * time.Millisecond, 2: 50 * time.Millisecond},
+			failingRangeRead: 1,
+			expectedHedged:   1,
+			expectedWon:      1,
+			expectedCalls:    2,
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			bkt := &hedgedRangeReadsBucket{
+				Bucket:           objstore.NewInMemBucket(),
+				latencies:        testData.latencies,
+				failingRangeRead: testData.failingRangeRead,
+			}
+			require.NoError(t, bkt.Upload(context.Background(), "chunks", strings.NewReader(content)))
+
+			reg := prometheus.NewPedanticRegistry()
+			h := newChunkRangeHedger(99, reg)
+			for i := 0; i < chunkRangeHedgerMinObservations; i++ {
+				h.observe(time.Millisecond)
+			}
+			require.Equal(t, 5*time.Millisecond, h.hedgeDelay())
+
+			reader, err := h.getRange(context.Background(), bkt, "chunks", 2, 4)
+			if testData.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				data, err := io.ReadAll(reader)
+				require.NoError(t, err)
+				require.NoError(t, reader.Close())
+				assert.Equal(t, content[2:6], string(data))
+			}
+
+			assert.Equal(t, testData.expectedCalls, bkt.calls.Load())
+			assert.Equal(t, float64(testData.expectedHedged), testutil.ToFloat64(h.hedgedRequests))
+			assert.Equal(t, float64(testData.expectedWon), testutil.ToFloat64(h.hedgedRequestsWon))
+
+			// The range read which isn't used is canceled and its reader closed.
+			assert.Eventually(t, func() bool {
+				return bkt.openReaders.Load() == 0
+			}, time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+// hedgedRangeReadsBucket counts the range reads, and can make one of them slow or failing.
+type hedgedRangeReadsBucket struct {
+	objstore.Bucket
+
+	calls       atomic.Int32
+	openReaders atomic.Int32
+
+	// latencies are the latencies of the range reads by number (starting from 1). A range read
+	// returns after its latency, unless its context is canceled before.
+	latencies map[int32]time.Duration
+
+	// failingRangeRead is the number of the range read (starting from 1) which fails. 0 to never fail.
+	failingRangeRead int32
+}
+
+func (b *hedgedRangeReadsBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	call := b.calls.Inc()
+	if latency, ok := b.latencies[call]; ok {
+		select {
+		case <-time.After(latency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if call == b.failingRangeRead {
+		return nil, errors.New("range read failed")
+	}
+
+	rc, err := b.Bucket.GetRange(ctx, name, off, length)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	b.openReaders.Inc()
+	return struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: bytes.NewReader(data),
+		Closer: closerFunc(func() error {
+			b.openReaders.Dec()
+			return rc.Close()
+		}),
+	}, nil
+}
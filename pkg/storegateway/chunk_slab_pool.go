@@ -0,0 +1,161 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
+)
+
+// chunkSlabPoolShards is the number of shards of the chunkSlabPool. Each shard has its own lock,
+// so that the chunk readers of concurrent queries don't contend on a single lock.
+const chunkSlabPoolShards = 16
+
+// chunkSlabSizes are the sizes of the slabs pooled by the chunkSlabPool. Slabs larger than the
+// last size are allocated on demand and not pooled.
+var chunkSlabSizes = []int{16 * 1024, 64 * 1024, 256 * 1024, 1024 * 1024}
+
+// chunkSlabPool is a sharded pool of the slabs which the chunk readers copy the loaded chunks to, shared
+// by the chunk readers of all the tenants. A chunk reader gets slabs while loading the chunks of a Series()
+// call, and puts them back once the chunks have been sent, so that the slabs are reused across Series()
+// calls instead of being allocated for each of them.
+type chunkSlabPool struct {
+	shards [chunkSlabPoolShards]chunkSlabPoolShard
+
+	// next is used to spread the slabs got and put across the shards.
+	next atomic.Uint64
+
+	// maxShardRetainedBytes is the max size of the slabs retained by each shard.
+	maxShardRetainedBytes int
+
+	// Metrics.
+	requests    prometheus.Counter
+	hits        prometheus.Counter
+	reusedBytes prometheus.Counter
+}
+
+type chunkSlabPoolShard struct {
+	mtx           sync.Mutex
+	free          [][]*[]byte // By slab size.
+	retainedBytes int
+}
+
+func newChunkSlabPool(maxRetainedBytes uint64, reg prometheus.Registerer) *chunkSlabPool {
+	p := &chunkSlabPool{
+		maxShardRetainedBytes: int(maxRetainedBytes / chunkSlabPoolShards),
+		requests: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_bucket_store_chunk_slab_pool_requests_total",
+			Help: "Total number of slabs requested to the chunk slab pool.",
+		}),
+		hits: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_bucket_store_chunk_slab_pool_hits_total",
+			Help: "Total number of slabs requested to the chunk slab pool which have been reused instead of allocated.",
+		}),
+		reusedBytes: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_bucket_store_chunk_slab_pool_reused_bytes_total",
+			Help: "Total bytes of the slabs reused from the chunk slab pool instead of being allocated.",
+		}),
+	}
+	for i := range p.shards {
+		p.shards[i].free = make([][]*[]byte, len(chunkSlabSizes))
+	}
+
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cortex_bucket_store_chunk_slab_pool_retained_bytes",
+		Help: "Bytes of the slabs retained by the chunk slab pool to be reused.",
+	}, func() float64 {
+		return float64(p.retainedBytes())
+	})
+
+	return p
+}
+
+// get returns an empty slab with a capacity of at least sz bytes. It's safe to call get on a nil pool,
+// in which case the slab is allocated.
+func (p *chunkSlabPool) get(sz int) *[]byte {
+	idx := chunkSlabSizeIndex(sz)
+	if idx < 0 {
+		b := make([]byte, 0, sz)
+		return &b
+	}
+	if p == nil {
+		b := make([]byte, 0, chunkSlabSizes[idx])
+		return &b
+	}
+
+	p.requests.Inc()
+
+	s := p.shard()
+	s.mtx.Lock()
+	var b *[]byte
+	if n := len(s.free[idx]); n > 0 {
+		b = s.free[idx][n-1]
+		s.free[idx][n-1] = nil
+		s.free[idx] = s.free[idx][:n-1]
+		s.retainedBytes -= cap(*b)
+	}
+	s.mtx.Unlock()
+
+	if b == nil {
+		slab := make([]byte, 0, chunkSlabSizes[idx])
+		return &slab
+	}
+
+	p.hits.Inc()
+	p.reusedBytes.Add(float64(cap(*b)))
+	return b
+}
+
+// put returns a slab to the pool, unless the pool already retains its max size. The slab must not be
+// used after put. It's safe to call put on a nil pool.
+func (p *chunkSlabPool) put(b *[]byte) {
+	if p == nil || b == nil {
+		return
+	}
+
+	// Only the slabs of one of the pooled sizes are retained.
+	idx := chunkSlabSizeIndex(cap(*b))
+	if idx < 0 || chunkSlabSizes[idx] != cap(*b) {
+		return
+	}
+	*b = (*b)[:0]
+
+	s := p.shard()
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.retainedBytes+cap(*b) > p.maxShardRetainedBytes {
+		return
+	}
+	s.free[idx] = append(s.free[idx], b)
+	s.retainedBytes += cap(*b)
+}
+
+func (p *chunkSlabPool) shard() *chunkSlabPoolShard {
+	return &p.shards[p.next.Inc()%chunkSlabPoolShards]
+}
+
+func (p *chunkSlabPool) retainedBytes() int {
+	total := 0
+	for i := range p.shards {
+		s := &p.shards[i]
+		s.mtx.Lock()
+		total += s.retainedBytes
+		s.mtx.Unlock()
+	}
+	return total
+}
+
+// chunkSlabSizeIndex returns the index of the smallest slab size fitting sz bytes, or -1 if sz
+// is larger than all the pooled slab sizes.
+func chunkSlabSizeIndex(sz int) int {
+	for i, size := range chunkSlabSizes {
+		if sz <= size {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,106 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkSlabPool(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	p := newChunkSlabPool(chunkSlabPoolShards*1024*1024, reg)
+
+	// The slab size is the smallest pooled size fitting the requested size.
+	first := p.get(100)
+	assert.Equal(t, 0, len(*first))
+	assert.Equal(t, 16*1024, cap(*first))
+	assert.Equal(t, 64*1024, cap(*p.get(16*1024 + 1)))
+
+	// Slabs larger than the pooled sizes are allocated with the requested size.
+	assert.Equal(t, 2*1024*1024, cap(*p.get(2 * 1024 * 1024)))
+
+	// Put back slabs are reused, once each shard has been tried.
+	*first = append(*first, 1, 2, 3)
+	p.put(first)
+	assert.Equal(t, 16*1024, p.retainedBytes())
+
+	reused := 0
+	for i := 0; i < chunkSlabPoolShards; i++ {
+		slab := p.get(100)
+		require.Equal(t, 0, len(*slab))
+		if slab == first {
+			reused++
+		}
+	}
+	assert.Equal(t, 1, reused)
+	assert.Equal(t, 0, p.retainedBytes())
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_store_chunk_slab_pool_hits_total Total number of slabs requested to the chunk slab pool which have been reused instead of allocated.
+		# TYPE cortex_bucket_store_chunk_slab_pool_hits_total counter
+		cortex_bucket_store_chunk_slab_pool_hits_total 1
+		# HELP cortex_bucket_store_chunk_slab_pool_requests_total Total number of slabs requested to the chunk slab pool.
+		# TYPE cortex_bucket_store_chunk_slab_pool_requests_total counter
+		cortex_bucket_store_chunk_slab_pool_requests_total 18
+		# HELP cortex_bucket_store_chunk_slab_pool_retained_bytes Bytes of the slabs retained by the chunk slab pool to be reused.
+		# TYPE cortex_bucket_store_chunk_slab_pool_retained_bytes gauge
+		cortex_bucket_store_chunk_slab_pool_retained_bytes 0
+		# HELP cortex_bucket_store_chunk_slab_pool_reused_bytes_total Total bytes of the slabs reused from the chunk slab pool instead of being allocated.
+		# TYPE cortex_bucket_store_chunk_slab_pool_reused_bytes_total counter
+		cortex_bucket_store_chunk_slab_pool_reused_bytes_total 16384
+	`)))
+}
+
+func TestChunkSlabPool_ShouldNotRetainMoreThanTheMaxRetainedBytes(t *testing.T) {
+	// Each shard retains up to 2 slabs of the smallest size.
+	p := newChunkSlabPool(chunkSlabPoolShards*32*1024, nil)
+
+	for i := 0; i < 4*chunkSlabPoolShards; i++ {
+		p.put(p.get(100))
+	}
+	assert.LessOrEqual(t, p.retainedBytes(), chunkSlabPoolShards*32*1024)
+
+	// Slabs which don't have one of the pooled sizes are not retained.
+	p = newChunkSlabPool(chunkSlabPoolShards*1024*1024, nil)
+	oddSlab := make([]byte, 0, 1000)
+	p.put(&oddSlab)
+	p.put(p.get(2 * 1024 * 1024))
+	assert.Equal(t, 0, p.retainedBytes())
+}
+
+func TestChunkSlabPool_NilPool(t *testing.T) {
+	var p *chunkSlabPool
+
+	slab := p.get(100)
+	assert.Equal(t, 16*1024, cap(*slab))
+	p.put(slab)
+}
+
+func TestChunkSlabPool_Concurrency(t *testing.T) {
+	p := newChunkSlabPool(chunkSlabPoolShards*1024*1024, nil)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < 10; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < 1000; i++ {
+				slab := p.get((w*1000 + i) % (1024 * 1024))
+				*slab = append(*slab, byte(w))
+				assert.Equal(t, []byte{byte(w)}, *slab)
+				p.put(slab)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, p.retainedBytes(), chunkSlabPoolShards*1024*1024)
+}
@@ -0,0 +1,127 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package chunkscache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/mimir/pkg/cache"
+)
+
+// Range identifies a range of bytes of a segment file of a block.
+type Range struct {
+	BlockID ulid.ULID
+	Seq     int
+	Start   int64
+	Length  int64
+}
+
+// Cache caches the chunk ranges read from the segment files of the blocks.
+type Cache interface {
+	// FetchChunkRange returns the cached bytes of the chunk range r of the tenant, and whether they were found.
+	FetchChunkRange(ctx context.Context, userID string, r Range) ([]byte, bool)
+
+	// StoreChunkRange stores the bytes of the chunk range r of the tenant. The bytes may be retained by the cache.
+	StoreChunkRange(ctx context.Context, userID string, r Range, data []byte)
+
+	// MaxItemSizeBytes returns the max size of a cached chunk range.
+	MaxItemSizeBytes() int64
+
+	// RemoveUser removes the metrics of the tenant.
+	RemoveUser(userID string)
+}
+
+// ChunkRangesCache is a Cache storing the chunk ranges in a generic cache backend.
+type ChunkRangesCache struct {
+	logger           log.Logger
+	cache            cache.Cache
+	ttl              time.Duration
+	maxItemSizeBytes int64
+
+	// Metrics.
+	requests    *prometheus.CounterVec
+	hits        *prometheus.CounterVec
+	hitBytes    *prometheus.CounterVec
+	storedBytes *prometheus.CounterVec
+}
+
+// NewChunkRangesCache makes a new ChunkRangesCache storing the chunk ranges up to maxItemSizeBytes in c for ttl.
+func NewChunkRangesCache(logger log.Logger, c cache.Cache, ttl time.Duration, maxItemSizeBytes int64, reg prometheus.Registerer) *ChunkRangesCache {
+	cc := &ChunkRangesCache{
+		logger:           logger,
+		cache:            c,
+		ttl:              ttl,
+		maxItemSizeBytes: maxItemSizeBytes,
+	}
+
+	cc.requests = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_chunk_ranges_cache_requests_total",
+		Help: "Total number of chunk range requests to the chunk ranges cache.",
+	}, []string{"user"})
+	cc.hits = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_chunk_ranges_cache_hits_total",
+		Help: "Total number of chunk range requests to the chunk ranges cache that were a hit.",
+	}, []string{"user"})
+	cc.hitBytes = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_chunk_ranges_cache_hit_bytes_total",
+		Help: "Total number of bytes of the chunk ranges served from the chunk ranges cache.",
+	}, []string{"user"})
+	cc.storedBytes = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_bucket_store_chunk_ranges_cache_stored_bytes_total",
+		Help: "Total number of bytes of the chunk ranges stored in the chunk ranges cache.",
+	}, []string{"user"})
+
+	level.Info(logger).Log("msg", "created chunk ranges cache", "backend", c.Name())
+
+	return cc
+}
+
+// FetchChunkRange implements Cache.
+func (c *ChunkRangesCache) FetchChunkRange(ctx context.Context, userID string, r Range) ([]byte, bool) {
+	c.requests.WithLabelValues(userID).Inc()
+
+	key := chunkRangeCacheKey(userID, r)
+	data, ok := c.cache.Fetch(ctx, []string{key})[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.hits.WithLabelValues(userID).Inc()
+	c.hitBytes.WithLabelValues(userID).Add(float64(len(data)))
+	return data, true
+}
+
+// StoreChunkRange implements Cache. The chunk ranges larger than the max item size are not stored.
+func (c *ChunkRangesCache) StoreChunkRange(ctx context.Context, userID string, r Range, data []byte) {
+	if int64(len(data)) > c.maxItemSizeBytes {
+		return
+	}
+
+	c.storedBytes.WithLabelValues(userID).Add(float64(len(data)))
+	c.cache.Store(ctx, map[string][]byte{chunkRangeCacheKey(userID, r): data}, c.ttl)
+}
+
+// MaxItemSizeBytes implements Cache.
+func (c *ChunkRangesCache) MaxItemSizeBytes() int64 {
+	return c.maxItemSizeBytes
+}
+
+// RemoveUser implements Cache.
+func (c *ChunkRangesCache) RemoveUser(userID string) {
+	c.requests.DeleteLabelValues(userID)
+	c.hits.DeleteLabelValues(userID)
+	c.hitBytes.DeleteLabelValues(userID)
+	c.storedBytes.DeleteLabelValues(userID)
+}
+
+func chunkRangeCacheKey(userID string, r Range) string {
+	return "CR:" + userID + ":" + r.BlockID.String() + ":" + strconv.Itoa(r.Seq) + ":" + strconv.FormatInt(r.Start, 10) + ":" + strconv.FormatInt(r.Length, 10)
+}
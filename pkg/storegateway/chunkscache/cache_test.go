@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package chunkscache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkRangesCache(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewPedanticRegistry()
+	backend, err := NewInMemoryCache("test", 1024, nil)
+	require.NoError(t, err)
+	c := NewChunkRangesCache(log.NewNopLogger(), backend, time.Hour, 10, reg)
+
+	rng := Range{BlockID: ulid.MustNew(1, nil), Seq: 1, Start: 100, Length: 8}
+	_, ok := c.FetchChunkRange(ctx, "user-1", rng)
+	require.False(t, ok)
+
+	c.StoreChunkRange(ctx, "user-1", rng, []byte("12345678"))
+	data, ok := c.FetchChunkRange(ctx, "user-1", rng)
+	require.True(t, ok)
+	assert.Equal(t, []byte("12345678"), data)
+
+	// The chunk ranges are cached per tenant, segment file and offsets.
+	for _, other := range []Range{
+		{BlockID: rng.BlockID, Seq: 2, Start: 100, Length: 8},
+		{BlockID: rng.BlockID, Seq: 1, Start: 101, Length: 8},
+		{BlockID: rng.BlockID, Seq: 1, Start: 100, Length: 7},
+		{BlockID: ulid.MustNew(2, nil), Seq: 1, Start: 100, Length: 8},
+	} {
+		_, ok = c.FetchChunkRange(ctx, "user-1", other)
+		assert.False(t, ok)
+	}
+	_, ok = c.FetchChunkRange(ctx, "user-2", rng)
+	assert.False(t, ok)
+
+	// The chunk ranges larger than the max item size are not cached.
+	large := Range{BlockID: rng.BlockID, Seq: 1, Start: 200, Length: 11}
+	c.StoreChunkRange(ctx, "user-1", large, []byte("12345678901"))
+	_, ok = c.FetchChunkRange(ctx, "user-1", large)
+	assert.False(t, ok)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_store_chunk_ranges_cache_hit_bytes_total Total number of bytes of the chunk ranges served from the chunk ranges cache.
+		# TYPE cortex_bucket_store_chunk_ranges_cache_hit_bytes_total counter
+		cortex_bucket_store_chunk_ranges_cache_hit_bytes_total{user="user-1"} 8
+
+		# HELP cortex_bucket_store_chunk_ranges_cache_hits_total Total number of chunk range requests to the chunk ranges cache that were a hit.
+		# TYPE cortex_bucket_store_chunk_ranges_cache_hits_total counter
+		cortex_bucket_store_chunk_ranges_cache_hits_total{user="user-1"} 1
+
+		# HELP cortex_bucket_store_chunk_ranges_cache_requests_total Total number of chunk range requests to the chunk ranges cache.
+		# TYPE cortex_bucket_store_chunk_ranges_cache_requests_total counter
+		cortex_bucket_store_chunk_ranges_cache_requests_total{user="user-1"} 7
+		cortex_bucket_store_chunk_ranges_cache_requests_total{user="user-2"} 1
+
+		# HELP cortex_bucket_store_chunk_ranges_cache_stored_bytes_total Total number of bytes of the chunk ranges stored in the chunk ranges cache.
+		# TYPE cortex_bucket_store_chunk_ranges_cache_stored_bytes_total counter
+		cortex_bucket_store_chunk_ranges_cache_stored_bytes_total{user="user-1"} 8
+	`)))
+
+	c.RemoveUser("user-1")
+	c.RemoveUser("user-2")
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader("")))
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package chunkscache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/simplelru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// InMemoryCache is a cache.Cache keeping the items in memory, evicting the least recently used ones
+// once their total size exceeds the max size.
+type InMemoryCache struct {
+	name         string
+	maxSizeBytes uint64
+	now          func() time.Time
+
+	mtx     sync.Mutex
+	lru     *lru.LRU
+	curSize uint64
+
+	// Metrics.
+	evicted prometheus.Counter
+}
+
+type inMemoryItem struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// NewInMemoryCache makes a new InMemoryCache whose items take up to maxSizeBytes.
+func NewInMemoryCache(name string, maxSizeBytes uint64, reg prometheus.Registerer) (*InMemoryCache, error) {
+	c := &InMemoryCache{
+		name:         name,
+		maxSizeBytes: maxSizeBytes,
+		now:          time.Now,
+	}
+
+	l, err := lru.NewLRU(int(^uint(0)>>1), c.onEvict)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = l
+
+	c.evicted = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "cortex_bucket_store_chunk_ranges_cache_memory_evicted_items_total",
+		Help:        "Total number of items evicted from the in-memory chunk ranges cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "cortex_bucket_store_chunk_ranges_cache_memory_items",
+		Help:        "Current number of items in the in-memory chunk ranges cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		return float64(c.lru.Len())
+	})
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "cortex_bucket_store_chunk_ranges_cache_memory_size_bytes",
+		Help:        "Current size in bytes of the items in the in-memory chunk ranges cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		return float64(c.curSize)
+	})
+
+	return c, nil
+}
+
+func (c *InMemoryCache) onEvict(key, val interface{}) {
+	c.curSize -= entrySize(key.(string), val.(inMemoryItem).data)
+}
+
+// Store implements cache.Cache. The items larger than the max size are not stored.
+func (c *InMemoryCache) Store(_ context.Context, data map[string][]byte, ttl time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	expiresAt := c.now().Add(ttl)
+	for key, val := range data {
+		size := entrySize(key, val)
+		if size > c.maxSizeBytes {
+			continue
+		}
+
+		if _, ok := c.lru.Peek(key); ok {
+			c.lru.Remove(key)
+		}
+		for c.curSize+size > c.maxSizeBytes {
+			if _, _, ok := c.lru.RemoveOldest(); !ok {
+				break
+			}
+			c.evicted.Inc()
+		}
+
+		c.lru.Add(key, inMemoryItem{data: val, expiresAt: expiresAt})
+		c.curSize += size
+	}
+}
+
+// Fetch implements cache.Cache.
+func (c *InMemoryCache) Fetch(_ context.Context, keys []string) map[string][]byte {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	now := c.now()
+	found := make(map[string][]byte, len(keys))
+	for _, key := range keys {
+		val, ok := c.lru.Get(key)
+		if !ok {
+			continue
+		}
+		item := val.(inMemoryItem)
+		if !now.Before(item.expiresAt) {
+			c.lru.Remove(key)
+			continue
+		}
+		found[key] = item.data
+	}
+	return found
+}
+
+// Name implements cache.Cache.
+func (c *InMemoryCache) Name() string {
+	return c.name
+}
+
+func entrySize(key string, data []byte) uint64 {
+	return uint64(len(key) + len(data))
+}
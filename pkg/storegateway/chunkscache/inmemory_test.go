@@ -0,0 +1,67 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package chunkscache
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewPedanticRegistry()
+
+	// Each item takes 10 bytes: 2 for the key and 8 for the data.
+	c, err := NewInMemoryCache("test", 30, reg)
+	require.NoError(t, err)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Store(ctx, map[string][]byte{"k1": []byte("12345678"), "k2": []byte("12345678"), "k3": []byte("12345678")}, time.Hour)
+	assert.Len(t, c.Fetch(ctx, []string{"k1", "k2", "k3"}), 3)
+
+	// The least recently used items are evicted once the max size is exceeded.
+	c.Fetch(ctx, []string{"k1"})
+	c.Store(ctx, map[string][]byte{"k4": []byte("12345678")}, time.Hour)
+	assert.Equal(t, []string{"k1", "k3", "k4"}, sortedKeys(c.Fetch(ctx, []string{"k1", "k2", "k3", "k4"})))
+
+	// Items larger than the max size are not stored.
+	c.Store(ctx, map[string][]byte{"k5": make([]byte, 30)}, time.Hour)
+	assert.Empty(t, c.Fetch(ctx, []string{"k5"}))
+
+	// Expired items are not returned.
+	c.Store(ctx, map[string][]byte{"k1": []byte("1234")}, time.Minute)
+	now = now.Add(time.Minute)
+	assert.Equal(t, []string{"k3", "k4"}, sortedKeys(c.Fetch(ctx, []string{"k1", "k3", "k4"})))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_store_chunk_ranges_cache_memory_evicted_items_total Total number of items evicted from the in-memory chunk ranges cache.
+		# TYPE cortex_bucket_store_chunk_ranges_cache_memory_evicted_items_total counter
+		cortex_bucket_store_chunk_ranges_cache_memory_evicted_items_total{name="test"} 1
+
+		# HELP cortex_bucket_store_chunk_ranges_cache_memory_items Current number of items in the in-memory chunk ranges cache.
+		# TYPE cortex_bucket_store_chunk_ranges_cache_memory_items gauge
+		cortex_bucket_store_chunk_ranges_cache_memory_items{name="test"} 2
+
+		# HELP cortex_bucket_store_chunk_ranges_cache_memory_size_bytes Current size in bytes of the items in the in-memory chunk ranges cache.
+		# TYPE cortex_bucket_store_chunk_ranges_cache_memory_size_bytes gauge
+		cortex_bucket_store_chunk_ranges_cache_memory_size_bytes{name="test"} 20
+	`)))
+}
+
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
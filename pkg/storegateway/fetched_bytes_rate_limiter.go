@@ -0,0 +1,83 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fetchedBytesRateLimits are the per-tenant limits of the fetchedBytesRateLimiter.
+type fetchedBytesRateLimits interface {
+	StoreGatewayFetchedBytesRateLimit(userID string) float64
+	StoreGatewayFetchedBytesBurstSize(userID string) int
+}
+
+// fetchedBytesRateLimiter is a token bucket limiting the rate of the chunk and index bytes fetched from
+// the bucket by a tenant, so that a single tenant can't consume all the object storage bandwidth. The
+// limits are read on each reservation, because they could be live reloaded.
+type fetchedBytesRateLimiter struct {
+	userID  string
+	limits  fetchedBytesRateLimits
+	limited prometheus.Counter
+
+	// limiter is created once the limit is enabled, so that the tenant starts with the whole burst available.
+	mtx     sync.Mutex
+	limiter *rate.Limiter
+}
+
+func newFetchedBytesRateLimiter(userID string, limits fetchedBytesRateLimits, limited prometheus.Counter) *fetchedBytesRateLimiter {
+	return &fetchedBytesRateLimiter{
+		userID:  userID,
+		limits:  limits,
+		limited: limited,
+	}
+}
+
+// reserve reserves num bytes to fetch from the bucket, and returns a resource exhausted error if the tenant
+// exceeded the rate limit. A read larger than the burst size is allowed once all the burst is available.
+// It's safe to call reserve on a nil limiter.
+func (l *fetchedBytesRateLimiter) reserve(num uint64) error {
+	if l == nil {
+		return nil
+	}
+
+	limit := l.limits.StoreGatewayFetchedBytesRateLimit(l.userID)
+	if limit <= 0 {
+		return nil
+	}
+	burst := l.limits.StoreGatewayFetchedBytesBurstSize(l.userID)
+	if burst <= 0 {
+		burst = int(math.Min(math.Ceil(limit), math.MaxInt32))
+	}
+
+	if num > uint64(burst) {
+		num = uint64(burst)
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now()
+	if l.limiter == nil {
+		l.limiter = rate.NewLimiter(rate.Limit(limit), burst)
+	}
+	if l.limiter.Limit() != rate.Limit(limit) {
+		l.limiter.SetLimitAt(now, rate.Limit(limit))
+	}
+	if l.limiter.Burst() != burst {
+		l.limiter.SetBurstAt(now, burst)
+	}
+	if !l.limiter.AllowN(now, int(num)) {
+		l.limited.Inc()
+		return status.Errorf(codes.ResourceExhausted, "exceeded fetched bytes rate limit of %s/s", humanize.IBytes(uint64(limit)))
+	}
+	return nil
+}
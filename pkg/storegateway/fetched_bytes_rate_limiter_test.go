@@ -0,0 +1,85 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestFetchedBytesRateLimiter(t *testing.T) {
+	t.Run("should not limit a nil limiter", func(t *testing.T) {
+		var l *fetchedBytesRateLimiter
+		assert.NoError(t, l.reserve(1024))
+	})
+
+	t.Run("should not limit if the rate limit is disabled", func(t *testing.T) {
+		limited := prometheus.NewCounter(prometheus.CounterOpts{Name: "limited"})
+		l := newFetchedBytesRateLimiter("user-1", &staticFetchedBytesRateLimits{}, limited)
+
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, l.reserve(1024*1024))
+		}
+		assert.Zero(t, testutil.ToFloat64(limited))
+	})
+
+	t.Run("should fail with a resource exhausted error once the burst is consumed", func(t *testing.T) {
+		limited := prometheus.NewCounter(prometheus.CounterOpts{Name: "limited"})
+		l := newFetchedBytesRateLimiter("user-1", &staticFetchedBytesRateLimits{rate: 1, burst: 1000}, limited)
+
+		require.NoError(t, l.reserve(600))
+		require.NoError(t, l.reserve(400))
+
+		err := l.reserve(100)
+		require.Error(t, err)
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, float64(1), testutil.ToFloat64(limited))
+	})
+
+	t.Run("should allow a read larger than the burst size once the whole burst is available", func(t *testing.T) {
+		limited := prometheus.NewCounter(prometheus.CounterOpts{Name: "limited"})
+		l := newFetchedBytesRateLimiter("user-1", &staticFetchedBytesRateLimits{rate: 1, burst: 1000}, limited)
+
+		require.NoError(t, l.reserve(5000))
+		require.Error(t, l.reserve(1))
+	})
+
+	t.Run("should use the rate limit as burst size if the burst size is not set", func(t *testing.T) {
+		limited := prometheus.NewCounter(prometheus.CounterOpts{Name: "limited"})
+		l := newFetchedBytesRateLimiter("user-1", &staticFetchedBytesRateLimits{rate: 1000}, limited)
+
+		require.NoError(t, l.reserve(1000))
+		require.Error(t, l.reserve(100))
+	})
+
+	t.Run("should apply the updated limits", func(t *testing.T) {
+		limited := prometheus.NewCounter(prometheus.CounterOpts{Name: "limited"})
+		limits := &staticFetchedBytesRateLimits{rate: 1, burst: 1000}
+		l := newFetchedBytesRateLimiter("user-1", limits, limited)
+
+		require.NoError(t, l.reserve(1000))
+		require.Error(t, l.reserve(1000))
+
+		limits.rate = 0
+		require.NoError(t, l.reserve(1000))
+	})
+}
+
+type staticFetchedBytesRateLimits struct {
+	rate  float64
+	burst int
+}
+
+func (l staticFetchedBytesRateLimits) StoreGatewayFetchedBytesRateLimit(string) float64 {
+	return l.rate
+}
+
+func (l staticFetchedBytesRateLimits) StoreGatewayFetchedBytesBurstSize(string) int {
+	return l.burst
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/grafana/mimir/pkg/storegateway/storepb"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/activitytracker"
+	"github.com/grafana/mimir/pkg/util/fieldcategory"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
@@ -50,11 +51,14 @@ var (
 // Config holds the store gateway config.
 type Config struct {
 	ShardingRing RingConfig `yaml:"sharding_ring" doc:"description=The hash ring configuration."`
+
+	SecondaryBucket SecondaryBucketConfig `yaml:"secondary_bucket" category:"experimental" doc:"description=The secondary bucket the store-gateway reads the blocks from when reading from the blocks storage bucket fails."`
 }
 
 // RegisterFlags registers the Config flags.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	cfg.ShardingRing.RegisterFlags(f, logger)
+	cfg.SecondaryBucket.RegisterFlagsWithPrefix("store-gateway.secondary-bucket.", f, logger)
 }
 
 // Validate the Config.
@@ -62,10 +66,43 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 	if limits.StoreGatewayTenantShardSize < 0 {
 		return errInvalidTenantShardSize
 	}
+	if err := cfg.SecondaryBucket.Validate(); err != nil {
+		return errors.Wrap(err, "secondary bucket configuration")
+	}
 
 	return nil
 }
 
+// SecondaryBucketConfig holds the config of the secondary bucket, for example a replica of the blocks
+// storage bucket in another region, which the store-gateway falls back to for the blocks reads.
+type SecondaryBucketConfig struct {
+	Enabled       bool `yaml:"enabled"`
+	bucket.Config `yaml:",inline"`
+}
+
+// RegisterFlagsWithPrefix registers the SecondaryBucketConfig flags with the provided prefix.
+func (cfg *SecondaryBucketConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet, logger log.Logger) {
+	registered := util.TrackRegisteredFlags(prefix, f, func(prefix string, f *flag.FlagSet) {
+		f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "If enabled, the store-gateway reads the index-headers and chunks from the secondary bucket when reading them from the blocks storage bucket fails. The secondary bucket is expected to be a replica of the blocks storage bucket, for example in another region. Blocks are always discovered from the blocks storage bucket.")
+		cfg.Config.RegisterFlagsWithPrefix(prefix, f, logger)
+	})
+
+	// The bucket config fields can't be categorized via struct tags, because they're shared with the other buckets.
+	overrides := make(map[string]fieldcategory.Category, len(registered.Flags))
+	for name := range registered.Flags {
+		overrides[prefix+name] = fieldcategory.Experimental
+	}
+	fieldcategory.AddOverrides(overrides)
+}
+
+// Validate the SecondaryBucketConfig.
+func (cfg *SecondaryBucketConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	return cfg.Config.Validate()
+}
+
 // StoreGateway is the Mimir service responsible to expose an API over the bucket
 // where blocks are stored, supporting blocks sharding and replication across a pool
 // of store gateway instances (optional).
@@ -92,7 +129,7 @@ type StoreGateway struct {
 func NewStoreGateway(gatewayCfg Config, storageCfg mimir_tsdb.BlocksStorageConfig, limits *validation.Overrides, logLevel logging.Level, logger log.Logger, reg prometheus.Registerer, tracker *activitytracker.ActivityTracker) (*StoreGateway, error) {
 	var ringStore kv.Client
 
-	bucketClient, err := createBucketClient(storageCfg, logger, reg)
+	bucketClient, err := createBucketClient(storageCfg, gatewayCfg.SecondaryBucket, logger, reg)
 	if err != nil {
 		return nil, err
 	}
@@ -277,6 +314,8 @@ func (g *StoreGateway) running(ctx context.Context) error {
 }
 
 func (g *StoreGateway) stopping(_ error) error {
+	g.stores.cancelWarmups()
+
 	if g.subservices != nil {
 		return services.StopManagerAndAwaitStopped(context.Background(), g.subservices)
 	}
@@ -329,11 +368,20 @@ func requestActivity(ctx context.Context, name string, req interface{}) string {
 	return fmt.Sprintf("%s: user=%q trace=%q request=%v", name, user, traceID, req)
 }
 
-func createBucketClient(cfg mimir_tsdb.BlocksStorageConfig, logger log.Logger, reg prometheus.Registerer) (objstore.Bucket, error) {
+func createBucketClient(cfg mimir_tsdb.BlocksStorageConfig, secondaryCfg SecondaryBucketConfig, logger log.Logger, reg prometheus.Registerer) (objstore.Bucket, error) {
 	bucketClient, err := bucket.NewClient(context.Background(), cfg.Bucket, "store-gateway", logger, reg)
 	if err != nil {
 		return nil, errors.Wrap(err, "create bucket client")
 	}
+	if !secondaryCfg.Enabled {
+		return bucketClient, nil
+	}
+
+	// The secondary bucket client metrics are tracked by a dedicated component, to tell them apart.
+	secondaryClient, err := bucket.NewClient(context.Background(), secondaryCfg.Config, "store-gateway-secondary", logger, reg)
+	if err != nil {
+		return nil, errors.Wrap(err, "create secondary bucket client")
+	}
 
-	return bucketClient, nil
+	return bucket.NewFallbackBucketClient(bucketClient, secondaryClient, logger, prometheus.WrapRegistererWith(prometheus.Labels{"component": "store-gateway"}, reg)), nil
 }
@@ -77,6 +77,19 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			expected: nil,
 		},
+		"should pass if the secondary bucket has an unsupported backend but is disabled": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.SecondaryBucket.Backend = "unknown"
+			},
+			expected: nil,
+		},
+		"should fail if the secondary bucket is enabled with an unsupported backend": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.SecondaryBucket.Enabled = true
+				cfg.SecondaryBucket.Backend = "unknown"
+			},
+			expected: bucket.ErrUnsupportedStorageBackend,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -86,7 +99,7 @@ func TestConfig_Validate(t *testing.T) {
 			flagext.DefaultValues(cfg, limits)
 			testData.setup(cfg, limits)
 
-			assert.Equal(t, testData.expected, cfg.Validate(*limits))
+			assert.ErrorIs(t, cfg.Validate(*limits), testData.expected)
 		})
 	}
 }
@@ -0,0 +1,91 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+// WarmupHandler starts the warmup of the blocks of a tenant on POST, and returns the progress of the last
+// warmup of the tenant on GET.
+func (s *StoreGateway) WarmupHandler(w http.ResponseWriter, req *http.Request) {
+	tenantID := mux.Vars(req)["tenant"]
+	if tenantID == "" {
+		http.Error(w, "Tenant ID can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	if req.Method == http.MethodGet {
+		status, err := s.stores.WarmupStatus(tenantID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		util.WriteJSONResponse(w, status)
+		return
+	}
+
+	if s.State() != services.Running {
+		http.Error(w, "Store-gateway is not running", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := req.ParseForm(); err != nil {
+		http.Error(w, fmt.Sprintf("Can't parse form: %s", err), http.StatusBadRequest)
+		return
+	}
+	mint, err := parseWarmupTime(req.Form.Get("start"), math.MinInt64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid start: %s", err), http.StatusBadRequest)
+		return
+	}
+	maxt, err := parseWarmupTime(req.Form.Get("end"), math.MaxInt64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid end: %s", err), http.StatusBadRequest)
+		return
+	}
+	if maxt < mint {
+		http.Error(w, "End can't be before start", http.StatusBadRequest)
+		return
+	}
+	var warmIndexCache bool
+	if value := req.Form.Get("index_cache"); value != "" {
+		if warmIndexCache, err = strconv.ParseBool(value); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid index_cache: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	status, err := s.stores.StartWarmup(tenantID, mint, maxt, warmIndexCache)
+	switch {
+	case errors.Is(err, errBucketStoreNotFound):
+		http.Error(w, "Tenant blocks are not loaded by this store-gateway", http.StatusNotFound)
+		return
+	case errors.Is(err, errWarmupInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	util.WriteJSONResponse(w, status)
+}
+
+func parseWarmupTime(value string, defaultTime int64) (int64, error) {
+	if value == "" {
+		return defaultTime, nil
+	}
+	return util.ParseTime(value)
+}
@@ -49,6 +49,13 @@ type InMemoryIndexCache struct {
 
 	curSize uint64
 
+	// Returns the max size of the entries of a tenant, 0 for no quota. Nil if quotas are disabled.
+	tenantMaxSize func(userID string) uint64
+
+	// Per-tenant size of the entries, and LRU of their keys used to enforce the tenant quotas.
+	tenantSize map[string]uint64
+	tenantLRU  map[string]*lru.LRU
+
 	evicted          *prometheus.CounterVec
 	requests         *prometheus.CounterVec
 	hits             *prometheus.CounterVec
@@ -57,6 +64,10 @@ type InMemoryIndexCache struct {
 	currentSize      *prometheus.GaugeVec
 	totalCurrentSize *prometheus.GaugeVec
 	overflow         *prometheus.CounterVec
+
+	tenantCurrentSize   *prometheus.GaugeVec
+	tenantQuotaEvicted  *prometheus.CounterVec
+	tenantQuotaOverflow *prometheus.CounterVec
 }
 
 // InMemoryIndexCacheConfig holds the in-memory index cache config.
@@ -65,6 +76,9 @@ type InMemoryIndexCacheConfig struct {
 	MaxSize flagext.Bytes `yaml:"max_size"`
 	// MaxItemSize represents maximum size of single item.
 	MaxItemSize flagext.Bytes `yaml:"max_item_size"`
+	// TenantMaxSize returns the maximum number of bytes the items of a tenant can take, 0 for no quota.
+	// When a tenant exceeds its quota, its own least recently used items are evicted.
+	TenantMaxSize func(userID string) uint64 `yaml:"-"`
 }
 
 // parseInMemoryIndexCacheConfig unmarshals a buffer into a InMemoryIndexCacheConfig with default values.
@@ -99,6 +113,9 @@ func NewInMemoryIndexCacheWithConfig(logger log.Logger, reg prometheus.Registere
 		logger:           logger,
 		maxSizeBytes:     uint64(config.MaxSize),
 		maxItemSizeBytes: uint64(config.MaxItemSize),
+		tenantMaxSize:    config.TenantMaxSize,
+		tenantSize:       map[string]uint64{},
+		tenantLRU:        map[string]*lru.LRU{},
 	}
 
 	c.evicted = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
@@ -149,6 +166,21 @@ func NewInMemoryIndexCacheWithConfig(logger log.Logger, reg prometheus.Registere
 	}, []string{"item_type"})
 	initLabelValuesForAllCacheTypes(c.totalCurrentSize.MetricVec)
 
+	c.tenantCurrentSize = promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "thanos_store_index_cache_tenant_items_size_bytes",
+		Help: "Current byte size of items in the index cache, by tenant.",
+	}, []string{"user"})
+
+	c.tenantQuotaEvicted = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_tenant_quota_items_evicted_total",
+		Help: "Total number of items evicted from the index cache because the tenant exceeded its quota.",
+	}, []string{"user"})
+
+	c.tenantQuotaOverflow = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_tenant_quota_items_overflowed_total",
+		Help: "Total number of items that could not be added to the index cache due to being bigger than the tenant quota.",
+	}, []string{"user"})
+
 	_ = promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
 		Name: "thanos_store_index_cache_max_size_bytes",
 		Help: "Maximum number of bytes to be held in the index cache.",
@@ -190,6 +222,20 @@ func (c *InMemoryIndexCache) onEvict(key, val interface{}) {
 	c.totalCurrentSize.WithLabelValues(typ).Sub(float64(entrySize + k.size()))
 
 	c.curSize -= entrySize
+
+	// Release the entry from the tenant accounting.
+	userID := k.tenant()
+	if tenantLRU, ok := c.tenantLRU[userID]; ok {
+		tenantLRU.Remove(key)
+	}
+	c.tenantSize[userID] -= entrySize
+	if c.tenantSize[userID] == 0 {
+		delete(c.tenantSize, userID)
+		delete(c.tenantLRU, userID)
+		c.tenantCurrentSize.DeleteLabelValues(userID)
+	} else {
+		c.tenantCurrentSize.WithLabelValues(userID).Sub(float64(entrySize))
+	}
 }
 
 func (c *InMemoryIndexCache) get(key cacheKey) ([]byte, bool) {
@@ -203,6 +249,9 @@ func (c *InMemoryIndexCache) get(key cacheKey) ([]byte, bool) {
 	if !ok {
 		return nil, false
 	}
+	if tenantLRU, ok := c.tenantLRU[key.tenant()]; ok {
+		tenantLRU.Get(key)
+	}
 	c.hits.WithLabelValues(typ).Inc()
 	return v.([]byte), true
 }
@@ -218,6 +267,12 @@ func (c *InMemoryIndexCache) set(key cacheKey, val []byte) {
 		return
 	}
 
+	userID := key.tenant()
+	if !c.ensureFitsTenantQuota(userID, size) {
+		c.tenantQuotaOverflow.WithLabelValues(userID).Inc()
+		return
+	}
+
 	if !c.ensureFits(size, typ) {
 		c.overflow.WithLabelValues(typ).Inc()
 		return
@@ -234,6 +289,54 @@ func (c *InMemoryIndexCache) set(key cacheKey, val []byte) {
 	c.totalCurrentSize.WithLabelValues(typ).Add(float64(size + key.size()))
 	c.current.WithLabelValues(typ).Inc()
 	c.curSize += size
+
+	// The tenant LRU is only needed to enforce the quotas.
+	if c.tenantMaxSize != nil {
+		tenantLRU, ok := c.tenantLRU[userID]
+		if !ok {
+			// The values are not stored in the tenant LRU, which only tracks the order the keys have been used.
+			tenantLRU, _ = lru.NewLRU(maxInt, nil)
+			c.tenantLRU[userID] = tenantLRU
+		}
+		tenantLRU.Add(key, nil)
+	}
+	c.tenantSize[userID] += size
+	c.tenantCurrentSize.WithLabelValues(userID).Add(float64(size))
+}
+
+// ensureFitsTenantQuota tries to make sure that the passed slice will fit into the quota of the tenant,
+// evicting the least recently used items of the tenant. Returns true if it will fit.
+func (c *InMemoryIndexCache) ensureFitsTenantQuota(userID string, size uint64) bool {
+	if c.tenantMaxSize == nil {
+		return true
+	}
+	maxSize := c.tenantMaxSize(userID)
+	if maxSize == 0 {
+		return true
+	}
+	if size > maxSize {
+		return false
+	}
+
+	for c.tenantSize[userID]+size > maxSize {
+		tenantLRU, ok := c.tenantLRU[userID]
+		if !ok {
+			break
+		}
+		key, _, ok := tenantLRU.GetOldest()
+		if !ok {
+			break
+		}
+
+		// Removing the key from the cache releases it from the tenant accounting too.
+		if !c.lru.Remove(key) {
+			// The key is not in the cache anymore, so it's just removed from the tenant LRU.
+			tenantLRU.Remove(key)
+			continue
+		}
+		c.tenantQuotaEvicted.WithLabelValues(userID).Inc()
+	}
+	return true
 }
 
 // ensureFits tries to make sure that the passed slice will fit into the LRU cache.
@@ -273,6 +376,9 @@ func (c *InMemoryIndexCache) reset() {
 	c.currentSize.Reset()
 	c.totalCurrentSize.Reset()
 	c.curSize = 0
+	c.tenantSize = map[string]uint64{}
+	c.tenantLRU = map[string]*lru.LRU{}
+	c.tenantCurrentSize.Reset()
 }
 
 func copyString(s string) string {
@@ -382,6 +488,8 @@ type cacheKey interface {
 	typ() string
 	// size is used to keep track of the cache size, it represents the footprint of the cache key in memory.
 	size() uint64
+	// tenant is the tenant the cache entry belongs to, used to enforce the tenant quotas.
+	tenant() string
 }
 
 // cacheKeyPostings implements cacheKey and is used to reference a postings cache entry in the inmemory cache.
@@ -397,6 +505,8 @@ func (c cacheKeyPostings) size() uint64 {
 	return stringSize(c.userID) + ulidSize + stringSize(c.label.Name) + stringSize(c.label.Value)
 }
 
+func (c cacheKeyPostings) tenant() string { return c.userID }
+
 // cacheKeyPostings implements cacheKey and is used to reference a seriesRef cache entry in the inmemory cache.
 type cacheKeySeriesForRef struct {
 	userID string
@@ -410,6 +520,8 @@ func (c cacheKeySeriesForRef) size() uint64 {
 	return stringSize(c.userID) + ulidSize + 8
 }
 
+func (c cacheKeySeriesForRef) tenant() string { return c.userID }
+
 // cacheKeyPostings implements cacheKey and is used to reference an expanded postings cache entry in the inmemory cache.
 type cacheKeyExpandedPostings struct {
 	userID      string
@@ -423,6 +535,8 @@ func (c cacheKeyExpandedPostings) size() uint64 {
 	return stringSize(c.userID) + ulidSize + stringSize(string(c.matchersKey))
 }
 
+func (c cacheKeyExpandedPostings) tenant() string { return c.userID }
+
 type cacheKeySeries struct {
 	userID      string
 	block       ulid.ULID
@@ -438,6 +552,8 @@ func (c cacheKeySeries) size() uint64 {
 	return stringSize(c.userID) + ulidSize + stringSize(string(c.matchersKey)) + stringSize(c.shard)
 }
 
+func (c cacheKeySeries) tenant() string { return c.userID }
+
 type cacheKeyLabelNames struct {
 	userID      string
 	block       ulid.ULID
@@ -452,6 +568,8 @@ func (c cacheKeyLabelNames) size() uint64 {
 	return stringSize(c.userID) + ulidSize + stringSize(string(c.matchersKey))
 }
 
+func (c cacheKeyLabelNames) tenant() string { return c.userID }
+
 type cacheKeyLabelValues struct {
 	userID      string
 	block       ulid.ULID
@@ -467,6 +585,8 @@ func (c cacheKeyLabelValues) size() uint64 {
 	return stringSize(c.userID) + ulidSize + stringSize(c.labelName) + stringSize(string(c.matchersKey))
 }
 
+func (c cacheKeyLabelValues) tenant() string { return c.userID }
+
 func stringSize(s string) uint64 {
 	return stringHeaderSize + uint64(len(s))
 }
@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
 	"github.com/hashicorp/golang-lru/simplelru"
 	"github.com/oklog/ulid"
 	"github.com/prometheus/client_golang/prometheus"
@@ -476,3 +477,57 @@ func TestInMemoryIndexCache_Eviction_WithMetrics(t *testing.T) {
 	assert.Equal(t, float64(5), promtest.ToFloat64(cache.hits.WithLabelValues(cacheTypePostings)))
 	assert.Equal(t, float64(1), promtest.ToFloat64(cache.hits.WithLabelValues(cacheTypeSeriesForRef)))
 }
+
+func TestInMemoryIndexCache_TenantQuota(t *testing.T) {
+	itemSize := uint64(sliceHeaderSize + 2)
+	quotas := map[string]uint64{"tenant-1": 2 * itemSize, "tenant-2": 0, "tenant-3": itemSize - 1}
+
+	metrics := prometheus.NewRegistry()
+	cache, err := NewInMemoryIndexCacheWithConfig(log.NewNopLogger(), metrics, InMemoryIndexCacheConfig{
+		MaxItemSize:   flagext.Bytes(10 * itemSize),
+		MaxSize:       flagext.Bytes(10 * itemSize),
+		TenantMaxSize: func(userID string) uint64 { return quotas[userID] },
+	})
+	assert.NoError(t, err)
+
+	id := ulid.MustNew(0, nil)
+	ctx := context.Background()
+	lbl := func(value string) labels.Label { return labels.Label{Name: "test", Value: value} }
+
+	cache.StorePostings(ctx, "tenant-1", id, lbl("1"), []byte{42, 33})
+	cache.StorePostings(ctx, "tenant-1", id, lbl("2"), []byte{42, 33})
+	cache.StorePostings(ctx, "tenant-2", id, lbl("1"), []byte{42, 33})
+	cache.StorePostings(ctx, "tenant-2", id, lbl("2"), []byte{42, 33})
+	cache.StorePostings(ctx, "tenant-2", id, lbl("3"), []byte{42, 33})
+
+	// Use the first item of tenant-1, so that the second one is the least recently used.
+	hits, _ := cache.FetchMultiPostings(ctx, "tenant-1", id, []labels.Label{lbl("1")})
+	assert.Len(t, hits, 1)
+
+	// Exceeding the quota of tenant-1 only evicts its least recently used item.
+	cache.StorePostings(ctx, "tenant-1", id, lbl("3"), []byte{42, 33})
+	hits, misses := cache.FetchMultiPostings(ctx, "tenant-1", id, []labels.Label{lbl("1"), lbl("2"), lbl("3")})
+	assert.Len(t, hits, 2)
+	assert.Equal(t, []labels.Label{lbl("2")}, misses)
+	hits, _ = cache.FetchMultiPostings(ctx, "tenant-2", id, []labels.Label{lbl("1"), lbl("2"), lbl("3")})
+	assert.Len(t, hits, 3)
+
+	// Items bigger than the quota are not stored.
+	cache.StorePostings(ctx, "tenant-3", id, lbl("1"), []byte{42, 33})
+	hits, _ = cache.FetchMultiPostings(ctx, "tenant-3", id, []labels.Label{lbl("1")})
+	assert.Empty(t, hits)
+
+	assert.Equal(t, float64(1), promtest.ToFloat64(cache.tenantQuotaEvicted.WithLabelValues("tenant-1")))
+	assert.Equal(t, float64(1), promtest.ToFloat64(cache.tenantQuotaOverflow.WithLabelValues("tenant-3")))
+	assert.Equal(t, float64(2*itemSize), promtest.ToFloat64(cache.tenantCurrentSize.WithLabelValues("tenant-1")))
+	assert.Equal(t, float64(3*itemSize), promtest.ToFloat64(cache.tenantCurrentSize.WithLabelValues("tenant-2")))
+	assert.Equal(t, 5*itemSize, cache.curSize)
+
+	// The size of a tenant is not tracked anymore once all its items are evicted.
+	for cache.lru.Len() > 0 {
+		cache.lru.RemoveOldest()
+	}
+	assert.Empty(t, cache.tenantSize)
+	assert.Empty(t, cache.tenantLRU)
+	assert.Equal(t, 0, promtest.CollectAndCount(cache.tenantCurrentSize))
+}
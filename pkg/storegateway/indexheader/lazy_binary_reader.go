@@ -38,6 +38,7 @@ type LazyBinaryReaderMetrics struct {
 	unloadCount       prometheus.Counter
 	unloadFailedCount prometheus.Counter
 	loadDuration      prometheus.Histogram
+	reloadDuration    prometheus.Histogram
 }
 
 // NewLazyBinaryReaderMetrics makes new LazyBinaryReaderMetrics.
@@ -64,6 +65,11 @@ func NewLazyBinaryReaderMetrics(reg prometheus.Registerer) *LazyBinaryReaderMetr
 			Help:    "Duration of the index-header lazy loading in seconds.",
 			Buckets: []float64{0.01, 0.02, 0.05, 0.1, 0.2, 0.5, 1, 2, 5, 15, 30, 60, 120, 300},
 		}),
+		reloadDuration: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "indexheader_lazy_reload_duration_seconds",
+			Help:    "Duration in seconds of the index-header lazy loading of the readers previously unloaded because idle or because of memory pressure.",
+			Buckets: []float64{0.01, 0.02, 0.05, 0.1, 0.2, 0.5, 1, 2, 5, 15, 30, 60, 120, 300},
+		}),
 	}
 }
 
@@ -85,6 +91,9 @@ type LazyBinaryReader struct {
 	reader    *BinaryReader
 	readerErr error
 
+	// Whether the index-header has been unloaded at least once, so that the next load is a reload.
+	unloaded bool
+
 	// Keep track of the last time it was used.
 	usedAt *atomic.Int64
 }
@@ -261,6 +270,9 @@ func (r *LazyBinaryReader) load() (returnErr error) {
 	r.reader = reader
 	level.Debug(r.logger).Log("msg", "lazy loaded index-header file", "path", r.filepath, "elapsed", time.Since(startTime))
 	r.metrics.loadDuration.Observe(time.Since(startTime).Seconds())
+	if r.unloaded {
+		r.metrics.reloadDuration.Observe(time.Since(startTime).Seconds())
+	}
 
 	return nil
 }
@@ -268,27 +280,36 @@ func (r *LazyBinaryReader) load() (returnErr error) {
 // unloadIfIdleSince closes underlying BinaryReader if the reader is idle since given time (as unix nano). If idleSince is 0,
 // the check on the last usage is skipped. Calling this function on a already unloaded reader is a no-op.
 func (r *LazyBinaryReader) unloadIfIdleSince(ts int64) error {
+	_, err := r.unloadIfIdleSinceWithSize(ts)
+	return err
+}
+
+// unloadIfIdleSinceWithSize is like unloadIfIdleSince, but also returns the size in bytes of
+// the index-header unloaded, or 0 if it wasn't loaded.
+func (r *LazyBinaryReader) unloadIfIdleSinceWithSize(ts int64) (int, error) {
 	r.readerMx.Lock()
 	defer r.readerMx.Unlock()
 
 	// Nothing to do if already unloaded.
 	if r.reader == nil {
-		return nil
+		return 0, nil
 	}
 
 	// Do not unloadIfIdleSince if not idle.
 	if ts > 0 && r.usedAt.Load() > ts {
-		return errNotIdle
+		return 0, errNotIdle
 	}
 
+	size := r.reader.b.Len()
 	r.metrics.unloadCount.Inc()
 	if err := r.reader.Close(); err != nil {
 		r.metrics.unloadFailedCount.Inc()
-		return err
+		return 0, err
 	}
 
 	r.reader = nil
-	return nil
+	r.unloaded = true
+	return size, nil
 }
 
 // isIdleSince returns true if the reader is idle since given time (as unix nano).
@@ -304,3 +325,14 @@ func (r *LazyBinaryReader) isIdleSince(ts int64) bool {
 
 	return loaded
 }
+
+// loadedSize returns the size in bytes of the loaded index-header, or 0 if it's not loaded.
+func (r *LazyBinaryReader) loadedSize() int {
+	r.readerMx.RLock()
+	defer r.readerMx.RUnlock()
+
+	if r.reader == nil {
+		return 0
+	}
+	return r.reader.b.Len()
+}
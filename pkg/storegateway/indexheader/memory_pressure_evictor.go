@@ -0,0 +1,179 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package indexheader
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/procfs"
+)
+
+// MemoryPressureEvictor unloads the least recently used lazy loaded index-headers of all the
+// registered ReaderPools when the resident memory of the process exceeds the memory budget.
+// The index-headers are unloaded until their size covers the memory in excess, and reloaded
+// upon next usage.
+type MemoryPressureEvictor struct {
+	budgetBytes uint64
+	logger      log.Logger
+
+	// Returns the resident memory of the process. Overridden in tests.
+	residentMemory func() (uint64, error)
+
+	// Channel used to signal once the evictor is closing.
+	close chan struct{}
+
+	poolsMx sync.Mutex
+	pools   map[*ReaderPool]struct{}
+
+	// Metrics.
+	evictions     prometheus.Counter
+	evictedBytes  prometheus.Counter
+	checkFailures prometheus.Counter
+}
+
+// NewMemoryPressureEvictor makes a new MemoryPressureEvictor, checking the resident memory
+// of the process against the budget every checkInterval.
+func NewMemoryPressureEvictor(budgetBytes uint64, checkInterval time.Duration, logger log.Logger, reg prometheus.Registerer) *MemoryPressureEvictor {
+	e := &MemoryPressureEvictor{
+		budgetBytes:    budgetBytes,
+		logger:         logger,
+		residentMemory: processResidentMemory,
+		close:          make(chan struct{}),
+		pools:          make(map[*ReaderPool]struct{}),
+		evictions: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "indexheader_lazy_memory_pressure_evictions_total",
+			Help: "Total number of index-headers unloaded because the resident memory exceeded the memory budget.",
+		}),
+		evictedBytes: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "indexheader_lazy_memory_pressure_evicted_bytes_total",
+			Help: "Total size in bytes of the index-headers unloaded because the resident memory exceeded the memory budget.",
+		}),
+		checkFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "indexheader_lazy_memory_pressure_check_failures_total",
+			Help: "Total number of failed checks of the resident memory against the memory budget.",
+		}),
+	}
+
+	go func() {
+		for {
+			select {
+			case <-e.close:
+				return
+			case <-time.After(checkInterval):
+				e.evictIfOverBudget()
+			}
+		}
+	}()
+
+	return e
+}
+
+// Close stops checking the memory usage. The index-headers loaded are not unloaded.
+func (e *MemoryPressureEvictor) Close() {
+	close(e.close)
+}
+
+func (e *MemoryPressureEvictor) register(p *ReaderPool) {
+	e.poolsMx.Lock()
+	defer e.poolsMx.Unlock()
+
+	e.pools[p] = struct{}{}
+}
+
+func (e *MemoryPressureEvictor) unregister(p *ReaderPool) {
+	e.poolsMx.Lock()
+	defer e.poolsMx.Unlock()
+
+	delete(e.pools, p)
+}
+
+// evictionCandidate is a loaded index-header, along with its last usage when collected.
+type evictionCandidate struct {
+	reader *LazyBinaryReader
+	usedAt int64
+}
+
+// evictIfOverBudget unloads the least recently used index-headers if the resident memory exceeds the budget.
+func (e *MemoryPressureEvictor) evictIfOverBudget() {
+	rss, err := e.residentMemory()
+	if err != nil {
+		e.checkFailures.Inc()
+		level.Warn(e.logger).Log("msg", "failed to check the resident memory against the index-headers memory budget", "err", err)
+		return
+	}
+	if rss <= e.budgetBytes {
+		return
+	}
+
+	excess := rss - e.budgetBytes
+	candidates := e.evictionCandidates()
+
+	var evicted, evictedBytes uint64
+	for _, c := range candidates {
+		if evictedBytes >= excess {
+			break
+		}
+
+		// The index-header is not unloaded if it's been used since it was collected.
+		size, err := c.reader.unloadIfIdleSinceWithSize(c.usedAt)
+		if err != nil {
+			if !errors.Is(err, errNotIdle) {
+				level.Warn(e.logger).Log("msg", "failed to unload index-header because of memory pressure", "path", c.reader.filepath, "err", err)
+			}
+			continue
+		}
+		if size > 0 {
+			evicted++
+			evictedBytes += uint64(size)
+		}
+	}
+
+	e.evictions.Add(float64(evicted))
+	e.evictedBytes.Add(float64(evictedBytes))
+	level.Info(e.logger).Log("msg", "unloaded index-headers because the resident memory exceeds the memory budget", "resident_memory_bytes", rss, "budget_bytes", e.budgetBytes, "evicted", evicted, "evicted_bytes", evictedBytes, "loaded", len(candidates))
+}
+
+// evictionCandidates returns the loaded index-headers of all the registered pools, least recently used first.
+func (e *MemoryPressureEvictor) evictionCandidates() []evictionCandidate {
+	e.poolsMx.Lock()
+	pools := make([]*ReaderPool, 0, len(e.pools))
+	for p := range e.pools {
+		pools = append(pools, p)
+	}
+	e.poolsMx.Unlock()
+
+	var candidates []evictionCandidate
+	for _, p := range pools {
+		for _, r := range p.trackedReaders() {
+			usedAt := r.usedAt.Load()
+			if r.loadedSize() > 0 {
+				candidates = append(candidates, evictionCandidate{reader: r, usedAt: usedAt})
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].usedAt < candidates[j].usedAt
+	})
+	return candidates
+}
+
+// processResidentMemory returns the resident memory of the process. It's only supported on Linux.
+func processResidentMemory() (uint64, error) {
+	proc, err := procfs.Self()
+	if err != nil {
+		return 0, errors.Wrap(err, "read process info")
+	}
+	stat, err := proc.Stat()
+	if err != nil {
+		return 0, errors.Wrap(err, "read process stat")
+	}
+	return uint64(stat.ResidentMemory()), nil
+}
@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package indexheader
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/thanos-io/objstore/providers/filesystem"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/storage/tsdb/metadata"
+	"github.com/grafana/mimir/pkg/storegateway/testhelper"
+)
+
+func TestMemoryPressureEvictor(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+
+	bkt, err := filesystem.NewBucket(filepath.Join(tmpDir, "bkt"))
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, bkt.Close()) })
+
+	// Create two blocks.
+	var blockIDs []ulid.ULID
+	for i := 0; i < 2; i++ {
+		blockID, err := testhelper.CreateBlock(ctx, tmpDir, []labels.Labels{
+			{{Name: "a", Value: "1"}},
+			{{Name: "a", Value: "2"}},
+		}, 100, 0, 1000, labels.FromStrings("ext1", "1"), 124, metadata.NoneFunc)
+		require.NoError(t, err)
+		require.NoError(t, block.Upload(ctx, log.NewNopLogger(), bkt, filepath.Join(tmpDir, blockID.String()), metadata.NoneFunc))
+		blockIDs = append(blockIDs, blockID)
+	}
+
+	var rss uint64
+	var rssErr error
+
+	reg := prometheus.NewPedanticRegistry()
+	evictor := NewMemoryPressureEvictor(1000, time.Hour, log.NewNopLogger(), reg)
+	evictor.residentMemory = func() (uint64, error) { return rss, rssErr }
+	t.Cleanup(evictor.Close)
+
+	metrics := NewReaderPoolMetrics(nil)
+	pool := NewReaderPool(log.NewNopLogger(), true, 0, evictor, metrics)
+	t.Cleanup(pool.Close)
+
+	var readers []*LazyBinaryReader
+	for _, id := range blockIDs {
+		r, err := pool.NewBinaryReader(ctx, log.NewNopLogger(), bkt, tmpDir, id, 3, BinaryReaderConfig{})
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, r.Close()) })
+		readers = append(readers, r.(*LazyBinaryReader))
+	}
+
+	// Load both index-headers, the first one being the least recently used.
+	for _, r := range readers {
+		_, err := r.LabelNames()
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond)
+	}
+	require.Greater(t, readers[0].loadedSize(), 0)
+	require.Greater(t, readers[1].loadedSize(), 0)
+
+	t.Run("should not evict the index-headers if the memory is within the budget", func(t *testing.T) {
+		rss = 1000
+		evictor.evictIfOverBudget()
+
+		assert.Greater(t, readers[0].loadedSize(), 0)
+		assert.Greater(t, readers[1].loadedSize(), 0)
+		assert.Equal(t, float64(0), promtestutil.ToFloat64(evictor.evictions))
+	})
+
+	t.Run("should not evict the index-headers if the memory can't be checked", func(t *testing.T) {
+		rss, rssErr = 2000, errors.New("not supported")
+		evictor.evictIfOverBudget()
+		rssErr = nil
+
+		assert.Greater(t, readers[1].loadedSize(), 0)
+		assert.Equal(t, float64(0), promtestutil.ToFloat64(evictor.evictions))
+		assert.Equal(t, float64(1), promtestutil.ToFloat64(evictor.checkFailures))
+	})
+
+	t.Run("should evict the least recently used index-headers covering the memory in excess", func(t *testing.T) {
+		size := readers[0].loadedSize()
+		rss = 1000 + uint64(size)
+		evictor.evictIfOverBudget()
+
+		assert.Equal(t, 0, readers[0].loadedSize())
+		assert.Greater(t, readers[1].loadedSize(), 0)
+		assert.Equal(t, float64(1), promtestutil.ToFloat64(evictor.evictions))
+		assert.Equal(t, float64(size), promtestutil.ToFloat64(evictor.evictedBytes))
+	})
+
+	t.Run("should reload the evicted index-headers upon next usage", func(t *testing.T) {
+		labelNames, err := readers[0].LabelNames()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, labelNames)
+		reloads := &dto.Metric{}
+		require.NoError(t, metrics.lazyReader.reloadDuration.Write(reloads))
+		assert.Equal(t, uint64(1), reloads.GetHistogram().GetSampleCount())
+		assert.Equal(t, float64(3), promtestutil.ToFloat64(metrics.lazyReader.loadCount))
+	})
+
+	t.Run("should evict all the index-headers if needed", func(t *testing.T) {
+		rss = 1 << 40
+		evictor.evictIfOverBudget()
+
+		assert.Equal(t, 0, readers[0].loadedSize())
+		assert.Equal(t, 0, readers[1].loadedSize())
+		assert.Equal(t, float64(3), promtestutil.ToFloat64(evictor.evictions))
+	})
+
+	t.Run("should not evict the index-headers of the unregistered pools", func(t *testing.T) {
+		_, err := readers[0].LabelNames()
+		require.NoError(t, err)
+
+		evictor.unregister(pool)
+		evictor.evictIfOverBudget()
+		evictor.register(pool)
+
+		assert.Greater(t, readers[0].loadedSize(), 0)
+	})
+}
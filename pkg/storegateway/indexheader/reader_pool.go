@@ -33,11 +33,13 @@ func NewReaderPoolMetrics(reg prometheus.Registerer) *ReaderPoolMetrics {
 
 // ReaderPool is used to istantiate new index-header readers and keep track of them.
 // When the lazy reader is enabled, the pool keeps track of all instantiated readers
-// and automatically close them once the idle timeout is reached. A closed lazy reader
-// will be automatically re-opened upon next usage.
+// and automatically close them once the idle timeout is reached, or when they're
+// evicted because of memory pressure. A closed lazy reader will be automatically
+// re-opened upon next usage.
 type ReaderPool struct {
 	lazyReaderEnabled     bool
 	lazyReaderIdleTimeout time.Duration
+	evictor               *MemoryPressureEvictor
 	logger                log.Logger
 	metrics               *ReaderPoolMetrics
 
@@ -49,8 +51,9 @@ type ReaderPool struct {
 	lazyReaders   map[*LazyBinaryReader]struct{}
 }
 
-// NewReaderPool makes a new ReaderPool.
-func NewReaderPool(logger log.Logger, lazyReaderEnabled bool, lazyReaderIdleTimeout time.Duration, metrics *ReaderPoolMetrics) *ReaderPool {
+// NewReaderPool makes a new ReaderPool. The evictor, if not nil, unloads the lazy readers of the
+// pool when the memory usage exceeds its budget.
+func NewReaderPool(logger log.Logger, lazyReaderEnabled bool, lazyReaderIdleTimeout time.Duration, evictor *MemoryPressureEvictor, metrics *ReaderPoolMetrics) *ReaderPool {
 	p := &ReaderPool{
 		logger:                logger,
 		metrics:               metrics,
@@ -60,6 +63,11 @@ func NewReaderPool(logger log.Logger, lazyReaderEnabled bool, lazyReaderIdleTime
 		close:                 make(chan struct{}),
 	}
 
+	if p.lazyReaderEnabled && evictor != nil {
+		p.evictor = evictor
+		p.evictor.register(p)
+	}
+
 	// Start a goroutine to close idle readers (only if required).
 	if p.lazyReaderEnabled && p.lazyReaderIdleTimeout > 0 {
 		checkFreq := p.lazyReaderIdleTimeout / 10
@@ -97,7 +105,7 @@ func (p *ReaderPool) NewBinaryReader(ctx context.Context, logger log.Logger, bkt
 	}
 
 	// Keep track of lazy readers only if required.
-	if p.lazyReaderEnabled && p.lazyReaderIdleTimeout > 0 {
+	if p.lazyReaderEnabled && (p.lazyReaderIdleTimeout > 0 || p.evictor != nil) {
 		p.lazyReadersMx.Lock()
 		p.lazyReaders[reader.(*LazyBinaryReader)] = struct{}{}
 		p.lazyReadersMx.Unlock()
@@ -109,6 +117,9 @@ func (p *ReaderPool) NewBinaryReader(ctx context.Context, logger log.Logger, bkt
 // Close the pool and stop checking for idle readers. No reader tracked by this pool
 // will be closed. It's the caller responsibility to close readers.
 func (p *ReaderPool) Close() {
+	if p.evictor != nil {
+		p.evictor.unregister(p)
+	}
 	close(p.close)
 }
 
@@ -136,6 +147,19 @@ func (p *ReaderPool) getIdleReadersSince(ts int64) []*LazyBinaryReader {
 	return idle
 }
 
+// trackedReaders returns the lazy readers tracked by the pool.
+func (p *ReaderPool) trackedReaders() []*LazyBinaryReader {
+	p.lazyReadersMx.Lock()
+	defer p.lazyReadersMx.Unlock()
+
+	readers := make([]*LazyBinaryReader, 0, len(p.lazyReaders))
+	for r := range p.lazyReaders {
+		readers = append(readers, r)
+	}
+
+	return readers
+}
+
 func (p *ReaderPool) isTracking(r *LazyBinaryReader) bool {
 	p.lazyReadersMx.Lock()
 	defer p.lazyReadersMx.Unlock()
@@ -100,3 +100,28 @@ func (g *gapBasedPartitioner) partition(length int, rng func(int) (uint64, uint6
 	}
 	return parts
 }
+
+// coalesceParts merges together adjacent parts separated by less than mergeGapBytes of unused bytes,
+// trading some wasted bandwidth for fewer bucket GET object requests. Parts must be sorted by Start
+// and contiguous in their ElemRng, as returned by Partitioner.Partition. A mergeGapBytes of 0
+// returns the input parts unchanged.
+func coalesceParts(parts []Part, mergeGapBytes uint64) []Part {
+	if mergeGapBytes == 0 || len(parts) < 2 {
+		return parts
+	}
+
+	merged := parts[:1]
+	for _, p := range parts[1:] {
+		last := &merged[len(merged)-1]
+		if p.Start >= last.End+mergeGapBytes {
+			merged = append(merged, p)
+			continue
+		}
+
+		if p.End > last.End {
+			last.End = p.End
+		}
+		last.ElemRng[1] = p.ElemRng[1]
+	}
+	return merged
+}
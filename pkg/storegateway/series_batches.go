@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/runutil"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/grafana/mimir/pkg/storegateway/storepb"
+)
+
+// blockEntries are the series of a block whose chunks have not been loaded yet.
+type blockEntries struct {
+	block   *bucketBlock
+	entries []seriesEntry
+}
+
+// seriesBatchSet is a storepb.SeriesSet merging the series of multiple blocks, which loads the chunks of the
+// series in batches: the chunks of a batch are loaded once the previous batch has been iterated, and the previous
+// batch chunks are released. This bounds the memory used for the chunks of queries selecting many series.
+//
+// The series and chunks returned by At() are only valid until the next call to Next().
+type seriesBatchSet struct {
+	ctx       context.Context
+	logger    log.Logger
+	blocks    []blockEntries
+	batchSize int
+	aggrs     []storepb.Aggr
+
+	// Limits of the query, shared by the chunk readers of all batches.
+	fetchLimits *queryFetchLimits
+
+	// Position of the next series of each block to add to a batch.
+	next []int
+
+	// The current batch and the chunk readers holding its chunks.
+	batch   storepb.SeriesSet
+	readers []*bucketChunkReader
+
+	stats *queryStats
+	err   error
+}
+
+func newSeriesBatchSet(ctx context.Context, logger log.Logger, blocks []blockEntries, batchSize int, aggrs []storepb.Aggr, fetchLimits *queryFetchLimits) *seriesBatchSet {
+	return &seriesBatchSet{
+		ctx:         ctx,
+		logger:      logger,
+		blocks:      blocks,
+		batchSize:   batchSize,
+		aggrs:       aggrs,
+		fetchLimits: fetchLimits,
+		next:        make([]int, len(blocks)),
+		stats:       &queryStats{},
+	}
+}
+
+func (s *seriesBatchSet) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.batch != nil && s.batch.Next() {
+		return true
+	}
+
+	if s.err = s.loadBatch(); s.err != nil {
+		return false
+	}
+	return s.batch.Next()
+}
+
+func (s *seriesBatchSet) At() (labels.Labels, []storepb.AggrChunk) {
+	return s.batch.At()
+}
+
+func (s *seriesBatchSet) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.batch != nil {
+		return s.batch.Err()
+	}
+	return nil
+}
+
+// Close releases the chunks of the current batch. It's safe to call Close multiple times.
+func (s *seriesBatchSet) Close() {
+	for _, r := range s.readers {
+		runutil.CloseWithLogOnErr(s.logger, r, "close batch chunk reader")
+	}
+	s.readers = nil
+}
+
+// loadBatch releases the chunks of the current batch, and loads the chunks of the next batch series.
+func (s *seriesBatchSet) loadBatch() error {
+	s.Close()
+
+	// Pick the next series, in labels order. The series of different blocks with the same labels
+	// are part of the same batch, so that they're merged.
+	batchEntries := make([][]seriesEntry, len(s.blocks))
+	for n := 0; n < s.batchSize; n++ {
+		var (
+			lset  labels.Labels
+			found bool
+		)
+		for i, b := range s.blocks {
+			if s.next[i] < len(b.entries) && (!found || labels.Compare(b.entries[s.next[i]].lset, lset) < 0) {
+				lset, found = b.entries[s.next[i]].lset, true
+			}
+		}
+		if !found {
+			break
+		}
+
+		for i, b := range s.blocks {
+			if s.next[i] < len(b.entries) && labels.Equal(b.entries[s.next[i]].lset, lset) {
+				batchEntries[i] = append(batchEntries[i], b.entries[s.next[i]])
+				// Do not retain the series once they're part of a batch, so that their chunks can be released.
+				b.entries[s.next[i]] = seriesEntry{}
+				s.next[i]++
+			}
+		}
+	}
+
+	g, gctx := errgroup.WithContext(s.ctx)
+	sets := make([]storepb.SeriesSet, 0, len(s.blocks))
+	for i, entries := range batchEntries {
+		if len(entries) == 0 {
+			continue
+		}
+
+		block := s.blocks[i].block
+		chunkr := block.chunkReader(gctx)
+		chunkr.setFetchLimits(s.fetchLimits)
+		s.readers = append(s.readers, chunkr)
+
+		for seriesIdx, entry := range entries {
+			for chunkIdx, ref := range entry.refs {
+				if err := chunkr.addLoad(ref, seriesIdx, chunkIdx); err != nil {
+					// Wait for the chunks of the other blocks being loaded, before their readers are closed.
+					_ = g.Wait()
+					return errors.Wrapf(err, "add chunk load for block %s", block.meta.ULID)
+				}
+			}
+		}
+
+		entries := entries
+		g.Go(func() error {
+			if err := chunkr.load(entries, s.aggrs); err != nil {
+				return errors.Wrapf(err, "load chunks for block %s", block.meta.ULID)
+			}
+			return nil
+		})
+		sets = append(sets, newBucketSeriesSet(entries))
+	}
+
+	err := g.Wait()
+	for _, r := range s.readers {
+		s.stats = s.stats.merge(r.stats)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(sets) > 0 {
+		s.stats.seriesBatches++
+	}
+	s.batch = storepb.MergeSeriesSets(sets...)
+	return nil
+}
@@ -0,0 +1,95 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package storegateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storegateway/storepb"
+)
+
+func TestSeriesBatchSet(t *testing.T) {
+	offsets := []uint32{8, 20000, 40000}
+	chks := newTestXORChunks(t, len(offsets))
+	blk1, bkt1 := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{})
+	blk2, bkt2 := prepareChunkReaderTestBlock(t, offsets, chks, chunkReaderConfig{})
+
+	entry := func(lset labels.Labels, chunkIdx int) seriesEntry {
+		return seriesEntry{
+			lset: lset,
+			refs: []chunks.ChunkRef{chunks.ChunkRef(offsets[chunkIdx])},
+			chks: []storepb.AggrChunk{{MinTime: int64(chunkIdx), MaxTime: int64(chunkIdx)}},
+		}
+	}
+
+	// The series with the same labels in both blocks are merged.
+	set := newSeriesBatchSet(context.Background(), log.NewNopLogger(), []blockEntries{
+		{block: blk1, entries: []seriesEntry{
+			entry(labels.FromStrings("a", "1"), 0),
+			entry(labels.FromStrings("a", "3"), 1),
+			entry(labels.FromStrings("a", "4"), 2),
+		}},
+		{block: blk2, entries: []seriesEntry{
+			entry(labels.FromStrings("a", "2"), 0),
+			entry(labels.FromStrings("a", "3"), 2),
+		}},
+	}, 2, nil, nil)
+	defer set.Close()
+
+	expected := []struct {
+		lset   labels.Labels
+		chunks []int
+	}{
+		{lset: labels.FromStrings("a", "1"), chunks: []int{0}},
+		{lset: labels.FromStrings("a", "2"), chunks: []int{0}},
+		{lset: labels.FromStrings("a", "3"), chunks: []int{1, 2}},
+		{lset: labels.FromStrings("a", "4"), chunks: []int{2}},
+	}
+
+	var actual int
+	for set.Next() {
+		require.Less(t, actual, len(expected))
+		lset, aggrChks := set.At()
+		assert.Equal(t, expected[actual].lset, lset)
+		require.Len(t, aggrChks, len(expected[actual].chunks))
+		for i, chunkIdx := range expected[actual].chunks {
+			require.NotNil(t, aggrChks[i].Raw)
+			assert.Equal(t, chks[chunkIdx].Bytes(), aggrChks[i].Raw.Data)
+		}
+		actual++
+
+		// Only the chunk readers of the current batch are kept open.
+		assert.LessOrEqual(t, len(set.readers), 2)
+	}
+	require.NoError(t, set.Err())
+	assert.Equal(t, len(expected), actual)
+
+	assert.Equal(t, 2, set.stats.seriesBatches)
+	assert.Equal(t, 5, set.stats.chunksFetched)
+	assert.Equal(t, 5, int(bkt1.getRangeCalls.Load()+bkt2.getRangeCalls.Load()))
+}
+
+func TestSeriesBatchSet_ShouldFailOnChunkLoadError(t *testing.T) {
+	offsets := []uint32{8}
+	blk, _ := prepareChunkReaderTestBlock(t, offsets, newTestXORChunks(t, len(offsets)), chunkReaderConfig{})
+
+	// The chunk references a segment file which doesn't exist.
+	set := newSeriesBatchSet(context.Background(), log.NewNopLogger(), []blockEntries{
+		{block: blk, entries: []seriesEntry{{
+			lset: labels.FromStrings("a", "1"),
+			refs: []chunks.ChunkRef{chunks.ChunkRef(uint64(1)<<32 | 8)},
+			chks: []storepb.AggrChunk{{}},
+		}}},
+	}, 10, nil, nil)
+	defer set.Close()
+
+	assert.False(t, set.Next())
+	assert.ErrorContains(t, set.Err(), "add chunk load for block")
+}
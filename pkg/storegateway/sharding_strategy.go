@@ -7,6 +7,8 @@ package storegateway
 
 import (
 	"context"
+	"sort"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -42,6 +44,8 @@ type ShardingStrategy interface {
 // limiting the scope of the limits to the ones required by sharding strategies.
 type ShardingLimits interface {
 	StoreGatewayTenantShardSize(userID string) int
+	StoreGatewayRecentBlocksPeriod(userID string) time.Duration
+	StoreGatewayRecentBlocksReplicationFactor(userID string) int
 }
 
 // ShuffleShardingStrategy is a shuffle sharding strategy, based on the hash ring formed by store-gateways,
@@ -113,12 +117,13 @@ func (s *ShuffleShardingStrategy) FilterBlocks(_ context.Context, userID string,
 
 	r := GetShuffleShardingSubring(s.r, userID, s.limits)
 	bufDescs, bufHosts, bufZones := ring.MakeBuffersForGet()
+	now := time.Now()
 
-	for blockID := range metas {
+	for blockID, meta := range metas {
 		key := mimir_tsdb.HashBlockID(blockID)
 
 		// Check if the block is owned by the store-gateway
-		set, err := r.Get(key, BlocksOwnerSync, bufDescs, bufHosts, bufZones)
+		set, err := GetBlockReplicationSet(r, userID, blockID, meta.MaxTime, now, BlocksOwnerSync, s.limits, bufDescs, bufHosts, bufZones)
 
 		// If an error occurs while checking the ring, we keep the previously loaded blocks.
 		if err != nil {
@@ -175,6 +180,52 @@ func GetShuffleShardingSubring(ring *ring.Ring, userID string, limits ShardingLi
 	return ring.ShuffleShard(userID, shardSize)
 }
 
+// GetBlockReplicationSet returns the store-gateways owning the block of a given user for the operation. The
+// block is owned by the instances the block hash belongs to in the ring, unless it's a recent block of the user
+// and the user's recent blocks replication factor is greater than the ring one: then the additional owners are
+// the healthy instances ranking first by the hash of their address and the block ID. This function should be
+// used both by store-gateway and querier in order to guarantee the same logic is used.
+func GetBlockReplicationSet(r ring.ReadRing, userID string, blockID ulid.ULID, blockMaxTime int64, now time.Time, op ring.Operation, limits ShardingLimits, bufDescs []ring.InstanceDesc, bufHosts, bufZones []string) (ring.ReplicationSet, error) {
+	set, err := r.Get(mimir_tsdb.HashBlockID(blockID), op, bufDescs, bufHosts, bufZones)
+	if err != nil {
+		return set, err
+	}
+
+	period := limits.StoreGatewayRecentBlocksPeriod(userID)
+	replicationFactor := limits.StoreGatewayRecentBlocksReplicationFactor(userID)
+	if period <= 0 || replicationFactor <= r.ReplicationFactor() || blockMaxTime < now.Add(-period).UnixMilli() {
+		return set, nil
+	}
+
+	// If the healthy instances can't be listed, the block is just owned by the ring owners.
+	healthy, err := r.GetAllHealthy(op)
+	if err != nil {
+		return set, nil
+	}
+
+	candidates := make([]ring.InstanceDesc, 0, len(healthy.Instances))
+	for _, instance := range healthy.Instances {
+		if !set.Includes(instance.Addr) {
+			candidates = append(candidates, instance)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return mimir_tsdb.HashInstanceBlockID(candidates[i].Addr, blockID) > mimir_tsdb.HashInstanceBlockID(candidates[j].Addr, blockID)
+	})
+
+	// Copy the instances, so that the input buffer is not modified beyond the returned replication set.
+	instances := append(make([]ring.InstanceDesc, 0, replicationFactor), set.Instances...)
+	for _, instance := range candidates {
+		if len(instances) >= replicationFactor {
+			break
+		}
+		instances = append(instances, instance)
+	}
+
+	set.Instances = instances
+	return set, nil
+}
+
 type shardingMetadataFilterAdapter struct {
 	userID   string
 	strategy ShardingStrategy
@@ -7,6 +7,7 @@ package storegateway
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -422,8 +423,97 @@ func TestShuffleShardingStrategy(t *testing.T) {
 	}
 }
 
+func TestGetBlockReplicationSet(t *testing.T) {
+	const (
+		userID       = "user-1"
+		numInstances = 5
+	)
+
+	ctx := context.Background()
+	now := time.Now()
+	store, closer := consul.NewInMemoryClient(ring.GetCodec(), log.NewNopLogger(), nil)
+	t.Cleanup(func() { assert.NoError(t, closer.Close()) })
+
+	require.NoError(t, store.CAS(ctx, "test", func(in interface{}) (interface{}, bool, error) {
+		d := ring.NewDesc()
+		for i := 1; i <= numInstances; i++ {
+			d.AddIngester(fmt.Sprintf("instance-%d", i), fmt.Sprintf("127.0.0.%d", i), "", ring.GenerateTokens(128, nil), ring.ACTIVE, now)
+		}
+		return d, true, nil
+	}))
+
+	cfg := ring.Config{ReplicationFactor: 1, HeartbeatTimeout: time.Minute}
+	r, err := ring.NewWithStoreClientAndStrategy(cfg, "test", "test", store, ring.NewIgnoreUnhealthyInstancesReplicationStrategy(), nil, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, r))
+	defer services.StopAndAwaitTerminated(ctx, r) //nolint:errcheck
+	require.NoError(t, ring.WaitInstanceState(ctx, r, "instance-1", ring.ACTIVE))
+
+	recentBlockMaxTime := now.Add(-time.Hour).UnixMilli()
+	oldBlockMaxTime := now.Add(-72 * time.Hour).UnixMilli()
+
+	for name, test := range map[string]struct {
+		limits            *shardingLimitsMock
+		blockMaxTime      int64
+		expectedInstances int
+	}{
+		"recent blocks replication disabled": {
+			limits:            &shardingLimitsMock{},
+			blockMaxTime:      recentBlockMaxTime,
+			expectedInstances: 1,
+		},
+		"recent block": {
+			limits:            &shardingLimitsMock{storeGatewayRecentBlocksPeriod: 48 * time.Hour, storeGatewayRecentBlocksReplicationFactor: 3},
+			blockMaxTime:      recentBlockMaxTime,
+			expectedInstances: 3,
+		},
+		"old block": {
+			limits:            &shardingLimitsMock{storeGatewayRecentBlocksPeriod: 48 * time.Hour, storeGatewayRecentBlocksReplicationFactor: 3},
+			blockMaxTime:      oldBlockMaxTime,
+			expectedInstances: 1,
+		},
+		"recent block with replication factor greater than the number of instances": {
+			limits:            &shardingLimitsMock{storeGatewayRecentBlocksPeriod: 48 * time.Hour, storeGatewayRecentBlocksReplicationFactor: 10},
+			blockMaxTime:      recentBlockMaxTime,
+			expectedInstances: numInstances,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				blockID := ulid.MustNew(uint64(i), nil)
+
+				baseSet, err := r.Get(mimir_tsdb.HashBlockID(blockID), BlocksOwnerSync, nil, nil, nil)
+				require.NoError(t, err)
+
+				set, err := GetBlockReplicationSet(r, userID, blockID, test.blockMaxTime, now, BlocksOwnerSync, test.limits, nil, nil, nil)
+				require.NoError(t, err)
+				require.Len(t, set.Instances, test.expectedInstances)
+
+				// The ring owners of the block are always owners, and the owners are distinct.
+				assert.True(t, set.Includes(baseSet.Instances[0].Addr))
+				assert.Len(t, set.GetAddresses(), test.expectedInstances)
+
+				// The same owners are returned for the same block.
+				again, err := GetBlockReplicationSet(r, userID, blockID, test.blockMaxTime, now, BlocksOwnerSync, test.limits, nil, nil, nil)
+				require.NoError(t, err)
+				assert.Equal(t, set.GetAddresses(), again.GetAddresses())
+			}
+		})
+	}
+}
+
 type shardingLimitsMock struct {
-	storeGatewayTenantShardSize int
+	storeGatewayTenantShardSize               int
+	storeGatewayRecentBlocksPeriod            time.Duration
+	storeGatewayRecentBlocksReplicationFactor int
+}
+
+func (m *shardingLimitsMock) StoreGatewayRecentBlocksPeriod(_ string) time.Duration {
+	return m.storeGatewayRecentBlocksPeriod
+}
+
+func (m *shardingLimitsMock) StoreGatewayRecentBlocksReplicationFactor(_ string) int {
+	return m.storeGatewayRecentBlocksReplicationFactor
 }
 
 func (m *shardingLimitsMock) StoreGatewayTenantShardSize(_ string) int {
@@ -47,6 +47,29 @@ type queryStats struct {
 	chunksFetchedSizeSum   int
 	chunksFetchCount       int
 	chunksFetchDurationSum time.Duration
+	chunksSkippedBytes     int
+	chunksDeduped          int
+	chunksRangeReseeks     int
+
+	// chunksBatchedFetches is the number of range reads batched with other ones of contiguous segment
+	// files, and so issued sequentially instead of as separate concurrent requests.
+	chunksBatchedFetches int
+
+	// chunksEstimatedSizeSum is the size of the chunks fetched, as estimated before fetching them.
+	chunksEstimatedSizeSum int
+
+	// seriesBatches is the number of batches the series chunks have been loaded and sent in,
+	// when streaming the series.
+	seriesBatches int
+
+	// chunksRefetches is the number of chunks refetched with a dedicated range read, because
+	// they're longer than their estimated length.
+	chunksRefetches int
+
+	// histogramChunksTouched and floatHistogramChunksTouched are the number of native histogram
+	// chunks touched, by encoding. They're included in chunksTouched too.
+	histogramChunksTouched      int
+	floatHistogramChunksTouched int
 
 	getAllDuration    time.Duration
 	mergedSeriesCount int
@@ -89,6 +112,15 @@ func (s queryStats) merge(o *queryStats) *queryStats {
 	s.chunksFetchedSizeSum += o.chunksFetchedSizeSum
 	s.chunksFetchCount += o.chunksFetchCount
 	s.chunksFetchDurationSum += o.chunksFetchDurationSum
+	s.chunksSkippedBytes += o.chunksSkippedBytes
+	s.chunksDeduped += o.chunksDeduped
+	s.chunksRangeReseeks += o.chunksRangeReseeks
+	s.chunksBatchedFetches += o.chunksBatchedFetches
+	s.chunksEstimatedSizeSum += o.chunksEstimatedSizeSum
+	s.chunksRefetches += o.chunksRefetches
+	s.histogramChunksTouched += o.histogramChunksTouched
+	s.floatHistogramChunksTouched += o.floatHistogramChunksTouched
+	s.seriesBatches += o.seriesBatches
 
 	s.getAllDuration += o.getAllDuration
 	s.mergedSeriesCount += o.mergedSeriesCount
@@ -98,6 +130,15 @@ func (s queryStats) merge(o *queryStats) *queryStats {
 	return &s
 }
 
+// chunksFetchedEstimateRatio returns the ratio between the chunk bytes actually fetched and the
+// estimated ones, or 0 if no chunk has been fetched.
+func (s queryStats) chunksFetchedEstimateRatio() float64 {
+	if s.chunksEstimatedSizeSum == 0 {
+		return 0
+	}
+	return float64(s.chunksFetchedSizeSum) / float64(s.chunksEstimatedSizeSum)
+}
+
 // safeQueryStats wraps queryStats adding functions manipulate the statistics while holding a lock.
 type safeQueryStats struct {
 	unsafeStatsMx sync.Mutex
@@ -44,3 +44,9 @@ func TestSafeQueryStats_export(t *testing.T) {
 	assert.Equal(t, 20, orig.unsafeStats.blocksQueried)
 	assert.Equal(t, 10, exported.blocksQueried)
 }
+
+func TestQueryStats_chunksFetchedEstimateRatio(t *testing.T) {
+	assert.Equal(t, float64(0), queryStats{}.chunksFetchedEstimateRatio())
+	assert.Equal(t, 0.5, queryStats{chunksEstimatedSizeSum: 2000, chunksFetchedSizeSum: 1000}.chunksFetchedEstimateRatio())
+	assert.Equal(t, 2.0, queryStats{chunksEstimatedSizeSum: 1000, chunksFetchedSizeSum: 2000}.chunksFetchedEstimateRatio())
+}
@@ -32,10 +32,14 @@ func NewHintsSeriesResponse(hints *types.Any) *SeriesResponse {
 	}
 }
 
-func NewStatsResponse(indexBytesFetched int) *SeriesResponse {
+func NewStatsResponse(indexBytesFetched, chunkBytesFetched, chunksFetched int) *SeriesResponse {
 	return &SeriesResponse{
 		Result: &SeriesResponse_Stats{
-			Stats: &Stats{FetchedIndexBytes: uint64(indexBytesFetched)},
+			Stats: &Stats{
+				FetchedIndexBytes: uint64(indexBytesFetched),
+				FetchedChunkBytes: uint64(chunkBytesFetched),
+				FetchedChunks:     uint64(chunksFetched),
+			},
 		},
 	}
 }
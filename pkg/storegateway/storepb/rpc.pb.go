@@ -119,6 +119,11 @@ var xxx_messageInfo_SeriesRequest proto.InternalMessageInfo
 type Stats struct {
 	// This is the sum of all fetched index bytes (postings + series) for a series request.
 	FetchedIndexBytes uint64 `protobuf:"varint,1,opt,name=fetched_index_bytes,json=fetchedIndexBytes,proto3" json:"fetched_index_bytes,omitempty"`
+	// This is the sum of all chunk bytes fetched from the object storage (including the gaps
+	// between chunks read within the same range request) for a series request.
+	FetchedChunkBytes uint64 `protobuf:"varint,2,opt,name=fetched_chunk_bytes,json=fetchedChunkBytes,proto3" json:"fetched_chunk_bytes,omitempty"`
+	// This is the number of chunks fetched from the object storage for a series request.
+	FetchedChunks uint64 `protobuf:"varint,3,opt,name=fetched_chunks,json=fetchedChunks,proto3" json:"fetched_chunks,omitempty"`
 }
 
 func (m *Stats) Reset()      { *m = Stats{} }
@@ -445,58 +450,60 @@ func init() {
 func init() { proto.RegisterFile("rpc.proto", fileDescriptor_77a6da22d6a3feb1) }
 
 var fileDescriptor_77a6da22d6a3feb1 = []byte{
-	// 811 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x93, 0xcd, 0x8e, 0xe3, 0x44,
-	0x10, 0xc7, 0xdd, 0xf1, 0x47, 0x9c, 0xca, 0x87, 0x7a, 0x7b, 0xb2, 0x2b, 0x8f, 0x91, 0xbc, 0x51,
-	0x24, 0xa4, 0x68, 0xb5, 0x64, 0x51, 0x90, 0x40, 0x1c, 0x93, 0x11, 0x68, 0x36, 0x62, 0x06, 0xc9,
-	0xb3, 0xcb, 0x22, 0x2e, 0xc1, 0x49, 0x7a, 0x1d, 0x6b, 0x93, 0x76, 0x70, 0xdb, 0x4c, 0x72, 0xe3,
-	0x11, 0x78, 0x0c, 0x24, 0xce, 0xbc, 0x00, 0xa7, 0xb9, 0x31, 0xc7, 0x39, 0x21, 0x92, 0xb9, 0x70,
-	0x42, 0xf3, 0x08, 0xa8, 0xdb, 0x4e, 0x9c, 0xa0, 0xa0, 0x61, 0x24, 0x4e, 0xee, 0xfa, 0xff, 0xcb,
-	0xdd, 0xd5, 0xbf, 0xaa, 0x86, 0x52, 0x34, 0x1f, 0xb5, 0xe7, 0x51, 0x18, 0x87, 0xc4, 0x88, 0x27,
-	0x1e, 0x0b, 0xb9, 0x5d, 0x8e, 0x97, 0x73, 0xca, 0x53, 0xd1, 0xfe, 0xc0, 0x0f, 0xe2, 0x49, 0x32,
-	0x6c, 0x8f, 0xc2, 0xd9, 0x0b, 0x3f, 0xf4, 0xc3, 0x17, 0x52, 0x1e, 0x26, 0x6f, 0x65, 0x24, 0x03,
-	0xb9, 0xca, 0xd2, 0x8f, 0xfd, 0x30, 0xf4, 0xa7, 0x34, 0xcf, 0xf2, 0xd8, 0x32, 0xb5, 0x9a, 0x7f,
-	0x15, 0xa0, 0x7a, 0x41, 0xa3, 0x80, 0x72, 0x97, 0x7e, 0x97, 0x50, 0x1e, 0x93, 0x63, 0x30, 0x67,
-	0x01, 0x1b, 0xc4, 0xc1, 0x8c, 0x5a, 0xa8, 0x81, 0x5a, 0xaa, 0x5b, 0x9c, 0x05, 0xec, 0x55, 0x30,
-	0xa3, 0xd2, 0xf2, 0x16, 0xa9, 0x55, 0xc8, 0x2c, 0x6f, 0x21, 0xad, 0x8f, 0x85, 0x15, 0x8f, 0x26,
-	0x34, 0xe2, 0x96, 0xda, 0x50, 0x5b, 0xe5, 0x4e, 0xbd, 0x9d, 0x56, 0xde, 0xfe, 0xc2, 0x1b, 0xd2,
-	0xe9, 0x59, 0x6a, 0xf6, 0xb4, 0xab, 0xdf, 0x9f, 0x2a, 0xee, 0x36, 0x97, 0x74, 0xe0, 0xb1, 0xd8,
-	0x32, 0xa2, 0x3c, 0x9c, 0x26, 0x71, 0x10, 0xb2, 0xc1, 0x65, 0xc0, 0xc6, 0xe1, 0xa5, 0xa5, 0xc9,
-	0xfd, 0x8f, 0x66, 0xde, 0xc2, 0xdd, 0x7a, 0x6f, 0xa4, 0x45, 0x9e, 0x03, 0x78, 0xbe, 0x1f, 0x51,
-	0xdf, 0x8b, 0x29, 0xb7, 0xf4, 0x86, 0xda, 0xaa, 0x75, 0x2a, 0x9b, 0xd3, 0xba, 0xbe, 0x1f, 0xb9,
-	0x3b, 0x3e, 0x79, 0x0a, 0x65, 0xfe, 0x2e, 0x98, 0x0f, 0x46, 0x93, 0x84, 0xbd, 0xe3, 0x96, 0xd9,
-	0x40, 0x2d, 0xd3, 0x05, 0x21, 0x9d, 0x48, 0x85, 0x3c, 0x03, 0x7d, 0x12, 0xb0, 0x98, 0x5b, 0xa5,
-	0x06, 0x92, 0x75, 0xa7, 0xb4, 0xda, 0x1b, 0x5a, 0xed, 0x2e, 0x5b, 0xba, 0x69, 0x0a, 0x21, 0xa0,
-	0xf1, 0x98, 0xce, 0x2d, 0x90, 0xd5, 0xc9, 0x35, 0xa9, 0x83, 0x1e, 0x79, 0xcc, 0xa7, 0x56, 0x59,
-	0x8a, 0x69, 0xd0, 0xd7, 0x4c, 0x03, 0x17, 0xfb, 0x9a, 0x59, 0xc4, 0x66, 0x5f, 0x33, 0x2b, 0xb8,
-	0xda, 0xd7, 0xcc, 0x2a, 0xae, 0x35, 0x3f, 0x01, 0xfd, 0x22, 0xf6, 0x62, 0x4e, 0xda, 0x70, 0xf4,
-	0x96, 0x0a, 0x0a, 0xe3, 0x41, 0xc0, 0xc6, 0x74, 0x31, 0x18, 0x2e, 0xc5, 0x75, 0x04, 0x72, 0xcd,
-	0x7d, 0x94, 0x59, 0x2f, 0x85, 0xd3, 0x13, 0x46, 0xf3, 0x17, 0x04, 0xb5, 0x4d, 0xa7, 0xf8, 0x3c,
-	0x64, 0x9c, 0x92, 0x16, 0x18, 0x5c, 0x2a, 0xf2, 0xaf, 0x72, 0xa7, 0xb6, 0x81, 0x90, 0xe6, 0x9d,
-	0x2a, 0x6e, 0xe6, 0x13, 0x1b, 0x8a, 0x97, 0x5e, 0xc4, 0x02, 0xe6, 0xcb, 0xc6, 0x95, 0x4e, 0x15,
-	0x77, 0x23, 0x90, 0xe7, 0x9b, 0xfb, 0xab, 0xff, 0x7e, 0xff, 0x53, 0x65, 0x43, 0xe0, 0x7d, 0xd0,
-	0xb9, 0xa8, 0x5f, 0x36, 0xa8, 0xdc, 0xa9, 0x6e, 0x8f, 0x14, 0xa2, 0x48, 0x93, 0x6e, 0xcf, 0x04,
-	0x23, 0xa2, 0x3c, 0x99, 0xc6, 0xcd, 0x9f, 0x11, 0x3c, 0x92, 0x23, 0x70, 0xee, 0xcd, 0xf2, 0x29,
-	0xab, 0xcb, 0x6d, 0xa2, 0x58, 0x1e, 0xaa, 0xba, 0x69, 0x40, 0x30, 0xa8, 0x94, 0x8d, 0xb3, 0xde,
-	0x8b, 0x65, 0xde, 0x1c, 0xfd, 0xfe, 0xe6, 0xec, 0xce, 0xa0, 0xf1, 0xdf, 0x67, 0xb0, 0xaf, 0x99,
-	0x08, 0x17, 0xfa, 0x9a, 0x59, 0xc0, 0x6a, 0x33, 0x02, 0xb2, 0x5b, 0x6c, 0x06, 0xba, 0x0e, 0x3a,
-	0x13, 0x82, 0x85, 0x1a, 0x6a, 0xab, 0xe4, 0xa6, 0x01, 0xb1, 0xc1, 0xcc, 0x18, 0x72, 0xab, 0x20,
-	0x8d, 0x6d, 0x9c, 0xd7, 0xad, 0xde, 0x5b, 0x77, 0xf3, 0x57, 0x94, 0x1d, 0xfa, 0x95, 0x37, 0x4d,
-	0xf6, 0x10, 0x4d, 0x85, 0x2a, 0x9b, 0x5b, 0x72, 0xd3, 0x20, 0x07, 0xa7, 0x1d, 0x00, 0xa7, 0x1f,
-	0x00, 0x67, 0x3c, 0x0c, 0x5c, 0xf1, 0x41, 0xe0, 0x0a, 0x58, 0xed, 0x6b, 0xa6, 0x8a, 0xb5, 0x66,
-	0x02, 0x47, 0x7b, 0x77, 0xc8, 0xc8, 0x3d, 0x01, 0xe3, 0x7b, 0xa9, 0x64, 0xe8, 0xb2, 0xe8, 0xff,
-	0x62, 0xf7, 0xec, 0x5b, 0xd0, 0xc4, 0x8b, 0x27, 0x15, 0x30, 0xc5, 0x77, 0xe0, 0x76, 0xdf, 0x60,
-	0x85, 0xd4, 0x00, 0x64, 0x74, 0xf2, 0xe5, 0xeb, 0xf3, 0x57, 0x18, 0x6d, 0xdd, 0x8b, 0xd7, 0x67,
-	0xb8, 0xb0, 0x8d, 0xce, 0x5e, 0x9e, 0x63, 0x35, 0x8f, 0xba, 0x5f, 0x63, 0x8d, 0x60, 0xa8, 0xe4,
-	0x7f, 0x7e, 0xe6, 0x62, 0xbd, 0xf3, 0x1b, 0x12, 0x2f, 0x36, 0x8c, 0x28, 0xf9, 0x14, 0x8c, 0xf4,
-	0x61, 0x91, 0xc7, 0xfb, 0x0f, 0x2d, 0xeb, 0x98, 0xfd, 0xe4, 0x9f, 0x72, 0x0a, 0xe1, 0x43, 0x44,
-	0x4e, 0x00, 0xf2, 0xb1, 0x22, 0xc7, 0x7b, 0x74, 0x77, 0xdf, 0x85, 0x6d, 0x1f, 0xb2, 0x32, 0x96,
-	0x9f, 0x43, 0x79, 0x07, 0x31, 0xd9, 0x4f, 0xdd, 0x9b, 0x1d, 0xfb, 0xbd, 0x83, 0x5e, 0xba, 0x4f,
-	0xaf, 0x7b, 0xb5, 0x72, 0x94, 0xeb, 0x95, 0xa3, 0xdc, 0xac, 0x1c, 0xe5, 0x6e, 0xe5, 0xa0, 0x1f,
-	0xd6, 0x0e, 0xfa, 0x69, 0xed, 0xa0, 0xab, 0xb5, 0x83, 0xae, 0xd7, 0x0e, 0xfa, 0x63, 0xed, 0xa0,
-	0x3f, 0xd7, 0x8e, 0x72, 0xb7, 0x76, 0xd0, 0x8f, 0xb7, 0x8e, 0x72, 0x7d, 0xeb, 0x28, 0x37, 0xb7,
-	0x8e, 0xf2, 0x4d, 0x91, 0x0b, 0x10, 0xf3, 0xe1, 0xd0, 0x90, 0xbd, 0xf8, 0xe8, 0xef, 0x00, 0x00,
-	0x00, 0xff, 0xff, 0x4a, 0x86, 0xba, 0x78, 0xa9, 0x06, 0x00, 0x00,
+	// 833 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x94, 0xcf, 0x8b, 0xdb, 0x46,
+	0x14, 0xc7, 0x35, 0xd6, 0x0f, 0xcb, 0xcf, 0x6b, 0x33, 0x99, 0xdd, 0x04, 0xad, 0x0a, 0x8a, 0x31,
+	0x04, 0x4c, 0x48, 0x9d, 0xe2, 0x42, 0xa1, 0x47, 0xef, 0xd2, 0xb2, 0x31, 0xdd, 0x2d, 0x68, 0x93,
+	0xa6, 0xf4, 0xe2, 0xca, 0xbb, 0x13, 0x59, 0xc4, 0x1e, 0xb9, 0x1a, 0xa9, 0x6b, 0xdf, 0xfa, 0x17,
+	0x94, 0xfe, 0x19, 0x85, 0x9e, 0xfb, 0x0f, 0xf4, 0xb4, 0xb7, 0xee, 0x31, 0xa7, 0x52, 0x7b, 0x2f,
+	0x3d, 0x95, 0xfc, 0x09, 0x65, 0x66, 0x64, 0xcb, 0x2a, 0x2e, 0xe9, 0x42, 0x4e, 0x9e, 0xf7, 0xbe,
+	0x5f, 0xcf, 0xbc, 0xf9, 0xbc, 0x37, 0x82, 0x5a, 0x32, 0xbb, 0xe8, 0xce, 0x92, 0x38, 0x8d, 0x89,
+	0x95, 0x8e, 0x03, 0x16, 0x73, 0xb7, 0x9e, 0x2e, 0x66, 0x94, 0xab, 0xa4, 0xfb, 0x61, 0x18, 0xa5,
+	0xe3, 0x6c, 0xd4, 0xbd, 0x88, 0xa7, 0x4f, 0xc3, 0x38, 0x8c, 0x9f, 0xca, 0xf4, 0x28, 0x7b, 0x25,
+	0x23, 0x19, 0xc8, 0x55, 0x6e, 0x3f, 0x0c, 0xe3, 0x38, 0x9c, 0xd0, 0xc2, 0x15, 0xb0, 0x85, 0x92,
+	0xda, 0x7f, 0x57, 0xa0, 0x71, 0x4e, 0x93, 0x88, 0x72, 0x9f, 0x7e, 0x97, 0x51, 0x9e, 0x92, 0x43,
+	0xb0, 0xa7, 0x11, 0x1b, 0xa6, 0xd1, 0x94, 0x3a, 0xa8, 0x85, 0x3a, 0xba, 0x5f, 0x9d, 0x46, 0xec,
+	0x79, 0x34, 0xa5, 0x52, 0x0a, 0xe6, 0x4a, 0xaa, 0xe4, 0x52, 0x30, 0x97, 0xd2, 0x27, 0x42, 0x4a,
+	0x2f, 0xc6, 0x34, 0xe1, 0x8e, 0xde, 0xd2, 0x3b, 0xf5, 0xde, 0x41, 0x57, 0x55, 0xde, 0xfd, 0x22,
+	0x18, 0xd1, 0xc9, 0xa9, 0x12, 0x8f, 0x8c, 0xeb, 0x3f, 0x1e, 0x6a, 0xfe, 0xc6, 0x4b, 0x7a, 0x70,
+	0x5f, 0x6c, 0x99, 0x50, 0x1e, 0x4f, 0xb2, 0x34, 0x8a, 0xd9, 0xf0, 0x2a, 0x62, 0x97, 0xf1, 0x95,
+	0x63, 0xc8, 0xfd, 0xf7, 0xa7, 0xc1, 0xdc, 0xdf, 0x68, 0x2f, 0xa5, 0x44, 0x9e, 0x00, 0x04, 0x61,
+	0x98, 0xd0, 0x30, 0x48, 0x29, 0x77, 0xcc, 0x96, 0xde, 0x69, 0xf6, 0xf6, 0xd6, 0xa7, 0xf5, 0xc3,
+	0x30, 0xf1, 0xb7, 0x74, 0xf2, 0x10, 0xea, 0xfc, 0x75, 0x34, 0x1b, 0x5e, 0x8c, 0x33, 0xf6, 0x9a,
+	0x3b, 0x76, 0x0b, 0x75, 0x6c, 0x1f, 0x44, 0xea, 0x58, 0x66, 0xc8, 0x63, 0x30, 0xc7, 0x11, 0x4b,
+	0xb9, 0x53, 0x6b, 0x21, 0x59, 0xb7, 0xa2, 0xd5, 0x5d, 0xd3, 0xea, 0xf6, 0xd9, 0xc2, 0x57, 0x16,
+	0x42, 0xc0, 0xe0, 0x29, 0x9d, 0x39, 0x20, 0xab, 0x93, 0x6b, 0x72, 0x00, 0x66, 0x12, 0xb0, 0x90,
+	0x3a, 0x75, 0x99, 0x54, 0xc1, 0xc0, 0xb0, 0x2d, 0x5c, 0x1d, 0x18, 0x76, 0x15, 0xdb, 0x03, 0xc3,
+	0xde, 0xc3, 0x8d, 0x81, 0x61, 0x37, 0x70, 0xb3, 0xfd, 0x23, 0x02, 0xf3, 0x3c, 0x0d, 0x52, 0x4e,
+	0xba, 0xb0, 0xff, 0x8a, 0x0a, 0x0c, 0x97, 0xc3, 0x88, 0x5d, 0xd2, 0xf9, 0x70, 0xb4, 0x10, 0xf7,
+	0x11, 0xcc, 0x0d, 0xff, 0x5e, 0x2e, 0x3d, 0x13, 0xca, 0x91, 0x10, 0xb6, 0xfd, 0xf2, 0x2e, 0xb9,
+	0xbf, 0x52, 0xf2, 0xcb, 0x3b, 0x29, 0xff, 0x23, 0x68, 0x96, 0xfc, 0xa2, 0x31, 0xc2, 0xda, 0xd8,
+	0xb6, 0xf2, 0xf6, 0xaf, 0x08, 0x9a, 0xeb, 0x09, 0xe0, 0xb3, 0x98, 0x71, 0x4a, 0x3a, 0x60, 0x71,
+	0x99, 0x91, 0xc5, 0xd4, 0x7b, 0xcd, 0x35, 0x5c, 0xe5, 0x3b, 0xd1, 0xfc, 0x5c, 0x27, 0x2e, 0x54,
+	0xaf, 0x82, 0x84, 0x45, 0x2c, 0x94, 0x75, 0xd4, 0x4e, 0x34, 0x7f, 0x9d, 0x20, 0x4f, 0xd6, 0x5c,
+	0xf5, 0xff, 0xe6, 0x7a, 0xa2, 0xad, 0xc9, 0x3e, 0x02, 0x93, 0x0b, 0x2c, 0xb2, 0xf1, 0xf5, 0x5e,
+	0x63, 0x73, 0xa4, 0x48, 0x0a, 0x9b, 0x54, 0x8f, 0x6c, 0xb0, 0x12, 0xca, 0xb3, 0x49, 0xda, 0xfe,
+	0x05, 0xc1, 0x3d, 0x39, 0x5a, 0x67, 0xc1, 0xb4, 0x98, 0xde, 0x03, 0xb9, 0x4d, 0x92, 0xca, 0x43,
+	0x75, 0x5f, 0x05, 0x04, 0x83, 0x4e, 0xd9, 0x65, 0x3e, 0x53, 0x62, 0x59, 0x34, 0xdd, 0x7c, 0x77,
+	0xd3, 0xb7, 0x67, 0xdb, 0xfa, 0xff, 0xb3, 0x3d, 0x30, 0x6c, 0x84, 0x2b, 0x03, 0xc3, 0xae, 0x60,
+	0xbd, 0x9d, 0x00, 0xd9, 0x2e, 0x36, 0x07, 0x7d, 0x00, 0x26, 0x13, 0x09, 0x07, 0xb5, 0xf4, 0x4e,
+	0xcd, 0x57, 0x01, 0x71, 0xc1, 0xce, 0x19, 0x8a, 0xee, 0x0a, 0x61, 0x13, 0x17, 0x75, 0xeb, 0xef,
+	0xac, 0xbb, 0xfd, 0x1b, 0xca, 0x0f, 0xfd, 0x2a, 0x98, 0x64, 0x25, 0x44, 0x13, 0x91, 0x95, 0xcd,
+	0xad, 0xf9, 0x2a, 0x28, 0xc0, 0x19, 0x3b, 0xc0, 0x99, 0x3b, 0xc0, 0x59, 0x77, 0x03, 0x57, 0xbd,
+	0x13, 0xb8, 0x0a, 0xd6, 0x07, 0x86, 0xad, 0x63, 0xa3, 0x9d, 0xc1, 0x7e, 0xe9, 0x0e, 0x39, 0xb9,
+	0x07, 0x60, 0x7d, 0x2f, 0x33, 0x39, 0xba, 0x3c, 0x7a, 0x5f, 0xec, 0x1e, 0x7f, 0x0b, 0x86, 0xf8,
+	0x92, 0x90, 0x3d, 0xb0, 0xc5, 0xef, 0xd0, 0xef, 0xbf, 0xc4, 0x1a, 0x69, 0x02, 0xc8, 0xe8, 0xf8,
+	0xcb, 0x17, 0x67, 0xcf, 0x31, 0xda, 0xa8, 0xe7, 0x2f, 0x4e, 0x71, 0x65, 0x13, 0x9d, 0x3e, 0x3b,
+	0xc3, 0x7a, 0x11, 0xf5, 0xbf, 0xc6, 0x06, 0xc1, 0xb0, 0x57, 0xfc, 0xf3, 0x33, 0x1f, 0x9b, 0xbd,
+	0xdf, 0xe5, 0x87, 0x20, 0x4e, 0x28, 0xf9, 0x14, 0x2c, 0xf5, 0xb0, 0xc8, 0xfd, 0xf2, 0x43, 0xcb,
+	0x3b, 0xe6, 0x3e, 0xf8, 0x77, 0x5a, 0x41, 0xf8, 0x08, 0x91, 0x63, 0x80, 0x62, 0xac, 0xc8, 0x61,
+	0x89, 0xee, 0xf6, 0xbb, 0x70, 0xdd, 0x5d, 0x52, 0xce, 0xf2, 0x73, 0xa8, 0x6f, 0x21, 0x26, 0x65,
+	0x6b, 0x69, 0x76, 0xdc, 0x0f, 0x76, 0x6a, 0x6a, 0x9f, 0xa3, 0xfe, 0xf5, 0xd2, 0xd3, 0x6e, 0x96,
+	0x9e, 0xf6, 0x66, 0xe9, 0x69, 0x6f, 0x97, 0x1e, 0xfa, 0x61, 0xe5, 0xa1, 0x9f, 0x57, 0x1e, 0xba,
+	0x5e, 0x79, 0xe8, 0x66, 0xe5, 0xa1, 0x3f, 0x57, 0x1e, 0xfa, 0x6b, 0xe5, 0x69, 0x6f, 0x57, 0x1e,
+	0xfa, 0xe9, 0xd6, 0xd3, 0x6e, 0x6e, 0x3d, 0xed, 0xcd, 0xad, 0xa7, 0x7d, 0x53, 0xe5, 0x02, 0xc4,
+	0x6c, 0x34, 0xb2, 0x64, 0x2f, 0x3e, 0xfe, 0x67, 0x00, 0xfd, 0x5d, 0x34, 0x52, 0x01, 0x07, 0x00,
+	0x00,
 }
 
 func (x Aggr) String() string {
@@ -586,6 +593,12 @@ func (this *Stats) Equal(that interface{}) bool {
 	if this.FetchedIndexBytes != that1.FetchedIndexBytes {
 		return false
 	}
+	if this.FetchedChunkBytes != that1.FetchedChunkBytes {
+		return false
+	}
+	if this.FetchedChunks != that1.FetchedChunks {
+		return false
+	}
 	return true
 }
 func (this *SeriesResponse) Equal(that interface{}) bool {
@@ -903,9 +916,11 @@ func (this *Stats) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 5)
+	s := make([]string, 0, 7)
 	s = append(s, "&storepb.Stats{")
 	s = append(s, "FetchedIndexBytes: "+fmt.Sprintf("%#v", this.FetchedIndexBytes)+",\n")
+	s = append(s, "FetchedChunkBytes: "+fmt.Sprintf("%#v", this.FetchedChunkBytes)+",\n")
+	s = append(s, "FetchedChunks: "+fmt.Sprintf("%#v", this.FetchedChunks)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -1357,6 +1372,16 @@ func (m *Stats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.FetchedChunks != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.FetchedChunks))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.FetchedChunkBytes != 0 {
+		i = encodeVarintRpc(dAtA, i, uint64(m.FetchedChunkBytes))
+		i--
+		dAtA[i] = 0x10
+	}
 	if m.FetchedIndexBytes != 0 {
 		i = encodeVarintRpc(dAtA, i, uint64(m.FetchedIndexBytes))
 		i--
@@ -1765,6 +1790,12 @@ func (m *Stats) Size() (n int) {
 	if m.FetchedIndexBytes != 0 {
 		n += 1 + sovRpc(uint64(m.FetchedIndexBytes))
 	}
+	if m.FetchedChunkBytes != 0 {
+		n += 1 + sovRpc(uint64(m.FetchedChunkBytes))
+	}
+	if m.FetchedChunks != 0 {
+		n += 1 + sovRpc(uint64(m.FetchedChunks))
+	}
 	return n
 }
 
@@ -1965,6 +1996,8 @@ func (this *Stats) String() string {
 	}
 	s := strings.Join([]string{`&Stats{`,
 		`FetchedIndexBytes:` + fmt.Sprintf("%v", this.FetchedIndexBytes) + `,`,
+		`FetchedChunkBytes:` + fmt.Sprintf("%v", this.FetchedChunkBytes) + `,`,
+		`FetchedChunks:` + fmt.Sprintf("%v", this.FetchedChunks) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -2443,6 +2476,44 @@ func (m *Stats) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedChunkBytes", wireType)
+			}
+			m.FetchedChunkBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedChunkBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedChunks", wireType)
+			}
+			m.FetchedChunks = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRpc
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedChunks |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRpc(dAtA[iNdEx:])
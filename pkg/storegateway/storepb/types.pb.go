@@ -32,15 +32,21 @@ const _ = proto.GoGoProtoPackageIsVersion3 // please upgrade the proto package
 type Chunk_Encoding int32
 
 const (
-	Chunk_XOR Chunk_Encoding = 0
+	Chunk_XOR            Chunk_Encoding = 0
+	Chunk_Histogram      Chunk_Encoding = 1
+	Chunk_FloatHistogram Chunk_Encoding = 2
 )
 
 var Chunk_Encoding_name = map[int32]string{
 	0: "Chunk_XOR",
+	1: "Chunk_Histogram",
+	2: "Chunk_FloatHistogram",
 }
 
 var Chunk_Encoding_value = map[string]int32{
-	"Chunk_XOR": 0,
+	"Chunk_XOR":            0,
+	"Chunk_Histogram":      1,
+	"Chunk_FloatHistogram": 2,
 }
 
 func (Chunk_Encoding) EnumDescriptor() ([]byte, []int) {
@@ -157,6 +163,9 @@ type AggrChunk struct {
 	Min     *Chunk `protobuf:"bytes,6,opt,name=min,proto3" json:"min,omitempty"`
 	Max     *Chunk `protobuf:"bytes,7,opt,name=max,proto3" json:"max,omitempty"`
 	Counter *Chunk `protobuf:"bytes,8,opt,name=counter,proto3" json:"counter,omitempty"`
+	// Whether the chunk overlaps the edges of the queried time range, so it contains samples
+	// outside of it which need to be trimmed.
+	Boundary bool `protobuf:"varint,9,opt,name=boundary,proto3" json:"boundary,omitempty"`
 }
 
 func (m *AggrChunk) Reset()      { *m = AggrChunk{} }
@@ -242,42 +251,44 @@ func init() {
 func init() { proto.RegisterFile("types.proto", fileDescriptor_d938547f84707355) }
 
 var fileDescriptor_d938547f84707355 = []byte{
-	// 545 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0xbd, 0x8e, 0xd3, 0x40,
-	0x10, 0xc7, 0xbd, 0xf9, 0x70, 0x92, 0xcd, 0x1d, 0x98, 0xbd, 0x13, 0x72, 0xae, 0xd8, 0x44, 0xa6,
-	0x20, 0x42, 0x3a, 0x07, 0x42, 0x45, 0x99, 0xa0, 0x74, 0x7c, 0x9d, 0xb9, 0x02, 0x21, 0xa4, 0x68,
-	0xed, 0x6c, 0x9c, 0xd5, 0xc5, 0x6b, 0x6b, 0xbd, 0x86, 0x5c, 0xc7, 0x23, 0x20, 0xf1, 0x04, 0x74,
-	0xbc, 0x08, 0x52, 0xca, 0x94, 0x27, 0x8a, 0x13, 0x71, 0x1a, 0xca, 0x7b, 0x04, 0xe4, 0x75, 0x02,
-	0x89, 0x2e, 0x05, 0x95, 0x67, 0xf6, 0xff, 0xfb, 0xcf, 0xcc, 0x8e, 0xd6, 0xb0, 0x2e, 0x2f, 0x23,
-	0x1a, 0xdb, 0x91, 0x08, 0x65, 0x88, 0x74, 0x39, 0x21, 0x3c, 0x8c, 0x4f, 0x4e, 0x7d, 0x26, 0x27,
-	0x89, 0x6b, 0x7b, 0x61, 0xd0, 0xf1, 0x43, 0x3f, 0xec, 0x28, 0xd9, 0x4d, 0xc6, 0x2a, 0x53, 0x89,
-	0x8a, 0x72, 0xdb, 0xc9, 0xe3, 0x6d, 0x5c, 0x90, 0x31, 0xe1, 0xa4, 0x13, 0xb0, 0x80, 0x89, 0x4e,
-	0x74, 0xe1, 0xe7, 0x51, 0xe4, 0xe6, 0xdf, 0xdc, 0x61, 0xb9, 0xb0, 0xfc, 0x7c, 0x92, 0xf0, 0x0b,
-	0xf4, 0x08, 0x96, 0xb2, 0x01, 0x4c, 0xd0, 0x02, 0xed, 0x3b, 0xdd, 0xfb, 0x76, 0x3e, 0x80, 0xad,
-	0x44, 0x7b, 0xc0, 0xbd, 0x70, 0xc4, 0xb8, 0xef, 0x28, 0x06, 0x21, 0x58, 0x1a, 0x11, 0x49, 0xcc,
-	0x42, 0x0b, 0xb4, 0x0f, 0x1c, 0x15, 0x5b, 0x0d, 0x58, 0xdd, 0x50, 0xe8, 0x10, 0xd6, 0x94, 0x6f,
-	0xf8, 0xee, 0xb5, 0x63, 0x68, 0xd6, 0x37, 0x00, 0xf5, 0xb7, 0x54, 0x30, 0x1a, 0xa3, 0x31, 0xd4,
-	0xa7, 0xc4, 0xa5, 0xd3, 0xd8, 0x04, 0xad, 0x62, 0xbb, 0xde, 0x3d, 0xb2, 0xbd, 0x50, 0x48, 0x3a,
-	0x8b, 0x5c, 0xfb, 0x45, 0x76, 0xfe, 0x86, 0x30, 0xd1, 0x7f, 0x36, 0xbf, 0x6e, 0x6a, 0x3f, 0xaf,
-	0x9b, 0x4f, 0xfe, 0xe7, 0x36, 0xb9, 0xaf, 0x37, 0x22, 0x91, 0xa4, 0xc2, 0x59, 0x57, 0x47, 0x1d,
-	0xa8, 0x7b, 0xd9, 0x04, 0xb1, 0x59, 0x50, 0x7d, 0xee, 0x6d, 0xee, 0xd3, 0xf3, 0x7d, 0xa1, 0x66,
-	0xeb, 0x97, 0xb2, 0x2e, 0xce, 0x1a, 0xb3, 0xbe, 0x16, 0x60, 0xed, 0xaf, 0x86, 0x1a, 0xb0, 0x1a,
-	0x30, 0x3e, 0x94, 0x2c, 0xc8, 0x17, 0x52, 0x74, 0x2a, 0x01, 0xe3, 0xe7, 0x2c, 0xa0, 0x4a, 0x22,
-	0xb3, 0x5c, 0x2a, 0xac, 0x25, 0x32, 0x53, 0x52, 0x13, 0x16, 0x05, 0xf9, 0x64, 0x16, 0x5b, 0xa0,
-	0x5d, 0xef, 0x1e, 0xee, 0x6c, 0xd0, 0xc9, 0x14, 0xf4, 0x00, 0x96, 0xbd, 0x30, 0xe1, 0xd2, 0x2c,
-	0xed, 0x43, 0x72, 0x2d, 0xab, 0x12, 0x27, 0x81, 0x59, 0xde, 0x5b, 0x25, 0x4e, 0x82, 0x0c, 0x08,
-	0x18, 0x37, 0xf5, 0xbd, 0x40, 0xc0, 0xb8, 0x02, 0xc8, 0xcc, 0xac, 0xec, 0x07, 0xc8, 0x0c, 0x3d,
-	0x84, 0x15, 0xd5, 0x8b, 0x0a, 0xb3, 0xba, 0x0f, 0xda, 0xa8, 0xd6, 0x0f, 0x00, 0x0f, 0xd4, 0x7e,
-	0x5f, 0x12, 0xe9, 0x4d, 0xa8, 0x40, 0xa7, 0x3b, 0xaf, 0xa4, 0xb1, 0xb1, 0x6d, 0x33, 0xf6, 0xf9,
-	0x65, 0x44, 0xff, 0x3d, 0x14, 0x4e, 0xd6, 0x8b, 0xaa, 0x39, 0x2a, 0x46, 0xc7, 0xb0, 0xfc, 0x91,
-	0x4c, 0x13, 0xaa, 0xf6, 0x54, 0x73, 0xf2, 0xc4, 0xfa, 0x00, 0x4b, 0x99, 0x0f, 0x1d, 0xc1, 0xbb,
-	0xdb, 0xc5, 0x86, 0x83, 0x33, 0x43, 0x43, 0xc7, 0xd0, 0xd8, 0x39, 0x7c, 0x35, 0x38, 0x33, 0xc0,
-	0x2d, 0xd4, 0x19, 0x18, 0x85, 0xdb, 0xa8, 0x33, 0x30, 0x8a, 0xfd, 0xde, 0x7c, 0x89, 0xb5, 0xc5,
-	0x12, 0x6b, 0x57, 0x4b, 0xac, 0xdd, 0x2c, 0x31, 0xf8, 0x9c, 0x62, 0xf0, 0x3d, 0xc5, 0x60, 0x9e,
-	0x62, 0xb0, 0x48, 0x31, 0xf8, 0x95, 0x62, 0xf0, 0x3b, 0xc5, 0xda, 0x4d, 0x8a, 0xc1, 0x97, 0x15,
-	0xd6, 0x16, 0x2b, 0xac, 0x5d, 0xad, 0xb0, 0xf6, 0xbe, 0x12, 0xcb, 0x50, 0xd0, 0xc8, 0x75, 0x75,
-	0xf5, 0xbf, 0x3c, 0xfd, 0x13, 0x00, 0x00, 0xff, 0xff, 0x2b, 0x46, 0xb2, 0x4d, 0xa7, 0x03, 0x00,
-	0x00,
+	// 583 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x93, 0x3d, 0x6f, 0x13, 0x31,
+	0x18, 0xc7, 0xcf, 0x79, 0x8f, 0xdb, 0xc2, 0xe1, 0x56, 0xe8, 0xda, 0xc1, 0x8d, 0xc2, 0x40, 0x84,
+	0xd4, 0x0b, 0x94, 0x89, 0xb1, 0x45, 0x41, 0x1d, 0x78, 0xab, 0xe9, 0x80, 0x10, 0x52, 0xe5, 0x4b,
+	0xdc, 0x8b, 0xd5, 0xd8, 0x3e, 0xf9, 0x7c, 0x90, 0x6e, 0x7c, 0x04, 0xf8, 0x08, 0x6c, 0x6c, 0x7c,
+	0x0a, 0xa4, 0x8e, 0x1d, 0x2b, 0x86, 0x8a, 0x5c, 0x17, 0xc6, 0x7e, 0x04, 0x74, 0x76, 0xd2, 0x17,
+	0x35, 0x03, 0xd3, 0x3d, 0xcf, 0xf3, 0xff, 0x3d, 0x2f, 0x7e, 0xf4, 0x1c, 0x5c, 0x30, 0x47, 0x09,
+	0x4b, 0xc3, 0x44, 0x2b, 0xa3, 0x50, 0xcd, 0x0c, 0xa9, 0x54, 0xe9, 0xda, 0x46, 0xcc, 0xcd, 0x30,
+	0x8b, 0xc2, 0xbe, 0x12, 0xdd, 0x58, 0xc5, 0xaa, 0x6b, 0xe5, 0x28, 0x3b, 0xb0, 0x9e, 0x75, 0xac,
+	0xe5, 0xd2, 0xd6, 0x1e, 0x5f, 0xc7, 0x35, 0x3d, 0xa0, 0x92, 0x76, 0x05, 0x17, 0x5c, 0x77, 0x93,
+	0xc3, 0xd8, 0x59, 0x49, 0xe4, 0xbe, 0x2e, 0xa3, 0xfd, 0x0d, 0xc0, 0xea, 0xf3, 0x61, 0x26, 0x0f,
+	0xd1, 0x23, 0x58, 0x29, 0x26, 0x08, 0x40, 0x0b, 0x74, 0xee, 0x6c, 0xde, 0x0f, 0xdd, 0x04, 0xa1,
+	0x15, 0xc3, 0x9e, 0xec, 0xab, 0x01, 0x97, 0x31, 0xb1, 0x0c, 0x42, 0xb0, 0x32, 0xa0, 0x86, 0x06,
+	0xa5, 0x16, 0xe8, 0x2c, 0x12, 0x6b, 0xb7, 0x77, 0x60, 0x63, 0x46, 0xa1, 0x25, 0xd8, 0xb4, 0x79,
+	0xfb, 0xef, 0xdf, 0x10, 0xdf, 0x43, 0xcb, 0xf0, 0xae, 0x73, 0x77, 0x78, 0x6a, 0x54, 0xac, 0xa9,
+	0xf0, 0x01, 0x0a, 0xe0, 0x8a, 0x0b, 0xbe, 0x18, 0x29, 0x6a, 0xae, 0x94, 0x52, 0xfb, 0x3b, 0x80,
+	0xb5, 0x77, 0x4c, 0x73, 0x96, 0xa2, 0x03, 0x58, 0x1b, 0xd1, 0x88, 0x8d, 0xd2, 0x00, 0xb4, 0xca,
+	0x9d, 0x85, 0xcd, 0xe5, 0xb0, 0xaf, 0xb4, 0x61, 0xe3, 0x24, 0x0a, 0x5f, 0x16, 0xf1, 0xb7, 0x94,
+	0xeb, 0xed, 0x67, 0xc7, 0x67, 0xeb, 0xde, 0xef, 0xb3, 0xf5, 0x27, 0xff, 0xf3, 0x7a, 0x97, 0xb7,
+	0x35, 0xa0, 0x89, 0x61, 0x9a, 0x4c, 0xab, 0xa3, 0x2e, 0xac, 0xf5, 0x8b, 0x61, 0xd2, 0xa0, 0x64,
+	0xfb, 0xdc, 0x9b, 0x3d, 0x7f, 0x2b, 0x8e, 0xb5, 0x1d, 0x73, 0xbb, 0x52, 0x74, 0x21, 0x53, 0xac,
+	0xfd, 0xb3, 0x04, 0x9b, 0x97, 0x1a, 0x5a, 0x85, 0x0d, 0xc1, 0xe5, 0xbe, 0xe1, 0xc2, 0xed, 0xaf,
+	0x4c, 0xea, 0x82, 0xcb, 0x3d, 0x2e, 0x98, 0x95, 0xe8, 0xd8, 0x49, 0xa5, 0xa9, 0x44, 0xc7, 0x56,
+	0x5a, 0x87, 0x65, 0x4d, 0x3f, 0x07, 0xe5, 0x16, 0xe8, 0x2c, 0x6c, 0x2e, 0xdd, 0x58, 0x38, 0x29,
+	0x14, 0xf4, 0x00, 0x56, 0xfb, 0x2a, 0x93, 0x26, 0xa8, 0xcc, 0x43, 0x9c, 0x56, 0x54, 0x49, 0x33,
+	0x11, 0x54, 0xe7, 0x56, 0x49, 0x33, 0x51, 0x00, 0x82, 0xcb, 0xa0, 0x36, 0x17, 0x10, 0x5c, 0x5a,
+	0x80, 0x8e, 0x83, 0xfa, 0x7c, 0x80, 0x8e, 0xd1, 0x43, 0x58, 0xb7, 0xbd, 0x98, 0x0e, 0x1a, 0xf3,
+	0xa0, 0x99, 0x8a, 0xd6, 0x60, 0x23, 0x52, 0x99, 0x1c, 0x50, 0x7d, 0x14, 0x34, 0x5b, 0xa0, 0xd3,
+	0x20, 0x97, 0x7e, 0xfb, 0x17, 0x80, 0x8b, 0x76, 0xf7, 0xaf, 0xa8, 0xe9, 0x0f, 0x99, 0x46, 0x1b,
+	0x37, 0x0e, 0x6e, 0x75, 0x56, 0xf2, 0x3a, 0x13, 0xee, 0x1d, 0x25, 0xec, 0xea, 0xe6, 0x24, 0x9d,
+	0x2e, 0xb1, 0x49, 0xac, 0x8d, 0x56, 0x60, 0xf5, 0x13, 0x1d, 0x65, 0xcc, 0xee, 0xb0, 0x49, 0x9c,
+	0xd3, 0xfe, 0x08, 0x2b, 0x45, 0x5e, 0x71, 0x76, 0xd7, 0x8b, 0xed, 0xf7, 0x76, 0x7d, 0x0f, 0xad,
+	0x40, 0xff, 0x46, 0xf0, 0x75, 0x6f, 0xd7, 0x07, 0xb7, 0x50, 0xd2, 0xf3, 0x4b, 0xb7, 0x51, 0xd2,
+	0xf3, 0xcb, 0xdb, 0x5b, 0xc7, 0x13, 0xec, 0x9d, 0x4c, 0xb0, 0x77, 0x3a, 0xc1, 0xde, 0xc5, 0x04,
+	0x83, 0x2f, 0x39, 0x06, 0x3f, 0x72, 0x0c, 0x8e, 0x73, 0x0c, 0x4e, 0x72, 0x0c, 0xfe, 0xe4, 0x18,
+	0xfc, 0xcd, 0xb1, 0x77, 0x91, 0x63, 0xf0, 0xf5, 0x1c, 0x7b, 0x27, 0xe7, 0xd8, 0x3b, 0x3d, 0xc7,
+	0xde, 0x87, 0x7a, 0x6a, 0x94, 0x66, 0x49, 0x14, 0xd5, 0xec, 0xbf, 0xf7, 0xf4, 0xdf, 0x00, 0xf3,
+	0x36, 0xdf, 0x53, 0xf3, 0x03, 0x00, 0x00,
 }
 
 func (x Chunk_Encoding) String() string {
@@ -401,6 +412,9 @@ func (this *AggrChunk) Equal(that interface{}) bool {
 	if !this.Counter.Equal(that1.Counter) {
 		return false
 	}
+	if this.Boundary != that1.Boundary {
+		return false
+	}
 	return true
 }
 func (this *LabelMatcher) Equal(that interface{}) bool {
@@ -465,7 +479,7 @@ func (this *AggrChunk) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 12)
+	s := make([]string, 0, 13)
 	s = append(s, "&storepb.AggrChunk{")
 	s = append(s, "MinTime: "+fmt.Sprintf("%#v", this.MinTime)+",\n")
 	s = append(s, "MaxTime: "+fmt.Sprintf("%#v", this.MaxTime)+",\n")
@@ -487,6 +501,7 @@ func (this *AggrChunk) GoString() string {
 	if this.Counter != nil {
 		s = append(s, "Counter: "+fmt.Sprintf("%#v", this.Counter)+",\n")
 	}
+	s = append(s, "Boundary: "+fmt.Sprintf("%#v", this.Boundary)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -616,6 +631,16 @@ func (m *AggrChunk) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if m.Boundary {
+		i--
+		if m.Boundary {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i--
+		dAtA[i] = 0x48
+	}
 	if m.Counter != nil {
 		{
 			size, err := m.Counter.MarshalToSizedBuffer(dAtA[:i])
@@ -827,6 +852,9 @@ func (m *AggrChunk) Size() (n int) {
 		l = m.Counter.Size()
 		n += 1 + l + sovTypes(uint64(l))
 	}
+	if m.Boundary {
+		n += 2
+	}
 	return n
 }
 
@@ -896,6 +924,7 @@ func (this *AggrChunk) String() string {
 		`Min:` + strings.Replace(this.Min.String(), "Chunk", "Chunk", 1) + `,`,
 		`Max:` + strings.Replace(this.Max.String(), "Chunk", "Chunk", 1) + `,`,
 		`Counter:` + strings.Replace(this.Counter.String(), "Chunk", "Chunk", 1) + `,`,
+		`Boundary:` + fmt.Sprintf("%v", this.Boundary) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1430,6 +1459,26 @@ func (m *AggrChunk) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 9:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Boundary", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowTypes
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.Boundary = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipTypes(dAtA[iNdEx:])
@@ -17,6 +17,7 @@ const (
 	MissingMetricName             ID = "missing-metric-name"
 	InvalidMetricName             ID = "metric-name-invalid"
 	MaxLabelNamesPerSeries        ID = "max-label-names-per-series"
+	MaxLabelsSizeBytes            ID = "max-labels-size-bytes"
 	SeriesInvalidLabel            ID = "label-invalid"
 	SeriesLabelNameTooLong        ID = "label-name-too-long"
 	SeriesLabelValueTooLong       ID = "label-value-too-long"
@@ -30,6 +31,7 @@ const (
 	MaxChunksPerQuery             ID = "max-chunks-per-query"
 	MaxSeriesPerQuery             ID = "max-series-per-query"
 	MaxChunkBytesPerQuery         ID = "max-chunks-bytes-per-query"
+	MaxExemplarsPerQuery          ID = "max-exemplars-per-query"
 
 	DistributorMaxIngestionRate             ID = "distributor-max-ingestion-rate"
 	DistributorMaxInflightPushRequests      ID = "distributor-max-inflight-push-requests"
@@ -49,11 +51,14 @@ const (
 	MetricMetadataHelpTooLong       ID = "help-too-long" // unused, left here to prevent reuse for different purpose
 	MetricMetadataUnitTooLong       ID = "unit-too-long"
 
-	MaxQueryLength       ID = "max-query-length"
-	MaxTotalQueryLength  ID = "max-total-query-length"
-	RequestRateLimited   ID = "tenant-max-request-rate"
-	IngestionRateLimited ID = "tenant-max-ingestion-rate"
-	TooManyHAClusters    ID = "tenant-too-many-ha-clusters"
+	MaxQueryLength            ID = "max-query-length"
+	MaxTotalQueryLength       ID = "max-total-query-length"
+	MaxEstimatedQueryCost     ID = "max-estimated-query-cost"
+	MaxResponseSize           ID = "max-response-size"
+	RequestRateLimited        ID = "tenant-max-request-rate"
+	IngestionRateLimited      ID = "tenant-max-ingestion-rate"
+	RulerIngestionRateLimited ID = "tenant-max-ruler-ingestion-rate"
+	TooManyHAClusters         ID = "tenant-too-many-ha-clusters"
 
 	SampleTimestampTooOld    ID = "sample-timestamp-too-old"
 	SampleOutOfOrder         ID = "sample-out-of-order"
@@ -39,6 +39,9 @@ type BucketedBytes struct {
 	usedTotal uint64
 	mtx       sync.Mutex
 
+	// allocations is the number of byte slices allocated because no pooled one could be reused.
+	allocations uint64
+
 	new func(s int) *[]byte
 }
 
@@ -92,6 +95,7 @@ func (p *BucketedBytes) Get(sz int) (*[]byte, error) {
 		b, ok := p.buckets[i].Get().(*[]byte)
 		if !ok {
 			b = p.new(bktSize)
+			p.allocations++
 		}
 
 		p.usedTotal += uint64(cap(*b))
@@ -100,6 +104,7 @@ func (p *BucketedBytes) Get(sz int) (*[]byte, error) {
 
 	// The requested size exceeds that of our highest bucket, allocate it directly.
 	p.usedTotal += uint64(sz)
+	p.allocations++
 	return p.new(sz), nil
 }
 
@@ -129,3 +134,20 @@ func (p *BucketedBytes) Put(b *[]byte) {
 		p.usedTotal -= uint64(sz)
 	}
 }
+
+// UsedBytes returns the number of bytes of the byte slices got from the pool and not put back yet.
+func (p *BucketedBytes) UsedBytes() uint64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.usedTotal
+}
+
+// Allocations returns the number of byte slices allocated by the pool because no pooled
+// byte slice could be reused.
+func (p *BucketedBytes) Allocations() uint64 {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return p.allocations
+}
@@ -49,6 +49,15 @@ func TestBytesPool(t *testing.T) {
 	// Outside of any bucket.
 	b, err := chunkPool.Get(1000)
 	require.NoError(t, err)
+	require.Equal(t, uint64(1000), chunkPool.UsedBytes())
+	allocations := chunkPool.Allocations()
+	chunkPool.Put(b)
+	require.Equal(t, uint64(0), chunkPool.UsedBytes())
+
+	// Slices outside of any bucket are never reused.
+	b, err = chunkPool.Get(1000)
+	require.NoError(t, err)
+	require.Equal(t, allocations+1, chunkPool.Allocations())
 	chunkPool.Put(b)
 
 	// Check size limitation.
@@ -33,8 +33,9 @@ const (
 	pbContentType   = "application/x-protobuf"
 	jsonContentType = "application/json"
 
-	otelParseError = "otlp_parse_error"
-	maxErrMsgLen   = 1024
+	otelParseError                      = "otlp_parse_error"
+	otelUnsupportedExponentialHistogram = "otlp_unsupported_exponential_histogram"
+	maxErrMsgLen                        = 1024
 )
 
 func OTLPHandler(
@@ -45,6 +46,7 @@ func OTLPHandler(
 	push Func,
 ) http.Handler {
 	discardedDueToOtelParseError := validation.DiscardedSamplesCounter(reg, otelParseError)
+	discardedDueToUnsupportedExponentialHistogram := validation.DiscardedSamplesCounter(reg, otelUnsupportedExponentialHistogram)
 
 	return handler(maxRecvMsgSize, sourceIPs, allowSkipLabelNameValidation, push, func(ctx context.Context, r *http.Request, maxRecvMsgSize int, dst []byte, req *mimirpb.PreallocWriteRequest) ([]byte, error) {
 		var decoderFunc func(buf []byte) (pmetricotlp.Request, error)
@@ -113,7 +115,7 @@ func OTLPHandler(
 			return body, err
 		}
 
-		metrics, err := otelMetricsToTimeseries(ctx, discardedDueToOtelParseError, logger, otlpReq.Metrics())
+		metrics, err := otelMetricsToTimeseries(ctx, discardedDueToOtelParseError, discardedDueToUnsupportedExponentialHistogram, logger, otlpReq.Metrics())
 		if err != nil {
 			return body, err
 		}
@@ -123,7 +125,17 @@ func OTLPHandler(
 	})
 }
 
-func otelMetricsToTimeseries(ctx context.Context, discardedDueToOtelParseError *prometheus.CounterVec, logger kitlog.Logger, md pmetric.Metrics) ([]mimirpb.PreallocTimeseries, error) {
+func otelMetricsToTimeseries(ctx context.Context, discardedDueToOtelParseError, discardedDueToUnsupportedExponentialHistogram *prometheus.CounterVec, logger kitlog.Logger, md pmetric.Metrics) ([]mimirpb.PreallocTimeseries, error) {
+	if dropped := removeExponentialHistograms(md); dropped > 0 {
+		userID, err := tenant.TenantID(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		discardedDueToUnsupportedExponentialHistogram.WithLabelValues(userID).Add(float64(dropped))
+		level.Warn(logger).Log("msg", "dropped OTLP exponential histogram datapoints because they're not supported", "datapoints", dropped)
+	}
+
 	tsMap, errs := prometheusremotewrite.FromMetrics(md, prometheusremotewrite.Settings{})
 
 	if errs != nil {
@@ -229,6 +241,29 @@ func TimeseriesToOTLPRequest(timeseries []prompb.TimeSeries) pmetricotlp.Request
 	return pmetricotlp.NewRequestFromMetrics(d)
 }
 
+// removeExponentialHistograms removes the exponential histogram metrics from md, and returns
+// the number of datapoints removed. They are dropped rather than converted into native histograms
+// because native histograms are not supported yet: once they are, the exponential histograms
+// should be converted instead of removed.
+func removeExponentialHistograms(md pmetric.Metrics) int {
+	dropped := 0
+	resourceMetrics := md.ResourceMetrics()
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		scopeMetrics := resourceMetrics.At(i).ScopeMetrics()
+		for j := 0; j < scopeMetrics.Len(); j++ {
+			scopeMetrics.At(j).Metrics().RemoveIf(func(metric pmetric.Metric) bool {
+				if metric.DataType() != pmetric.MetricDataTypeExponentialHistogram {
+					return false
+				}
+				dropped += metric.ExponentialHistogram().DataPoints().Len()
+				return true
+			})
+		}
+	}
+
+	return dropped
+}
+
 func sampleCountInMap(tsMap map[string]*prompb.TimeSeries) int {
 	count := 0
 	for _, ts := range tsMap {
@@ -38,6 +38,9 @@ var bufferPool = sync.Pool{
 }
 
 const SkipLabelNameValidationHeader = "X-Mimir-SkipLabelNameValidation"
+
+// IdempotencyKeyHeader is the header clients can set to identify a push request among its retries.
+const IdempotencyKeyHeader = "Idempotency-Key"
 const statusClientClosedRequest = 499
 
 // Handler is a http.Handler which accepts WriteRequests.
@@ -115,6 +118,7 @@ func handler(maxRecvMsgSize int,
 			return &req.WriteRequest, cleanup, nil
 		}
 		req := newRequest(supplier)
+		req.IdempotencyKey = r.Header.Get(IdempotencyKeyHeader)
 		if _, err := push(ctx, req); err != nil {
 			if errors.Is(err, context.Canceled) {
 				http.Error(w, err.Error(), statusClientClosedRequest)
@@ -13,12 +13,15 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/snappy"
 	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/stretchr/testify/assert"
@@ -100,6 +103,34 @@ func TestHandler_otlpDroppedMetricsPanic(t *testing.T) {
 	assert.Equal(t, 200, resp.Code)
 }
 
+func TestHandler_otlpExponentialHistogramsDropped(t *testing.T) {
+	md := createOTLPMetricRequest(t).Metrics()
+
+	metric := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	metric.SetName("exp_histogram")
+	metric.SetDataType(pmetric.MetricDataTypeExponentialHistogram)
+	metric.ExponentialHistogram().SetAggregationTemporality(pmetric.MetricAggregationTemporalityCumulative)
+	for i := 0; i < 2; i++ {
+		datapoint := metric.ExponentialHistogram().DataPoints().AppendEmpty()
+		datapoint.SetTimestamp(pcommon.NewTimestampFromTime(time.Now()))
+		datapoint.SetCount(10)
+		datapoint.SetSum(100)
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	req := createOTLPRequest(t, pmetricotlp.NewRequestFromMetrics(md), false)
+	resp := httptest.NewRecorder()
+	handler := OTLPHandler(100000, nil, false, reg, verifyWritePushFunc(t, mimirpb.API))
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, 200, resp.Code)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_discarded_samples_total The total number of samples that were discarded.
+		# TYPE cortex_discarded_samples_total counter
+		cortex_discarded_samples_total{reason="otlp_unsupported_exponential_histogram",user="test"} 2
+	`), "cortex_discarded_samples_total"))
+}
+
 func TestHandler_otlpWriteWithCompression(t *testing.T) {
 	req := createOTLPRequest(t, createOTLPMetricRequest(t), true)
 	resp := httptest.NewRecorder()
@@ -175,6 +206,25 @@ func TestHandler_contextCanceledRequest(t *testing.T) {
 	assert.Equal(t, 499, resp.Code)
 }
 
+func TestHandler_idempotencyKey(t *testing.T) {
+	for _, key := range []string{"", "some-key"} {
+		t.Run(fmt.Sprintf("key=%q", key), func(t *testing.T) {
+			req := createRequest(t, createPrometheusRemoteWriteProtobuf(t))
+			if key != "" {
+				req.Header.Set(IdempotencyKeyHeader, key)
+			}
+			resp := httptest.NewRecorder()
+			handler := Handler(100000, nil, false, func(_ context.Context, pushReq *Request) (*mimirpb.WriteResponse, error) {
+				defer pushReq.CleanUp()
+				assert.Equal(t, key, pushReq.IdempotencyKey)
+				return &mimirpb.WriteResponse{}, nil
+			})
+			handler.ServeHTTP(resp, req)
+			assert.Equal(t, 200, resp.Code)
+		})
+	}
+}
+
 func TestHandler_EnsureSkipLabelNameValidationBehaviour(t *testing.T) {
 	tests := []struct {
 		name                                      string
@@ -14,6 +14,9 @@ type supplierFunc func() (req *mimirpb.WriteRequest, cleanup func(), err error)
 // Request represents a push request. It allows lazy body reading from the underlying http request
 // and adding cleanup functions that should be called after the request has been handled.
 type Request struct {
+	// IdempotencyKey is the optional key, set by the client, identifying the request among its retries.
+	IdempotencyKey string
+
 	// have a backing array to avoid extra allocations
 	cleanupsArr [10]func()
 	cleanups    []func()
@@ -0,0 +1,76 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+)
+
+// DebugSeriesLogger logs what happens to the samples of the series matching the tenant's debug series
+// selector while going through the write path, to the logs and to the request's trace. A nil
+// DebugSeriesLogger, returned if the tenant has no debug series selector, logs nothing.
+type DebugSeriesLogger struct {
+	logger   log.Logger
+	matchers []*labels.Matcher
+}
+
+// NewDebugSeriesLogger makes a new DebugSeriesLogger for the tenant, or returns nil if the tenant has
+// no debug series selector.
+func NewDebugSeriesLogger(ctx context.Context, fallback log.Logger, limits *Overrides, userID string) *DebugSeriesLogger {
+	matchers := limits.DebugSeriesMatchers(userID)
+	if len(matchers) == 0 {
+		return nil
+	}
+
+	return &DebugSeriesLogger{
+		// The span logger adds the tenant ID to the logs.
+		logger:   spanlogger.FromContext(ctx, fallback),
+		matchers: matchers,
+	}
+}
+
+// Matches returns whether the series matches the tenant's debug series selector.
+func (l *DebugSeriesLogger) Matches(series []mimirpb.LabelAdapter) bool {
+	if l == nil {
+		return false
+	}
+
+	for _, m := range l.matchers {
+		value := ""
+		for _, lbl := range series {
+			if lbl.Name == m.Name {
+				value = lbl.Value
+				break
+			}
+		}
+		if !m.Matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Log logs the outcome of the samples of the series. The caller is expected to check that the series
+// Matches the debug series selector first.
+func (l *DebugSeriesLogger) Log(series labels.Labels, samples int, outcome string, keyvals ...interface{}) {
+	if l == nil {
+		return
+	}
+
+	level.Info(l.logger).Log(append([]interface{}{"msg", "debug series", "series", series.String(), "samples", samples, "outcome", outcome}, keyvals...)...)
+}
+
+func parseDebugSeriesSelector(selector string) ([]*labels.Matcher, error) {
+	if selector == "" {
+		return nil, nil
+	}
+	return parser.ParseMetricSelector(selector)
+}
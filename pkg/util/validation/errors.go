@@ -116,6 +116,26 @@ func (e tooManyLabelsError) Error() string {
 		maxLabelNamesPerSeriesFlag)
 }
 
+type labelsSizeTooLargeError struct {
+	series []mimirpb.LabelAdapter
+	size   int
+	limit  int
+}
+
+func newLabelsSizeTooLargeError(series []mimirpb.LabelAdapter, size, limit int) ValidationError {
+	return labelsSizeTooLargeError{
+		series: series,
+		size:   size,
+		limit:  limit,
+	}
+}
+
+func (e labelsSizeTooLargeError) Error() string {
+	return globalerror.MaxLabelsSizeBytes.MessageWithPerTenantLimitConfig(
+		fmt.Sprintf("received a series whose labels size exceeds the limit (actual: %d bytes, limit: %d bytes) series: '%.200s'", e.size, e.limit, formatLabelSet(e.series)),
+		maxLabelsSizeBytesFlag)
+}
+
 type noMetricNameError struct{}
 
 func newNoMetricNameError() ValidationError {
@@ -269,6 +289,24 @@ func NewMaxTotalQueryLengthError(actualQueryLen, maxTotalQueryLength time.Durati
 		maxTotalQueryLengthFlag))
 }
 
+func NewMaxExemplarsPerQueryError(maxExemplars int) LimitError {
+	return LimitError(globalerror.MaxExemplarsPerQuery.MessageWithPerTenantLimitConfig(
+		fmt.Sprintf("the query exceeded the maximum number of exemplars (limit: %d exemplars)", maxExemplars),
+		MaxExemplarsPerQueryFlag))
+}
+
+func NewMaxEstimatedQueryCostError(estimatedCost, maxEstimatedCost int) LimitError {
+	return LimitError(globalerror.MaxEstimatedQueryCost.MessageWithPerTenantLimitConfig(
+		fmt.Sprintf("the estimated query cost exceeds the limit (estimated cost: %d, limit: %d)", estimatedCost, maxEstimatedCost),
+		maxEstimatedQueryCostFlag))
+}
+
+func NewMaxResponseSizeError(maxResponseSizeBytes int) LimitError {
+	return LimitError(globalerror.MaxResponseSize.MessageWithPerTenantLimitConfig(
+		fmt.Sprintf("the query response is larger than the max response size (limit: %d bytes)", maxResponseSizeBytes),
+		maxResponseSizeBytesFlag))
+}
+
 func NewRequestRateLimitedError(limit float64, burst int) LimitError {
 	return LimitError(globalerror.RequestRateLimited.MessageWithPerTenantLimitConfig(
 		fmt.Sprintf("the request has been rejected because the tenant exceeded the request rate limit, set to %v requests/s across all distributors with a maximum allowed burst of %d", limit, burst),
@@ -281,6 +319,12 @@ func NewIngestionRateLimitedError(limit float64, burst int) LimitError {
 		ingestionRateFlag, ingestionBurstSizeFlag))
 }
 
+func NewRulerIngestionRateLimitedError(limit float64, burst int) LimitError {
+	return LimitError(globalerror.RulerIngestionRateLimited.MessageWithPerTenantLimitConfig(
+		fmt.Sprintf("the request has been rejected because the tenant exceeded the ingestion rate limit of the samples written by the ruler, set to %v items/s with a maximum allowed burst of %d. This limit is applied on the total number of samples, exemplars and metadata written by the ruler across all distributors", limit, burst),
+		rulerIngestionRateFlag, rulerIngestionBurstFlag))
+}
+
 // formatLabelSet formats label adapters as a metric name with labels, while preserving
 // label order, and keeping duplicates. If there are multiple "__name__" labels, only
 // first one is used as metric name, other ones will be included as regular labels.
@@ -36,6 +36,16 @@ func TestNewTotalMaxQueryLengthError(t *testing.T) {
 	assert.Equal(t, "the total query time range exceeds the limit (query length: 1h0m0s, limit: 1m0s) (err-mimir-max-total-query-length). To adjust the related per-tenant limit, configure -query-frontend.max-total-query-length, or contact your service administrator.", err.Error())
 }
 
+func TestNewMaxEstimatedQueryCostError(t *testing.T) {
+	err := NewMaxEstimatedQueryCostError(2000, 1000)
+	assert.Equal(t, "the estimated query cost exceeds the limit (estimated cost: 2000, limit: 1000) (err-mimir-max-estimated-query-cost). To adjust the related per-tenant limit, configure -query-frontend.max-estimated-query-cost, or contact your service administrator.", err.Error())
+}
+
+func TestNewMaxResponseSizeError(t *testing.T) {
+	err := NewMaxResponseSizeError(1024)
+	assert.Equal(t, "the query response is larger than the max response size (limit: 1024 bytes) (err-mimir-max-response-size). To adjust the related per-tenant limit, configure -query-frontend.max-response-size-bytes, or contact your service administrator.", err.Error())
+}
+
 func TestNewRequestRateLimitedError(t *testing.T) {
 	err := NewRequestRateLimitedError(10, 5)
 	assert.Equal(t, "the request has been rejected because the tenant exceeded the request rate limit, set to 10 requests/s across all distributors with a maximum allowed burst of 5 (err-mimir-tenant-max-request-rate). To adjust the related per-tenant limits, configure -distributor.request-rate-limit and -distributor.request-burst-size, or contact your service administrator.", err.Error())
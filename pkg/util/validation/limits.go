@@ -11,17 +11,20 @@ import (
 	"flag"
 	"fmt"
 	"math"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/grafana/dskit/flagext"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/relabel"
 	"golang.org/x/time/rate"
 	"gopkg.in/yaml.v3"
 
 	"github.com/grafana/mimir/pkg/ingester/activeseries"
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/util"
 )
 
 const (
@@ -32,23 +35,44 @@ const (
 	MaxChunksPerQueryFlag      = "querier.max-fetched-chunks-per-query"
 	MaxChunkBytesPerQueryFlag  = "querier.max-fetched-chunk-bytes-per-query"
 	MaxSeriesPerQueryFlag      = "querier.max-fetched-series-per-query"
+	MaxExemplarsPerQueryFlag   = "querier.max-fetched-exemplars-per-query"
+	ExemplarsRetentionFlag     = "ingester.exemplars-retention-period"
 	maxLabelNamesPerSeriesFlag = "validation.max-label-names-per-series"
 	maxLabelNameLengthFlag     = "validation.max-length-label-name"
 	maxLabelValueLengthFlag    = "validation.max-length-label-value"
+	labelValueStrategyFlag     = "validation.label-value-length-over-limit-strategy"
+	maxLabelsSizeBytesFlag     = "validation.max-labels-size-bytes"
 	maxMetadataLengthFlag      = "validation.max-metadata-length"
 	creationGracePeriodFlag    = "validation.create-grace-period"
 	maxQueryLengthFlag         = "store.max-query-length"
 	maxTotalQueryLengthFlag    = "query-frontend.max-total-query-length"
+	maxEstimatedQueryCostFlag  = "query-frontend.max-estimated-query-cost"
+	maxResponseSizeBytesFlag   = "query-frontend.max-response-size-bytes"
 	requestRateFlag            = "distributor.request-rate-limit"
 	requestBurstSizeFlag       = "distributor.request-burst-size"
 	ingestionRateFlag          = "distributor.ingestion-rate-limit"
 	ingestionBurstSizeFlag     = "distributor.ingestion-burst-size"
+	rulerIngestionRateFlag     = "distributor.ruler-ingestion-rate-limit"
+	rulerIngestionBurstFlag    = "distributor.ruler-ingestion-burst-size"
+	debugSeriesSelectorFlag    = "distributor.debug-series-selector"
 	HATrackerMaxClustersFlag   = "distributor.ha-tracker.max-clusters"
 
 	// MinCompactorPartialBlockDeletionDelay is the minimum partial blocks deletion delay that can be configured in Mimir.
 	MinCompactorPartialBlockDeletionDelay = 4 * time.Hour
+
+	// ResultsCacheCompressionNone disables the compression of the tenant's query-frontend results cache entries.
+	ResultsCacheCompressionNone = "none"
+
+	// LabelValueLengthOverLimitStrategyError rejects the series with a label value exceeding the max length.
+	LabelValueLengthOverLimitStrategyError = "error"
+	// LabelValueLengthOverLimitStrategyTruncate truncates the label values exceeding the max length.
+	LabelValueLengthOverLimitStrategyTruncate = "truncate"
 )
 
+var supportedResultsCacheCompressions = []string{ResultsCacheCompressionNone, "snappy", "zstd"}
+
+var supportedLabelValueLengthOverLimitStrategies = []string{LabelValueLengthOverLimitStrategyError, LabelValueLengthOverLimitStrategyTruncate}
+
 // LimitError are errors that do not comply with the limits specified.
 type LimitError string
 
@@ -64,6 +88,19 @@ type ForwardingRule struct {
 // ForwardingRules are keyed by metric names, excluding labels.
 type ForwardingRules map[string]ForwardingRule
 
+// BlockedQuery is a query rejected by the query-frontend.
+type BlockedQuery struct {
+	// Pattern is the PromQL expression of the blocked query or, if Regex is true, a regular expression
+	// matching the whole PromQL expression.
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Regex   bool   `yaml:"regex" json:"regex"`
+}
+
+// Regexp returns the regular expression of the pattern, anchored to match the whole PromQL expression.
+func (q *BlockedQuery) Regexp() (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + q.Pattern + ")$")
+}
+
 // Limits describe all the limits for users; can be used to describe global default
 // limits via flags, or per-user limits via yaml config.
 type Limits struct {
@@ -72,6 +109,9 @@ type Limits struct {
 	RequestBurstSize          int                 `yaml:"request_burst_size" json:"request_burst_size" category:"experimental"`
 	IngestionRate             float64             `yaml:"ingestion_rate" json:"ingestion_rate"`
 	IngestionBurstSize        int                 `yaml:"ingestion_burst_size" json:"ingestion_burst_size"`
+	RulerIngestionRate        float64             `yaml:"ruler_ingestion_rate" json:"ruler_ingestion_rate" category:"experimental"`
+	RulerIngestionBurstSize   int                 `yaml:"ruler_ingestion_burst_size" json:"ruler_ingestion_burst_size" category:"experimental"`
+	DebugSeriesSelector       string              `yaml:"debug_series_selector" json:"debug_series_selector" category:"experimental"`
 	AcceptHASamples           bool                `yaml:"accept_ha_samples" json:"accept_ha_samples"`
 	HAClusterLabel            string              `yaml:"ha_cluster_label" json:"ha_cluster_label"`
 	HAReplicaLabel            string              `yaml:"ha_replica_label" json:"ha_replica_label"`
@@ -79,6 +119,8 @@ type Limits struct {
 	DropLabels                flagext.StringSlice `yaml:"drop_labels" json:"drop_labels" category:"advanced"`
 	MaxLabelNameLength        int                 `yaml:"max_label_name_length" json:"max_label_name_length"`
 	MaxLabelValueLength       int                 `yaml:"max_label_value_length" json:"max_label_value_length"`
+	LabelValueLengthStrategy  string              `yaml:"label_value_length_over_limit_strategy" json:"label_value_length_over_limit_strategy" category:"experimental"`
+	MaxLabelsSizeBytes        int                 `yaml:"max_labels_size_bytes" json:"max_labels_size_bytes" category:"experimental"`
 	MaxLabelNamesPerSeries    int                 `yaml:"max_label_names_per_series" json:"max_label_names_per_series"`
 	MaxMetadataLength         int                 `yaml:"max_metadata_length" json:"max_metadata_length"`
 	CreationGracePeriod       model.Duration      `yaml:"creation_grace_period" json:"creation_grace_period" category:"advanced"`
@@ -94,28 +136,42 @@ type Limits struct {
 	MaxGlobalMetricsWithMetadataPerUser int `yaml:"max_global_metadata_per_user" json:"max_global_metadata_per_user"`
 	MaxGlobalMetadataPerMetric          int `yaml:"max_global_metadata_per_metric" json:"max_global_metadata_per_metric"`
 	// Exemplars
-	MaxGlobalExemplarsPerUser int `yaml:"max_global_exemplars_per_user" json:"max_global_exemplars_per_user" category:"experimental"`
+	MaxGlobalExemplarsPerUser int            `yaml:"max_global_exemplars_per_user" json:"max_global_exemplars_per_user" category:"experimental"`
+	ExemplarsRetentionPeriod  model.Duration `yaml:"exemplars_retention_period" json:"exemplars_retention_period" category:"experimental"`
+	MaxExemplarsPerQuery      int            `yaml:"max_fetched_exemplars_per_query" json:"max_fetched_exemplars_per_query" category:"experimental"`
 	// Active series custom trackers
 	ActiveSeriesCustomTrackersConfig activeseries.CustomTrackersConfig `yaml:"active_series_custom_trackers" json:"active_series_custom_trackers" doc:"description=Additional custom trackers for active metrics. If there are active series matching a provided matcher (map value), the count will be exposed in the custom trackers metric labeled using the tracker name (map key). Zero valued counts are not exposed (and removed when they go back to zero)." category:"advanced"`
 	// Max allowed time window for out-of-order samples.
 	OutOfOrderTimeWindow model.Duration `yaml:"out_of_order_time_window" json:"out_of_order_time_window" category:"experimental"`
 
 	// Querier enforced limits.
-	MaxChunksPerQuery              int            `yaml:"max_fetched_chunks_per_query" json:"max_fetched_chunks_per_query"`
-	MaxFetchedSeriesPerQuery       int            `yaml:"max_fetched_series_per_query" json:"max_fetched_series_per_query"`
-	MaxFetchedChunkBytesPerQuery   int            `yaml:"max_fetched_chunk_bytes_per_query" json:"max_fetched_chunk_bytes_per_query"`
-	MaxQueryLookback               model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
-	MaxQueryLength                 model.Duration `yaml:"max_query_length" json:"max_query_length"`
-	MaxQueryParallelism            int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
-	MaxLabelsQueryLength           model.Duration `yaml:"max_labels_query_length" json:"max_labels_query_length"`
-	MaxCacheFreshness              model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness" category:"advanced"`
-	MaxQueriersPerTenant           int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
-	QueryShardingTotalShards       int            `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
-	QueryShardingMaxShardedQueries int            `yaml:"query_sharding_max_sharded_queries" json:"query_sharding_max_sharded_queries"`
-	SplitInstantQueriesByInterval  model.Duration `yaml:"split_instant_queries_by_interval" json:"split_instant_queries_by_interval" category:"experimental"`
+	MaxChunksPerQuery                 int            `yaml:"max_fetched_chunks_per_query" json:"max_fetched_chunks_per_query"`
+	MaxFetchedSeriesPerQuery          int            `yaml:"max_fetched_series_per_query" json:"max_fetched_series_per_query"`
+	MaxFetchedChunkBytesPerQuery      int            `yaml:"max_fetched_chunk_bytes_per_query" json:"max_fetched_chunk_bytes_per_query"`
+	MaxQueryLookback                  model.Duration `yaml:"max_query_lookback" json:"max_query_lookback"`
+	MaxQueryLength                    model.Duration `yaml:"max_query_length" json:"max_query_length"`
+	MaxQueryParallelism               int            `yaml:"max_query_parallelism" json:"max_query_parallelism"`
+	MaxLabelsQueryLength              model.Duration `yaml:"max_labels_query_length" json:"max_labels_query_length"`
+	MaxCacheFreshness                 model.Duration `yaml:"max_cache_freshness" json:"max_cache_freshness" category:"advanced"`
+	MaxQueriersPerTenant              int            `yaml:"max_queriers_per_tenant" json:"max_queriers_per_tenant"`
+	QueryShardingTotalShards          int            `yaml:"query_sharding_total_shards" json:"query_sharding_total_shards"`
+	QueryShardingMaxShardedQueries    int            `yaml:"query_sharding_max_sharded_queries" json:"query_sharding_max_sharded_queries"`
+	QueryShardingVerticalEnabled      bool           `yaml:"query_sharding_vertical_enabled" json:"query_sharding_vertical_enabled" category:"experimental"`
+	QueryShardingTargetSeriesPerShard int            `yaml:"query_sharding_target_series_per_shard" json:"query_sharding_target_series_per_shard" category:"experimental"`
+	SplitInstantQueriesByInterval     model.Duration `yaml:"split_instant_queries_by_interval" json:"split_instant_queries_by_interval" category:"experimental"`
 
 	// Query-frontend limits.
-	MaxTotalQueryLength model.Duration `yaml:"max_total_query_length,omitempty" json:"max_total_query_length,omitempty" category:"experimental"`
+	MaxTotalQueryLength          model.Duration  `yaml:"max_total_query_length,omitempty" json:"max_total_query_length,omitempty" category:"experimental"`
+	MaxQueryIntoFuture           model.Duration  `yaml:"max_query_into_future" json:"max_query_into_future" category:"experimental"`
+	QueryTimeout                 model.Duration  `yaml:"query_timeout" json:"query_timeout" category:"experimental"`
+	SlowQueryLogThreshold        model.Duration  `yaml:"slow_query_log_threshold" json:"slow_query_log_threshold" category:"experimental"`
+	MaxEstimatedQueryCost        int             `yaml:"max_estimated_query_cost" json:"max_estimated_query_cost" category:"experimental"`
+	MaxResponseSizeBytes         int             `yaml:"max_response_size_bytes" json:"max_response_size_bytes" category:"experimental"`
+	BlockedQueries               []*BlockedQuery `yaml:"blocked_queries,omitempty" json:"blocked_queries,omitempty" doc:"nocli|description=List of queries rejected by the query-frontend with HTTP status code 422. Each entry has a pattern, matched against the PromQL expression of the query, and a regex flag: if false, the pattern must be equal to the expression, ignoring formatting differences; if true, the pattern is a regular expression which must match the whole expression." category:"experimental"`
+	ResultsCacheTTL              model.Duration  `yaml:"results_cache_ttl" json:"results_cache_ttl" category:"experimental"`
+	ResultsCacheMaxItemSizeBytes int             `yaml:"results_cache_max_item_size_bytes" json:"results_cache_max_item_size_bytes" category:"experimental"`
+	ResultsCacheCompression      string          `yaml:"results_cache_compression" json:"results_cache_compression" category:"experimental"`
+	QueryStepAlignEnabled        bool            `yaml:"query_step_align_enabled" json:"query_step_align_enabled" category:"experimental"`
 
 	// Cardinality
 	CardinalityAnalysisEnabled                    bool `yaml:"cardinality_analysis_enabled" json:"cardinality_analysis_enabled"`
@@ -129,17 +185,25 @@ type Limits struct {
 	RulerMaxRuleGroupsPerTenant          int            `yaml:"ruler_max_rule_groups_per_tenant" json:"ruler_max_rule_groups_per_tenant"`
 	RulerRecordingRulesEvaluationEnabled bool           `yaml:"ruler_recording_rules_evaluation_enabled" json:"ruler_recording_rules_evaluation_enabled" category:"experimental"`
 	RulerAlertingRulesEvaluationEnabled  bool           `yaml:"ruler_alerting_rules_evaluation_enabled" json:"ruler_alerting_rules_evaluation_enabled" category:"experimental"`
+	RulerMaxConcurrentEvaluations        int            `yaml:"ruler_max_concurrent_evaluations" json:"ruler_max_concurrent_evaluations" category:"experimental"`
+	RulerRuleGroupEvaluationDeadline     model.Duration `yaml:"ruler_rule_group_evaluation_deadline" json:"ruler_rule_group_evaluation_deadline" category:"experimental"`
 
 	// Store-gateway.
-	StoreGatewayTenantShardSize int `yaml:"store_gateway_tenant_shard_size" json:"store_gateway_tenant_shard_size"`
+	StoreGatewayTenantShardSize               int            `yaml:"store_gateway_tenant_shard_size" json:"store_gateway_tenant_shard_size"`
+	StoreGatewayFetchedBytesRateLimit         float64        `yaml:"store_gateway_fetched_bytes_rate_limit" json:"store_gateway_fetched_bytes_rate_limit" category:"experimental"`
+	StoreGatewayFetchedBytesBurstSize         int            `yaml:"store_gateway_fetched_bytes_burst_size" json:"store_gateway_fetched_bytes_burst_size" category:"experimental"`
+	StoreGatewayIndexCacheMaxSizeBytes        int            `yaml:"store_gateway_index_cache_max_size_bytes" json:"store_gateway_index_cache_max_size_bytes" category:"experimental"`
+	StoreGatewayRecentBlocksPeriod            model.Duration `yaml:"store_gateway_recent_blocks_period" json:"store_gateway_recent_blocks_period" category:"experimental"`
+	StoreGatewayRecentBlocksReplicationFactor int            `yaml:"store_gateway_recent_blocks_replication_factor" json:"store_gateway_recent_blocks_replication_factor" category:"experimental"`
 
 	// Compactor.
-	CompactorBlocksRetentionPeriod     model.Duration `yaml:"compactor_blocks_retention_period" json:"compactor_blocks_retention_period"`
-	CompactorSplitAndMergeShards       int            `yaml:"compactor_split_and_merge_shards" json:"compactor_split_and_merge_shards"`
-	CompactorSplitGroups               int            `yaml:"compactor_split_groups" json:"compactor_split_groups"`
-	CompactorTenantShardSize           int            `yaml:"compactor_tenant_shard_size" json:"compactor_tenant_shard_size"`
-	CompactorPartialBlockDeletionDelay model.Duration `yaml:"compactor_partial_block_deletion_delay" json:"compactor_partial_block_deletion_delay"`
-	CompactorBlockUploadEnabled        bool           `yaml:"compactor_block_upload_enabled" json:"compactor_block_upload_enabled"`
+	CompactorBlocksRetentionPeriod     model.Duration         `yaml:"compactor_blocks_retention_period" json:"compactor_blocks_retention_period"`
+	CompactorSplitAndMergeShards       int                    `yaml:"compactor_split_and_merge_shards" json:"compactor_split_and_merge_shards"`
+	CompactorSplitGroups               int                    `yaml:"compactor_split_groups" json:"compactor_split_groups"`
+	CompactorTenantShardSize           int                    `yaml:"compactor_tenant_shard_size" json:"compactor_tenant_shard_size"`
+	CompactorPartialBlockDeletionDelay model.Duration         `yaml:"compactor_partial_block_deletion_delay" json:"compactor_partial_block_deletion_delay"`
+	CompactorBlockUploadEnabled        bool                   `yaml:"compactor_block_upload_enabled" json:"compactor_block_upload_enabled"`
+	CompactorLargeCompactionsWindows   flagext.StringSliceCSV `yaml:"compactor_large_compactions_windows" json:"compactor_large_compactions_windows" category:"experimental"`
 
 	// This config doesn't have a CLI flag registered here because they're registered in
 	// their own original config struct.
@@ -173,6 +237,9 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.RequestBurstSize, requestBurstSizeFlag, 0, "Per-tenant allowed request burst size. 0 to disable.")
 	f.Float64Var(&l.IngestionRate, ingestionRateFlag, 10000, "Per-tenant ingestion rate limit in samples per second.")
 	f.IntVar(&l.IngestionBurstSize, ingestionBurstSizeFlag, 200000, "Per-tenant allowed ingestion burst size (in number of samples).")
+	f.Float64Var(&l.RulerIngestionRate, rulerIngestionRateFlag, 0, "Per-tenant ingestion rate limit in samples per second for the samples written by the ruler. If greater than 0, the samples written by the ruler are rate limited by this limit instead of the tenant ingestion rate limit. If negative, the samples written by the ruler are not rate limited. 0 to rate limit them with the tenant ingestion rate limit.")
+	f.IntVar(&l.RulerIngestionBurstSize, rulerIngestionBurstFlag, 0, fmt.Sprintf("Per-tenant allowed ingestion burst size (in number of samples) for the samples written by the ruler, when -%s is greater than 0. 0 to use the tenant ingestion burst size.", rulerIngestionRateFlag))
+	f.StringVar(&l.DebugSeriesSelector, debugSeriesSelectorFlag, "", `Series selector, for example {__name__="up",job="node"}. Distributors and ingesters log what happens to the samples of the series matching it, for example whether they're accepted, deduplicated or rejected and why, to troubleshoot missing series. Empty to disable.`)
 	f.BoolVar(&l.AcceptHASamples, "distributor.ha-tracker.enable-for-all-users", false, "Flag to enable, for all tenants, handling of samples with external labels identifying replicas in an HA Prometheus setup.")
 	f.StringVar(&l.HAClusterLabel, "distributor.ha-tracker.cluster", "cluster", "Prometheus label to look for in samples to identify a Prometheus HA cluster.")
 	f.StringVar(&l.HAReplicaLabel, "distributor.ha-tracker.replica", "__replica__", "Prometheus label to look for in samples to identify a Prometheus HA replica.")
@@ -180,7 +247,9 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&l.DropLabels, "distributor.drop-label", "This flag can be used to specify label names that to drop during sample ingestion within the distributor and can be repeated in order to drop multiple labels.")
 	f.IntVar(&l.MaxLabelNameLength, maxLabelNameLengthFlag, 1024, "Maximum length accepted for label names")
 	f.IntVar(&l.MaxLabelValueLength, maxLabelValueLengthFlag, 2048, "Maximum length accepted for label value. This setting also applies to the metric name")
+	f.StringVar(&l.LabelValueLengthStrategy, labelValueStrategyFlag, LabelValueLengthOverLimitStrategyError, fmt.Sprintf("What to do with the series having a label value longer than -%s. Supported values: %s. With 'error' the series is rejected, with 'truncate' the label value is truncated to the max length and suffixed with the hash of the original value. The metric name is never truncated.", maxLabelValueLengthFlag, strings.Join(supportedLabelValueLengthOverLimitStrategies, ", ")))
 	f.IntVar(&l.MaxLabelNamesPerSeries, maxLabelNamesPerSeriesFlag, 30, "Maximum number of label names per series.")
+	f.IntVar(&l.MaxLabelsSizeBytes, maxLabelsSizeBytesFlag, 0, "Maximum combined size, in bytes, of the label names and values of a series, including the metric name. 0 to disable.")
 	f.IntVar(&l.MaxMetadataLength, maxMetadataLengthFlag, 1024, "Maximum length accepted for metric metadata. Metadata refers to Metric Name, HELP and UNIT. Longer metadata is dropped except for HELP which is truncated.")
 	_ = l.CreationGracePeriod.Set("10m")
 	f.Var(&l.CreationGracePeriod, creationGracePeriodFlag, "Controls how far into the future incoming samples are accepted compared to the wall clock. Any sample with timestamp `t` will be rejected if `t > (now + validation.create-grace-period)`. Also used by query-frontend to avoid querying too far into the future. 0 to disable.")
@@ -192,6 +261,8 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxGlobalMetricsWithMetadataPerUser, MaxMetadataPerUserFlag, 0, "The maximum number of in-memory metrics with metadata per tenant, across the cluster. 0 to disable.")
 	f.IntVar(&l.MaxGlobalMetadataPerMetric, MaxMetadataPerMetricFlag, 0, "The maximum number of metadata per metric, across the cluster. 0 to disable.")
 	f.IntVar(&l.MaxGlobalExemplarsPerUser, "ingester.max-global-exemplars-per-user", 0, "The maximum number of exemplars in memory, across the cluster. 0 to disable exemplars ingestion.")
+	f.Var(&l.ExemplarsRetentionPeriod, ExemplarsRetentionFlag, "If greater than 0, the distributor drops the exemplars older than this period, and the ingester doesn't return them to exemplar queries, even if they are still in memory. 0 to disable.")
+	f.IntVar(&l.MaxExemplarsPerQuery, MaxExemplarsPerQueryFlag, 0, "The maximum number of exemplars that a single exemplar query can return. This limit is enforced in the querier. 0 to disable.")
 	f.Var(&l.ActiveSeriesCustomTrackersConfig, "ingester.active-series-custom-trackers", "Additional active series metrics, matching the provided matchers. Matchers should be in form <name>:<matcher>, like 'foobar:{foo=\"bar\"}'. Multiple matchers can be provided either providing the flag multiple times or providing multiple semicolon-separated values to a single flag.")
 	f.Var(&l.OutOfOrderTimeWindow, "ingester.out-of-order-time-window", "Non-zero value enables out-of-order support for most recent samples that are within the time window in relation to the TSDB's maximum time, i.e., within [db.maxTime-timeWindow, db.maxTime]). The ingester will need more memory as a factor of rate of out-of-order samples being ingested and the number of series that are getting out-of-order samples. A lower TTL of 10 minutes will be set for the query cache entries that overlap with this window.")
 
@@ -210,6 +281,8 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxQueriersPerTenant, "query-frontend.max-queriers-per-tenant", 0, "Maximum number of queriers that can handle requests for a single tenant. If set to 0 or value higher than number of available queriers, *all* queriers will handle requests for the tenant. Each frontend (or query-scheduler, if used) will select the same set of queriers for the same tenant (given that all queriers are connected to all frontends / query-schedulers). This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL.")
 	f.IntVar(&l.QueryShardingTotalShards, "query-frontend.query-sharding-total-shards", 16, "The amount of shards to use when doing parallelisation via query sharding by tenant. 0 to disable query sharding for tenant. Query sharding implementation will adjust the number of query shards based on compactor shards. This allows querier to not search the blocks which cannot possibly have the series for given query shard.")
 	f.IntVar(&l.QueryShardingMaxShardedQueries, "query-frontend.query-sharding-max-sharded-queries", 128, "The max number of sharded queries that can be run for a given received query. 0 to disable limit.")
+	f.BoolVar(&l.QueryShardingVerticalEnabled, "query-frontend.query-sharding-vertical-enabled", false, "True to shard by series the queries without aggregations, like rate(foo[5m]), and concatenate the results of the shards in the query-frontend. The queries with sum, count, min, max and avg aggregations are sharded regardless of this setting.")
+	f.IntVar(&l.QueryShardingTargetSeriesPerShard, "query-frontend.query-sharding-target-series-per-shard", 0, "The target number of series per shard. When set, the query-frontend estimates the number of series selected by a query from the ingesters cardinality analysis, which must be enabled for the tenant, and shards the query accordingly, up to -query-frontend.query-sharding-total-shards shards. 0 to always use -query-frontend.query-sharding-total-shards shards.")
 	f.Var(&l.SplitInstantQueriesByInterval, "query-frontend.split-instant-queries-by-interval", "Split instant queries by an interval and execute in parallel. 0 to disable it.")
 
 	f.Var(&l.RulerEvaluationDelay, "ruler.evaluation-delay-duration", "Duration to delay the evaluation of rules to ensure the underlying metrics have been pushed.")
@@ -218,6 +291,8 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.RulerMaxRuleGroupsPerTenant, "ruler.max-rule-groups-per-tenant", 70, "Maximum number of rule groups per-tenant. 0 to disable.")
 	f.BoolVar(&l.RulerRecordingRulesEvaluationEnabled, "ruler.recording-rules-evaluation-enabled", true, "Controls whether recording rules evaluation is enabled. This configuration option can be used to forcefully disable recording rules evaluation on a per-tenant basis.")
 	f.BoolVar(&l.RulerAlertingRulesEvaluationEnabled, "ruler.alerting-rules-evaluation-enabled", true, "Controls whether alerting rules evaluation is enabled. This configuration option can be used to forcefully disable alerting rules evaluation on a per-tenant basis.")
+	f.IntVar(&l.RulerMaxConcurrentEvaluations, "ruler.max-concurrent-evaluations", 0, "Maximum number of rule groups of a tenant concurrently evaluated by each ruler. The rule group evaluations exceeding the limit wait until another evaluation completes, or until their deadline. 0 to disable.")
+	f.Var(&l.RulerRuleGroupEvaluationDeadline, "ruler.rule-group-evaluation-deadline", "Maximum duration of the evaluation of a rule group. The evaluation of the remaining rules of a rule group is skipped once the deadline is exceeded, so that a slow rule group doesn't delay its next evaluations. The deadline can't be greater than the rule group interval. 0 to use the rule group interval.")
 
 	f.Var(&l.CompactorBlocksRetentionPeriod, "compactor.blocks-retention-period", "Delete blocks containing samples older than the specified retention period. Also used by query-frontend to avoid querying beyond the retention period. 0 to disable.")
 	f.IntVar(&l.CompactorSplitAndMergeShards, "compactor.split-and-merge-shards", 0, "The number of shards to use when splitting blocks. 0 to disable splitting.")
@@ -225,12 +300,28 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.CompactorTenantShardSize, "compactor.compactor-tenant-shard-size", 0, "Max number of compactors that can compact blocks for single tenant. 0 to disable the limit and use all compactors.")
 	f.Var(&l.CompactorPartialBlockDeletionDelay, "compactor.partial-block-deletion-delay", fmt.Sprintf("If a partial block (unfinished block without %s file) hasn't been modified for this time, it will be marked for deletion. The minimum accepted value is %s: a lower value will be ignored and the feature disabled. 0 to disable.", block.MetaFilename, MinCompactorPartialBlockDeletionDelay.String()))
 	f.BoolVar(&l.CompactorBlockUploadEnabled, "compactor.block-upload-enabled", false, "Enable block upload API for the tenant.")
+	f.Var(&l.CompactorLargeCompactionsWindows, "compactor.large-compactions-windows", "Comma-separated list of time of day windows, in UTC and in the HH:MM-HH:MM format, for example 22:00-06:00, during which the compactor can start the large compaction jobs of the tenant: the jobs compacting blocks spanning more than the smallest compaction block range. The other jobs, like the ones compacting the blocks uploaded by the ingesters, run at any time. Empty to run the large compaction jobs at any time.")
 
 	// Query-frontend.
+	f.Var(&l.MaxQueryIntoFuture, "query-frontend.max-query-into-future", fmt.Sprintf("Limit how far into the future data can be queried, up until <now + max-query-into-future>. This limit is enforced in the query-frontend, in addition to -%s. If the requested time range is outside the allowed range, the request will not fail but will be manipulated to only query data within the allowed time range. 0 to disable.", creationGracePeriodFlag))
 	f.Var(&l.MaxTotalQueryLength, maxTotalQueryLengthFlag, fmt.Sprintf("Limit the total query time range (end - start time). This limit is enforced in the query-frontend on the received query. Defaults to the value of -%s if set to 0.", maxQueryLengthFlag))
+	f.Var(&l.QueryTimeout, "query-frontend.query-timeout", "Maximum time a query can take to execute in the query-frontend. Queries taking longer are canceled and fail with HTTP status code 504. When a query is executed on behalf of multiple tenants, the smallest timeout is used. 0 to disable.")
+	f.Var(&l.SlowQueryLogThreshold, "query-frontend.slow-query-log-threshold", "Per-tenant override of -query-frontend.log-queries-longer-than: the query-frontend logs the tenant's queries slower than the specified duration. When a query is executed on behalf of multiple tenants, the smallest threshold is used. 0 to use -query-frontend.log-queries-longer-than.")
+	f.IntVar(&l.MaxEstimatedQueryCost, maxEstimatedQueryCostFlag, 0, "Maximum estimated cost of a query, checked by the query-frontend before executing it. The cost is the estimated number of samples processed by the query: the number of series matching each selector, as reported by the ingesters' cardinality analysis, multiplied by the number of evaluation steps and by the samples in the selector range. Requires the cardinality analysis to be enabled for the tenant; if the cost can't be estimated, the query is executed. 0 to disable.")
+	f.IntVar(&l.MaxResponseSizeBytes, maxResponseSizeBytesFlag, 0, "Maximum size - in bytes - of a query response returned by the query-frontend to the client. Responses exceeding it are rejected with HTTP status code 422, while the responses of the paths configured with -query-frontend.streaming-path-prefixes, which may have already started, are aborted, so that the client doesn't receive a truncated response. When a query is executed on behalf of multiple tenants, the smallest limit is used. 0 to disable.")
+	_ = l.ResultsCacheTTL.Set("7d")
+	f.Var(&l.ResultsCacheTTL, "query-frontend.results-cache-ttl", "Time to live of the tenant's entries in the query-frontend results cache. The entries overlapping the out-of-order time window keep being cached for a shorter time. When a query is executed on behalf of multiple tenants, the smallest TTL is used.")
+	f.IntVar(&l.ResultsCacheMaxItemSizeBytes, "query-frontend.results-cache-max-item-size-bytes", 0, "Maximum size - in bytes - of the tenant's entries stored in the query-frontend results cache, before compression. Larger entries are not cached. When a query is executed on behalf of multiple tenants, the smallest limit is used. 0 to disable.")
+	f.StringVar(&l.ResultsCacheCompression, "query-frontend.results-cache-compression", "", fmt.Sprintf("Per-tenant override of -query-frontend.results-cache.compression. Supported values are: %s. When a query is executed on behalf of multiple tenants with different values, -query-frontend.results-cache.compression is used. Empty to use -query-frontend.results-cache.compression.", strings.Join(supportedResultsCacheCompressions, ", ")))
+	f.BoolVar(&l.QueryStepAlignEnabled, "query-frontend.query-step-align-enabled", false, "True to align the start and end of the tenant's range queries with their step, to improve the cacheability of the query results. Clients can opt out for a single query with the Step-Align-Control: disabled HTTP header. When a query is executed on behalf of multiple tenants, the query is aligned only if enabled for all of them. The queries of every tenant are aligned when -query-frontend.align-queries-with-step is enabled.")
 
 	// Store-gateway.
 	f.IntVar(&l.StoreGatewayTenantShardSize, "store-gateway.tenant-shard-size", 0, "The tenant's shard size, used when store-gateway sharding is enabled. Value of 0 disables shuffle sharding for the tenant, that is all tenant blocks are sharded across all store-gateway replicas.")
+	f.Float64Var(&l.StoreGatewayFetchedBytesRateLimit, "store-gateway.fetched-bytes-rate-limit", 0, "Per-tenant rate limit - in bytes/sec - of the chunk and index bytes fetched from the object storage by each store-gateway. The reads exceeding the limit fail with a resource exhausted error, which fails the query. 0 to disable.")
+	f.IntVar(&l.StoreGatewayFetchedBytesBurstSize, "store-gateway.fetched-bytes-burst-size", 0, "Per-tenant burst size - in bytes - of the chunk and index bytes fetched from the object storage by each store-gateway. 0 to use the rate limit as burst size.")
+	f.IntVar(&l.StoreGatewayIndexCacheMaxSizeBytes, "store-gateway.index-cache-max-size-bytes", 0, "Per-tenant quota - in bytes - of the in-memory index cache of each store-gateway, shared between all tenants. When the tenant's items exceed the quota, the least recently used items of the tenant are evicted, so that a single tenant can't evict the items of the other tenants. Only applies to the in-memory index cache backend. 0 to disable.")
+	f.Var(&l.StoreGatewayRecentBlocksPeriod, "store-gateway.recent-blocks-period", "The tenant's blocks whose max time is within this period are recent blocks, which are replicated across -store-gateway.recent-blocks-replication-factor store-gateways. 0 to disable.")
+	f.IntVar(&l.StoreGatewayRecentBlocksReplicationFactor, "store-gateway.recent-blocks-replication-factor", 0, "The replication factor of the tenant's recent blocks, used when store-gateway sharding is enabled. Recent blocks are replicated across more store-gateways when greater than -store-gateway.sharding-ring.replication-factor, which is used for the other blocks. 0 to use -store-gateway.sharding-ring.replication-factor.")
 
 	// Alertmanager.
 	f.Var(&l.AlertmanagerReceiversBlockCIDRNetworks, "alertmanager.receivers-firewall-block-cidr-networks", "Comma-separated list of network CIDRs to block in Alertmanager receiver integrations.")
@@ -269,7 +360,7 @@ func (l *Limits) UnmarshalYAML(value *yaml.Node) error {
 		return err
 	}
 
-	return nil
+	return l.Validate()
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
@@ -292,6 +383,40 @@ func (l *Limits) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	return l.Validate()
+}
+
+// Validate the limits.
+func (l *Limits) Validate() error {
+	if l.ResultsCacheCompression != "" && !util.StringsContain(supportedResultsCacheCompressions, l.ResultsCacheCompression) {
+		return fmt.Errorf("unsupported results cache compression: %q, supported values: %v", l.ResultsCacheCompression, supportedResultsCacheCompressions)
+	}
+
+	if l.LabelValueLengthStrategy != "" && !util.StringsContain(supportedLabelValueLengthOverLimitStrategies, l.LabelValueLengthStrategy) {
+		return fmt.Errorf("unsupported label value length over limit strategy: %q, supported values: %v", l.LabelValueLengthStrategy, supportedLabelValueLengthOverLimitStrategies)
+	}
+
+	if l.LabelValueLengthStrategy == LabelValueLengthOverLimitStrategyTruncate && l.MaxLabelValueLength < labelValueHashSuffixLength {
+		return fmt.Errorf("the label value length over limit strategy %q requires a max label value length of at least %d", LabelValueLengthOverLimitStrategyTruncate, labelValueHashSuffixLength)
+	}
+
+	if _, err := parseDebugSeriesSelector(l.DebugSeriesSelector); err != nil {
+		return fmt.Errorf("invalid debug series selector: %w", err)
+	}
+
+	for _, q := range l.BlockedQueries {
+		if q == nil || !q.Regex {
+			continue
+		}
+		if _, err := q.Regexp(); err != nil {
+			return fmt.Errorf("invalid blocked query regular expression %q: %w", q.Pattern, err)
+		}
+	}
+
+	if _, err := parseTimeOfDayWindows(l.CompactorLargeCompactionsWindows); err != nil {
+		return fmt.Errorf("invalid compactor large compactions windows: %w", err)
+	}
+
 	return nil
 }
 
@@ -373,6 +498,24 @@ func (o *Overrides) IngestionBurstSize(userID string) int {
 	return o.getOverridesForUser(userID).IngestionBurstSize
 }
 
+// RulerIngestionRate returns the limit on the ingestion rate (samples per second) of the samples written by the ruler.
+func (o *Overrides) RulerIngestionRate(userID string) float64 {
+	return o.getOverridesForUser(userID).RulerIngestionRate
+}
+
+// RulerIngestionBurstSize returns the burst size for the ingestion rate of the samples written by the ruler.
+func (o *Overrides) RulerIngestionBurstSize(userID string) int {
+	return o.getOverridesForUser(userID).RulerIngestionBurstSize
+}
+
+// DebugSeriesMatchers returns the matchers of the series whose samples are logged while going through the
+// write path, or nil if disabled.
+func (o *Overrides) DebugSeriesMatchers(userID string) []*labels.Matcher {
+	// The selector is validated when the limits are loaded.
+	matchers, _ := parseDebugSeriesSelector(o.getOverridesForUser(userID).DebugSeriesSelector)
+	return matchers
+}
+
 // AcceptHASamples returns whether the distributor should track and accept samples from HA replicas for this user.
 func (o *Overrides) AcceptHASamples(userID string) bool {
 	return o.getOverridesForUser(userID).AcceptHASamples
@@ -404,6 +547,16 @@ func (o *Overrides) MaxLabelValueLength(userID string) int {
 	return o.getOverridesForUser(userID).MaxLabelValueLength
 }
 
+// LabelValueLengthOverLimitStrategy returns what to do with the series having a label value longer than the max length.
+func (o *Overrides) LabelValueLengthOverLimitStrategy(userID string) string {
+	return o.getOverridesForUser(userID).LabelValueLengthStrategy
+}
+
+// MaxLabelsSizeBytes returns maximum combined size of the label names and values of a series.
+func (o *Overrides) MaxLabelsSizeBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxLabelsSizeBytes
+}
+
 // MaxLabelNamesPerSeries returns maximum number of label/value pairs timeseries.
 func (o *Overrides) MaxLabelNamesPerSeries(userID string) int {
 	return o.getOverridesForUser(userID).MaxLabelNamesPerSeries
@@ -457,6 +610,11 @@ func (o *Overrides) MaxQueryLength(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MaxQueryLength)
 }
 
+// MaxQueryIntoFuture returns how far into the future queries can query data.
+func (o *Overrides) MaxQueryIntoFuture(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).MaxQueryIntoFuture)
+}
+
 // MaxTotalQueryLength returns the limit of the total length (in time) of a query.
 func (o *Overrides) MaxTotalQueryLength(userID string) time.Duration {
 	t := time.Duration(o.getOverridesForUser(userID).MaxTotalQueryLength)
@@ -466,6 +624,55 @@ func (o *Overrides) MaxTotalQueryLength(userID string) time.Duration {
 	return t
 }
 
+// QueryTimeout returns the maximum time a query can take to execute in the query-frontend.
+func (o *Overrides) QueryTimeout(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).QueryTimeout)
+}
+
+// SlowQueryLogThreshold returns the duration above which the tenant's queries are logged as slow by the query-frontend.
+// 0 means the query-frontend default applies.
+func (o *Overrides) SlowQueryLogThreshold(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).SlowQueryLogThreshold)
+}
+
+// MaxEstimatedQueryCost returns the maximum estimated cost of a query, checked by the query-frontend before executing it.
+func (o *Overrides) MaxEstimatedQueryCost(userID string) int {
+	return o.getOverridesForUser(userID).MaxEstimatedQueryCost
+}
+
+// MaxResponseSizeBytes returns the maximum size of a query response returned by the query-frontend.
+func (o *Overrides) MaxResponseSizeBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxResponseSizeBytes
+}
+
+// ResultsCacheTTL returns the time to live of the tenant's entries in the query-frontend results cache.
+func (o *Overrides) ResultsCacheTTL(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).ResultsCacheTTL)
+}
+
+// ResultsCacheMaxItemSizeBytes returns the maximum size of the tenant's entries in the query-frontend results cache.
+// 0 means no limit.
+func (o *Overrides) ResultsCacheMaxItemSizeBytes(userID string) int {
+	return o.getOverridesForUser(userID).ResultsCacheMaxItemSizeBytes
+}
+
+// ResultsCacheCompression returns the compression of the tenant's entries in the query-frontend results cache.
+// Empty means the query-frontend default applies.
+func (o *Overrides) ResultsCacheCompression(userID string) string {
+	return o.getOverridesForUser(userID).ResultsCacheCompression
+}
+
+// QueryStepAlignEnabled returns whether the query-frontend should align the start and end
+// of the tenant's range queries with their step.
+func (o *Overrides) QueryStepAlignEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).QueryStepAlignEnabled
+}
+
+// BlockedQueries returns the queries rejected by the query-frontend for the tenant.
+func (o *Overrides) BlockedQueries(userID string) []*BlockedQuery {
+	return o.getOverridesForUser(userID).BlockedQueries
+}
+
 // MaxLabelsQueryLength returns the limit of the length (in time) of a label names or values request.
 func (o *Overrides) MaxLabelsQueryLength(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MaxLabelsQueryLength)
@@ -500,6 +707,18 @@ func (o *Overrides) QueryShardingMaxShardedQueries(userID string) int {
 	return o.getOverridesForUser(userID).QueryShardingMaxShardedQueries
 }
 
+// QueryShardingVerticalEnabled returns whether the queries without aggregations
+// should be sharded by series via the query-frontend.
+func (o *Overrides) QueryShardingVerticalEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).QueryShardingVerticalEnabled
+}
+
+// QueryShardingTargetSeriesPerShard returns the target number of series per shard used to
+// pick the number of shards of a query from its estimated series count. 0 to disable.
+func (o *Overrides) QueryShardingTargetSeriesPerShard(userID string) int {
+	return o.getOverridesForUser(userID).QueryShardingTargetSeriesPerShard
+}
+
 // SplitInstantQueriesByInterval returns the split time interval to use when splitting an instant query
 // via the query-frontend. 0 to disable limit.
 func (o *Overrides) SplitInstantQueriesByInterval(userID string) time.Duration {
@@ -526,6 +745,16 @@ func (o *Overrides) MaxGlobalExemplarsPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxGlobalExemplarsPerUser
 }
 
+// ExemplarsRetentionPeriod returns the period after which the exemplars are rejected and not returned by queries.
+func (o *Overrides) ExemplarsRetentionPeriod(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).ExemplarsRetentionPeriod)
+}
+
+// MaxExemplarsPerQuery returns the maximum number of exemplars returned by an exemplar query.
+func (o *Overrides) MaxExemplarsPerQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxExemplarsPerQuery
+}
+
 func (o *Overrides) ActiveSeriesCustomTrackersConfig(userID string) activeseries.CustomTrackersConfig {
 	return o.getOverridesForUser(userID).ActiveSeriesCustomTrackersConfig
 }
@@ -584,6 +813,14 @@ func (o *Overrides) CompactorBlockUploadEnabled(tenantID string) bool {
 	return o.getOverridesForUser(tenantID).CompactorBlockUploadEnabled
 }
 
+// CompactorLargeCompactionsWindows returns the time of day windows during which the large compaction jobs of the
+// tenant can start. No window means the jobs can start at any time.
+func (o *Overrides) CompactorLargeCompactionsWindows(userID string) []TimeOfDayWindow {
+	// The windows have been validated when the limits have been loaded.
+	windows, _ := parseTimeOfDayWindows(o.getOverridesForUser(userID).CompactorLargeCompactionsWindows)
+	return windows
+}
+
 // MetricRelabelConfigs returns the metric relabel configs for a given user.
 func (o *Overrides) MetricRelabelConfigs(userID string) []*relabel.Config {
 	return o.getOverridesForUser(userID).MetricRelabelConfigs
@@ -614,11 +851,46 @@ func (o *Overrides) RulerAlertingRulesEvaluationEnabled(userID string) bool {
 	return o.getOverridesForUser(userID).RulerAlertingRulesEvaluationEnabled
 }
 
+// RulerMaxConcurrentEvaluations returns the maximum number of rule groups of a given user concurrently evaluated.
+func (o *Overrides) RulerMaxConcurrentEvaluations(userID string) int {
+	return o.getOverridesForUser(userID).RulerMaxConcurrentEvaluations
+}
+
+// RulerRuleGroupEvaluationDeadline returns the maximum duration of the evaluation of a rule group for a given user.
+func (o *Overrides) RulerRuleGroupEvaluationDeadline(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).RulerRuleGroupEvaluationDeadline)
+}
+
 // StoreGatewayTenantShardSize returns the store-gateway shard size for a given user.
 func (o *Overrides) StoreGatewayTenantShardSize(userID string) int {
 	return o.getOverridesForUser(userID).StoreGatewayTenantShardSize
 }
 
+// StoreGatewayFetchedBytesRateLimit returns the limit to the bytes fetched per second by each store-gateway for a given user.
+func (o *Overrides) StoreGatewayFetchedBytesRateLimit(userID string) float64 {
+	return o.getOverridesForUser(userID).StoreGatewayFetchedBytesRateLimit
+}
+
+// StoreGatewayFetchedBytesBurstSize returns the burst size of the bytes fetched by each store-gateway for a given user.
+func (o *Overrides) StoreGatewayFetchedBytesBurstSize(userID string) int {
+	return o.getOverridesForUser(userID).StoreGatewayFetchedBytesBurstSize
+}
+
+// StoreGatewayRecentBlocksPeriod returns the period of the recent blocks of a given user in the store-gateways.
+func (o *Overrides) StoreGatewayRecentBlocksPeriod(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).StoreGatewayRecentBlocksPeriod)
+}
+
+// StoreGatewayRecentBlocksReplicationFactor returns the replication factor of the recent blocks of a given user in the store-gateways.
+func (o *Overrides) StoreGatewayRecentBlocksReplicationFactor(userID string) int {
+	return o.getOverridesForUser(userID).StoreGatewayRecentBlocksReplicationFactor
+}
+
+// StoreGatewayIndexCacheMaxSizeBytes returns the quota of a given user in the in-memory index cache of each store-gateway.
+func (o *Overrides) StoreGatewayIndexCacheMaxSizeBytes(userID string) int {
+	return o.getOverridesForUser(userID).StoreGatewayIndexCacheMaxSizeBytes
+}
+
 // MaxHAClusters returns maximum number of clusters that HA tracker will track for a user.
 func (o *Overrides) MaxHAClusters(user string) int {
 	return o.getOverridesForUser(user).HAMaxClusters
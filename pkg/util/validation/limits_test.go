@@ -90,6 +90,38 @@ func TestLimitsLoadingFromJson(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestLimitsValidation(t *testing.T) {
+	for _, compression := range []string{"", "none", "snappy", "zstd"} {
+		l := Limits{}
+		assert.NoError(t, yaml.Unmarshal([]byte(`results_cache_compression: "`+compression+`"`), &l), compression)
+		assert.NoError(t, json.Unmarshal([]byte(`{"results_cache_compression": "`+compression+`"}`), &l), compression)
+	}
+
+	l := Limits{}
+	assert.EqualError(t, yaml.Unmarshal([]byte(`results_cache_compression: unknown`), &l), `unsupported results cache compression: "unknown", supported values: [none snappy zstd]`)
+	assert.EqualError(t, json.Unmarshal([]byte(`{"results_cache_compression": "unknown"}`), &l), `unsupported results cache compression: "unknown", supported values: [none snappy zstd]`)
+
+	l = Limits{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`debug_series_selector: '{__name__="up"}'`), &l))
+	assert.Error(t, yaml.Unmarshal([]byte(`debug_series_selector: 'up{'`), &l))
+
+	l = Limits{}
+	assert.NoError(t, yaml.Unmarshal([]byte("blocked_queries:\n- pattern: up\n- pattern: '.*expensive_metric.*'\n  regex: true"), &l))
+	assert.NoError(t, yaml.Unmarshal([]byte("blocked_queries:\n- pattern: '[invalid'"), &l))
+	assert.EqualError(t, yaml.Unmarshal([]byte("blocked_queries:\n- pattern: '[invalid'\n  regex: true"), &l), "invalid blocked query regular expression \"[invalid\": error parsing regexp: missing closing ]: `[invalid)$`")
+
+	l = Limits{}
+	assert.NoError(t, yaml.Unmarshal([]byte(`compactor_large_compactions_windows: "22:00-06:00,12:00-13:30"`), &l))
+	assert.EqualError(t, yaml.Unmarshal([]byte(`compactor_large_compactions_windows: "22:00"`), &l), `invalid compactor large compactions windows: invalid time of day window "22:00": expected format is HH:MM-HH:MM`)
+	assert.Error(t, yaml.Unmarshal([]byte(`compactor_large_compactions_windows: "22:00-25:00"`), &l))
+	assert.Error(t, yaml.Unmarshal([]byte(`compactor_large_compactions_windows: "22:00-22:00"`), &l))
+
+	l = Limits{}
+	assert.NoError(t, yaml.Unmarshal([]byte("label_value_length_over_limit_strategy: truncate\nmax_label_value_length: 100"), &l))
+	assert.EqualError(t, yaml.Unmarshal([]byte("label_value_length_over_limit_strategy: truncate\nmax_label_value_length: 10"), &l), `the label value length over limit strategy "truncate" requires a max label value length of at least 23`)
+	assert.EqualError(t, yaml.Unmarshal([]byte("label_value_length_over_limit_strategy: drop"), &l), `unsupported label value length over limit strategy: "drop", supported values: [error truncate]`)
+}
+
 func TestLimitsTagsYamlMatchJson(t *testing.T) {
 	limits := reflect.TypeOf(Limits{})
 	n := limits.NumField()
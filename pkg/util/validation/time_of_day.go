@@ -0,0 +1,78 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const timeOfDayLayout = "15:04"
+
+// TimeOfDayWindow is a window of time of the day, in UTC, starting at Start and ending at End, both expressed
+// as the time elapsed since midnight. The window spans midnight if End is before Start.
+type TimeOfDayWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Contains returns whether the time of the day of t, in UTC, is within the window.
+func (w TimeOfDayWindow) Contains(t time.Time) bool {
+	t = t.UTC()
+	sinceMidnight := t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+
+	if w.Start <= w.End {
+		return sinceMidnight >= w.Start && sinceMidnight < w.End
+	}
+	return sinceMidnight >= w.Start || sinceMidnight < w.End
+}
+
+func (w TimeOfDayWindow) String() string {
+	midnight := time.Time{}
+	return midnight.Add(w.Start).Format(timeOfDayLayout) + "-" + midnight.Add(w.End).Format(timeOfDayLayout)
+}
+
+// TimeOfDayWindowsContain returns whether the time of the day of t, in UTC, is within any of the windows.
+func TimeOfDayWindowsContain(windows []TimeOfDayWindow, t time.Time) bool {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeOfDayWindows parses windows in the HH:MM-HH:MM format, for example 22:00-06:00.
+func parseTimeOfDayWindows(windows []string) ([]TimeOfDayWindow, error) {
+	if len(windows) == 0 {
+		return nil, nil
+	}
+
+	parsed := make([]TimeOfDayWindow, 0, len(windows))
+	for _, window := range windows {
+		start, end, ok := strings.Cut(strings.TrimSpace(window), "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid time of day window %q: expected format is HH:MM-HH:MM", window)
+		}
+
+		startTime, err := time.Parse(timeOfDayLayout, start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start of time of day window %q: %w", window, err)
+		}
+		endTime, err := time.Parse(timeOfDayLayout, end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end of time of day window %q: %w", window, err)
+		}
+		if startTime.Equal(endTime) {
+			return nil, fmt.Errorf("invalid time of day window %q: start and end must be different", window)
+		}
+
+		midnight, _ := time.Parse(timeOfDayLayout, "00:00")
+		parsed = append(parsed, TimeOfDayWindow{
+			Start: startTime.Sub(midnight),
+			End:   endTime.Sub(midnight),
+		})
+	}
+	return parsed, nil
+}
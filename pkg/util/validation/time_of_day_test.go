@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeOfDayWindows(t *testing.T) {
+	windows, err := parseTimeOfDayWindows([]string{"22:00-06:00", " 12:00-13:30"})
+	require.NoError(t, err)
+	require.Equal(t, []TimeOfDayWindow{
+		{Start: 22 * time.Hour, End: 6 * time.Hour},
+		{Start: 12 * time.Hour, End: 13*time.Hour + 30*time.Minute},
+	}, windows)
+	assert.Equal(t, "22:00-06:00", windows[0].String())
+	assert.Equal(t, "12:00-13:30", windows[1].String())
+
+	at := func(hour, min int) time.Time {
+		return time.Date(2022, 11, 1, hour, min, 0, 0, time.UTC)
+	}
+	for _, tc := range []struct {
+		time     time.Time
+		expected bool
+	}{
+		{time: at(21, 59), expected: false},
+		{time: at(22, 0), expected: true},
+		{time: at(0, 0), expected: true},
+		{time: at(5, 59), expected: true},
+		{time: at(6, 0), expected: false},
+		{time: at(12, 30), expected: true},
+		{time: at(13, 30), expected: false},
+		// The time of the day is computed in UTC.
+		{time: at(23, 0).In(time.FixedZone("UTC-10", -10*60*60)), expected: true},
+	} {
+		assert.Equal(t, tc.expected, TimeOfDayWindowsContain(windows, tc.time), tc.time.String())
+	}
+
+	assert.False(t, TimeOfDayWindowsContain(nil, at(0, 0)))
+}
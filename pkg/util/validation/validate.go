@@ -6,10 +6,12 @@
 package validation
 
 import (
+	"fmt"
 	"strings"
 	"time"
 	"unicode/utf8"
 
+	"github.com/cespare/xxhash"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/model"
@@ -25,6 +27,9 @@ const (
 	// The combined length of the label names and values of an Exemplar's LabelSet MUST NOT exceed 128 UTF-8 characters
 	// https://github.com/OpenObservability/OpenMetrics/blob/main/specification/OpenMetrics.md#exemplars
 	ExemplarMaxLabelSetLength = 128
+
+	// labelValueHashSuffixLength is the length of the "(hash:<16 hex digits>)" suffix of truncated label values.
+	labelValueHashSuffixLength = 23
 )
 
 var (
@@ -32,6 +37,7 @@ var (
 	reasonMissingMetricName      = metricReasonFromErrorID(globalerror.MissingMetricName)
 	reasonInvalidMetricName      = metricReasonFromErrorID(globalerror.InvalidMetricName)
 	reasonMaxLabelNamesPerSeries = metricReasonFromErrorID(globalerror.MaxLabelNamesPerSeries)
+	reasonMaxLabelsSizeBytes     = metricReasonFromErrorID(globalerror.MaxLabelsSizeBytes)
 	reasonInvalidLabel           = metricReasonFromErrorID(globalerror.SeriesInvalidLabel)
 	reasonLabelNameTooLong       = metricReasonFromErrorID(globalerror.SeriesLabelNameTooLong)
 	reasonLabelValueTooLong      = metricReasonFromErrorID(globalerror.SeriesLabelValueTooLong)
@@ -117,24 +123,30 @@ type SampleValidationMetrics struct {
 	missingMetricName      *prometheus.CounterVec
 	invalidMetricName      *prometheus.CounterVec
 	maxLabelNamesPerSeries *prometheus.CounterVec
+	maxLabelsSizeBytes     *prometheus.CounterVec
 	invalidLabel           *prometheus.CounterVec
 	labelNameTooLong       *prometheus.CounterVec
 	labelValueTooLong      *prometheus.CounterVec
 	duplicateLabelNames    *prometheus.CounterVec
 	labelsNotSorted        *prometheus.CounterVec
 	tooFarInFuture         *prometheus.CounterVec
+
+	// Label values truncated because exceeding the max length, when the truncate strategy is configured.
+	labelValuesTruncated *prometheus.CounterVec
 }
 
 func (m *SampleValidationMetrics) DeleteUserMetrics(userID string) {
 	m.missingMetricName.DeleteLabelValues(userID)
 	m.invalidMetricName.DeleteLabelValues(userID)
 	m.maxLabelNamesPerSeries.DeleteLabelValues(userID)
+	m.maxLabelsSizeBytes.DeleteLabelValues(userID)
 	m.invalidLabel.DeleteLabelValues(userID)
 	m.labelNameTooLong.DeleteLabelValues(userID)
 	m.labelValueTooLong.DeleteLabelValues(userID)
 	m.duplicateLabelNames.DeleteLabelValues(userID)
 	m.labelsNotSorted.DeleteLabelValues(userID)
 	m.tooFarInFuture.DeleteLabelValues(userID)
+	m.labelValuesTruncated.DeleteLabelValues(userID)
 }
 
 func NewSampleValidationMetrics(r prometheus.Registerer) *SampleValidationMetrics {
@@ -142,12 +154,18 @@ func NewSampleValidationMetrics(r prometheus.Registerer) *SampleValidationMetric
 		missingMetricName:      DiscardedSamplesCounter(r, reasonMissingMetricName),
 		invalidMetricName:      DiscardedSamplesCounter(r, reasonInvalidMetricName),
 		maxLabelNamesPerSeries: DiscardedSamplesCounter(r, reasonMaxLabelNamesPerSeries),
+		maxLabelsSizeBytes:     DiscardedSamplesCounter(r, reasonMaxLabelsSizeBytes),
 		invalidLabel:           DiscardedSamplesCounter(r, reasonInvalidLabel),
 		labelNameTooLong:       DiscardedSamplesCounter(r, reasonLabelNameTooLong),
 		labelValueTooLong:      DiscardedSamplesCounter(r, reasonLabelValueTooLong),
 		duplicateLabelNames:    DiscardedSamplesCounter(r, reasonDuplicateLabelNames),
 		labelsNotSorted:        DiscardedSamplesCounter(r, reasonLabelsNotSorted),
 		tooFarInFuture:         DiscardedSamplesCounter(r, reasonTooFarInFuture),
+
+		labelValuesTruncated: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_truncated_label_values_total",
+			Help: "The total number of label values truncated because their length exceeded the limit.",
+		}, []string{"user"}),
 	}
 }
 
@@ -258,10 +276,13 @@ type LabelValidationConfig interface {
 	MaxLabelNamesPerSeries(userID string) int
 	MaxLabelNameLength(userID string) int
 	MaxLabelValueLength(userID string) int
+	LabelValueLengthOverLimitStrategy(userID string) string
+	MaxLabelsSizeBytes(userID string) int
 }
 
 // ValidateLabels returns an err if the labels are invalid.
 // The returned error may retain the provided series labels.
+// Label values exceeding the max length are truncated in place if the truncate strategy is configured for the tenant.
 func ValidateLabels(m *SampleValidationMetrics, cfg LabelValidationConfig, userID string, ls []mimirpb.LabelAdapter, skipLabelNameValidation bool) ValidationError {
 	unsafeMetricName, err := extract.UnsafeMetricNameFromLabelAdapters(ls)
 	if err != nil {
@@ -282,15 +303,18 @@ func ValidateLabels(m *SampleValidationMetrics, cfg LabelValidationConfig, userI
 
 	maxLabelNameLength := cfg.MaxLabelNameLength(userID)
 	maxLabelValueLength := cfg.MaxLabelValueLength(userID)
+	truncateLabelValues := cfg.LabelValueLengthOverLimitStrategy(userID) == LabelValueLengthOverLimitStrategyTruncate
+	maxLabelsSizeBytes := cfg.MaxLabelsSizeBytes(userID)
+	labelsSizeBytes := 0
 	lastLabelName := ""
-	for _, l := range ls {
+	for i, l := range ls {
 		if !skipLabelNameValidation && !model.LabelName(l.Name).IsValid() {
 			m.invalidLabel.WithLabelValues(userID).Inc()
 			return newInvalidLabelError(ls, l.Name)
 		} else if len(l.Name) > maxLabelNameLength {
 			m.labelNameTooLong.WithLabelValues(userID).Inc()
 			return newLabelNameTooLongError(ls, l.Name)
-		} else if len(l.Value) > maxLabelValueLength {
+		} else if len(l.Value) > maxLabelValueLength && (!truncateLabelValues || l.Name == model.MetricNameLabel) {
 			m.labelValueTooLong.WithLabelValues(userID).Inc()
 			return newLabelValueTooLongError(ls, l.Value)
 		} else if lastLabelName == l.Name {
@@ -301,11 +325,36 @@ func ValidateLabels(m *SampleValidationMetrics, cfg LabelValidationConfig, userI
 			return newLabelsNotSortedError(ls, l.Name)
 		}
 
+		if len(l.Value) > maxLabelValueLength {
+			ls[i].Value = truncateLabelValue(l.Value, maxLabelValueLength)
+			m.labelValuesTruncated.WithLabelValues(userID).Inc()
+		}
+
+		labelsSizeBytes += len(ls[i].Name) + len(ls[i].Value)
 		lastLabelName = l.Name
 	}
+
+	if maxLabelsSizeBytes > 0 && labelsSizeBytes > maxLabelsSizeBytes {
+		m.maxLabelsSizeBytes.WithLabelValues(userID).Inc()
+		return newLabelsSizeTooLargeError(ls, labelsSizeBytes, maxLabelsSizeBytes)
+	}
 	return nil
 }
 
+// truncateLabelValue truncates the input value to maxLength bytes. The truncated value is annotated with
+// the hash of the original value, so that series whose label values only differ after the truncation point
+// don't collide. maxLength is expected to be greater than or equal to labelValueHashSuffixLength.
+func truncateLabelValue(value string, maxLength int) string {
+	prefixLength := maxLength - labelValueHashSuffixLength
+
+	// Do not split a multi-byte character.
+	for prefixLength > 0 && !utf8.RuneStart(value[prefixLength]) {
+		prefixLength--
+	}
+
+	return fmt.Sprintf("%s(hash:%016x)", value[:prefixLength], xxhash.Sum64String(value))
+}
+
 // MetadataValidationMetrics is a collection of metrics used by metadata validation.
 type MetadataValidationMetrics struct {
 	missingMetricName *prometheus.CounterVec
@@ -6,9 +6,12 @@
 package validation
 
 import (
+	"fmt"
 	"strings"
 	"testing"
+	"unicode/utf8"
 
+	"github.com/cespare/xxhash"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/common/model"
@@ -19,9 +22,11 @@ import (
 )
 
 type validateLabelsCfg struct {
-	maxLabelNamesPerSeries int
-	maxLabelNameLength     int
-	maxLabelValueLength    int
+	maxLabelNamesPerSeries   int
+	maxLabelNameLength       int
+	maxLabelValueLength      int
+	labelValueLengthStrategy string
+	maxLabelsSizeBytes       int
 }
 
 func (v validateLabelsCfg) MaxLabelNamesPerSeries(userID string) int {
@@ -36,6 +41,14 @@ func (v validateLabelsCfg) MaxLabelValueLength(userID string) int {
 	return v.maxLabelValueLength
 }
 
+func (v validateLabelsCfg) LabelValueLengthOverLimitStrategy(userID string) string {
+	return v.labelValueLengthStrategy
+}
+
+func (v validateLabelsCfg) MaxLabelsSizeBytes(userID string) int {
+	return v.maxLabelsSizeBytes
+}
+
 type validateMetadataCfg struct {
 	enforceMetadataMetricName bool
 	maxMetadataLength         int
@@ -59,6 +72,7 @@ func TestValidateLabels(t *testing.T) {
 	cfg.maxLabelValueLength = 25
 	cfg.maxLabelNameLength = 25
 	cfg.maxLabelNamesPerSeries = 2
+	cfg.maxLabelsSizeBytes = 40
 
 	for _, c := range []struct {
 		metric                  model.Metric
@@ -118,6 +132,14 @@ func TestValidateLabels(t *testing.T) {
 			true,
 			nil,
 		},
+		{
+			map[model.LabelName]model.LabelValue{model.MetricNameLabel: "labelsSizeTooLarge", "label_name": "label_value"},
+			false,
+			newLabelsSizeTooLargeError([]mimirpb.LabelAdapter{
+				{Name: model.MetricNameLabel, Value: "labelsSizeTooLarge"},
+				{Name: "label_name", Value: "label_value"},
+			}, 47, 40),
+		},
 	} {
 		err := ValidateLabels(s, cfg, userID, mimirpb.FromMetricsToLabelAdapters(c.metric), c.skipLabelNameValidation)
 		assert.Equal(t, c.err, err, "wrong error")
@@ -133,6 +155,7 @@ func TestValidateLabels(t *testing.T) {
 			cortex_discarded_samples_total{reason="label_name_too_long",user="testUser"} 1
 			cortex_discarded_samples_total{reason="label_value_too_long",user="testUser"} 1
 			cortex_discarded_samples_total{reason="max_label_names_per_series",user="testUser"} 1
+			cortex_discarded_samples_total{reason="max_labels_size_bytes",user="testUser"} 1
 			cortex_discarded_samples_total{reason="metric_name_invalid",user="testUser"} 1
 			cortex_discarded_samples_total{reason="missing_metric_name",user="testUser"} 1
 
@@ -311,6 +334,70 @@ func TestValidateMetadata(t *testing.T) {
 	`), "cortex_discarded_metadata_total"))
 }
 
+func TestValidateLabels_TruncateLabelValues(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	s := NewSampleValidationMetrics(reg)
+
+	cfg := validateLabelsCfg{
+		maxLabelNamesPerSeries:   10,
+		maxLabelNameLength:       25,
+		maxLabelValueLength:      30,
+		labelValueLengthStrategy: LabelValueLengthOverLimitStrategyTruncate,
+	}
+	userID := "testUser"
+
+	longValue := "this_is_a_very_long_value_that_exceeds_the_limit"
+	otherLongValue := "this_is_a_very_long_value_that_exceeds_the_limit_too"
+
+	ls := []mimirpb.LabelAdapter{
+		{Name: model.MetricNameLabel, Value: "foo"},
+		{Name: "long", Value: longValue},
+		{Name: "other_long", Value: otherLongValue},
+		{Name: "short", Value: "bar"},
+	}
+	require.NoError(t, ValidateLabels(s, cfg, userID, ls, false))
+
+	// Label values exceeding the limit are truncated and annotated with the hash of the original value.
+	assert.Equal(t, "this_is(hash:"+fmt.Sprintf("%016x", xxhash.Sum64String(longValue))+")", ls[1].Value)
+	assert.Equal(t, "this_is(hash:"+fmt.Sprintf("%016x", xxhash.Sum64String(otherLongValue))+")", ls[2].Value)
+	assert.Len(t, ls[1].Value, cfg.maxLabelValueLength)
+	assert.NotEqual(t, ls[1].Value, ls[2].Value)
+	assert.Equal(t, "bar", ls[3].Value)
+
+	// Multi-byte characters are not split.
+	ls = []mimirpb.LabelAdapter{
+		{Name: model.MetricNameLabel, Value: "foo"},
+		{Name: "long", Value: "€€€€€€€€€€€€€€€€"},
+	}
+	require.NoError(t, ValidateLabels(s, cfg, userID, ls, false))
+	assert.True(t, strings.HasPrefix(ls[1].Value, "€€(hash:"), ls[1].Value)
+	assert.True(t, utf8.ValidString(ls[1].Value))
+
+	// The metric name is never truncated.
+	ls = []mimirpb.LabelAdapter{
+		{Name: model.MetricNameLabel, Value: "this_is_a_very_long_metric_name_exceeding_the_limit"},
+	}
+	assert.Equal(t, newLabelValueTooLongError(ls, ls[0].Value), ValidateLabels(s, cfg, userID, ls, false))
+
+	// The labels size limit is checked after the truncation.
+	cfg.maxLabelsSizeBytes = 45
+	ls = []mimirpb.LabelAdapter{
+		{Name: model.MetricNameLabel, Value: "foo"},
+		{Name: "long", Value: longValue},
+	}
+	require.NoError(t, ValidateLabels(s, cfg, userID, ls, false))
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_discarded_samples_total The total number of samples that were discarded.
+			# TYPE cortex_discarded_samples_total counter
+			cortex_discarded_samples_total{reason="label_value_too_long",user="testUser"} 1
+
+			# HELP cortex_truncated_label_values_total The total number of label values truncated because their length exceeded the limit.
+			# TYPE cortex_truncated_label_values_total counter
+			cortex_truncated_label_values_total{user="testUser"} 4
+	`), "cortex_discarded_samples_total", "cortex_truncated_label_values_total"))
+}
+
 func TestValidateLabelOrder(t *testing.T) {
 	var cfg validateLabelsCfg
 	cfg.maxLabelNameLength = 10